@@ -110,6 +110,17 @@ func delete(ctx context.Context, containerID string, force bool) error {
 		forceStop = true
 	}
 
+	// The post-stop hooks are passed the sandbox as it was before the
+	// deletion, failing to get it must not prevent the deletion.
+	sandboxStatus := vc.SandboxStatus{ID: sandboxID}
+	if ociSpec.Hooks != nil && len(ociSpec.Hooks.Poststop) > 0 {
+		if s, err := vci.StatusSandbox(ctx, sandboxID); err != nil {
+			kataLog.WithError(err).Warn("Failed to get the sandbox status for the post-stop hooks")
+		} else {
+			sandboxStatus = s
+		}
+	}
+
 	switch containerType {
 	case vc.PodSandbox:
 		if err := deleteSandbox(ctx, sandboxID, force); err != nil {
@@ -124,7 +135,7 @@ func delete(ctx context.Context, containerID string, force bool) error {
 	}
 
 	// Run post-stop OCI hooks.
-	if err := katautils.PostStopHooks(ctx, ociSpec, sandboxID, status.Annotations[vcAnnot.BundlePathKey]); err != nil {
+	if err := katautils.PostStopHooks(ctx, ociSpec, sandboxID, status.Annotations[vcAnnot.BundlePathKey], sandboxStatus); err != nil {
 		return err
 	}
 
@@ -7,6 +7,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -29,12 +30,26 @@ type event struct {
 
 // stats is the runc specific stats structure for stability when encoding and decoding stats.
 type stats struct {
-	CPU      cpu                `json:"cpu"`
-	Memory   memory             `json:"memory"`
-	Pids     pids               `json:"pids"`
-	Blkio    blkio              `json:"blkio"`
-	Hugetlb  map[string]hugetlb `json:"hugetlb"`
-	IntelRdt intelRdt           `json:"intel_rdt"`
+	CPU               cpu                 `json:"cpu"`
+	Memory            memory              `json:"memory"`
+	Pids              pids                `json:"pids"`
+	Blkio             blkio               `json:"blkio"`
+	Hugetlb           map[string]hugetlb  `json:"hugetlb"`
+	IntelRdt          intelRdt            `json:"intel_rdt"`
+	NetworkInterfaces []*networkInterface `json:"network_interfaces,omitempty"`
+}
+
+// networkInterface has the stats of an interface of the VM
+type networkInterface struct {
+	Name      string `json:"name"`
+	RxBytes   uint64 `json:"rx_bytes"`
+	RxPackets uint64 `json:"rx_packets"`
+	RxErrors  uint64 `json:"rx_errors"`
+	RxDropped uint64 `json:"rx_dropped"`
+	TxBytes   uint64 `json:"tx_bytes"`
+	TxPackets uint64 `json:"tx_packets"`
+	TxErrors  uint64 `json:"tx_errors"`
+	TxDropped uint64 `json:"tx_dropped"`
 }
 
 type hugetlb struct {
@@ -125,7 +140,7 @@ var eventsCLICommand = cli.Command{
 
 Where "<container-id>" is the name for the instance of the container.`,
 	Description: `The events command displays information about the container. By default the
-information is displayed once every 5 seconds.`,
+information is displayed once every 5 seconds, until the container stops.`,
 	Flags: []cli.Flag{
 		cli.DurationFlag{
 			Name:  "interval",
@@ -208,9 +223,15 @@ information is displayed once every 5 seconds.`,
 		}
 
 		go func() {
-			for range time.Tick(context.Duration("interval")) {
+			defer close(events)
+			for range time.Tick(duration) {
 				s, err := vci.StatsContainer(ctx, sandboxID, containerID)
 				if err != nil {
+					// the stats of a stopped container are not
+					// available anymore, the stream ends with it
+					if !containerRunning(ctx, sandboxID, containerID) {
+						return
+					}
 					logrus.Error(err)
 					continue
 				}
@@ -223,6 +244,12 @@ information is displayed once every 5 seconds.`,
 	},
 }
 
+// containerRunning returns whether the container is still running
+func containerRunning(ctx context.Context, sandboxID, containerID string) bool {
+	status, err := vci.StatusContainer(ctx, sandboxID, containerID)
+	return err == nil && status.State.State != types.StateStopped
+}
+
 func convertVirtcontainerStats(containerStats *vc.ContainerStats) *stats {
 	cg := containerStats.CgroupStats
 	if cg == nil {
@@ -261,6 +288,20 @@ func convertVirtcontainerStats(containerStats *vc.ContainerStats) *stats {
 		s.Hugetlb[k] = convertHugtlb(v)
 	}
 
+	for _, n := range containerStats.NetworkStats {
+		s.NetworkInterfaces = append(s.NetworkInterfaces, &networkInterface{
+			Name:      n.Name,
+			RxBytes:   n.RxBytes,
+			RxPackets: n.RxPackets,
+			RxErrors:  n.RxErrors,
+			RxDropped: n.RxDropped,
+			TxBytes:   n.TxBytes,
+			TxPackets: n.TxPackets,
+			TxErrors:  n.TxErrors,
+			TxDropped: n.TxDropped,
+		})
+	}
+
 	return &s
 }
 
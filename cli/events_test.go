@@ -7,6 +7,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"os"
 	"testing"
@@ -142,3 +143,88 @@ func TestEventsCLISuccessful(t *testing.T) {
 	err = actionFunc(ctx)
 	assert.NoError(err)
 }
+
+func TestEventsCLIStopsWithContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	sandbox := &vcmock.Sandbox{
+		MockID: testContainerID,
+	}
+
+	sandbox.MockContainers = []*vcmock.Container{
+		{
+			MockID:      sandbox.ID(),
+			MockSandbox: sandbox,
+		},
+	}
+
+	// the container stops after the first stats
+	state := types.StateRunning
+	testingImpl.StatusContainerFunc = func(ctx context.Context, sandboxID, containerID string) (vc.ContainerStatus, error) {
+		return vc.ContainerStatus{
+			ID: sandbox.ID(),
+			Annotations: map[string]string{
+				vcAnnotations.ContainerTypeKey: string(vc.PodContainer),
+			},
+			State: types.ContainerState{
+				State: state,
+			},
+		}, nil
+	}
+
+	testingImpl.StatsContainerFunc = func(ctx context.Context, sandboxID, containerID string) (vc.ContainerStats, error) {
+		if state == types.StateStopped {
+			return vc.ContainerStats{}, errors.New("container not running")
+		}
+		state = types.StateStopped
+		return vc.ContainerStats{}, nil
+	}
+
+	defer func() {
+		testingImpl.StatusContainerFunc = nil
+		testingImpl.StatsContainerFunc = nil
+	}()
+
+	path, err := createTempContainerIDMapping(sandbox.ID(), sandbox.ID())
+	assert.NoError(err)
+	defer os.RemoveAll(path)
+
+	actionFunc, ok := eventsCLICommand.Action.(func(ctx *cli.Context) error)
+	assert.True(ok)
+
+	flagSet := flag.NewFlagSet("events", flag.ContinueOnError)
+	flagSet.Parse([]string{testContainerID})
+	flagSet.Duration("interval", 10*time.Millisecond, "")
+	ctx := createCLIContext(flagSet)
+	assert.NoError(actionFunc(ctx))
+}
+
+func TestConvertVirtcontainerStats(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(convertVirtcontainerStats(&vc.ContainerStats{}))
+
+	s := convertVirtcontainerStats(&vc.ContainerStats{
+		CgroupStats: &vc.CgroupStats{},
+		NetworkStats: []*vc.NetworkStats{
+			{
+				Name:      "eth0",
+				RxBytes:   1024,
+				RxPackets: 8,
+				TxBytes:   512,
+				TxPackets: 4,
+				TxDropped: 1,
+			},
+		},
+	})
+	assert.Equal([]*networkInterface{
+		{
+			Name:      "eth0",
+			RxBytes:   1024,
+			RxPackets: 8,
+			TxBytes:   512,
+			TxPackets: 4,
+			TxDropped: 1,
+		},
+	}, s.NetworkInterfaces)
+}
@@ -19,6 +19,7 @@ import (
 	pb "github.com/kata-containers/runtime/protocols/cache"
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	vf "github.com/kata-containers/runtime/virtcontainers/factory"
+	"github.com/kata-containers/runtime/virtcontainers/factory/template"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/pkg/errors"
 	"github.com/urfave/cli"
@@ -326,6 +327,14 @@ var statusFactoryCommand = cli.Command{
 				fmt.Fprintln(defaultOutputFile, "vm factory is off")
 			} else {
 				fmt.Fprintln(defaultOutputFile, "vm factory is on")
+
+				stats, err := template.GetStats(factoryConfig.TemplatePath)
+				if err != nil {
+					fmt.Fprintln(defaultOutputFile, errors.Wrapf(err, "failed to get the stats of the vm template"))
+				} else {
+					fmt.Fprintf(defaultOutputFile, "vm template = %s clones = %d shared memory = %dMiB saved memory = %dMiB\n",
+						factoryConfig.TemplatePath, stats.Clones, stats.SharedMemory>>20, stats.SavedMemory>>20)
+				}
 			}
 		} else {
 			fmt.Fprintln(defaultOutputFile, "vm factory not enabled")
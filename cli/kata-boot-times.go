@@ -0,0 +1,96 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/urfave/cli"
+)
+
+var kataBootTimesCLICommand = cli.Command{
+	Name:      "boot-times",
+	Usage:     "show how long the boot steps of a sandbox took",
+	ArgsUsage: `<sandbox-id>`,
+
+	Description: `The boot-times command prints the time taken by the VMM to start the VM, by
+       the guest kernel to boot and start the agent, by the agent to set up
+       the sandbox and until the first container started. Steps which didn't
+       happen are shown as "-". It's meant to compare the boot performance
+       of runtime, VMM or guest image versions.`,
+
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Format output as JSON",
+		},
+	},
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		sandboxID := context.Args().First()
+		if sandboxID == "" {
+			return fmt.Errorf("Missing sandbox ID")
+		}
+
+		return bootTimes(ctx, sandboxID, context.Bool("json"), defaultOutputFile)
+	},
+}
+
+func bootTimes(ctx context.Context, sandboxID string, jsonOutput bool, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "bootTimes")
+	defer span.Finish()
+
+	kataLog = kataLog.WithField("sandbox", sandboxID)
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("sandbox", sandboxID)
+
+	times, err := vci.SandboxBootTimes(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(times, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	for _, step := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"vmm_spawn", times.VMMSpawn},
+		{"kernel_boot", times.KernelBoot},
+		{"agent_ready", times.AgentReady},
+		{"first_container_start", times.FirstContainerStart},
+		{"total", times.Total},
+	} {
+		duration := "-"
+		if step.duration != 0 {
+			duration = step.duration.String()
+		}
+
+		if _, err := fmt.Fprintf(out, "%s=%s\n", step.name, duration); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"testing"
+	"time"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBootTimes(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.SandboxBootTimesFunc = func(ctx context.Context, sandboxID string) (vc.BootTimes, error) {
+		return vc.BootTimes{
+			VMMSpawn:   50 * time.Millisecond,
+			KernelBoot: 450 * time.Millisecond,
+			AgentReady: 20 * time.Millisecond,
+			Total:      520 * time.Millisecond,
+		}, nil
+	}
+	defer func() {
+		testingImpl.SandboxBootTimesFunc = nil
+	}()
+
+	var buf bytes.Buffer
+	err := bootTimes(context.Background(), testSandboxID, false, &buf)
+	assert.NoError(err)
+	assert.Equal("vmm_spawn=50ms\nkernel_boot=450ms\nagent_ready=20ms\nfirst_container_start=-\ntotal=520ms\n", buf.String())
+
+	buf.Reset()
+	err = bootTimes(context.Background(), testSandboxID, true, &buf)
+	assert.NoError(err)
+
+	var times vc.BootTimes
+	assert.NoError(json.Unmarshal(buf.Bytes(), &times))
+	assert.Equal(450*time.Millisecond, times.KernelBoot)
+}
+
+func TestBootTimesCLIFunctionFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.SandboxBootTimesFunc = func(ctx context.Context, sandboxID string) (vc.BootTimes, error) {
+		return vc.BootTimes{}, errors.New("sandbox not found")
+	}
+	defer func() {
+		testingImpl.SandboxBootTimesFunc = nil
+	}()
+
+	// missing sandbox ID
+	execCLICommandFunc(assert, kataBootTimesCLICommand, flag.NewFlagSet("", 0), true)
+
+	set := flag.NewFlagSet("", 0)
+	set.Parse([]string{testSandboxID})
+	execCLICommandFunc(assert, kataBootTimesCLICommand, set, true)
+}
@@ -0,0 +1,176 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const successMessageFirecracker = "System can run firecracker VMs with " + project
+
+// variables rather than consts to allow tests to modify them
+var (
+	fcCheckVersion = vc.CheckFirecrackerVersion
+	fcChrootBase   = vc.FirecrackerChrootBaseDir()
+	tunDevice      = "/dev/net/tun"
+	netNsPath      = "/proc/self/ns/net"
+)
+
+// fcHostCheck is a firecracker host requirement, remedy telling how to fix
+// the host when it's not met.
+type fcHostCheck struct {
+	desc   string
+	remedy string
+	check  func() error
+}
+
+func checkFirecrackerBinary(path string) error {
+	version, err := fcCheckVersion(path)
+	if err != nil {
+		return err
+	}
+
+	kataLog.WithFields(logrus.Fields{
+		"path":    path,
+		"version": version,
+	}).Info("firecracker version supported")
+
+	return nil
+}
+
+// checkJailerBinary checks the jailer is an executable only root can modify,
+// since it's run as root.
+func checkJailerBinary(path string) error {
+	var st syscall.Stat_t
+	if err := syscall.Stat(path, &st); err != nil {
+		return fmt.Errorf("jailer %s not found: %v", path, err)
+	}
+
+	mode := os.FileMode(st.Mode).Perm()
+
+	if st.Mode&syscall.S_IFMT != syscall.S_IFREG || mode&0111 == 0 {
+		return fmt.Errorf("jailer %s is not an executable file", path)
+	}
+
+	if st.Uid != 0 || mode&0022 != 0 {
+		return fmt.Errorf("jailer %s can be modified by other users than root (owner %d, mode %v)", path, st.Uid, mode)
+	}
+
+	return nil
+}
+
+// checkJailerChrootBase checks the jailer root of the VMs is on a file
+// system allowing exec, the runtime remounts it for each sandbox otherwise.
+func checkJailerChrootBase(dir string) error {
+	// the directory is only created with the first sandbox
+	for !katautils.FileExists(dir) && dir != filepath.Dir(dir) {
+		dir = filepath.Dir(dir)
+	}
+
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return err
+	}
+
+	if st.Flags&unix.ST_NOEXEC != 0 {
+		return fmt.Errorf("%s is mounted noexec", dir)
+	}
+
+	return nil
+}
+
+func checkDeviceExists(path string) error {
+	if !katautils.FileExists(path) {
+		return fmt.Errorf("%s not found", path)
+	}
+
+	return nil
+}
+
+// firecrackerHostChecks returns the checks of the host requirements of the
+// firecracker configuration, the fatal ones first.
+func firecrackerHostChecks(config oci.RuntimeConfig) (required, recommended []fcHostCheck) {
+	hConfig := config.HypervisorConfig
+
+	chrootBase := fcChrootBase
+	if hConfig.JailerChrootBase != "" {
+		chrootBase = hConfig.JailerChrootBase
+	}
+
+	required = []fcHostCheck{
+		{
+			desc:   "firecracker binary",
+			remedy: fmt.Sprintf("install firecracker %s or later and set its path in the configuration", vc.FirecrackerMinSupportedVersion()),
+			check:  func() error { return checkFirecrackerBinary(hConfig.HypervisorPath) },
+		},
+		{
+			desc:   "network namespaces",
+			remedy: "use a kernel built with CONFIG_NET_NS",
+			check:  func() error { return checkDeviceExists(netNsPath) },
+		},
+		{
+			desc:   "tap devices",
+			remedy: "load the tun module with 'modprobe tun'",
+			check:  func() error { return checkDeviceExists(tunDevice) },
+		},
+	}
+
+	if hConfig.JailerPath != "" {
+		required = append(required, fcHostCheck{
+			desc:   "jailer binary",
+			remedy: "install the jailer matching the firecracker version, owned by root and not writable by other users",
+			check:  func() error { return checkJailerBinary(hConfig.JailerPath) },
+		})
+
+		recommended = append(recommended, fcHostCheck{
+			desc:   "jailer root exec permissions",
+			remedy: fmt.Sprintf("mount the file system of %s without noexec, or set jailer_chroot_base, so that the fast boot profile doesn't remount the jailer root of every sandbox", chrootBase),
+			check:  func() error { return checkJailerChrootBase(chrootBase) },
+		})
+	}
+
+	return required, recommended
+}
+
+// checkFirecrackerHost runs the checks of the firecracker configuration and
+// returns the number of requirements which are not met, all of them being
+// logged along with their remediation.
+func checkFirecrackerHost(config oci.RuntimeConfig) uint32 {
+	var count uint32
+
+	required, recommended := firecrackerHostChecks(config)
+
+	for i, c := range append(required, recommended...) {
+		fields := logrus.Fields{
+			"type":        "firecracker",
+			"description": c.desc,
+		}
+
+		if err := c.check(); err != nil {
+			entry := kataLog.WithFields(fields).WithError(err).WithField("remediation", c.remedy)
+			if i < len(required) {
+				entry.Error("firecracker requirement not met")
+				count++
+			} else {
+				entry.Warn("firecracker recommendation not met")
+			}
+			continue
+		}
+
+		kataLog.WithFields(fields).Info("firecracker requirement met")
+	}
+
+	return count
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckJailerBinary(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(ktu.TestDisabledNeedRoot)
+	}
+
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	jailer := filepath.Join(dir, "jailer")
+
+	assert.Error(checkJailerBinary(jailer))
+
+	assert.NoError(ioutil.WriteFile(jailer, nil, 0755))
+	assert.NoError(checkJailerBinary(jailer))
+
+	// not executable
+	assert.NoError(os.Chmod(jailer, 0644))
+	assert.Error(checkJailerBinary(jailer))
+
+	// writable by the other users
+	assert.NoError(os.Chmod(jailer, 0777))
+	assert.Error(checkJailerBinary(jailer))
+
+	assert.Error(checkJailerBinary(dir))
+}
+
+func TestCheckJailerChrootBase(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// the closest existing directory is checked
+	err = checkJailerChrootBase(filepath.Join(dir, "vc", "tmp"))
+	assert.Equal(checkJailerChrootBase(dir), err)
+}
+
+func TestCheckFirecrackerHost(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedFCCheckVersion := fcCheckVersion
+	savedTunDevice := tunDevice
+	savedNetNsPath := netNsPath
+	defer func() {
+		fcCheckVersion = savedFCCheckVersion
+		tunDevice = savedTunDevice
+		netNsPath = savedNetNsPath
+	}()
+
+	fcCheckVersion = func(path string) (string, error) {
+		return "0.21.1", nil
+	}
+
+	tunDevice = filepath.Join(dir, "tun")
+	netNsPath = filepath.Join(dir, "net")
+	assert.NoError(ioutil.WriteFile(tunDevice, nil, 0600))
+	assert.NoError(ioutil.WriteFile(netNsPath, nil, 0600))
+
+	config := oci.RuntimeConfig{
+		HypervisorType: vc.FirecrackerHypervisor,
+		HypervisorConfig: vc.HypervisorConfig{
+			HypervisorPath: "/usr/bin/firecracker",
+		},
+	}
+
+	assert.Equal(uint32(0), checkFirecrackerHost(config))
+
+	fcCheckVersion = func(path string) (string, error) {
+		return "", errors.New("version 0.19.0 is not supported")
+	}
+	assert.NoError(os.Remove(tunDevice))
+	assert.Equal(uint32(2), checkFirecrackerHost(config))
+
+	// the jailer is required when configured
+	config.HypervisorConfig.JailerPath = filepath.Join(dir, "jailer")
+	assert.Equal(uint32(3), checkFirecrackerHost(config))
+}
@@ -30,6 +30,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/sirupsen/logrus"
 	"github.com/urfave/cli"
+	"golang.org/x/sys/unix"
 )
 
 type kernelModule struct {
@@ -60,12 +61,13 @@ type vmContainerCapableDetails struct {
 }
 
 const (
-	moduleParamDir        = "parameters"
-	successMessageCapable = "System is capable of running " + project
-	successMessageCreate  = "System can currently create " + project
-	successMessageVersion = "Version consistency of " + project + " is verified"
-	failMessage           = "System is not capable of running " + project
-	kernelPropertyCorrect = "Kernel property value correct"
+	moduleParamDir             = "parameters"
+	successMessageCapable      = "System is capable of running " + project
+	successMessageCreate       = "System can currently create " + project
+	successMessageVersion      = "Version consistency of " + project + " is verified"
+	successMessageConfidential = "System can create confidential guests with " + project
+	failMessage                = "System is not capable of running " + project
+	kernelPropertyCorrect      = "Kernel property value correct"
 
 	// these refer to fields in the procCPUINFO file
 	genericCPUFlagsTag    = "flags"      // nolint: varcheck, unused, deadcode
@@ -85,6 +87,13 @@ var (
 	kvmDevice = "/dev/kvm"
 )
 
+// blockingEntropyDevices are the host entropy sources whose reads block when
+// the host runs out of entropy, keyed by device numbers.
+var blockingEntropyDevices = map[[2]uint32]string{
+	{1, 8}:    "/dev/random",
+	{10, 183}: "/dev/hwrng",
+}
+
 // getCPUInfo returns details of the first CPU read from the specified cpuinfo file
 func getCPUInfo(cpuInfoFile string) (string, error) {
 	text, err := katautils.GetFileContents(cpuInfoFile)
@@ -353,6 +362,30 @@ var kataCheckCLICommand = cli.Command{
 		}
 		fmt.Println(successMessageCapable)
 
+		if runtimeConfig.HypervisorType == vc.FirecrackerHypervisor {
+			if count := checkFirecrackerHost(runtimeConfig); count > 0 {
+				return fmt.Errorf("ERROR: %d firecracker requirements not met, %s", count, failMessage)
+			}
+
+			fmt.Println(successMessageFirecracker)
+		}
+
+		if err := checkEntropySource(runtimeConfig); err != nil {
+			return err
+		}
+
+		protection := vc.AvailableGuestProtection()
+		kataLog.WithField("protection", protection).Info("confidential guest protection")
+
+		if runtimeConfig.HypervisorConfig.ConfidentialGuest {
+			err = checkConfidentialGuest(runtimeConfig, protection)
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(successMessageConfidential)
+		}
+
 		if os.Geteuid() == 0 {
 			err = archHostCanCreateVMContainer(runtimeConfig.HypervisorType)
 			if err != nil {
@@ -470,6 +503,69 @@ func genericCheckKVMExtensions(extensions map[string]kvmExtension) (map[string]i
 	return results, nil
 }
 
+// checkConfidentialGuest checks the configured confidential guests can be
+// created with the memory protection the host provides.
+func checkConfidentialGuest(config oci.RuntimeConfig, protection string) error {
+	if config.HypervisorType != vc.QemuHypervisor {
+		return fmt.Errorf("confidential guests are not supported by %s", config.HypervisorType)
+	}
+
+	if protection == "none" {
+		return fmt.Errorf("confidential guests are enabled but the host provides neither Intel TDX nor AMD SEV")
+	}
+
+	if config.HypervisorConfig.FirmwarePath == "" {
+		return fmt.Errorf("confidential guests require a firmware, OVMF for AMD SEV or TDVF for Intel TDX")
+	}
+
+	if protection == "tdx" && config.HypervisorConfig.HypervisorMachineType != vc.QemuQ35 {
+		return fmt.Errorf("Intel TDX guests require the %s machine type", vc.QemuQ35)
+	}
+
+	return nil
+}
+
+// guestEntropySource returns the host source of entropy of the guest
+// virtio-rng device. Firecracker has no such device, the guest kernel trusts
+// the CPU random number generator instead, and acrn-dm always reads
+// /dev/random.
+func guestEntropySource(config oci.RuntimeConfig) string {
+	switch config.HypervisorType {
+	case vc.FirecrackerHypervisor:
+		return ""
+	case vc.AcrnHypervisor:
+		return "/dev/random"
+	}
+
+	return config.HypervisorConfig.EntropySource
+}
+
+// checkEntropySource checks the host source of entropy of the guests exists
+// and warns when reading it may block, delaying the boot of the VMs.
+func checkEntropySource(config oci.RuntimeConfig) error {
+	path := guestEntropySource(config)
+	if path == "" {
+		return nil
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(path, &st); err != nil {
+		return fmt.Errorf("entropy source %s not found: %v", path, err)
+	}
+
+	if st.Mode&unix.S_IFMT != unix.S_IFCHR {
+		return nil
+	}
+
+	rdev := uint64(st.Rdev)
+	if _, ok := blockingEntropyDevices[[2]uint32{unix.Major(rdev), unix.Minor(rdev)}]; ok {
+		kataLog.WithField("entropy-source", path).
+			Warn("entropy source may block when the host runs out of entropy, slowing down the VM boot, consider /dev/urandom")
+	}
+
+	return nil
+}
+
 // checkVersionConsistencyInComponents checks version consistency in Kata Components.
 func checkVersionConsistencyInComponents(config oci.RuntimeConfig) error {
 	proxyInfo := getProxyInfo(config)
@@ -20,6 +20,7 @@ import (
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	"github.com/kata-containers/runtime/pkg/katautils"
 	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/urfave/cli"
@@ -990,3 +991,58 @@ func TestCheckVersionConsistencyInComponents(t *testing.T) {
 		}
 	}
 }
+
+func TestCheckConfidentialGuest(t *testing.T) {
+	assert := assert.New(t)
+
+	config := oci.RuntimeConfig{
+		HypervisorType: vc.QemuHypervisor,
+		HypervisorConfig: vc.HypervisorConfig{
+			ConfidentialGuest:     true,
+			FirmwarePath:          "/usr/share/ovmf/OVMF.fd",
+			HypervisorMachineType: vc.QemuPC,
+		},
+	}
+
+	assert.NoError(checkConfidentialGuest(config, "sev"))
+	assert.Error(checkConfidentialGuest(config, "none"))
+
+	// TDX guests require q35
+	assert.Error(checkConfidentialGuest(config, "tdx"))
+	config.HypervisorConfig.HypervisorMachineType = vc.QemuQ35
+	assert.NoError(checkConfidentialGuest(config, "tdx"))
+
+	config.HypervisorConfig.FirmwarePath = ""
+	assert.Error(checkConfidentialGuest(config, "tdx"))
+
+	config.HypervisorType = vc.FirecrackerHypervisor
+	assert.Error(checkConfidentialGuest(config, "sev"))
+}
+
+func TestCheckEntropySource(t *testing.T) {
+	assert := assert.New(t)
+
+	config := oci.RuntimeConfig{
+		HypervisorType: vc.QemuHypervisor,
+		HypervisorConfig: vc.HypervisorConfig{
+			EntropySource: "/dev/urandom",
+		},
+	}
+
+	assert.Equal("/dev/urandom", guestEntropySource(config))
+	assert.NoError(checkEntropySource(config))
+
+	config.HypervisorConfig.EntropySource = "/dev/random"
+	assert.NoError(checkEntropySource(config))
+
+	config.HypervisorConfig.EntropySource = "/does/not/exist"
+	assert.Error(checkEntropySource(config))
+
+	// firecracker has no virtio-rng device
+	config.HypervisorType = vc.FirecrackerHypervisor
+	assert.Equal("", guestEntropySource(config))
+	assert.NoError(checkEntropySource(config))
+
+	config.HypervisorType = vc.AcrnHypervisor
+	assert.Equal("/dev/random", guestEntropySource(config))
+}
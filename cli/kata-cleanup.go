@@ -0,0 +1,59 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/urfave/cli"
+)
+
+var kataCleanupCLICommand = cli.Command{
+	Name:  "cleanup",
+	Usage: "remove the VM directories left behind by crashed sandboxes",
+	Description: `The cleanup command removes the VM and jail directories which belong to no
+       running sandbox and are not used by any process anymore, after
+       unmounting what was left mounted in them. A sandbox is running while
+       the hypervisor recorded in its store is. The jails of the
+       jailer_chroot_base of the configuration are collected too. It's also
+       done when a sandbox is created, the directories created recently are
+       never removed.`,
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		runtimeConfig, ok := context.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("invalid runtime config")
+		}
+
+		return cleanup(ctx, runtimeConfig.HypervisorConfig.JailerChrootBase, defaultOutputFile)
+	},
+}
+
+func cleanup(ctx context.Context, jailerChrootBase string, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "cleanup")
+	defer span.Finish()
+
+	removed, err := vci.CleanupOrphans(ctx, jailerChrootBase)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range removed {
+		fmt.Fprintf(out, "removed %s\n", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanup(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.CleanupOrphansFunc = func(ctx context.Context, jailerChrootBase string) ([]string, error) {
+		assert.Equal("/var/lib/kata-containers/jail", jailerChrootBase)
+		return []string{"/run/vc/vm/" + testSandboxID}, nil
+	}
+	defer func() {
+		testingImpl.CleanupOrphansFunc = nil
+	}()
+
+	var buf bytes.Buffer
+	assert.NoError(cleanup(context.Background(), "/var/lib/kata-containers/jail", &buf))
+	assert.Equal("removed /run/vc/vm/"+testSandboxID+"\n", buf.String())
+
+	testingImpl.CleanupOrphansFunc = func(ctx context.Context, jailerChrootBase string) ([]string, error) {
+		return nil, errors.New("mountinfo not found")
+	}
+	assert.Error(cleanup(context.Background(), "/var/lib/kata-containers/jail", &buf))
+}
@@ -0,0 +1,353 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/urfave/cli"
+)
+
+const (
+	// defaultCollectTailLines is the number of lines kept from each log
+	defaultCollectTailLines = 1000
+
+	redactedValue = "<redacted>"
+)
+
+// defaultRedactPatterns match the host specific data removed from the
+// bundle when redaction is requested.
+var defaultRedactPatterns = []string{
+	// IPv4 addresses
+	`\b(?:[0-9]{1,3}\.){3}[0-9]{1,3}\b`,
+	// MAC addresses
+	`\b(?:[0-9a-fA-F]{2}:){5}[0-9a-fA-F]{2}\b`,
+}
+
+var kataCollectCLICommand = cli.Command{
+	Name:  "collect",
+	Usage: "generate a support bundle for a sandbox",
+	Description: `The collect command gathers the effective configuration, the persisted
+       state, the hypervisor logs and the host environment of a sandbox into a
+       tarball that can be attached to bug reports. The guest console output
+       is part of the sandbox log when sandbox_log_dir is set, and of the
+       system log otherwise. The trace spans are not collected, they are only
+       kept by the tracing backend.`,
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "sandbox",
+			Usage: "ID of the sandbox",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Usage: "path of the generated tarball (default: kata-collect-<sandbox-id>-<time>.tar.gz)",
+		},
+		cli.IntFlag{
+			Name:  "tail",
+			Value: defaultCollectTailLines,
+			Usage: "number of lines kept from each log file, 0 to keep them all",
+		},
+		cli.BoolFlag{
+			Name:  "redact",
+			Usage: "replace IP and MAC addresses with " + redactedValue,
+		},
+		cli.StringSliceFlag{
+			Name:  "redact-pattern",
+			Usage: "regular expression whose matches are replaced with " + redactedValue + ", can be repeated",
+		},
+	},
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		sandboxID := context.String("sandbox")
+		if sandboxID == "" {
+			return errors.New("Missing sandbox ID")
+		}
+
+		configFile, ok := context.App.Metadata["configFile"].(string)
+		if !ok {
+			return errors.New("cannot determine config file")
+		}
+
+		runtimeConfig, ok := context.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("cannot determine runtime config")
+		}
+
+		var patterns []string
+		if context.Bool("redact") {
+			patterns = append(patterns, defaultRedactPatterns...)
+		}
+		patterns = append(patterns, context.StringSlice("redact-pattern")...)
+
+		redactors, err := compileRedactPatterns(patterns)
+		if err != nil {
+			return err
+		}
+
+		output := context.String("output")
+		if output == "" {
+			output = fmt.Sprintf("kata-collect-%s-%s.tar.gz", sandboxID, time.Now().UTC().Format("20060102T150405Z"))
+		}
+
+		if err := collect(ctx, sandboxID, output, context.Int("tail"), redactors, configFile, runtimeConfig); err != nil {
+			return err
+		}
+
+		fmt.Fprintln(defaultOutputFile, output)
+		return nil
+	},
+}
+
+func compileRedactPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	var redactors []*regexp.Regexp
+
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid redact pattern %q: %v", p, err)
+		}
+		redactors = append(redactors, re)
+	}
+
+	return redactors, nil
+}
+
+// bundleWriter adds files to the support bundle, redacting their content
+type bundleWriter struct {
+	tw        *tar.Writer
+	prefix    string
+	redactors []*regexp.Regexp
+	modTime   time.Time
+}
+
+func (b *bundleWriter) redact(data []byte) []byte {
+	for _, re := range b.redactors {
+		data = re.ReplaceAll(data, []byte(redactedValue))
+	}
+	return data
+}
+
+func (b *bundleWriter) addFile(name string, data []byte) error {
+	data = b.redact(data)
+
+	hdr := &tar.Header{
+		Name:    filepath.Join(b.prefix, name),
+		Mode:    0640,
+		Size:    int64(len(data)),
+		ModTime: b.modTime,
+	}
+
+	if err := b.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := b.tw.Write(data)
+	return err
+}
+
+// addDir adds the regular files of dir to the bundle under name, log
+// files are truncated to their last tail lines.
+func (b *bundleWriter) addDir(name, dir string, tail int) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+
+		// sockets, fifos and devices can't be archived
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		return b.addPath(filepath.Join(name, rel), path, tail)
+	})
+}
+
+// addPath adds the file at path to the bundle under name, log files are
+// truncated to their last tail lines. Missing files are skipped.
+func (b *bundleWriter) addPath(name, path string, tail int) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			kataLog.WithError(err).WithField("file", path).Warn("Could not collect file")
+		}
+		return nil
+	}
+
+	if strings.HasSuffix(path, ".log") {
+		data = tailLines(data, tail)
+	}
+
+	return b.addFile(name, data)
+}
+
+// tailLines returns the last n lines of data, or data if n is not positive
+func tailLines(data []byte, n int) []byte {
+	if n <= 0 {
+		return data
+	}
+
+	end := len(data)
+	if end > 0 && data[end-1] == '\n' {
+		end--
+	}
+
+	idx := end
+	for i := 0; i < n; i++ {
+		idx = bytes.LastIndexByte(data[:idx], '\n')
+		if idx < 0 {
+			return data
+		}
+	}
+
+	return data[idx+1:]
+}
+
+func collect(ctx context.Context, sandboxID, output string, tail int, redactors []*regexp.Regexp, configFile string, runtimeConfig oci.RuntimeConfig) (err error) {
+	span, _ := katautils.Trace(ctx, "collect")
+	defer span.Finish()
+
+	kataLog = kataLog.WithField("sandbox", sandboxID)
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("sandbox", sandboxID)
+
+	store, err := persist.GetDriver()
+	if err != nil {
+		return err
+	}
+
+	stateDir := filepath.Join(store.RunStoragePath(), sandboxID)
+	vmDir := vc.SandboxVMPath(store.RunVMStoragePath(), sandboxID)
+
+	if _, err := os.Stat(stateDir); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("sandbox %s not found", sandboxID)
+		}
+		return err
+	}
+
+	f, err := os.OpenFile(output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(output)
+		}
+	}()
+
+	gw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gw)
+
+	b := &bundleWriter{
+		tw:        tw,
+		prefix:    strings.TrimSuffix(strings.TrimSuffix(filepath.Base(output), ".gz"), ".tar"),
+		redactors: redactors,
+		modTime:   time.Now(),
+	}
+
+	if err = collectBundle(b, sandboxID, stateDir, vmDir, tail, configFile, runtimeConfig); err != nil {
+		return err
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+
+	return gw.Close()
+}
+
+func collectBundle(b *bundleWriter, sandboxID, stateDir, vmDir string, tail int, configFile string, runtimeConfig oci.RuntimeConfig) error {
+	// host environment, same as kata-env
+	env, err := getEnvInfo(configFile, runtimeConfig)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(env); err != nil {
+		return err
+	}
+	if err := b.addFile("kata-env.toml", buf.Bytes()); err != nil {
+		return err
+	}
+
+	// effective configuration
+	if data, err := ioutil.ReadFile(configFile); err == nil {
+		if err := b.addFile(filepath.Join("config", filepath.Base(configFile)), data); err != nil {
+			return err
+		}
+	} else {
+		kataLog.WithError(err).WithField("file", configFile).Warn("Could not collect configuration file")
+	}
+
+	data, err := json.MarshalIndent(runtimeConfig, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := b.addFile(filepath.Join("config", "runtime-config.json"), data); err != nil {
+		return err
+	}
+
+	// persisted state
+	if err := b.addDir("state", stateDir, tail); err != nil {
+		return err
+	}
+
+	// sandbox log, with the runtime, hypervisor and guest console logs
+	if runtimeConfig.SandboxLogDir != "" {
+		logFile := filepath.Join(runtimeConfig.SandboxLogDir, fmt.Sprintf("%s.log", sandboxID))
+		if err := b.addPath(filepath.Join("logs", "sandbox.log"), logFile, tail); err != nil {
+			return err
+		}
+	}
+
+	// firecracker configuration and logs, kept out of the VM directory
+	if runtimeConfig.HypervisorType == vc.FirecrackerHypervisor {
+		for _, file := range vc.FirecrackerLogFiles(sandboxID, runtimeConfig.HypervisorConfig) {
+			if err := b.addPath(filepath.Join("vm", "firecracker", filepath.Base(file)), file, tail); err != nil {
+				return err
+			}
+		}
+	}
+
+	// hypervisor logs and configuration
+	return b.addDir("vm", vmDir, tail)
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTailLines(t *testing.T) {
+	assert := assert.New(t)
+
+	data := []byte("one\ntwo\nthree\n")
+
+	assert.Equal(data, tailLines(data, 0))
+	assert.Equal(data, tailLines(data, 3))
+	assert.Equal(data, tailLines(data, 10))
+	assert.Equal([]byte("three\n"), tailLines(data, 1))
+	assert.Equal([]byte("two\nthree\n"), tailLines(data, 2))
+	assert.Equal([]byte("three"), tailLines([]byte("one\ntwo\nthree"), 1))
+	assert.Equal([]byte{}, tailLines([]byte{}, 1))
+}
+
+func TestCompileRedactPatterns(t *testing.T) {
+	assert := assert.New(t)
+
+	redactors, err := compileRedactPatterns(defaultRedactPatterns)
+	assert.NoError(err)
+	assert.Len(redactors, len(defaultRedactPatterns))
+
+	_, err = compileRedactPatterns([]string{"("})
+	assert.Error(err)
+}
+
+func TestBundleWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "kata-collect")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(tmpdir, "persist.json"), []byte(`{"mac":"02:42:ac:11:00:02"}`), 0640))
+	assert.NoError(ioutil.WriteFile(filepath.Join(tmpdir, "console.log"), []byte("boot\nip 10.0.0.2\n"), 0640))
+
+	redactors, err := compileRedactPatterns(defaultRedactPatterns)
+	assert.NoError(err)
+
+	var buf bytes.Buffer
+	b := &bundleWriter{
+		tw:        tar.NewWriter(&buf),
+		prefix:    "bundle",
+		redactors: redactors,
+		modTime:   time.Now(),
+	}
+
+	assert.NoError(b.addDir("vm", tmpdir, 1))
+	assert.NoError(b.addDir("missing", filepath.Join(tmpdir, "missing"), 1))
+	assert.NoError(b.tw.Close())
+
+	files := make(map[string]string)
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err != nil {
+			break
+		}
+		data, err := ioutil.ReadAll(tr)
+		assert.NoError(err)
+		files[hdr.Name] = string(data)
+	}
+
+	assert.Equal(map[string]string{
+		"bundle/vm/persist.json": `{"mac":"` + redactedValue + `"}`,
+		"bundle/vm/console.log":  "ip " + redactedValue + "\n",
+	}, files)
+}
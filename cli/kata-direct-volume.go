@@ -0,0 +1,84 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/kata-containers/runtime/virtcontainers/pkg/volume"
+	"github.com/urfave/cli"
+)
+
+const (
+	volumePathFlag = "volume-path"
+	mountInfoFlag  = "mount-info"
+)
+
+var directVolumeSubCmds = []cli.Command{
+	addDirectVolumeCommand,
+	removeDirectVolumeCommand,
+}
+
+var kataDirectVolumeCLICommand = cli.Command{
+	Name:  "direct-volume",
+	Usage: "manage the volumes directly assigned to sandbox VMs",
+	Description: `The direct-volume commands are meant for CSI drivers: a volume added
+       is not mounted on the host, its block device is hotplugged to the VM
+       and mounted by the agent inside the guest instead of being shared
+       with the VM.`,
+	Subcommands: directVolumeSubCmds,
+	Action: func(context *cli.Context) {
+		cli.ShowSubcommandHelp(context)
+	},
+}
+
+var addDirectVolumeCommand = cli.Command{
+	Name:  "add",
+	Usage: "add the mount information of a direct assigned volume",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  volumePathFlag,
+			Usage: "the path the volume is published at, i.e. the source of the container mount",
+		},
+		cli.StringFlag{
+			Name: mountInfoFlag,
+			Usage: `the mount information of the volume in JSON, e.g.
+	'{"volume-type": "block", "device": "/dev/sdb", "fstype": "ext4", "options": ["ro"], "cache-mode": "none"}'`,
+		},
+	},
+	Action: func(context *cli.Context) error {
+		volumePath := context.String(volumePathFlag)
+		if volumePath == "" {
+			return fmt.Errorf("Missing --%s", volumePathFlag)
+		}
+
+		mountInfo := context.String(mountInfoFlag)
+		if mountInfo == "" {
+			return fmt.Errorf("Missing --%s", mountInfoFlag)
+		}
+
+		return volume.Add(volumePath, mountInfo)
+	},
+}
+
+var removeDirectVolumeCommand = cli.Command{
+	Name:  "remove",
+	Usage: "remove the mount information of a direct assigned volume",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  volumePathFlag,
+			Usage: "the path the volume is published at",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		volumePath := context.String(volumePathFlag)
+		if volumePath == "" {
+			return fmt.Errorf("Missing --%s", volumePathFlag)
+		}
+
+		return volume.Remove(volumePath)
+	},
+}
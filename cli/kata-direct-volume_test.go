@@ -0,0 +1,59 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/pkg/volume"
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func TestDirectVolumeCLIFunctions(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	savedRootPath := volume.RootPath
+	volume.RootPath = tmpdir
+	defer func() {
+		volume.RootPath = savedRootPath
+	}()
+
+	addFn, ok := addDirectVolumeCommand.Action.(func(context *cli.Context) error)
+	assert.True(ok)
+	removeFn, ok := removeDirectVolumeCommand.Action.(func(context *cli.Context) error)
+	assert.True(ok)
+
+	volumePath := "/var/lib/kubelet/pods/abc/volumes/kubernetes.io~csi/pvc/mount"
+
+	// missing flags
+	set := flag.NewFlagSet("", 0)
+	set.String(volumePathFlag, "", "")
+	set.String(mountInfoFlag, "", "")
+	assert.Error(addFn(createCLIContext(set)))
+	assert.Error(removeFn(createCLIContext(set)))
+
+	set.Set(volumePathFlag, volumePath)
+	assert.Error(addFn(createCLIContext(set)))
+
+	set.Set(mountInfoFlag, `{"volume-type": "block", "device": "/dev/sdb", "fstype": "ext4"}`)
+	assert.NoError(addFn(createCLIContext(set)))
+
+	info, err := volume.VolumeMountInfo(volumePath)
+	assert.NoError(err)
+	assert.Equal("/dev/sdb", info.Device)
+
+	assert.NoError(removeFn(createCLIContext(set)))
+	_, err = volume.VolumeMountInfo(volumePath)
+	assert.True(os.IsNotExist(err))
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/containerd/console"
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var kataExecCLICommand = cli.Command{
+	Name:  "kata-exec",
+	Usage: "open a shell of the guest of a running sandbox",
+	ArgsUsage: `<sandbox-container-id>
+
+   <sandbox-container-id> is the ID of a container of the running sandbox.`,
+
+	Description: `The kata-exec command connects to the debug console of the agent of a
+       running sandbox, a root shell of the guest OS outside of the containers,
+       for the administrator of the host to debug the VM. The debug console must
+       be enabled with the debug_console_enabled option of the agent.
+
+       Unlike "exec", which runs a process in a container, kata-exec reaches the
+       guest itself.`,
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		args := context.Args()
+		if len(args) != 1 {
+			return fmt.Errorf("Expecting a sandbox container ID")
+		}
+
+		return kataExec(ctx, args.First(), os.Stdin, defaultOutputFile)
+	},
+}
+
+func kataExec(ctx context.Context, sandboxContainerID string, in io.Reader, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "kataExec")
+	defer span.Finish()
+
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("kata-exec must be run as root")
+	}
+
+	status, sandboxID, err := getExistingContainerInfo(ctx, sandboxContainerID)
+	if err != nil {
+		return err
+	}
+
+	kataLog = kataLog.WithFields(logrus.Fields{
+		"container": sandboxContainerID,
+		"sandbox":   sandboxID,
+	})
+
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("sandbox", sandboxID)
+
+	if status.State.State != types.StateRunning {
+		return fmt.Errorf("container with id %s is not running", status.ID)
+	}
+
+	conn, err := vci.OpenSandboxDebugConsole(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// the guest shell handles the line editing and the signals
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		c := console.Current()
+		if err := c.SetRaw(); err != nil {
+			return err
+		}
+		defer c.Reset()
+	}
+
+	go io.Copy(conn, in)
+
+	// the session ends when the guest shell exits
+	_, err = io.Copy(out, conn)
+	return err
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKataExec(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	path, err := createTempContainerIDMapping(testContainerID, testSandboxID)
+	assert.NoError(err)
+	defer os.RemoveAll(path)
+
+	state := types.ContainerState{
+		State: types.StateRunning,
+	}
+
+	testingImpl.StatusContainerFunc = func(ctx context.Context, sandboxID, containerID string) (vc.ContainerStatus, error) {
+		return newSingleContainerStatus(testContainerID, state, map[string]string{}, &specs.Spec{}), nil
+	}
+
+	testingImpl.OpenSandboxDebugConsoleFunc = func(ctx context.Context, sandboxID string) (net.Conn, error) {
+		assert.Equal(testSandboxID, sandboxID)
+
+		host, guest := net.Pipe()
+
+		// the guest shell echoes a command then exits
+		go func() {
+			defer guest.Close()
+
+			buf := make([]byte, 64)
+			n, _ := guest.Read(buf)
+			guest.Write(buf[:n])
+		}()
+
+		return host, nil
+	}
+
+	defer func() {
+		testingImpl.StatusContainerFunc = nil
+		testingImpl.OpenSandboxDebugConsoleFunc = nil
+	}()
+
+	var out bytes.Buffer
+	err = kataExec(context.Background(), testContainerID, strings.NewReader("uname -r\n"), &out)
+	assert.NoError(err)
+	assert.Equal("uname -r\n", out.String())
+
+	// the sandbox has no debug console
+	testingImpl.OpenSandboxDebugConsoleFunc = func(ctx context.Context, sandboxID string) (net.Conn, error) {
+		return nil, errors.New("no debug console")
+	}
+
+	err = kataExec(context.Background(), testContainerID, strings.NewReader(""), &out)
+	assert.Error(err)
+
+	// the container is not running
+	state.State = types.StateStopped
+	err = kataExec(context.Background(), testContainerID, strings.NewReader(""), &out)
+	assert.Error(err)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	"github.com/kata-containers/runtime/virtcontainers/persist/fs"
+	"github.com/urfave/cli"
+)
+
+var kataFixLocksCLICommand = cli.Command{
+	Name:  "fix-locks",
+	Usage: "break the stale locks of the sandbox stores",
+	ArgsUsage: `[sandbox-id...]
+
+   <sandbox-id> is the ID of a sandbox, all the sandboxes are checked when
+   none is given.`,
+
+	Description: `The fix-locks command checks the lock of the persisted state of the sandboxes.
+       A lock still held while all the processes which took it have exited, for
+       instance when its file descriptor leaked to a child process, is broken.
+       The locks held by running processes are reported along with their PIDs,
+       so that hung processes can be investigated.`,
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		store, err := persist.GetDriver()
+		if err != nil {
+			return err
+		}
+
+		return fixLocks(ctx, store.RunStoragePath(), []string(context.Args()), defaultOutputFile)
+	},
+}
+
+func fixLocks(ctx context.Context, storePath string, sandboxIDs []string, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "fix-locks")
+	defer span.Finish()
+
+	if len(sandboxIDs) == 0 {
+		entries, err := ioutil.ReadDir(storePath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+
+		for _, e := range entries {
+			if e.IsDir() {
+				sandboxIDs = append(sandboxIDs, e.Name())
+			}
+		}
+	}
+
+	for _, sandboxID := range sandboxIDs {
+		holders, broken, err := fs.BreakStaleLock(filepath.Join(storePath, sandboxID))
+		if os.IsNotExist(err) {
+			return fmt.Errorf("sandbox %s not found", sandboxID)
+		}
+		if err != nil {
+			return fmt.Errorf("sandbox %s: %v", sandboxID, err)
+		}
+
+		var pids []string
+		for _, h := range holders {
+			pids = append(pids, strconv.Itoa(h.PID))
+		}
+
+		switch {
+		case broken:
+			fmt.Fprintf(out, "%s: broke the stale lock of processes %s\n", sandboxID, strings.Join(pids, ", "))
+		case len(holders) > 0:
+			fmt.Fprintf(out, "%s: locked by processes %s\n", sandboxID, strings.Join(pids, ", "))
+		default:
+			fmt.Fprintf(out, "%s: no stale lock\n", sandboxID)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixLocks(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// no sandbox
+	var buf bytes.Buffer
+	assert.NoError(fixLocks(context.Background(), filepath.Join(dir, "sbs"), nil, &buf))
+	assert.Empty(buf.String())
+
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "sbs", testSandboxID), testDirMode))
+
+	assert.NoError(fixLocks(context.Background(), filepath.Join(dir, "sbs"), nil, &buf))
+	assert.Equal(testSandboxID+": no stale lock\n", buf.String())
+
+	err = fixLocks(context.Background(), filepath.Join(dir, "sbs"), []string{"missing"}, &buf)
+	assert.Error(err)
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/urfave/cli"
+)
+
+var kataHypervisorCapabilitiesCLICommand = cli.Command{
+	Name:  "hypervisor-capabilities",
+	Usage: "show the features supported by the configured hypervisor",
+
+	Description: `The hypervisor-capabilities command reports which of block device, memory,
+       vCPU and VFIO hotplug, virtio-fs, VM snapshots and multi-queue
+       devices the configured hypervisor supports, as implemented by the
+       runtime and provided by the hypervisor binary.`,
+
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Format output as JSON",
+		},
+	},
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		runtimeConfig, ok := context.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("hypervisor-capabilities: cannot determine runtime config")
+		}
+
+		return hypervisorCapabilities(ctx, runtimeConfig, context.Bool("json"), defaultOutputFile)
+	},
+}
+
+func hypervisorCapabilities(ctx context.Context, runtimeConfig oci.RuntimeConfig, jsonOutput bool, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "hypervisorCapabilities")
+	defer span.Finish()
+
+	caps, err := vci.GetHypervisorCapabilities(ctx, runtimeConfig.HypervisorType, runtimeConfig.HypervisorConfig)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(caps, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 1, ' ', 0)
+	fmt.Fprintf(w, "Hypervisor:\t%s %s (%s)\n", caps.Hypervisor, caps.Version, caps.Path)
+	for _, feature := range []struct {
+		name      string
+		supported bool
+	}{
+		{"Block device hotplug", caps.BlockDeviceHotplug},
+		{"Memory hotplug", caps.MemoryHotplug},
+		{"vCPU hotplug", caps.CPUHotplug},
+		{"virtio-fs", caps.VirtioFS},
+		{"virtio-9p", caps.Virtio9P},
+		{"VFIO hotplug", caps.VFIOHotplug},
+		{"VM snapshots", caps.VMSnapshot},
+		{"Multi-queue", caps.MultiQueue},
+	} {
+		supported := "no"
+		if feature.supported {
+			supported = "yes"
+		}
+		fmt.Fprintf(w, "%s:\t%s\n", feature.name, supported)
+	}
+
+	return w.Flush()
+}
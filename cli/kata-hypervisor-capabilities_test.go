@@ -0,0 +1,64 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHypervisorCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.GetHypervisorCapabilitiesFunc = func(ctx context.Context, hType vc.HypervisorType, conf vc.HypervisorConfig) (vc.HypervisorCapabilities, error) {
+		return vc.HypervisorCapabilities{
+			Hypervisor:         hType,
+			Path:               conf.HypervisorPath,
+			Version:            "5.0.0",
+			BlockDeviceHotplug: true,
+			VirtioFS:           true,
+		}, nil
+	}
+	defer func() {
+		testingImpl.GetHypervisorCapabilitiesFunc = nil
+	}()
+
+	config := oci.RuntimeConfig{
+		HypervisorType: vc.QemuHypervisor,
+		HypervisorConfig: vc.HypervisorConfig{
+			HypervisorPath: "/usr/bin/qemu-system-x86_64",
+		},
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(hypervisorCapabilities(context.Background(), config, false, &buf))
+	assert.Contains(buf.String(), "Hypervisor:           qemu 5.0.0 (/usr/bin/qemu-system-x86_64)\n")
+	assert.Contains(buf.String(), "Block device hotplug: yes\n")
+	assert.Contains(buf.String(), "Memory hotplug:       no\n")
+	assert.Contains(buf.String(), "virtio-fs:            yes\n")
+	assert.Contains(buf.String(), "virtio-9p:            no\n")
+
+	buf.Reset()
+	assert.NoError(hypervisorCapabilities(context.Background(), config, true, &buf))
+
+	var caps vc.HypervisorCapabilities
+	assert.NoError(json.Unmarshal(buf.Bytes(), &caps))
+	assert.Equal(vc.QemuHypervisor, caps.Hypervisor)
+	assert.True(caps.VirtioFS)
+	assert.False(caps.VFIOHotplug)
+
+	testingImpl.GetHypervisorCapabilitiesFunc = func(ctx context.Context, hType vc.HypervisorType, conf vc.HypervisorConfig) (vc.HypervisorCapabilities, error) {
+		return vc.HypervisorCapabilities{}, errors.New("qemu not found")
+	}
+	assert.Error(hypervisorCapabilities(context.Background(), config, false, &buf))
+}
@@ -0,0 +1,72 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/urfave/cli"
+)
+
+var kataInspectCLICommand = cli.Command{
+	Name:  "inspect",
+	Usage: "show the live state of a sandbox VM",
+	ArgsUsage: `<sandbox-id> [sandbox-id...]
+
+   <sandbox-id> is the ID of the sandbox.`,
+
+	Description: `The inspect command prints in JSON the persisted state of a sandbox along with
+       the state of its VM as reported by the hypervisor API: VM state, vCPUs,
+       memory, attached devices, network endpoints, agent socket and jail paths.
+       The VM part is replaced with the error returned by the hypervisor when
+       it does not answer, which helps debugging stuck pods.`,
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		args := context.Args()
+		if !args.Present() {
+			return fmt.Errorf("Missing sandbox ID, should at least provide one")
+		}
+
+		for _, sandboxID := range []string(args) {
+			if err := inspect(ctx, sandboxID, defaultOutputFile); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+func inspect(ctx context.Context, sandboxID string, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "inspect")
+	defer span.Finish()
+
+	kataLog = kataLog.WithField("sandbox", sandboxID)
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("sandbox", sandboxID)
+
+	info, err := vci.InspectSandbox(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, string(data))
+	return err
+}
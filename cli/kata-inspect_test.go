@@ -0,0 +1,65 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInspect(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.InspectSandboxFunc = func(ctx context.Context, sandboxID string) (vc.SandboxInfo, error) {
+		return vc.SandboxInfo{
+			ID:             sandboxID,
+			HypervisorType: vc.FirecrackerHypervisor,
+			VM: &vc.VMInfo{
+				State:    "Running",
+				VCPUs:    1,
+				MemoryMB: 2048,
+			},
+		}, nil
+	}
+	defer func() {
+		testingImpl.InspectSandboxFunc = nil
+	}()
+
+	var buf bytes.Buffer
+	err := inspect(context.Background(), testSandboxID, &buf)
+	assert.NoError(err)
+
+	var info vc.SandboxInfo
+	assert.NoError(json.Unmarshal(buf.Bytes(), &info))
+	assert.Equal(testSandboxID, info.ID)
+	assert.Equal(vc.FirecrackerHypervisor, info.HypervisorType)
+	assert.Equal(uint32(2048), info.VM.MemoryMB)
+}
+
+func TestInspectCLIFunctionFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.InspectSandboxFunc = func(ctx context.Context, sandboxID string) (vc.SandboxInfo, error) {
+		return vc.SandboxInfo{}, errors.New("sandbox not found")
+	}
+	defer func() {
+		testingImpl.InspectSandboxFunc = nil
+	}()
+
+	// missing sandbox ID
+	execCLICommandFunc(assert, kataInspectCLICommand, flag.NewFlagSet("", 0), true)
+
+	set := flag.NewFlagSet("", 0)
+	set.Parse([]string{testSandboxID})
+	execCLICommandFunc(assert, kataInspectCLICommand, set, true)
+}
@@ -0,0 +1,57 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/urfave/cli"
+)
+
+var kataLaunchMeasurementCLICommand = cli.Command{
+	Name:      "launch-measurement",
+	Usage:     "show the launch measurement of a confidential sandbox VM",
+	ArgsUsage: `<sandbox-id>`,
+
+	Description: `The launch-measurement command prints the base64 encoded measurement of the
+       initial state of the VM of a sandbox created with confidential_guest,
+       as computed by the AMD secure processor. Attestation services compare
+       it with the expected one before giving secrets to the guest.`,
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		sandboxID := context.Args().First()
+		if sandboxID == "" {
+			return fmt.Errorf("Missing sandbox ID")
+		}
+
+		return launchMeasurement(ctx, sandboxID, defaultOutputFile)
+	},
+}
+
+func launchMeasurement(ctx context.Context, sandboxID string, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "launchMeasurement")
+	defer span.Finish()
+
+	kataLog = kataLog.WithField("sandbox", sandboxID)
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("sandbox", sandboxID)
+
+	measurement, err := vci.SandboxLaunchMeasurement(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, measurement)
+	return err
+}
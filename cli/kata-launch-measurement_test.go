@@ -0,0 +1,50 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLaunchMeasurement(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.SandboxLaunchMeasurementFunc = func(ctx context.Context, sandboxID string) (string, error) {
+		return "c2V2LWxhdW5jaC1tZWFzdXJl", nil
+	}
+	defer func() {
+		testingImpl.SandboxLaunchMeasurementFunc = nil
+	}()
+
+	var buf bytes.Buffer
+	err := launchMeasurement(context.Background(), testSandboxID, &buf)
+	assert.NoError(err)
+	assert.Equal("c2V2LWxhdW5jaC1tZWFzdXJl\n", buf.String())
+}
+
+func TestLaunchMeasurementCLIFunctionFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.SandboxLaunchMeasurementFunc = func(ctx context.Context, sandboxID string) (string, error) {
+		return "", errors.New("not a confidential guest")
+	}
+	defer func() {
+		testingImpl.SandboxLaunchMeasurementFunc = nil
+	}()
+
+	// missing sandbox ID
+	execCLICommandFunc(assert, kataLaunchMeasurementCLICommand, flag.NewFlagSet("", 0), true)
+
+	set := flag.NewFlagSet("", 0)
+	set.Parse([]string{testSandboxID})
+	execCLICommandFunc(assert, kataLaunchMeasurementCLICommand, set, true)
+}
@@ -0,0 +1,152 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/urfave/cli"
+)
+
+const (
+	migrationURIFlag   = "uri"
+	migrationStateFlag = "state"
+)
+
+var migrateSubCmds = []cli.Command{
+	sendMigrationCommand,
+	receiveMigrationCommand,
+}
+
+var kataMigrateCLICommand = cli.Command{
+	Name:  "migrate",
+	Usage: "migrate a running sandbox to another host",
+	Description: `The migrate commands move a running sandbox and its VM to another host:
+       "receive" is started on the destination host first, it waits for the
+       VM sent by "send" on the source host. The state file written by "send"
+       has to be copied to the destination host before "receive" reads it,
+       the network namespace of the sandbox must exist on the destination
+       host, with the same interfaces. The sandbox is left paused on the
+       source host, to be deleted once the migration succeeded.
+
+       Only QEMU sandboxes that share no file system with the host, and
+       haven't had devices, memory or vCPUs hotplugged, can be migrated.`,
+	Subcommands: migrateSubCmds,
+	Action: func(context *cli.Context) {
+		cli.ShowSubcommandHelp(context)
+	},
+}
+
+var sendMigrationCommand = cli.Command{
+	Name:      "send",
+	Usage:     "send the VM of a running sandbox and write its state",
+	ArgsUsage: `<sandbox-id>`,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  migrationURIFlag,
+			Usage: `the URI to send the VM to, "tcp:<host>:<port>", "unix:<path>" or "file://<path>"`,
+		},
+		cli.StringFlag{
+			Name:  migrationStateFlag,
+			Usage: "the file to write the state of the sandbox to",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		sandboxID := context.Args().First()
+		if sandboxID == "" {
+			return fmt.Errorf("Missing sandbox ID")
+		}
+
+		uri := context.String(migrationURIFlag)
+		if uri == "" {
+			return fmt.Errorf("Missing --%s", migrationURIFlag)
+		}
+
+		stateFile := context.String(migrationStateFlag)
+		if stateFile == "" {
+			return fmt.Errorf("Missing --%s", migrationStateFlag)
+		}
+
+		return sendMigration(ctx, sandboxID, uri, stateFile)
+	},
+}
+
+var receiveMigrationCommand = cli.Command{
+	Name:  "receive",
+	Usage: "restore a sandbox from its state and the VM sent by the source host",
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  migrationURIFlag,
+			Usage: `the URI to receive the VM from, "tcp:<address>:<port>", "unix:<path>" or "file://<path>"`,
+		},
+		cli.StringFlag{
+			Name:  migrationStateFlag,
+			Usage: "the file written by the send command on the source host",
+		},
+	},
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		uri := context.String(migrationURIFlag)
+		if uri == "" {
+			return fmt.Errorf("Missing --%s", migrationURIFlag)
+		}
+
+		stateFile := context.String(migrationStateFlag)
+		if stateFile == "" {
+			return fmt.Errorf("Missing --%s", migrationStateFlag)
+		}
+
+		return receiveMigration(ctx, uri, stateFile)
+	},
+}
+
+func sendMigration(ctx context.Context, sandboxID, uri, stateFile string) error {
+	span, _ := katautils.Trace(ctx, "sendMigration")
+	defer span.Finish()
+
+	kataLog = kataLog.WithField("sandbox", sandboxID)
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("sandbox", sandboxID)
+
+	state, err := vci.MigrateSandbox(ctx, sandboxID, uri)
+	if err != nil {
+		return err
+	}
+
+	// the state holds the whole sandbox configuration
+	return ioutil.WriteFile(stateFile, state, 0600)
+}
+
+func receiveMigration(ctx context.Context, uri, stateFile string) error {
+	span, _ := katautils.Trace(ctx, "receiveMigration")
+	defer span.Finish()
+
+	state, err := ioutil.ReadFile(stateFile)
+	if err != nil {
+		return err
+	}
+
+	sandbox, err := vci.RestoreSandbox(ctx, state, uri)
+	if err != nil {
+		return err
+	}
+
+	kataLog.WithField("sandbox", sandbox.ID()).Info("Sandbox restored from migration")
+
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/vcmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateCLIFunctions(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	stateFile := filepath.Join(tmpdir, "state.json")
+
+	testingImpl.MigrateSandboxFunc = func(ctx context.Context, sandboxID, uri string) ([]byte, error) {
+		return []byte(`{"Sandbox":{}}`), nil
+	}
+	testingImpl.RestoreSandboxFunc = func(ctx context.Context, state []byte, uri string) (vc.VCSandbox, error) {
+		assert.Equal(`{"Sandbox":{}}`, string(state))
+		return &vcmock.Sandbox{MockID: testSandboxID}, nil
+	}
+	defer func() {
+		testingImpl.MigrateSandboxFunc = nil
+		testingImpl.RestoreSandboxFunc = nil
+	}()
+
+	// missing sandbox ID and flags
+	execCLICommandFunc(assert, sendMigrationCommand, flag.NewFlagSet("", 0), true)
+	execCLICommandFunc(assert, receiveMigrationCommand, flag.NewFlagSet("", 0), true)
+
+	set := flag.NewFlagSet("", 0)
+	set.String(migrationURIFlag, "tcp:localhost:4444", "")
+	set.String(migrationStateFlag, stateFile, "")
+	set.Parse([]string{testSandboxID})
+	execCLICommandFunc(assert, sendMigrationCommand, set, false)
+
+	info, err := os.Stat(stateFile)
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	set = flag.NewFlagSet("", 0)
+	set.String(migrationURIFlag, "tcp:0:4444", "")
+	set.String(migrationStateFlag, stateFile, "")
+	execCLICommandFunc(assert, receiveMigrationCommand, set, false)
+
+	// missing state file
+	set = flag.NewFlagSet("", 0)
+	set.String(migrationURIFlag, "tcp:0:4444", "")
+	set.String(migrationStateFlag, filepath.Join(tmpdir, "missing"), "")
+	execCLICommandFunc(assert, receiveMigrationCommand, set, true)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/kata-containers/runtime/pkg/katamonitor"
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	"github.com/sirupsen/logrus"
+)
+
+const monitorName = "kata-monitor"
+
+// version is the kata-monitor version. This variable is populated at build time.
+var version = "unknown"
+
+var monitorLog = logrus.New()
+
+const componentDescription = `is a per node daemon serving the metrics of the Kata sandboxes to
+Prometheus, the metrics of their shims, hypervisors and agents, and proxying
+the pprof endpoints of the shims enabling them. The sandboxes are discovered
+from the persisted state of the runtime. It listens on localhost by default,
+the endpoints not being authenticated.
+`
+
+type monitorParams struct {
+	listenAddress string
+	logLevel      string
+}
+
+func printVersion() {
+	fmt.Printf("%s version %s\n", monitorName, version)
+}
+
+func printComponentDescription() {
+	fmt.Printf("\n%s %s\n", monitorName, componentDescription)
+}
+
+func parseOptions() monitorParams {
+	var version, help bool
+
+	params := monitorParams{}
+
+	flag.BoolVar(&help, "h", false, "describe component usage")
+	flag.BoolVar(&help, "help", false, "")
+	flag.BoolVar(&version, "v", false, "display program version and exit")
+	flag.BoolVar(&version, "version", false, "")
+	flag.StringVar(&params.listenAddress, "listen-address", "127.0.0.1:8090",
+		"address of the HTTP endpoints")
+	flag.StringVar(&params.logLevel, "log", "warn",
+		"log messages above specified level: debug, warn, error, fatal or panic")
+
+	flag.Parse()
+
+	if help {
+		printComponentDescription()
+		flag.PrintDefaults()
+		os.Exit(0)
+	}
+
+	if version {
+		printVersion()
+		os.Exit(0)
+	}
+
+	return params
+}
+
+func main() {
+	params := parseOptions()
+
+	level, err := logrus.ParseLevel(params.logLevel)
+	if err != nil {
+		monitorLog.WithError(err).Fatal("Invalid log level")
+	}
+	monitorLog.SetLevel(level)
+	monitorLog.Formatter = &logrus.TextFormatter{TimestampFormat: time.RFC3339Nano}
+
+	logger := monitorLog.WithField("name", monitorName)
+	katamonitor.SetLogger(logger)
+
+	m, err := katamonitor.New(persist.GetDriver)
+	if err != nil {
+		logger.WithError(err).Fatal("Could not create the monitor")
+	}
+
+	logger.WithField("address", params.listenAddress).Info("Serving the sandbox metrics")
+
+	if err := http.ListenAndServe(params.listenAddress, m.Handler()); err != nil {
+		logger.WithError(err).Fatal("Could not serve the endpoints")
+	}
+}
@@ -0,0 +1,103 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/urfave/cli"
+)
+
+var kataMountsCLICommand = cli.Command{
+	Name:  "mounts",
+	Usage: "show how the container mounts reach the sandbox VM",
+	ArgsUsage: `<sandbox-id>
+
+   <sandbox-id> is the ID of the sandbox.`,
+
+	Description: `The mounts command prints, for each container of the sandbox, the host
+       path of its rootfs and of its mounts, the mechanism used to pass them
+       to the VM (shared file system, block device, copy, watched copy, or
+       created by the agent), their paths in the VM and whether they are
+       mounted in the VM.
+       The mounts not passed to the VM are reported with the reason why, which
+       helps debugging volumes not visible in the containers.`,
+
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Format output as JSON",
+		},
+	},
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		sandboxID := context.Args().First()
+		if sandboxID == "" {
+			return fmt.Errorf("Missing sandbox ID")
+		}
+
+		return mounts(ctx, sandboxID, context.Bool("json"), defaultOutputFile)
+	},
+}
+
+func mounts(ctx context.Context, sandboxID string, jsonOutput bool, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "mounts")
+	defer span.Finish()
+
+	kataLog = kataLog.WithField("sandbox", sandboxID)
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("sandbox", sandboxID)
+
+	info, err := vci.SandboxMounts(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	fmt.Fprintf(out, "Shared file system: %s\n\n", info.SharedFS)
+
+	w := tabwriter.NewWriter(out, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "CONTAINER\tDESTINATION\tSOURCE\tMECHANISM\tGUEST PATH\tSTATUS")
+	for _, c := range info.Containers {
+		for _, m := range append([]vc.MountInfo{c.Rootfs}, c.Mounts...) {
+			guestPath := m.GuestPath
+			if m.BlockDevice != nil && guestPath == "" {
+				guestPath = m.BlockDevice.ID
+			}
+
+			status := m.AgentStatus
+			if m.Reason != "" {
+				status += " (" + m.Reason + ")"
+			} else if m.HostPath != "" && !m.HostMounted {
+				status += " (not mounted on the host)"
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", c.ID, m.Destination, m.Source, m.Mechanism, guestPath, status)
+		}
+	}
+
+	return w.Flush()
+}
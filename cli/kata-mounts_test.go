@@ -0,0 +1,95 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMounts(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.SandboxMountsFunc = func(ctx context.Context, sandboxID string) (vc.SandboxMountInfo, error) {
+		return vc.SandboxMountInfo{
+			ID:       sandboxID,
+			SharedFS: "virtio-fs",
+			Containers: []vc.ContainerMountInfo{
+				{
+					ID: sandboxID,
+					Rootfs: vc.MountInfo{
+						Destination: "/",
+						Mechanism:   vc.MountMechanismSharedFS,
+						HostPath:    "/run/kata-containers/shared/sandboxes/foo/rootfs",
+						GuestPath:   "/run/kata-containers/shared/containers/foo/rootfs",
+						AgentStatus: vc.MountStatusMounted,
+					},
+					Mounts: []vc.MountInfo{
+						{
+							Source:      "/var/lib/kubelet/pods/abc/volumes/data",
+							Destination: "/data",
+							Mechanism:   vc.MountMechanismSharedFS,
+							HostPath:    "/run/kata-containers/shared/sandboxes/foo/foo-abc-data",
+							GuestPath:   "/run/kata-containers/shared/containers/foo-abc-data",
+							AgentStatus: vc.MountStatusMounted,
+						},
+						{
+							Source:      "/sys/fs/cgroup",
+							Destination: "/sys/fs/cgroup",
+							Mechanism:   vc.MountMechanismNone,
+							Reason:      "system mount of the host",
+							AgentStatus: vc.MountStatusIgnored,
+						},
+					},
+				},
+			},
+		}, nil
+	}
+	defer func() {
+		testingImpl.SandboxMountsFunc = nil
+	}()
+
+	var buf bytes.Buffer
+	err := mounts(context.Background(), testSandboxID, true, &buf)
+	assert.NoError(err)
+
+	var info vc.SandboxMountInfo
+	assert.NoError(json.Unmarshal(buf.Bytes(), &info))
+	assert.Equal(testSandboxID, info.ID)
+	assert.Len(info.Containers[0].Mounts, 2)
+
+	buf.Reset()
+	err = mounts(context.Background(), testSandboxID, false, &buf)
+	assert.NoError(err)
+	assert.Contains(buf.String(), "Shared file system: virtio-fs")
+	assert.Contains(buf.String(), "mounted (not mounted on the host)")
+	assert.Contains(buf.String(), "ignored (system mount of the host)")
+}
+
+func TestMountsCLIFunctionFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.SandboxMountsFunc = func(ctx context.Context, sandboxID string) (vc.SandboxMountInfo, error) {
+		return vc.SandboxMountInfo{}, errors.New("sandbox not found")
+	}
+	defer func() {
+		testingImpl.SandboxMountsFunc = nil
+	}()
+
+	// missing sandbox ID
+	execCLICommandFunc(assert, kataMountsCLICommand, flag.NewFlagSet("", 0), true)
+
+	set := flag.NewFlagSet("", 0)
+	set.Parse([]string{testSandboxID})
+	execCLICommandFunc(assert, kataMountsCLICommand, set, true)
+}
@@ -0,0 +1,126 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/urfave/cli"
+)
+
+// overheadReport is the overhead of the sampled sandboxes, with the pod
+// overhead memory covering all of them.
+type overheadReport struct {
+	Sandboxes []vc.Overhead `json:"sandboxes"`
+
+	// PodOverheadMemory is the highest actual memory overhead of the
+	// sandboxes, rounded up to MiB.
+	PodOverheadMemory uint64 `json:"pod_overhead_memory_bytes"`
+}
+
+var kataOverheadReportCLICommand = cli.Command{
+	Name:  "overhead-report",
+	Usage: "report the host overhead of the running sandboxes",
+	ArgsUsage: `[sandbox-id...]
+
+   <sandbox-id> is the ID of a sandbox, all the running sandboxes are
+   sampled when none is given.`,
+
+	Description: `The overhead-report command samples the running sandboxes and reports for
+       each of them the vCPUs and memory of the VM not given to the containers
+       limits, the memory used by the hypervisor, shim and proxy processes on
+       the host and the part of it not used by the containers in the guest.
+       The highest memory overhead is the memory to set in the PodOverhead of
+       the runtime class of the sandboxes.`,
+
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Format output as JSON",
+		},
+	},
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		return reportOverhead(ctx, []string(context.Args()), context.Bool("json"), defaultOutputFile)
+	},
+}
+
+func reportOverhead(ctx context.Context, sandboxIDs []string, jsonOutput bool, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "overheadReport")
+	defer span.Finish()
+
+	if len(sandboxIDs) == 0 {
+		sandboxes, err := vci.ListSandbox(ctx)
+		if err != nil {
+			return err
+		}
+
+		for _, s := range sandboxes {
+			if s.State.State == types.StateRunning {
+				sandboxIDs = append(sandboxIDs, s.ID)
+			}
+		}
+	}
+
+	report := overheadReport{
+		Sandboxes: []vc.Overhead{},
+	}
+
+	for _, sandboxID := range sandboxIDs {
+		overhead, err := vci.SandboxOverhead(ctx, sandboxID)
+		if err != nil {
+			return fmt.Errorf("Could not get the overhead of sandbox %s: %v", sandboxID, err)
+		}
+
+		report.Sandboxes = append(report.Sandboxes, overhead)
+
+		if overhead.ActualMemory > report.PodOverheadMemory {
+			report.PodOverheadMemory = overhead.ActualMemory
+		}
+	}
+
+	const mib = 1 << 20
+	report.PodOverheadMemory = (report.PodOverheadMemory + mib - 1) / mib * mib
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(out, string(data))
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 1, ' ', 0)
+	fmt.Fprintln(w, "SANDBOX\tEXPECTED VCPUS\tEXPECTED MEMORY\tPROCESSES MEMORY\tGUEST MEMORY\tACTUAL MEMORY")
+	for _, o := range report.Sandboxes {
+		var rss uint64
+		for _, p := range o.Processes {
+			rss += p.RSS
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\n", o.ID, o.ExpectedVCPUs, o.ExpectedMemory, rss, o.GuestMemory, o.ActualMemory)
+	}
+
+	if len(report.Sandboxes) > 0 {
+		fmt.Fprintf(w, "\nPodOverhead memory:\t%dMi\n", report.PodOverheadMemory>>20)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReportOverhead(t *testing.T) {
+	assert := assert.New(t)
+
+	testingImpl.ListSandboxFunc = func(ctx context.Context) ([]vc.SandboxStatus, error) {
+		return []vc.SandboxStatus{
+			{ID: "running", State: types.SandboxState{State: types.StateRunning}},
+			{ID: "stopped", State: types.SandboxState{State: types.StateStopped}},
+		}, nil
+	}
+	testingImpl.SandboxOverheadFunc = func(ctx context.Context, sandboxID string) (vc.Overhead, error) {
+		if sandboxID != "running" {
+			return vc.Overhead{}, errors.New("sandbox not running")
+		}
+
+		return vc.Overhead{
+			ID:             sandboxID,
+			ExpectedVCPUs:  1,
+			ExpectedMemory: 2048 << 20,
+			Processes: []vc.OverheadProcess{
+				{Name: "hypervisor", Pid: 1234, RSS: 300 << 20},
+				{Name: "shim", Pid: 4321, RSS: 30 << 20},
+			},
+			GuestMemory:  200 << 20,
+			ActualMemory: 130<<20 + 1,
+		}, nil
+	}
+	defer func() {
+		testingImpl.ListSandboxFunc = nil
+		testingImpl.SandboxOverheadFunc = nil
+	}()
+
+	// the running sandboxes are sampled
+	var buf bytes.Buffer
+	err := reportOverhead(context.Background(), nil, true, &buf)
+	assert.NoError(err)
+
+	var report overheadReport
+	assert.NoError(json.Unmarshal(buf.Bytes(), &report))
+	assert.Len(report.Sandboxes, 1)
+	assert.Equal("running", report.Sandboxes[0].ID)
+	assert.Equal(uint64(131<<20), report.PodOverheadMemory)
+
+	buf.Reset()
+	err = reportOverhead(context.Background(), []string{"running"}, false, &buf)
+	assert.NoError(err)
+	assert.Contains(buf.String(), "PodOverhead memory: 131Mi")
+
+	err = reportOverhead(context.Background(), []string{"stopped"}, false, &buf)
+	assert.Error(err)
+}
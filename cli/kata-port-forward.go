@@ -0,0 +1,103 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var kataPortForwardCLICommand = cli.Command{
+	Name:  "kata-port-forward",
+	Usage: "forward a host TCP port to a guest vsock port of a running sandbox",
+	ArgsUsage: `<sandbox-container-id> <guest-port>
+
+   <sandbox-container-id> is the ID of a container of the running sandbox.
+   <guest-port> is the vsock port of the guest service to reach.`,
+
+	Description: `The kata-port-forward command listens on a host TCP address and forwards
+       every accepted connection to a vsock port of the guest, so that services
+       running inside the VM can be reached for debugging without going through
+       the network of the sandbox. The address being listened on is printed and
+       the command runs until it is interrupted.`,
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "address",
+			Value: "127.0.0.1:0",
+			Usage: "host TCP address to listen on, a random port being picked for port 0",
+		},
+	},
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		args := context.Args()
+		if len(args) != 2 {
+			return fmt.Errorf("Expecting a sandbox container ID and a guest port")
+		}
+
+		port, err := strconv.ParseUint(args.Get(1), 10, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid guest port %q: %v", args.Get(1), err)
+		}
+
+		return portForward(ctx, args.First(), uint32(port), context.String("address"))
+	},
+}
+
+func portForward(ctx context.Context, sandboxContainerID string, port uint32, address string) error {
+	span, _ := katautils.Trace(ctx, "portForward")
+	defer span.Finish()
+
+	status, sandboxID, err := getExistingContainerInfo(ctx, sandboxContainerID)
+	if err != nil {
+		return err
+	}
+
+	kataLog = kataLog.WithFields(logrus.Fields{
+		"container": sandboxContainerID,
+		"sandbox":   sandboxID,
+	})
+
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("sandbox", sandboxID)
+
+	if status.State.State != types.StateRunning {
+		return fmt.Errorf("container with id %s is not running", status.ID)
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	go func() {
+		<-sigCh
+		listener.Close()
+	}()
+
+	fmt.Fprintf(defaultOutputFile, "Forwarding %s to guest port %d\n", listener.Addr(), port)
+
+	return vci.ForwardSandboxPort(ctx, sandboxID, port, listener)
+}
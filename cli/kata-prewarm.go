@@ -0,0 +1,97 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/sirupsen/logrus"
+	"github.com/urfave/cli"
+)
+
+var kataPrewarmCLICommand = cli.Command{
+	Name:  "kata-prewarm",
+	Usage: "stage a container image into a running sandbox",
+	ArgsUsage: `<sandbox-container-id> <container-id>
+
+   <sandbox-container-id> is the ID of a container of the running sandbox.
+   <container-id> is the ID of the container the image is staged for.`,
+
+	Description: `The kata-prewarm command stages the rootfs of a container that has not been
+       created yet into a running sandbox. A mounted rootfs is shared with the guest
+       and a block device rootfs is hotplugged to the VM, so that a later create
+       call for <container-id> does not pay for the image preparation.`,
+
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "rootfs",
+			Usage: "path to the mounted rootfs of the container",
+		},
+		cli.StringFlag{
+			Name:  "block-device",
+			Usage: "path to the block device holding the rootfs of the container",
+		},
+	},
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		args := context.Args()
+		if len(args) != 2 {
+			return fmt.Errorf("Expecting a sandbox container ID and a container ID")
+		}
+
+		rootfs := context.String("rootfs")
+		blockDevice := context.String("block-device")
+
+		if (rootfs == "") == (blockDevice == "") {
+			return fmt.Errorf("Exactly one of --rootfs and --block-device must be specified")
+		}
+
+		rootFs := vc.RootFs{
+			Source:  blockDevice,
+			Target:  rootfs,
+			Mounted: rootfs != "",
+		}
+
+		return prewarm(ctx, args.First(), args.Get(1), rootFs)
+	},
+}
+
+func prewarm(ctx context.Context, sandboxContainerID, containerID string, rootFs vc.RootFs) error {
+	span, _ := katautils.Trace(ctx, "prewarm")
+	defer span.Finish()
+
+	kataLog = kataLog.WithField("container", containerID)
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("container", containerID)
+
+	status, sandboxID, err := getExistingContainerInfo(ctx, sandboxContainerID)
+	if err != nil {
+		return err
+	}
+
+	kataLog = kataLog.WithFields(logrus.Fields{
+		"container": containerID,
+		"sandbox":   sandboxID,
+	})
+
+	setExternalLoggers(ctx, kataLog)
+	span.SetTag("sandbox", sandboxID)
+
+	if status.State.State == types.StateStopped {
+		return fmt.Errorf("container with id %s is not running", status.ID)
+	}
+
+	return vci.PrewarmContainerImage(ctx, sandboxID, containerID, rootFs)
+}
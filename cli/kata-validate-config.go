@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/urfave/cli"
+)
+
+var kataValidateConfigCLICommand = cli.Command{
+	Name:  "validate-config",
+	Usage: "check the hypervisor configuration before creating sandboxes",
+
+	Description: `The validate-config command checks the assets of the hypervisor configuration
+       exist, its memory and vCPUs settings and the features it enables are
+       supported by the hypervisor. Unlike kata-check, the host isn't
+       checked.`,
+
+	Flags: []cli.Flag{
+		cli.BoolFlag{
+			Name:  "json",
+			Usage: "Format output as JSON",
+		},
+	},
+
+	Action: func(context *cli.Context) error {
+		ctx, err := cliContextToContext(context)
+		if err != nil {
+			return err
+		}
+
+		runtimeConfig, ok := context.App.Metadata["runtimeConfig"].(oci.RuntimeConfig)
+		if !ok {
+			return errors.New("validate-config: cannot determine runtime config")
+		}
+
+		return validateConfig(ctx, runtimeConfig, context.Bool("json"), defaultOutputFile)
+	},
+}
+
+// configValidation is the result of validate-config.
+type configValidation struct {
+	Hypervisor vc.HypervisorType `json:"hypervisor"`
+	Valid      bool              `json:"valid"`
+	Errors     vc.ConfigErrors   `json:"errors,omitempty"`
+}
+
+func validateConfig(ctx context.Context, runtimeConfig oci.RuntimeConfig, jsonOutput bool, out io.Writer) error {
+	span, _ := katautils.Trace(ctx, "validateConfig")
+	defer span.Finish()
+
+	result := configValidation{
+		Hypervisor: runtimeConfig.HypervisorType,
+		Valid:      true,
+	}
+
+	if err := runtimeConfig.HypervisorConfig.Validate(runtimeConfig.HypervisorType); err != nil {
+		errs, ok := err.(vc.ConfigErrors)
+		if !ok {
+			return err
+		}
+
+		result.Valid = false
+		result.Errors = errs
+	}
+
+	if jsonOutput {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return err
+		}
+
+		if _, err := fmt.Fprintln(out, string(data)); err != nil {
+			return err
+		}
+	} else {
+		for _, e := range result.Errors {
+			if _, err := fmt.Fprintln(out, e.Error()); err != nil {
+				return err
+			}
+		}
+	}
+
+	if !result.Valid {
+		return fmt.Errorf("%d invalid settings in the %s configuration", len(result.Errors), result.Hypervisor)
+	}
+
+	if !jsonOutput {
+		_, err := fmt.Fprintf(out, "The %s configuration is valid\n", result.Hypervisor)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	_, config, err := makeRuntimeConfig(tmpdir)
+	assert.NoError(err)
+
+	var buf bytes.Buffer
+	err = validateConfig(context.Background(), config, false, &buf)
+	assert.NoError(err)
+	assert.Equal("The qemu configuration is valid\n", buf.String())
+
+	config.HypervisorConfig.KernelPath = filepath.Join(tmpdir, "missing")
+	config.HypervisorConfig.JailerPath = config.HypervisorConfig.HypervisorPath
+
+	buf.Reset()
+	err = validateConfig(context.Background(), config, false, &buf)
+	assert.Error(err)
+	assert.Contains(buf.String(), "KernelPath: ")
+	assert.Contains(buf.String(), "JailerPath: the jailer is only supported by firecracker")
+
+	buf.Reset()
+	err = validateConfig(context.Background(), config, true, &buf)
+	assert.Error(err)
+
+	var result configValidation
+	assert.NoError(json.Unmarshal(buf.Bytes(), &result))
+	assert.Equal(vc.QemuHypervisor, result.Hypervisor)
+	assert.False(result.Valid)
+	assert.Len(result.Errors, 2)
+	assert.Equal("KernelPath", result.Errors[0].Field)
+}
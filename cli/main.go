@@ -141,6 +141,21 @@ var runtimeCommands = []cli.Command{
 	kataEnvCLICommand,
 	kataNetworkCLICommand,
 	kataOverheadCLICommand,
+	kataOverheadReportCLICommand,
+	kataPrewarmCLICommand,
+	kataPortForwardCLICommand,
+	kataExecCLICommand,
+	kataCollectCLICommand,
+	kataInspectCLICommand,
+	kataMountsCLICommand,
+	kataFixLocksCLICommand,
+	kataCleanupCLICommand,
+	kataLaunchMeasurementCLICommand,
+	kataBootTimesCLICommand,
+	kataValidateConfigCLICommand,
+	kataHypervisorCapabilitiesCLICommand,
+	kataDirectVolumeCLICommand,
+	kataMigrateCLICommand,
 	factoryCLICommand,
 }
 
@@ -338,6 +353,12 @@ func beforeSubcommands(c *cli.Context) error {
 	if err != nil {
 		fatal(err)
 	}
+
+	// The --rootless option takes precedence over the configuration file
+	if r == nil && runtimeConfig.Rootless {
+		rootless.SetRootless(true)
+	}
+	rootless.SetSlirp4netnsPath(runtimeConfig.Slirp4netnsPath)
 	if !subCmdIsCheckCmd {
 		debug = runtimeConfig.Debug
 		crashOnError = runtimeConfig.Debug
@@ -102,9 +102,7 @@ func start(ctx context.Context, containerID string) (vc.VCSandbox, error) {
 	}
 
 	// Run post-start OCI hooks.
-	err = katautils.EnterNetNS(sandbox.GetNetNs(), func() error {
-		return katautils.PostStartHooks(ctx, ociSpec, sandboxID, status.Annotations[vcAnnot.BundlePathKey])
-	})
+	err = katautils.PostStartHooks(ctx, ociSpec, sandboxID, status.Annotations[vcAnnot.BundlePathKey], sandbox.Status())
 	if err != nil {
 		return nil, err
 	}
@@ -34,6 +34,10 @@ type container struct {
 	status   task.Status
 	terminal bool
 	mounted  bool
+
+	// exitPublished is set once the exit of the container has been
+	// published, by watchSandbox when the sandbox died.
+	exitPublished bool
 }
 
 func newContainer(s *service, r *taskAPI.CreateTaskRequest, containerType vc.ContainerType, spec *specs.Spec, mounted bool) (*container, error) {
@@ -29,6 +29,7 @@ import (
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/compatoci"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
 )
 
 func create(ctx context.Context, s *service, r *taskAPI.CreateTaskRequest) (*container, error) {
@@ -172,6 +173,17 @@ func loadRuntimeConfig(s *service, r *taskAPI.CreateTaskRequest, anno map[string
 		return nil, err
 	}
 
+	if runtimeConfig.Trace {
+		if _, err := katautils.CreateTracer("kata-shim-v2"); err != nil {
+			return nil, err
+		}
+	}
+
+	if runtimeConfig.Rootless {
+		rootless.SetRootless(true)
+	}
+	rootless.SetSlirp4netnsPath(runtimeConfig.Slirp4netnsPath)
+
 	// For the unit test, the config will be predefined
 	if s.config == nil {
 		s.config = &runtimeConfig
@@ -16,6 +16,17 @@ import (
 	vc "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 )
 
+// grpcCodes maps the virtcontainers error codes to the grpc ones.
+var grpcCodes = map[vc.ErrorCode]codes.Code{
+	vc.ErrCodeInvalidArgument:      codes.InvalidArgument,
+	vc.ErrCodeNotFound:             codes.NotFound,
+	vc.ErrCodeNotRunning:           codes.FailedPrecondition,
+	vc.ErrCodeHypervisorNotRunning: codes.Unavailable,
+	vc.ErrCodeDeviceBusy:           codes.FailedPrecondition,
+	vc.ErrCodeAgentTimeout:         codes.DeadlineExceeded,
+	vc.ErrCodeNotSupported:         codes.Unimplemented,
+}
+
 // toGRPC maps the virtcontainers error into a grpc error,
 // using the original error message as a description.
 func toGRPC(err error) error {
@@ -28,12 +39,16 @@ func toGRPC(err error) error {
 		return err
 	}
 
+	if code, ok := grpcCodes[vc.ErrorCodeOf(err)]; ok {
+		return status.Error(code, err.Error())
+	}
+
 	err = errors.Cause(err)
 	switch {
 	case isInvalidArgument(err):
-		return status.Errorf(codes.InvalidArgument, err.Error())
+		return status.Error(codes.InvalidArgument, err.Error())
 	case isNotFound(err):
-		return status.Errorf(codes.NotFound, err.Error())
+		return status.Error(codes.NotFound, err.Error())
 	}
 
 	return err
@@ -10,7 +10,10 @@ import (
 	"testing"
 
 	vc "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestToGRPC(t *testing.T) {
@@ -27,3 +30,19 @@ func TestToGRPC(t *testing.T) {
 		assert.True(isGRPCError(err))
 	}
 }
+
+func TestToGRPCErrorCode(t *testing.T) {
+	assert := assert.New(t)
+
+	for code, grpcCode := range grpcCodes {
+		err := vc.Errorf(code, "sandbox %s", "foo")
+		assert.False(isGRPCError(err))
+
+		err = toGRPC(errors.Wrap(err, "wrapped"))
+		assert.True(isGRPCError(err))
+		assert.Equal(grpcCode, status.Code(err))
+		assert.Contains(err.Error(), "wrapped: sandbox foo")
+	}
+
+	assert.Equal(codes.Unknown, status.Code(toGRPC(errors.New("foo"))))
+}
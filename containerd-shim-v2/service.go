@@ -782,6 +782,9 @@ func (s *service) Shutdown(ctx context.Context, r *taskAPI.ShutdownRequest) (_ *
 
 	s.cancel()
 
+	// report the spans of the sandbox before exiting
+	katautils.StopTracing(s.ctx)
+
 	os.Exit(0)
 
 	// This will never be called, but this is only there to make sure the
@@ -0,0 +1,158 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+	"sort"
+
+	"github.com/kata-containers/runtime/pkg/katamonitor"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// mutexProfileFraction is the rate of the mutex contention events reported
+// in the mutex profile when pprof is enabled.
+const mutexProfileFraction = 5
+
+// peerCredListener only accepts the connections of the processes running
+// as the user of the shim, as anyone in the network namespace can connect
+// to an abstract socket.
+type peerCredListener struct {
+	net.Listener
+}
+
+func (l *peerCredListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkPeerCred(conn); err != nil {
+			logrus.WithError(err).Warn("management server connection refused")
+			conn.Close()
+			continue
+		}
+
+		return conn, nil
+	}
+}
+
+func checkPeerCred(conn net.Conn) error {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return fmt.Errorf("Not a unix connection")
+	}
+
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	}); err != nil {
+		return err
+	}
+	if credErr != nil {
+		return credErr
+	}
+
+	if int(cred.Uid) != os.Geteuid() {
+		return fmt.Errorf("Peer process %d of user %d is not allowed", cred.Pid, cred.Uid)
+	}
+
+	return nil
+}
+
+// startManagementServer serves the metrics of the shim to kata-monitor on
+// an abstract socket, removed along with the shim, and the pprof endpoints
+// and the execution traces of the Go runtime when enable_pprof is set.
+func (s *service) startManagementServer() error {
+	l, err := net.Listen("unix", katamonitor.ShimSocket(s.sandbox.ID()))
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", s.serveMetrics)
+
+	if s.config != nil && s.config.EnablePprof {
+		runtime.SetMutexProfileFraction(mutexProfileFraction)
+
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	go func() {
+		if err := http.Serve(&peerCredListener{l}, mux); err != nil {
+			logrus.WithError(err).Warn("management server stopped")
+		}
+	}()
+
+	return nil
+}
+
+// serveMetrics serves the metrics of the shim process and the metrics of
+// the containers reported by the agent.
+func (s *service) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics := katamonitor.NewMetrics()
+
+	if err := metrics.AddProcessMetrics("kata_shim", os.Getpid()); err != nil {
+		logrus.WithError(err).Warn("Could not get shim process metrics")
+	}
+	metrics.Add("kata_shim_goroutines", "gauge", "Number of goroutines of the shim.", float64(runtime.NumGoroutine()))
+
+	s.mu.Lock()
+	var ids []string
+	for id := range s.containers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		stats, err := s.sandbox.StatsContainer(id)
+		if err != nil {
+			logrus.WithError(err).WithField("container", id).Debug("Could not get container stats")
+			continue
+		}
+
+		addAgentMetrics(metrics, id, &stats)
+	}
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", katamonitor.MetricsContentType)
+	metrics.WriteTo(w)
+}
+
+// addAgentMetrics adds the metrics of the cgroup of the container in the
+// guest.
+func addAgentMetrics(metrics *katamonitor.Metrics, containerID string, stats *vc.ContainerStats) {
+	if stats.CgroupStats == nil {
+		return
+	}
+
+	cg := stats.CgroupStats
+
+	metrics.Add("kata_agent_container_cpu_seconds_total", "counter", "Total CPU time of the container in seconds.",
+		float64(cg.CPUStats.CPUUsage.TotalUsage)/1e9, "container_id", containerID)
+	metrics.Add("kata_agent_container_memory_usage_bytes", "gauge", "Memory usage of the container in bytes.",
+		float64(cg.MemoryStats.Usage.Usage), "container_id", containerID)
+	metrics.Add("kata_agent_container_pids", "gauge", "Number of processes of the container.",
+		float64(cg.PidsStats.Current), "container_id", containerID)
+}
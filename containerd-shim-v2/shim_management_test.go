@@ -0,0 +1,83 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package containerdshim
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/kata-containers/runtime/pkg/katamonitor"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/vcmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddAgentMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	metrics := katamonitor.NewMetrics()
+
+	// no cgroup stats, no metrics
+	addAgentMetrics(metrics, "c1", &vc.ContainerStats{})
+
+	stats := &vc.ContainerStats{CgroupStats: &vc.CgroupStats{}}
+	stats.CgroupStats.CPUStats.CPUUsage.TotalUsage = 1500000000
+	stats.CgroupStats.MemoryStats.Usage.Usage = 4096
+	stats.CgroupStats.PidsStats.Current = 3
+	addAgentMetrics(metrics, "c2", stats)
+
+	var buf bytes.Buffer
+	_, err := metrics.WriteTo(&buf)
+	assert.NoError(err)
+	assert.NotContains(buf.String(), "c1")
+	assert.Contains(buf.String(), `kata_agent_container_cpu_seconds_total{container_id="c2"} 1.5`+"\n")
+	assert.Contains(buf.String(), `kata_agent_container_memory_usage_bytes{container_id="c2"} 4096`+"\n")
+	assert.Contains(buf.String(), `kata_agent_container_pids{container_id="c2"} 3`+"\n")
+}
+
+func TestManagementServerPprof(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, enabled := range []bool{false, true} {
+		sandboxID := fmt.Sprintf("pprof-%t-%d", enabled, os.Getpid())
+
+		s := &service{
+			sandbox:    &vcmock.Sandbox{MockID: sandboxID},
+			config:     &oci.RuntimeConfig{EnablePprof: enabled},
+			containers: make(map[string]*container),
+		}
+		assert.NoError(s.startManagementServer())
+
+		client := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", katamonitor.ShimSocket(sandboxID))
+				},
+			},
+		}
+
+		resp, err := client.Get("http://shim/metrics")
+		assert.NoError(err)
+		resp.Body.Close()
+		assert.Equal(http.StatusOK, resp.StatusCode)
+
+		resp, err = client.Get("http://shim/debug/pprof/heap")
+		assert.NoError(err)
+		resp.Body.Close()
+		if enabled {
+			assert.Equal(http.StatusOK, resp.StatusCode)
+		} else {
+			assert.Equal(http.StatusNotFound, resp.StatusCode)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 
 	"github.com/containerd/containerd/api/types/task"
 	"github.com/kata-containers/runtime/pkg/katautils"
+	"github.com/sirupsen/logrus"
 )
 
 func startContainer(ctx context.Context, s *service, c *container) error {
@@ -36,6 +37,10 @@ func startContainer(ctx context.Context, s *service, c *container) error {
 			return err
 		}
 		go watchSandbox(s)
+
+		if err := s.startManagementServer(); err != nil {
+			logrus.WithError(err).Warn("Could not start the management server of kata-monitor")
+		}
 	} else {
 		_, err := s.sandbox.StartContainer(c.id)
 		if err != nil {
@@ -44,10 +49,7 @@ func startContainer(ctx context.Context, s *service, c *container) error {
 	}
 
 	// Run post-start OCI hooks.
-	err := katautils.EnterNetNS(s.sandbox.GetNetNs(), func() error {
-		return katautils.PostStartHooks(ctx, *c.spec, s.sandbox.ID(), c.bundle)
-	})
-	if err != nil {
+	if err := katautils.PostStartHooks(ctx, *c.spec, s.sandbox.ID(), c.bundle, s.sandbox.Status()); err != nil {
 		return err
 	}
 
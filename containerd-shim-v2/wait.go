@@ -82,9 +82,12 @@ func wait(s *service, c *container, execID string) (int32, error) {
 
 		execs.exitCh <- uint32(ret)
 	}
+	published := execID == "" && c.exitPublished
 	s.mu.Unlock()
 
-	go cReap(s, int(ret), c.id, execID, timeStamp)
+	if !published {
+		go cReap(s, int(ret), c.id, execID, timeStamp)
+	}
 
 	return ret, nil
 }
@@ -99,6 +102,20 @@ func watchSandbox(s *service) {
 	}
 	s.monitor = nil
 
+	// Let containerd know the sandbox is gone right away, the waiters of
+	// the containers only notice it once the agent is found unreachable.
+	// The waiter of the sandbox container doesn't publish its exit again.
+	s.mu.Lock()
+	c, ok := s.containers[s.id]
+	if ok {
+		c.exitPublished = true
+	}
+	s.mu.Unlock()
+
+	if ok {
+		cReap(s, exitCode255, s.id, "", time.Now())
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 	// sandbox malfunctioning, cleanup as much as we can
@@ -0,0 +1,195 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package katamonitor
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/procfs"
+)
+
+// labelValueEscaper escapes the label values of the text exposition format.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Metrics is a set of metric families in the Prometheus text exposition
+// format. The samples of a family are written together, whatever the order
+// they are added in.
+type Metrics struct {
+	families []*metricFamily
+	byName   map[string]*metricFamily
+}
+
+type metricFamily struct {
+	name    string
+	help    string
+	typ     string
+	samples []string
+}
+
+// NewMetrics returns an empty set of metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		byName: make(map[string]*metricFamily),
+	}
+}
+
+func (m *Metrics) family(name string) *metricFamily {
+	f, ok := m.byName[name]
+	if !ok {
+		f = &metricFamily{name: name}
+		m.byName[name] = f
+		m.families = append(m.families, f)
+	}
+
+	return f
+}
+
+// formatLabels returns the labels, given as name and value pairs, as they
+// are written between the braces of a sample.
+func formatLabels(labels []string) string {
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], labelValueEscaper.Replace(labels[i+1])))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// Add adds a sample to the family "name" of type "typ", e.g. "counter" or
+// "gauge", the labels being name and value pairs.
+func (m *Metrics) Add(name, typ, help string, value float64, labels ...string) {
+	f := m.family(name)
+	if f.typ == "" {
+		f.typ = typ
+	}
+	if f.help == "" {
+		f.help = help
+	}
+
+	sample := name
+	if l := formatLabels(labels); l != "" {
+		sample += "{" + l + "}"
+	}
+
+	f.samples = append(f.samples, sample+" "+strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// Merge adds the metrics of the text exposition read from r, the labels
+// being added to all of its samples.
+func (m *Metrics) Merge(r io.Reader, labels ...string) error {
+	extra := formatLabels(labels)
+
+	var current *metricFamily
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(line, " ", 4)
+			if len(fields) < 3 || (fields[1] != "HELP" && fields[1] != "TYPE") {
+				continue
+			}
+
+			current = m.family(fields[2])
+			text := ""
+			if len(fields) == 4 {
+				text = fields[3]
+			}
+
+			if fields[1] == "HELP" && current.help == "" {
+				current.help = text
+			} else if fields[1] == "TYPE" && current.typ == "" {
+				current.typ = text
+			}
+			continue
+		}
+
+		end := strings.IndexAny(line, "{ ")
+		if end <= 0 {
+			return fmt.Errorf("Invalid metric sample %q", line)
+		}
+		name := line[:end]
+
+		// the samples of histograms and summaries have suffixes
+		f := current
+		if f == nil || !strings.HasPrefix(name, f.name) {
+			f = m.family(name)
+		}
+
+		if extra != "" {
+			switch {
+			case line[end] == ' ':
+				line = name + "{" + extra + "}" + line[end:]
+			case strings.HasPrefix(line[end+1:], "}"):
+				line = name + "{" + extra + line[end+1:]
+			default:
+				line = name + "{" + extra + "," + line[end+1:]
+			}
+		}
+
+		f.samples = append(f.samples, line)
+	}
+
+	return scanner.Err()
+}
+
+// WriteTo writes the metrics in the text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var buf bytes.Buffer
+
+	for _, f := range m.families {
+		if len(f.samples) == 0 {
+			continue
+		}
+
+		if f.help != "" {
+			fmt.Fprintf(&buf, "# HELP %s %s\n", f.name, f.help)
+		}
+		if f.typ != "" {
+			fmt.Fprintf(&buf, "# TYPE %s %s\n", f.name, f.typ)
+		}
+		for _, s := range f.samples {
+			buf.WriteString(s + "\n")
+		}
+	}
+
+	return buf.WriteTo(w)
+}
+
+// AddProcessMetrics adds the CPU, memory, file descriptor and thread
+// metrics of the process "pid", their names starting with prefix.
+func (m *Metrics) AddProcessMetrics(prefix string, pid int, labels ...string) error {
+	proc, err := procfs.NewProc(pid)
+	if err != nil {
+		return err
+	}
+
+	stat, err := proc.NewStat()
+	if err != nil {
+		return err
+	}
+
+	fds, err := proc.FileDescriptorsLen()
+	if err != nil {
+		return err
+	}
+
+	m.Add(prefix+"_process_cpu_seconds_total", "counter", "Total user and system CPU time spent in seconds.", stat.CPUTime(), labels...)
+	m.Add(prefix+"_process_resident_memory_bytes", "gauge", "Resident memory size in bytes.", float64(stat.ResidentMemory()), labels...)
+	m.Add(prefix+"_process_virtual_memory_bytes", "gauge", "Virtual memory size in bytes.", float64(stat.VirtualMemory()), labels...)
+	m.Add(prefix+"_process_open_fds", "gauge", "Number of open file descriptors.", float64(fds), labels...)
+	m.Add(prefix+"_process_threads", "gauge", "Number of threads.", float64(stat.NumThreads), labels...)
+
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package katamonitor
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsAdd(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMetrics()
+	m.Add("kata_foo", "gauge", "Foo.", 1, "id", "a")
+	m.Add("kata_bar", "counter", "Bar.", 2.5)
+	m.Add("kata_foo", "gauge", "", 3, "id", `b"\`)
+
+	var buf bytes.Buffer
+	_, err := m.WriteTo(&buf)
+	assert.NoError(err)
+	assert.Equal(`# HELP kata_foo Foo.
+# TYPE kata_foo gauge
+kata_foo{id="a"} 1
+kata_foo{id="b\"\\"} 3
+# HELP kata_bar Bar.
+# TYPE kata_bar counter
+kata_bar 2.5
+`, buf.String())
+}
+
+func TestMetricsMerge(t *testing.T) {
+	assert := assert.New(t)
+
+	shim := `# HELP kata_shim_goroutines Number of goroutines of the shim.
+# TYPE kata_shim_goroutines gauge
+kata_shim_goroutines 12
+# TYPE kata_latency_seconds histogram
+kata_latency_seconds_bucket{le="+Inf"} 4
+kata_latency_seconds_sum 0.5
+kata_latency_seconds_count 4
+
+kata_agent_container_pids{container_id="c1"} 3
+`
+
+	m := NewMetrics()
+	assert.NoError(m.Merge(strings.NewReader(shim), "sandbox_id", "s1"))
+	assert.NoError(m.Merge(strings.NewReader("kata_shim_goroutines{} 7\n"), "sandbox_id", "s2"))
+
+	var buf bytes.Buffer
+	_, err := m.WriteTo(&buf)
+	assert.NoError(err)
+	assert.Equal(`# HELP kata_shim_goroutines Number of goroutines of the shim.
+# TYPE kata_shim_goroutines gauge
+kata_shim_goroutines{sandbox_id="s1"} 12
+kata_shim_goroutines{sandbox_id="s2"} 7
+# TYPE kata_latency_seconds histogram
+kata_latency_seconds_bucket{sandbox_id="s1",le="+Inf"} 4
+kata_latency_seconds_sum{sandbox_id="s1"} 0.5
+kata_latency_seconds_count{sandbox_id="s1"} 4
+kata_agent_container_pids{sandbox_id="s1",container_id="c1"} 3
+`, buf.String())
+
+	assert.Error(m.Merge(strings.NewReader("{foo} 1\n")))
+}
+
+func TestAddProcessMetrics(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMetrics()
+	assert.NoError(m.AddProcessMetrics("kata_test", os.Getpid(), "sandbox_id", "s1"))
+
+	var buf bytes.Buffer
+	_, err := m.WriteTo(&buf)
+	assert.NoError(err)
+	assert.Contains(buf.String(), "# TYPE kata_test_process_cpu_seconds_total counter\n")
+	assert.Contains(buf.String(), `kata_test_process_threads{sandbox_id="s1"} `)
+
+	assert.Error(m.AddProcessMetrics("kata_test", -1))
+}
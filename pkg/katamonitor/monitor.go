@@ -0,0 +1,291 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package katamonitor implements kata-monitor, the node daemon serving the
+// metrics of the sandboxes for Prometheus and proxying the pprof endpoints
+// of their shims, as well as the metrics the shims expose to it.
+package katamonitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+	"github.com/sirupsen/logrus"
+)
+
+// shimSocketPrefix is the prefix of the abstract unix sockets of the
+// management servers of the shims, which are removed along with the shims.
+// The abstract sockets belong to the network namespace of the host.
+const shimSocketPrefix = "@kata-shim-monitor/"
+
+// shimTimeout bounds the collection of the metrics of a shim.
+const shimTimeout = 5 * time.Second
+
+// MetricsContentType is the content type of the text exposition format.
+const MetricsContentType = "text/plain; version=0.0.4"
+
+var monitorLog = logrus.WithField("source", "katamonitor")
+
+// SetLogger sets the logger of the package.
+func SetLogger(logger *logrus.Entry) {
+	monitorLog = logger.WithField("source", "katamonitor")
+}
+
+// ShimSocket returns the address of the abstract socket of the management
+// server of the shim of the sandbox.
+func ShimSocket(sandboxID string) string {
+	return shimSocketPrefix + sandboxID
+}
+
+// Monitor discovers the sandboxes from the persist store and serves their
+// metrics and the pprof endpoints of their shims.
+type Monitor struct {
+	// store returns a persist driver reading the state of the sandboxes.
+	store func() (persistapi.PersistDriver, error)
+
+	storePath string
+}
+
+// New returns a monitor of the sandboxes of the persist driver returned by
+// store, e.g. persist.GetDriver.
+func New(store func() (persistapi.PersistDriver, error)) (*Monitor, error) {
+	driver, err := store()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Monitor{
+		store:     store,
+		storePath: driver.RunStoragePath(),
+	}, nil
+}
+
+// Sandboxes returns the IDs of the sandboxes of the persist store.
+func (m *Monitor) Sandboxes() ([]string, error) {
+	entries, err := ioutil.ReadDir(m.storePath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			ids = append(ids, e.Name())
+		}
+	}
+
+	return ids, nil
+}
+
+// shimTransport returns a transport connecting to the management socket of
+// the shim of the sandbox.
+func (m *Monitor) shimTransport(sandboxID string) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", ShimSocket(sandboxID))
+		},
+	}
+}
+
+// shimMetrics returns the metrics exposed by the shim of the sandbox.
+func (m *Monitor) shimMetrics(sandboxID string) ([]byte, error) {
+	client := &http.Client{
+		Transport: m.shimTransport(sandboxID),
+		Timeout:   shimTimeout,
+	}
+
+	resp, err := client.Get("http://shim/metrics")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Unexpected status of shim metrics: %s", resp.Status)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+// addHypervisorMetrics adds the process metrics of the hypervisor and of
+// virtiofsd, their PIDs being read from the persist store, and the restarts
+// of virtiofsd as of the last time the sandbox state was saved.
+func (m *Monitor) addHypervisorMetrics(metrics *Metrics, sandboxID string) error {
+	store, err := m.store()
+	if err != nil {
+		return err
+	}
+
+	ss, _, err := store.FromDisk(sandboxID)
+	if err != nil {
+		return err
+	}
+
+	for _, p := range []struct {
+		prefix string
+		pid    int
+	}{
+		{"kata_hypervisor", ss.HypervisorState.Pid},
+		{"kata_virtiofsd", ss.HypervisorState.VirtiofsdPid},
+	} {
+		if p.pid <= 0 {
+			continue
+		}
+
+		if err := metrics.AddProcessMetrics(p.prefix, p.pid, "sandbox_id", sandboxID); err != nil {
+			monitorLog.WithError(err).WithField("sandbox", sandboxID).Debugf("Could not get %s metrics", p.prefix)
+		}
+	}
+
+	if ss.Config.HypervisorConfig.SharedFS == config.VirtioFS {
+		metrics.Add("kata_virtiofsd_restarts_total", "counter", "Number of restarts of virtiofsd after crashes.",
+			float64(ss.HypervisorState.VirtiofsdRestarts), "sandbox_id", sandboxID)
+	}
+
+	return nil
+}
+
+// Metrics returns the metrics of the sandboxes, the metrics of their shims
+// being collected concurrently.
+func (m *Monitor) Metrics() (*Metrics, error) {
+	ids, err := m.Sandboxes()
+	if err != nil {
+		return nil, err
+	}
+
+	shimMetrics := make([][]byte, len(ids))
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+
+			data, err := m.shimMetrics(id)
+			if err != nil {
+				monitorLog.WithError(err).WithField("sandbox", id).Debug("Could not get shim metrics")
+				return
+			}
+			shimMetrics[i] = data
+		}(i, id)
+	}
+	wg.Wait()
+
+	metrics := NewMetrics()
+
+	running := 0
+	for i, id := range ids {
+		// the sandboxes without a shim are being created or deleted
+		if shimMetrics[i] == nil {
+			continue
+		}
+		running++
+
+		if err := metrics.Merge(bytes.NewReader(shimMetrics[i]), "sandbox_id", id); err != nil {
+			monitorLog.WithError(err).WithField("sandbox", id).Warn("Invalid shim metrics")
+		}
+
+		if err := m.addHypervisorMetrics(metrics, id); err != nil {
+			monitorLog.WithError(err).WithField("sandbox", id).Warn("Could not read sandbox state")
+		}
+	}
+
+	metrics.Add("kata_monitor_sandboxes", "gauge", "Number of sandboxes of the persist store.", float64(len(ids)))
+	metrics.Add("kata_monitor_running_shims", "gauge", "Number of shims the metrics were collected from.", float64(running))
+
+	return metrics, nil
+}
+
+func (m *Monitor) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	metrics, err := m.Metrics()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", MetricsContentType)
+	metrics.WriteTo(w)
+}
+
+func (m *Monitor) serveSandboxes(w http.ResponseWriter, r *http.Request) {
+	ids, err := m.Sandboxes()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if ids == nil {
+		ids = []string{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ids)
+}
+
+// servePprof proxies /sandboxes/<id>/debug/pprof/ to the pprof endpoints of
+// the shim of the sandbox, the links of the index page staying relative to
+// the sandbox. The shims only serve them when enable_pprof is set.
+func (m *Monitor) servePprof(w http.ResponseWriter, r *http.Request) {
+	rest := strings.TrimPrefix(r.URL.Path, "/sandboxes/")
+
+	i := strings.Index(rest, "/")
+	if i <= 0 || !strings.HasPrefix(rest[i:], "/debug/pprof/") {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, target := rest[:i], rest[i:]
+	if id == "." || id == ".." {
+		http.NotFound(w, r)
+		return
+	}
+
+	if _, err := os.Stat(filepath.Join(m.storePath, id)); err != nil {
+		http.Error(w, fmt.Sprintf("Sandbox %s not found", id), http.StatusNotFound)
+		return
+	}
+
+	proxy := &httputil.ReverseProxy{
+		Director: func(req *http.Request) {
+			req.URL.Scheme = "http"
+			req.URL.Host = "shim"
+			req.URL.Path = target
+			req.URL.RawPath = ""
+		},
+		Transport: m.shimTransport(id),
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, fmt.Sprintf("Could not reach the shim of sandbox %s: %v", id, err), http.StatusBadGateway)
+		},
+	}
+
+	proxy.ServeHTTP(w, r)
+}
+
+// Handler returns the handler of the endpoints of the monitor:
+//
+//	/metrics                            metrics of all the sandboxes
+//	/sandboxes                          IDs of the sandboxes, in JSON
+//	/sandboxes/<id>/debug/pprof/...     pprof endpoints of the shim of <id>
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", m.serveMetrics)
+	mux.HandleFunc("/sandboxes", m.serveSandboxes)
+	mux.HandleFunc("/sandboxes/", m.servePprof)
+
+	return mux
+}
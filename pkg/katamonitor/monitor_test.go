@@ -0,0 +1,111 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package katamonitor
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/persist/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+// startTestShim serves the endpoints of a shim on the socket of the sandbox.
+func startTestShim(t *testing.T, sandboxID string) func() {
+	assert := assert.New(t)
+
+	l, err := net.Listen("unix", ShimSocket(sandboxID))
+	assert.NoError(err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "# TYPE kata_shim_goroutines gauge")
+		fmt.Fprintln(w, "kata_shim_goroutines 12")
+	})
+	mux.HandleFunc("/debug/pprof/", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s?%s", r.URL.Path, r.URL.RawQuery)
+	})
+
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+
+	return func() {
+		srv.Close()
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	assert := assert.New(t)
+
+	defer fs.MockStorageDestroy()
+
+	m, err := New(fs.MockFSInit)
+	assert.NoError(err)
+
+	ids, err := m.Sandboxes()
+	assert.NoError(err)
+	assert.Empty(ids)
+
+	// a running sandbox, with the test as its hypervisor, and a sandbox
+	// without a shim
+	running := fmt.Sprintf("running-%d", os.Getpid())
+	for _, id := range []string{running, "stopped"} {
+		assert.NoError(os.MkdirAll(filepath.Join(m.storePath, id), 0750))
+	}
+
+	state := fmt.Sprintf(`{"SandboxContainer": %q, "HypervisorState": {"Pid": %d}}`, running, os.Getpid())
+	assert.NoError(ioutil.WriteFile(filepath.Join(m.storePath, running, "persist.json"), []byte(state), 0600))
+
+	stop := startTestShim(t, running)
+	defer stop()
+
+	srv := httptest.NewServer(m.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/sandboxes")
+	assert.NoError(err)
+	assert.NoError(json.NewDecoder(resp.Body).Decode(&ids))
+	resp.Body.Close()
+	assert.Equal([]string{running, "stopped"}, ids)
+
+	resp, err = http.Get(srv.URL + "/metrics")
+	assert.NoError(err)
+	data, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(err)
+	assert.Equal(MetricsContentType, resp.Header.Get("Content-Type"))
+	assert.Contains(string(data), fmt.Sprintf(`kata_shim_goroutines{sandbox_id="%s"} 12`, running))
+	assert.Contains(string(data), fmt.Sprintf(`kata_hypervisor_process_threads{sandbox_id="%s"} `, running))
+	assert.Contains(string(data), "kata_monitor_sandboxes 2\n")
+	assert.Contains(string(data), "kata_monitor_running_shims 1\n")
+
+	resp, err = http.Get(srv.URL + "/sandboxes/" + running + "/debug/pprof/goroutine?debug=1")
+	assert.NoError(err)
+	data, err = ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.NoError(err)
+	assert.Equal("/debug/pprof/goroutine?debug=1", string(data))
+
+	for _, path := range []string{"/sandboxes/unknown/debug/pprof/", "/sandboxes/" + running + "/metrics", "/sandboxes/../debug/pprof/"} {
+		resp, err = http.Get(srv.URL + path)
+		assert.NoError(err)
+		resp.Body.Close()
+		assert.Equal(http.StatusNotFound, resp.StatusCode, path)
+	}
+
+	// the shim of the sandbox is not running
+	resp, err = http.Get(srv.URL + "/sandboxes/stopped/debug/pprof/")
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusBadGateway, resp.StatusCode)
+}
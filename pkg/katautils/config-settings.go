@@ -27,6 +27,8 @@ const defaultMaxVCPUCount uint32 = 0
 const defaultMemSize uint32 = 2048 // MiB
 const defaultMemSlots uint32 = 10
 const defaultMemOffset uint32 = 0 // MiB
+const defaultMemorySizingCeiling uint32 = 8192
+const defaultMemorySizingHostMargin uint32 = 1024
 const defaultVirtioMem bool = false
 const defaultBridgesCount uint32 = 1
 const defaultInterNetworkingModel = "tcfilter"
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 	goruntime "runtime"
 	"strings"
 
@@ -17,7 +18,11 @@ import (
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	exp "github.com/kata-containers/runtime/virtcontainers/experimental"
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	"github.com/kata-containers/runtime/virtcontainers/persist/plugin/kv"
+	vcCNI "github.com/kata-containers/runtime/virtcontainers/pkg/cni"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 	"github.com/sirupsen/logrus"
 )
@@ -33,6 +38,11 @@ var (
 
 	// if true, enable opentracing support.
 	tracing = false
+
+	// where and how many of the traces are reported when tracing.
+	traceCollectorEndpoint = ""
+	traceAgentEndpoint     = ""
+	traceSampleRate        = 1.0
 )
 
 // The TOML configuration file contains a number of sections (or
@@ -74,6 +84,11 @@ type tomlConfig struct {
 	Runtime    runtime
 	Factory    factory
 	Netmon     netmon
+	CNI        cni
+	Assets     assets
+
+	// AssetProfile are the [asset_profile.<name>] tables
+	AssetProfile map[string]assetProfile `toml:"asset_profile"`
 }
 
 type factory struct {
@@ -83,51 +98,104 @@ type factory struct {
 	VMCacheEndpoint string `toml:"vm_cache_endpoint"`
 }
 
+type asset struct {
+	Digest string `toml:"digest"`
+	URL    string `toml:"url"`
+}
+
+type assets struct {
+	CacheDir   string `toml:"cache_dir"`
+	Manifest   string `toml:"manifest"`
+	Kernel     asset  `toml:"kernel"`
+	Image      asset  `toml:"image"`
+	Initrd     asset  `toml:"initrd"`
+	Firmware   asset  `toml:"firmware"`
+	Hypervisor asset  `toml:"hypervisor"`
+	Jailer     asset  `toml:"jailer"`
+}
+
+type assetProfile struct {
+	Kernel       string `toml:"kernel"`
+	Image        string `toml:"image"`
+	Initrd       string `toml:"initrd"`
+	KernelParams string `toml:"kernel_params"`
+}
+
 type hypervisor struct {
-	Path                    string   `toml:"path"`
-	JailerPath              string   `toml:"jailer_path"`
-	Kernel                  string   `toml:"kernel"`
-	CtlPath                 string   `toml:"ctlpath"`
-	Initrd                  string   `toml:"initrd"`
-	Image                   string   `toml:"image"`
-	Firmware                string   `toml:"firmware"`
-	MachineAccelerators     string   `toml:"machine_accelerators"`
-	KernelParams            string   `toml:"kernel_params"`
-	MachineType             string   `toml:"machine_type"`
-	BlockDeviceDriver       string   `toml:"block_device_driver"`
-	EntropySource           string   `toml:"entropy_source"`
-	SharedFS                string   `toml:"shared_fs"`
-	VirtioFSDaemon          string   `toml:"virtio_fs_daemon"`
-	VirtioFSCache           string   `toml:"virtio_fs_cache"`
-	VirtioFSExtraArgs       []string `toml:"virtio_fs_extra_args"`
-	VirtioFSCacheSize       uint32   `toml:"virtio_fs_cache_size"`
-	BlockDeviceCacheSet     bool     `toml:"block_device_cache_set"`
-	BlockDeviceCacheDirect  bool     `toml:"block_device_cache_direct"`
-	BlockDeviceCacheNoflush bool     `toml:"block_device_cache_noflush"`
-	EnableVhostUserStore    bool     `toml:"enable_vhost_user_store"`
-	VhostUserStorePath      string   `toml:"vhost_user_store_path"`
-	NumVCPUs                int32    `toml:"default_vcpus"`
-	DefaultMaxVCPUs         uint32   `toml:"default_maxvcpus"`
-	MemorySize              uint32   `toml:"default_memory"`
-	MemSlots                uint32   `toml:"memory_slots"`
-	MemOffset               uint32   `toml:"memory_offset"`
-	DefaultBridges          uint32   `toml:"default_bridges"`
-	Msize9p                 uint32   `toml:"msize_9p"`
-	PCIeRootPort            uint32   `toml:"pcie_root_port"`
-	DisableBlockDeviceUse   bool     `toml:"disable_block_device_use"`
-	MemPrealloc             bool     `toml:"enable_mem_prealloc"`
-	HugePages               bool     `toml:"enable_hugepages"`
-	VirtioMem               bool     `toml:"enable_virtio_mem"`
-	FileBackedMemRootDir    string   `toml:"file_mem_backend"`
-	Swap                    bool     `toml:"enable_swap"`
-	Debug                   bool     `toml:"enable_debug"`
-	DisableNestingChecks    bool     `toml:"disable_nesting_checks"`
-	EnableIOThreads         bool     `toml:"enable_iothreads"`
-	UseVSock                bool     `toml:"use_vsock"`
-	DisableImageNvdimm      bool     `toml:"disable_image_nvdimm"`
-	HotplugVFIOOnRootBus    bool     `toml:"hotplug_vfio_on_root_bus"`
-	DisableVhostNet         bool     `toml:"disable_vhost_net"`
-	GuestHookPath           string   `toml:"guest_hook_path"`
+	Path                    string            `toml:"path"`
+	JailerPath              string            `toml:"jailer_path"`
+	JailerChrootBase        string            `toml:"jailer_chroot_base"`
+	Kernel                  string            `toml:"kernel"`
+	CtlPath                 string            `toml:"ctlpath"`
+	Initrd                  string            `toml:"initrd"`
+	Image                   string            `toml:"image"`
+	Firmware                string            `toml:"firmware"`
+	MachineAccelerators     string            `toml:"machine_accelerators"`
+	KernelParams            string            `toml:"kernel_params"`
+	MachineType             string            `toml:"machine_type"`
+	BlockDeviceDriver       string            `toml:"block_device_driver"`
+	EntropySource           string            `toml:"entropy_source"`
+	SharedFS                string            `toml:"shared_fs"`
+	VirtioFSDaemon          string            `toml:"virtio_fs_daemon"`
+	VirtioFSCache           string            `toml:"virtio_fs_cache"`
+	VirtioFSExtraArgs       []string          `toml:"virtio_fs_extra_args"`
+	VirtioFSCacheSize       uint32            `toml:"virtio_fs_cache_size"`
+	VirtioFSThreadPoolSize  uint32            `toml:"virtio_fs_thread_pool_size"`
+	VirtioFSMaxRestarts     uint32            `toml:"virtio_fs_max_restarts"`
+	BlockDeviceCacheSet     bool              `toml:"block_device_cache_set"`
+	BlockDeviceCacheDirect  bool              `toml:"block_device_cache_direct"`
+	BlockDeviceCacheNoflush bool              `toml:"block_device_cache_noflush"`
+	BlockDeviceQueues       uint32            `toml:"block_device_queues"`
+	BlockDeviceNaming       string            `toml:"block_device_naming"`
+	EnableVhostUserStore    bool              `toml:"enable_vhost_user_store"`
+	VhostUserStorePath      string            `toml:"vhost_user_store_path"`
+	NumVCPUs                int32             `toml:"default_vcpus"`
+	DefaultMaxVCPUs         uint32            `toml:"default_maxvcpus"`
+	CPUFeatures             string            `toml:"cpu_features"`
+	EnableGuestSMT          bool              `toml:"enable_guest_smt"`
+	CPUSockets              uint32            `toml:"cpu_sockets"`
+	CPUCores                uint32            `toml:"cpu_cores"`
+	CPUThreads              uint32            `toml:"cpu_threads"`
+	MemorySize              uint32            `toml:"default_memory"`
+	MemSlots                uint32            `toml:"memory_slots"`
+	MemOffset               uint32            `toml:"memory_offset"`
+	DefaultBridges          uint32            `toml:"default_bridges"`
+	Msize9p                 uint32            `toml:"msize_9p"`
+	PCIeRootPort            uint32            `toml:"pcie_root_port"`
+	DisableBlockDeviceUse   bool              `toml:"disable_block_device_use"`
+	BlockVolumeThreshold    uint32            `toml:"block_volume_threshold"`
+	MemPrealloc             bool              `toml:"enable_mem_prealloc"`
+	HugePages               bool              `toml:"enable_hugepages"`
+	VirtioMem               bool              `toml:"enable_virtio_mem"`
+	FileBackedMemRootDir    string            `toml:"file_mem_backend"`
+	Swap                    bool              `toml:"enable_swap"`
+	Debug                   bool              `toml:"enable_debug"`
+	DisableNestingChecks    bool              `toml:"disable_nesting_checks"`
+	EnableIOThreads         bool              `toml:"enable_iothreads"`
+	UseVSock                bool              `toml:"use_vsock"`
+	DisableImageNvdimm      bool              `toml:"disable_image_nvdimm"`
+	UsePmemRootfs           bool              `toml:"use_pmem_rootfs"`
+	HotplugVFIOOnRootBus    bool              `toml:"hotplug_vfio_on_root_bus"`
+	DisableVhostNet         bool              `toml:"disable_vhost_net"`
+	NetworkQueues           uint32            `toml:"network_queues"`
+	GuestHookPath           string            `toml:"guest_hook_path"`
+	EnableAnnotations       []string          `toml:"enable_annotations"`
+	VMMAPITimeout           uint32            `toml:"vmm_api_timeout"`
+	BootTimeout             uint32            `toml:"boot_timeout"`
+	ShutdownTimeout         uint32            `toml:"shutdown_timeout"`
+	VMMLogLevel             string            `toml:"vmm_log_level"`
+	VMMLogDir               string            `toml:"vmm_log_dir"`
+	VMMForwardMetrics       bool              `toml:"vmm_forward_metrics"`
+	CPUTemplate             string            `toml:"cpu_template"`
+	VSockChannels           map[string]uint32 `toml:"vsock_channels"`
+	ConfidentialGuest       bool              `toml:"confidential_guest"`
+	SEVPolicy               uint32            `toml:"sev_policy"`
+	EmulatorThreadsCPUs     string            `toml:"emulator_threads_cpuset"`
+	EmulatorThreadsNice     int32             `toml:"emulator_threads_nice"`
+	EmulatorRTPriority      uint32            `toml:"emulator_threads_rt_priority"`
+
+	DisableNetOffloads   []string            `toml:"disable_net_offloads"`
+	InterfaceNetOffloads map[string][]string `toml:"interface_net_offloads"`
 }
 
 type proxy struct {
@@ -136,13 +204,46 @@ type proxy struct {
 }
 
 type runtime struct {
-	Debug               bool     `toml:"enable_debug"`
-	Tracing             bool     `toml:"enable_tracing"`
-	DisableNewNetNs     bool     `toml:"disable_new_netns"`
-	DisableGuestSeccomp bool     `toml:"disable_guest_seccomp"`
-	SandboxCgroupOnly   bool     `toml:"sandbox_cgroup_only"`
-	Experimental        []string `toml:"experimental"`
-	InterNetworkModel   string   `toml:"internetworking_model"`
+	Debug                     bool              `toml:"enable_debug"`
+	Tracing                   bool              `toml:"enable_tracing"`
+	TraceCollectorEndpoint    string            `toml:"trace_collector_endpoint"`
+	TraceAgentEndpoint        string            `toml:"trace_agent_endpoint"`
+	TraceSampleRate           float64           `toml:"trace_sample_rate"`
+	TraceDisabledSubsystems   []string          `toml:"trace_disabled_subsystems"`
+	DisableNewNetNs           bool              `toml:"disable_new_netns"`
+	DisableGuestSeccomp       bool              `toml:"disable_guest_seccomp"`
+	DisableGuestSELinux       bool              `toml:"disable_guest_selinux"`
+	DisableGuestAppArmor      bool              `toml:"disable_guest_apparmor"`
+	SandboxCgroupOnly         bool              `toml:"sandbox_cgroup_only"`
+	PrivilegedDeviceAllowList []string          `toml:"privileged_device_allowlist"`
+	SandboxTmpQuota           uint32            `toml:"sandbox_tmp_quota"`
+	ScratchDiskSize           uint32            `toml:"scratch_disk_size"`
+	StatsVMMOverhead          bool              `toml:"stats_vmm_overhead"`
+	GuestTimeSyncInterval     uint32            `toml:"guest_time_sync_interval"`
+	MountWatchInterval        uint32            `toml:"mount_watch_interval"`
+	IdlePauseTimeout          uint32            `toml:"idle_pause_timeout"`
+	StaticSandboxResourceMgmt bool              `toml:"static_sandbox_resource_mgmt"`
+	MemorySizing              string            `toml:"memory_sizing"`
+	MemorySizingFloor         uint32            `toml:"memory_sizing_floor"`
+	MemorySizingCeiling       uint32            `toml:"memory_sizing_ceiling"`
+	MemorySizingHostMargin    uint32            `toml:"memory_sizing_host_margin"`
+	Rootless                  bool              `toml:"rootless"`
+	Slirp4netnsPath           string            `toml:"slirp4netns_path"`
+	HypervisorExitHook        string            `toml:"hypervisor_exit_hook"`
+	AuditLog                  string            `toml:"audit_log"`
+	PersistRemoteStore        string            `toml:"persist_remote_store"`
+	SandboxLogDir             string            `toml:"sandbox_log_dir"`
+	SandboxLogFormat          string            `toml:"sandbox_log_format"`
+	GuestDNS                  bool              `toml:"guest_dns"`
+	SystemLog                 string            `toml:"system_log"`
+	SystemLogRateLimits       map[string]uint32 `toml:"system_log_rate_limits"`
+	NetSysctlAllowList        []string          `toml:"net_sysctl_allowlist"`
+	VhostUserSocketPath       string            `toml:"vhost_user_socket_path"`
+	Experimental              []string          `toml:"experimental"`
+	InterNetworkModel         string            `toml:"internetworking_model"`
+	NetworkMTU                int               `toml:"network_mtu"`
+	ConntrackBypass           bool              `toml:"conntrack_bypass"`
+	EnablePprof               bool              `toml:"enable_pprof"`
 }
 
 type shim struct {
@@ -152,11 +253,16 @@ type shim struct {
 }
 
 type agent struct {
-	Debug         bool     `toml:"enable_debug"`
-	Tracing       bool     `toml:"enable_tracing"`
-	TraceMode     string   `toml:"trace_mode"`
-	TraceType     string   `toml:"trace_type"`
-	KernelModules []string `toml:"kernel_modules"`
+	Debug          bool     `toml:"enable_debug"`
+	Tracing        bool     `toml:"enable_tracing"`
+	TraceMode      string   `toml:"trace_mode"`
+	TraceType      string   `toml:"trace_type"`
+	KernelModules  []string `toml:"kernel_modules"`
+	DialTimeout    uint32   `toml:"dial_timeout"`
+	RequestTimeout uint32   `toml:"request_timeout"`
+	RequestRetries uint32   `toml:"request_retries"`
+	RetryDelay     uint32   `toml:"retry_delay"`
+	DebugConsole   bool     `toml:"debug_console_enabled"`
 }
 
 type netmon struct {
@@ -165,6 +271,12 @@ type netmon struct {
 	Enable bool   `toml:"enable_netmon"`
 }
 
+type cni struct {
+	Enable  bool     `toml:"enable_cni"`
+	ConfDir string   `toml:"conf_dir"`
+	BinDirs []string `toml:"bin_dirs"`
+}
+
 func (h hypervisor) path() (string, error) {
 	p := h.Path
 
@@ -195,6 +307,19 @@ func (h hypervisor) jailerPath() (string, error) {
 	return ResolvePath(p)
 }
 
+func (h hypervisor) jailerChrootBase(hypervisorPath string) (string, error) {
+	if h.JailerChrootBase == "" {
+		return "", nil
+	}
+
+	p := filepath.Clean(h.JailerChrootBase)
+	if err := vc.CheckJailerChrootBase(p, hypervisorPath); err != nil {
+		return "", fmt.Errorf("Invalid jailer_chroot_base: %v", err)
+	}
+
+	return p, nil
+}
+
 func (h hypervisor) kernel() (string, error) {
 	p := h.Kernel
 
@@ -372,10 +497,11 @@ func (h hypervisor) blockDeviceDriver() (string, error) {
 }
 
 func (h hypervisor) sharedFS() (string, error) {
-	supportedSharedFS := []string{config.Virtio9P, config.VirtioFS}
+	supportedSharedFS := []string{config.Virtio9P, config.VirtioFS, config.NoSharedFS}
 
+	// negotiated with the hypervisor when the sandbox is created
 	if h.SharedFS == "" {
-		return config.Virtio9P, nil
+		return "", nil
 	}
 
 	for _, fs := range supportedSharedFS {
@@ -426,9 +552,61 @@ func (h hypervisor) getInitrdAndImage() (initrd string, image string, err error)
 		return "", "", fmt.Errorf("Either initrd or image must be set to a valid path (initrd: %v) (image: %v)", errInitrd, errImage)
 	}
 
+	initrd, image = detectInitrdAndImage(initrd, image)
+
 	return
 }
 
+// detectInitrdAndImage boots the guest rootfs set as an image or an initrd
+// as what it actually is.
+func detectInitrdAndImage(initrd, image string) (string, string) {
+	rootfs := initrd
+	if image != "" {
+		rootfs = image
+	}
+
+	imageType, err := vc.DetectGuestImage(rootfs)
+	if err != nil {
+		return initrd, image
+	}
+
+	logger := kataUtilsLogger.WithField("rootfs", rootfs).WithField("type", imageType)
+
+	switch {
+	case image != "" && imageType == vc.GuestImageInitrd:
+		logger.Warn("The guest image is an initrd, booting it as an initrd")
+		return image, ""
+	case initrd != "" && imageType.IsDisk():
+		logger.Warn("The guest initrd is a disk image, booting it as an image")
+		return "", initrd
+	}
+
+	return initrd, image
+}
+
+func (r runtime) memorySizing() vc.MemorySizing {
+	sizing := vc.MemorySizing{
+		Policy:     vc.MemorySizingPolicy(r.MemorySizing),
+		Floor:      r.MemorySizingFloor,
+		Ceiling:    r.MemorySizingCeiling,
+		HostMargin: r.MemorySizingHostMargin,
+	}
+
+	if sizing.Floor == 0 {
+		sizing.Floor = vc.MinHypervisorMemory
+	}
+
+	if sizing.Ceiling == 0 {
+		sizing.Ceiling = defaultMemorySizingCeiling
+	}
+
+	if sizing.HostMargin == 0 {
+		sizing.HostMargin = defaultMemorySizingHostMargin
+	}
+
+	return sizing
+}
+
 func (p proxy) path() (string, error) {
 	path := p.Path
 	if path == "" {
@@ -496,6 +674,22 @@ func (n netmon) debug() bool {
 	return n.Debug
 }
 
+func (c cni) confDir() string {
+	if c.ConfDir == "" {
+		return vcCNI.DefaultConfDir
+	}
+
+	return c.ConfDir
+}
+
+func (c cni) binDirs() []string {
+	if len(c.BinDirs) == 0 {
+		return []string{vcCNI.DefaultBinDir}
+	}
+
+	return c.BinDirs
+}
+
 func newFirecrackerHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 	hypervisor, err := h.path()
 	if err != nil {
@@ -507,6 +701,11 @@ func newFirecrackerHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		return vc.HypervisorConfig{}, err
 	}
 
+	chrootBase, err := h.jailerChrootBase(hypervisor)
+	if err != nil {
+		return vc.HypervisorConfig{}, err
+	}
+
 	kernel, err := h.kernel()
 	if err != nil {
 		return vc.HypervisorConfig{}, err
@@ -536,6 +735,7 @@ func newFirecrackerHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 	return vc.HypervisorConfig{
 		HypervisorPath:        hypervisor,
 		JailerPath:            jailer,
+		JailerChrootBase:      chrootBase,
 		KernelPath:            kernel,
 		InitrdPath:            initrd,
 		ImagePath:             image,
@@ -543,20 +743,37 @@ func newFirecrackerHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		KernelParams:          vc.DeserializeParams(strings.Fields(kernelParams)),
 		NumVCPUs:              h.defaultVCPUs(),
 		DefaultMaxVCPUs:       h.defaultMaxVCPUs(),
+		CPUFeatures:           h.CPUFeatures,
+		EnableGuestSMT:        h.EnableGuestSMT,
 		MemorySize:            h.defaultMemSz(),
 		MemSlots:              h.defaultMemSlots(),
 		EntropySource:         h.GetEntropySource(),
 		DefaultBridges:        h.defaultBridges(),
 		DisableBlockDeviceUse: h.DisableBlockDeviceUse,
+		BlockVolumeThreshold:  h.BlockVolumeThreshold,
 		HugePages:             h.HugePages,
 		Mlock:                 !h.Swap,
 		Debug:                 h.Debug,
 		DisableNestingChecks:  h.DisableNestingChecks,
 		BlockDeviceDriver:     blockDriver,
+		BlockDeviceNaming:     h.BlockDeviceNaming,
 		EnableIOThreads:       h.EnableIOThreads,
 		DisableVhostNet:       true, // vhost-net backend is not supported in Firecracker
 		UseVSock:              true,
+		UsePmemRootfs:         h.UsePmemRootfs,
 		GuestHookPath:         h.guestHookPath(),
+		EnableAnnotations:     h.EnableAnnotations,
+		VMMAPITimeout:         h.VMMAPITimeout,
+		BootTimeout:           h.BootTimeout,
+		ShutdownTimeout:       h.ShutdownTimeout,
+		VMMLogLevel:           h.VMMLogLevel,
+		VMMLogDir:             h.VMMLogDir,
+		VMMForwardMetrics:     h.VMMForwardMetrics,
+		CPUTemplate:           h.CPUTemplate,
+		VSockChannels:         h.VSockChannels,
+		EmulatorThreadsCPUs:   h.EmulatorThreadsCPUs,
+		EmulatorThreadsNice:   h.EmulatorThreadsNice,
+		EmulatorRTPriority:    h.EmulatorRTPriority,
 	}, nil
 }
 
@@ -586,6 +803,13 @@ func newQemuHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 			errors.New("either image or initrd must be defined in the configuration file")
 	}
 
+	// the guest image is already plugged as a nvdimm device, unless
+	// disable_image_nvdimm is set
+	if h.UsePmemRootfs {
+		return vc.HypervisorConfig{},
+			errors.New("use_pmem_rootfs is not supported by QEMU, the guest image is plugged as a nvdimm device unless disable_image_nvdimm is set")
+	}
+
 	firmware, err := h.firmware()
 	if err != nil {
 		return vc.HypervisorConfig{}, err
@@ -631,6 +855,11 @@ func newQemuHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		HypervisorMachineType:   machineType,
 		NumVCPUs:                h.defaultVCPUs(),
 		DefaultMaxVCPUs:         h.defaultMaxVCPUs(),
+		CPUFeatures:             h.CPUFeatures,
+		EnableGuestSMT:          h.EnableGuestSMT,
+		CPUSockets:              h.CPUSockets,
+		CPUCores:                h.CPUCores,
+		CPUThreads:              h.CPUThreads,
 		MemorySize:              h.defaultMemSz(),
 		MemSlots:                h.defaultMemSlots(),
 		MemOffset:               h.defaultMemOffset(),
@@ -638,11 +867,14 @@ func newQemuHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		EntropySource:           h.GetEntropySource(),
 		DefaultBridges:          h.defaultBridges(),
 		DisableBlockDeviceUse:   h.DisableBlockDeviceUse,
+		BlockVolumeThreshold:    h.BlockVolumeThreshold,
 		SharedFS:                sharedFS,
 		VirtioFSDaemon:          h.VirtioFSDaemon,
 		VirtioFSCacheSize:       h.VirtioFSCacheSize,
 		VirtioFSCache:           h.defaultVirtioFSCache(),
 		VirtioFSExtraArgs:       h.VirtioFSExtraArgs,
+		VirtioFSThreadPoolSize:  h.VirtioFSThreadPoolSize,
+		VirtioFSMaxRestarts:     h.VirtioFSMaxRestarts,
 		MemPrealloc:             h.MemPrealloc,
 		HugePages:               h.HugePages,
 		FileBackedMemRootDir:    h.FileBackedMemRootDir,
@@ -653,16 +885,32 @@ func newQemuHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		BlockDeviceCacheSet:     h.BlockDeviceCacheSet,
 		BlockDeviceCacheDirect:  h.BlockDeviceCacheDirect,
 		BlockDeviceCacheNoflush: h.BlockDeviceCacheNoflush,
+		BlockDeviceQueues:       h.BlockDeviceQueues,
+		BlockDeviceNaming:       h.BlockDeviceNaming,
 		EnableIOThreads:         h.EnableIOThreads,
 		Msize9p:                 h.msize9p(),
 		UseVSock:                useVSock,
 		DisableImageNvdimm:      h.DisableImageNvdimm,
+		UsePmemRootfs:           h.UsePmemRootfs,
 		HotplugVFIOOnRootBus:    h.HotplugVFIOOnRootBus,
 		PCIeRootPort:            h.PCIeRootPort,
 		DisableVhostNet:         h.DisableVhostNet,
+		NetworkQueues:           h.NetworkQueues,
+		DisableNetOffloads:      h.DisableNetOffloads,
+		InterfaceNetOffloads:    h.InterfaceNetOffloads,
 		EnableVhostUserStore:    h.EnableVhostUserStore,
 		VhostUserStorePath:      h.vhostUserStorePath(),
 		GuestHookPath:           h.guestHookPath(),
+		EnableAnnotations:       h.EnableAnnotations,
+		VMMAPITimeout:           h.VMMAPITimeout,
+		BootTimeout:             h.BootTimeout,
+		ShutdownTimeout:         h.ShutdownTimeout,
+		VSockChannels:           h.VSockChannels,
+		ConfidentialGuest:       h.ConfidentialGuest,
+		SEVPolicy:               h.SEVPolicy,
+		EmulatorThreadsCPUs:     h.EmulatorThreadsCPUs,
+		EmulatorThreadsNice:     h.EmulatorThreadsNice,
+		EmulatorRTPriority:      h.EmulatorRTPriority,
 	}, nil
 }
 
@@ -724,6 +972,10 @@ func newAcrnHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		BlockDeviceDriver:    blockDriver,
 		DisableVhostNet:      h.DisableVhostNet,
 		GuestHookPath:        h.guestHookPath(),
+		EnableAnnotations:    h.EnableAnnotations,
+		EmulatorThreadsCPUs:  h.EmulatorThreadsCPUs,
+		EmulatorThreadsNice:  h.EmulatorThreadsNice,
+		EmulatorRTPriority:   h.EmulatorRTPriority,
 	}, nil
 }
 
@@ -785,6 +1037,9 @@ func newClhHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		HypervisorMachineType:   machineType,
 		NumVCPUs:                h.defaultVCPUs(),
 		DefaultMaxVCPUs:         h.defaultMaxVCPUs(),
+		CPUSockets:              h.CPUSockets,
+		CPUCores:                h.CPUCores,
+		CPUThreads:              h.CPUThreads,
 		MemorySize:              h.defaultMemSz(),
 		MemSlots:                h.defaultMemSlots(),
 		MemOffset:               h.defaultMemOffset(),
@@ -792,10 +1047,12 @@ func newClhHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		EntropySource:           h.GetEntropySource(),
 		DefaultBridges:          h.defaultBridges(),
 		DisableBlockDeviceUse:   h.DisableBlockDeviceUse,
+		BlockVolumeThreshold:    h.BlockVolumeThreshold,
 		SharedFS:                sharedFS,
 		VirtioFSDaemon:          h.VirtioFSDaemon,
 		VirtioFSCacheSize:       h.VirtioFSCacheSize,
 		VirtioFSCache:           h.VirtioFSCache,
+		VirtioFSThreadPoolSize:  h.VirtioFSThreadPoolSize,
 		MemPrealloc:             h.MemPrealloc,
 		HugePages:               h.HugePages,
 		FileBackedMemRootDir:    h.FileBackedMemRootDir,
@@ -811,7 +1068,14 @@ func newClhHypervisorConfig(h hypervisor) (vc.HypervisorConfig, error) {
 		HotplugVFIOOnRootBus:    h.HotplugVFIOOnRootBus,
 		PCIeRootPort:            h.PCIeRootPort,
 		DisableVhostNet:         true,
+		NetworkQueues:           h.NetworkQueues,
 		UseVSock:                true,
+		UsePmemRootfs:           h.UsePmemRootfs,
+		EnableAnnotations:       h.EnableAnnotations,
+		VSockChannels:           h.VSockChannels,
+		EmulatorThreadsCPUs:     h.EmulatorThreadsCPUs,
+		EmulatorThreadsNice:     h.EmulatorThreadsNice,
+		EmulatorRTPriority:      h.EmulatorRTPriority,
 	}, nil
 }
 
@@ -830,6 +1094,97 @@ func newFactoryConfig(f factory) (oci.FactoryConfig, error) {
 	}, nil
 }
 
+func newAssetRegistryConfig(a assets) (vc.AssetRegistryConfig, error) {
+	registry := vc.AssetRegistryConfig{
+		CacheDir: a.CacheDir,
+		Manifest: a.Manifest,
+	}
+
+	for _, entry := range []struct {
+		t      types.AssetType
+		source asset
+	}{
+		{types.KernelAsset, a.Kernel},
+		{types.ImageAsset, a.Image},
+		{types.InitrdAsset, a.Initrd},
+		{types.FirmwareAsset, a.Firmware},
+		{types.HypervisorAsset, a.Hypervisor},
+		{types.JailerAsset, a.Jailer},
+	} {
+		t, source := entry.t, entry.source
+
+		if source.Digest == "" {
+			if source.URL != "" {
+				return vc.AssetRegistryConfig{}, fmt.Errorf("Asset %s has an url but no digest", t)
+			}
+			continue
+		}
+
+		if source.URL != "" && !vc.IsFetchableAsset(t) {
+			return vc.AssetRegistryConfig{}, fmt.Errorf("Asset %s cannot be fetched from an url", t)
+		}
+
+		if _, _, err := vc.ParseAssetDigest(source.Digest); err != nil {
+			return vc.AssetRegistryConfig{}, fmt.Errorf("Asset %s: %v", t, err)
+		}
+
+		if registry.Assets == nil {
+			registry.Assets = make(map[types.AssetType]vc.AssetSource)
+		}
+
+		registry.Assets[t] = vc.AssetSource{
+			Digest: source.Digest,
+			URL:    source.URL,
+		}
+	}
+
+	return registry, nil
+}
+
+func newAssetProfiles(profiles map[string]assetProfile) (map[string]oci.AssetProfile, error) {
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+
+	resolve := func(path string) (string, error) {
+		if path == "" {
+			return "", nil
+		}
+		return ResolvePath(path)
+	}
+
+	assetProfiles := make(map[string]oci.AssetProfile)
+	for name, p := range profiles {
+		if p.Image != "" && p.Initrd != "" {
+			return nil, fmt.Errorf("Asset profile %s: cannot specify an image and an initrd", name)
+		}
+
+		kernel, err := resolve(p.Kernel)
+		if err != nil {
+			return nil, fmt.Errorf("Asset profile %s: %v", name, err)
+		}
+
+		image, err := resolve(p.Image)
+		if err != nil {
+			return nil, fmt.Errorf("Asset profile %s: %v", name, err)
+		}
+
+		initrd, err := resolve(p.Initrd)
+		if err != nil {
+			return nil, fmt.Errorf("Asset profile %s: %v", name, err)
+		}
+
+		assetProfiles[name] = oci.AssetProfile{
+			KernelPath:   kernel,
+			ImagePath:    image,
+			InitrdPath:   initrd,
+			KernelParams: vc.DeserializeParams(strings.Fields(p.KernelParams)),
+		}
+	}
+
+	return assetProfiles, nil
+}
+
 func newShimConfig(s shim) (vc.ShimConfig, error) {
 	path, err := s.path()
 	if err != nil {
@@ -914,10 +1269,16 @@ func updateRuntimeConfigAgent(configPath string, tomlConf tomlConfig, config *oc
 
 		config.AgentType = vc.KataContainersAgent
 		config.AgentConfig = vc.KataAgentConfig{
-			LongLiveConn:  true,
-			UseVSock:      config.HypervisorConfig.UseVSock,
-			Debug:         agentConfig.Debug,
-			KernelModules: agentConfig.KernelModules,
+			LongLiveConn:   true,
+			UseVSock:       config.HypervisorConfig.UseVSock,
+			Debug:          agentConfig.Debug,
+			KernelModules:  agentConfig.KernelModules,
+			DialTimeout:    agentConfig.DialTimeout,
+			RequestTimeout: agentConfig.RequestTimeout,
+			RequestRetries: agentConfig.RequestRetries,
+			RetryDelay:     agentConfig.RetryDelay,
+
+			DebugConsoleEnabled: agentConfig.DebugConsoleEnabled,
 		}
 
 		return nil
@@ -928,12 +1289,18 @@ func updateRuntimeConfigAgent(configPath string, tomlConf tomlConfig, config *oc
 		case kataAgentTableType:
 			config.AgentType = vc.KataContainersAgent
 			config.AgentConfig = vc.KataAgentConfig{
-				UseVSock:      config.HypervisorConfig.UseVSock,
-				Debug:         agent.debug(),
-				Trace:         agent.trace(),
-				TraceMode:     agent.traceMode(),
-				TraceType:     agent.traceType(),
-				KernelModules: agent.kernelModules(),
+				UseVSock:       config.HypervisorConfig.UseVSock,
+				Debug:          agent.debug(),
+				Trace:          agent.trace(),
+				TraceMode:      agent.traceMode(),
+				TraceType:      agent.traceType(),
+				KernelModules:  agent.kernelModules(),
+				DialTimeout:    agent.DialTimeout,
+				RequestTimeout: agent.RequestTimeout,
+				RequestRetries: agent.RequestRetries,
+				RetryDelay:     agent.RetryDelay,
+
+				DebugConsoleEnabled: agent.DebugConsole,
 			}
 		default:
 			return fmt.Errorf("%s agent type is not supported", k)
@@ -1020,6 +1387,40 @@ func SetKernelParams(runtimeConfig *oci.RuntimeConfig) error {
 	return nil
 }
 
+func updateRuntimeTraceConfig(tomlConf tomlConfig, config *oci.RuntimeConfig) error {
+	rt := tomlConf.Runtime
+
+	if rt.TraceCollectorEndpoint != "" &&
+		!strings.HasPrefix(rt.TraceCollectorEndpoint, "http://") &&
+		!strings.HasPrefix(rt.TraceCollectorEndpoint, "https://") {
+		return fmt.Errorf("Invalid trace collector endpoint %q, expecting an http or https URL", rt.TraceCollectorEndpoint)
+	}
+
+	if rt.TraceSampleRate < 0 || rt.TraceSampleRate > 1 {
+		return fmt.Errorf("Invalid trace sample rate %v, expecting a value between 0 and 1", rt.TraceSampleRate)
+	}
+
+	if err := vc.SetTracingDisabledSubsystems(rt.TraceDisabledSubsystems); err != nil {
+		return err
+	}
+
+	config.TraceCollectorEndpoint = rt.TraceCollectorEndpoint
+	config.TraceAgentEndpoint = rt.TraceAgentEndpoint
+	config.TraceSampleRate = rt.TraceSampleRate
+	config.TraceDisabledSubsystems = rt.TraceDisabledSubsystems
+
+	// an unset sample rate reports all the traces
+	if config.TraceSampleRate == 0 {
+		config.TraceSampleRate = 1
+	}
+
+	traceCollectorEndpoint = config.TraceCollectorEndpoint
+	traceAgentEndpoint = config.TraceAgentEndpoint
+	traceSampleRate = config.TraceSampleRate
+
+	return nil
+}
+
 func updateRuntimeConfig(configPath string, tomlConf tomlConfig, config *oci.RuntimeConfig, builtIn bool) error {
 	if err := updateRuntimeConfigHypervisor(configPath, tomlConf, config); err != nil {
 		return err
@@ -1043,12 +1444,30 @@ func updateRuntimeConfig(configPath string, tomlConf tomlConfig, config *oci.Run
 	}
 	config.FactoryConfig = fConfig
 
+	registry, err := newAssetRegistryConfig(tomlConf.Assets)
+	if err != nil {
+		return fmt.Errorf("%v: %v", configPath, err)
+	}
+	config.AssetRegistry = registry
+
+	profiles, err := newAssetProfiles(tomlConf.AssetProfile)
+	if err != nil {
+		return fmt.Errorf("%v: %v", configPath, err)
+	}
+	config.AssetProfiles = profiles
+
 	config.NetmonConfig = vc.NetmonConfig{
 		Path:   tomlConf.Netmon.path(),
 		Debug:  tomlConf.Netmon.debug(),
 		Enable: tomlConf.Netmon.enable(),
 	}
 
+	config.CNIConfig = vc.CNIConfig{
+		Enable:  tomlConf.CNI.Enable,
+		ConfDir: tomlConf.CNI.confDir(),
+		BinDirs: tomlConf.CNI.binDirs(),
+	}
+
 	err = SetKernelParams(config)
 	if err != nil {
 		return err
@@ -1147,6 +1566,10 @@ func LoadConfiguration(configPath string, ignoreLogging, builtIn bool) (resolved
 	config.Trace = tomlConf.Runtime.Tracing
 	tracing = config.Trace
 
+	if err := updateRuntimeTraceConfig(tomlConf, &config); err != nil {
+		return "", config, err
+	}
+
 	if tomlConf.Runtime.InterNetworkModel != "" {
 		err = config.InterNetworkModel.SetModel(tomlConf.Runtime.InterNetworkModel)
 		if err != nil {
@@ -1155,7 +1578,7 @@ func LoadConfiguration(configPath string, ignoreLogging, builtIn bool) (resolved
 	}
 
 	if !ignoreLogging {
-		err := handleSystemLog("", "")
+		err := handleSystemLog(tomlConf.Runtime.SystemLog, "", "", tomlConf.Runtime.SystemLogRateLimits)
 		if err != nil {
 			return "", config, err
 		}
@@ -1171,7 +1594,13 @@ func LoadConfiguration(configPath string, ignoreLogging, builtIn bool) (resolved
 		return "", config, err
 	}
 
+	if err := handlePersistRemoteStore(tomlConf.Runtime.PersistRemoteStore); err != nil {
+		return "", config, err
+	}
+
 	config.DisableGuestSeccomp = tomlConf.Runtime.DisableGuestSeccomp
+	config.DisableGuestSELinux = tomlConf.Runtime.DisableGuestSELinux
+	config.DisableGuestAppArmor = tomlConf.Runtime.DisableGuestAppArmor
 
 	// use no proxy if HypervisorConfig.UseVSock is true
 	if config.HypervisorConfig.UseVSock {
@@ -1181,7 +1610,28 @@ func LoadConfiguration(configPath string, ignoreLogging, builtIn bool) (resolved
 	}
 
 	config.SandboxCgroupOnly = tomlConf.Runtime.SandboxCgroupOnly
+	config.PrivilegedDeviceAllowList = tomlConf.Runtime.PrivilegedDeviceAllowList
+	config.SandboxTmpQuota = tomlConf.Runtime.SandboxTmpQuota
+	config.ScratchDiskSize = tomlConf.Runtime.ScratchDiskSize
+	config.StatsVMMOverhead = tomlConf.Runtime.StatsVMMOverhead
+	config.GuestTimeSyncInterval = tomlConf.Runtime.GuestTimeSyncInterval
+	config.MountWatchInterval = tomlConf.Runtime.MountWatchInterval
+	config.IdlePauseTimeout = tomlConf.Runtime.IdlePauseTimeout
+	config.StaticSandboxResourceMgmt = tomlConf.Runtime.StaticSandboxResourceMgmt
+	config.MemorySizing = tomlConf.Runtime.memorySizing()
+	config.Rootless = tomlConf.Runtime.Rootless
+	config.Slirp4netnsPath = tomlConf.Runtime.Slirp4netnsPath
+	config.HypervisorExitHook = tomlConf.Runtime.HypervisorExitHook
+	config.AuditLog = tomlConf.Runtime.AuditLog
+	config.SandboxLogDir = tomlConf.Runtime.SandboxLogDir
+	config.SandboxLogFormat = tomlConf.Runtime.SandboxLogFormat
+	config.GuestDNS = tomlConf.Runtime.GuestDNS
+	config.NetSysctlAllowList = tomlConf.Runtime.NetSysctlAllowList
 	config.DisableNewNetNs = tomlConf.Runtime.DisableNewNetNs
+	config.NetworkMTU = tomlConf.Runtime.NetworkMTU
+	config.ConntrackBypass = tomlConf.Runtime.ConntrackBypass
+	config.EnablePprof = tomlConf.Runtime.EnablePprof
+	config.VhostUserSocketPath = tomlConf.Runtime.VhostUserSocketPath
 	for _, f := range tomlConf.Runtime.Experimental {
 		feature := exp.Get(f)
 		if feature == nil {
@@ -1241,16 +1691,110 @@ func checkConfig(config oci.RuntimeConfig) error {
 		return err
 	}
 
+	if err := checkIdlePauseConfig(config); err != nil {
+		return err
+	}
+
+	if err := checkAuditLog(config.AuditLog); err != nil {
+		return err
+	}
+
+	if err := checkSandboxLog(config); err != nil {
+		return err
+	}
+
+	if err := checkMemorySizing(config); err != nil {
+		return err
+	}
+
+	if config.VhostUserSocketPath != "" {
+		if err := vc.CheckVhostUserSocketPath(config.VhostUserSocketPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkMemorySizing ensures the memory of the sandboxes can be sized when
+// they are created.
+func checkMemorySizing(config oci.RuntimeConfig) error {
+	if err := config.MemorySizing.Valid(); err != nil {
+		return err
+	}
+
+	// the VMs of the factory are created before the pods are known
+	if config.MemorySizing.Dynamic() && (config.FactoryConfig.Template || config.FactoryConfig.VMCacheNumber > 0) {
+		return errors.New("memory_sizing dynamic conflicts with the VM factory")
+	}
+
 	return nil
 }
 
+// checkAuditLog ensures the audit events sink is either the journal or an
+// absolute file path.
+func checkAuditLog(auditLog string) error {
+	if auditLog == "" || auditLog == "journal" {
+		return nil
+	}
+
+	if !filepath.IsAbs(auditLog) {
+		return fmt.Errorf("audit_log must be %q or an absolute path, got %q", "journal", auditLog)
+	}
+
+	return nil
+}
+
+// handlePersistRemoteStore mirrors the persist data of the sandboxes into the
+// etcd cluster at endpoint, if any, for them to survive the loss of the local
+// state of the node.
+func handlePersistRemoteStore(endpoint string) error {
+	if endpoint == "" {
+		persist.SetRemoteStore(nil)
+		return nil
+	}
+
+	store, err := kv.NewEtcd(endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid persist_remote_store: %v", err)
+	}
+
+	persist.SetRemoteStore(store)
+
+	return nil
+}
+
+// checkSandboxLog ensures the sandbox logs directory is an absolute path and
+// their format is known.
+func checkSandboxLog(config oci.RuntimeConfig) error {
+	if config.SandboxLogDir != "" && !filepath.IsAbs(config.SandboxLogDir) {
+		return fmt.Errorf("sandbox_log_dir must be an absolute path, got %q", config.SandboxLogDir)
+	}
+
+	return vc.CheckSandboxLogFormat(config.SandboxLogFormat)
+}
+
 // checkNetNsConfig performs sanity checks on disable_new_netns config.
 // Because it is an expert option and conflicts with some other common configs.
 func checkNetNsConfig(config oci.RuntimeConfig) error {
+	if config.ConntrackBypass {
+		model := config.InterNetworkModel
+		if model == vc.NetXConnectDefaultModel {
+			model = vc.DefaultNetInterworkingModel
+		}
+
+		if model != vc.NetXConnectTCFilterModel && model != vc.NetXConnectEBPFModel {
+			return fmt.Errorf("config conntrack_bypass only works with 'tcfilter' or 'ebpf' internetworking_model")
+		}
+	}
+
 	if config.DisableNewNetNs {
 		if config.NetmonConfig.Enable {
 			return fmt.Errorf("config disable_new_netns conflicts with enable_netmon")
 		}
+		if config.CNIConfig.Enable {
+			return fmt.Errorf("config disable_new_netns conflicts with enable_cni")
+		}
 		if config.InterNetworkModel != vc.NetXConnectNoneModel {
 			return fmt.Errorf("config disable_new_netns only works with 'none' internetworking_model")
 		}
@@ -1284,6 +1828,21 @@ func checkFactoryConfig(config oci.RuntimeConfig) error {
 	return nil
 }
 
+// checkIdlePauseConfig ensures idle sandboxes are only paused with the
+// hypervisors which can pause the VM.
+func checkIdlePauseConfig(config oci.RuntimeConfig) error {
+	if config.IdlePauseTimeout == 0 {
+		return nil
+	}
+
+	switch config.HypervisorType {
+	case vc.QemuHypervisor, vc.FirecrackerHypervisor:
+		return nil
+	}
+
+	return fmt.Errorf("idle_pause_timeout is only supported by qemu and firecracker, not %s", config.HypervisorType)
+}
+
 // checkHypervisorConfig performs basic "sanity checks" on the hypervisor
 // config.
 func checkHypervisorConfig(config vc.HypervisorConfig) error {
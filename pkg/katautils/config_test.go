@@ -21,7 +21,9 @@ import (
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	vc "github.com/kata-containers/runtime/virtcontainers"
+	vcCNI "github.com/kata-containers/runtime/virtcontainers/pkg/cni"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 	"github.com/stretchr/testify/assert"
 )
@@ -208,6 +210,11 @@ func createAllRuntimeConfigFiles(dir, hypervisor string) (config testRuntimeConf
 		NetmonConfig:    netmonConfig,
 		DisableNewNetNs: disableNewNetNs,
 
+		CNIConfig: vc.CNIConfig{
+			ConfDir: vcCNI.DefaultConfDir,
+			BinDirs: []string{vcCNI.DefaultBinDir},
+		},
+
 		FactoryConfig: factoryConfig,
 	}
 
@@ -669,6 +676,11 @@ func TestMinimalRuntimeConfig(t *testing.T) {
 
 		NetmonConfig: expectedNetmonConfig,
 
+		CNIConfig: vc.CNIConfig{
+			ConfDir: vcCNI.DefaultConfDir,
+			BinDirs: []string{vcCNI.DefaultBinDir},
+		},
+
 		FactoryConfig: expectedFactoryConfig,
 	}
 	err = SetKernelParams(&expectedConfig)
@@ -797,6 +809,7 @@ func TestNewQemuHypervisorConfig(t *testing.T) {
 		HotplugVFIOOnRootBus:  hotplugVFIOOnRootBus,
 		PCIeRootPort:          pcieRootPort,
 		UseVSock:              true,
+		BlockDeviceQueues:     4,
 	}
 
 	files := []string{hypervisorPath, kernelPath, imagePath}
@@ -857,6 +870,10 @@ func TestNewQemuHypervisorConfig(t *testing.T) {
 	if config.PCIeRootPort != pcieRootPort {
 		t.Errorf("Expected value for PCIeRootPort %v, got %v", pcieRootPort, config.PCIeRootPort)
 	}
+
+	if config.BlockDeviceQueues != 4 {
+		t.Errorf("Expected value for BlockDeviceQueues %v, got %v", 4, config.BlockDeviceQueues)
+	}
 }
 
 func TestNewQemuHypervisorConfigImageAndInitrd(t *testing.T) {
@@ -900,6 +917,71 @@ func TestNewQemuHypervisorConfigImageAndInitrd(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestNewQemuHypervisorConfigPmemRootfs(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir(testDir, "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	imagePath := filepath.Join(tmpdir, "image")
+	hypervisorPath := path.Join(tmpdir, "hypervisor")
+	kernelPath := path.Join(tmpdir, "kernel")
+
+	for _, file := range []string{imagePath, hypervisorPath, kernelPath} {
+		err = createEmptyFile(file)
+		assert.NoError(err)
+	}
+
+	hypervisor := hypervisor{
+		Path:               hypervisorPath,
+		Kernel:             kernelPath,
+		Image:              imagePath,
+		UsePmemRootfs:      true,
+		DisableImageNvdimm: true,
+	}
+
+	_, err = newQemuHypervisorConfig(hypervisor)
+	assert.Error(err)
+
+	// QEMU plugs the image as a nvdimm device already
+	hypervisor.DisableImageNvdimm = false
+	_, err = newQemuHypervisorConfig(hypervisor)
+	assert.Error(err)
+}
+
+func TestNewQemuHypervisorConfigTimeouts(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir(testDir, "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	imagePath := filepath.Join(tmpdir, "image")
+	hypervisorPath := path.Join(tmpdir, "hypervisor")
+	kernelPath := path.Join(tmpdir, "kernel")
+
+	for _, file := range []string{imagePath, hypervisorPath, kernelPath} {
+		err = createEmptyFile(file)
+		assert.NoError(err)
+	}
+
+	hypervisor := hypervisor{
+		Path:            hypervisorPath,
+		Kernel:          kernelPath,
+		Image:           imagePath,
+		VMMAPITimeout:   30,
+		BootTimeout:     60,
+		ShutdownTimeout: 5,
+	}
+
+	config, err := newQemuHypervisorConfig(hypervisor)
+	assert.NoError(err)
+	assert.Equal(uint32(30), config.VMMAPITimeout)
+	assert.Equal(uint32(60), config.BootTimeout)
+	assert.Equal(uint32(5), config.ShutdownTimeout)
+}
+
 func TestNewClhHypervisorConfig(t *testing.T) {
 
 	assert := assert.New(t)
@@ -924,6 +1006,7 @@ func TestNewClhHypervisorConfig(t *testing.T) {
 		Image:          imagePath,
 		VirtioFSDaemon: virtioFsDaemon,
 		VirtioFSCache:  "always",
+		NetworkQueues:  4,
 	}
 	config, err := newClhHypervisorConfig(hypervisor)
 	if err != nil {
@@ -958,6 +1041,10 @@ func TestNewClhHypervisorConfig(t *testing.T) {
 		t.Errorf("Expected VirtioFSCache %v, got %v", true, config.VirtioFSCache)
 	}
 
+	if config.NetworkQueues != 4 {
+		t.Errorf("Expected NetworkQueues %v, got %v", 4, config.NetworkQueues)
+	}
+
 }
 
 func TestNewShimConfig(t *testing.T) {
@@ -1215,6 +1302,31 @@ func TestHypervisorDefaultsVhostUserStorePath(t *testing.T) {
 	assert.Equal(vhostUserStorePath, testVhostUserStorePath, "custom vhost-user store path wrong")
 }
 
+func TestHypervisorDefaultsJailerChrootBase(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir(testDir, "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	hypervisorPath := filepath.Join(tmpdir, "firecracker")
+	assert.NoError(createEmptyFile(hypervisorPath))
+
+	h := hypervisor{}
+	chrootBase, err := h.jailerChrootBase(hypervisorPath)
+	assert.NoError(err)
+	assert.Empty(chrootBase)
+
+	h.JailerChrootBase = filepath.Join(tmpdir, "jail") + "/"
+	chrootBase, err = h.jailerChrootBase(hypervisorPath)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(tmpdir, "jail"), chrootBase)
+
+	h.JailerChrootBase = "jail"
+	_, err = h.jailerChrootBase(hypervisorPath)
+	assert.Error(err)
+}
+
 func TestProxyDefaults(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1561,6 +1673,123 @@ func TestUpdateRuntimeConfigurationFactoryConfig(t *testing.T) {
 	assert.Equal(expectedFactoryConfig, config.FactoryConfig)
 }
 
+func TestUpdateRuntimeTraceConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	savedCollectorEndpoint := traceCollectorEndpoint
+	savedAgentEndpoint := traceAgentEndpoint
+	savedSampleRate := traceSampleRate
+	defer func() {
+		traceCollectorEndpoint = savedCollectorEndpoint
+		traceAgentEndpoint = savedAgentEndpoint
+		traceSampleRate = savedSampleRate
+		vc.SetTracingDisabledSubsystems(nil)
+	}()
+
+	config := oci.RuntimeConfig{}
+	assert.NoError(updateRuntimeTraceConfig(tomlConfig{}, &config))
+	assert.Equal(float64(1), config.TraceSampleRate)
+
+	tomlConf := tomlConfig{Runtime: runtime{
+		TraceCollectorEndpoint:  "http://localhost:14268/api/traces",
+		TraceSampleRate:         0.25,
+		TraceDisabledSubsystems: []string{"network"},
+	}}
+	assert.NoError(updateRuntimeTraceConfig(tomlConf, &config))
+	assert.Equal("http://localhost:14268/api/traces", config.TraceCollectorEndpoint)
+	assert.Equal(0.25, config.TraceSampleRate)
+	assert.Equal([]string{"network"}, config.TraceDisabledSubsystems)
+	assert.Equal(config.TraceCollectorEndpoint, traceCollectorEndpoint)
+	assert.Equal(config.TraceSampleRate, traceSampleRate)
+
+	for _, rt := range []runtime{
+		{TraceCollectorEndpoint: "localhost:14268"},
+		{TraceSampleRate: 1.5},
+		{TraceSampleRate: -1},
+		{TraceDisabledSubsystems: []string{"foo"}},
+	} {
+		assert.Error(updateRuntimeTraceConfig(tomlConfig{Runtime: rt}, &config), "runtime: %+v", rt)
+	}
+}
+
+func TestNewAssetRegistryConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	registry, err := newAssetRegistryConfig(assets{})
+	assert.NoError(err)
+	assert.Equal(vc.AssetRegistryConfig{}, registry)
+
+	digest := "sha256:" + strings.Repeat("ab", 32)
+
+	registry, err = newAssetRegistryConfig(assets{
+		CacheDir:   "/cache",
+		Manifest:   "/usr/share/kata-containers/SHA256SUMS",
+		Kernel:     asset{Digest: digest, URL: "https://example.com/vmlinux"},
+		Image:      asset{Digest: digest},
+		Hypervisor: asset{Digest: digest},
+	})
+	assert.NoError(err)
+	assert.Equal(vc.AssetRegistryConfig{
+		CacheDir: "/cache",
+		Manifest: "/usr/share/kata-containers/SHA256SUMS",
+		Assets: map[types.AssetType]vc.AssetSource{
+			types.KernelAsset:     {Digest: digest, URL: "https://example.com/vmlinux"},
+			types.ImageAsset:      {Digest: digest},
+			types.HypervisorAsset: {Digest: digest},
+		},
+	}, registry)
+
+	// url without digest
+	_, err = newAssetRegistryConfig(assets{Initrd: asset{URL: "https://example.com/initrd"}})
+	assert.Error(err)
+
+	// host binaries are never fetched
+	_, err = newAssetRegistryConfig(assets{Jailer: asset{Digest: digest, URL: "https://example.com/jailer"}})
+	assert.Error(err)
+
+	for _, d := range []string{"ab", "md5:" + strings.Repeat("ab", 16), "sha256:abcd", "sha512:" + strings.Repeat("zz", 64)} {
+		_, err = newAssetRegistryConfig(assets{Firmware: asset{Digest: d}})
+		assert.Error(err, "digest %q", d)
+	}
+}
+
+func TestNewAssetProfiles(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	kernel := filepath.Join(dir, "vmlinux-rt")
+	initrd := filepath.Join(dir, "initrd-gpu.img")
+	for _, file := range []string{kernel, initrd} {
+		assert.NoError(createEmptyFile(file))
+	}
+
+	profiles, err := newAssetProfiles(nil)
+	assert.NoError(err)
+	assert.Nil(profiles)
+
+	profiles, err = newAssetProfiles(map[string]assetProfile{
+		"rt-kernel": {Kernel: kernel, KernelParams: "isolcpus=1 nohz"},
+		"gpu":       {Initrd: initrd},
+	})
+	assert.NoError(err)
+	assert.Equal(map[string]oci.AssetProfile{
+		"rt-kernel": {
+			KernelPath:   kernel,
+			KernelParams: []vc.Param{{Key: "isolcpus", Value: "1"}, {Key: "nohz"}},
+		},
+		"gpu": {InitrdPath: initrd},
+	}, profiles)
+
+	_, err = newAssetProfiles(map[string]assetProfile{"gpu": {Kernel: filepath.Join(dir, "missing")}})
+	assert.Error(err)
+
+	_, err = newAssetProfiles(map[string]assetProfile{"gpu": {Image: initrd, Initrd: initrd}})
+	assert.Error(err)
+}
+
 func TestUpdateRuntimeConfigurationInvalidKernelParams(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1588,6 +1817,40 @@ func TestUpdateRuntimeConfigurationInvalidKernelParams(t *testing.T) {
 	assert.EqualError(err, "Empty kernel parameter")
 }
 
+func TestDetectInitrdAndImage(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(testDir, "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	// a gzip compressed initramfs
+	initrd := filepath.Join(dir, "kata-containers-initrd.img")
+	assert.NoError(ioutil.WriteFile(initrd, []byte{0x1f, 0x8b, 0x08, 0x00}, testFileMode))
+
+	unknown := filepath.Join(dir, "kata-containers.img")
+	assert.NoError(createEmptyFile(unknown))
+
+	h := hypervisor{Image: initrd}
+	gotInitrd, gotImage, err := h.getInitrdAndImage()
+	assert.NoError(err)
+	assert.Equal(initrd, gotInitrd)
+	assert.Empty(gotImage)
+
+	gotInitrd, gotImage = detectInitrdAndImage(initrd, "")
+	assert.Equal(initrd, gotInitrd)
+	assert.Empty(gotImage)
+
+	// the files which aren't recognized are left as configured
+	gotInitrd, gotImage = detectInitrdAndImage("", unknown)
+	assert.Empty(gotInitrd)
+	assert.Equal(unknown, gotImage)
+
+	gotInitrd, gotImage = detectInitrdAndImage(unknown, "")
+	assert.Equal(unknown, gotInitrd)
+	assert.Empty(gotImage)
+}
+
 func TestCheckHypervisorConfig(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1710,6 +1973,105 @@ func TestCheckNetNsConfig(t *testing.T) {
 	}
 	err = checkNetNsConfig(config)
 	assert.Error(err)
+
+	config = oci.RuntimeConfig{
+		DisableNewNetNs: true,
+		CNIConfig: vc.CNIConfig{
+			Enable: true,
+		},
+	}
+	err = checkNetNsConfig(config)
+	assert.Error(err)
+
+	config = oci.RuntimeConfig{
+		ConntrackBypass:   true,
+		InterNetworkModel: vc.NetXConnectMacVtapModel,
+	}
+	err = checkNetNsConfig(config)
+	assert.Error(err)
+
+	config.InterNetworkModel = vc.NetXConnectDefaultModel
+	assert.NoError(checkNetNsConfig(config))
+
+	config.InterNetworkModel = vc.NetXConnectEBPFModel
+	assert.NoError(checkNetNsConfig(config))
+
+	config.DisableNewNetNs = true
+	config.InterNetworkModel = vc.NetXConnectNoneModel
+	assert.Error(checkNetNsConfig(config))
+}
+
+func TestCheckAuditLog(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(checkAuditLog(""))
+	assert.NoError(checkAuditLog("journal"))
+	assert.NoError(checkAuditLog("/var/log/kata-containers/audit.log"))
+	assert.Error(checkAuditLog("audit.log"))
+}
+
+func TestHandlePersistRemoteStore(t *testing.T) {
+	assert := assert.New(t)
+
+	defer handlePersistRemoteStore("")
+
+	assert.NoError(handlePersistRemoteStore(""))
+	assert.NoError(handlePersistRemoteStore("http://127.0.0.1:2379"))
+	assert.Error(handlePersistRemoteStore("127.0.0.1:2379"))
+}
+
+func TestCheckSandboxLog(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(checkSandboxLog(oci.RuntimeConfig{}))
+	assert.NoError(checkSandboxLog(oci.RuntimeConfig{SandboxLogDir: "/var/log/kata-containers/sandboxes", SandboxLogFormat: "json"}))
+	assert.Error(checkSandboxLog(oci.RuntimeConfig{SandboxLogDir: "sandboxes"}))
+	assert.Error(checkSandboxLog(oci.RuntimeConfig{SandboxLogDir: "/var/log/kata-containers/sandboxes", SandboxLogFormat: "xml"}))
+}
+
+func TestCheckMemorySizing(t *testing.T) {
+	assert := assert.New(t)
+
+	config := oci.RuntimeConfig{
+		MemorySizing: runtime{MemorySizing: "dynamic", MemorySizingCeiling: 4096}.memorySizing(),
+	}
+	assert.Equal(vc.MemorySizing{
+		Policy:     vc.MemorySizingDynamic,
+		Floor:      vc.MinHypervisorMemory,
+		Ceiling:    4096,
+		HostMargin: defaultMemorySizingHostMargin,
+	}, config.MemorySizing)
+	assert.NoError(checkMemorySizing(config))
+
+	// finite by default
+	assert.Equal(defaultMemorySizingCeiling, runtime{MemorySizing: "dynamic"}.memorySizing().Ceiling)
+
+	config.FactoryConfig.Template = true
+	assert.Error(checkMemorySizing(config))
+
+	config.MemorySizing.Policy = vc.MemorySizingStatic
+	assert.NoError(checkMemorySizing(config))
+
+	config.MemorySizing.Policy = "auto"
+	assert.Error(checkMemorySizing(config))
+}
+
+func TestCheckIdlePauseConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	config := oci.RuntimeConfig{
+		HypervisorType: vc.ClhHypervisor,
+	}
+	assert.NoError(checkIdlePauseConfig(config))
+
+	config.IdlePauseTimeout = 600
+	assert.Error(checkIdlePauseConfig(config))
+
+	config.HypervisorType = vc.QemuHypervisor
+	assert.NoError(checkIdlePauseConfig(config))
+
+	config.HypervisorType = vc.FirecrackerHypervisor
+	assert.NoError(checkIdlePauseConfig(config))
 }
 
 func TestCheckFactoryConfig(t *testing.T) {
@@ -15,8 +15,10 @@ import (
 
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	vf "github.com/kata-containers/runtime/virtcontainers/factory"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/cdi"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/sirupsen/logrus"
 )
 
 // GetKernelParamsFunc use a variable to allow tests to modify its value
@@ -48,7 +50,18 @@ func getKernelParams(needSystemd, trace bool) []vc.Param {
 }
 
 func needSystemd(config vc.HypervisorConfig) bool {
-	return config.ImagePath != ""
+	if config.ImagePath == "" {
+		return false
+	}
+
+	// the images running the agent as init have no systemd to configure
+	imageType, err := vc.DetectGuestImage(config.ImagePath)
+	if err != nil {
+		kataUtilsLogger.WithError(err).WithField("image", config.ImagePath).Warn("Could not detect the guest image type")
+		return true
+	}
+
+	return imageType != vc.GuestImageAgentInit
 }
 
 // HandleFactory  set the factory
@@ -109,11 +122,18 @@ func CreateSandbox(ctx context.Context, vci vc.VC, ociSpec specs.Spec, runtimeCo
 	span, ctx := Trace(ctx, "createSandbox")
 	defer span.Finish()
 
+	modules, err := cdi.InjectDevices(&ociSpec, cdi.DefaultSpecDirs)
+	if err != nil {
+		return nil, vc.Process{}, err
+	}
+
 	sandboxConfig, err := oci.SandboxConfig(ociSpec, runtimeConfig, bundlePath, containerID, console, disableOutput, systemdCgroup)
 	if err != nil {
 		return nil, vc.Process{}, err
 	}
 
+	addCDIKernelModules(&sandboxConfig, modules)
+
 	if builtIn {
 		sandboxConfig.Stateful = true
 	}
@@ -144,15 +164,22 @@ func CreateSandbox(ctx context.Context, vci vc.VC, ociSpec specs.Spec, runtimeCo
 		// cleanup netns if kata creates it
 		ns := sandboxConfig.NetworkConfig
 		if err != nil && ns.NetNsCreated {
+			if ns.CNIConfig.Enable {
+				if ex := vc.RemoveCNINetwork(ctx, ns.NetNSPath, ns.CNIConfig); ex != nil {
+					kataUtilsLogger.WithField("path", ns.NetNSPath).WithError(ex).Warn("failed to tear down CNI network")
+				}
+			}
 			if ex := cleanupNetNS(ns.NetNSPath); ex != nil {
 				kataUtilsLogger.WithField("path", ns.NetNSPath).WithError(ex).Warn("failed to cleanup netns")
 			}
 		}
 	}()
 
-	// Run pre-start OCI hooks.
-	err = EnterNetNS(sandboxConfig.NetworkConfig.NetNSPath, func() error {
-		return PreStartHooks(ctx, ociSpec, containerID, bundlePath)
+	// Run pre-start OCI hooks, the VM is not created yet.
+	err = PreStartHooks(ctx, ociSpec, containerID, bundlePath, vc.SandboxStatus{
+		ID:         sandboxConfig.ID,
+		Hypervisor: sandboxConfig.HypervisorType,
+		NetNsPath:  sandboxConfig.NetworkConfig.NetNSPath,
 	})
 	if err != nil {
 		return nil, vc.Process{}, err
@@ -182,6 +209,31 @@ func CreateSandbox(ctx context.Context, vci vc.VC, ociSpec specs.Spec, runtimeCo
 	return sandbox, containers[0].Process(), nil
 }
 
+// addCDIKernelModules adds the guest kernel modules the CDI devices of the
+// sandbox need to the modules the agent loads.
+func addCDIKernelModules(sandboxConfig *vc.SandboxConfig, modules []string) {
+	c, ok := sandboxConfig.AgentConfig.(vc.KataAgentConfig)
+	if !ok || len(modules) == 0 {
+		return
+	}
+
+	for _, m := range modules {
+		found := false
+		for _, km := range c.KernelModules {
+			if km == m {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			c.KernelModules = append(c.KernelModules, m)
+		}
+	}
+
+	sandboxConfig.AgentConfig = c
+}
+
 var procFIPS = "/proc/sys/crypto/fips_enabled"
 
 func checkForFIPS(sandboxConfig *vc.SandboxConfig) error {
@@ -220,6 +272,19 @@ func CreateContainer(ctx context.Context, vci vc.VC, sandbox vc.VCSandbox, ociSp
 
 	ociSpec = SetEphemeralStorageType(ociSpec)
 
+	modules, err := cdi.InjectDevices(&ociSpec, cdi.DefaultSpecDirs)
+	if err != nil {
+		return vc.Process{}, err
+	}
+
+	// the agent loads the kernel modules when the sandbox starts
+	if len(modules) > 0 {
+		kataUtilsLogger.WithFields(logrus.Fields{
+			"container": containerID,
+			"modules":   modules,
+		}).Warn("guest kernel modules of the CDI devices not loaded, request the devices in the sandbox container")
+	}
+
 	contConfig, err := oci.ContainerConfig(ociSpec, bundlePath, containerID, console, disableOutput)
 	if err != nil {
 		return vc.Process{}, err
@@ -262,10 +327,7 @@ func CreateContainer(ctx context.Context, vci vc.VC, sandbox vc.VCSandbox, ociSp
 	}
 
 	// Run pre-start OCI hooks.
-	err = EnterNetNS(sandbox.GetNetNs(), func() error {
-		return PreStartHooks(ctx, ociSpec, containerID, bundlePath)
-	})
-	if err != nil {
+	if err := PreStartHooks(ctx, ociSpec, containerID, bundlePath, sandbox.Status()); err != nil {
 		return vc.Process{}, err
 	}
 
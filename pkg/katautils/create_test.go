@@ -376,6 +376,24 @@ func TestCheckForFips(t *testing.T) {
 	assert.Equal(config.HypervisorConfig, hconfig)
 }
 
+func TestAddCDIKernelModules(t *testing.T) {
+	assert := assert.New(t)
+
+	config := vc.SandboxConfig{
+		AgentConfig: vc.KataAgentConfig{
+			KernelModules: []string{"gpu_core"},
+		},
+	}
+
+	addCDIKernelModules(&config, []string{"gpu_core", "gpu_uvm debug=1"})
+	assert.Equal([]string{"gpu_core", "gpu_uvm debug=1"}, config.AgentConfig.(vc.KataAgentConfig).KernelModules)
+
+	// only the kata agent loads kernel modules
+	config.AgentConfig = nil
+	addCDIKernelModules(&config, []string{"gpu_core"})
+	assert.Nil(config.AgentConfig)
+}
+
 func TestCreateContainerContainerConfigFail(t *testing.T) {
 	assert := assert.New(t)
 
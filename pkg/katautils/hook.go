@@ -11,11 +11,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"strings"
 	"syscall"
 	"time"
 
+	vc "github.com/kata-containers/runtime/virtcontainers"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/opentracing/opentracing-go/log"
 	"github.com/sirupsen/logrus"
@@ -26,7 +28,19 @@ func hookLogger() *logrus.Entry {
 	return kataUtilsLogger.WithField("subsystem", "hook")
 }
 
-func runHook(ctx context.Context, hook specs.Hook, cid, bundlePath string) error {
+// hookEnv returns the variables added to the environment of the hooks for
+// them to find the VM of the sandbox, e.g. to monitor it. The hypervisor PID
+// is 0 when the VM is not running.
+func hookEnv(sandbox vc.SandboxStatus) []string {
+	return []string{
+		fmt.Sprintf("KATA_SANDBOX_ID=%s", sandbox.ID),
+		fmt.Sprintf("KATA_VM_PATH=%s", sandbox.VMPath),
+		fmt.Sprintf("KATA_HYPERVISOR_PID=%d", sandbox.HypervisorPid),
+		fmt.Sprintf("KATA_HYPERVISOR=%s", sandbox.Hypervisor),
+	}
+}
+
+func runHook(ctx context.Context, hook specs.Hook, cid, bundlePath string, env []string) error {
 	span, _ := Trace(ctx, "hook")
 	defer span.Finish()
 
@@ -47,11 +61,17 @@ func runHook(ctx context.Context, hook specs.Hook, cid, bundlePath string) error
 		return err
 	}
 
+	// like exec.Cmd, a hook without environment inherits the runtime's
+	hookEnv := hook.Env
+	if hookEnv == nil {
+		hookEnv = os.Environ()
+	}
+
 	var stdout, stderr bytes.Buffer
 	cmd := &exec.Cmd{
 		Path:   hook.Path,
 		Args:   hook.Args,
-		Env:    hook.Env,
+		Env:    append(append([]string{}, hookEnv...), env...),
 		Stdin:  bytes.NewReader(stateJSON),
 		Stdout: &stdout,
 		Stderr: &stderr,
@@ -89,52 +109,70 @@ func runHook(ctx context.Context, hook specs.Hook, cid, bundlePath string) error
 	return nil
 }
 
-func runHooks(ctx context.Context, hooks []specs.Hook, cid, bundlePath, hookType string) error {
+// runHooks runs the hooks in the network namespace of the sandbox.
+func runHooks(ctx context.Context, hooks []specs.Hook, cid, bundlePath, hookType string, sandbox vc.SandboxStatus) error {
+	if len(hooks) == 0 {
+		return nil
+	}
+
 	span, _ := Trace(ctx, "hooks")
 	defer span.Finish()
 
 	span.SetTag("subsystem", hookType)
 
-	for _, hook := range hooks {
-		if err := runHook(ctx, hook, cid, bundlePath); err != nil {
-			hookLogger().WithFields(logrus.Fields{
-				"hook-type": hookType,
-				"error":     err,
-			}).Error("hook error")
+	env := hookEnv(sandbox)
+
+	return EnterNetNS(sandbox.NetNsPath, func() error {
+		for _, hook := range hooks {
+			if err := runHook(ctx, hook, cid, bundlePath, env); err != nil {
+				hookLogger().WithFields(logrus.Fields{
+					"hook-type": hookType,
+					"error":     err,
+				}).Error("hook error")
 
-			return err
+				return err
+			}
 		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
-// PreStartHooks run the hooks before start container
-func PreStartHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string) error {
+// PreStartHooks run the hooks before start container. The hooks of the
+// sandbox container run before the VM is started.
+func PreStartHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string, sandbox vc.SandboxStatus) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec.Hooks.Prestart, cid, bundlePath, "pre-start")
+	return runHooks(ctx, spec.Hooks.Prestart, cid, bundlePath, "pre-start", sandbox)
 }
 
 // PostStartHooks run the hooks just after start container
-func PostStartHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string) error {
+func PostStartHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string, sandbox vc.SandboxStatus) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec.Hooks.Poststart, cid, bundlePath, "post-start")
+	return runHooks(ctx, spec.Hooks.Poststart, cid, bundlePath, "post-start", sandbox)
 }
 
-// PostStopHooks run the hooks after stop container
-func PostStopHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string) error {
+// PostStopHooks run the hooks after stop container. The network namespace
+// Kata Containers created for the sandbox is removed with it, the hooks of
+// the sandbox container then run in the current one.
+func PostStopHooks(ctx context.Context, spec specs.Spec, cid, bundlePath string, sandbox vc.SandboxStatus) error {
 	// If no hook available, nothing needs to be done.
 	if spec.Hooks == nil {
 		return nil
 	}
 
-	return runHooks(ctx, spec.Hooks.Poststop, cid, bundlePath, "post-stop")
+	if sandbox.NetNsPath != "" {
+		if _, err := os.Stat(sandbox.NetNsPath); os.IsNotExist(err) {
+			sandbox.NetNsPath = ""
+		}
+	}
+
+	return runHooks(ctx, spec.Hooks.Poststop, cid, bundlePath, "post-stop", sandbox)
 }
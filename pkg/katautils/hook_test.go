@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
 	. "github.com/kata-containers/runtime/virtcontainers/pkg/mock"
 	"github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
@@ -65,23 +66,23 @@ func TestRunHook(t *testing.T) {
 
 	// Run with timeout 0
 	hook := createHook(0)
-	err := runHook(ctx, hook, testSandboxID, testBundlePath)
+	err := runHook(ctx, hook, testSandboxID, testBundlePath, nil)
 	assert.NoError(err)
 
 	// Run with timeout 1
 	hook = createHook(1)
-	err = runHook(ctx, hook, testSandboxID, testBundlePath)
+	err = runHook(ctx, hook, testSandboxID, testBundlePath, nil)
 	assert.NoError(err)
 
 	// Run timeout failure
 	hook = createHook(1)
 	hook.Args = append(hook.Args, "2")
-	err = runHook(ctx, hook, testSandboxID, testBundlePath)
+	err = runHook(ctx, hook, testSandboxID, testBundlePath, nil)
 	assert.Error(err)
 
 	// Failure due to wrong hook
 	hook = createWrongHook()
-	err = runHook(ctx, hook, testSandboxID, testBundlePath)
+	err = runHook(ctx, hook, testSandboxID, testBundlePath, nil)
 	assert.Error(err)
 }
 
@@ -96,14 +97,14 @@ func TestPreStartHooks(t *testing.T) {
 
 	// Hooks field is nil
 	spec := specs.Spec{}
-	err := PreStartHooks(ctx, spec, "", "")
+	err := PreStartHooks(ctx, spec, "", "", vc.SandboxStatus{})
 	assert.NoError(err)
 
 	// Hooks list is empty
 	spec = specs.Spec{
 		Hooks: &specs.Hooks{},
 	}
-	err = PreStartHooks(ctx, spec, "", "")
+	err = PreStartHooks(ctx, spec, "", "", vc.SandboxStatus{})
 	assert.NoError(err)
 
 	// Run with timeout 0
@@ -113,7 +114,7 @@ func TestPreStartHooks(t *testing.T) {
 			Prestart: []specs.Hook{hook},
 		},
 	}
-	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath, vc.SandboxStatus{ID: testSandboxID})
 	assert.NoError(err)
 
 	// Failure due to wrong hook
@@ -123,7 +124,7 @@ func TestPreStartHooks(t *testing.T) {
 			Prestart: []specs.Hook{hook},
 		},
 	}
-	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PreStartHooks(ctx, spec, testSandboxID, testBundlePath, vc.SandboxStatus{ID: testSandboxID})
 	assert.Error(err)
 }
 
@@ -138,14 +139,14 @@ func TestPostStartHooks(t *testing.T) {
 
 	// Hooks field is nil
 	spec := specs.Spec{}
-	err := PostStartHooks(ctx, spec, "", "")
+	err := PostStartHooks(ctx, spec, "", "", vc.SandboxStatus{})
 	assert.NoError(err)
 
 	// Hooks list is empty
 	spec = specs.Spec{
 		Hooks: &specs.Hooks{},
 	}
-	err = PostStartHooks(ctx, spec, "", "")
+	err = PostStartHooks(ctx, spec, "", "", vc.SandboxStatus{})
 	assert.NoError(err)
 
 	// Run with timeout 0
@@ -155,7 +156,7 @@ func TestPostStartHooks(t *testing.T) {
 			Poststart: []specs.Hook{hook},
 		},
 	}
-	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath, vc.SandboxStatus{ID: testSandboxID})
 	assert.NoError(err)
 
 	// Failure due to wrong hook
@@ -165,7 +166,7 @@ func TestPostStartHooks(t *testing.T) {
 			Poststart: []specs.Hook{hook},
 		},
 	}
-	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStartHooks(ctx, spec, testSandboxID, testBundlePath, vc.SandboxStatus{ID: testSandboxID})
 	assert.Error(err)
 }
 
@@ -180,14 +181,14 @@ func TestPostStopHooks(t *testing.T) {
 
 	// Hooks field is nil
 	spec := specs.Spec{}
-	err := PostStopHooks(ctx, spec, "", "")
+	err := PostStopHooks(ctx, spec, "", "", vc.SandboxStatus{})
 	assert.NoError(err)
 
 	// Hooks list is empty
 	spec = specs.Spec{
 		Hooks: &specs.Hooks{},
 	}
-	err = PostStopHooks(ctx, spec, "", "")
+	err = PostStopHooks(ctx, spec, "", "", vc.SandboxStatus{})
 	assert.NoError(err)
 
 	// Run with timeout 0
@@ -197,7 +198,14 @@ func TestPostStopHooks(t *testing.T) {
 			Poststop: []specs.Hook{hook},
 		},
 	}
-	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath, vc.SandboxStatus{ID: testSandboxID})
+	assert.NoError(err)
+
+	// The network namespace was removed with the sandbox
+	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath, vc.SandboxStatus{
+		ID:        testSandboxID,
+		NetNsPath: "/does/not/exist",
+	})
 	assert.NoError(err)
 
 	// Failure due to wrong hook
@@ -207,6 +215,44 @@ func TestPostStopHooks(t *testing.T) {
 			Poststop: []specs.Hook{hook},
 		},
 	}
-	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath)
+	err = PostStopHooks(ctx, spec, testSandboxID, testBundlePath, vc.SandboxStatus{ID: testSandboxID})
 	assert.Error(err)
 }
+
+func TestRunHookEnv(t *testing.T) {
+	assert := assert.New(t)
+
+	env := hookEnv(vc.SandboxStatus{
+		ID:            testSandboxID,
+		Hypervisor:    vc.QemuHypervisor,
+		HypervisorPid: 42,
+		VMPath:        "/run/vc/vm/" + testSandboxID,
+	})
+	assert.Equal([]string{
+		"KATA_SANDBOX_ID=" + testSandboxID,
+		"KATA_VM_PATH=/run/vc/vm/" + testSandboxID,
+		"KATA_HYPERVISOR_PID=42",
+		"KATA_HYPERVISOR=qemu",
+	}, env)
+
+	// The variables are added to the environment of the hook
+	hook := specs.Hook{
+		Path: "/bin/sh",
+		Args: []string{"sh", "-c", `test "$FOO" = bar && test "$KATA_HYPERVISOR_PID" = 42`},
+		Env:  []string{"FOO=bar"},
+	}
+	err := runHook(context.Background(), hook, testContainerIDHook, testBundlePath, env)
+	assert.NoError(err)
+	assert.Equal([]string{"FOO=bar"}, hook.Env)
+
+	// A hook without environment inherits the runtime's
+	os.Setenv("KATA_TEST_HOOK_ENV", "foo")
+	defer os.Unsetenv("KATA_TEST_HOOK_ENV")
+
+	hook = specs.Hook{
+		Path: "/bin/sh",
+		Args: []string{"sh", "-c", `test "$KATA_TEST_HOOK_ENV" = foo && test "$KATA_HYPERVISOR_PID" = 42`},
+	}
+	err = runHook(context.Background(), hook, testContainerIDHook, testBundlePath, env)
+	assert.NoError(err)
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package katautils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// journalSocket is the socket of the native protocol of systemd-journald.
+var journalSocket = "/run/systemd/journal/socket"
+
+// journalPriorities maps the logrus levels to the syslog priorities, as the
+// syslog hook does.
+var journalPriorities = map[logrus.Level]int{
+	logrus.PanicLevel: 2,
+	logrus.FatalLevel: 2,
+	logrus.ErrorLevel: 3,
+	logrus.WarnLevel:  4,
+	logrus.InfoLevel:  6,
+	logrus.DebugLevel: 7,
+	logrus.TraceLevel: 7,
+}
+
+// journalHook sends the log entries to the journal with their fields as
+// journal fields, e.g. the "sandbox" field of an entry is its SANDBOX
+// journal field, so that they can be matched with journalctl.
+type journalHook struct {
+	conn *net.UnixConn
+	addr *net.UnixAddr
+}
+
+func newJournalHook() (*journalHook, error) {
+	if _, err := os.Stat(journalSocket); err != nil {
+		return nil, fmt.Errorf("journal is not available: %v", err)
+	}
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &journalHook{
+		conn: conn,
+		addr: &net.UnixAddr{Name: journalSocket, Net: "unixgram"},
+	}, nil
+}
+
+func (h *journalHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire sends the entry to the journal.
+func (h *journalHook) Fire(e *logrus.Entry) error {
+	_, _, err := h.conn.WriteMsgUnix(journalMessage(e), nil, h.addr)
+	return err
+}
+
+// journalFieldName returns the journal field name of a logrus field, only
+// made of upper case letters, digits and underscores and not starting with
+// an underscore, reserved to the journal.
+func journalFieldName(field string) string {
+	field = strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, field)
+
+	field = strings.TrimLeft(field, "_")
+	if field != "" && field[0] >= '0' && field[0] <= '9' {
+		field = "F" + field
+	}
+
+	return field
+}
+
+// journalMessage encodes the entry in the journal native protocol.
+func journalMessage(e *logrus.Entry) []byte {
+	var b bytes.Buffer
+
+	write := func(name, value string) {
+		// the values with a new line are sent with their size
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&b, "%s=%s\n", name, value)
+			return
+		}
+
+		b.WriteString(name)
+		b.WriteByte('\n')
+		binary.Write(&b, binary.LittleEndian, uint64(len(value)))
+		b.WriteString(value)
+		b.WriteByte('\n')
+	}
+
+	write("MESSAGE", e.Message)
+	write("PRIORITY", fmt.Sprintf("%d", journalPriorities[e.Level]))
+	write("SYSLOG_IDENTIFIER", name)
+
+	for k, v := range e.Data {
+		field := journalFieldName(k)
+		switch field {
+		case "", "MESSAGE", "PRIORITY", "SYSLOG_IDENTIFIER":
+			continue
+		}
+
+		write(field, fmt.Sprint(v))
+	}
+
+	return b.Bytes()
+}
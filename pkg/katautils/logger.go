@@ -8,13 +8,20 @@ package katautils
 
 import (
 	"context"
+	"fmt"
 	"log/syslog"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 	lSyslog "github.com/sirupsen/logrus/hooks/syslog"
 )
 
+const (
+	systemLogSyslog  = "syslog"
+	systemLogJournal = "journal"
+)
+
 // Default our log level to 'Warn', rather than the logrus default
 // of 'Info', which is rather noisy.
 var originalLoggerLevel = logrus.WarnLevel
@@ -74,13 +81,114 @@ func newSystemLogHook(network, raddr string) (*sysLogHook, error) {
 	}, nil
 }
 
-// handleSystemLog sets up the system-level logger.
-func handleSystemLog(network, raddr string) error {
-	hook, err := newSystemLogHook(network, raddr)
+// rateLimitHook wraps a system log hook, limiting the number of entries per
+// second of the noisy subsystems, e.g. the firecracker log fifo. The number
+// of entries dropped is given by the next entry of the subsystem sent.
+type rateLimitHook struct {
+	sync.Mutex
+	hook    logrus.Hook
+	limits  map[string]uint32
+	windows map[string]*rateLimitWindow
+}
+
+// rateLimitWindow counts the entries of a subsystem in the current second.
+type rateLimitWindow struct {
+	start      time.Time
+	count      uint32
+	suppressed uint64
+}
+
+func newRateLimitHook(hook logrus.Hook, limits map[string]uint32) *rateLimitHook {
+	return &rateLimitHook{
+		hook:    hook,
+		limits:  limits,
+		windows: make(map[string]*rateLimitWindow),
+	}
+}
+
+func (h *rateLimitHook) Levels() []logrus.Level {
+	return h.hook.Levels()
+}
+
+// allow returns if an entry of the subsystem can be sent at time now, and
+// how many entries were dropped since the previous one sent.
+func (h *rateLimitHook) allow(subsystem string, now time.Time) (bool, uint64) {
+	limit, ok := h.limits[subsystem]
+	if !ok || limit == 0 {
+		return true, 0
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	w := h.windows[subsystem]
+	if w == nil {
+		w = &rateLimitWindow{start: now}
+		h.windows[subsystem] = w
+	}
+
+	if now.Sub(w.start) >= time.Second {
+		w.start = now
+		w.count = 0
+	}
+
+	if w.count >= limit {
+		w.suppressed++
+		return false, 0
+	}
+
+	w.count++
+	suppressed := w.suppressed
+	w.suppressed = 0
+
+	return true, suppressed
+}
+
+// Fire sends the entry to the system log unless its subsystem exceeded its
+// rate.
+func (h *rateLimitHook) Fire(e *logrus.Entry) error {
+	subsystem, _ := e.Data["subsystem"].(string)
+
+	allowed, suppressed := h.allow(subsystem, time.Now())
+	if !allowed {
+		return nil
+	}
+
+	if suppressed > 0 {
+		// the entry is shared with the other outputs of the logger
+		entry := e.WithField("suppressed", suppressed)
+		entry.Time = e.Time
+		entry.Level = e.Level
+		entry.Message = e.Message
+		e = entry
+	}
+
+	return h.hook.Fire(e)
+}
+
+// handleSystemLog sets up the system-level logger, sending the entries
+// either to the syslog at network and raddr or to the journal. The entries
+// of the subsystems with a rate limit are dropped above that many per second.
+func handleSystemLog(sink, network, raddr string, rateLimits map[string]uint32) error {
+	var hook logrus.Hook
+	var err error
+
+	switch sink {
+	case "", systemLogSyslog:
+		hook, err = newSystemLogHook(network, raddr)
+	case systemLogJournal:
+		hook, err = newJournalHook()
+	default:
+		err = fmt.Errorf("Invalid system log %q, expected %q or %q", sink, systemLogSyslog, systemLogJournal)
+	}
 	if err != nil {
 		return err
 	}
 
+	if len(rateLimits) > 0 {
+		hook = newRateLimitHook(hook, rateLimits)
+	}
+
 	kataUtilsLogger.Logger.Hooks.Add(hook)
 
 	return nil
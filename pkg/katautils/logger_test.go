@@ -46,13 +46,91 @@ func TestHandleSystemLog(t *testing.T) {
 	}
 
 	for _, d := range data {
-		err := handleSystemLog(d.network, d.raddr)
+		err := handleSystemLog("", d.network, d.raddr, nil)
 		if d.expectError {
 			assert.Error(err, fmt.Sprintf("%+v", d))
 		} else {
 			assert.NoError(err, fmt.Sprintf("%+v", d))
 		}
 	}
+
+	assert.Error(handleSystemLog("console", "", "", nil))
+}
+
+type testHook struct {
+	entries []*logrus.Entry
+}
+
+func (h *testHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (h *testHook) Fire(e *logrus.Entry) error {
+	h.entries = append(h.entries, e)
+	return nil
+}
+
+func TestRateLimitHook(t *testing.T) {
+	assert := assert.New(t)
+
+	hook := &testHook{}
+	rlHook := newRateLimitHook(hook, map[string]uint32{"firecracker": 2})
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	logger.Hooks.Add(rlHook)
+
+	for i := 0; i < 5; i++ {
+		logger.WithField("subsystem", "firecracker").Error("firecracker failed")
+		logger.WithField("subsystem", "sandbox").Info("sandbox")
+	}
+	assert.Len(hook.entries, 7)
+
+	now := time.Now()
+	allowed, _ := rlHook.allow("firecracker", now)
+	assert.False(allowed)
+
+	// the next window tells how many entries were dropped
+	allowed, suppressed := rlHook.allow("firecracker", now.Add(time.Second))
+	assert.True(allowed)
+	assert.Equal(uint64(4), suppressed)
+
+	allowed, suppressed = rlHook.allow("firecracker", now.Add(time.Second))
+	assert.True(allowed)
+	assert.Equal(uint64(0), suppressed)
+}
+
+func TestJournalFieldName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("SANDBOX", journalFieldName("sandbox"))
+	assert.Equal("VM_CONSOLE", journalFieldName("vm-console"))
+	assert.Equal("ID", journalFieldName("_id"))
+	assert.Equal("F9P", journalFieldName("9p"))
+	assert.Equal("", journalFieldName("__"))
+}
+
+func TestJournalMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	e := &logrus.Entry{
+		Level:   logrus.WarnLevel,
+		Message: "sandbox stopped unexpectedly",
+		Data: logrus.Fields{
+			"sandbox":  "foo",
+			"priority": "high",
+			"stack":    "a\nb",
+		},
+	}
+
+	msg := string(journalMessage(e))
+	assert.Contains(msg, "MESSAGE=sandbox stopped unexpectedly\n")
+	assert.Contains(msg, "PRIORITY=4\n")
+	assert.Contains(msg, "SYSLOG_IDENTIFIER="+name+"\n")
+	assert.Contains(msg, "SANDBOX=foo\n")
+	assert.NotContains(msg, "PRIORITY=high")
+	// multi-line values are prefixed with their size
+	assert.Contains(msg, "STACK\n\x03\x00\x00\x00\x00\x00\x00\x00a\nb\n")
 }
 
 func TestNewSystemLogHook(t *testing.T) {
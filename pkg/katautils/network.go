@@ -8,6 +8,7 @@ package katautils
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -17,7 +18,9 @@ import (
 	"github.com/containernetworking/plugins/pkg/ns"
 	"github.com/containernetworking/plugins/pkg/testutils"
 	vc "github.com/kata-containers/runtime/virtcontainers"
+	vcCNI "github.com/kata-containers/runtime/virtcontainers/pkg/cni"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/sys/unix"
 )
 
@@ -65,15 +68,33 @@ func SetupNetworkNamespace(config *vc.NetworkConfig) error {
 
 	if config.NetNSPath == "" {
 		if rootless.IsRootless() {
+			if config.CNIConfig.Enable {
+				return fmt.Errorf("CNI plugins can't be invoked by a rootless runtime")
+			}
+
 			n, err = rootless.NewNS()
 			if err != nil {
 				return err
 			}
+
+			// There is no way to create a tap connected to the host
+			// network without privileges, slirp4netns provides one.
+			if err = rootless.StartSlirp4netns(n.Path()); err != nil {
+				cleanupNetNS(n.Path())
+				return err
+			}
 		} else {
 			n, err = testutils.NewNS()
 			if err != nil {
 				return err
 			}
+
+			if config.CNIConfig.Enable {
+				if err = addCNINetwork(n.Path(), config.CNIConfig); err != nil {
+					cleanupNetNS(n.Path())
+					return err
+				}
+			}
 		}
 
 		config.NetNSPath = n.Path()
@@ -193,8 +214,29 @@ func hostNetworkingRequested(configNetNs string) (bool, error) {
 	return false, nil
 }
 
+// addCNINetwork invokes the CNI plugins to set up the network namespace
+// created for the sandbox, before the VM is connected to its interfaces.
+func addCNINetwork(netNSPath string, config vc.CNIConfig) error {
+	network, err := vcCNI.LoadNetwork(config.ConfDir)
+	if err != nil {
+		return err
+	}
+
+	kataUtilsLogger.WithFields(logrus.Fields{
+		"netns":   netNSPath,
+		"network": network.Name,
+	}).Info("Setting up CNI network")
+
+	_, err = network.Add(context.Background(), config.BinDirs, netNSPath)
+	return err
+}
+
 // cleanupNetNS cleanup netns created by kata, trigger only create sandbox fails
 func cleanupNetNS(netNSPath string) error {
+	if err := rootless.StopSlirp4netns(netNSPath); err != nil {
+		return fmt.Errorf("failed to stop slirp4netns for namespace %s: %v", netNSPath, err)
+	}
+
 	n, err := ns.GetNS(netNSPath)
 	if err != nil {
 		return fmt.Errorf("failed to get netns %s: %v", netNSPath, err)
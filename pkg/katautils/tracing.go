@@ -10,6 +10,7 @@ import (
 	"io"
 
 	opentracing "github.com/opentracing/opentracing-go"
+	jaeger "github.com/uber/jaeger-client-go"
 	"github.com/uber/jaeger-client-go/config"
 )
 
@@ -41,7 +42,7 @@ func CreateTracer(name string) (opentracing.Tracer, error) {
 		// it pollutes the output stream which causes (atleast) the
 		// "state" command to fail under Docker.
 		Sampler: &config.SamplerConfig{
-			Type:  "const",
+			Type:  jaeger.SamplerTypeConst,
 			Param: 1,
 		},
 
@@ -49,11 +50,23 @@ func CreateTracer(name string) (opentracing.Tracer, error) {
 		// This is essential as it is used by:
 		//
 		// https: //github.com/kata-containers/tests/blob/master/tracing/tracing-test.sh
+		//
+		// The spans are sent to the local Jaeger agent unless another
+		// agent or a collector is configured. The collector endpoint
+		// accepts the Jaeger thrift protocol over HTTP, as the jaeger
+		// receiver of an OpenTelemetry collector does.
 		Reporter: &config.ReporterConfig{
-			LogSpans: tracing,
+			LogSpans:           tracing,
+			LocalAgentHostPort: traceAgentEndpoint,
+			CollectorEndpoint:  traceCollectorEndpoint,
 		},
 	}
 
+	if traceSampleRate < 1 {
+		cfg.Sampler.Type = jaeger.SamplerTypeProbabilistic
+		cfg.Sampler.Param = traceSampleRate
+	}
+
 	logger := traceLogger{}
 
 	tracer, closer, err := cfg.NewTracer(config.Logger(logger))
@@ -0,0 +1,72 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package sdk_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/kata-containers/runtime/pkg/sdk"
+)
+
+// This example boots a firecracker sandbox, runs a command in a container
+// and tears the sandbox down.
+func Example_runContainer() {
+	ctx := context.Background()
+
+	s, err := sdk.NewSandbox(ctx, "function-42",
+		sdk.WithConfigFile("/usr/share/defaults/kata-containers/configuration-fc.toml"),
+		sdk.WithVCPUs(1),
+		sdk.WithMemory(256),
+		sdk.WithSandboxContainer(
+			sdk.WithRootfs("/var/lib/functions/pause/rootfs"),
+			sdk.WithArgs("/pause"),
+		),
+	)
+	if err != nil {
+		fmt.Printf("Could not create sandbox: %s", err)
+		return
+	}
+	defer s.Delete(ctx)
+	defer s.Stop(ctx, true)
+
+	if err := s.Start(ctx); err != nil {
+		fmt.Printf("Could not start sandbox: %s", err)
+		return
+	}
+
+	c, err := s.CreateContainer(ctx, "handler",
+		sdk.WithRootfs("/var/lib/functions/handler/rootfs"),
+		sdk.WithArgs("/handler", "--event", "hello"),
+		sdk.WithEnv("LOG_LEVEL=debug"),
+	)
+	if err != nil {
+		fmt.Printf("Could not create container: %s", err)
+		return
+	}
+
+	if err := c.Start(ctx); err != nil {
+		fmt.Printf("Could not start container: %s", err)
+		return
+	}
+
+	_, stdout, _, err := c.IO(ctx)
+	if err != nil {
+		fmt.Printf("Could not get container output: %s", err)
+		return
+	}
+	go io.Copy(os.Stdout, stdout)
+
+	code, err := c.Wait(ctx)
+	if err != nil {
+		fmt.Printf("Could not wait for container: %s", err)
+		return
+	}
+
+	fmt.Printf("handler exited with %d", code)
+}
@@ -0,0 +1,422 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package sdk
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	criContainerdAnnotations "github.com/containerd/cri-containerd/pkg/annotations"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// defaultPath is the PATH of the processes not setting it.
+const defaultPath = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// defaultCapabilities are the capabilities of the container processes, as
+// for the runc default spec.
+var defaultCapabilities = []string{
+	"CAP_AUDIT_WRITE",
+	"CAP_KILL",
+	"CAP_NET_BIND_SERVICE",
+}
+
+// Option is an option of NewSandbox.
+type Option func(*sandboxOptions) error
+
+// ContainerOption is an option of a container or of a process.
+type ContainerOption func(*containerOptions) error
+
+type sandboxOptions struct {
+	configPath  string
+	configEdits []func(*oci.RuntimeConfig) error
+	netNSPath   string
+	annotations map[string]string
+	container   containerOptions
+}
+
+type containerOptions struct {
+	rootfs         string
+	readonlyRootfs bool
+	args           []string
+	env            []string
+	workDir        string
+	uid            uint32
+	gid            uint32
+	terminal       bool
+	mounts         []specs.Mount
+}
+
+// editConfig returns the option changing the configuration of the sandbox.
+func editConfig(edit func(*oci.RuntimeConfig) error) Option {
+	return func(o *sandboxOptions) error {
+		o.configEdits = append(o.configEdits, edit)
+		return nil
+	}
+}
+
+// WithConfigFile sets the Kata Containers configuration file of the
+// sandbox, the default one being used otherwise. Its hypervisor is the
+// hypervisor of the sandbox, e.g. firecracker for configuration-fc.toml.
+func WithConfigFile(path string) Option {
+	return func(o *sandboxOptions) error {
+		o.configPath = path
+		return nil
+	}
+}
+
+// WithHypervisorPath sets the path of the hypervisor binary.
+func WithHypervisorPath(path string) Option {
+	return editConfig(func(c *oci.RuntimeConfig) error {
+		c.HypervisorConfig.HypervisorPath = path
+		return nil
+	})
+}
+
+// WithJailerPath sets the path of the jailer binary of firecracker.
+func WithJailerPath(path string) Option {
+	return editConfig(func(c *oci.RuntimeConfig) error {
+		if c.HypervisorType != vc.FirecrackerHypervisor {
+			return fmt.Errorf("Jailer only supported by firecracker, not %s", c.HypervisorType.String())
+		}
+
+		c.HypervisorConfig.JailerPath = path
+		return nil
+	})
+}
+
+// WithKernel sets the guest kernel and adds the parameters to its command
+// line, e.g. "console=hvc0".
+func WithKernel(path string, params ...string) Option {
+	return editConfig(func(c *oci.RuntimeConfig) error {
+		c.HypervisorConfig.KernelPath = path
+		c.HypervisorConfig.KernelParams = append(c.HypervisorConfig.KernelParams, vc.DeserializeParams(params)...)
+		return nil
+	})
+}
+
+// WithImage sets the guest image, rather than an initrd.
+func WithImage(path string) Option {
+	return editConfig(func(c *oci.RuntimeConfig) error {
+		c.HypervisorConfig.ImagePath = path
+		c.HypervisorConfig.InitrdPath = ""
+		return nil
+	})
+}
+
+// WithInitrd sets the guest initrd, rather than an image.
+func WithInitrd(path string) Option {
+	return editConfig(func(c *oci.RuntimeConfig) error {
+		c.HypervisorConfig.InitrdPath = path
+		c.HypervisorConfig.ImagePath = ""
+		return nil
+	})
+}
+
+// WithVCPUs sets the number of vCPUs of the VM.
+func WithVCPUs(vcpus uint32) Option {
+	return editConfig(func(c *oci.RuntimeConfig) error {
+		if vcpus == 0 {
+			return fmt.Errorf("Invalid number of vCPUs 0")
+		}
+
+		c.HypervisorConfig.NumVCPUs = vcpus
+		return nil
+	})
+}
+
+// WithMemory sets the memory of the VM in MiB.
+func WithMemory(memory uint32) Option {
+	return editConfig(func(c *oci.RuntimeConfig) error {
+		if memory == 0 {
+			return fmt.Errorf("Invalid memory size 0")
+		}
+
+		c.HypervisorConfig.MemorySize = memory
+		return nil
+	})
+}
+
+// WithNetNS sets the network namespace of the sandbox, whose interfaces are
+// connected to the VM. A network namespace is created for the sandbox
+// otherwise.
+func WithNetNS(path string) Option {
+	return func(o *sandboxOptions) error {
+		o.netNSPath = path
+		return nil
+	}
+}
+
+// WithAnnotations sets annotations of the sandbox, e.g. the
+// io.katacontainers.config annotations enabled by the configuration.
+func WithAnnotations(annotations map[string]string) Option {
+	return func(o *sandboxOptions) error {
+		if o.annotations == nil {
+			o.annotations = make(map[string]string)
+		}
+
+		for k, v := range annotations {
+			o.annotations[k] = v
+		}
+		return nil
+	}
+}
+
+// WithSandboxContainer sets the options of the sandbox container.
+func WithSandboxContainer(opts ...ContainerOption) Option {
+	return func(o *sandboxOptions) error {
+		for _, opt := range opts {
+			if err := opt(&o.container); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// WithRootfs sets the host directory of the rootfs of the container.
+func WithRootfs(path string) ContainerOption {
+	return func(o *containerOptions) error {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("Rootfs %s is not an absolute path", path)
+		}
+
+		o.rootfs = path
+		return nil
+	}
+}
+
+// WithReadonlyRootfs makes the rootfs of the container read-only.
+func WithReadonlyRootfs() ContainerOption {
+	return func(o *containerOptions) error {
+		o.readonlyRootfs = true
+		return nil
+	}
+}
+
+// WithArgs sets the command and arguments of the process.
+func WithArgs(args ...string) ContainerOption {
+	return func(o *containerOptions) error {
+		o.args = args
+		return nil
+	}
+}
+
+// WithEnv adds "NAME=value" environment variables to the process.
+func WithEnv(env ...string) ContainerOption {
+	return func(o *containerOptions) error {
+		for _, e := range env {
+			if !strings.Contains(e, "=") {
+				return fmt.Errorf("Invalid environment variable %q, expecting NAME=value", e)
+			}
+		}
+
+		o.env = append(o.env, env...)
+		return nil
+	}
+}
+
+// WithWorkDir sets the working directory of the process, "/" by default.
+func WithWorkDir(dir string) ContainerOption {
+	return func(o *containerOptions) error {
+		o.workDir = dir
+		return nil
+	}
+}
+
+// WithUser sets the user and group IDs of the process, root by default.
+func WithUser(uid, gid uint32) ContainerOption {
+	return func(o *containerOptions) error {
+		o.uid = uid
+		o.gid = gid
+		return nil
+	}
+}
+
+// WithTerminal gives a terminal to the process.
+func WithTerminal() ContainerOption {
+	return func(o *containerOptions) error {
+		o.terminal = true
+		return nil
+	}
+}
+
+// WithMount bind mounts the host path source on the destination of the
+// container, with the mount options, e.g. "ro".
+func WithMount(source, destination string, options ...string) ContainerOption {
+	return func(o *containerOptions) error {
+		if !filepath.IsAbs(source) || !filepath.IsAbs(destination) {
+			return fmt.Errorf("Invalid mount of %s on %s, expecting absolute paths", source, destination)
+		}
+
+		o.mounts = append(o.mounts, specs.Mount{
+			Source:      source,
+			Destination: destination,
+			Type:        "bind",
+			Options:     append([]string{"rbind"}, options...),
+		})
+		return nil
+	}
+}
+
+// process returns the OCI process of the options.
+func (o *containerOptions) process() (*specs.Process, error) {
+	if len(o.args) == 0 {
+		return nil, fmt.Errorf("Missing command")
+	}
+
+	env := o.env
+	hasPath := false
+	for _, e := range env {
+		if strings.HasPrefix(e, "PATH=") {
+			hasPath = true
+			break
+		}
+	}
+
+	if !hasPath {
+		env = append([]string{defaultPath}, env...)
+	}
+
+	workDir := o.workDir
+	if workDir == "" {
+		workDir = "/"
+	}
+
+	return &specs.Process{
+		Terminal: o.terminal,
+		User: specs.User{
+			UID: o.uid,
+			GID: o.gid,
+		},
+		Args: o.args,
+		Env:  env,
+		Cwd:  workDir,
+		Capabilities: &specs.LinuxCapabilities{
+			Bounding:    defaultCapabilities,
+			Effective:   defaultCapabilities,
+			Inheritable: defaultCapabilities,
+			Permitted:   defaultCapabilities,
+			Ambient:     defaultCapabilities,
+		},
+		NoNewPrivileges: true,
+	}, nil
+}
+
+// cmd returns the command of a process run in a container.
+func (o *containerOptions) cmd() (types.Cmd, error) {
+	p, err := o.process()
+	if err != nil {
+		return types.Cmd{}, err
+	}
+
+	var envs []types.EnvVar
+	for _, e := range p.Env {
+		kv := strings.SplitN(e, "=", 2)
+		envs = append(envs, types.EnvVar{Var: kv[0], Value: kv[1]})
+	}
+
+	return types.Cmd{
+		Args:            p.Args,
+		Envs:            envs,
+		User:            strconv.FormatUint(uint64(p.User.UID), 10),
+		PrimaryGroup:    strconv.FormatUint(uint64(p.User.GID), 10),
+		WorkDir:         p.Cwd,
+		Interactive:     p.Terminal,
+		Detach:          !p.Terminal,
+		NoNewPrivileges: p.NoNewPrivileges,
+	}, nil
+}
+
+// spec returns the OCI spec of the container id, of the sandbox sandboxID,
+// the sandbox container having no sandbox ID.
+func (o *containerOptions) spec(id, sandboxID string) (specs.Spec, error) {
+	if o.rootfs == "" {
+		return specs.Spec{}, fmt.Errorf("Missing rootfs")
+	}
+
+	p, err := o.process()
+	if err != nil {
+		return specs.Spec{}, err
+	}
+
+	spec := specs.Spec{
+		Version: specs.Version,
+		Process: p,
+		Root: &specs.Root{
+			Path:     o.rootfs,
+			Readonly: o.readonlyRootfs,
+		},
+		Hostname:    id,
+		Mounts:      append(defaultMounts(), o.mounts...),
+		Annotations: make(map[string]string),
+		Linux: &specs.Linux{
+			Resources:   &specs.LinuxResources{},
+			CgroupsPath: filepath.Join(sandboxID, id),
+			Namespaces: []specs.LinuxNamespace{
+				{Type: specs.PIDNamespace},
+				{Type: specs.IPCNamespace},
+				{Type: specs.UTSNamespace},
+				{Type: specs.MountNamespace},
+			},
+		},
+	}
+
+	// the containers of a sandbox are identified as by the CRI
+	if sandboxID != "" {
+		spec.Annotations[criContainerdAnnotations.ContainerType] = criContainerdAnnotations.ContainerTypeContainer
+		spec.Annotations[criContainerdAnnotations.SandboxID] = sandboxID
+	}
+
+	return spec, nil
+}
+
+// defaultMounts returns the mounts of the containers, as for the runc
+// default spec.
+func defaultMounts() []specs.Mount {
+	return []specs.Mount{
+		{
+			Destination: "/proc",
+			Type:        "proc",
+			Source:      "proc",
+		},
+		{
+			Destination: "/dev",
+			Type:        "tmpfs",
+			Source:      "tmpfs",
+			Options:     []string{"nosuid", "strictatime", "mode=755", "size=65536k"},
+		},
+		{
+			Destination: "/dev/pts",
+			Type:        "devpts",
+			Source:      "devpts",
+			Options:     []string{"nosuid", "noexec", "newinstance", "ptmxmode=0666", "mode=0620", "gid=5"},
+		},
+		{
+			Destination: "/dev/shm",
+			Type:        "tmpfs",
+			Source:      "shm",
+			Options:     []string{"nosuid", "noexec", "nodev", "mode=1777", "size=65536k"},
+		},
+		{
+			Destination: "/dev/mqueue",
+			Type:        "mqueue",
+			Source:      "mqueue",
+			Options:     []string{"nosuid", "noexec", "nodev"},
+		},
+		{
+			Destination: "/sys",
+			Type:        "sysfs",
+			Source:      "sysfs",
+			Options:     []string{"nosuid", "noexec", "nodev", "ro"},
+		},
+	}
+}
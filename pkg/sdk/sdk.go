@@ -0,0 +1,454 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package sdk is the API to create and manage Kata Containers sandboxes
+// from a Go program, without a container manager: a serverless platform can
+// boot a sandbox, run its workloads in containers and tear it down with a
+// few calls.
+//
+// The sandboxes are configured by the Kata Containers configuration file,
+// the options of NewSandbox overriding its settings. The containers are
+// described by their rootfs and process options, the SDK generating their
+// OCI spec.
+//
+// All the methods take a context. A sandbox is driven from the process
+// which created it, the operations are synchronous and the context is
+// checked before each of them; the waits return as soon as the context is
+// done.
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"syscall"
+
+	"github.com/kata-containers/runtime/pkg/katautils"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+)
+
+// vci is the virtcontainers implementation, replaced by a mock in tests.
+var vci vc.VC = &vc.VCImpl{}
+
+// loadConfiguration loads the Kata Containers configuration file, the
+// default one when the path is empty.
+var loadConfiguration = func(path string) (oci.RuntimeConfig, error) {
+	_, config, err := katautils.LoadConfiguration(path, true, true)
+	return config, err
+}
+
+// State is the state of a sandbox or of a container.
+type State string
+
+const (
+	// StateReady is the state of a sandbox or container created but not
+	// started yet.
+	StateReady State = State(types.StateReady)
+
+	// StateRunning is the state of a running sandbox or container.
+	StateRunning State = State(types.StateRunning)
+
+	// StatePaused is the state of a paused sandbox or container.
+	StatePaused State = State(types.StatePaused)
+
+	// StateStopped is the state of a stopped sandbox or container.
+	StateStopped State = State(types.StateStopped)
+)
+
+// Sandbox is a VM running containers.
+type Sandbox interface {
+	// ID returns the ID of the sandbox.
+	ID() string
+
+	// State returns the state of the sandbox.
+	State(ctx context.Context) (State, error)
+
+	// Start starts the containers created with the sandbox.
+	Start(ctx context.Context) error
+
+	// Stop stops the containers and the VM of the sandbox, the
+	// containers being killed when force is true.
+	Stop(ctx context.Context, force bool) error
+
+	// Delete deletes the stopped sandbox and its containers.
+	Delete(ctx context.Context) error
+
+	// CreateContainer creates a container in the running sandbox.
+	CreateContainer(ctx context.Context, id string, opts ...ContainerOption) (Container, error)
+
+	// Container returns the container id of the sandbox.
+	Container(id string) (Container, error)
+
+	// Containers returns the containers of the sandbox.
+	Containers() []Container
+}
+
+// Process is a process running in a container.
+type Process interface {
+	// ID returns the ID of the process in its container.
+	ID() string
+
+	// Wait waits for the process to exit and returns its exit code.
+	Wait(ctx context.Context) (int32, error)
+
+	// Signal sends the signal to the process.
+	Signal(ctx context.Context, signal syscall.Signal) error
+
+	// IO returns the standard input, output and error of the process, the
+	// error being empty when the process has a terminal.
+	IO(ctx context.Context) (io.WriteCloser, io.Reader, io.Reader, error)
+
+	// Resize resizes the terminal of the process.
+	Resize(ctx context.Context, height, width uint32) error
+}
+
+// Container is a container of a sandbox, the methods of Process applying
+// to its init process.
+type Container interface {
+	Process
+
+	// State returns the state of the container.
+	State(ctx context.Context) (State, error)
+
+	// Start starts the container created in a running sandbox.
+	Start(ctx context.Context) error
+
+	// Stop stops the container, killing its processes.
+	Stop(ctx context.Context) error
+
+	// Delete deletes the stopped container.
+	Delete(ctx context.Context) error
+
+	// Exec runs a process in the running container, the rootfs and
+	// mount options being ignored.
+	Exec(ctx context.Context, opts ...ContainerOption) (Process, error)
+}
+
+type sandbox struct {
+	sandbox vc.VCSandbox
+}
+
+type container struct {
+	sandbox   *sandbox
+	id        string
+	processID string
+}
+
+type process struct {
+	container *container
+	id        string
+}
+
+// NewSandbox creates the sandbox id and boots its VM. The sandbox container,
+// the first container of the sandbox described by the WithSandboxContainer
+// option, is created with it and started by Start.
+func NewSandbox(ctx context.Context, id string, opts ...Option) (Sandbox, error) {
+	if id == "" {
+		return nil, fmt.Errorf("Missing sandbox ID")
+	}
+
+	o := &sandboxOptions{}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := loadConfiguration(o.configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, edit := range o.configEdits {
+		if err := edit(&config); err != nil {
+			return nil, err
+		}
+	}
+
+	ociSpec, err := o.container.spec(id, "")
+	if err != nil {
+		return nil, fmt.Errorf("Invalid sandbox container: %v", err)
+	}
+
+	if o.netNSPath != "" {
+		ociSpec.Linux.Namespaces = append(ociSpec.Linux.Namespaces, specs.LinuxNamespace{
+			Type: specs.NetworkNamespace,
+			Path: o.netNSPath,
+		})
+	}
+
+	for k, v := range o.annotations {
+		ociSpec.Annotations[k] = v
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rootFs := vc.RootFs{Target: ociSpec.Root.Path, Mounted: true}
+	s, _, err := katautils.CreateSandbox(ctx, vci, ociSpec, config, rootFs, id, filepath.Dir(ociSpec.Root.Path), "", false, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sandbox{sandbox: s}, nil
+}
+
+// LoadSandbox returns the sandbox id created by NewSandbox.
+func LoadSandbox(ctx context.Context, id string) (Sandbox, error) {
+	s, err := vci.FetchSandbox(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sandbox{sandbox: s}, nil
+}
+
+func (s *sandbox) ID() string {
+	return s.sandbox.ID()
+}
+
+func (s *sandbox) State(ctx context.Context) (State, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	return State(s.sandbox.Status().State.State), nil
+}
+
+func (s *sandbox) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.sandbox.Start()
+}
+
+func (s *sandbox) Stop(ctx context.Context, force bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.sandbox.Stop(force)
+}
+
+func (s *sandbox) Delete(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.sandbox.Delete()
+}
+
+func (s *sandbox) CreateContainer(ctx context.Context, id string, opts ...ContainerOption) (Container, error) {
+	if id == "" {
+		return nil, fmt.Errorf("Missing container ID")
+	}
+
+	o := &containerOptions{}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	ociSpec, err := o.spec(id, s.sandbox.ID())
+	if err != nil {
+		return nil, fmt.Errorf("Invalid container %s: %v", id, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	rootFs := vc.RootFs{Target: ociSpec.Root.Path, Mounted: true}
+	if _, err := katautils.CreateContainer(ctx, vci, s.sandbox, ociSpec, rootFs, id, filepath.Dir(ociSpec.Root.Path), "", false, true); err != nil {
+		return nil, err
+	}
+
+	return s.container(id), nil
+}
+
+func (s *sandbox) Container(id string) (Container, error) {
+	if c := s.sandbox.GetContainer(id); c == nil || c.ID() != id {
+		return nil, fmt.Errorf("Container %s not found in sandbox %s", id, s.sandbox.ID())
+	}
+
+	return s.container(id), nil
+}
+
+func (s *sandbox) Containers() []Container {
+	var containers []Container
+	for _, c := range s.sandbox.GetAllContainers() {
+		containers = append(containers, s.container(c.ID()))
+	}
+
+	return containers
+}
+
+// container returns the container id, its init process having the ID of
+// the container.
+func (s *sandbox) container(id string) *container {
+	return &container{
+		sandbox:   s,
+		id:        id,
+		processID: id,
+	}
+}
+
+func (c *container) ID() string {
+	return c.id
+}
+
+func (c *container) Wait(ctx context.Context) (int32, error) {
+	return c.sandbox.wait(ctx, c.id, c.processID)
+}
+
+func (c *container) Signal(ctx context.Context, signal syscall.Signal) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.sandbox.sandbox.SignalProcess(c.id, c.processID, signal, false)
+}
+
+func (c *container) IO(ctx context.Context) (io.WriteCloser, io.Reader, io.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return c.sandbox.sandbox.IOStream(c.id, c.processID)
+}
+
+func (c *container) Resize(ctx context.Context, height, width uint32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return c.sandbox.sandbox.WinsizeProcess(c.id, c.processID, height, width)
+}
+
+func (c *container) State(ctx context.Context) (State, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	status, err := c.sandbox.sandbox.StatusContainer(c.id)
+	if err != nil {
+		return "", err
+	}
+
+	return State(status.State.State), nil
+}
+
+func (c *container) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := c.sandbox.sandbox.StartContainer(c.id)
+	return err
+}
+
+func (c *container) Stop(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := c.sandbox.sandbox.StopContainer(c.id, true)
+	return err
+}
+
+func (c *container) Delete(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := c.sandbox.sandbox.DeleteContainer(c.id)
+	return err
+}
+
+func (c *container) Exec(ctx context.Context, opts ...ContainerOption) (Process, error) {
+	o := &containerOptions{}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd, err := o.cmd()
+	if err != nil {
+		return nil, fmt.Errorf("Invalid process of container %s: %v", c.id, err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	_, p, err := c.sandbox.sandbox.EnterContainer(c.id, cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	return &process{container: c, id: p.Token}, nil
+}
+
+func (p *process) ID() string {
+	return p.id
+}
+
+func (p *process) Wait(ctx context.Context) (int32, error) {
+	return p.container.sandbox.wait(ctx, p.container.id, p.id)
+}
+
+func (p *process) Signal(ctx context.Context, signal syscall.Signal) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return p.container.sandbox.sandbox.SignalProcess(p.container.id, p.id, signal, false)
+}
+
+func (p *process) IO(ctx context.Context) (io.WriteCloser, io.Reader, io.Reader, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return p.container.sandbox.sandbox.IOStream(p.container.id, p.id)
+}
+
+func (p *process) Resize(ctx context.Context, height, width uint32) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return p.container.sandbox.sandbox.WinsizeProcess(p.container.id, p.id, height, width)
+}
+
+// wait waits for the process of the container to exit, returning when the
+// context is done.
+func (s *sandbox) wait(ctx context.Context, containerID, processID string) (int32, error) {
+	type result struct {
+		code int32
+		err  error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		code, err := s.sandbox.WaitProcess(containerID, processID)
+		done <- result{code, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.code, r.err
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	}
+}
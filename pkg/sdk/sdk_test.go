@@ -0,0 +1,218 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package sdk
+
+import (
+	"context"
+	"syscall"
+	"testing"
+
+	criContainerdAnnotations "github.com/containerd/cri-containerd/pkg/annotations"
+	vc "github.com/kata-containers/runtime/virtcontainers"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/oci"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/vcmock"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testSandboxID   = "sdk-sandbox"
+	testContainerID = "sdk-container"
+	testRootfs      = "/var/lib/sdk/rootfs"
+)
+
+func mockSDK(t *testing.T) (*vcmock.VCMock, func()) {
+	m := &vcmock.VCMock{}
+
+	savedVCI := vci
+	savedLoad := loadConfiguration
+
+	vci = m
+	loadConfiguration = func(path string) (oci.RuntimeConfig, error) {
+		return oci.RuntimeConfig{
+			HypervisorType: vc.FirecrackerHypervisor,
+			AgentType:      vc.KataContainersAgent,
+			AgentConfig:    vc.KataAgentConfig{},
+			// no network namespace created by the tests
+			DisableNewNetNs: true,
+		}, nil
+	}
+
+	return m, func() {
+		vci = savedVCI
+		loadConfiguration = savedLoad
+	}
+}
+
+func TestContainerSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	o := &containerOptions{}
+	_, err := o.spec(testContainerID, testSandboxID)
+	assert.Error(err)
+
+	for _, opt := range []ContainerOption{
+		WithRootfs(testRootfs),
+		WithArgs("/bin/sh", "-c", "true"),
+		WithEnv("FOO=bar"),
+		WithUser(1000, 100),
+		WithMount("/srv/data", "/data", "ro"),
+	} {
+		assert.NoError(opt(o))
+	}
+
+	spec, err := o.spec(testContainerID, testSandboxID)
+	assert.NoError(err)
+
+	assert.Equal(testRootfs, spec.Root.Path)
+	assert.Equal([]string{"/bin/sh", "-c", "true"}, spec.Process.Args)
+	assert.Equal([]string{defaultPath, "FOO=bar"}, spec.Process.Env)
+	assert.Equal("/", spec.Process.Cwd)
+	assert.Equal(specs.User{UID: 1000, GID: 100}, spec.Process.User)
+	assert.Equal("sdk-sandbox/sdk-container", spec.Linux.CgroupsPath)
+
+	last := spec.Mounts[len(spec.Mounts)-1]
+	assert.Equal(specs.Mount{Source: "/srv/data", Destination: "/data", Type: "bind", Options: []string{"rbind", "ro"}}, last)
+
+	// the container is identified as a container of the sandbox
+	assert.Equal(criContainerdAnnotations.ContainerTypeContainer, spec.Annotations[criContainerdAnnotations.ContainerType])
+	assert.Equal(testSandboxID, spec.Annotations[criContainerdAnnotations.SandboxID])
+
+	spec, err = o.spec(testSandboxID, "")
+	assert.NoError(err)
+	assert.Empty(spec.Annotations)
+
+	assert.Error(WithRootfs("rootfs")(o))
+	assert.Error(WithEnv("FOO")(o))
+	assert.Error(WithMount("data", "/data")(o))
+}
+
+func TestContainerCmd(t *testing.T) {
+	assert := assert.New(t)
+
+	o := &containerOptions{}
+	_, err := o.cmd()
+	assert.Error(err)
+
+	assert.NoError(WithArgs("top")(o))
+	assert.NoError(WithEnv("PATH=/bin", "TERM=xterm")(o))
+	assert.NoError(WithWorkDir("/tmp")(o))
+	assert.NoError(WithTerminal()(o))
+
+	cmd, err := o.cmd()
+	assert.NoError(err)
+	assert.Equal(types.Cmd{
+		Args:            []string{"top"},
+		Envs:            []types.EnvVar{{Var: "PATH", Value: "/bin"}, {Var: "TERM", Value: "xterm"}},
+		User:            "0",
+		PrimaryGroup:    "0",
+		WorkDir:         "/tmp",
+		Interactive:     true,
+		NoNewPrivileges: true,
+	}, cmd)
+}
+
+func TestNewSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	m, cleanup := mockSDK(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	mockSandbox := &vcmock.Sandbox{
+		MockID: testSandboxID,
+	}
+	mockSandbox.MockContainers = []*vcmock.Container{
+		{MockID: testSandboxID, MockSandbox: mockSandbox},
+	}
+
+	m.CreateSandboxFunc = func(ctx context.Context, config vc.SandboxConfig) (vc.VCSandbox, error) {
+		assert.Equal(testSandboxID, config.ID)
+		assert.Equal(vc.FirecrackerHypervisor, config.HypervisorType)
+		assert.Equal(uint32(2), config.HypervisorConfig.NumVCPUs)
+		assert.Equal(uint32(512), config.HypervisorConfig.MemorySize)
+		assert.Equal("/opt/fc/vmlinux", config.HypervisorConfig.KernelPath)
+		assert.Equal("/opt/fc/initrd", config.HypervisorConfig.InitrdPath)
+		assert.Equal("/opt/fc/jailer", config.HypervisorConfig.JailerPath)
+		assert.Len(config.Containers, 1)
+		assert.Equal(testRootfs, config.Containers[0].RootFs.Target)
+		assert.Equal(string(vc.PodSandbox), config.Containers[0].Annotations[vcAnnotations.ContainerTypeKey])
+
+		return mockSandbox, nil
+	}
+
+	// missing sandbox container
+	_, err := NewSandbox(ctx, testSandboxID)
+	assert.Error(err)
+
+	s, err := NewSandbox(ctx, testSandboxID,
+		WithVCPUs(2),
+		WithMemory(512),
+		WithKernel("/opt/fc/vmlinux", "console=ttyS0"),
+		WithInitrd("/opt/fc/initrd"),
+		WithJailerPath("/opt/fc/jailer"),
+		WithSandboxContainer(
+			WithRootfs(testRootfs),
+			WithArgs("/pause"),
+		),
+	)
+	assert.NoError(err)
+	assert.Equal(testSandboxID, s.ID())
+
+	assert.Len(s.Containers(), 1)
+	c, err := s.Container(testSandboxID)
+	assert.NoError(err)
+	assert.Equal(testSandboxID, c.ID())
+
+	_, err = s.Container("missing")
+	assert.Error(err)
+
+	assert.NoError(s.Start(ctx))
+
+	// the operations honour the context
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Error(s.Stop(cancelled, true))
+	_, err = c.Wait(cancelled)
+	assert.Error(err)
+
+	_, err = NewSandbox(ctx, testSandboxID, WithVCPUs(0), WithSandboxContainer(WithRootfs(testRootfs), WithArgs("/pause")))
+	assert.Error(err)
+}
+
+func TestSandboxCreateContainer(t *testing.T) {
+	assert := assert.New(t)
+
+	_, cleanup := mockSDK(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	s := &sandbox{sandbox: &vcmock.Sandbox{MockID: testSandboxID}}
+
+	_, err := s.CreateContainer(ctx, testContainerID, WithArgs("/bin/app"))
+	assert.Error(err)
+
+	c, err := s.CreateContainer(ctx, testContainerID, WithRootfs(testRootfs), WithArgs("/bin/app"))
+	assert.NoError(err)
+	assert.Equal(testContainerID, c.ID())
+
+	assert.NoError(c.Start(ctx))
+	assert.NoError(c.Signal(ctx, syscall.SIGTERM))
+
+	code, err := c.Wait(ctx)
+	assert.NoError(err)
+	assert.Equal(int32(0), code)
+
+	p, err := c.Exec(ctx, WithArgs("/bin/ls"))
+	assert.NoError(err)
+	assert.NotNil(p)
+
+	_, err = c.Exec(ctx)
+	assert.Error(err)
+}
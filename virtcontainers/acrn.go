@@ -23,6 +23,7 @@ import (
 
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/uuid"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
@@ -76,6 +77,10 @@ type AcrnInfo struct {
 type AcrnState struct {
 	UUID string
 	PID  int
+
+	// BlkDevSlots are the PCI slots of the virtio-blk devices by drive
+	// index, assigned when the VM is launched.
+	BlkDevSlots []int
 }
 
 // Acrn is an Hypervisor interface implementation for the Linux acrn hypervisor.
@@ -137,7 +142,7 @@ func (a *Acrn) kernelParameters() string {
 	// add the params specified by the provided config. As the kernel
 	// honours the last parameter value set and since the config-provided
 	// params are added here, they will take priority over the defaults.
-	params = append(params, a.config.KernelParams...)
+	params = appendKernelParams(params, a.config.KernelParams)
 
 	paramsStr := SerializeParams(params, "=")
 
@@ -209,7 +214,7 @@ func (a *Acrn) trace(name string) (opentracing.Span, context.Context) {
 		a.ctx = context.Background()
 	}
 
-	span, ctx := opentracing.StartSpanFromContext(a.ctx, name)
+	span, ctx := startSpanFromContext(a.ctx, "hypervisor", name)
 
 	span.SetTag("subsystem", "hypervisor")
 	span.SetTag("type", "acrn")
@@ -280,6 +285,10 @@ func (a *Acrn) buildDevices(imagePath string) ([]Device, error) {
 		return nil, err
 	}
 
+	if a.config.EntropySource != "" {
+		devices = a.arch.appendRng(devices)
+	}
+
 	return devices, nil
 }
 
@@ -293,6 +302,14 @@ func (a *Acrn) setup(id string, hypervisorConfig *HypervisorConfig) error {
 		return err
 	}
 
+	if hypervisorConfig.CPUFeatures != "" {
+		return fmt.Errorf("CPU features are not supported by acrn")
+	}
+
+	if hypervisorConfig.CPUSockets != 0 {
+		return fmt.Errorf("Guest CPU topology is not supported by acrn")
+	}
+
 	a.id = id
 	a.config = *hypervisorConfig
 	a.arch = newAcrnArch(a.config)
@@ -431,8 +448,7 @@ func (a *Acrn) startSandbox(timeoutSecs int) error {
 		a.Logger().WithField("default-kernel-parameters", formatted).Debug()
 	}
 
-	vmPath := filepath.Join(a.store.RunVMStoragePath(), a.id)
-	err := os.MkdirAll(vmPath, DirMode)
+	vmPath, err := createSandboxVMPath(a.store.RunVMStoragePath(), a.id)
 	if err != nil {
 		return err
 	}
@@ -452,6 +468,10 @@ func (a *Acrn) startSandbox(timeoutSecs int) error {
 	}
 	a.state.PID = PID
 
+	// the slots of the block devices are needed to hot add drives from
+	// the other runtime processes
+	a.state.BlkDevSlots = append([]int{}, AcrnBlkdDevSlot...)
+
 	if err = a.waitSandbox(timeoutSecs); err != nil {
 		a.Logger().WithField("acrn wait failed:", err).Debug()
 		return err
@@ -539,30 +559,86 @@ func (a *Acrn) stopSandbox() (err error) {
 
 }
 
+// blkDevSlot returns the PCI slot of the virtio-blk device of the drive
+// index. The slots of the VMs launched before they were saved are only
+// known by the runtime process which launched them.
+func (a *Acrn) blkDevSlot(index int) int {
+	if index < len(a.state.BlkDevSlots) {
+		return a.state.BlkDevSlots[index]
+	}
+
+	return AcrnBlkdDevSlot[index]
+}
+
+// rescanBlockDevice replaces the backing file of the virtio-blk device of
+// the drive index, "nodisk" ejecting it.
+func (a *Acrn) rescanBlockDevice(index int, file string) error {
+	acrnctlPath, err := a.acrnctlPath()
+	if err != nil {
+		return err
+	}
+
+	args := []string{"blkrescan", a.acrnConfig.Name, fmt.Sprintf("%d,%s", a.blkDevSlot(index), file)}
+
+	a.Logger().WithFields(logrus.Fields{
+		"args": args,
+		"path": acrnctlPath,
+	}).Info("rescanning block device with acrnctl")
+
+	if out, err := exec.Command(acrnctlPath, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("acrnctl blkrescan failed: %v: %s", err, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+// checkBlockDriveIndex checks the drive index is one of the drives of the
+// pool, the drive 0 being the VM rootfs.
+func (a *Acrn) checkBlockDriveIndex(drive *config.BlockDrive) error {
+	if drive.Index < 1 || drive.Index > AcrnBlkDevPoolSz {
+		return vcTypes.Errorf(vcTypes.ErrCodeDeviceBusy, "Invalid drive index %d for drive %s: the %d drives of the ACRN block device pool are in use",
+			drive.Index, drive.ID, AcrnBlkDevPoolSz)
+	}
+
+	return nil
+}
+
 func (a *Acrn) updateBlockDevice(drive *config.BlockDrive) error {
-	var err error
-	if drive.File == "" || drive.Index >= AcrnBlkDevPoolSz {
-		return fmt.Errorf("Empty filepath or invalid drive index, Dive ID:%s, Drive Index:%d",
-			drive.ID, drive.Index)
+	if drive.File == "" {
+		return fmt.Errorf("Empty filepath for drive %s", drive.ID)
+	}
+
+	if err := a.checkBlockDriveIndex(drive); err != nil {
+		return err
+	}
+
+	// the drives of the pool are read-write, only their backing file
+	// can be updated
+	if drive.ReadOnly {
+		return fmt.Errorf("Read-only drive %s not supported by ACRN", drive.File)
 	}
 
-	slot := AcrnBlkdDevSlot[drive.Index]
+	if drive.CacheMode != "" {
+		a.Logger().WithFields(logrus.Fields{
+			"drive":      drive.ID,
+			"cache-mode": drive.CacheMode,
+		}).Warn("ACRN does not support drive cache modes, ignoring it")
+	}
 
 	//Explicitly set PCIAddr to NULL, so that VirtPath can be used
 	drive.PCIAddr = ""
 
-	args := []string{"blkrescan", a.acrnConfig.Name, fmt.Sprintf("%d,%s", slot, drive.File)}
+	return a.rescanBlockDevice(drive.Index, drive.File)
+}
 
-	a.Logger().WithFields(logrus.Fields{
-		"drive": drive,
-		"path":  a.config.HypervisorCtlPath,
-	}).Info("updateBlockDevice with acrnctl path")
-	cmd := exec.Command(a.config.HypervisorCtlPath, args...)
-	if err := cmd.Run(); err != nil {
-		a.Logger().WithError(err).Error("updating Block device with newFile path")
+// removeBlockDevice ejects the backing file of the drive, the virtio-blk
+// device stays in the pool for the next drive hot added.
+func (a *Acrn) removeBlockDevice(drive *config.BlockDrive) error {
+	if err := a.checkBlockDriveIndex(drive); err != nil {
+		return err
 	}
 
-	return err
+	return a.rescanBlockDevice(drive.Index, "nodisk")
 }
 
 func (a *Acrn) hotplugAddDevice(devInfo interface{}, devType deviceType) (interface{}, error) {
@@ -583,9 +659,13 @@ func (a *Acrn) hotplugRemoveDevice(devInfo interface{}, devType deviceType) (int
 	span, _ := a.trace("hotplugRemoveDevice")
 	defer span.Finish()
 
-	// Not supported. return success
-
-	return nil, nil
+	switch devType {
+	case blockDev:
+		return nil, a.removeBlockDevice(devInfo.(*config.BlockDrive))
+	default:
+		// Not supported. return success
+		return nil, nil
+	}
 }
 
 func (a *Acrn) pauseSandbox() error {
@@ -645,7 +725,7 @@ func (a *Acrn) getSandboxConsole(id string) (string, error) {
 	span, _ := a.trace("getSandboxConsole")
 	defer span.Finish()
 
-	return utils.BuildSocketPath(a.store.RunVMStoragePath(), id, acrnConsoleSocket)
+	return utils.BuildSocketPath(SandboxVMPath(a.store.RunVMStoragePath(), id), acrnConsoleSocket)
 }
 
 func (a *Acrn) saveSandbox() error {
@@ -692,6 +772,10 @@ func (a *Acrn) getPids() []int {
 	return []int{a.state.PID}
 }
 
+func (a *Acrn) getVMPath() string {
+	return SandboxVMPath(a.store.RunVMStoragePath(), a.id)
+}
+
 func (a *Acrn) fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error {
 	return errors.New("acrn is not supported by VM cache")
 }
@@ -704,12 +788,14 @@ func (a *Acrn) save() (s persistapi.HypervisorState) {
 	s.Pid = a.state.PID
 	s.Type = string(AcrnHypervisor)
 	s.UUID = a.state.UUID
+	s.BlkDevSlots = a.state.BlkDevSlots
 	return
 }
 
 func (a *Acrn) load(s persistapi.HypervisorState) {
 	a.state.PID = s.Pid
 	a.state.UUID = s.UUID
+	a.state.BlkDevSlots = s.BlkDevSlots
 }
 
 func (a *Acrn) check() error {
@@ -720,6 +806,22 @@ func (a *Acrn) check() error {
 	return nil
 }
 
+func (a *Acrn) describe() (VMInfo, error) {
+	return VMInfo{}, errors.New("acrn does not provide an API to describe the VM")
+}
+
+func (a *Acrn) rebootSandbox() error {
+	return errors.New("acrn does not support rebooting the VM")
+}
+
+func (a *Acrn) migrateSandbox(uri string) error {
+	return errors.New("acrn does not support migration")
+}
+
+func (a *Acrn) launchMeasurement() (string, error) {
+	return "", errors.New("acrn does not support confidential guests")
+}
+
 func (a *Acrn) generateSocket(id string, useVsock bool) (interface{}, error) {
 	return generateVMSocket(id, useVsock, a.store.RunVMStoragePath())
 }
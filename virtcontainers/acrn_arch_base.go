@@ -52,6 +52,9 @@ type acrnArch interface {
 	// appendSocket appends a socket to devices
 	appendSocket(devices []Device, socket types.Socket) []Device
 
+	// appendRng appends a random number generator to devices
+	appendRng(devices []Device) []Device
+
 	// appendNetwork appends a endpoint device to devices
 	appendNetwork(devices []Device, endpoint Endpoint) []Device
 
@@ -80,6 +83,7 @@ const acrnGVTgReservedSlot = 2
 
 const acrnLPCDev = "lpc"
 const acrnHostBridge = "hostbridge"
+const acrnRngDev = "virtio-rnd"
 
 var baselogger *logrus.Entry
 
@@ -257,6 +261,13 @@ type LPCDevice struct {
 	Emul string
 }
 
+// RngDevice represents a acrn random number generator device, acrn-dm
+// reads the host entropy from /dev/random.
+type RngDevice struct {
+	// Emul is a string describing the type of PCI device e.g. virtio-rnd
+	Emul string
+}
+
 // Memory is the guest memory configuration structure.
 type Memory struct {
 	// Size is the amount of memory made available to the guest.
@@ -337,15 +348,11 @@ func (a *acrnArchBase) acrnctlPath() (string, error) {
 }
 
 func (a *acrnArchBase) kernelParameters(debug bool) []Param {
-	params := a.kernelParams
-
 	if debug {
-		params = append(params, a.kernelParamsDebug...)
-	} else {
-		params = append(params, a.kernelParamsNonDebug...)
+		return mergeKernelParams(a.kernelParams, a.kernelParamsDebug)
 	}
 
-	return params
+	return mergeKernelParams(a.kernelParams, a.kernelParamsNonDebug)
 }
 
 func (a *acrnArchBase) memoryTopology(memoryMb uint64) Memory {
@@ -523,6 +530,21 @@ func (lpcDev LPCDevice) AcrnParams(slot int, config *Config) []string {
 	return acrnParams
 }
 
+// Valid returns true if the RngDevice structure is valid and complete.
+func (rngDev RngDevice) Valid() bool {
+	return rngDev.Emul == acrnRngDev
+}
+
+// AcrnParams returns the acrn parameters built out of this rng device.
+func (rngDev RngDevice) AcrnParams(slot int, config *Config) []string {
+	var acrnParams []string
+
+	acrnParams = append(acrnParams, "-s")
+	acrnParams = append(acrnParams, fmt.Sprintf("%d,%s", slot, rngDev.Emul))
+
+	return acrnParams
+}
+
 func (config *Config) appendName() {
 	if config.Name != "" {
 		config.acrnParams = append(config.acrnParams, config.Name)
@@ -672,6 +694,16 @@ func (a *acrnArchBase) appendLPC(devices []Device) []Device {
 	return devices
 }
 
+func (a *acrnArchBase) appendRng(devices []Device) []Device {
+	devices = append(devices,
+		RngDevice{
+			Emul: acrnRngDev,
+		},
+	)
+
+	return devices
+}
+
 func (a *acrnArchBase) appendConsole(devices []Device, path string) []Device {
 	console := ConsoleDevice{
 		Name:     "console0",
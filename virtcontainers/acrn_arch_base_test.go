@@ -191,6 +191,25 @@ func TestAcrnArchBaseAppendLpcDevice(t *testing.T) {
 	assert.Equal(expectedOut, devices)
 }
 
+func TestAcrnArchBaseAppendRngDevice(t *testing.T) {
+	var devices []Device
+	assert := assert.New(t)
+	acrnArchBase := newAcrnArchBase()
+
+	devices = acrnArchBase.appendRng(devices)
+	assert.Len(devices, 1)
+
+	expectedOut := []Device{
+		RngDevice{
+			Emul: acrnRngDev,
+		},
+	}
+
+	assert.Equal(expectedOut, devices)
+	assert.True(devices[0].Valid())
+	assert.Equal([]string{"-s", "5,virtio-rnd"}, devices[0].AcrnParams(5, nil))
+}
+
 func testAcrnArchBaseAppend(t *testing.T, structure interface{}, expected []Device) {
 	var devices []Device
 	var err error
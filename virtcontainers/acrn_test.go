@@ -13,6 +13,7 @@ import (
 
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/persist"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -192,6 +193,87 @@ func TestAcrnUpdateBlockDeviceInvalidIdx(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestAcrnUpdateBlockDeviceRootfsIdx(t *testing.T) {
+	assert := assert.New(t)
+
+	a := &Acrn{
+		ctx:    context.Background(),
+		id:     "acrnBlkTest",
+		config: newAcrnConfig(),
+	}
+
+	// the drive 0 is the VM rootfs, not a drive of the pool
+	drive := &config.BlockDrive{
+		File:  "/tmp/test.img",
+		Index: 0,
+	}
+
+	err := a.updateBlockDevice(drive)
+	assert.Error(err)
+	assert.Equal(vcTypes.ErrCodeDeviceBusy, vcTypes.ErrorCodeOf(err))
+
+	_, err = a.hotplugRemoveDevice(drive, blockDev)
+	assert.Error(err)
+}
+
+func TestAcrnUpdateBlockDeviceReadOnly(t *testing.T) {
+	assert := assert.New(t)
+
+	a := &Acrn{
+		ctx:    context.Background(),
+		id:     "acrnBlkTest",
+		config: newAcrnConfig(),
+	}
+
+	drive := &config.BlockDrive{
+		File:     "/tmp/test.img",
+		Index:    1,
+		ReadOnly: true,
+	}
+
+	err := a.updateBlockDevice(drive)
+	assert.Error(err)
+}
+
+func TestAcrnBlkDevSlot(t *testing.T) {
+	assert := assert.New(t)
+
+	savedSlots := AcrnBlkdDevSlot
+	defer func() {
+		AcrnBlkdDevSlot = savedSlots
+	}()
+	AcrnBlkdDevSlot = make([]int, AcrnBlkDevPoolSz+1)
+	AcrnBlkdDevSlot[1] = 3
+
+	a := &Acrn{}
+
+	// the slots assigned by the runtime process which launched the VM
+	assert.Equal(3, a.blkDevSlot(1))
+
+	a.state.BlkDevSlots = make([]int, AcrnBlkDevPoolSz+1)
+	a.state.BlkDevSlots[1] = 5
+	assert.Equal(5, a.blkDevSlot(1))
+}
+
+func TestAcrnSaveLoad(t *testing.T) {
+	assert := assert.New(t)
+
+	a := &Acrn{
+		state: AcrnState{
+			UUID:        "f2a0ed4b-4e5f-4b84-9b6a-9b1c1cd4f7c5",
+			PID:         1234,
+			BlkDevSlots: []int{0, 3, 4},
+		},
+	}
+
+	s := a.save()
+	assert.Equal(string(AcrnHypervisor), s.Type)
+
+	loaded := &Acrn{}
+	loaded.load(s)
+	assert.Equal(a.state, loaded.state)
+}
+
 func TestAcrnGetSandboxConsole(t *testing.T) {
 	assert := assert.New(t)
 
@@ -203,7 +285,7 @@ func TestAcrnGetSandboxConsole(t *testing.T) {
 		store: store,
 	}
 	sandboxID := "testSandboxID"
-	expected := filepath.Join(a.store.RunVMStoragePath(), sandboxID, consoleSocket)
+	expected := filepath.Join(sandboxTmpPath(sandboxID), "vm", consoleSocket)
 
 	result, err := a.getSandboxConsole(sandboxID)
 	assert.NoError(err)
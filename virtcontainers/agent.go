@@ -159,6 +159,13 @@ type agent interface {
 	// stopSandbox will tell the agent to stop all containers related to the Sandbox.
 	stopSandbox(sandbox *Sandbox) error
 
+	// restartSandbox will wait for the agent of the rebooted guest and start the Sandbox again.
+	restartSandbox(sandbox *Sandbox) error
+
+	// resumeMigratedSandbox will wait for the agent of a guest migrated from another host,
+	// whose Sandbox is already started.
+	resumeMigratedSandbox(sandbox *Sandbox) error
+
 	// createContainer will tell the agent to create a container related to a Sandbox.
 	createContainer(sandbox *Sandbox, c *Container) (*Process, error)
 
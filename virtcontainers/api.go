@@ -7,6 +7,7 @@ package virtcontainers
 
 import (
 	"context"
+	"net"
 	"os"
 	"runtime"
 	"syscall"
@@ -31,7 +32,7 @@ var virtLog = logrus.WithField("source", "virtcontainers")
 // trace creates a new tracing span based on the specified name and parent
 // context.
 func trace(parent context.Context, name string) (opentracing.Span, context.Context) {
-	span, ctx := opentracing.StartSpanFromContext(parent, name)
+	span, ctx := startSpanFromContext(parent, "api", name)
 
 	// Should not need to be changed (again).
 	span.SetTag("source", "virtcontainers")
@@ -58,6 +59,12 @@ func CreateSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Fac
 	span, ctx := trace(ctx, "CreateSandbox")
 	defer span.Finish()
 
+	// collect what the crashed sandboxes left behind, this never fails
+	// the creation
+	if _, err := cleanupOrphans(sandboxConfig.HypervisorConfig.JailerChrootBase); err != nil {
+		virtLog.WithError(err).Warn("Could not collect orphaned VM trees")
+	}
+
 	s, err := createSandboxFromConfig(ctx, sandboxConfig, factory)
 	if err == nil {
 		s.releaseStatelessSandbox()
@@ -70,12 +77,25 @@ func createSandboxFromConfig(ctx context.Context, sandboxConfig SandboxConfig, f
 	span, ctx := trace(ctx, "createSandboxFromConfig")
 	defer span.Finish()
 
+	// The VMs of a factory share the file system it was created with
+	if factory != nil && sandboxConfig.HypervisorConfig.SharedFS == "" {
+		sandboxConfig.HypervisorConfig.SharedFS = factory.Config().HypervisorConfig.SharedFS
+	}
+
+	if err := NegotiateSharedFS(ctx, sandboxConfig.HypervisorType, &sandboxConfig.HypervisorConfig); err != nil {
+		return nil, err
+	}
+
 	// Create the sandbox.
 	s, err := createSandbox(ctx, sandboxConfig, factory)
 	if err != nil {
 		return nil, err
 	}
 
+	defer func() {
+		s.audit(auditSandboxCreate, nil, err)
+	}()
+
 	// Move runtime to sandbox cgroup so all process are created there.
 	if s.config.SandboxCgroupOnly {
 		if err := s.setupSandboxCgroup(); err != nil {
@@ -374,15 +394,8 @@ func StatusSandbox(ctx context.Context, sandboxID string) (SandboxStatus, error)
 		contStatusList = append(contStatusList, contStatus)
 	}
 
-	sandboxStatus := SandboxStatus{
-		ID:               s.id,
-		State:            s.state,
-		Hypervisor:       s.config.HypervisorType,
-		HypervisorConfig: s.config.HypervisorConfig,
-		Agent:            s.config.AgentType,
-		ContainersStatus: contStatusList,
-		Annotations:      s.config.Annotations,
-	}
+	sandboxStatus := s.Status()
+	sandboxStatus.ContainersStatus = contStatusList
 
 	return sandboxStatus, nil
 }
@@ -781,6 +794,220 @@ func StatsSandbox(ctx context.Context, sandboxID string) (SandboxStats, []Contai
 	return sandboxStats, containerStats, nil
 }
 
+// InspectSandbox is the virtcontainers entry point to describe a sandbox
+// and the live state of its VM.
+func InspectSandbox(ctx context.Context, sandboxID string) (SandboxInfo, error) {
+	span, ctx := trace(ctx, "InspectSandbox")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return SandboxInfo{}, vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rLockSandbox(sandboxID)
+	if err != nil {
+		return SandboxInfo{}, err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return SandboxInfo{}, err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.Inspect()
+}
+
+// MigrateSandbox is the virtcontainers entry point to migrate the VM of a
+// running sandbox to uri, see Sandbox.Migrate(). It returns the state to
+// restore the sandbox from with RestoreSandbox() on the destination host.
+func MigrateSandbox(ctx context.Context, sandboxID, uri string) ([]byte, error) {
+	span, ctx := trace(ctx, "MigrateSandbox")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return nil, vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rwLockSandbox(sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.Migrate(uri)
+}
+
+// RestoreSandbox is the virtcontainers entry point to restore a sandbox
+// migrated from another host with MigrateSandbox(), from the state it
+// returned and the migration stream of its VM at uri.
+func RestoreSandbox(ctx context.Context, state []byte, uri string) (VCSandbox, error) {
+	span, ctx := trace(ctx, "RestoreSandbox")
+	defer span.Finish()
+
+	s, err := restoreSandbox(ctx, state, uri)
+	if err != nil {
+		return nil, err
+	}
+	s.releaseStatelessSandbox()
+
+	return s, nil
+}
+
+// RebootSandbox is the virtcontainers entry point to reboot the guest of a
+// running sandbox, see Sandbox.Reboot().
+func RebootSandbox(ctx context.Context, sandboxID string) error {
+	span, ctx := trace(ctx, "RebootSandbox")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rwLockSandbox(sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.Reboot()
+}
+
+// CleanupOrphans is the virtcontainers entry point to remove the VM trees
+// and their mounts left behind by the sandboxes which weren't deleted
+// properly, it returns the removed paths. The jails in jailerChrootBase are
+// collected too if not empty.
+func CleanupOrphans(ctx context.Context, jailerChrootBase string) ([]string, error) {
+	span, _ := trace(ctx, "CleanupOrphans")
+	defer span.Finish()
+
+	return cleanupOrphans(jailerChrootBase)
+}
+
+// SandboxLaunchMeasurement is the virtcontainers entry point to get the
+// launch measurement of a running confidential sandbox, for attestation
+// services to verify the VM before releasing secrets to it.
+func SandboxLaunchMeasurement(ctx context.Context, sandboxID string) (string, error) {
+	span, ctx := trace(ctx, "SandboxLaunchMeasurement")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return "", vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rLockSandbox(sandboxID)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return "", err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.LaunchMeasurement()
+}
+
+// SandboxBootTimes is the virtcontainers entry point to get the boot
+// timeline of a sandbox, see Sandbox.BootTimes().
+func SandboxBootTimes(ctx context.Context, sandboxID string) (BootTimes, error) {
+	span, ctx := trace(ctx, "SandboxBootTimes")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return BootTimes{}, vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rLockSandbox(sandboxID)
+	if err != nil {
+		return BootTimes{}, err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return BootTimes{}, err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.BootTimes(), nil
+}
+
+// SandboxOverhead is the virtcontainers entry point to get the expected
+// and actual host overhead of a sandbox, see Sandbox.Overhead().
+func SandboxOverhead(ctx context.Context, sandboxID string) (Overhead, error) {
+	span, ctx := trace(ctx, "SandboxOverhead")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return Overhead{}, vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rLockSandbox(sandboxID)
+	if err != nil {
+		return Overhead{}, err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return Overhead{}, err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.Overhead()
+}
+
+// SandboxMounts is the virtcontainers entry point to describe how the
+// mounts of the containers of a sandbox reach its guest, see
+// Sandbox.MountInfo().
+func SandboxMounts(ctx context.Context, sandboxID string) (SandboxMountInfo, error) {
+	span, ctx := trace(ctx, "SandboxMounts")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return SandboxMountInfo{}, vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rLockSandbox(sandboxID)
+	if err != nil {
+		return SandboxMountInfo{}, err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return SandboxMountInfo{}, err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.MountInfo()
+}
+
+// GetHypervisorCapabilities is the virtcontainers entry point to get the
+// features a configured hypervisor supports, probing its binary.
+func GetHypervisorCapabilities(ctx context.Context, hType HypervisorType, conf HypervisorConfig) (HypervisorCapabilities, error) {
+	span, ctx := trace(ctx, "GetHypervisorCapabilities")
+	defer span.Finish()
+
+	return hypervisorCapabilities(ctx, hType, conf)
+}
+
 func togglePauseContainer(ctx context.Context, sandboxID, containerID string, pause bool) error {
 	if sandboxID == "" {
 		return vcTypes.ErrNeedSandboxID
@@ -849,6 +1076,106 @@ func AddDevice(ctx context.Context, sandboxID string, info deviceConfig.DeviceIn
 	return s.AddDevice(info)
 }
 
+// PrewarmContainerImage is the virtcontainers entry point to stage the rootfs
+// of a container into a running sandbox before the container gets created.
+func PrewarmContainerImage(ctx context.Context, sandboxID, containerID string, rootFs RootFs) error {
+	span, ctx := trace(ctx, "PrewarmContainerImage")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return vcTypes.ErrNeedSandboxID
+	}
+
+	if containerID == "" {
+		return vcTypes.ErrNeedContainerID
+	}
+
+	unlock, err := rwLockSandbox(sandboxID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.PrewarmContainerImage(containerID, rootFs)
+}
+
+// sandboxVSockPortURL returns the URL of a guest vsock port of the sandbox.
+func sandboxVSockPortURL(ctx context.Context, sandboxID string, port uint32) (string, error) {
+	unlock, err := rLockSandbox(sandboxID)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return "", err
+	}
+	defer s.releaseStatelessSandbox()
+
+	return s.vsockPortURL(port)
+}
+
+// ForwardSandboxPort is the virtcontainers entry point to forward the
+// connections accepted by the listener to a guest vsock port of a running
+// sandbox. It returns once the listener is closed, the sandbox not being
+// locked meanwhile.
+func ForwardSandboxPort(ctx context.Context, sandboxID string, port uint32, listener net.Listener) error {
+	span, ctx := trace(ctx, "ForwardSandboxPort")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return vcTypes.ErrNeedSandboxID
+	}
+
+	channelURL, err := sandboxVSockPortURL(ctx, sandboxID, port)
+	if err != nil {
+		return err
+	}
+
+	newVSockTunnel(listener, channelURL, virtLog.WithField("sandbox", sandboxID)).wait()
+
+	return nil
+}
+
+// OpenSandboxDebugConsole is the virtcontainers entry point to connect to
+// the debug console of the agent of a running sandbox, a shell of the guest
+// running as root. The connection is not bound to the lifetime of the
+// sandbox lock.
+func OpenSandboxDebugConsole(ctx context.Context, sandboxID string) (net.Conn, error) {
+	span, ctx := trace(ctx, "OpenSandboxDebugConsole")
+	defer span.Finish()
+
+	if sandboxID == "" {
+		return nil, vcTypes.ErrNeedSandboxID
+	}
+
+	unlock, err := rLockSandbox(sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	s, err := fetchSandbox(ctx, sandboxID)
+	if err != nil {
+		return nil, err
+	}
+	defer s.releaseStatelessSandbox()
+
+	channelURL, err := s.debugConsoleURL()
+	if err != nil {
+		return nil, err
+	}
+
+	return dialVSock(channelURL)
+}
+
 func toggleInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface, add bool) (*vcTypes.Interface, error) {
 	if sandboxID == "" {
 		return nil, vcTypes.ErrNeedSandboxID
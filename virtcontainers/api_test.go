@@ -9,6 +9,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -529,6 +530,8 @@ func TestStatusSandboxSuccessfulStateReady(t *testing.T) {
 		BlockDeviceDriver: defaultBlockDriver,
 		DefaultMaxVCPUs:   defaultMaxQemuVCPUs,
 		Msize9p:           defaultMsize9p,
+		// the mock hypervisor shares no file system
+		SharedFS: "none",
 	}
 
 	expectedStatus := SandboxStatus{
@@ -567,6 +570,8 @@ func TestStatusSandboxSuccessfulStateReady(t *testing.T) {
 	// Copy the start time as we can't pretend we know what that
 	// value will be.
 	expectedStatus.ContainersStatus[0].StartTime = status.ContainersStatus[0].StartTime
+	expectedStatus.State.BootTimeline = status.State.BootTimeline
+	expectedStatus.State.Transitions = status.State.Transitions
 
 	assert.Equal(status, expectedStatus)
 }
@@ -589,6 +594,8 @@ func TestStatusSandboxSuccessfulStateRunning(t *testing.T) {
 		BlockDeviceDriver: defaultBlockDriver,
 		DefaultMaxVCPUs:   defaultMaxQemuVCPUs,
 		Msize9p:           defaultMsize9p,
+		// the mock hypervisor shares no file system
+		SharedFS: "none",
 	}
 
 	expectedStatus := SandboxStatus{
@@ -631,6 +638,8 @@ func TestStatusSandboxSuccessfulStateRunning(t *testing.T) {
 	// Copy the start time as we can't pretend we know what that
 	// value will be.
 	expectedStatus.ContainersStatus[0].StartTime = status.ContainersStatus[0].StartTime
+	expectedStatus.State.BootTimeline = status.State.BootTimeline
+	expectedStatus.State.Transitions = status.State.Transitions
 
 	assert.Exactly(status, expectedStatus)
 }
@@ -653,6 +662,204 @@ func TestStatusSandboxFailingFetchSandboxConfig(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestInspectSandbox(t *testing.T) {
+	defer cleanUp()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	_, err := InspectSandbox(ctx, "")
+	assert.Error(err)
+
+	config := newTestSandboxConfigNoop()
+	p, err := CreateSandbox(ctx, config, nil)
+	assert.NoError(err)
+	assert.NotNil(p)
+
+	info, err := InspectSandbox(ctx, p.ID())
+	assert.NoError(err)
+	assert.Equal(p.ID(), info.ID)
+	assert.Equal(string(types.StateReady), info.State)
+	assert.Equal(MockHypervisor, info.HypervisorType)
+	assert.Empty(info.VMError)
+	assert.NotNil(info.VM)
+	assert.Equal("running", info.VM.State)
+
+	_, err = StopSandbox(ctx, p.ID(), false)
+	assert.NoError(err)
+
+	// the VMM isn't queried once the sandbox is stopped
+	info, err = InspectSandbox(ctx, p.ID())
+	assert.NoError(err)
+	assert.Nil(info.VM)
+	assert.NotEmpty(info.VMError)
+}
+
+func TestSandboxMounts(t *testing.T) {
+	defer cleanUp()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	_, err := SandboxMounts(ctx, "")
+	assert.Error(err)
+
+	config := newTestSandboxConfigNoop()
+	p, err := CreateSandbox(ctx, config, nil)
+	assert.NoError(err)
+	assert.NotNil(p)
+
+	info, err := SandboxMounts(ctx, p.ID())
+	assert.NoError(err)
+	assert.Equal(p.ID(), info.ID)
+	assert.Len(info.Containers, len(config.Containers))
+}
+
+func TestSandboxBootTimes(t *testing.T) {
+	defer cleanUp()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	_, err := SandboxBootTimes(ctx, "")
+	assert.Error(err)
+
+	config := newTestSandboxConfigNoop()
+	p, err := CreateSandbox(ctx, config, nil)
+	assert.NoError(err)
+
+	times, err := SandboxBootTimes(ctx, p.ID())
+	assert.NoError(err)
+	assert.False(times.Timeline.Create.IsZero())
+	assert.False(times.Timeline.VMMReady.IsZero())
+	assert.False(times.Timeline.AgentReady.IsZero())
+	assert.True(times.Timeline.FirstContainerStart.IsZero())
+	assert.NotZero(times.Total)
+
+	_, err = StartSandbox(ctx, p.ID())
+	assert.NoError(err)
+
+	times, err = SandboxBootTimes(ctx, p.ID())
+	assert.NoError(err)
+	assert.False(times.Timeline.FirstContainerStart.IsZero())
+}
+
+func TestMigrateSandbox(t *testing.T) {
+	defer cleanUp()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	_, err := MigrateSandbox(ctx, "", "tcp:localhost:4444")
+	assert.Error(err)
+
+	config := newTestSandboxConfigNoop()
+	p, err := CreateSandbox(ctx, config, nil)
+	assert.NoError(err)
+
+	// the sandbox isn't running
+	_, err = MigrateSandbox(ctx, p.ID(), "tcp:localhost:4444")
+	assert.Error(err)
+
+	_, err = StartSandbox(ctx, p.ID())
+	assert.NoError(err)
+
+	state, err := MigrateSandbox(ctx, p.ID(), "tcp:localhost:4444")
+	assert.NoError(err)
+	assert.NotEmpty(state)
+
+	status, err := StatusSandbox(ctx, p.ID())
+	assert.NoError(err)
+	assert.Equal(types.StatePaused, status.State.State)
+}
+
+func TestRestoreSandbox(t *testing.T) {
+	defer cleanUp()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	_, err := RestoreSandbox(ctx, []byte("invalid"), "tcp:0:4444")
+	assert.Error(err)
+
+	config := newTestSandboxConfigNoop()
+	p, err := CreateSandbox(ctx, config, nil)
+	assert.NoError(err)
+
+	_, err = StartSandbox(ctx, p.ID())
+	assert.NoError(err)
+
+	state, err := MigrateSandbox(ctx, p.ID(), "tcp:localhost:4444")
+	assert.NoError(err)
+
+	// the sandbox exists on this host
+	_, err = RestoreSandbox(ctx, state, "tcp:0:4444")
+	assert.Error(err)
+
+	// move the sandbox to another host
+	store, err := persist.GetDriver()
+	assert.NoError(err)
+	assert.NoError(store.Destroy(p.ID()))
+
+	r, err := RestoreSandbox(ctx, state, "tcp:0:4444")
+	assert.NoError(err)
+	assert.Equal(p.ID(), r.ID())
+
+	status, err := StatusSandbox(ctx, p.ID())
+	assert.NoError(err)
+	assert.Equal(types.StateRunning, status.State.State)
+	assert.Len(status.ContainersStatus, len(config.Containers))
+
+	s, ok := r.(*Sandbox)
+	assert.True(ok)
+	assert.Empty(s.config.HypervisorConfig.IncomingMigrationURI)
+}
+
+func TestRebootSandbox(t *testing.T) {
+	defer cleanUp()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	err := RebootSandbox(ctx, "")
+	assert.Error(err)
+
+	config := newTestSandboxConfigNoop()
+	p, err := CreateSandbox(ctx, config, nil)
+	assert.NoError(err)
+
+	// the sandbox isn't running
+	err = RebootSandbox(ctx, p.ID())
+	assert.Error(err)
+
+	_, err = StartSandbox(ctx, p.ID())
+	assert.NoError(err)
+
+	err = RebootSandbox(ctx, p.ID())
+	assert.NoError(err)
+
+	status, err := StatusSandbox(ctx, p.ID())
+	assert.NoError(err)
+	assert.Equal(types.StateRunning, status.State.State)
+	for _, c := range status.ContainersStatus {
+		assert.Equal(types.StateStopped, c.State.State)
+	}
+}
+
+func TestSandboxOverhead(t *testing.T) {
+	defer cleanUp()
+	assert := assert.New(t)
+
+	ctx := context.Background()
+	_, err := SandboxOverhead(ctx, "")
+	assert.Error(err)
+
+	config := newTestSandboxConfigNoop()
+	p, err := CreateSandbox(ctx, config, nil)
+	assert.NoError(err)
+	assert.NotNil(p)
+
+	overhead, err := SandboxOverhead(ctx, p.ID())
+	assert.NoError(err)
+	assert.Equal(p.ID(), overhead.ID)
+	assert.Equal(uint32(defaultVCPUs), overhead.ExpectedVCPUs)
+	assert.Equal(uint64(defaultMemSzMiB)<<20, overhead.ExpectedMemory)
+}
+
 func TestStatusPodSandboxFailingFetchSandboxState(t *testing.T) {
 	defer cleanUp()
 	assert := assert.New(t)
@@ -1745,3 +1952,132 @@ func TestCleanupContainer(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestPrewarmContainerImage(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	defer cleanUp()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	rootFs := RootFs{Mounted: true}
+
+	err := PrewarmContainerImage(ctx, "", "100", rootFs)
+	assert.Error(err)
+
+	err = PrewarmContainerImage(ctx, testSandboxID, "", rootFs)
+	assert.Error(err)
+
+	err = PrewarmContainerImage(ctx, testSandboxID, "100", rootFs)
+	assert.Error(err)
+
+	savedKataHostSharedDir := kataHostSharedDir
+	sharedDir, err := ioutil.TempDir("", "kata-prewarm")
+	assert.NoError(err)
+	kataHostSharedDir = func() string {
+		return sharedDir
+	}
+	defer func() {
+		kataHostSharedDir = savedKataHostSharedDir
+		os.RemoveAll(sharedDir)
+	}()
+
+	config := newTestSandboxConfigNoop()
+
+	s, _, err := createAndStartSandbox(ctx, config)
+	assert.NoError(err)
+	assert.NotNil(s)
+
+	imageDir, err := ioutil.TempDir("", "kata-prewarm-image")
+	assert.NoError(err)
+	defer os.RemoveAll(imageDir)
+	err = ioutil.WriteFile(filepath.Join(imageDir, "file"), []byte("data"), 0644)
+	assert.NoError(err)
+
+	// a rootfs target is required
+	err = PrewarmContainerImage(ctx, s.ID(), "100", rootFs)
+	assert.Error(err)
+
+	// the noop agent does not support block devices
+	err = PrewarmContainerImage(ctx, s.ID(), "100", RootFs{Source: "/dev/null"})
+	assert.Error(err)
+
+	// container already exists in the sandbox
+	rootFs.Target = imageDir
+	err = PrewarmContainerImage(ctx, s.ID(), containerID, rootFs)
+	assert.Error(err)
+
+	rootfsDest := filepath.Join(sharedDir, s.ID(), "100", rootfsDir)
+	defer syscall.Unmount(rootfsDest, syscall.MNT_DETACH)
+
+	err = PrewarmContainerImage(ctx, s.ID(), "100", rootFs)
+	assert.NoError(err)
+	_, err = os.Stat(filepath.Join(rootfsDest, "file"))
+	assert.NoError(err)
+
+	// prewarming twice doesn't stack mounts
+	err = PrewarmContainerImage(ctx, s.ID(), "100", rootFs)
+	assert.NoError(err)
+
+	err = bindUnmountContainerRootfs(ctx, sharedDir, s.ID(), "100")
+	assert.NoError(err)
+	_, err = os.Stat(filepath.Join(rootfsDest, "file"))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestForwardSandboxPort(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	defer cleanUp()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	err := ForwardSandboxPort(ctx, "", 2000, nil)
+	assert.Error(err)
+
+	err = ForwardSandboxPort(ctx, testSandboxID, 2000, nil)
+	assert.Error(err)
+
+	config := newTestSandboxConfigNoop()
+
+	s, _, err := createAndStartSandbox(ctx, config)
+	assert.NoError(err)
+	assert.NotNil(s)
+
+	// the noop agent is not connected through a vsock
+	err = ForwardSandboxPort(ctx, s.ID(), 2000, nil)
+	assert.Error(err)
+}
+
+func TestOpenSandboxDebugConsole(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	defer cleanUp()
+
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	_, err := OpenSandboxDebugConsole(ctx, "")
+	assert.Error(err)
+
+	_, err = OpenSandboxDebugConsole(ctx, testSandboxID)
+	assert.Error(err)
+
+	config := newTestSandboxConfigNoop()
+
+	s, _, err := createAndStartSandbox(ctx, config)
+	assert.NoError(err)
+	assert.NotNil(s)
+
+	// the noop agent has no debug console
+	_, err = OpenSandboxDebugConsole(ctx, s.ID())
+	assert.Error(err)
+}
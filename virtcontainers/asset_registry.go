@@ -0,0 +1,333 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/types"
+)
+
+const (
+	// defaultAssetCacheDir is where fetched assets are stored when no
+	// cache directory is configured.
+	defaultAssetCacheDir = "/var/cache/kata-containers/assets"
+
+	assetFetchTimeout = 10 * time.Minute
+)
+
+// for mocking in unit tests
+var assetHTTPClient = &http.Client{Timeout: assetFetchTimeout}
+
+// AssetSource describes the expected content of a guest asset and,
+// optionally, where to fetch it from.
+type AssetSource struct {
+	// Digest is the expected digest of the asset, as "<algorithm>:<hex>"
+	// where algorithm is sha256 or sha512.
+	Digest string
+
+	// URL is a http or https location of the asset. It's fetched into
+	// the cache when the configured asset is missing or doesn't match
+	// its digest.
+	URL string
+}
+
+// AssetRegistryConfig lists the assets verified at sandbox creation.
+type AssetRegistryConfig struct {
+	// CacheDir is the directory storing the fetched assets.
+	CacheDir string
+
+	// Assets are the expected kernel, image, initrd, firmware, hypervisor
+	// and jailer. Only the guest assets can be fetched.
+	Assets map[types.AssetType]AssetSource
+
+	// Manifest is the path of a file in the sha256sum or sha512sum
+	// format, providing the digest of the configured assets missing
+	// from Assets.
+	Manifest string
+}
+
+// registryAssetTypes are the asset types the registry can manage
+var registryAssetTypes = []types.AssetType{
+	types.KernelAsset,
+	types.ImageAsset,
+	types.InitrdAsset,
+	types.FirmwareAsset,
+	types.HypervisorAsset,
+	types.JailerAsset,
+}
+
+// IsFetchableAsset returns whether an asset of type t can be fetched from
+// an url. The host binaries run outside of the VM are never downloaded.
+func IsFetchableAsset(t types.AssetType) bool {
+	return t != types.HypervisorAsset && t != types.JailerAsset
+}
+
+// ParseAssetDigest splits a "<algorithm>:<hex>" digest, it returns an error
+// if the algorithm is not supported or the hex value is malformed.
+func ParseAssetDigest(digest string) (string, string, error) {
+	fields := strings.SplitN(digest, ":", 2)
+	if len(fields) != 2 {
+		return "", "", fmt.Errorf("Invalid asset digest %q, expecting <algorithm>:<hex>", digest)
+	}
+
+	algorithm, value := fields[0], strings.ToLower(fields[1])
+
+	h, err := newAssetHash(algorithm)
+	if err != nil {
+		return "", "", err
+	}
+
+	if _, err := hex.DecodeString(value); err != nil || len(value) != hex.EncodedLen(h.Size()) {
+		return "", "", fmt.Errorf("Invalid %s value in asset digest %q", algorithm, digest)
+	}
+
+	return algorithm, value, nil
+}
+
+func newAssetHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	}
+
+	return nil, fmt.Errorf("Unsupported asset digest algorithm %q", algorithm)
+}
+
+// computeAssetDigest returns the hex digest of the file at path
+func computeAssetDigest(path, algorithm string) (string, error) {
+	h, err := newAssetHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	// guest images are big, don't read them in memory
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// verifyAsset returns an error if the file at path doesn't match digest
+func verifyAsset(path, digest string) error {
+	algorithm, expected, err := ParseAssetDigest(digest)
+	if err != nil {
+		return err
+	}
+
+	computed, err := computeAssetDigest(path, algorithm)
+	if err != nil {
+		return err
+	}
+
+	if computed != expected {
+		return fmt.Errorf("Invalid digest for %s: computed %s:%s, expecting %s", path, algorithm, computed, digest)
+	}
+
+	return nil
+}
+
+// readAssetManifest parses the sha256sum or sha512sum formatted manifest at
+// path and returns the digests of the files it lists, keyed by path.
+func readAssetManifest(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Could not read asset manifest: %v", err)
+	}
+	defer f.Close()
+
+	digests := make(map[string]string)
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("Invalid asset manifest %s line %d", path, line)
+		}
+
+		var algorithm string
+		switch len(fields[0]) {
+		case hex.EncodedLen(sha256.Size):
+			algorithm = "sha256"
+		case hex.EncodedLen(sha512.Size):
+			algorithm = "sha512"
+		default:
+			return nil, fmt.Errorf("Invalid digest in asset manifest %s line %d", path, line)
+		}
+
+		digest := algorithm + ":" + fields[0]
+		if _, _, err := ParseAssetDigest(digest); err != nil {
+			return nil, fmt.Errorf("Asset manifest %s line %d: %v", path, line, err)
+		}
+
+		// "*" marks the files hashed in binary mode
+		digests[filepath.Clean(strings.TrimPrefix(fields[1], "*"))] = digest
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return digests, nil
+}
+
+// fetchAsset downloads the asset described by source into cacheDir and
+// returns its path. An already cached asset is not downloaded again.
+func fetchAsset(source AssetSource, cacheDir string) (string, error) {
+	algorithm, value, err := ParseAssetDigest(source.Digest)
+	if err != nil {
+		return "", err
+	}
+
+	// the cache is content addressed, a cached asset can only be stale
+	// if it has been tampered with
+	path := filepath.Join(cacheDir, algorithm, value)
+	if verifyAsset(path, source.Digest) == nil {
+		return path, nil
+	}
+
+	if !strings.HasPrefix(source.URL, "http://") && !strings.HasPrefix(source.URL, "https://") {
+		return "", fmt.Errorf("Unsupported asset URL %q", source.URL)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), DirMode); err != nil {
+		return "", err
+	}
+
+	resp, err := assetHTTPClient.Get(source.URL)
+	if err != nil {
+		return "", fmt.Errorf("Could not fetch asset %s: %v", source.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Could not fetch asset %s: %s", source.URL, resp.Status)
+	}
+
+	// download next to the final file so that concurrent sandboxes never
+	// see a partial asset
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".fetch-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, resp.Body)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", fmt.Errorf("Could not fetch asset %s: %v", source.URL, err)
+	}
+
+	if err := verifyAsset(tmp.Name(), source.Digest); err != nil {
+		return "", fmt.Errorf("Fetched asset %s: %v", source.URL, err)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	virtLog.WithField("url", source.URL).WithField("path", path).Info("Asset fetched")
+
+	return path, nil
+}
+
+// applyAssetRegistry verifies the configured assets against the registry,
+// fetching the missing or mismatching ones when they have a URL. Assets
+// overridden through annotations are left alone, they carry their own
+// hash annotation.
+func applyAssetRegistry(registry AssetRegistryConfig, conf *HypervisorConfig) error {
+	cacheDir := registry.CacheDir
+	if cacheDir == "" {
+		cacheDir = defaultAssetCacheDir
+	}
+
+	var manifest map[string]string
+	if registry.Manifest != "" {
+		var err error
+		if manifest, err = readAssetManifest(registry.Manifest); err != nil {
+			return err
+		}
+	}
+
+	for _, t := range registryAssetTypes {
+		if conf.isCustomAsset(t) {
+			continue
+		}
+
+		path, err := conf.assetPath(t)
+		if err != nil {
+			return err
+		}
+
+		source, ok := registry.Assets[t]
+		if !ok {
+			// the manifest only covers the assets it lists
+			digest, listed := manifest[filepath.Clean(path)]
+			if path == "" || !listed {
+				continue
+			}
+			source = AssetSource{Digest: digest}
+		}
+
+		if path != "" {
+			err = verifyAsset(path, source.Digest)
+			if err == nil {
+				continue
+			}
+		} else {
+			err = fmt.Errorf("No %s configured", t)
+		}
+
+		if source.URL == "" || !IsFetchableAsset(t) {
+			return fmt.Errorf("Could not verify %s: %v", t, err)
+		}
+
+		virtLog.WithError(err).WithField("asset", t).Info("Using the asset from the registry")
+
+		path, err = fetchAsset(source, cacheDir)
+		if err != nil {
+			return err
+		}
+
+		if err := conf.setAssetPath(t, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,221 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func assetSha256(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestParseAssetDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	algorithm, value, err := ParseAssetDigest("sha256:" + strings.Repeat("AB", 32))
+	assert.NoError(err)
+	assert.Equal("sha256", algorithm)
+	assert.Equal(strings.Repeat("ab", 32), value)
+
+	_, _, err = ParseAssetDigest("sha512:" + strings.Repeat("ab", 64))
+	assert.NoError(err)
+
+	for _, d := range []string{"", "sha256", "md5:" + strings.Repeat("ab", 16), "sha256:" + strings.Repeat("ab", 64), "sha256:" + strings.Repeat("zz", 32)} {
+		_, _, err = ParseAssetDigest(d)
+		assert.Error(err, "digest %q", d)
+	}
+}
+
+func TestVerifyAsset(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "assets")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	content := []byte("kernel")
+	path := filepath.Join(dir, "vmlinux")
+	assert.NoError(ioutil.WriteFile(path, content, 0644))
+
+	assert.NoError(verifyAsset(path, assetSha256(content)))
+	assert.Error(verifyAsset(path, assetSha256([]byte("other"))))
+	assert.Error(verifyAsset(filepath.Join(dir, "missing"), assetSha256(content)))
+}
+
+func TestApplyAssetRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "assets")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	content := []byte("kernel")
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/vmlinux" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(content)
+	}))
+	defer srv.Close()
+
+	kernelPath := filepath.Join(dir, "vmlinux")
+	assert.NoError(ioutil.WriteFile(kernelPath, []byte("stale kernel"), 0644))
+
+	registry := AssetRegistryConfig{
+		CacheDir: filepath.Join(dir, "cache"),
+		Assets: map[types.AssetType]AssetSource{
+			types.KernelAsset: {Digest: assetSha256(content)},
+		},
+	}
+
+	// mismatch without url
+	conf := &HypervisorConfig{KernelPath: kernelPath}
+	assert.Error(applyAssetRegistry(registry, conf))
+
+	// mismatch fetched from the url
+	registry.Assets[types.KernelAsset] = AssetSource{Digest: assetSha256(content), URL: srv.URL + "/vmlinux"}
+	assert.NoError(applyAssetRegistry(registry, conf))
+	assert.NotEqual(kernelPath, conf.KernelPath)
+	assert.True(strings.HasPrefix(conf.KernelPath, registry.CacheDir))
+	assert.NoError(verifyAsset(conf.KernelPath, assetSha256(content)))
+	assert.Equal(1, requests)
+
+	// cached asset is reused
+	conf = &HypervisorConfig{}
+	assert.NoError(applyAssetRegistry(registry, conf))
+	assert.NoError(verifyAsset(conf.KernelPath, assetSha256(content)))
+	assert.Equal(1, requests)
+
+	// matching asset is left alone
+	assert.NoError(ioutil.WriteFile(kernelPath, content, 0644))
+	conf = &HypervisorConfig{KernelPath: kernelPath}
+	assert.NoError(applyAssetRegistry(registry, conf))
+	assert.Equal(kernelPath, conf.KernelPath)
+
+	// fetched asset not matching its digest
+	registry.Assets[types.ImageAsset] = AssetSource{Digest: assetSha256([]byte("image")), URL: srv.URL + "/vmlinux"}
+	conf = &HypervisorConfig{KernelPath: kernelPath}
+	assert.Error(applyAssetRegistry(registry, conf))
+	assert.Empty(conf.ImagePath)
+
+	// fetch failure
+	registry.Assets[types.ImageAsset] = AssetSource{Digest: assetSha256([]byte("image")), URL: srv.URL + "/image"}
+	assert.Error(applyAssetRegistry(registry, conf))
+
+	// unsupported url
+	registry.Assets[types.ImageAsset] = AssetSource{Digest: assetSha256([]byte("image")), URL: "file:///image"}
+	assert.Error(applyAssetRegistry(registry, conf))
+}
+
+func TestReadAssetManifest(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "assets")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sha256Hex := strings.Repeat("ab", 32)
+	sha512Hex := strings.Repeat("cd", 64)
+
+	manifest := filepath.Join(dir, "SHA256SUMS")
+	assert.NoError(ioutil.WriteFile(manifest, []byte("# kata assets\n"+
+		sha256Hex+"  /usr/bin/qemu-system-x86_64\n\n"+
+		sha512Hex+" */usr/share/kata-containers//vmlinux\n"), 0644))
+
+	digests, err := readAssetManifest(manifest)
+	assert.NoError(err)
+	assert.Equal(map[string]string{
+		"/usr/bin/qemu-system-x86_64":        "sha256:" + sha256Hex,
+		"/usr/share/kata-containers/vmlinux": "sha512:" + sha512Hex,
+	}, digests)
+
+	for _, content := range []string{"abcd  /vmlinux\n", sha256Hex + "\n", strings.Repeat("zz", 32) + "  /vmlinux\n"} {
+		assert.NoError(ioutil.WriteFile(manifest, []byte(content), 0644))
+		_, err = readAssetManifest(manifest)
+		assert.Error(err, "manifest %q", content)
+	}
+
+	_, err = readAssetManifest(filepath.Join(dir, "missing"))
+	assert.Error(err)
+}
+
+func TestApplyAssetRegistryHostBinaries(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "assets")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	content := []byte("hypervisor")
+	hypervisorPath := filepath.Join(dir, "qemu")
+	assert.NoError(ioutil.WriteFile(hypervisorPath, content, 0755))
+
+	manifest := filepath.Join(dir, "SHA256SUMS")
+	assert.NoError(ioutil.WriteFile(manifest, []byte(strings.TrimPrefix(assetSha256(content), "sha256:")+"  "+hypervisorPath+"\n"), 0644))
+
+	registry := AssetRegistryConfig{Manifest: manifest}
+
+	// the jailer is not listed
+	conf := &HypervisorConfig{HypervisorPath: hypervisorPath, JailerPath: filepath.Join(dir, "jailer")}
+	assert.NoError(applyAssetRegistry(registry, conf))
+
+	assert.NoError(ioutil.WriteFile(hypervisorPath, []byte("tampered"), 0755))
+	assert.Error(applyAssetRegistry(registry, conf))
+
+	// the registry digests take precedence over the manifest
+	registry.Assets = map[types.AssetType]AssetSource{
+		types.HypervisorAsset: {Digest: assetSha256([]byte("tampered"))},
+	}
+	assert.NoError(applyAssetRegistry(registry, conf))
+
+	// the host binaries are not fetched
+	registry.Assets[types.JailerAsset] = AssetSource{Digest: assetSha256([]byte("jailer")), URL: "https://example.com/jailer"}
+	assert.Error(applyAssetRegistry(registry, conf))
+	assert.Equal(filepath.Join(dir, "jailer"), conf.JailerPath)
+}
+
+func TestCreateAssetsRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "assets")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	kernelPath := filepath.Join(dir, "vmlinux")
+	assert.NoError(ioutil.WriteFile(kernelPath, []byte("kernel"), 0644))
+
+	p := &SandboxConfig{
+		HypervisorConfig: HypervisorConfig{KernelPath: kernelPath},
+		AssetRegistry: AssetRegistryConfig{
+			CacheDir: dir,
+			Assets: map[types.AssetType]AssetSource{
+				types.KernelAsset: {Digest: assetSha256([]byte("other kernel"))},
+			},
+		},
+	}
+
+	assert.Error(createAssets(context.Background(), p))
+
+	p.AssetRegistry.Assets[types.KernelAsset] = AssetSource{Digest: assetSha256([]byte("kernel"))}
+	assert.NoError(createAssets(context.Background(), p))
+}
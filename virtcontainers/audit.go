@@ -0,0 +1,201 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"encoding/json"
+	"log/syslog"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/api"
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+)
+
+const (
+	// auditJournalSink sends the audit events to the system log,
+	// collected by the journal on systemd hosts.
+	auditJournalSink = "journal"
+
+	auditSyslogTag = "kata-runtime-audit"
+
+	auditSandboxCreate   = "createSandbox"
+	auditSandboxStart    = "startSandbox"
+	auditSandboxStop     = "stopSandbox"
+	auditSandboxReboot   = "rebootSandbox"
+	auditDeviceHotplug   = "hotplugDevice"
+	auditDeviceHotunplug = "hotunplugDevice"
+)
+
+// auditHypervisor describes the VM of an audit event.
+type auditHypervisor struct {
+	Type     HypervisorType `json:"type"`
+	Path     string         `json:"path,omitempty"`
+	Pid      int            `json:"pid,omitempty"`
+	Kernel   string         `json:"kernel,omitempty"`
+	Image    string         `json:"image,omitempty"`
+	Initrd   string         `json:"initrd,omitempty"`
+	VCPUs    uint32         `json:"vcpus"`
+	MemoryMB uint32         `json:"memory_mb"`
+}
+
+// auditDevice describes the device hot(un)plugged by an audit event.
+type auditDevice struct {
+	ID       string            `json:"id"`
+	Type     config.DeviceType `json:"type"`
+	HostPath string            `json:"host_path,omitempty"`
+}
+
+// auditEvent is a record of the audit log, JSON encoded on a single line.
+type auditEvent struct {
+	Time         string          `json:"time"`
+	Event        string          `json:"event"`
+	SandboxID    string          `json:"sandbox_id"`
+	User         string          `json:"user"`
+	PodUID       string          `json:"pod_uid,omitempty"`
+	PodName      string          `json:"pod_name,omitempty"`
+	PodNamespace string          `json:"pod_namespace,omitempty"`
+	Image        string          `json:"image,omitempty"`
+	Hypervisor   auditHypervisor `json:"hypervisor"`
+	Device       *auditDevice    `json:"device,omitempty"`
+	Error        string          `json:"error,omitempty"`
+}
+
+// auditUser returns the user running the runtime, by name if it can be
+// resolved.
+func auditUser() string {
+	uid := strconv.Itoa(os.Getuid())
+
+	if u, err := user.LookupId(uid); err == nil {
+		return u.Username
+	}
+
+	return uid
+}
+
+func newAuditDevice(device api.Device, devType config.DeviceType) *auditDevice {
+	d := &auditDevice{
+		ID:   device.DeviceID(),
+		Type: devType,
+	}
+
+	switch info := device.GetDeviceInfo().(type) {
+	case *config.BlockDrive:
+		d.HostPath = info.File
+	case []*config.VFIODev:
+		var bdfs []string
+		for _, dev := range info {
+			bdfs = append(bdfs, dev.BDF)
+		}
+		d.HostPath = strings.Join(bdfs, ",")
+	case *config.VhostUserDeviceAttrs:
+		d.HostPath = info.SocketPath
+	}
+
+	return d
+}
+
+// sandboxContainerAnnotations returns the annotations of the sandbox
+// container, the first container being used outside of a pod.
+func (s *Sandbox) sandboxContainerAnnotations() map[string]string {
+	for _, c := range s.config.Containers {
+		if c.Annotations[vcAnnotations.ContainerTypeKey] == string(PodSandbox) {
+			return c.Annotations
+		}
+	}
+
+	if len(s.config.Containers) > 0 {
+		return s.config.Containers[0].Annotations
+	}
+
+	return nil
+}
+
+// newAuditEvent builds the audit event of the sandbox, the pod and image
+// details being the ones of its sandbox container.
+func (s *Sandbox) newAuditEvent(event string, err error) auditEvent {
+	hConfig := s.config.HypervisorConfig
+
+	e := auditEvent{
+		Time:      time.Now().UTC().Format(time.RFC3339Nano),
+		Event:     event,
+		SandboxID: s.id,
+		User:      auditUser(),
+		Hypervisor: auditHypervisor{
+			Type:     s.config.HypervisorType,
+			Path:     hConfig.HypervisorPath,
+			Kernel:   hConfig.KernelPath,
+			Image:    hConfig.ImagePath,
+			Initrd:   hConfig.InitrdPath,
+			VCPUs:    hConfig.NumVCPUs,
+			MemoryMB: hConfig.MemorySize,
+		},
+	}
+
+	if s.hypervisor != nil {
+		if pids := s.hypervisor.getPids(); len(pids) > 0 {
+			e.Hypervisor.Pid = pids[0]
+		}
+	}
+
+	annotations := s.sandboxContainerAnnotations()
+	e.PodUID = annotations[vcAnnotations.PodUIDKey]
+	e.PodName = annotations[vcAnnotations.PodNameKey]
+	e.PodNamespace = annotations[vcAnnotations.PodNamespaceKey]
+	e.Image = annotations[vcAnnotations.ContainerImageKey]
+
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	return e
+}
+
+// writeAuditEvent appends the event to the audit log sink.
+func writeAuditEvent(sink string, e auditEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	if sink == auditJournalSink {
+		w, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, auditSyslogTag)
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+
+		return w.Info(string(data))
+	}
+
+	f, err := os.OpenFile(sink, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// audit records the lifecycle event of the sandbox, err being the result of
+// the operation. Failing to record the event doesn't fail the operation.
+func (s *Sandbox) audit(event string, device *auditDevice, err error) {
+	if s.config == nil || s.config.AuditLog == "" {
+		return
+	}
+
+	e := s.newAuditEvent(event, err)
+	e.Device = device
+
+	if werr := writeAuditEvent(s.config.AuditLog, e); werr != nil {
+		s.Logger().WithError(werr).WithField("event", event).Warn("Could not record audit event")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/kata-containers/runtime/virtcontainers/device/drivers"
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxAudit(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	auditLog := filepath.Join(tmpdir, "audit.log")
+
+	s := &Sandbox{
+		id: testSandboxID,
+		config: &SandboxConfig{
+			HypervisorType: MockHypervisor,
+			HypervisorConfig: HypervisorConfig{
+				KernelPath: "/usr/share/kata-containers/vmlinuz",
+				NumVCPUs:   1,
+				MemorySize: 2048,
+			},
+			Containers: []ContainerConfig{
+				{
+					ID: "sandbox",
+					Annotations: map[string]string{
+						vcAnnotations.ContainerTypeKey:  string(PodSandbox),
+						vcAnnotations.PodUIDKey:         "6f3c2a1e",
+						vcAnnotations.PodNameKey:        "nginx",
+						vcAnnotations.PodNamespaceKey:   "default",
+						vcAnnotations.ContainerImageKey: "k8s.gcr.io/pause:3.1",
+					},
+				},
+			},
+		},
+		hypervisor: &mockHypervisor{mockPid: 1234},
+	}
+
+	// no event is recorded when there is no sink
+	s.audit(auditSandboxStart, nil, nil)
+	_, err = os.Stat(auditLog)
+	assert.True(os.IsNotExist(err))
+
+	s.config.AuditLog = auditLog
+
+	device := &drivers.BlockDevice{
+		GenericDevice: &drivers.GenericDevice{ID: "foo"},
+		BlockDrive:    &config.BlockDrive{File: "/dev/sdb"},
+	}
+
+	s.audit(auditSandboxStart, nil, nil)
+	s.audit(auditDeviceHotplug, newAuditDevice(device, config.DeviceBlock), errors.New("hotplug failed"))
+
+	f, err := os.Open(auditLog)
+	assert.NoError(err)
+	defer f.Close()
+
+	var events []auditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e auditEvent
+		assert.NoError(json.Unmarshal(scanner.Bytes(), &e))
+		events = append(events, e)
+	}
+	assert.NoError(scanner.Err())
+	assert.Len(events, 2)
+
+	e := events[0]
+	assert.Equal(auditSandboxStart, e.Event)
+	assert.Equal(testSandboxID, e.SandboxID)
+	assert.NotEmpty(e.User)
+	assert.Equal("6f3c2a1e", e.PodUID)
+	assert.Equal("nginx", e.PodName)
+	assert.Equal("default", e.PodNamespace)
+	assert.Equal("k8s.gcr.io/pause:3.1", e.Image)
+	assert.Equal(MockHypervisor, e.Hypervisor.Type)
+	assert.Equal(1234, e.Hypervisor.Pid)
+	assert.Equal("/usr/share/kata-containers/vmlinuz", e.Hypervisor.Kernel)
+	assert.Equal(uint32(2048), e.Hypervisor.MemoryMB)
+	assert.Nil(e.Device)
+	assert.Empty(e.Error)
+
+	e = events[1]
+	assert.Equal(auditDeviceHotplug, e.Event)
+	assert.Equal(&auditDevice{ID: "foo", Type: config.DeviceBlock, HostPath: "/dev/sdb"}, e.Device)
+	assert.Equal("hotplug failed", e.Error)
+}
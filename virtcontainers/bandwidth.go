@@ -0,0 +1,153 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+
+	"github.com/vishvananda/netlink"
+)
+
+const (
+	// handles of the shaping qdiscs, "1:" is the HTB root, "1:1" its
+	// only class and "10:" the fq_codel leaf queueing the class traffic.
+	bandwidthHtbMajor     = 1
+	bandwidthClassMinor   = 1
+	bandwidthFqCodelMajor = 0x10
+)
+
+// setupBandwidthLimits shapes the traffic of the endpoint on the host side,
+// whatever the guest network configuration. ingress is the rate in bits per
+// second of the traffic going to the sandbox, egress the rate of the traffic
+// it sends, 0 meaning unlimited.
+//
+// The traffic to the sandbox is shaped when it's transmitted to the VM by
+// the TAP device, which is only possible when the TAP is not a macvtap. The
+// traffic from the sandbox is shaped when transmitted by the endpoint link.
+func setupBandwidthLimits(endpoint Endpoint, ingress, egress uint64) error {
+	if ingress == 0 && egress == 0 {
+		return nil
+	}
+
+	netPair := endpoint.NetworkPair()
+	if netPair == nil {
+		return fmt.Errorf("Bandwidth limits are not supported for %s endpoints", endpoint.Type())
+	}
+
+	netHandle, err := netlink.NewHandle()
+	if err != nil {
+		return err
+	}
+	defer netHandle.Delete()
+
+	if ingress != 0 {
+		if netPair.NetInterworkingModel != NetXConnectTCFilterModel && netPair.NetInterworkingModel != NetXConnectEBPFModel {
+			return fmt.Errorf("Ingress bandwidth limits require the %s or %s internetworking model", tcFilterNetModelStr, ebpfNetModelStr)
+		}
+
+		tapLink, err := getLinkByName(netHandle, netPair.TAPIface.Name, &netlink.Tuntap{})
+		if err != nil {
+			return fmt.Errorf("Could not get TAP interface: %s", err)
+		}
+
+		if err := addBandwidthQdisc(netHandle, tapLink, ingress); err != nil {
+			return err
+		}
+	}
+
+	if egress != 0 {
+		link, err := getLinkForEndpoint(endpoint, netHandle)
+		if err != nil {
+			return err
+		}
+
+		if err := addBandwidthQdisc(netHandle, link, egress); err != nil {
+			return err
+		}
+	}
+
+	networkLogger().WithField("endpoint", endpoint.Name()).WithField("ingress", ingress).
+		WithField("egress", egress).Info("Bandwidth limits applied")
+
+	return nil
+}
+
+// addBandwidthQdisc limits the rate in bits per second of the traffic
+// transmitted by "link", replacing any limit previously set.
+//
+// This is equivalent to calling:
+// `tc qdisc replace dev dev root handle 1: htb default 1`
+// `tc class add dev dev parent 1: classid 1:1 htb rate rate`
+// `tc qdisc add dev dev parent 1:1 handle 10: fq_codel`
+func addBandwidthQdisc(netHandle *netlink.Handle, link netlink.Link, rate uint64) error {
+	// htb doesn't support being changed in place
+	if err := removeBandwidthQdisc(netHandle, link); err != nil {
+		return err
+	}
+
+	index := link.Attrs().Index
+	htb := netlink.NewHtb(netlink.QdiscAttrs{
+		LinkIndex: index,
+		Handle:    netlink.MakeHandle(bandwidthHtbMajor, 0),
+		Parent:    netlink.HANDLE_ROOT,
+	})
+	htb.Defcls = bandwidthClassMinor
+
+	if err := netHandle.QdiscReplace(htb); err != nil {
+		return fmt.Errorf("Failed to add htb qdisc for network index %d : %s", index, err)
+	}
+
+	class := netlink.NewHtbClass(netlink.ClassAttrs{
+		LinkIndex: index,
+		Parent:    htb.Handle,
+		Handle:    netlink.MakeHandle(bandwidthHtbMajor, bandwidthClassMinor),
+	}, netlink.HtbClassAttrs{
+		Rate: rate,
+	})
+
+	if err := netHandle.ClassAdd(class); err != nil {
+		return fmt.Errorf("Failed to add htb class for network index %d : %s", index, err)
+	}
+
+	// fair queueing within the limit, so that a bulk transfer doesn't
+	// add latency to the other flows of the sandbox. The class keeps its
+	// default pfifo leaf on kernels built without fq_codel.
+	fqCodel := netlink.NewFqCodel(netlink.QdiscAttrs{
+		LinkIndex: index,
+		Handle:    netlink.MakeHandle(bandwidthFqCodelMajor, 0),
+		Parent:    class.Handle,
+	})
+
+	if err := netHandle.QdiscAdd(fqCodel); err != nil {
+		networkLogger().WithError(err).WithField("index", index).
+			Warn("Could not add fq_codel qdisc, using pfifo")
+	}
+
+	return nil
+}
+
+// removeBandwidthQdisc removes the shaping qdiscs previously created on
+// "link", the default root qdisc is restored by the kernel.
+func removeBandwidthQdisc(netHandle *netlink.Handle, link netlink.Link) error {
+	qdiscs, err := netHandle.QdiscList(link)
+	if err != nil {
+		return err
+	}
+
+	for _, qdisc := range qdiscs {
+		htb, ok := qdisc.(*netlink.Htb)
+		if !ok || htb.Handle != netlink.MakeHandle(bandwidthHtbMajor, 0) || htb.Parent != netlink.HANDLE_ROOT {
+			continue
+		}
+
+		// the class and the fq_codel leaf are removed along
+		if err := netHandle.QdiscDel(htb); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
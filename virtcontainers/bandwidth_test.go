@@ -0,0 +1,105 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"testing"
+
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+func hasBandwidthQdisc(netHandle *netlink.Handle, link netlink.Link) (bool, error) {
+	qdiscs, err := netHandle.QdiscList(link)
+	if err != nil {
+		return false, err
+	}
+
+	for _, qdisc := range qdiscs {
+		if _, ok := qdisc.(*netlink.Htb); ok && qdisc.Attrs().Parent == netlink.HANDLE_ROOT {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func TestBandwidthLimitsNoLimits(t *testing.T) {
+	assert.NoError(t, setupBandwidthLimits(&PhysicalEndpoint{}, 0, 0))
+}
+
+func TestBandwidthLimitsNoNetworkPair(t *testing.T) {
+	assert.Error(t, setupBandwidthLimits(&PhysicalEndpoint{}, 1000, 1000))
+}
+
+func TestBandwidthLimitsIngressMacvtap(t *testing.T) {
+	endpoint, err := createVethNetworkEndpoint(1, "foo", NetXConnectMacVtapModel)
+	assert.NoError(t, err)
+
+	assert.Error(t, setupBandwidthLimits(endpoint, 1000, 0))
+}
+
+func TestBandwidthLimits(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	netHandle, err := netlink.NewHandle()
+	assert.NoError(err)
+	defer netHandle.Delete()
+
+	// Create a test veth interface.
+	vethName := "foo"
+	veth := &netlink.Veth{LinkAttrs: netlink.LinkAttrs{Name: vethName, TxQLen: 200, MTU: 1400}, PeerName: "bar"}
+
+	err = netlink.LinkAdd(veth)
+	assert.NoError(err)
+
+	endpoint, err := createVethNetworkEndpoint(1, vethName, NetXConnectTCFilterModel)
+	assert.NoError(err)
+
+	link, err := netlink.LinkByName(vethName)
+	assert.NoError(err)
+
+	err = netHandle.LinkSetUp(link)
+	assert.NoError(err)
+
+	err = setupTCFiltering(endpoint, 1, true)
+	assert.NoError(err)
+
+	err = setupBandwidthLimits(endpoint, 10000000, 1000000)
+	assert.NoError(err)
+
+	tapLink, err := netlink.LinkByName(endpoint.NetworkPair().TAPIface.Name)
+	assert.NoError(err)
+
+	for _, l := range []netlink.Link{link, tapLink} {
+		shaped, err := hasBandwidthQdisc(netHandle, l)
+		assert.NoError(err)
+		assert.True(shaped)
+	}
+
+	// Applying the limits again replaces the previous ones.
+	err = setupBandwidthLimits(endpoint, 0, 2000000)
+	assert.NoError(err)
+
+	err = removeBandwidthQdisc(netHandle, link)
+	assert.NoError(err)
+
+	shaped, err := hasBandwidthQdisc(netHandle, link)
+	assert.NoError(err)
+	assert.False(shaped)
+
+	err = removeTCFiltering(endpoint)
+	assert.NoError(err)
+
+	// Remove the veth created for testing.
+	err = netHandle.LinkDel(link)
+	assert.NoError(err)
+}
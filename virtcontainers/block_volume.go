@@ -0,0 +1,211 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/volume"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// The ways the volumes are passed to the VM the annotations of a container
+// can request, see volumePolicy().
+const (
+	volumePolicyBlock    = "block"
+	volumePolicySharedFS = "shared-fs"
+)
+
+// volumePolicy returns how the volume mounted at destination is passed to
+// the VM as requested by the annotations of the container, empty when it's
+// decided by the size of the volume.
+func (c *Container) volumePolicy(destination string) string {
+	listed := func(key string) bool {
+		for _, d := range strings.Split(c.config.Annotations[key], ",") {
+			if d = strings.TrimSpace(d); d != "" && filepath.Clean(d) == filepath.Clean(destination) {
+				return true
+			}
+		}
+		return false
+	}
+
+	switch {
+	case listed(annotations.SharedFSVolumes):
+		return volumePolicySharedFS
+	case listed(annotations.BlockVolumes):
+		return volumePolicyBlock
+	}
+
+	return ""
+}
+
+// hostBlockMount returns the block device and the filesystem mounted at
+// path on the host, empty when path is not the root of the filesystem of a
+// block device.
+func hostBlockMount(path string) (string, string, error) {
+	absPath, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", "", err
+	}
+
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return "", "", err
+	}
+	defer f.Close()
+
+	var root, device, fsType string
+
+	// the last mount on path is the one visible
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || mountInfoUnescaper.Replace(fields[4]) != absPath {
+			continue
+		}
+
+		// the optional fields end with a separator
+		sep := 6
+		for sep < len(fields) && fields[sep] != "-" {
+			sep++
+		}
+		if sep+2 >= len(fields) {
+			continue
+		}
+
+		root = fields[3]
+		fsType = fields[sep+1]
+		device = mountInfoUnescaper.Replace(fields[sep+2])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	// a bind mount of a directory of the filesystem is not the content
+	// of the device
+	if device == "" || root != "/" {
+		return "", "", nil
+	}
+
+	var stat unix.Stat_t
+	if err := unix.Stat(device, &stat); err != nil || stat.Mode&unix.S_IFMT != unix.S_IFBLK {
+		return "", "", nil
+	}
+
+	return device, fsType, nil
+}
+
+// blockDeviceSize returns the size in bytes of the block device.
+func blockDeviceSize(device string) (uint64, error) {
+	f, err := os.Open(device)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint64(size), nil
+}
+
+// blockVolumeOptions returns the options the agent mounts a block volume
+// with. The host keeps the filesystem mounted, the guest mustn't replay its
+// journal.
+func blockVolumeOptions(fsType string) []string {
+	switch fsType {
+	case "ext3", "ext4":
+		return []string{"ro", "noload"}
+	case "xfs":
+		return []string{"ro", "norecovery"}
+	}
+
+	return []string{"ro"}
+}
+
+// blockVolume returns the block device and the filesystem of the volume
+// mounted by m when it's attached to the VM as a block device rather than
+// shared, as requested by the annotations of the container or because it's
+// larger than the block volume threshold. Only the read-only volumes which
+// are the root of the filesystem of a host block device can be.
+func (c *Container) blockVolume(m Mount) (string, string, error) {
+	policy := c.volumePolicy(m.Destination)
+	threshold := uint64(c.sandbox.config.HypervisorConfig.BlockVolumeThreshold) << 20
+
+	if policy == volumePolicySharedFS || (policy == "" && threshold == 0) {
+		return "", "", nil
+	}
+
+	device, fsType, err := hostBlockMount(m.Source)
+	if err != nil {
+		return "", "", err
+	}
+
+	readOnly := m.ReadOnly
+	for _, o := range m.Options {
+		if o == "ro" {
+			readOnly = true
+		}
+	}
+
+	if policy == volumePolicyBlock {
+		if device == "" {
+			return "", "", fmt.Errorf("Volume %s is not the root of the filesystem of a block device", m.Destination)
+		}
+		if !readOnly {
+			return "", "", fmt.Errorf("Volume %s must be read-only to be attached as a block device", m.Destination)
+		}
+
+		return device, fsType, nil
+	}
+
+	if device == "" || !readOnly {
+		return "", "", nil
+	}
+
+	size, err := blockDeviceSize(device)
+	if err != nil {
+		return "", "", err
+	}
+
+	if size < threshold {
+		return "", "", nil
+	}
+
+	return device, fsType, nil
+}
+
+// createBlockVolumeDevice creates the block device of the volume mounted by
+// c.mounts[idx], its filesystem being mounted read-only by the agent.
+func (c *Container) createBlockVolumeDevice(idx int, device, fsType string) error {
+	if err := c.createDirectVolumeDevice(idx, &volume.MountInfo{
+		VolumeType: volume.BlockVolumeType,
+		Device:     device,
+		FsType:     fsType,
+		Options:    blockVolumeOptions(fsType),
+	}); err != nil {
+		return err
+	}
+
+	c.mounts[idx].BlockFsType = fsType
+
+	c.Logger().WithFields(logrus.Fields{
+		"volume": c.mounts[idx].Destination,
+		"device": device,
+	}).Info("Volume attached as a block device")
+
+	return nil
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+// testBlockDevice returns a block device of the host, empty if there's none.
+func testBlockDevice() string {
+	for _, dev := range []string{"/dev/loop0", "/dev/vda", "/dev/sda", "/dev/nvme0n1"} {
+		var stat unix.Stat_t
+		if err := unix.Stat(dev, &stat); err == nil && stat.Mode&unix.S_IFMT == unix.S_IFBLK {
+			return dev
+		}
+	}
+
+	return ""
+}
+
+func TestVolumePolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	c := &Container{
+		config: &ContainerConfig{
+			Annotations: map[string]string{
+				annotations.BlockVolumes:    "/models, /images/",
+				annotations.SharedFSVolumes: "/config,/images",
+			},
+		},
+	}
+
+	assert.Equal(volumePolicyBlock, c.volumePolicy("/models"))
+	assert.Equal(volumePolicySharedFS, c.volumePolicy("/config"))
+	assert.Equal(volumePolicySharedFS, c.volumePolicy("/images"))
+	assert.Equal("", c.volumePolicy("/data"))
+	assert.Equal("", c.volumePolicy("/model"))
+}
+
+func TestHostBlockMount(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedMountInfoPath := mountInfoPath
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	defer func() {
+		mountInfoPath = savedMountInfoPath
+	}()
+
+	volume := filepath.Join(dir, "volume")
+	subdir := filepath.Join(dir, "subdir")
+	tmp := filepath.Join(dir, "tmp")
+	for _, d := range []string{volume, subdir, tmp} {
+		assert.NoError(os.Mkdir(d, 0750))
+	}
+
+	device := testBlockDevice()
+	assert.NoError(ioutil.WriteFile(mountInfoPath, []byte(fmt.Sprintf(`22 1 0:21 / %s rw shared:5 - tmpfs tmpfs rw
+23 1 253:1 / %s ro,relatime shared:6 - ext4 %s ro
+24 1 253:1 /data %s ro,relatime - ext4 %s ro
+25 1 0:22 / %s rw - tmpfs tmpfs rw
+`, volume, volume, device, subdir, device, tmp)), 0600))
+
+	// bind mounts of a directory of a filesystem, and filesystems not
+	// backed by a block device
+	for _, path := range []string{subdir, tmp} {
+		dev, fsType, err := hostBlockMount(path)
+		assert.NoError(err)
+		assert.Empty(dev)
+		assert.Empty(fsType)
+	}
+
+	_, _, err = hostBlockMount(filepath.Join(dir, "missing"))
+	assert.Error(err)
+
+	if device == "" {
+		t.Skip("no block device")
+	}
+
+	dev, fsType, err := hostBlockMount(volume)
+	assert.NoError(err)
+	assert.Equal(device, dev)
+	assert.Equal("ext4", fsType)
+}
+
+func TestBlockDeviceSize(t *testing.T) {
+	assert := assert.New(t)
+
+	f, err := ioutil.TempFile("", "")
+	assert.NoError(err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	assert.NoError(f.Truncate(4 << 20))
+
+	size, err := blockDeviceSize(f.Name())
+	assert.NoError(err)
+	assert.Equal(uint64(4<<20), size)
+
+	_, err = blockDeviceSize("/does/not/exist")
+	assert.Error(err)
+}
+
+func TestBlockVolumeOptions(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"ro", "noload"}, blockVolumeOptions("ext4"))
+	assert.Equal([]string{"ro", "norecovery"}, blockVolumeOptions("xfs"))
+	assert.Equal([]string{"ro"}, blockVolumeOptions("squashfs"))
+}
+
+func TestBlockVolume(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedMountInfoPath := mountInfoPath
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	defer func() {
+		mountInfoPath = savedMountInfoPath
+	}()
+
+	device := testBlockDevice()
+	assert.NoError(ioutil.WriteFile(mountInfoPath, []byte(fmt.Sprintf(`23 1 253:1 / %s ro,relatime shared:6 - ext4 %s ro
+`, dir, device)), 0600))
+
+	c := &Container{
+		config: &ContainerConfig{
+			Annotations: map[string]string{
+				annotations.BlockVolumes:    "/models,/data",
+				annotations.SharedFSVolumes: "/config",
+			},
+		},
+		sandbox: &Sandbox{
+			config: &SandboxConfig{},
+		},
+	}
+
+	// shared whatever the threshold
+	dev, _, err := c.blockVolume(Mount{Source: dir, Destination: "/config", Options: []string{"ro"}})
+	assert.NoError(err)
+	assert.Empty(dev)
+
+	// no threshold
+	dev, _, err = c.blockVolume(Mount{Source: dir, Destination: "/other", Options: []string{"ro"}})
+	assert.NoError(err)
+	assert.Empty(dev)
+
+	// the volumes requested as block devices must be backed by one
+	_, _, err = c.blockVolume(Mount{Source: filepath.Join(dir, "missing"), Destination: "/models", Options: []string{"ro"}})
+	assert.Error(err)
+
+	if device == "" {
+		t.Skip("no block device")
+	}
+
+	_, _, err = c.blockVolume(Mount{Source: dir, Destination: "/data", Options: []string{"rw"}})
+	assert.Error(err)
+
+	dev, fsType, err := c.blockVolume(Mount{Source: dir, Destination: "/models", Options: []string{"rbind", "ro"}})
+	assert.NoError(err)
+	assert.Equal(device, dev)
+	assert.Equal("ext4", fsType)
+
+	// the read-only volumes larger than the threshold
+	c.sandbox.config.HypervisorConfig.BlockVolumeThreshold = 1
+	dev, _, err = c.blockVolume(Mount{Source: dir, Destination: "/other", Options: []string{"rw"}})
+	assert.NoError(err)
+	assert.Empty(dev)
+
+	size, sizeErr := blockDeviceSize(device)
+	dev, _, err = c.blockVolume(Mount{Source: dir, Destination: "/other", ReadOnly: true})
+	switch {
+	case sizeErr != nil:
+		assert.Error(err)
+	case size < 1<<20:
+		assert.NoError(err)
+		assert.Empty(dev)
+	default:
+		assert.NoError(err)
+		assert.Equal(device, dev)
+	}
+}
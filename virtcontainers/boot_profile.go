@@ -0,0 +1,161 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	opentracing "github.com/opentracing/opentracing-go"
+)
+
+// BootProfile selects the optional work done while a sandbox boots.
+type BootProfile string
+
+const (
+	// BootProfileDefault enables all the subsystems the configuration
+	// asks for.
+	BootProfileDefault BootProfile = "default"
+
+	// BootProfileFast minimizes the sandbox start latency, for short
+	// lived workloads. The hypervisor logs, metrics and console are not
+	// collected, tracing spans are not created, the hypervisor version
+	// is checked once per binary and redundant mounts are skipped.
+	BootProfileFast BootProfile = "fast"
+)
+
+// for mocking in unit tests
+var hypervisorVersionCacheDir = "/run/vc/cache/versions"
+
+// ParseBootProfile returns the boot profile named by value, an empty value
+// is the default profile.
+func ParseBootProfile(value string) (BootProfile, error) {
+	switch p := BootProfile(value); p {
+	case "":
+		return BootProfileDefault, nil
+	case BootProfileDefault, BootProfileFast:
+		return p, nil
+	}
+
+	return "", fmt.Errorf("Unknown boot profile %q, expecting %q or %q", value, BootProfileDefault, BootProfileFast)
+}
+
+type tracingDisabledKey struct{}
+
+// withoutTracing returns a copy of ctx for which no tracing span is created
+func withoutTracing(ctx context.Context) context.Context {
+	return context.WithValue(ctx, tracingDisabledKey{}, true)
+}
+
+// tracingSubsystems are the subsystems creating tracing spans.
+var tracingSubsystems = []string{"agent", "api", "container", "hypervisor", "network", "sandbox", "virtiofsd"}
+
+// tracingDisabledSubsystems holds the subsystems whose spans are not
+// created, whatever the context.
+var tracingDisabledSubsystems = map[string]bool{}
+
+// SetTracingDisabledSubsystems disables the tracing spans of the given
+// subsystems, all the other subsystems being traced.
+func SetTracingDisabledSubsystems(subsystems []string) error {
+	disabled := make(map[string]bool)
+	for _, subsystem := range subsystems {
+		found := false
+		for _, s := range tracingSubsystems {
+			if s == subsystem {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("Unknown tracing subsystem %q, expecting one of %s", subsystem, strings.Join(tracingSubsystems, ", "))
+		}
+
+		disabled[subsystem] = true
+	}
+
+	tracingDisabledSubsystems = disabled
+
+	return nil
+}
+
+// startSpanFromContext is opentracing.StartSpanFromContext unless tracing
+// has been disabled for ctx or subsystem, a no-op span is returned then.
+func startSpanFromContext(ctx context.Context, subsystem, name string) (opentracing.Span, context.Context) {
+	if tracingDisabledSubsystems[subsystem] {
+		return opentracing.NoopTracer{}.StartSpan(name), ctx
+	}
+
+	if ctx != nil {
+		if disabled, _ := ctx.Value(tracingDisabledKey{}).(bool); disabled {
+			return opentracing.NoopTracer{}.StartSpan(name), ctx
+		}
+	}
+
+	return opentracing.StartSpanFromContext(ctx, name)
+}
+
+func hypervisorVersionCachePath(path string) string {
+	sum := sha256.Sum256([]byte(path))
+	return filepath.Join(hypervisorVersionCacheDir, hex.EncodeToString(sum[:]))
+}
+
+// cachedHypervisorVersion returns the version of the hypervisor binary at
+// path. getVersion, which is expected to validate the version too, is only
+// called when the binary changed since the version was cached.
+func cachedHypervisorVersion(path string, getVersion func() (string, error)) (string, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	// Expected format: "<size> <modification time> <version>"
+	key := fmt.Sprintf("%d %d", st.Size(), st.ModTime().UnixNano())
+	cachePath := hypervisorVersionCachePath(path)
+
+	if data, err := ioutil.ReadFile(cachePath); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) == 3 && strings.Join(fields[:2], " ") == key {
+			return fields[2], nil
+		}
+	}
+
+	version, err := getVersion()
+	if err != nil {
+		return "", err
+	}
+
+	// the cache is an optimization, failing to update it is not fatal
+	if err := os.MkdirAll(hypervisorVersionCacheDir, DirMode); err != nil {
+		virtLog.WithError(err).Warn("Could not create the hypervisor version cache")
+		return version, nil
+	}
+
+	tmp, err := ioutil.TempFile(hypervisorVersionCacheDir, ".version-")
+	if err != nil {
+		virtLog.WithError(err).Warn("Could not cache the hypervisor version")
+		return version, nil
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = fmt.Fprintf(tmp, "%s %s\n", key, version)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), cachePath)
+	}
+	if err != nil {
+		virtLog.WithError(err).Warn("Could not cache the hypervisor version")
+	}
+
+	return version, nil
+}
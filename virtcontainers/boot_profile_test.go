@@ -0,0 +1,119 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	opentracing "github.com/opentracing/opentracing-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBootProfile(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := ParseBootProfile("")
+	assert.NoError(err)
+	assert.Equal(BootProfileDefault, p)
+
+	p, err = ParseBootProfile("default")
+	assert.NoError(err)
+	assert.Equal(BootProfileDefault, p)
+
+	p, err = ParseBootProfile("fast")
+	assert.NoError(err)
+	assert.Equal(BootProfileFast, p)
+
+	_, err = ParseBootProfile("Fast")
+	assert.Error(err)
+}
+
+func TestStartSpanFromContextWithoutTracing(t *testing.T) {
+	assert := assert.New(t)
+
+	ctx := withoutTracing(context.Background())
+	span, spanCtx := startSpanFromContext(ctx, "sandbox", "test")
+	defer span.Finish()
+
+	assert.Equal(ctx, spanCtx)
+	assert.Nil(opentracing.SpanFromContext(spanCtx))
+	assert.IsType(opentracing.NoopTracer{}, span.Tracer())
+}
+
+func TestStartSpanFromContextDisabledSubsystem(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Error(SetTracingDisabledSubsystems([]string{"foo"}))
+
+	assert.NoError(SetTracingDisabledSubsystems([]string{"agent"}))
+	defer SetTracingDisabledSubsystems(nil)
+
+	ctx := context.Background()
+	span, spanCtx := startSpanFromContext(ctx, "agent", "test")
+	defer span.Finish()
+
+	assert.Equal(ctx, spanCtx)
+	assert.IsType(opentracing.NoopTracer{}, span.Tracer())
+
+	span, spanCtx = startSpanFromContext(ctx, "sandbox", "test")
+	defer span.Finish()
+
+	assert.NotNil(opentracing.SpanFromContext(spanCtx))
+}
+
+func TestCachedHypervisorVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "boot-profile")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedDir := hypervisorVersionCacheDir
+	hypervisorVersionCacheDir = filepath.Join(dir, "cache")
+	defer func() {
+		hypervisorVersionCacheDir = savedDir
+	}()
+
+	binary := filepath.Join(dir, "firecracker")
+	assert.NoError(ioutil.WriteFile(binary, []byte("v1"), 0750))
+
+	calls := 0
+	getVersion := func() (string, error) {
+		calls++
+		return "0.21.1", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		version, err := cachedHypervisorVersion(binary, getVersion)
+		assert.NoError(err)
+		assert.Equal("0.21.1", version)
+	}
+	assert.Equal(1, calls)
+
+	// an updated binary is checked again
+	assert.NoError(ioutil.WriteFile(binary, []byte("v2 binary"), 0750))
+	future := time.Now().Add(time.Hour)
+	assert.NoError(os.Chtimes(binary, future, future))
+	_, err = cachedHypervisorVersion(binary, getVersion)
+	assert.NoError(err)
+	assert.Equal(2, calls)
+
+	// invalid versions are not cached
+	assert.NoError(os.Chtimes(binary, time.Now(), time.Now()))
+	_, err = cachedHypervisorVersion(binary, func() (string, error) {
+		return "", errors.New("unsupported version")
+	})
+	assert.Error(err)
+
+	_, err = cachedHypervisorVersion(filepath.Join(dir, "missing"), getVersion)
+	assert.Error(err)
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/types"
+)
+
+type bootEvent string
+
+const (
+	bootEventCreate              bootEvent = "create"
+	bootEventVMMStart            bootEvent = "vmm-start"
+	bootEventVMMReady            bootEvent = "vmm-ready"
+	bootEventGuestReady          bootEvent = "guest-ready"
+	bootEventAgentReady          bootEvent = "agent-ready"
+	bootEventFirstContainerStart bootEvent = "first-container-start"
+)
+
+// BootTimes breaks the boot of a sandbox down into the durations of its
+// steps, a step is zero when it or the previous one didn't happen.
+type BootTimes struct {
+	Timeline types.BootTimeline `json:"timeline"`
+
+	// VMMSpawn is the time taken by the VMM to start the VM.
+	VMMSpawn time.Duration `json:"vmm_spawn"`
+
+	// KernelBoot is the time from the VM start to the first answer of
+	// the agent, it includes the start of the agent.
+	KernelBoot time.Duration `json:"kernel_boot"`
+
+	// AgentReady is the time taken by the agent to set up the sandbox.
+	AgentReady time.Duration `json:"agent_ready"`
+
+	// FirstContainerStart is the time from the sandbox setup to the
+	// start of its first container.
+	FirstContainerStart time.Duration `json:"first_container_start"`
+
+	// Total is the time from the sandbox creation request to the last
+	// step which happened.
+	Total time.Duration `json:"total"`
+}
+
+func bootStepDuration(from, to time.Time) time.Duration {
+	if from.IsZero() || to.IsZero() {
+		return 0
+	}
+
+	return to.Sub(from)
+}
+
+// recordBootEvent stores when the sandbox went through a boot step, only
+// the first time is kept since the VM boots once.
+func (s *Sandbox) recordBootEvent(event bootEvent, t time.Time) {
+	timeline := &s.state.BootTimeline
+
+	var step *time.Time
+	switch event {
+	case bootEventCreate:
+		step = &timeline.Create
+	case bootEventVMMStart:
+		step = &timeline.VMMStart
+	case bootEventVMMReady:
+		step = &timeline.VMMReady
+	case bootEventGuestReady:
+		step = &timeline.GuestReady
+	case bootEventAgentReady:
+		step = &timeline.AgentReady
+	case bootEventFirstContainerStart:
+		step = &timeline.FirstContainerStart
+	default:
+		return
+	}
+
+	if !step.IsZero() {
+		return
+	}
+	*step = t
+
+	s.Logger().WithField("boot-event", event).WithField("time", t).Debug("Sandbox boot progress")
+}
+
+// BootTimes returns the boot timeline of the sandbox.
+func (s *Sandbox) BootTimes() BootTimes {
+	timeline := s.state.BootTimeline
+
+	times := BootTimes{
+		Timeline:            timeline,
+		VMMSpawn:            bootStepDuration(timeline.VMMStart, timeline.VMMReady),
+		KernelBoot:          bootStepDuration(timeline.VMMReady, timeline.GuestReady),
+		AgentReady:          bootStepDuration(timeline.GuestReady, timeline.AgentReady),
+		FirstContainerStart: bootStepDuration(timeline.AgentReady, timeline.FirstContainerStart),
+	}
+
+	for _, last := range []time.Time{
+		timeline.FirstContainerStart,
+		timeline.AgentReady,
+		timeline.GuestReady,
+		timeline.VMMReady,
+		timeline.VMMStart,
+	} {
+		if !last.IsZero() {
+			times.Total = bootStepDuration(timeline.Create, last)
+			break
+		}
+	}
+
+	return times
+}
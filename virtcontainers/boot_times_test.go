@@ -0,0 +1,45 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxBootTimesSteps(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{id: testSandboxID}
+	assert.Equal(BootTimes{}, s.BootTimes())
+
+	start := time.Now()
+	s.recordBootEvent(bootEventCreate, start)
+	s.recordBootEvent(bootEventVMMStart, start.Add(time.Millisecond))
+	s.recordBootEvent(bootEventVMMReady, start.Add(3*time.Millisecond))
+
+	times := s.BootTimes()
+	assert.Equal(2*time.Millisecond, times.VMMSpawn)
+	assert.Zero(times.KernelBoot)
+	assert.Equal(3*time.Millisecond, times.Total)
+
+	s.recordBootEvent(bootEventGuestReady, start.Add(10*time.Millisecond))
+	s.recordBootEvent(bootEventAgentReady, start.Add(12*time.Millisecond))
+	s.recordBootEvent(bootEventFirstContainerStart, start.Add(20*time.Millisecond))
+
+	// a container started later doesn't change the timeline
+	s.recordBootEvent(bootEventFirstContainerStart, start.Add(time.Second))
+
+	times = s.BootTimes()
+	assert.Equal(2*time.Millisecond, times.VMMSpawn)
+	assert.Equal(7*time.Millisecond, times.KernelBoot)
+	assert.Equal(2*time.Millisecond, times.AgentReady)
+	assert.Equal(8*time.Millisecond, times.FirstContainerStart)
+	assert.Equal(20*time.Millisecond, times.Total)
+	assert.Equal(start.Add(20*time.Millisecond), times.Timeline.FirstContainerStart)
+}
@@ -85,7 +85,7 @@ func (endpoint *BridgedMacvlanEndpoint) NetworkPair() *NetworkInterfacePair {
 // Attach for virtual endpoint bridges the network pair and adds the
 // tap interface of the network pair to the hypervisor.
 func (endpoint *BridgedMacvlanEndpoint) Attach(h hypervisor) error {
-	if err := xConnectVMNetwork(endpoint, h); err != nil {
+	if err := xConnectVMNetwork(endpoint, h, netQueues(h)); err != nil {
 		networkLogger().WithError(err).Error("Error bridging virtual ep")
 		return err
 	}
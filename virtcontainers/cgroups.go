@@ -14,6 +14,7 @@ import (
 	"strings"
 
 	"github.com/containerd/cgroups"
+	vccgroups "github.com/kata-containers/runtime/virtcontainers/pkg/cgroups"
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 )
 
@@ -27,8 +28,44 @@ type cgroupPather interface {
 // where path is defined by the containers manager
 const cgroupKataPath = "/kata/"
 
-var cgroupsLoadFunc = cgroups.Load
-var cgroupsNewFunc = cgroups.New
+var cgroupsLoadFunc = loadCgroups
+var cgroupsNewFunc = newCgroups
+
+// cgroupV2Path returns the path of a cgroup in the unified hierarchy,
+// there are no subsystems hence the name is ignored.
+func cgroupV2Path(path cgroups.Path) (string, error) {
+	return path("")
+}
+
+// newCgroups creates a cgroup in the hierarchy used by the host, the
+// subsystems are ignored with cgroups v2.
+func newCgroups(hierarchy cgroups.Hierarchy, path cgroups.Path, resources *specs.LinuxResources, opts ...cgroups.InitOpts) (cgroups.Cgroup, error) {
+	if !vccgroups.IsCgroupV2() {
+		return cgroups.New(hierarchy, path, resources, opts...)
+	}
+
+	p, err := cgroupV2Path(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return vccgroups.NewV2(p, resources)
+}
+
+// loadCgroups loads a cgroup from the hierarchy used by the host, the
+// subsystems are ignored with cgroups v2.
+func loadCgroups(hierarchy cgroups.Hierarchy, path cgroups.Path, opts ...cgroups.InitOpts) (cgroups.Cgroup, error) {
+	if !vccgroups.IsCgroupV2() {
+		return cgroups.Load(hierarchy, path, opts...)
+	}
+
+	p, err := cgroupV2Path(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return vccgroups.LoadV2(p)
+}
 
 // V1Constraints returns the cgroups that are compatible with the VC architecture
 // and hypervisor, constraints can be applied to these cgroups.
@@ -114,6 +114,19 @@ func TestCgroupNoConstraintsPath(t *testing.T) {
 	assert.Equal(expectedPath, path)
 }
 
+func TestCgroupV2Path(t *testing.T) {
+	assert := assert.New(t)
+
+	path, err := cgroupV2Path(cgroups.StaticPath("/kata/abc"))
+	assert.NoError(err)
+	assert.Equal("/kata/abc", path)
+
+	_, err = cgroupV2Path(func(cgroups.Name) (string, error) {
+		return "", fmt.Errorf("no path")
+	})
+	assert.Error(err)
+}
+
 func TestUpdateCgroups(t *testing.T) {
 	assert := assert.New(t)
 
@@ -22,6 +22,7 @@ import (
 
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
 	chclient "github.com/kata-containers/runtime/virtcontainers/pkg/cloud-hypervisor/client"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -65,6 +66,8 @@ const (
 	supportedMinorVersion = 5
 	defaultClhPath        = "/usr/local/bin/cloud-hypervisor"
 	virtioFsCacheAlways   = "always"
+	// pmem devices are mapped in 2 MiB huge page aligned regions
+	clhPmemAlignment = 2 * 1024 * 1024
 )
 
 // Interface that hides the implementation of openAPI client
@@ -162,6 +165,10 @@ func (clh *cloudHypervisor) createSandbox(ctx context.Context, id string, networ
 		return err
 	}
 
+	if hypervisorConfig.CPUFeatures != "" {
+		return fmt.Errorf("CPU features are not supported by cloud-hypervisor")
+	}
+
 	clh.id = id
 	clh.config = *hypervisorConfig
 	clh.state.state = clhNotReady
@@ -231,6 +238,15 @@ func (clh *cloudHypervisor) createSandbox(ctx context.Context, id string, networ
 		MaxVcpus:  int32(clh.config.DefaultMaxVCPUs),
 	}
 
+	if clh.config.CPUSockets != 0 {
+		clh.vmconfig.Cpus.Topology = &chclient.CpuTopology{
+			ThreadsPerCore: int32(clh.config.CPUThreads),
+			CoresPerDie:    int32(clh.config.CPUCores),
+			DiesPerPackage: 1,
+			Packages:       int32(clh.config.CPUSockets),
+		}
+	}
+
 	// Add the kernel path
 	kernelPath, err := clh.config.KernelAssetPath()
 	if err != nil {
@@ -240,16 +256,23 @@ func (clh *cloudHypervisor) createSandbox(ctx context.Context, id string, networ
 		Path: kernelPath,
 	}
 
-	// First take the default parameters defined by this driver
-	params := clhKernelParams
-
-	// Followed by extra debug parameters if debug enabled in configuration file
+	// Extra debug parameters if debug enabled in configuration file
+	var debugParams []Param
 	if clh.config.Debug {
-		params = append(params, clhDebugKernelParams...)
+		debugParams = clhDebugKernelParams
 	}
 
-	// Followed by extra debug parameters defined in the configuration file
-	params = append(params, clh.config.KernelParams...)
+	// The image is mounted from the pmem device with DAX instead of
+	// the default virtio-blk disk
+	var rootParams []Param
+	if clh.config.UsePmemRootfs {
+		rootParams = commonNvdimmKernelRootParams
+	}
+
+	// First take the default parameters defined by this driver, followed
+	// by the debug parameters and the parameters defined in the
+	// configuration file
+	params := appendKernelParams(mergeKernelParams(clhKernelParams, rootParams, debugParams), clh.config.KernelParams)
 
 	clh.vmconfig.Cmdline.Args = kernelParamsToString(params)
 
@@ -268,11 +291,19 @@ func (clh *cloudHypervisor) createSandbox(ctx context.Context, id string, networ
 		return errors.New("image path is empty")
 	}
 
-	disk := chclient.DiskConfig{
-		Path:     imagePath,
-		Readonly: true,
+	if clh.config.UsePmemRootfs {
+		pmem, err := clhPmemConfig(imagePath)
+		if err != nil {
+			return err
+		}
+		clh.vmconfig.Pmem = append(clh.vmconfig.Pmem, pmem)
+	} else {
+		disk := chclient.DiskConfig{
+			Path:     imagePath,
+			Readonly: true,
+		}
+		clh.vmconfig.Disks = append(clh.vmconfig.Disks, disk)
 	}
-	clh.vmconfig.Disks = append(clh.vmconfig.Disks, disk)
 
 	// set the serial console to the cloud hypervisor
 	if clh.config.Debug {
@@ -310,6 +341,7 @@ func (clh *cloudHypervisor) createSandbox(ctx context.Context, id string, networ
 		extraArgs:  clh.config.VirtioFSExtraArgs,
 		debug:      clh.config.Debug,
 		cache:      clh.config.VirtioFSCache,
+		threads:    clh.config.VirtioFSThreadPoolSize,
 	}
 
 	return nil
@@ -325,8 +357,7 @@ func (clh *cloudHypervisor) startSandbox(timeout int) error {
 
 	clh.Logger().WithField("function", "startSandbox").Info("starting Sandbox")
 
-	vmPath := filepath.Join(clh.store.RunVMStoragePath(), clh.id)
-	err := os.MkdirAll(vmPath, DirMode)
+	_, err := createSandboxVMPath(clh.store.RunVMStoragePath(), clh.id)
 	if err != nil {
 		return err
 	}
@@ -595,6 +626,35 @@ func (clh *cloudHypervisor) check() error {
 	return err
 }
 
+func (clh *cloudHypervisor) describe() (VMInfo, error) {
+	info, err := clh.vmInfo()
+	if err != nil {
+		return VMInfo{}, err
+	}
+
+	return VMInfo{
+		State:    info.State,
+		VCPUs:    uint32(info.Config.Cpus.BootVcpus),
+		MemoryMB: uint32(info.Config.Memory.Size >> 20),
+		Details: map[string]string{
+			"api_socket": clh.state.apiSocket,
+			"max_vcpus":  strconv.Itoa(int(info.Config.Cpus.MaxVcpus)),
+		},
+	}, nil
+}
+
+func (clh *cloudHypervisor) rebootSandbox() error {
+	return errors.New("cloud-hypervisor does not support rebooting the VM")
+}
+
+func (clh *cloudHypervisor) migrateSandbox(uri string) error {
+	return errors.New("cloud-hypervisor does not support migration")
+}
+
+func (clh *cloudHypervisor) launchMeasurement() (string, error) {
+	return "", errors.New("cloud-hypervisor does not support confidential guests")
+}
+
 func (clh *cloudHypervisor) getPids() []int {
 
 	var pids []int
@@ -603,6 +663,10 @@ func (clh *cloudHypervisor) getPids() []int {
 	return pids
 }
 
+func (clh *cloudHypervisor) getVMPath() string {
+	return SandboxVMPath(clh.store.RunVMStoragePath(), clh.id)
+}
+
 func (clh *cloudHypervisor) addDevice(devInfo interface{}, devType deviceType) error {
 	span, _ := clh.trace("addDevice")
 	defer span.Finish()
@@ -644,6 +708,11 @@ func (clh *cloudHypervisor) capabilities() types.Capabilities {
 	clh.Logger().WithField("function", "capabilities").Info("get Capabilities")
 	var caps types.Capabilities
 	caps.SetFsSharingSupport()
+	caps.SetVirtioFSSupport()
+	caps.SetMultiQueueSupport()
+	caps.SetMemoryHotplugSupport()
+	caps.SetCPUHotplugSupport()
+	caps.SetVFIOHotplugSupport()
 	return caps
 }
 
@@ -654,7 +723,7 @@ func (clh *cloudHypervisor) trace(name string) (opentracing.Span, context.Contex
 		clh.ctx = context.Background()
 	}
 
-	span, ctx := opentracing.StartSpanFromContext(clh.ctx, name)
+	span, ctx := startSpanFromContext(clh.ctx, "hypervisor", name)
 
 	span.SetTag("subsystem", "cloudHypervisor")
 	span.SetTag("type", "clh")
@@ -745,23 +814,44 @@ func (clh *cloudHypervisor) generateSocket(id string, useVsock bool) (interface{
 }
 
 func (clh *cloudHypervisor) virtioFsSocketPath(id string) (string, error) {
-	return utils.BuildSocketPath(clh.store.RunVMStoragePath(), id, virtioFsSocket)
+	return utils.BuildSocketPath(SandboxVMPath(clh.store.RunVMStoragePath(), id), virtioFsSocket)
 }
 
 func (clh *cloudHypervisor) vsockSocketPath(id string) (string, error) {
-	return utils.BuildSocketPath(clh.store.RunVMStoragePath(), id, clhSocket)
+	return utils.BuildSocketPath(SandboxVMPath(clh.store.RunVMStoragePath(), id), clhSocket)
 }
 
 func (clh *cloudHypervisor) serialPath(id string) (string, error) {
-	return utils.BuildSocketPath(clh.store.RunVMStoragePath(), id, clhSerial)
+	return utils.BuildSocketPath(SandboxVMPath(clh.store.RunVMStoragePath(), id), clhSerial)
 }
 
 func (clh *cloudHypervisor) apiSocketPath(id string) (string, error) {
-	return utils.BuildSocketPath(clh.store.RunVMStoragePath(), id, clhAPISocket)
+	return utils.BuildSocketPath(SandboxVMPath(clh.store.RunVMStoragePath(), id), clhAPISocket)
+}
+
+// clhPmemConfig describes the rootfs image as a pmem device. Guest writes
+// are discarded so that the image shared by all the sandboxes is never
+// modified.
+func clhPmemConfig(imagePath string) (chclient.PmemConfig, error) {
+	st, err := os.Stat(imagePath)
+	if err != nil {
+		return chclient.PmemConfig{}, err
+	}
+
+	if st.Size() == 0 || st.Size()%clhPmemAlignment != 0 {
+		return chclient.PmemConfig{}, fmt.Errorf("Image %s size %d is not a multiple of %d bytes, it can't be used as a pmem device",
+			imagePath, st.Size(), clhPmemAlignment)
+	}
+
+	return chclient.PmemConfig{
+		File:          imagePath,
+		Size:          st.Size(),
+		DiscardWrites: true,
+	}, nil
 }
 
 func (clh *cloudHypervisor) logFilePath(id string) (string, error) {
-	return utils.BuildSocketPath(clh.store.RunVMStoragePath(), id, clhLogFile)
+	return utils.BuildSocketPath(SandboxVMPath(clh.store.RunVMStoragePath(), id), clhLogFile)
 }
 
 func (clh *cloudHypervisor) waitVMM(timeout uint) error {
@@ -772,7 +862,7 @@ func (clh *cloudHypervisor) waitVMM(timeout uint) error {
 	}
 
 	if !clhRunning {
-		return fmt.Errorf("CLH is not running")
+		return vcTypes.Errorf(vcTypes.ErrCodeHypervisorNotRunning, "CLH is not running")
 	}
 
 	return nil
@@ -955,7 +1045,7 @@ func (clh *cloudHypervisor) isClhRunning(timeout uint) (bool, error) {
 		}
 
 		if time.Since(timeStart).Seconds() > float64(timeout) {
-			return false, fmt.Errorf("Failed to connect to API (timeout %ds): %s", timeout, openAPIClientError(err))
+			return false, vcTypes.Errorf(vcTypes.ErrCodeHypervisorNotRunning, "Failed to connect to API (timeout %ds): %s", timeout, openAPIClientError(err))
 		}
 
 		time.Sleep(time.Duration(10) * time.Millisecond)
@@ -1088,7 +1178,20 @@ func (clh *cloudHypervisor) addNet(e Endpoint) error {
 		"tap": tapPath,
 	}).Info("Adding Net")
 
-	clh.vmconfig.Net = append(clh.vmconfig.Net, chclient.NetConfig{Mac: mac, Tap: tapPath})
+	net := chclient.NetConfig{Mac: mac, Tap: tapPath}
+
+	// cloud-hypervisor opens the queues of the TAP itself, one RX and
+	// one TX queue per queue pair. The TAP is persistent so the queues
+	// opened when it was created can be released.
+	if queues := len(netPair.VMFds); queues > 0 {
+		net.NumQueues = int32(2 * queues)
+		for _, f := range netPair.VMFds {
+			f.Close()
+		}
+		netPair.VMFds = nil
+	}
+
+	clh.vmconfig.Net = append(clh.vmconfig.Net, net)
 	return nil
 }
 
@@ -1142,7 +1245,7 @@ func (clh *cloudHypervisor) cleanupVM(force bool) error {
 	}
 
 	// cleanup vm path
-	dir := filepath.Join(clh.store.RunVMStoragePath(), clh.id)
+	dir := SandboxVMPath(clh.store.RunVMStoragePath(), clh.id)
 
 	// If it's a symlink, remove both dir and the target.
 	link, err := filepath.EvalSymlinks(dir)
@@ -1178,6 +1281,12 @@ func (clh *cloudHypervisor) cleanupVM(force bool) error {
 			}
 			clh.Logger().WithError(err).WithField("path", dir).Warnf("failed to remove vm path")
 		}
+		if err := cleanupSandboxTmpDir(clh.config.VMid); err != nil {
+			if !force {
+				return err
+			}
+			clh.Logger().WithError(err).WithField("vm", clh.config.VMid).Warn("failed to remove vm temporary tree")
+		}
 	}
 
 	clh.reset()
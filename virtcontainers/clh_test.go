@@ -7,6 +7,7 @@ package virtcontainers
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -144,6 +145,30 @@ func TestCloudHypervisorAddNetCheckNetConfigListValues(t *testing.T) {
 	}
 }
 
+// Check addNet sizes the queues of multi queue TAPs
+func TestCloudHypervisorAddNetMultiQueue(t *testing.T) {
+	assert := assert.New(t)
+
+	clh := cloudHypervisor{}
+	caps := clh.capabilities()
+	assert.True(caps.IsMultiQueueSupported())
+
+	r, w, err := os.Pipe()
+	assert.NoError(err)
+
+	e := &VethEndpoint{}
+	e.NetPair.TAPIface.HardAddr = "00:00:00:00:00"
+	e.NetPair.TapInterface.TAPIface.Name = "/path/to/tap"
+	e.NetPair.VMFds = []*os.File{r, w}
+
+	assert.NoError(clh.addNet(e))
+	assert.Equal(int32(4), clh.vmconfig.Net[0].NumQueues)
+	assert.Nil(e.NetPair.VMFds)
+
+	// the queues opened by the runtime are released
+	assert.Error(r.Close())
+}
+
 // Check addNet with valid values, and fail with invalid values
 // For Cloud Hypervisor only tap is be required
 func TestCloudHypervisorAddNetCheckEnpointTypes(t *testing.T) {
@@ -244,6 +269,52 @@ func TestClhCreateSandbox(t *testing.T) {
 	assert.Exactly(clhConfig, clh.config)
 }
 
+func TestClhCreateSandboxPmemRootfs(t *testing.T) {
+	assert := assert.New(t)
+
+	clhConfig, err := newClhConfig()
+	assert.NoError(err)
+
+	dir, err := ioutil.TempDir("", "clh-pmem")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	image := filepath.Join(dir, "image")
+	f, err := os.Create(image)
+	assert.NoError(err)
+	assert.NoError(f.Truncate(clhPmemAlignment - 1))
+	f.Close()
+
+	store, err := persist.GetDriver()
+	assert.NoError(err)
+
+	clhConfig.ImagePath = image
+	clhConfig.UsePmemRootfs = true
+
+	clh := &cloudHypervisor{
+		config: clhConfig,
+		store:  store,
+	}
+
+	// misaligned image
+	err = clh.createSandbox(context.Background(), "testSandbox", NetworkNamespace{}, &clhConfig, false)
+	assert.Error(err)
+
+	assert.NoError(os.Truncate(image, clhPmemAlignment))
+	clh = &cloudHypervisor{
+		config: clhConfig,
+		store:  store,
+	}
+	err = clh.createSandbox(context.Background(), "testSandbox", NetworkNamespace{}, &clhConfig, false)
+	assert.NoError(err)
+
+	assert.Empty(clh.vmconfig.Disks)
+	assert.Equal([]chclient.PmemConfig{{File: image, Size: clhPmemAlignment, DiscardWrites: true}}, clh.vmconfig.Pmem)
+	assert.Contains(clh.vmconfig.Cmdline.Args, "root=/dev/pmem0p1")
+	assert.Contains(clh.vmconfig.Cmdline.Args, "rootflags=dax,data=ordered,errors=remount-ro ro")
+	assert.NotContains(clh.vmconfig.Cmdline.Args, "root=/dev/vda1")
+}
+
 func TestClooudHypervisorStartSandbox(t *testing.T) {
 	assert := assert.New(t)
 	clhConfig, err := newClhConfig()
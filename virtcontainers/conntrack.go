@@ -0,0 +1,170 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+// The traffic of the VM redirected by the tc filters or tc programs bypasses
+// netfilter in the network namespace of the sandbox, but it is tracked again
+// once it leaves the host side of the veth pairs. The tc actions can't mark
+// the packets as untracked, the rules bypassing the connection tracking of
+// the host are netfilter rules of the raw table, identified by their comment.
+
+// conntrackBypassTables are the iptables commands of the address families.
+var conntrackBypassTables = []struct {
+	family int
+	cmd    string
+}{
+	{netlink.FAMILY_V4, "iptables"},
+	{netlink.FAMILY_V6, "ip6tables"},
+}
+
+// execIptables is mocked by the tests.
+var execIptables = func(cmd string, args ...string) ([]byte, error) {
+	// wait for the xtables lock held by the other users
+	return exec.Command(cmd, append([]string{"-w"}, args...)...).CombinedOutput()
+}
+
+// conntrackBypassComment returns the comment of the rules of the network
+// namespace.
+func conntrackBypassComment(netNSPath string) string {
+	return "kata-notrack:" + filepath.Base(netNSPath)
+}
+
+// conntrackBypassRules returns the rules, by iptables command, untracking
+// the traffic of the host side of the veth pair and the traffic to the
+// addresses of the endpoint.
+func conntrackBypassRules(hostVeth string, addrs []netlink.Addr, comment string) map[string][][]string {
+	rules := make(map[string][][]string)
+
+	match := []string{"-m", "comment", "--comment", comment, "-j", "CT", "--notrack"}
+
+	for _, t := range conntrackBypassTables {
+		var ips []string
+		for _, addr := range addrs {
+			if addr.IP.IsLoopback() {
+				continue
+			}
+
+			if (addr.IP.To4() != nil) == (t.family == netlink.FAMILY_V4) {
+				ips = append(ips, addr.IP.String())
+			}
+		}
+
+		if len(ips) == 0 {
+			continue
+		}
+
+		rules[t.cmd] = append(rules[t.cmd], append([]string{"PREROUTING", "-i", hostVeth}, match...))
+		for _, ip := range ips {
+			rules[t.cmd] = append(rules[t.cmd],
+				append([]string{"PREROUTING", "-d", ip}, match...),
+				append([]string{"OUTPUT", "-d", ip}, match...))
+		}
+	}
+
+	return rules
+}
+
+// hostVethPeer returns the host side of the veth pair of the endpoint,
+// looked for in the current network namespace.
+func hostVethPeer(endpoint *VethEndpoint) (string, error) {
+	iface := endpoint.Properties().Iface
+
+	// the index of the peer is the link of the veth
+	link, err := netlink.LinkByIndex(iface.ParentIndex)
+	if err != nil {
+		return "", fmt.Errorf("Could not find host side of veth %s: %v", iface.Name, err)
+	}
+
+	if link.Type() != (&netlink.Veth{}).Type() {
+		return "", fmt.Errorf("Host side %s of veth %s is a %s link", link.Attrs().Name, iface.Name, link.Type())
+	}
+
+	return link.Attrs().Name, nil
+}
+
+// addConntrackBypass adds the rules bypassing the connection tracking of
+// the host for the traffic of the veth endpoints using tc filters or tc
+// programs. It is called from the host network namespace.
+func addConntrackBypass(netNSPath string, endpoints []Endpoint) (err error) {
+	comment := conntrackBypassComment(netNSPath)
+
+	defer func() {
+		if err != nil {
+			removeConntrackBypass(netNSPath)
+		}
+	}()
+
+	for _, endpoint := range endpoints {
+		ep, ok := endpoint.(*VethEndpoint)
+		if !ok || (ep.NetPair.NetInterworkingModel != NetXConnectTCFilterModel && ep.NetPair.NetInterworkingModel != NetXConnectEBPFModel) {
+			continue
+		}
+
+		hostVeth, err := hostVethPeer(ep)
+		if err != nil {
+			return err
+		}
+
+		networkLogger().WithFields(logrus.Fields{
+			"veth":      hostVeth,
+			"interface": ep.Name(),
+		}).Info("Bypassing connection tracking")
+
+		for cmd, rules := range conntrackBypassRules(hostVeth, ep.Properties().Addrs, comment) {
+			for _, rule := range rules {
+				if out, err := execIptables(cmd, append([]string{"-t", "raw", "-A"}, rule...)...); err != nil {
+					return fmt.Errorf("Could not add %s rule %v: %v: %s", cmd, rule, err, strings.TrimSpace(string(out)))
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// removeConntrackBypass removes the rules of the network namespace, all
+// the rules being removed even when some of them can't be.
+func removeConntrackBypass(netNSPath string) error {
+	comment := conntrackBypassComment(netNSPath)
+
+	var removeErr error
+	for _, t := range conntrackBypassTables {
+		out, err := execIptables(t.cmd, "-t", "raw", "-S")
+		if err != nil {
+			if removeErr == nil {
+				removeErr = fmt.Errorf("Could not list %s rules: %v: %s", t.cmd, err, strings.TrimSpace(string(out)))
+			}
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(out))
+		for scanner.Scan() {
+			rule := strings.Fields(scanner.Text())
+			if len(rule) < 2 || rule[0] != "-A" || !strings.Contains(scanner.Text(), "--comment "+comment+" ") {
+				continue
+			}
+
+			rule[0] = "-D"
+			if out, err := execIptables(t.cmd, append([]string{"-t", "raw"}, rule...)...); err != nil && removeErr == nil {
+				removeErr = fmt.Errorf("Could not remove %s rule %v: %v: %s", t.cmd, rule, err, strings.TrimSpace(string(out)))
+			}
+		}
+	}
+
+	return removeErr
+}
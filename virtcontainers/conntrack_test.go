@@ -0,0 +1,104 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/vishvananda/netlink"
+)
+
+const testConntrackNetNS = "/var/run/netns/cni-0123"
+
+func TestConntrackBypassRules(t *testing.T) {
+	assert := assert.New(t)
+
+	addrs := []netlink.Addr{
+		{IPNet: &net.IPNet{IP: net.ParseIP("10.88.0.2"), Mask: net.CIDRMask(16, 32)}},
+		{IPNet: &net.IPNet{IP: net.ParseIP("127.0.0.1"), Mask: net.CIDRMask(8, 32)}},
+	}
+
+	comment := conntrackBypassComment(testConntrackNetNS)
+	assert.Equal("kata-notrack:cni-0123", comment)
+
+	match := []string{"-m", "comment", "--comment", comment, "-j", "CT", "--notrack"}
+	assert.Equal(map[string][][]string{
+		"iptables": {
+			append([]string{"PREROUTING", "-i", "veth0123"}, match...),
+			append([]string{"PREROUTING", "-d", "10.88.0.2"}, match...),
+			append([]string{"OUTPUT", "-d", "10.88.0.2"}, match...),
+		},
+	}, conntrackBypassRules("veth0123", addrs, comment))
+
+	addrs = append(addrs, netlink.Addr{IPNet: &net.IPNet{IP: net.ParseIP("fd00::2"), Mask: net.CIDRMask(64, 128)}})
+	rules := conntrackBypassRules("veth0123", addrs, comment)
+	assert.Len(rules["iptables"], 3)
+	assert.Equal(append([]string{"OUTPUT", "-d", "fd00::2"}, match...), rules["ip6tables"][2])
+
+	assert.Empty(conntrackBypassRules("veth0123", nil, comment))
+}
+
+func TestRemoveConntrackBypass(t *testing.T) {
+	assert := assert.New(t)
+
+	savedExecIptables := execIptables
+	defer func() {
+		execIptables = savedExecIptables
+	}()
+
+	var calls []string
+	execIptables = func(cmd string, args ...string) ([]byte, error) {
+		calls = append(calls, cmd+" "+strings.Join(args, " "))
+
+		if args[len(args)-1] != "-S" {
+			return nil, nil
+		}
+
+		if cmd == "ip6tables" {
+			return []byte("ip6tables: not found"), fmt.Errorf("exit status 1")
+		}
+
+		return []byte(`-P PREROUTING ACCEPT
+-P OUTPUT ACCEPT
+-A PREROUTING -i veth0123 -m comment --comment kata-notrack:cni-0123 -j CT --notrack
+-A PREROUTING -i veth4567 -m comment --comment kata-notrack:cni-01234 -j CT --notrack
+-A OUTPUT -d 10.88.0.2/32 -m comment --comment kata-notrack:cni-0123 -j CT --notrack
+`), nil
+	}
+
+	// the failure of ip6tables is reported after removing the rules
+	assert.Error(removeConntrackBypass(testConntrackNetNS))
+	assert.Equal([]string{
+		"iptables -t raw -S",
+		"iptables -t raw -D PREROUTING -i veth0123 -m comment --comment kata-notrack:cni-0123 -j CT --notrack",
+		"iptables -t raw -D OUTPUT -d 10.88.0.2/32 -m comment --comment kata-notrack:cni-0123 -j CT --notrack",
+		"ip6tables -t raw -S",
+	}, calls)
+}
+
+func TestAddConntrackBypassSkipsEndpoints(t *testing.T) {
+	assert := assert.New(t)
+
+	savedExecIptables := execIptables
+	defer func() {
+		execIptables = savedExecIptables
+	}()
+
+	execIptables = func(cmd string, args ...string) ([]byte, error) {
+		return nil, fmt.Errorf("unexpected %s call", cmd)
+	}
+
+	// only the veth endpoints using tc filters are untracked
+	endpoints := []Endpoint{
+		&VethEndpoint{NetPair: NetworkInterfacePair{NetInterworkingModel: NetXConnectMacVtapModel}},
+		&TapEndpoint{},
+	}
+	assert.NoError(addConntrackBypass(testConntrackNetNS, endpoints))
+}
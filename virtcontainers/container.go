@@ -30,6 +30,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/device/manager"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/volume"
 	"github.com/kata-containers/runtime/virtcontainers/store"
 )
 
@@ -56,6 +57,11 @@ var cdromMajors = map[int64]string{
 // #define FLOPPY_MAJOR		2
 const floppyMajor = int64(2)
 
+// maxCopiedDirSize is the size of the largest directory mount copied to the
+// guest when no file system is shared, e.g. the secrets holding TLS
+// certificates.
+const maxCopiedDirSize = 1 << 20
+
 // Process gathers data related to a container process.
 type Process struct {
 	// Token is the process execution context ID. It must be
@@ -360,7 +366,7 @@ func (c *Container) trace(name string) (opentracing.Span, context.Context) {
 		c.ctx = context.Background()
 	}
 
-	span, ctx := opentracing.StartSpanFromContext(c.ctx, name)
+	span, ctx := startSpanFromContext(c.ctx, "container", name)
 
 	span.SetTag("subsystem", "container")
 
@@ -453,31 +459,43 @@ func (c *Container) shareFiles(m Mount, idx int, hostSharedDir, guestSharedDir s
 	}
 
 	filename := fmt.Sprintf("%s-%s-%s", c.id, hex.EncodeToString(randBytes), filepath.Base(m.Destination))
+
+	// copy the Kubernetes volumes updated by the kubelet to the guest and
+	// copy them again when they change, rather than relying on the shared
+	// file system to propagate the updates.
+	if c.sandbox.config.MountWatchInterval != 0 && isWatchableMount(m.Source) {
+		watchedDest := filepath.Join(watchablePath(), filename)
+		watched, err := c.sandbox.watchMount(c.id, m.Source, watchedDest)
+		if err != nil {
+			return "", false, err
+		}
+
+		if watched {
+			c.mounts[idx].WatchedPath = watchedDest
+			return watchedDest, false, nil
+		}
+
+		c.Logger().WithField("source", m.Source).Warn("Watchable mount has no file, sharing it")
+	}
+
 	guestDest := filepath.Join(guestSharedDir, filename)
 
 	// copy file to contaier's rootfs if filesystem sharing is not supported, otherwise
 	// bind mount it in the shared directory.
-	caps := c.sandbox.hypervisor.capabilities()
-	if !caps.IsFsSharingSupported() {
+	if !fsShareSupported(c.sandbox.hypervisor) {
 		c.Logger().Debug("filesystem sharing is not supported, files will be copied")
 
-		fileInfo, err := os.Stat(m.Source)
+		copied, err := c.copyMount(m.Source, guestDest)
 		if err != nil {
 			return "", false, err
 		}
 
-		// Ignore the mount if this is not a regular file (excludes
-		// directory, socket, device, ...) as it cannot be handled by
-		// a simple copy. But this should not be treated as an error,
-		// only as a limitation.
-		if !fileInfo.Mode().IsRegular() {
-			c.Logger().WithField("ignored-file", m.Source).Debug("Ignoring non-regular file as FS sharing not supported")
+		// Ignore the mount if it cannot be handled by a simple copy
+		// (socket, device, large directory, ...). But this should not
+		// be treated as an error, only as a limitation.
+		if !copied {
 			return "", true, nil
 		}
-
-		if err := c.sandbox.agent.copyFile(m.Source, guestDest); err != nil {
-			return "", false, err
-		}
 	} else {
 		// These mounts are created in the shared dir
 		mountDest := filepath.Join(hostSharedDir, c.sandbox.id, filename)
@@ -491,6 +509,51 @@ func (c *Container) shareFiles(m Mount, idx int, hostSharedDir, guestSharedDir s
 	return guestDest, false, nil
 }
 
+// copyMount copies the regular file source, or the regular files of the
+// directory source when they are smaller than maxCopiedDirSize, to dst in the
+// guest through the agent. It returns false when source is not copied.
+func (c *Container) copyMount(source, dst string) (bool, error) {
+	fileInfo, err := os.Stat(source)
+	if err != nil {
+		return false, err
+	}
+
+	if fileInfo.Mode().IsRegular() {
+		return true, c.sandbox.agent.copyFile(source, dst)
+	}
+
+	logger := c.Logger().WithField("ignored-file", source)
+
+	if !fileInfo.IsDir() {
+		logger.Debug("Ignoring non-regular file as FS sharing not supported")
+		return false, nil
+	}
+
+	files, err := regularFiles(source)
+	if err != nil {
+		return false, err
+	}
+
+	var size int64
+	for _, f := range files {
+		size += f.size
+	}
+
+	// the agent can't create an empty directory
+	if len(files) == 0 || size > maxCopiedDirSize {
+		logger.WithField("size", size).Warn("Ignoring empty or large directory as FS sharing not supported")
+		return false, nil
+	}
+
+	for rel, f := range files {
+		if err := c.sandbox.agent.copyFile(f.path, filepath.Join(dst, rel)); err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
 // mountSharedDirMounts handles bind-mounts by bindmounting to the host shared
 // directory which is mounted through 9pfs in the VM.
 // It also updates the container mount list with the HostPath info, and store
@@ -499,14 +562,7 @@ func (c *Container) shareFiles(m Mount, idx int, hostSharedDir, guestSharedDir s
 func (c *Container) mountSharedDirMounts(hostSharedDir, guestSharedDir string) (sharedDirMounts map[string]Mount, ignoredMounts map[string]Mount, err error) {
 	sharedDirMounts = make(map[string]Mount)
 	ignoredMounts = make(map[string]Mount)
-	var devicesToDetach []string
-	defer func() {
-		if err != nil {
-			for _, id := range devicesToDetach {
-				c.sandbox.devManager.DetachDevice(id, c.sandbox)
-			}
-		}
-	}()
+	var blockDevices []string
 	for idx, m := range c.mounts {
 		// Skip mounting certain system paths from the source on the host side
 		// into the container as it does not make sense to do so.
@@ -529,11 +585,10 @@ func (c *Container) mountSharedDirMounts(hostSharedDir, guestSharedDir string) (
 		// Check if mount is a block device file. If it is, the block device will be attached to the host
 		// instead of passing this as a shared mount.
 		if len(m.BlockDeviceID) > 0 {
-			// Attach this block device, all other devices passed in the config have been attached at this point
-			if err = c.sandbox.devManager.AttachDevice(m.BlockDeviceID, c.sandbox); err != nil {
-				return nil, nil, err
-			}
-			devicesToDetach = append(devicesToDetach, m.BlockDeviceID)
+			// The block devices are attached together once the mounts are
+			// handled, all other devices passed in the config have been
+			// attached at this point
+			blockDevices = append(blockDevices, m.BlockDeviceID)
 			continue
 		}
 
@@ -546,9 +601,15 @@ func (c *Container) mountSharedDirMounts(hostSharedDir, guestSharedDir string) (
 
 		var ignore bool
 		var guestDest string
-		guestDest, ignore, err = c.shareFiles(m, idx, hostSharedDir, guestSharedDir)
-		if err != nil {
-			return nil, nil, err
+		if m.Destination == GuestDNSFile && c.sandbox.guestDNS() {
+			// the resolv.conf generated by the agent replaces the
+			// host one, nothing to share
+			guestDest = GuestDNSFile
+		} else {
+			guestDest, ignore, err = c.shareFiles(m, idx, hostSharedDir, guestSharedDir)
+			if err != nil {
+				return nil, nil, err
+			}
 		}
 
 		// Expand the list of mounts to ignore.
@@ -578,6 +639,11 @@ func (c *Container) mountSharedDirMounts(hostSharedDir, guestSharedDir string) (
 		sharedDirMounts[sharedDirMount.Destination] = sharedDirMount
 	}
 
+	// the device manager detaches them if one fails
+	if err = c.sandbox.devManager.AttachDevices(blockDevices, c.sandbox); err != nil {
+		return nil, nil, err
+	}
+
 	return sharedDirMounts, ignoredMounts, nil
 }
 
@@ -587,6 +653,10 @@ func (c *Container) unmountHostMounts() error {
 	defer span.Finish()
 
 	for _, m := range c.mounts {
+		if m.WatchedPath != "" {
+			c.sandbox.unwatchMounts(c.id)
+		}
+
 		if m.HostPath != "" {
 			span, _ := c.trace("unmount")
 			span.SetTag("host-path", m.HostPath)
@@ -642,8 +712,111 @@ func filterDevices(c *Container, devices []ContainerDevice) (ret []ContainerDevi
 	return
 }
 
+// isPrivileged returns true if spec describes a privileged container.
+// There is no such flag in the OCI spec, but container managers grant
+// CAP_SYS_ADMIN and don't mask nor make read-only any path for them.
+func isPrivileged(spec *specs.Spec) bool {
+	if spec == nil || spec.Linux == nil || spec.Process == nil || spec.Process.Capabilities == nil {
+		return false
+	}
+
+	if len(spec.Linux.MaskedPaths) > 0 || len(spec.Linux.ReadonlyPaths) > 0 {
+		return false
+	}
+
+	for _, c := range spec.Process.Capabilities.Bounding {
+		if c == "CAP_SYS_ADMIN" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isDeviceAllowed(path string, allowList []string) bool {
+	for _, pattern := range allowList {
+		if match, err := filepath.Match(pattern, path); err == nil && match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterPrivilegedDevices removes the host devices not matching allowList
+// from the devices of a privileged container, so that they are neither
+// attached to the VM nor created inside the guest. The container keeps its
+// capabilities, which only apply to the guest.
+func filterPrivilegedDevices(contConfig *ContainerConfig, allowList []string) {
+	var deviceInfos []config.DeviceInfo
+	for _, info := range contConfig.DeviceInfos {
+		if !isDeviceAllowed(info.ContainerPath, allowList) {
+			virtLog.WithFields(logrus.Fields{
+				"container": contConfig.ID,
+				"device":    info.ContainerPath,
+			}).Info("Not attaching device to privileged container")
+			continue
+		}
+		deviceInfos = append(deviceInfos, info)
+	}
+	contConfig.DeviceInfos = deviceInfos
+
+	if contConfig.CustomSpec.Linux == nil {
+		return
+	}
+
+	var devices []specs.LinuxDevice
+	for _, d := range contConfig.CustomSpec.Linux.Devices {
+		if isDeviceAllowed(d.Path, allowList) {
+			devices = append(devices, d)
+		}
+	}
+	contConfig.CustomSpec.Linux.Devices = devices
+}
+
+// createDirectVolumeDevice creates the block device of the direct assigned
+// volume mounted by c.mounts[idx], it's mounted by the agent in the guest
+// instead of being shared with the VM.
+func (c *Container) createDirectVolumeDevice(idx int, mountInfo *volume.MountInfo) error {
+	m := c.mounts[idx]
+
+	var stat unix.Stat_t
+	if err := unix.Stat(mountInfo.Device, &stat); err != nil {
+		return fmt.Errorf("stat %q failed: %v", mountInfo.Device, err)
+	}
+
+	if stat.Mode&unix.S_IFBLK != unix.S_IFBLK {
+		return fmt.Errorf("Device %s of volume %s is not a block device", mountInfo.Device, m.Source)
+	}
+
+	b, err := c.sandbox.devManager.NewDevice(config.DeviceInfo{
+		HostPath:      mountInfo.Device,
+		ContainerPath: m.Destination,
+		DevType:       "b",
+		Major:         int64(unix.Major(stat.Rdev)),
+		Minor:         int64(unix.Minor(stat.Rdev)),
+		ReadOnly:      m.ReadOnly || mountInfo.ReadOnly(),
+		CacheMode:     mountInfo.CacheMode,
+	})
+	if err != nil {
+		return fmt.Errorf("device manager failed to create device of volume %s: %v", m.Source, err)
+	}
+
+	c.mounts[idx].BlockDeviceID = b.DeviceID()
+
+	return nil
+}
+
 func (c *Container) createBlockDevices() error {
 	if !c.checkBlockDeviceSupport() {
+		// The host path of a direct assigned volume doesn't hold its
+		// content, it can't be shared with the VM instead.
+		for _, m := range c.mounts {
+			if _, err := volume.VolumeMountInfo(m.Source); err == nil {
+				return fmt.Errorf("Direct assigned volume %s requires block device support", m.Source)
+			}
+		}
+
 		c.Logger().Warn("Block device not supported")
 		return nil
 	}
@@ -657,6 +830,24 @@ func (c *Container) createBlockDevices() error {
 			continue
 		}
 
+		if mountInfo, err := volume.VolumeMountInfo(m.Source); err == nil {
+			if err := c.createDirectVolumeDevice(i, mountInfo); err != nil {
+				return err
+			}
+			continue
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+
+		if device, fsType, err := c.blockVolume(m); err != nil {
+			return err
+		} else if device != "" {
+			if err := c.createBlockVolumeDevice(i, device, fsType); err != nil {
+				return err
+			}
+			continue
+		}
+
 		var stat unix.Stat_t
 		if err := unix.Stat(m.Source, &stat); err != nil {
 			return fmt.Errorf("stat %q failed: %v", m.Source, err)
@@ -674,6 +865,7 @@ func (c *Container) createBlockDevices() error {
 				DevType:       "b",
 				Major:         int64(unix.Major(stat.Rdev)),
 				Minor:         int64(unix.Minor(stat.Rdev)),
+				ReadOnly:      m.ReadOnly,
 			}
 			// check whether source can be used as a pmem device
 		} else if di, err = config.PmemDeviceInfo(m.Source, m.Destination); err != nil {
@@ -753,6 +945,10 @@ func newContainer(sandbox *Sandbox, contConfig *ContainerConfig) (*Container, er
 		}
 	}
 
+	if isPrivileged(contConfig.CustomSpec) {
+		filterPrivilegedDevices(contConfig, sandbox.config.PrivilegedDeviceAllowList)
+	}
+
 	// Go to next step for first created container
 	if err := c.createMounts(); err != nil {
 		return nil, err
@@ -985,7 +1181,7 @@ func (c *Container) checkSandboxRunning(cmd string) error {
 	}
 
 	if c.sandbox.state.State != types.StateRunning {
-		return fmt.Errorf("Sandbox not running, impossible to %s the container", cmd)
+		return vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running, impossible to %s the container", cmd)
 	}
 
 	return nil
@@ -1026,6 +1222,7 @@ func (c *Container) start() error {
 		}
 		return err
 	}
+	c.sandbox.recordBootEvent(bootEventFirstContainerStart, time.Now())
 
 	return c.setContainerState(types.StateRunning)
 }
@@ -1215,7 +1412,7 @@ func (c *Container) processList(options ProcessListOptions) (ProcessList, error)
 	}
 
 	if c.state.State != types.StateRunning {
-		return nil, fmt.Errorf("Container not running, impossible to list processes")
+		return nil, vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Container not running, impossible to list processes")
 	}
 
 	return c.sandbox.agent.processListContainer(c.sandbox, *c, options)
@@ -1234,7 +1431,7 @@ func (c *Container) update(resources specs.LinuxResources) error {
 	}
 
 	if state := c.state.State; !(state == types.StateRunning || state == types.StateReady) {
-		return fmt.Errorf("Container(%s) not running or ready, impossible to update", state)
+		return vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Container(%s) not running or ready, impossible to update", state)
 	}
 
 	if c.config.Resources.CPU == nil {
@@ -1277,7 +1474,7 @@ func (c *Container) pause() error {
 	}
 
 	if c.state.State != types.StateRunning {
-		return fmt.Errorf("Container not running, impossible to pause")
+		return vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Container not running, impossible to pause")
 	}
 
 	if err := c.sandbox.agent.pauseContainer(c.sandbox, *c); err != nil {
@@ -1429,23 +1626,48 @@ func (c *Container) removeDrive() (err error) {
 }
 
 func (c *Container) attachDevices(devices []ContainerDevice) error {
-	// there's no need to do rollback when error happens,
-	// because if attachDevices fails, container creation will fail too,
-	// and rollbackFailingContainerCreation could do all the rollbacks
-
 	// since devices with large bar space require delayed attachment,
 	// the devices need to be split into two lists, normalAttachedDevs and delayAttachedDevs.
 	// so c.device is not used here. See issue https://github.com/kata-containers/runtime/issues/2460.
+	var ids []string
 	for _, dev := range devices {
-		if err := c.sandbox.devManager.AttachDevice(dev.ID, c.sandbox); err != nil {
-			return err
-		}
+		ids = append(ids, dev.ID)
+	}
+
+	// The device manager detaches the devices it attached when one of them
+	// fails. They're released from the container too, so that
+	// rollbackFailingContainerCreation doesn't detach them once more on
+	// behalf of this container, which would break the other containers
+	// sharing them.
+	if err := c.sandbox.devManager.AttachDevices(ids, c.sandbox); err != nil {
+		c.releaseDevices(devices)
+		return err
 	}
 	return nil
 }
 
+// releaseDevices removes devices, which are not attached, from the container.
+func (c *Container) releaseDevices(devices []ContainerDevice) {
+	for _, dev := range devices {
+		if err := c.sandbox.devManager.RemoveDevice(dev.ID); err != nil {
+			c.Logger().WithField("device-id", dev.ID).WithError(err).Warn("remove device failed")
+		}
+
+		for i, d := range c.devices {
+			if d.ID == dev.ID {
+				c.devices = append(c.devices[:i], c.devices[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
 func (c *Container) detachDevices() error {
-	for _, dev := range c.devices {
+	// The devices are dropped from the container once released: a device
+	// shared with other containers must not be detached again, on behalf
+	// of this container, when stop() is retried after a failure.
+	for len(c.devices) > 0 {
+		dev := c.devices[0]
 		err := c.sandbox.devManager.DetachDevice(dev.ID, c.sandbox)
 		if err != nil && err != manager.ErrDeviceNotAttached {
 			return err
@@ -1462,6 +1684,8 @@ func (c *Container) detachDevices() error {
 				return err
 			}
 		}
+
+		c.devices = c.devices[1:]
 	}
 	return nil
 }
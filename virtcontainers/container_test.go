@@ -22,6 +22,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/device/manager"
 	"github.com/kata-containers/runtime/virtcontainers/persist"
 	"github.com/kata-containers/runtime/virtcontainers/types"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -125,6 +126,56 @@ func TestContainerRemoveDrive(t *testing.T) {
 	assert.Nil(t, err, "remove drive should succeed")
 }
 
+func TestContainerDetachSharedDevices(t *testing.T) {
+	assert := assert.New(t)
+
+	sandbox := &Sandbox{
+		ctx:        context.Background(),
+		id:         "sandbox",
+		hypervisor: &mockHypervisor{},
+		devManager: manager.NewDeviceManager(manager.VirtioSCSI, false, "", nil),
+		config:     &SandboxConfig{},
+		state:      types.SandboxState{BlockIndexMap: make(map[int]struct{})},
+	}
+
+	deviceInfo := config.DeviceInfo{
+		HostPath:      "/dev/hda",
+		ContainerPath: "/dev/hda",
+		DevType:       "b",
+		Major:         3,
+	}
+
+	var containers []*Container
+	for _, id := range []string{"foo", "bar"} {
+		device, err := sandbox.devManager.NewDevice(deviceInfo)
+		assert.NoError(err)
+
+		c := &Container{
+			sandbox: sandbox,
+			id:      id,
+			devices: []ContainerDevice{{ID: device.DeviceID(), ContainerPath: deviceInfo.ContainerPath}},
+		}
+		assert.NoError(c.attachDevices(c.devices))
+		containers = append(containers, c)
+	}
+
+	id := containers[0].devices[0].ID
+	assert.Equal(id, containers[1].devices[0].ID)
+	assert.Equal(uint(2), sandbox.devManager.GetDeviceByID(id).GetAttachCount())
+
+	// detaching the devices of the first container twice must not
+	// release the device used by the second one
+	for i := 0; i < 2; i++ {
+		assert.NoError(containers[0].detachDevices())
+		assert.Empty(containers[0].devices)
+		assert.True(sandbox.devManager.IsDeviceAttached(id))
+	}
+
+	assert.NoError(containers[1].detachDevices())
+	assert.False(sandbox.devManager.IsDeviceAttached(id))
+	assert.Nil(sandbox.devManager.GetDeviceByID(id))
+}
+
 func TestUnmountHostMountsRemoveBindHostPath(t *testing.T) {
 	if tc.NotValid(ktu.NeedRoot()) {
 		t.Skip(testDisabledAsNonRoot)
@@ -539,3 +590,100 @@ func TestProcessIOStream(t *testing.T) {
 	_, _, _, err = c.ioStream(processID)
 	assert.Error(err)
 }
+
+func TestIsPrivileged(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(isPrivileged(nil))
+	assert.False(isPrivileged(&specs.Spec{}))
+
+	spec := &specs.Spec{
+		Process: &specs.Process{
+			Capabilities: &specs.LinuxCapabilities{
+				Bounding: []string{"CAP_CHOWN", "CAP_SYS_ADMIN"},
+			},
+		},
+		Linux: &specs.Linux{},
+	}
+	assert.True(isPrivileged(spec))
+
+	spec.Linux.MaskedPaths = []string{"/proc/kcore"}
+	assert.False(isPrivileged(spec))
+
+	spec.Linux.MaskedPaths = nil
+	spec.Process.Capabilities.Bounding = []string{"CAP_CHOWN"}
+	assert.False(isPrivileged(spec))
+}
+
+func TestFilterPrivilegedDevices(t *testing.T) {
+	assert := assert.New(t)
+
+	contConfig := &ContainerConfig{
+		DeviceInfos: []config.DeviceInfo{
+			{ContainerPath: "/dev/fuse"},
+			{ContainerPath: "/dev/sda"},
+			{ContainerPath: "/dev/sdb1"},
+		},
+		CustomSpec: &specs.Spec{
+			Linux: &specs.Linux{
+				Devices: []specs.LinuxDevice{
+					{Path: "/dev/fuse"},
+					{Path: "/dev/sda"},
+					{Path: "/dev/sdb1"},
+				},
+			},
+		},
+	}
+
+	filterPrivilegedDevices(contConfig, []string{"/dev/fuse", "/dev/sd?1", "[invalid"})
+	assert.Equal([]config.DeviceInfo{{ContainerPath: "/dev/fuse"}, {ContainerPath: "/dev/sdb1"}}, contConfig.DeviceInfos)
+	assert.Equal([]specs.LinuxDevice{{Path: "/dev/fuse"}, {Path: "/dev/sdb1"}}, contConfig.CustomSpec.Linux.Devices)
+
+	// no device is allowed by default
+	filterPrivilegedDevices(contConfig, nil)
+	assert.Empty(contConfig.DeviceInfos)
+	assert.Empty(contConfig.CustomSpec.Linux.Devices)
+}
+
+func TestContainerCopyMount(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "copy-mount")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	secret := filepath.Join(dir, "secret")
+	assert.NoError(os.MkdirAll(filepath.Join(secret, "..data"), DirMode))
+	assert.NoError(ioutil.WriteFile(filepath.Join(secret, "..data", "tls.crt"), []byte("cert"), 0644))
+	assert.NoError(os.Symlink(filepath.Join("..data", "tls.crt"), filepath.Join(secret, "tls.crt")))
+
+	file := filepath.Join(dir, "resolv.conf")
+	assert.NoError(ioutil.WriteFile(file, []byte("nameserver 127.0.0.1"), 0644))
+
+	agent := &mountWatcherAgent{copies: make(map[string]string)}
+	c := &Container{
+		id:      "container",
+		sandbox: &Sandbox{agent: agent},
+	}
+
+	copied, err := c.copyMount(file, "/guest/resolv.conf")
+	assert.NoError(err)
+	assert.True(copied)
+	assert.Equal(file, agent.copies["/guest/resolv.conf"])
+
+	copied, err = c.copyMount(secret, "/guest/secret")
+	assert.NoError(err)
+	assert.True(copied)
+	assert.Equal(filepath.Join(secret, "..data", "tls.crt"), agent.copies["/guest/secret/tls.crt"])
+	assert.Len(agent.copies, 2)
+
+	// empty directories can't be copied
+	empty := filepath.Join(dir, "empty")
+	assert.NoError(os.MkdirAll(empty, DirMode))
+	copied, err = c.copyMount(empty, "/guest/empty")
+	assert.NoError(err)
+	assert.False(copied)
+
+	_, err = c.copyMount(filepath.Join(dir, "missing"), "/guest/missing")
+	assert.Error(err)
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+// cpuFeature is a CPU feature enabled or disabled in the guest.
+type cpuFeature struct {
+	name    string
+	enabled bool
+}
+
+var cpuFeatureNameRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// cpuFeatureNameReplacer converts the QEMU names of the CPU features to
+// the /proc/cpuinfo ones, e.g. sse4.1 is sse4_1.
+var cpuFeatureNameReplacer = strings.NewReplacer(".", "_", "-", "_")
+
+// cpuProperties are the CPU model properties which are not CPUID flags,
+// they can't be checked against the host CPU flags.
+var cpuProperties = map[string]bool{
+	"pmu":             true,
+	"migratable":      true,
+	"host_cache_info": true,
+	"l3_cache":        true,
+	"check":           true,
+	"enforce":         true,
+}
+
+// parseCPUFeatures parses a comma separated list of CPU features, each of
+// them being either "name=on", "name=off", "+name" or "-name".
+func parseCPUFeatures(features string) ([]cpuFeature, error) {
+	if features == "" {
+		return nil, nil
+	}
+
+	var parsed []cpuFeature
+	for _, f := range strings.Split(features, ",") {
+		var feature cpuFeature
+
+		switch {
+		case strings.HasPrefix(f, "+"):
+			feature = cpuFeature{name: f[1:], enabled: true}
+		case strings.HasPrefix(f, "-"):
+			feature = cpuFeature{name: f[1:]}
+		case strings.HasSuffix(f, "=on"):
+			feature = cpuFeature{name: strings.TrimSuffix(f, "=on"), enabled: true}
+		case strings.HasSuffix(f, "=off"):
+			feature = cpuFeature{name: strings.TrimSuffix(f, "=off")}
+		default:
+			return nil, fmt.Errorf("Invalid CPU feature %q, expected name=on, name=off, +name or -name", f)
+		}
+
+		if !cpuFeatureNameRegex.MatchString(feature.name) {
+			return nil, fmt.Errorf("Invalid CPU feature name %q", feature.name)
+		}
+
+		parsed = append(parsed, feature)
+	}
+
+	return parsed, nil
+}
+
+// hostCPUFlags returns the flags of the host CPU, nil if /proc/cpuinfo
+// doesn't list them on this architecture.
+func hostCPUFlags(cpuInfoPath string) (map[string]bool, error) {
+	if runtime.GOARCH != "amd64" {
+		return nil, nil
+	}
+
+	f, err := os.Open(cpuInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Expected format: ["flags", ":", ...] or ["flags:", ...]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.HasPrefix(fields[0], "flags") {
+			continue
+		}
+
+		flags := make(map[string]bool)
+		for _, field := range fields[1:] {
+			flags[field] = true
+		}
+
+		return flags, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("Couldn't find the CPU flags in %s", cpuInfoPath)
+}
+
+// checkCPUFeatures returns an error if one of the enabled features is not
+// provided by the host CPU, they're not emulated.
+func checkCPUFeatures(features []cpuFeature, cpuInfoPath string) error {
+	flags, err := hostCPUFlags(cpuInfoPath)
+	if err != nil || flags == nil {
+		return err
+	}
+
+	for _, f := range features {
+		name := cpuFeatureNameReplacer.Replace(f.name)
+		if !f.enabled || cpuProperties[name] || strings.HasPrefix(name, "kvm_") || strings.HasPrefix(name, "hv_") {
+			continue
+		}
+
+		if !flags[name] {
+			return fmt.Errorf("CPU feature %s is not supported by the host CPU", f.name)
+		}
+	}
+
+	return nil
+}
+
+// qemuCPUFeatures returns the -cpu properties of features.
+func qemuCPUFeatures(features []cpuFeature) string {
+	var props []string
+	for _, f := range features {
+		state := "off"
+		if f.enabled {
+			state = "on"
+		}
+		props = append(props, f.name+"="+state)
+	}
+
+	return strings.Join(props, ",")
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCPUFeatures(t *testing.T) {
+	assert := assert.New(t)
+
+	features, err := parseCPUFeatures("")
+	assert.NoError(err)
+	assert.Nil(features)
+
+	features, err = parseCPUFeatures("pmu=off,avx512f=on,+sse4.1,-hv-time")
+	assert.NoError(err)
+	assert.Equal([]cpuFeature{
+		{name: "pmu"},
+		{name: "avx512f", enabled: true},
+		{name: "sse4.1", enabled: true},
+		{name: "hv-time"},
+	}, features)
+	assert.Equal("pmu=off,avx512f=on,sse4.1=on,hv-time=off", qemuCPUFeatures(features))
+
+	for _, f := range []string{"avx512f", "avx512f=yes", "=on", "+", "pmu=off,", "avx,512f=on", "AVX=on", "x;y=on"} {
+		_, err = parseCPUFeatures(f)
+		assert.Error(err, f)
+	}
+}
+
+func TestCheckCPUFeatures(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "cpu-features")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cpuInfoPath := filepath.Join(dir, "cpuinfo")
+	err = ioutil.WriteFile(cpuInfoPath, []byte("processor\t: 0\nflags\t\t: fpu vmx sse4_1 avx\n"), os.FileMode(0640))
+	assert.NoError(err)
+
+	features, err := parseCPUFeatures("pmu=off,sse4.1=on,avx=on,avx512f=off,kvm-pv-eoi=on,hv-time=on")
+	assert.NoError(err)
+	assert.NoError(checkCPUFeatures(features, cpuInfoPath))
+
+	features, err = parseCPUFeatures("avx512f=on")
+	assert.NoError(err)
+	err = checkCPUFeatures(features, cpuInfoPath)
+	if runtime.GOARCH == "amd64" {
+		assert.Error(err)
+		assert.Error(checkCPUFeatures(features, filepath.Join(dir, "missing")))
+	} else {
+		assert.NoError(err)
+	}
+}
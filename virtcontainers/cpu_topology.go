@@ -0,0 +1,53 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// hostThreadsPerCore returns the number of hyperthreads of the host cores,
+// the siblings of the first CPU. The offline siblings are not listed, it's
+// 1 when SMT is disabled.
+func hostThreadsPerCore() (uint32, error) {
+	siblings, err := ioutil.ReadFile(filepath.Join(sysCPUPath, "cpu0", "topology", "thread_siblings_list"))
+	if err != nil {
+		return 0, err
+	}
+
+	cpus, err := parseCPUList(strings.TrimSpace(string(siblings)))
+	if err != nil {
+		return 0, err
+	}
+
+	if len(cpus) == 0 {
+		return 0, fmt.Errorf("No thread siblings for cpu0")
+	}
+
+	return uint32(len(cpus)), nil
+}
+
+// guestThreadsPerCore returns the number of hyperthreads of the guest
+// cores, the host one when the guest SMT is enabled.
+func guestThreadsPerCore(conf *HypervisorConfig) (uint32, error) {
+	if !conf.EnableGuestSMT {
+		return 1, nil
+	}
+
+	threads, err := hostThreadsPerCore()
+	if err != nil {
+		return 0, fmt.Errorf("Couldn't read the host CPU topology: %v", err)
+	}
+
+	if threads < 2 {
+		return 0, fmt.Errorf("Guest SMT can't be enabled, SMT is disabled or not supported by the host")
+	}
+
+	return threads, nil
+}
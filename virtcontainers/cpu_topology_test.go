@@ -0,0 +1,68 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// mockHostSMT mocks a host of 4 cores of threads hyperthreads.
+func mockHostSMT(t *testing.T, threads int) func() {
+	dir, err := ioutil.TempDir("", "cpu")
+	assert.NoError(t, err)
+
+	savedSysCPUPath := sysCPUPath
+	sysCPUPath = dir
+
+	mockCPUTopology(t, dir, 4*threads, func(cpu int) string {
+		core := cpu % 4
+		if threads == 1 {
+			return fmt.Sprintf("%d", core)
+		}
+		return fmt.Sprintf("%d,%d", core, core+4)
+	})
+
+	return func() {
+		sysCPUPath = savedSysCPUPath
+		os.RemoveAll(dir)
+	}
+}
+
+func TestHostThreadsPerCore(t *testing.T) {
+	assert := assert.New(t)
+
+	cleanup := mockHostSMT(t, 2)
+	threads, err := hostThreadsPerCore()
+	cleanup()
+	assert.NoError(err)
+	assert.Equal(uint32(2), threads)
+
+	cleanup = mockHostSMT(t, 1)
+	threads, err = hostThreadsPerCore()
+	cleanup()
+	assert.NoError(err)
+	assert.Equal(uint32(1), threads)
+}
+
+func TestGuestThreadsPerCore(t *testing.T) {
+	assert := assert.New(t)
+
+	cleanup := mockHostSMT(t, 1)
+	defer cleanup()
+
+	threads, err := guestThreadsPerCore(&HypervisorConfig{})
+	assert.NoError(err)
+	assert.Equal(uint32(1), threads)
+
+	// SMT is disabled on the host
+	_, err = guestThreadsPerCore(&HypervisorConfig{EnableGuestSMT: true})
+	assert.Error(err)
+}
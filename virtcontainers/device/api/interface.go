@@ -85,6 +85,7 @@ type DeviceManager interface {
 	NewDevice(config.DeviceInfo) (Device, error)
 	RemoveDevice(string) error
 	AttachDevice(string, DeviceReceiver) error
+	AttachDevices([]string, DeviceReceiver) error
 	DetachDevice(string, DeviceReceiver) error
 	IsDeviceAttached(string) bool
 	GetDeviceByID(string) Device
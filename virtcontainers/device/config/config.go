@@ -7,6 +7,9 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -31,6 +34,9 @@ const (
 	// DeviceGeneric is a generic device type
 	DeviceGeneric DeviceType = "generic"
 
+	// DeviceGuestChar is a character device provided by the guest kernel
+	DeviceGuestChar DeviceType = "guest-char"
+
 	//VhostUserSCSI - SCSI based vhost-user type
 	VhostUserSCSI = "vhost-user-scsi-pci"
 
@@ -61,12 +67,84 @@ const (
 	Nvdimm = "nvdimm"
 )
 
+const (
+	// BlockCacheNone bypasses the host page cache for the drive
+	BlockCacheNone = "none"
+
+	// BlockCacheWriteback uses the host page cache for the drive, writes
+	// being reported done once cached
+	BlockCacheWriteback = "writeback"
+
+	// BlockCacheWritethrough uses the host page cache for the drive, writes
+	// being reported done once on the disk
+	BlockCacheWritethrough = "writethrough"
+)
+
+// ValidBlockCacheMode returns an error if mode is not a block drive cache
+// mode, the empty mode leaving the hypervisor default.
+func ValidBlockCacheMode(mode string) error {
+	switch mode {
+	case "", BlockCacheNone, BlockCacheWriteback, BlockCacheWritethrough:
+		return nil
+	}
+
+	return fmt.Errorf("Invalid block drive cache mode %q", mode)
+}
+
+const (
+	// BlockNamingIndex lets the guest find the drives the hypervisor
+	// attaches without an address from their index, the N-th drive
+	// being named /dev/vdX, which assumes the drives are named in the
+	// order the hypervisor attaches them.
+	BlockNamingIndex = "index"
+
+	// BlockNamingSerial lets the agent find the drives from the ID the
+	// hypervisor reports for them, i.e. their serial number, whatever
+	// their order.
+	BlockNamingSerial = "serial"
+)
+
+// maxDriveSerialLen is the size of the ID of the virtio-blk devices.
+const maxDriveSerialLen = 20
+
+// ValidBlockNaming returns an error if naming is not a way the guest finds
+// the block drives, the empty naming meaning BlockNamingIndex.
+func ValidBlockNaming(naming string) error {
+	switch naming {
+	case "", BlockNamingIndex, BlockNamingSerial:
+		return nil
+	}
+
+	return fmt.Errorf("Invalid block device naming %q", naming)
+}
+
+// DriveSerial returns the serial number given to the drive identified by
+// driveID by the hypervisors setting one, short enough to be reported
+// whole by the virtio-blk devices.
+func DriveSerial(driveID string) string {
+	sum := sha256.Sum256([]byte(driveID))
+	return hex.EncodeToString(sum[:])[:maxDriveSerialLen]
+}
+
+// DriveWWN returns the World Wide Name given to the SCSI drive identified
+// by driveID, a locally assigned NAA (3) identifier the guest names the
+// drive after, e.g. /dev/disk/by-id/wwn-0x3..., whatever its address.
+func DriveWWN(driveID string) uint64 {
+	sum := sha256.Sum256([]byte(driveID))
+	return 0x3<<60 | binary.BigEndian.Uint64(sum[:8])>>4
+}
+
 const (
 	// Virtio9P means use virtio-9p for the shared file system
 	Virtio9P = "virtio-9p"
 
 	// VirtioFS means use virtio-fs for the shared file system
 	VirtioFS = "virtio-fs"
+
+	// NoSharedFS means the hypervisor shares no file system with the
+	// guest, the container rootfs being attached as block devices and
+	// the files of the volumes copied
+	NoSharedFS = "none"
 )
 
 const (
@@ -77,6 +155,27 @@ const (
 	VhostUserSCSIMajor = 242
 )
 
+// guestCharDevices are the character devices the guest kernel provides at
+// the same numbers as the host kernel, such as the devices used by the FUSE
+// file systems and the VPN clients. They're not attached to the VM, the agent
+// creates them in the container from the OCI spec.
+var guestCharDevices = map[[2]int64]string{
+	{10, 200}: "/dev/net/tun",
+	{10, 229}: "/dev/fuse",
+	{108, 0}:  "/dev/ppp",
+}
+
+// IsGuestCharDevice checks if devInfo is a character device the guest kernel
+// provides.
+func IsGuestCharDevice(devInfo DeviceInfo) bool {
+	if devInfo.DevType != "c" {
+		return false
+	}
+
+	_, ok := guestCharDevices[[2]int64{devInfo.Major, devInfo.Minor}]
+	return ok
+}
+
 // Defining these as a variable instead of a const, to allow
 // overriding this in the tests.
 
@@ -129,6 +228,12 @@ type DeviceInfo struct {
 	// ID for the device that is passed to the hypervisor.
 	ID string
 
+	// ReadOnly makes a block device read-only in the guest.
+	ReadOnly bool
+
+	// CacheMode is the cache mode of a block device, see BlockCacheNone.
+	CacheMode string
+
 	// DriverOptions is specific options for each device driver
 	// for example, for BlockDevice, we can set DriverOptions["blockDriver"]="virtio-blk"
 	DriverOptions map[string]string
@@ -165,6 +270,10 @@ type BlockDrive struct {
 	// VirtPath at which the device appears inside the VM, outside of the container mount namespace
 	VirtPath string
 
+	// Serial is the ID the hypervisor reports to the guest for the
+	// drive, empty when it doesn't report one
+	Serial string
+
 	// DevNo identifies the css bus id for virtio-blk-ccw
 	DevNo string
 
@@ -174,6 +283,10 @@ type BlockDrive struct {
 	// ReadOnly sets the device file readonly
 	ReadOnly bool
 
+	// CacheMode is the host cache mode of the drive, the hypervisor
+	// default being used when empty
+	CacheMode string
+
 	// Pmem enables persistent memory. Use File as backing file
 	// for a nvdimm device in the guest
 	Pmem bool
@@ -245,6 +358,10 @@ type VhostUserDeviceAttrs struct {
 	CacheSize uint32
 	Cache     string
 
+	// Reconnect is the delay in seconds between the attempts to
+	// reconnect to a restarted vhost-user daemon, 0 not to reconnect
+	Reconnect uint32
+
 	// PCIAddr is the PCI address used to identify the slot at which the drive is attached.
 	// It is only meaningful for vhost user block devices
 	PCIAddr string
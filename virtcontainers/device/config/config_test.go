@@ -71,3 +71,42 @@ func TestGetSysDevPathImpl(t *testing.T) {
 	assert.Contains(path, expectedFormat)
 	assert.Contains(path, "block")
 }
+
+func TestValidBlockCacheMode(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, mode := range []string{"", BlockCacheNone, BlockCacheWriteback, BlockCacheWritethrough} {
+		assert.NoError(ValidBlockCacheMode(mode), "mode: %q", mode)
+	}
+
+	assert.Error(ValidBlockCacheMode("unsafe"))
+	assert.Error(ValidBlockCacheMode("None"))
+}
+
+func TestValidBlockNaming(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, naming := range []string{"", BlockNamingIndex, BlockNamingSerial} {
+		assert.NoError(ValidBlockNaming(naming), "naming: %q", naming)
+	}
+
+	assert.Error(ValidBlockNaming("wwn"))
+}
+
+func TestDriveSerial(t *testing.T) {
+	assert := assert.New(t)
+
+	serial := DriveSerial("drive-b0e8c1a3d2f4")
+	assert.Len(serial, maxDriveSerialLen)
+	assert.Equal(serial, DriveSerial("drive-b0e8c1a3d2f4"))
+	assert.NotEqual(serial, DriveSerial("drive-b0e8c1a3d2f5"))
+}
+
+func TestDriveWWN(t *testing.T) {
+	assert := assert.New(t)
+
+	wwn := DriveWWN("drive-b0e8c1a3d2f4")
+	assert.Equal(uint64(0x3), wwn>>60)
+	assert.Equal(wwn, DriveWWN("drive-b0e8c1a3d2f4"))
+	assert.NotEqual(wwn, DriveWWN("drive-b0e8c1a3d2f5"))
+}
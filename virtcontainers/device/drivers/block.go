@@ -60,12 +60,18 @@ func (device *BlockDevice) Attach(devReceiver api.DeviceReceiver) (err error) {
 		return err
 	}
 
+	if err = config.ValidBlockCacheMode(device.DeviceInfo.CacheMode); err != nil {
+		return err
+	}
+
 	drive := &config.BlockDrive{
-		File:   device.DeviceInfo.HostPath,
-		Format: "raw",
-		ID:     utils.MakeNameID("drive", device.DeviceInfo.ID, maxDevIDSize),
-		Index:  index,
-		Pmem:   device.DeviceInfo.Pmem,
+		File:      device.DeviceInfo.HostPath,
+		Format:    "raw",
+		ID:        utils.MakeNameID("drive", device.DeviceInfo.ID, maxDevIDSize),
+		Index:     index,
+		Pmem:      device.DeviceInfo.Pmem,
+		ReadOnly:  device.DeviceInfo.ReadOnly,
+		CacheMode: device.DeviceInfo.CacheMode,
 	}
 
 	if fs, ok := device.DeviceInfo.DriverOptions["fstype"]; ok {
@@ -83,25 +89,10 @@ func (device *BlockDevice) Attach(devReceiver api.DeviceReceiver) (err error) {
 		}
 
 		drive.SCSIAddr = scsiAddr
-	} else if customOptions["block-driver"] != "nvdimm" {
-		var globalIdx int
-
-		switch customOptions["block-driver"] {
-		case "virtio-blk":
-			globalIdx = index
-		case "virtio-blk-ccw":
-			globalIdx = index
-		case "virtio-mmio":
-			//With firecracker the rootfs for the VM itself
-			//sits at /dev/vda and consumes the first index.
-			//Longer term block based VM rootfs should be added
-			//as a regular block device which eliminates the
-			//offset.
-			//https://github.com/kata-containers/runtime/issues/1061
-			globalIdx = index + 1
-		}
-
-		driveName, err := utils.GetVirtDriveName(globalIdx)
+	} else if customOptions["block-driver"] != "nvdimm" && customOptions["block-driver"] != "virtio-mmio" {
+		// The virtio-mmio drives are named by firecracker, which
+		// picks the drive of its disk pool the device is hot added to.
+		driveName, err := utils.GetVirtDriveName(index)
 		if err != nil {
 			return err
 		}
@@ -164,17 +155,20 @@ func (device *BlockDevice) Save() persistapi.DeviceState {
 	drive := device.BlockDrive
 	if drive != nil {
 		ds.BlockDrive = &persistapi.BlockDrive{
-			File:     drive.File,
-			Format:   drive.Format,
-			ID:       drive.ID,
-			Index:    drive.Index,
-			MmioAddr: drive.MmioAddr,
-			PCIAddr:  drive.PCIAddr,
-			SCSIAddr: drive.SCSIAddr,
-			NvdimmID: drive.NvdimmID,
-			VirtPath: drive.VirtPath,
-			DevNo:    drive.DevNo,
-			Pmem:     drive.Pmem,
+			File:      drive.File,
+			Format:    drive.Format,
+			ID:        drive.ID,
+			Index:     drive.Index,
+			MmioAddr:  drive.MmioAddr,
+			PCIAddr:   drive.PCIAddr,
+			SCSIAddr:  drive.SCSIAddr,
+			NvdimmID:  drive.NvdimmID,
+			VirtPath:  drive.VirtPath,
+			Serial:    drive.Serial,
+			DevNo:     drive.DevNo,
+			Pmem:      drive.Pmem,
+			ReadOnly:  drive.ReadOnly,
+			CacheMode: drive.CacheMode,
 		}
 	}
 	return ds
@@ -190,17 +184,20 @@ func (device *BlockDevice) Load(ds persistapi.DeviceState) {
 		return
 	}
 	device.BlockDrive = &config.BlockDrive{
-		File:     bd.File,
-		Format:   bd.Format,
-		ID:       bd.ID,
-		Index:    bd.Index,
-		MmioAddr: bd.MmioAddr,
-		PCIAddr:  bd.PCIAddr,
-		SCSIAddr: bd.SCSIAddr,
-		NvdimmID: bd.NvdimmID,
-		VirtPath: bd.VirtPath,
-		DevNo:    bd.DevNo,
-		Pmem:     bd.Pmem,
+		File:      bd.File,
+		Format:    bd.Format,
+		ID:        bd.ID,
+		Index:     bd.Index,
+		MmioAddr:  bd.MmioAddr,
+		PCIAddr:   bd.PCIAddr,
+		SCSIAddr:  bd.SCSIAddr,
+		NvdimmID:  bd.NvdimmID,
+		VirtPath:  bd.VirtPath,
+		Serial:    bd.Serial,
+		DevNo:     bd.DevNo,
+		Pmem:      bd.Pmem,
+		ReadOnly:  bd.ReadOnly,
+		CacheMode: bd.CacheMode,
 	}
 }
 
@@ -0,0 +1,41 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package drivers
+
+import (
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+)
+
+// GuestCharDevice refers to a character device the guest kernel provides at
+// the same numbers as the host kernel, e.g. /dev/fuse or /dev/net/tun.
+// Nothing is attached to the VM, the agent creates the device node in the
+// container.
+type GuestCharDevice struct {
+	GenericDevice
+}
+
+// NewGuestCharDevice creates a new GuestCharDevice
+func NewGuestCharDevice(devInfo *config.DeviceInfo) *GuestCharDevice {
+	return &GuestCharDevice{
+		GenericDevice: GenericDevice{
+			ID:         devInfo.ID,
+			DeviceInfo: devInfo,
+		},
+	}
+}
+
+// DeviceType is standard interface of api.Device, it returns device type
+func (device *GuestCharDevice) DeviceType() config.DeviceType {
+	return config.DeviceGuestChar
+}
+
+// Save converts Device to DeviceState
+func (device *GuestCharDevice) Save() persistapi.DeviceState {
+	dss := device.GenericDevice.Save()
+	dss.Type = string(device.DeviceType())
+	return dss
+}
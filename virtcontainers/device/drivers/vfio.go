@@ -76,7 +76,9 @@ func (device *VFIODevice) Attach(devReceiver api.DeviceReceiver) (retErr error)
 		return err
 	}
 
-	// Pass all devices in iommu group
+	// Pass all devices in iommu group, the group may have been attached
+	// and detached already.
+	device.VfioDevs = nil
 	for i, deviceFile := range deviceFiles {
 		//Get bdf of device eg 0000:00:1c.0
 		deviceBDF, deviceSysfsDev, vfioDeviceType, err := getVFIODetails(deviceFile.Name(), iommuDevicesPath)
@@ -17,6 +17,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/device/drivers"
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 )
 
@@ -38,12 +39,12 @@ var (
 	// and no more IDs can be generated
 	ErrIDExhausted = errors.New("IDs are exhausted")
 	// ErrDeviceNotExist represents device hasn't been created before
-	ErrDeviceNotExist = errors.New("device with specified ID hasn't been created")
+	ErrDeviceNotExist = vcTypes.Errorf(vcTypes.ErrCodeNotFound, "device with specified ID hasn't been created")
 	// ErrDeviceNotAttached represents the device isn't attached
 	ErrDeviceNotAttached = errors.New("device isn't attached")
 	// ErrRemoveAttachedDevice represents the device isn't detached
 	// so not allow to remove from list
-	ErrRemoveAttachedDevice = errors.New("can't remove attached device")
+	ErrRemoveAttachedDevice = vcTypes.Errorf(vcTypes.ErrCodeDeviceBusy, "can't remove attached device")
 )
 
 type deviceManager struct {
@@ -86,12 +87,30 @@ func NewDeviceManager(blockDriver string, vhostUserStoreEnabled bool, vhostUserS
 	return dm
 }
 
-func (dm *deviceManager) findDeviceByMajorMinor(major, minor int64) api.Device {
+// findDevice returns the device previously created for devInfo, so that a
+// device used by several containers is attached to the sandbox only once.
+func (dm *deviceManager) findDevice(devInfo config.DeviceInfo) api.Device {
 	for _, dev := range dm.devices {
 		dma, dmi := dev.GetMajorMinor()
-		if dma == major && dmi == minor {
-			return dev
+		if dma != devInfo.Major || dmi != devInfo.Minor {
+			continue
+		}
+
+		// the major and minor numbers of a pmem device are the ones of
+		// the filesystem holding its backing file, they are shared by
+		// all the files of that filesystem.
+		pmem := false
+		if b, ok := dev.(*drivers.BlockDevice); ok && b.DeviceInfo != nil {
+			pmem = b.DeviceInfo.Pmem
+			if pmem && b.DeviceInfo.HostPath != devInfo.HostPath {
+				continue
+			}
 		}
+		if pmem != devInfo.Pmem {
+			continue
+		}
+
+		return dev
 	}
 	return nil
 }
@@ -114,7 +133,7 @@ func (dm *deviceManager) createDevice(devInfo config.DeviceInfo) (dev api.Device
 		}
 	}()
 
-	if existingDev := dm.findDeviceByMajorMinor(devInfo.Major, devInfo.Minor); existingDev != nil {
+	if existingDev := dm.findDevice(devInfo); existingDev != nil {
 		return existingDev, nil
 	}
 
@@ -125,6 +144,8 @@ func (dm *deviceManager) createDevice(devInfo config.DeviceInfo) (dev api.Device
 	}
 	if isVFIO(devInfo.HostPath) {
 		return drivers.NewVFIODevice(&devInfo), nil
+	} else if config.IsGuestCharDevice(devInfo) {
+		return drivers.NewGuestCharDevice(&devInfo), nil
 	} else if isVhostUserBlk(devInfo) {
 		if devInfo.DriverOptions == nil {
 			devInfo.DriverOptions = make(map[string]string)
@@ -165,6 +186,9 @@ func (dm *deviceManager) RemoveDevice(id string) error {
 
 	if dev.Dereference() == 0 {
 		if dev.GetAttachCount() > 0 {
+			// don't drop the reference of a device which can't
+			// be removed, it would be released twice.
+			dev.Reference()
 			return ErrRemoveAttachedDevice
 		}
 		delete(dm.devices, id)
@@ -204,6 +228,40 @@ func (dm *deviceManager) AttachDevice(id string, dr api.DeviceReceiver) error {
 	return nil
 }
 
+// AttachDevices attaches several devices, one after the other in order:
+// the hypervisors handle a single hotplug at a time. The devices attached
+// are detached if one of them fails, whose error is returned.
+func (dm *deviceManager) AttachDevices(ids []string, dr api.DeviceReceiver) error {
+	dm.Lock()
+	defer dm.Unlock()
+
+	var devices []api.Device
+	for _, id := range ids {
+		d, ok := dm.devices[id]
+		if !ok {
+			return ErrDeviceNotExist
+		}
+		devices = append(devices, d)
+	}
+
+	for i, d := range devices {
+		err := d.Attach(dr)
+		if err == nil {
+			continue
+		}
+
+		for j := i - 1; j >= 0; j-- {
+			if err := devices[j].Detach(dr); err != nil {
+				deviceLogger().WithField("device", devices[j].DeviceID()).WithError(err).Error("Could not detach device after attach failure")
+			}
+		}
+
+		return err
+	}
+
+	return nil
+}
+
 func (dm *deviceManager) DetachDevice(id string, dr api.DeviceReceiver) error {
 	dm.Lock()
 	defer dm.Unlock()
@@ -262,6 +320,8 @@ func (dm *deviceManager) LoadDevices(devStates []persistapi.DeviceState) {
 		switch config.DeviceType(ds.Type) {
 		case config.DeviceGeneric:
 			dev = &drivers.GenericDevice{}
+		case config.DeviceGuestChar:
+			dev = &drivers.GuestCharDevice{}
 		case config.DeviceBlock:
 			dev = &drivers.BlockDevice{}
 		case config.DeviceVFIO:
@@ -7,17 +7,20 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/device/drivers"
+	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
 	"github.com/stretchr/testify/assert"
 
 	"golang.org/x/sys/unix"
@@ -170,6 +173,47 @@ func TestAttachGenericDevice(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestAttachGuestCharDevice(t *testing.T) {
+	dm := &deviceManager{
+		blockDriver: VirtioBlock,
+		devices:     make(map[string]api.Device),
+	}
+	path := "/dev/fuse"
+	deviceInfo := config.DeviceInfo{
+		HostPath:      path,
+		ContainerPath: path,
+		DevType:       "c",
+		Major:         10,
+		Minor:         229,
+	}
+
+	device, err := dm.NewDevice(deviceInfo)
+	assert.Nil(t, err)
+	_, ok := device.(*drivers.GuestCharDevice)
+	assert.True(t, ok)
+
+	devReceiver := &api.MockDeviceReceiver{}
+	err = device.Attach(devReceiver)
+	assert.Nil(t, err)
+	assert.Equal(t, uint(1), device.GetAttachCount())
+
+	dm.LoadDevices([]persistapi.DeviceState{device.Save()})
+	loaded := dm.GetDeviceByID(device.DeviceID())
+	_, ok = loaded.(*drivers.GuestCharDevice)
+	assert.True(t, ok)
+	assert.Equal(t, uint(1), loaded.GetAttachCount())
+
+	err = loaded.Detach(devReceiver)
+	assert.Nil(t, err)
+
+	// the other char devices are not created in the guest
+	deviceInfo.Minor = 230
+	device, err = dm.NewDevice(deviceInfo)
+	assert.Nil(t, err)
+	_, ok = device.(*drivers.GenericDevice)
+	assert.True(t, ok)
+}
+
 func TestAttachBlockDevice(t *testing.T) {
 	dm := &deviceManager{
 		blockDriver: VirtioBlock,
@@ -332,3 +376,171 @@ func TestAttachDetachDevice(t *testing.T) {
 	err = dm.RemoveDevice(device.DeviceID())
 	assert.Nil(t, err)
 }
+
+type countingDeviceReceiver struct {
+	api.MockDeviceReceiver
+	plugged int
+}
+
+func (r *countingDeviceReceiver) HotplugAddDevice(api.Device, config.DeviceType) error {
+	r.plugged++
+	return nil
+}
+
+func (r *countingDeviceReceiver) HotplugRemoveDevice(api.Device, config.DeviceType) error {
+	r.plugged--
+	return nil
+}
+
+func TestSharedDevice(t *testing.T) {
+	assert := assert.New(t)
+	dm := NewDeviceManager(VirtioBlock, false, "", nil)
+	devReceiver := &countingDeviceReceiver{}
+
+	deviceInfo := config.DeviceInfo{
+		HostPath:      "/dev/hda",
+		ContainerPath: "/dev/hda",
+		DevType:       "b",
+		Major:         3,
+	}
+
+	first, err := dm.NewDevice(deviceInfo)
+	assert.NoError(err)
+	second, err := dm.NewDevice(deviceInfo)
+	assert.NoError(err)
+	assert.Equal(first.DeviceID(), second.DeviceID())
+	id := first.DeviceID()
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(dm.AttachDevice(id, devReceiver))
+	}
+	assert.Equal(1, devReceiver.plugged)
+
+	assert.NoError(dm.DetachDevice(id, devReceiver))
+	assert.NoError(dm.RemoveDevice(id))
+	assert.Equal(1, devReceiver.plugged)
+	assert.NotNil(dm.GetDeviceByID(id))
+
+	assert.NoError(dm.DetachDevice(id, devReceiver))
+	assert.NoError(dm.RemoveDevice(id))
+	assert.Equal(0, devReceiver.plugged)
+	assert.Nil(dm.GetDeviceByID(id))
+}
+
+type failingDeviceReceiver struct {
+	api.MockDeviceReceiver
+	sync.Mutex
+	failPath string
+	plugged  map[string]bool
+}
+
+func (r *failingDeviceReceiver) HotplugAddDevice(dev api.Device, devType config.DeviceType) error {
+	r.Lock()
+	defer r.Unlock()
+
+	drive := dev.GetDeviceInfo().(*config.BlockDrive)
+	if drive.File == r.failPath {
+		return errors.New("hotplug failed")
+	}
+	r.plugged[drive.File] = true
+	return nil
+}
+
+func (r *failingDeviceReceiver) HotplugRemoveDevice(dev api.Device, devType config.DeviceType) error {
+	r.Lock()
+	defer r.Unlock()
+
+	delete(r.plugged, dev.GetDeviceInfo().(*config.BlockDrive).File)
+	return nil
+}
+
+func TestAttachDevices(t *testing.T) {
+	assert := assert.New(t)
+	dm := NewDeviceManager(VirtioBlock, false, "", nil)
+	devReceiver := &failingDeviceReceiver{plugged: make(map[string]bool)}
+
+	var ids []string
+	for i := 0; i < 8; i++ {
+		path := fmt.Sprintf("/dev/vd%c", 'a'+i)
+		device, err := dm.NewDevice(config.DeviceInfo{
+			HostPath:      path,
+			ContainerPath: path,
+			DevType:       "b",
+			Major:         252,
+			Minor:         int64(i),
+		})
+		assert.NoError(err)
+		ids = append(ids, device.DeviceID())
+	}
+
+	assert.Equal(ErrDeviceNotExist, dm.AttachDevices([]string{ids[0], "non-exist"}, devReceiver))
+	assert.False(dm.IsDeviceAttached(ids[0]))
+
+	// the devices attached are detached when one fails
+	devReceiver.failPath = "/dev/vdc"
+	assert.Error(dm.AttachDevices(ids, devReceiver))
+	assert.Empty(devReceiver.plugged)
+	for _, id := range ids {
+		assert.False(dm.IsDeviceAttached(id))
+	}
+
+	// a device listed twice is attached twice
+	devReceiver.failPath = ""
+	assert.NoError(dm.AttachDevices(append(ids, ids[0]), devReceiver))
+	assert.Len(devReceiver.plugged, len(ids))
+	assert.Equal(uint(2), dm.GetDeviceByID(ids[0]).GetAttachCount())
+	assert.Equal(uint(1), dm.GetDeviceByID(ids[1]).GetAttachCount())
+}
+
+func TestRemoveAttachedDevice(t *testing.T) {
+	assert := assert.New(t)
+	dm := NewDeviceManager(VirtioBlock, false, "", nil)
+	devReceiver := &api.MockDeviceReceiver{}
+
+	deviceInfo := config.DeviceInfo{
+		HostPath:      "/dev/hda",
+		ContainerPath: "/dev/hda",
+		DevType:       "b",
+		Major:         3,
+	}
+
+	device, err := dm.NewDevice(deviceInfo)
+	assert.NoError(err)
+	id := device.DeviceID()
+	assert.NoError(dm.AttachDevice(id, devReceiver))
+
+	// the reference is kept when the removal is refused
+	assert.Equal(ErrRemoveAttachedDevice, dm.RemoveDevice(id))
+
+	_, err = dm.NewDevice(deviceInfo)
+	assert.NoError(err)
+	assert.NoError(dm.DetachDevice(id, devReceiver))
+	assert.NoError(dm.RemoveDevice(id))
+	assert.NotNil(dm.GetDeviceByID(id))
+}
+
+func TestNewPmemDevices(t *testing.T) {
+	assert := assert.New(t)
+	dm := NewDeviceManager(VirtioBlock, false, "", nil)
+
+	// pmem files of the same filesystem share their major and minor
+	deviceInfo := config.DeviceInfo{
+		HostPath: "/mnt/foo.img",
+		DevType:  "b",
+		Major:    253,
+		Minor:    1,
+		Pmem:     true,
+	}
+
+	first, err := dm.NewDevice(deviceInfo)
+	assert.NoError(err)
+
+	deviceInfo.HostPath = "/mnt/bar.img"
+	second, err := dm.NewDevice(deviceInfo)
+	assert.NoError(err)
+	assert.NotEqual(first.DeviceID(), second.DeviceID())
+
+	third, err := dm.NewDevice(deviceInfo)
+	assert.NoError(err)
+	assert.Equal(second.DeviceID(), third.DeviceID())
+}
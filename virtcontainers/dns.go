@@ -0,0 +1,153 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+)
+
+// maxDNSServers is the number of name servers the resolver of the libc
+// uses (MAXNS), the following ones are ignored.
+const maxDNSServers = 3
+
+// DNSConfig is the DNS configuration of a sandbox, as set by the CRI: the
+// name servers, the search domains and the resolver options of the
+// resolv.conf of its guest and containers.
+type DNSConfig struct {
+	Servers  []string
+	Searches []string
+	Options  []string
+}
+
+// IsEmpty returns true when the configuration has no setting.
+func (c DNSConfig) IsEmpty() bool {
+	return len(c.Servers) == 0 && len(c.Searches) == 0 && len(c.Options) == 0
+}
+
+// override returns the configuration with the settings of o replacing
+// its own ones, the empty settings of o being kept.
+func (c DNSConfig) override(o DNSConfig) DNSConfig {
+	if len(o.Servers) > 0 {
+		c.Servers = o.Servers
+	}
+
+	if len(o.Searches) > 0 {
+		c.Searches = o.Searches
+	}
+
+	if len(o.Options) > 0 {
+		c.Options = o.Options
+	}
+
+	return c
+}
+
+// resolvConf returns the lines of the resolv.conf of the configuration.
+func (c DNSConfig) resolvConf() []string {
+	var lines []string
+
+	for _, s := range c.Servers {
+		lines = append(lines, "nameserver "+s)
+	}
+
+	if len(c.Searches) > 0 {
+		lines = append(lines, "search "+strings.Join(c.Searches, " "))
+	}
+
+	if len(c.Options) > 0 {
+		lines = append(lines, "options "+strings.Join(c.Options, " "))
+	}
+
+	return lines
+}
+
+// CheckDNSConfig checks the name servers of the configuration are IP
+// addresses and that there aren't more than the resolver uses.
+func CheckDNSConfig(c DNSConfig) error {
+	if len(c.Servers) > maxDNSServers {
+		return fmt.Errorf("Too many DNS servers %v, at most %d are used", c.Servers, maxDNSServers)
+	}
+
+	for _, s := range c.Servers {
+		if net.ParseIP(s) == nil {
+			return fmt.Errorf("Invalid DNS server %q, expecting an IP address", s)
+		}
+	}
+
+	return nil
+}
+
+// parseResolvConf returns the DNS configuration of a resolv.conf, the
+// "domain" keyword being a single search domain as for the resolver.
+func parseResolvConf(content []byte) DNSConfig {
+	var c DNSConfig
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.HasPrefix(fields[0], ";") {
+			continue
+		}
+
+		switch fields[0] {
+		case "nameserver":
+			if len(fields) > 1 {
+				c.Servers = append(c.Servers, fields[1])
+			}
+		case "domain", "search":
+			// the last one wins
+			c.Searches = fields[1:]
+		case "options":
+			c.Options = append(c.Options, fields[1:]...)
+		}
+	}
+
+	return c
+}
+
+// guestDNS returns true when the resolv.conf of the guest and of the
+// containers is generated by the agent from the DNS configuration of the
+// sandbox, rather than shared from the host.
+func (s *Sandbox) guestDNS() bool {
+	return s.config.GuestDNS || !s.config.DNS.IsEmpty()
+}
+
+// sandboxDNS returns the lines of the resolv.conf of the guest, from the
+// resolv.conf mounted in the sandbox container by the container manager,
+// overridden by the DNS configuration of the sandbox when the agent
+// generates it.
+func (s *Sandbox) sandboxDNS() ([]string, error) {
+	var content []byte
+
+	if ociSpec := s.GetPatchedOCISpec(); ociSpec != nil {
+		for _, m := range ociSpec.Mounts {
+			if m.Destination != GuestDNSFile {
+				continue
+			}
+
+			data, err := ioutil.ReadFile(m.Source)
+			if err != nil {
+				return nil, fmt.Errorf("Could not read file %s: %s", m.Source, err)
+			}
+			content = data
+			break
+		}
+	}
+
+	if !s.guestDNS() {
+		if content == nil {
+			return nil, nil
+		}
+		return strings.Split(string(content), "\n"), nil
+	}
+
+	return parseResolvConf(content).override(s.config.DNS).resolvConf(), nil
+}
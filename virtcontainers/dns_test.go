@@ -0,0 +1,150 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/kata-containers/runtime/virtcontainers/device/manager"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const testResolvConf = `# generated by the kubelet
+nameserver 10.96.0.10
+nameserver 10.96.0.11
+search default.svc.cluster.local svc.cluster.local
+options ndots:5
+options timeout:1
+`
+
+func TestParseResolvConf(t *testing.T) {
+	assert := assert.New(t)
+
+	c := parseResolvConf([]byte(testResolvConf))
+	assert.Equal(DNSConfig{
+		Servers:  []string{"10.96.0.10", "10.96.0.11"},
+		Searches: []string{"default.svc.cluster.local", "svc.cluster.local"},
+		Options:  []string{"ndots:5", "timeout:1"},
+	}, c)
+
+	// the last domain or search line wins
+	c = parseResolvConf([]byte("search foo bar\ndomain example.com\n"))
+	assert.Equal([]string{"example.com"}, c.Searches)
+
+	assert.True(parseResolvConf(nil).IsEmpty())
+}
+
+func TestDNSConfigOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	c := parseResolvConf([]byte(testResolvConf)).override(DNSConfig{
+		Servers: []string{"1.1.1.1"},
+	})
+
+	assert.Equal([]string{
+		"nameserver 1.1.1.1",
+		"search default.svc.cluster.local svc.cluster.local",
+		"options ndots:5 timeout:1",
+	}, c.resolvConf())
+}
+
+func TestCheckDNSConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(CheckDNSConfig(DNSConfig{}))
+	assert.NoError(CheckDNSConfig(DNSConfig{Servers: []string{"10.96.0.10", "2001:db8::53"}}))
+	assert.Error(CheckDNSConfig(DNSConfig{Servers: []string{"kube-dns"}}))
+	assert.Error(CheckDNSConfig(DNSConfig{Servers: []string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"}}))
+}
+
+func TestSandboxDNS(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	resolvConf := filepath.Join(tmpdir, "resolv.conf")
+	assert.NoError(ioutil.WriteFile(resolvConf, []byte("nameserver 10.96.0.10\n"), 0644))
+
+	s := &Sandbox{
+		config: &SandboxConfig{
+			Containers: []ContainerConfig{
+				{
+					Annotations: map[string]string{
+						annotations.ContainerTypeKey: string(PodSandbox),
+					},
+					CustomSpec: &specs.Spec{
+						Mounts: []specs.Mount{
+							{Source: resolvConf, Destination: GuestDNSFile},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	// the host file is passed as is
+	dns, err := s.sandboxDNS()
+	assert.NoError(err)
+	assert.Equal([]string{"nameserver 10.96.0.10", ""}, dns)
+
+	s.config.DNS = DNSConfig{Options: []string{"ndots:2"}}
+	dns, err = s.sandboxDNS()
+	assert.NoError(err)
+	assert.Equal([]string{"nameserver 10.96.0.10", "options ndots:2"}, dns)
+
+	// no resolv.conf mounted in the sandbox container
+	s.config.Containers[0].CustomSpec.Mounts = nil
+	dns, err = s.sandboxDNS()
+	assert.NoError(err)
+	assert.Equal([]string{"options ndots:2"}, dns)
+}
+
+func TestGuestDNSContainerMount(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{
+		id:         testSandboxID,
+		hypervisor: &mockHypervisor{},
+		config: &SandboxConfig{
+			GuestDNS: true,
+		},
+		devManager: manager.NewDeviceManager(config.VirtioBlock, false, "", nil),
+		ctx:        context.Background(),
+	}
+
+	c := Container{
+		sandbox: s,
+		id:      "100",
+		mounts: []Mount{
+			{
+				Source:      "/var/lib/kubelet/pods/resolv.conf",
+				Destination: GuestDNSFile,
+				Type:        "bind",
+				Options:     []string{"rbind", "ro"},
+			},
+		},
+	}
+
+	mounts, ignored, err := c.mountSharedDirMounts("", "")
+	assert.NoError(err)
+	assert.Empty(ignored)
+	assert.Equal(Mount{
+		Source:      GuestDNSFile,
+		Destination: GuestDNSFile,
+		Type:        "bind",
+		Options:     []string{"rbind", "ro"},
+		ReadOnly:    true,
+	}, mounts[GuestDNSFile])
+}
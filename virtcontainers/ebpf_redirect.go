@@ -0,0 +1,73 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// The ebpf internetworking model connects the network interface and the
+// tap device like the tcfilter one, but the traffic is redirected by a tc
+// program attached in direct-action mode to the ingress qdiscs, rather than
+// matched by a u32 filter and redirected by a mirred action.
+
+// redirectProgName is the name of the redirect programs, as reported by
+// `tc filter show` and bpftool.
+const redirectProgName = "kata_redirect"
+
+// redirectInstructions returns the program redirecting all the packets to
+// the egress of the interface with index "destIndex". bpf_redirect()
+// returns TC_ACT_REDIRECT, which is the verdict of the program.
+func redirectInstructions(destIndex int) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Imm(asm.R1, int32(destIndex)),
+		asm.Mov.Imm(asm.R2, 0),
+		asm.FnRedirect.Call(),
+		asm.Return(),
+	}
+}
+
+// addRedirectBPFFilter adds a tc bpf filter for device with index
+// "sourceIndex", redirecting all its traffic to interface with index
+// "destIndex".
+//
+// This is equivalent to calling:
+// `tc filter add dev source parent ffff: protocol all bpf direct-action obj redirect.o`
+func addRedirectBPFFilter(sourceIndex, destIndex int) error {
+	prog, err := ebpf.NewProgram(&ebpf.ProgramSpec{
+		Name:         redirectProgName,
+		Type:         ebpf.SchedCLS,
+		Instructions: redirectInstructions(destIndex),
+		License:      "GPL",
+	})
+	if err != nil {
+		return fmt.Errorf("Failed to load redirect program for index %d : %s", destIndex, err)
+	}
+	// the filter holds a reference on the program
+	defer prog.Close()
+
+	filter := &netlink.BpfFilter{
+		FilterAttrs: netlink.FilterAttrs{
+			LinkIndex: sourceIndex,
+			Parent:    netlink.MakeHandle(0xffff, 0),
+			Protocol:  unix.ETH_P_ALL,
+		},
+		Fd:           prog.FD(),
+		Name:         redirectProgName,
+		DirectAction: true,
+	}
+
+	if err := netlink.FilterAdd(filter); err != nil {
+		return fmt.Errorf("Failed to add bpf filter for index %d : %s", sourceIndex, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,28 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"testing"
+
+	"github.com/cilium/ebpf/asm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedirectInstructions(t *testing.T) {
+	assert := assert.New(t)
+
+	insns := redirectInstructions(42)
+	assert.Len(insns, 4)
+
+	// bpf_redirect(42, 0) redirects to the egress of the interface
+	assert.Equal(asm.R1, insns[0].Dst)
+	assert.Equal(int64(42), insns[0].Constant)
+	assert.Equal(asm.R2, insns[1].Dst)
+	assert.Equal(int64(0), insns[1].Constant)
+	assert.Equal(asm.FnRedirect.Call(), insns[2])
+	assert.Equal(asm.Return(), insns[3])
+}
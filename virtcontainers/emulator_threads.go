@@ -0,0 +1,145 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	schedFIFO = 1
+
+	minNice       = -20
+	maxNice       = 19
+	maxRTPriority = 99
+)
+
+// emulatorThreadsTuned returns if the threads of the hypervisor other than
+// the vCPUs are pinned or reprioritized.
+func (conf *HypervisorConfig) emulatorThreadsTuned() bool {
+	return conf.EmulatorThreadsCPUs != "" || conf.EmulatorThreadsNice != 0 || conf.EmulatorRTPriority != 0
+}
+
+// validateEmulatorThreads checks the settings of the emulator threads.
+func (conf *HypervisorConfig) validateEmulatorThreads(errs *ConfigErrors) {
+	if conf.EmulatorThreadsCPUs != "" {
+		if cpus, err := parseCPUList(conf.EmulatorThreadsCPUs); err != nil {
+			errs.add("EmulatorThreadsCPUs", "%v", err)
+		} else if len(cpus) == 0 {
+			errs.add("EmulatorThreadsCPUs", "no CPU in %q", conf.EmulatorThreadsCPUs)
+		}
+	}
+
+	if conf.EmulatorThreadsNice < minNice || conf.EmulatorThreadsNice > maxNice {
+		errs.add("EmulatorThreadsNice", "%d is not a nice value between %d and %d", conf.EmulatorThreadsNice, minNice, maxNice)
+	}
+
+	if conf.EmulatorRTPriority > maxRTPriority {
+		errs.add("EmulatorRTPriority", "%d is more than the highest real-time priority of %d", conf.EmulatorRTPriority, maxRTPriority)
+	}
+}
+
+// tuneEmulatorThreads pins the threads of the hypervisor processes other
+// than the vCPUs, i.e. the API, I/O and virtio queue threads, to their
+// CPUs and sets their priority. It's run again when the hypervisor may
+// have created threads.
+func (s *Sandbox) tuneEmulatorThreads() error {
+	conf := &s.config.HypervisorConfig
+	if !conf.emulatorThreadsTuned() {
+		return nil
+	}
+
+	var cpus unix.CPUSet
+	if conf.EmulatorThreadsCPUs != "" {
+		list, err := parseCPUList(conf.EmulatorThreadsCPUs)
+		if err != nil {
+			return err
+		}
+
+		for _, cpu := range list {
+			cpus.Set(cpu)
+		}
+	}
+
+	tids, err := s.hypervisor.getThreadIDs()
+	if err != nil {
+		return fmt.Errorf("failed to get thread ids from hypervisor: %v", err)
+	}
+
+	vcpus := make(map[int]bool)
+	for _, tid := range tids.vcpus {
+		vcpus[tid] = true
+	}
+
+	for _, pid := range s.hypervisor.getPids() {
+		if pid <= 0 {
+			continue
+		}
+
+		threads, err := processThreads(pid)
+		if err != nil {
+			return err
+		}
+
+		for _, tid := range threads {
+			if vcpus[tid] {
+				continue
+			}
+
+			if err := tuneEmulatorThread(tid, conf, &cpus); err != nil {
+				return fmt.Errorf("Could not tune thread %d of hypervisor PID %d: %v", tid, pid, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func tuneEmulatorThread(tid int, conf *HypervisorConfig, cpus *unix.CPUSet) error {
+	if cpus.Count() > 0 {
+		if err := unix.SchedSetaffinity(tid, cpus); err != nil {
+			return err
+		}
+	}
+
+	if conf.EmulatorThreadsNice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, tid, int(conf.EmulatorThreadsNice)); err != nil {
+			return err
+		}
+	}
+
+	if conf.EmulatorRTPriority != 0 {
+		param := struct{ priority int32 }{int32(conf.EmulatorRTPriority)}
+		if _, _, errno := unix.RawSyscall(unix.SYS_SCHED_SETSCHEDULER, uintptr(tid), schedFIFO, uintptr(unsafe.Pointer(&param))); errno != 0 {
+			return errno
+		}
+	}
+
+	return nil
+}
+
+// processThreads returns the IDs of the threads of the process.
+func processThreads(pid int) ([]int, error) {
+	tasks, err := ioutil.ReadDir(filepath.Join(procRoot, strconv.Itoa(pid), "task"))
+	if err != nil {
+		return nil, err
+	}
+
+	var tids []int
+	for _, task := range tasks {
+		if tid, err := strconv.Atoi(task.Name()); err == nil {
+			tids = append(tids, tid)
+		}
+	}
+
+	return tids, nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestValidateEmulatorThreads(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := newQemuConfig()
+	conf.EmulatorThreadsCPUs = "0-1,4"
+	conf.EmulatorThreadsNice = -5
+	conf.EmulatorRTPriority = 10
+	assert.NoError(conf.Validate(QemuHypervisor))
+
+	conf.EmulatorThreadsCPUs = "2-1"
+	conf.EmulatorThreadsNice = 20
+	conf.EmulatorRTPriority = 100
+
+	err := conf.Validate(QemuHypervisor)
+	assert.Error(err)
+	errs, ok := err.(ConfigErrors)
+	assert.True(ok)
+
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal([]string{"EmulatorThreadsCPUs", "EmulatorThreadsNice", "EmulatorRTPriority"}, fields)
+}
+
+func TestProcessThreads(t *testing.T) {
+	assert := assert.New(t)
+
+	tids, err := processThreads(os.Getpid())
+	assert.NoError(err)
+	assert.Contains(tids, os.Getpid())
+
+	_, err = processThreads(-1)
+	assert.Error(err)
+}
+
+func TestTuneEmulatorThreads(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{
+		hypervisor: &mockHypervisor{mockPid: os.Getpid()},
+		config:     &SandboxConfig{},
+	}
+
+	// nothing to tune
+	assert.NoError(s.tuneEmulatorThreads())
+
+	// the threads of the test are pinned to the CPUs they can already use
+	var allowed unix.CPUSet
+	assert.NoError(unix.SchedGetaffinity(os.Getpid(), &allowed))
+
+	var cpus []string
+	for cpu := 0; cpu < len(allowed)*64; cpu++ {
+		if allowed.IsSet(cpu) {
+			cpus = append(cpus, strconv.Itoa(cpu))
+		}
+	}
+
+	s.config.HypervisorConfig.EmulatorThreadsCPUs = strings.Join(cpus, ",")
+	assert.NoError(s.tuneEmulatorThreads())
+
+	s.config.HypervisorConfig.EmulatorThreadsCPUs = "a"
+	assert.Error(s.tuneEmulatorThreads())
+}
@@ -129,6 +129,7 @@ func saveTapIf(tapif *TapInterface) *persistapi.TapInterface {
 			HardAddr: tapif.TAPIface.HardAddr,
 			Addrs:    tapif.TAPIface.Addrs,
 		},
+		DisabledOffloads: tapif.DisabledOffloads,
 	}
 }
 
@@ -145,6 +146,7 @@ func loadTapIf(tapif *persistapi.TapInterface) *TapInterface {
 			HardAddr: tapif.TAPIface.HardAddr,
 			Addrs:    tapif.TAPIface.Addrs,
 		},
+		DisabledOffloads: tapif.DisabledOffloads,
 	}
 }
 
@@ -51,6 +51,15 @@ func NewFactory(ctx context.Context, config Config, fetchOnly bool) (vc.Factory,
 	span, _ := trace(ctx, "NewFactory")
 	defer span.Finish()
 
+	if config.VMConfig.HypervisorConfig.SharedFS == "" {
+		conf := config.VMConfig.HypervisorConfig
+		conf.BootToBeTemplate = config.Template
+		if err := vc.NegotiateSharedFS(ctx, config.VMConfig.HypervisorType, &conf); err != nil {
+			return nil, err
+		}
+		config.VMConfig.HypervisorConfig.SharedFS = conf.SharedFS
+	}
+
 	err := config.VMConfig.Valid()
 	if err != nil {
 		return nil, err
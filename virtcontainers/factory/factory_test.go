@@ -91,6 +91,7 @@ func TestFactorySetLogger(t *testing.T) {
 	SetLogger(context.Background(), testLog)
 
 	var config Config
+	config.VMConfig.HypervisorType = vc.MockHypervisor
 	config.VMConfig.HypervisorConfig = vc.HypervisorConfig{
 		KernelPath: "foo",
 		ImagePath:  "bar",
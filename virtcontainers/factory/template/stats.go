@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package template
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// for mocking in unit tests
+var procRoot = "/proc"
+
+// Stats describes the VMs created from a template and the memory they
+// share through its memory file.
+type Stats struct {
+	// Clones is the number of running VMs created from the template.
+	Clones int
+
+	// SharedMemory is the memory of the template, in bytes, mapped by
+	// the clones and present once on the host.
+	SharedMemory uint64
+
+	// SavedMemory is the memory, in bytes, the clones would have used on
+	// top of SharedMemory if they didn't share the template pages.
+	SavedMemory uint64
+}
+
+// smapsSharedMemory returns whether the process whose smaps file is at path
+// maps memoryPath, and the size in bytes of the mapped pages shared with
+// other processes.
+func smapsSharedMemory(path, memoryPath string) (bool, uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, 0, err
+	}
+	defer f.Close()
+
+	var mapped bool
+	var shared uint64
+	inMapping := false
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		// the "Key: value kB" lines follow the header of their mapping
+		if !strings.HasSuffix(fields[0], ":") {
+			inMapping = len(fields) >= 6 && fields[len(fields)-1] == memoryPath
+			mapped = mapped || inMapping
+			continue
+		}
+
+		if !inMapping || len(fields) < 2 {
+			continue
+		}
+
+		if fields[0] == "Shared_Clean:" || fields[0] == "Shared_Dirty:" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return false, 0, err
+			}
+			shared += kb << 10
+		}
+	}
+
+	return mapped, shared, scanner.Err()
+}
+
+// GetStats returns the stats of the VMs created from the template at
+// templatePath, found by looking for the host processes mapping its memory
+// file.
+func GetStats(templatePath string) (Stats, error) {
+	var stats Stats
+
+	memoryPath := filepath.Join(templatePath, "memory")
+
+	entries, err := filepath.Glob(filepath.Join(procRoot, "[0-9]*", "smaps"))
+	if err != nil {
+		return stats, err
+	}
+
+	var total uint64
+	for _, path := range entries {
+		// processes exit or are not readable, skip them
+		mapped, shared, err := smapsSharedMemory(path, memoryPath)
+		if err != nil || !mapped {
+			continue
+		}
+
+		stats.Clones++
+		total += shared
+
+		if shared > stats.SharedMemory {
+			stats.SharedMemory = shared
+		}
+	}
+
+	stats.SavedMemory = total - stats.SharedMemory
+
+	return stats, nil
+}
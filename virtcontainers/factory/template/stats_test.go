@@ -0,0 +1,76 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package template
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeSmaps(t *testing.T, root, pid, smaps string) {
+	dir := filepath.Join(root, pid)
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "smaps"), []byte(smaps), 0644))
+}
+
+func cloneSmaps(memoryPath string, sharedClean, privateDirty int) string {
+	return fmt.Sprintf(`55d0c0a00000-55d0c0e00000 r-xp 00000000 08:01 1234      /usr/bin/qemu-system-x86_64
+Size:               4096 kB
+Shared_Clean:       2048 kB
+Shared_Dirty:          0 kB
+VmFlags: rd ex mr mw me dw
+7f2a00000000-7f2a80000000 rw-p 00000000 00:2a 5678      %s
+Size:            2097152 kB
+Rss:              %d kB
+Shared_Clean:     %d kB
+Shared_Dirty:          0 kB
+Private_Clean:         0 kB
+Private_Dirty:    %d kB
+VmFlags: rd wr mr mw me ac
+7ffc00000000-7ffc00021000 rw-p 00000000 00:00 0         [stack]
+Size:                132 kB
+Shared_Clean:         12 kB
+`, memoryPath, sharedClean+privateDirty, sharedClean, privateDirty)
+}
+
+func TestGetStats(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	savedProcRoot := procRoot
+	procRoot = tmpdir
+	defer func() {
+		procRoot = savedProcRoot
+	}()
+
+	templatePath := "/run/vc/vm/template"
+	memoryPath := filepath.Join(templatePath, "memory")
+
+	stats, err := GetStats(templatePath)
+	assert.NoError(err)
+	assert.Equal(Stats{}, stats)
+
+	writeSmaps(t, tmpdir, "100", cloneSmaps(memoryPath, 1024, 512))
+	writeSmaps(t, tmpdir, "200", cloneSmaps(memoryPath, 768, 2048))
+	writeSmaps(t, tmpdir, "300", cloneSmaps("/run/vc/vm/other/memory", 4096, 0))
+	writeSmaps(t, tmpdir, "self", cloneSmaps(memoryPath, 4096, 0))
+
+	stats, err = GetStats(templatePath)
+	assert.NoError(err)
+	assert.Equal(Stats{
+		Clones:       2,
+		SharedMemory: 1024 << 10,
+		SavedMemory:  768 << 10,
+	}, stats)
+}
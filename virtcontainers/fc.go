@@ -17,6 +17,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"runtime"
 	"strconv"
 	"strings"
 	"sync"
@@ -26,11 +28,13 @@ import (
 	"github.com/containerd/fifo"
 	httptransport "github.com/go-openapi/runtime/client"
 	"github.com/go-openapi/strfmt"
+	"github.com/go-openapi/swag"
 	kataclient "github.com/kata-containers/agent/protocols/client"
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/firecracker/client"
 	models "github.com/kata-containers/runtime/virtcontainers/pkg/firecracker/client/models"
 	ops "github.com/kata-containers/runtime/virtcontainers/pkg/firecracker/client/operations"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	opentracing "github.com/opentracing/opentracing-go"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
@@ -40,6 +44,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
+	"golang.org/x/sys/unix"
 )
 
 type vmmState uint8
@@ -51,14 +56,11 @@ const (
 )
 
 const (
-	//fcTimeout is the maximum amount of time in seconds to wait for the VMM to respond
-	fcTimeout = 10
-	fcSocket  = "firecracker.socket"
+	fcSocket = "firecracker.socket"
 	//Name of the files within jailer root
 	//Having predefined names helps with cleanup
-	fcKernel             = "vmlinux"
-	fcRootfs             = "rootfs"
-	fcStopSandboxTimeout = 15
+	fcKernel = "vmlinux"
+	fcRootfs = "rootfs"
 	// This indicates the number of block devices that can be attached to the
 	// firecracker guest VM.
 	// We attach a pool of placeholder drives before the guest has started, and then
@@ -66,6 +68,10 @@ const (
 	fcDiskPoolSize           = 8
 	defaultHybridVSocketName = "kata.hvsock"
 
+	// The drives of the pool follow the VM rootfs, which is /dev/vda in
+	// the guest.
+	fcDiskPoolFirstDrive = 1
+
 	// This is the first usable vsock context ID. All the vsocks can use the same
 	// ID, since it's only used in the guest.
 	defaultGuestVSockCID = int64(0x3)
@@ -73,6 +79,8 @@ const (
 	// This is related to firecracker logging scheme
 	fcLogFifo     = "logs.fifo"
 	fcMetricsFifo = "metrics.fifo"
+	fcLogFile     = "logs.log"
+	fcMetricsFile = "metrics.log"
 
 	defaultFcConfig = "fcConfig.json"
 	// storagePathSuffix mirrors persist/fs/fs.go:storagePathSuffix
@@ -82,8 +90,25 @@ const (
 // Specify the minimum version of firecracker supported
 var fcMinSupportedVersion = semver.MustParse("0.21.1")
 
-var fcKernelParams = append(commonVirtioblkKernelRootParams, []Param{
-	// The boot source is the first partition of the first block device added
+// fcHugePagesMinVersion is the first firecracker version able to back the
+// guest memory with huge pages
+var fcHugePagesMinVersion = semver.MustParse("1.7.0")
+
+// fcPauseMinVersion is the first firecracker version able to pause and
+// resume the VM
+var fcPauseMinVersion = semver.MustParse("0.23.0")
+
+// fcLogLevels are the levels of the firecracker logger
+var fcLogLevels = []string{"Error", "Warning", "Info", "Debug"}
+
+// fcLogLevelRegexp matches the level in the prefix of the firecracker log lines
+var fcLogLevelRegexp = regexp.MustCompile(`^[^\[]*\[[^:\]]*:([A-Z]+)[:\]]`)
+
+// fcCPUTemplates are the CPU templates of the guest vCPUs
+var fcCPUTemplates = []models.CPUTemplate{models.CPUTemplateT2, models.CPUTemplateC3}
+
+// The boot source is the first partition of the first block device added
+var fcKernelParams = []Param{
 	{"pci", "off"},
 	{"reboot", "k"},
 	{"panic", "1"},
@@ -94,7 +119,18 @@ var fcKernelParams = append(commonVirtioblkKernelRootParams, []Param{
 	// Firecracker doesn't support ACPI
 	// Fix kernel error "ACPI BIOS Error (bug)"
 	{"acpi", "off"},
-}...)
+}
+
+// fcDebugKernelParams are used when a debug console is available
+var fcDebugKernelParams = []Param{
+	{"console", "ttyS0"},
+}
+
+var fcNonDebugKernelParams = []Param{
+	{"8250.nr_uarts", "0"},
+	// Tell agent where to send the logs
+	{"agent.log_vport", fmt.Sprintf("%d", vSockLogsPort)},
+}
 
 func (s vmmState) String() string {
 	switch s {
@@ -114,6 +150,11 @@ func (s vmmState) String() string {
 type FirecrackerInfo struct {
 	PID     int
 	Version string
+
+	// Exited and ExitStatus are restored from the state saved by the
+	// runtime which reaped the firecracker process.
+	Exited     bool
+	ExitStatus int
 }
 
 type firecrackerState struct {
@@ -121,16 +162,28 @@ type firecrackerState struct {
 	state vmmState
 }
 
-func (s *firecrackerState) set(state vmmState) {
+// transition moves the VMM to state: it's configured once started, then
+// the VM is started. It can go back to notReady from any state, when the
+// VMM is stopped.
+func (s *firecrackerState) transition(state vmmState) error {
 	s.Lock()
 	defer s.Unlock()
 
-	s.state = state
+	switch {
+	case state == notReady,
+		s.state == notReady && state == cfReady,
+		s.state == cfReady && state == vmReady:
+		s.state = state
+		return nil
+	}
+
+	return fmt.Errorf("Invalid firecracker state transition from %q to %q", s.state, state)
 }
 
 // firecracker is an Hypervisor interface implementation for the firecracker VMM.
 type firecracker struct {
 	id            string //Unique ID per pod. Normally maps to the sandbox id
+	sandboxID     string //Sandbox ID, id may be a shortened one
 	vmPath        string //All jailed VM assets need to be under this
 	chrootBaseDir string //chroot base for the jailer
 	jailerRoot    string
@@ -141,7 +194,7 @@ type firecracker struct {
 
 	info FirecrackerInfo
 
-	firecrackerd *exec.Cmd           //Tracks the firecracker process itself
+	firecrackerd *fcProcess          //Tracks the firecracker process itself
 	connection   *client.Firecracker //Tracks the current active connection
 
 	ctx            context.Context
@@ -154,6 +207,10 @@ type firecracker struct {
 
 	fcConfigPath string
 	fcConfig     *types.FcConfig // Parameters configured before VM starts
+
+	// diskPool has the IDs of the drives hot added to each drive of
+	// the pool, empty for the free ones.
+	diskPool []string
 }
 
 type firecrackerDevice struct {
@@ -172,7 +229,7 @@ func (fc *firecracker) trace(name string) (opentracing.Span, context.Context) {
 		fc.ctx = context.Background()
 	}
 
-	span, ctx := opentracing.StartSpanFromContext(fc.ctx, name)
+	span, ctx := startSpanFromContext(fc.ctx, "hypervisor", name)
 
 	span.SetTag("subsystem", "hypervisor")
 	span.SetTag("type", "firecracker")
@@ -180,34 +237,23 @@ func (fc *firecracker) trace(name string) (opentracing.Span, context.Context) {
 	return span, ctx
 }
 
-//At some cases, when sandbox id is too long, it will incur error of overlong
-//firecracker API unix socket(fc.socketPath).
-//In Linux, sun_path could maximumly contains 108 bytes in size.
-//(http://man7.org/linux/man-pages/man7/unix.7.html)
-func (fc *firecracker) truncateID(id string) string {
-	if len(id) > 32 {
-		//truncate the id to only leave the size of UUID(128bit).
-		return id[:32]
+// fcJailerIDMaxLen is the maximum length of the jailer IDs.
+const fcJailerIDMaxLen = 64
+
+// fcJailerID returns the sandbox ID shortened for the jailer, which requires
+// IDs of at most 64 characters and names the VM directory of chrootBaseDir
+// after it, whose API socket path must fit in UNIX_PATH_MAX.
+func fcJailerID(id, chrootBaseDir string) string {
+	max := pathIDBudget(chrootBaseDir, len(filepath.Join("root", "run", fcSocket)))
+	if max > fcJailerIDMaxLen {
+		max = fcJailerIDMaxLen
 	}
 
-	return id
+	return shortenPathID(id, max)
 }
 
-// For firecracker this call only sets the internal structure up.
-// The sandbox will be created and started through startSandbox().
-func (fc *firecracker) createSandbox(ctx context.Context, id string, networkNS NetworkNamespace, hypervisorConfig *HypervisorConfig, stateful bool) error {
-	fc.ctx = ctx
-
-	span, _ := fc.trace("createSandbox")
-	defer span.Finish()
-
-	//TODO: check validity of the hypervisor config provided
-	//https://github.com/kata-containers/runtime/issues/1065
-	fc.id = fc.truncateID(id)
-	fc.state.set(notReady)
-	fc.config = *hypervisorConfig
-	fc.stateful = stateful
-
+// setPaths sets the paths of the VM of the sandbox id up.
+func (fc *firecracker) setPaths(id string) {
 	// When running with jailer all resources need to be under
 	// a specific location and that location needs to have
 	// exec permission (i.e. should not be mounted noexec, e.g. /run, /var/run)
@@ -217,16 +263,72 @@ func (fc *firecracker) createSandbox(ctx context.Context, id string, networkNS N
 	// <chroot_base>/<exec_file_name>/<id>/
 	// Also jailer based on the id implicitly sets up cgroups under
 	// <cgroups_base>/<exec_file_name>/<id>/
-	hypervisorName := filepath.Base(hypervisorConfig.HypervisorPath)
-	//fs.RunStoragePath cannot be used as we need exec perms
-	fc.chrootBaseDir = filepath.Join("/run", storagePathSuffix)
+	hypervisorName := filepath.Base(fc.config.HypervisorPath)
+	//fs.RunStoragePath cannot be used as we need exec perms, all the
+	//jailed assets live in the sandbox temporary tree instead, unless
+	//the host has a better place for them.
+	fc.chrootBaseDir = sandboxTmpPath(id)
+	if fc.config.JailerChrootBase != "" {
+		fc.chrootBaseDir = fc.config.JailerChrootBase
+	}
 
+	fc.sandboxID = id
+	fc.id = fcJailerID(id, filepath.Join(fc.chrootBaseDir, hypervisorName))
 	fc.vmPath = filepath.Join(fc.chrootBaseDir, hypervisorName, fc.id)
 	fc.jailerRoot = filepath.Join(fc.vmPath, "root") // auto created by jailer
 
 	// Firecracker and jailer automatically creates default API socket under /run
 	// with the name of "firecracker.socket"
 	fc.socketPath = filepath.Join(fc.jailerRoot, "run", fcSocket)
+}
+
+// FirecrackerLogFiles returns the host paths of the configuration and of the
+// log files of the firecracker VM of the sandbox. The log files only exist
+// when the VMM logs are kept in a directory.
+func FirecrackerLogFiles(sandboxID string, config HypervisorConfig) []string {
+	fc := &firecracker{config: config}
+	fc.setPaths(sandboxID)
+
+	files := []string{filepath.Join(fc.vmPath, defaultFcConfig)}
+	if config.VMMLogDir != "" {
+		files = append(files, fc.fcLogFile(fcLogFile), fc.fcLogFile(fcMetricsFile))
+	}
+
+	return files
+}
+
+// For firecracker this call only sets the internal structure up.
+// The sandbox will be created and started through startSandbox().
+func (fc *firecracker) createSandbox(ctx context.Context, id string, networkNS NetworkNamespace, hypervisorConfig *HypervisorConfig, stateful bool) error {
+	fc.ctx = ctx
+
+	span, _ := fc.trace("createSandbox")
+	defer span.Finish()
+
+	if err := hypervisorConfig.Validate(FirecrackerHypervisor); err != nil {
+		return err
+	}
+
+	fc.state.transition(notReady)
+	fc.config = *hypervisorConfig
+	fc.stateful = stateful
+
+	if _, err := fc.fcLogLevel(); err != nil {
+		return err
+	}
+
+	if _, err := fc.fcHTEnabled(); err != nil {
+		return err
+	}
+
+	// firecracker has no persistent memory device, the image is
+	// attached as a virtio-block drive instead
+	if fc.config.UsePmemRootfs {
+		fc.Logger().Warn("Persistent memory rootfs is not supported, falling back to virtio-block")
+		fc.config.UsePmemRootfs = false
+	}
+
+	fc.setPaths(id)
 
 	// So we need to repopulate this at startSandbox where it is valid
 	fc.netNSPath = networkNS.NetNsPath
@@ -309,6 +411,19 @@ func (fc *firecracker) getVersionNumber() (string, error) {
 	return "", errors.New("getting FC version failed, the output is malformed")
 }
 
+func (fc *firecracker) getCheckedVersionNumber() (string, error) {
+	version, err := fc.getVersionNumber()
+	if err != nil {
+		return "", err
+	}
+
+	if err := fc.checkVersion(version); err != nil {
+		return "", err
+	}
+
+	return version, nil
+}
+
 func (fc *firecracker) checkVersion(version string) error {
 	v, err := semver.Make(version)
 	if err != nil {
@@ -322,23 +437,104 @@ func (fc *firecracker) checkVersion(version string) error {
 	return nil
 }
 
-// waitVMMRunning will wait for timeout seconds for the VMM to be up and running.
-func (fc *firecracker) waitVMMRunning(timeout int) error {
+// CheckFirecrackerVersion returns the version of the firecracker binary at
+// hypervisorPath, failing if it's not supported.
+func CheckFirecrackerVersion(hypervisorPath string) (string, error) {
+	fc := &firecracker{
+		config: HypervisorConfig{HypervisorPath: hypervisorPath},
+	}
+
+	return fc.getCheckedVersionNumber()
+}
+
+// FirecrackerMinSupportedVersion returns the oldest supported firecracker
+// version.
+func FirecrackerMinSupportedVersion() string {
+	return fcMinSupportedVersion.String()
+}
+
+// FirecrackerChrootBaseDir returns the directory under which the jailer
+// sets up the root of the firecracker VMs, it needs to allow exec.
+func FirecrackerChrootBaseDir() string {
+	return sandboxTmpRoot
+}
+
+// CheckJailerChrootBase returns an error if dir, or its closest existing
+// parent since it's created with the first sandbox, can't host the jailed
+// VMs: it needs to allow exec and to have room for the copy of the
+// firecracker binary at hypervisorPath made in each jail.
+func CheckJailerChrootBase(dir, hypervisorPath string) error {
+	if !filepath.IsAbs(dir) {
+		return fmt.Errorf("%s is not an absolute path", dir)
+	}
+
+	existing := dir
+	for {
+		fi, err := os.Stat(existing)
+		if err == nil {
+			if !fi.IsDir() {
+				return fmt.Errorf("%s is not a directory", existing)
+			}
+			break
+		}
+		if !os.IsNotExist(err) {
+			return err
+		}
+		existing = filepath.Dir(existing)
+	}
+
+	var st unix.Statfs_t
+	if err := unix.Statfs(existing, &st); err != nil {
+		return err
+	}
+
+	if st.Flags&unix.ST_NOEXEC != 0 {
+		return fmt.Errorf("%s is mounted noexec", existing)
+	}
+
+	fi, err := os.Stat(hypervisorPath)
+	if err != nil {
+		return err
+	}
+
+	if free := int64(st.Bavail) * int64(st.Bsize); free < fi.Size() {
+		return fmt.Errorf("%s has %d bytes available, %d are needed for each sandbox", existing, free, fi.Size())
+	}
+
+	return nil
+}
+
+// waitVMMRunning will wait for apiTimeout seconds for the VMM API to answer,
+// then for bootTimeout seconds for the VM to be up and running.
+func (fc *firecracker) waitVMMRunning(apiTimeout, bootTimeout int) error {
 	span, _ := fc.trace("wait VMM to be running")
 	defer span.Finish()
 
-	if timeout < 0 {
-		return fmt.Errorf("Invalid timeout %ds", timeout)
+	if apiTimeout < 0 || bootTimeout < 0 {
+		return fmt.Errorf("Invalid timeouts %ds and %ds", apiTimeout, bootTimeout)
 	}
 
 	timeStart := time.Now()
+	for {
+		if _, err := fc.client().Operations.DescribeInstance(nil); err == nil {
+			break
+		}
+
+		if int(time.Since(timeStart).Seconds()) > apiTimeout {
+			return vcTypes.Errorf(vcTypes.ErrCodeHypervisorNotRunning, "Failed to connect to firecracker API (timeout %ds)", apiTimeout)
+		}
+
+		time.Sleep(time.Duration(10) * time.Millisecond)
+	}
+
+	timeStart = time.Now()
 	for {
 		if fc.vmRunning() {
 			return nil
 		}
 
-		if int(time.Since(timeStart).Seconds()) > timeout {
-			return fmt.Errorf("Failed to connect to firecrackerinstance (timeout %ds)", timeout)
+		if int(time.Since(timeStart).Seconds()) > bootTimeout {
+			return vcTypes.Errorf(vcTypes.ErrCodeHypervisorNotRunning, "Failed to connect to firecrackerinstance (timeout %ds)", bootTimeout)
 		}
 
 		time.Sleep(time.Duration(10) * time.Millisecond)
@@ -351,22 +547,29 @@ func (fc *firecracker) fcInit(timeout int) error {
 
 	var err error
 	//FC version set and check
-	if fc.info.Version, err = fc.getVersionNumber(); err != nil {
+	if fc.config.BootProfile == BootProfileFast {
+		fc.info.Version, err = cachedHypervisorVersion(fc.config.HypervisorPath, fc.getCheckedVersionNumber)
+	} else {
+		fc.info.Version, err = fc.getCheckedVersionNumber()
+	}
+	if err != nil {
 		return err
 	}
 
-	if err := fc.checkVersion(fc.info.Version); err != nil {
+	if fc.fcConfigPath, err = fc.fcJailResource(fc.fcConfigPath, defaultFcConfig); err != nil {
 		return err
 	}
 
+	return fc.fcStartVMM(timeout)
+}
+
+// fcStartVMM starts firecracker with the jailed configuration file and waits
+// for timeout seconds for its VM to be running.
+func (fc *firecracker) fcStartVMM(timeout int) error {
 	var cmd *exec.Cmd
 	var args []string
 
-	if fc.fcConfigPath, err = fc.fcJailResource(fc.fcConfigPath, defaultFcConfig); err != nil {
-		return err
-	}
-
-	if !fc.config.Debug && fc.stateful {
+	if !fc.debugConsole() && fc.stateful {
 		args = append(args, "--daemonize")
 	}
 
@@ -398,7 +601,7 @@ func (fc *firecracker) fcInit(timeout int) error {
 		cmd = exec.Command(fc.config.HypervisorPath, args...)
 	}
 
-	if fc.config.Debug && fc.stateful {
+	if fc.debugConsole() {
 		stdin, err := fc.watchConsole()
 		if err != nil {
 			return err
@@ -418,10 +621,10 @@ func (fc *firecracker) fcInit(timeout int) error {
 	}
 
 	fc.info.PID = cmd.Process.Pid
-	fc.firecrackerd = cmd
+	fc.firecrackerd = newFcProcess(cmd, fc.Logger())
 	fc.connection = fc.newFireClient()
 
-	if err := fc.waitVMMRunning(timeout); err != nil {
+	if err := fc.waitVMMRunning(fc.config.vmmAPITimeout(), timeout); err != nil {
 		fc.Logger().WithField("fcInit failed:", err).Debug()
 		return err
 	}
@@ -442,6 +645,11 @@ func (fc *firecracker) fcEnd() (err error) {
 		}
 	}()
 
+	// the pid of a reaped process may have been reused since
+	if _, exited := fc.vmmExited(); exited {
+		return nil
+	}
+
 	pid := fc.info.PID
 
 	// Send a SIGTERM to the VM process to try to stop it properly
@@ -453,24 +661,37 @@ func (fc *firecracker) fcEnd() (err error) {
 	}
 
 	// Wait for the VM process to terminate
+	if fc.fcWaitExit(fc.config.shutdownTimeout()) {
+		return nil
+	}
+	fc.Logger().Warnf("VM still running after waiting %ds", fc.config.shutdownTimeout())
+
+	// Let's try with a hammer now, a SIGKILL should get rid of the
+	// VM process.
+	return syscall.Kill(pid, syscall.SIGKILL)
+}
+
+// fcWaitExit waits for timeout seconds for the VM process to terminate, it
+// returns false if the process is still running.
+func (fc *firecracker) fcWaitExit(timeout int) bool {
+	if fc.firecrackerd != nil {
+		return fc.firecrackerd.wait(time.Duration(timeout) * time.Second)
+	}
+
+	// the process was started by another runtime instance
 	tInit := time.Now()
 	for {
-		if err = syscall.Kill(pid, syscall.Signal(0)); err != nil {
-			return nil
+		if err := syscall.Kill(fc.info.PID, syscall.Signal(0)); err != nil {
+			return true
 		}
 
-		if time.Since(tInit).Seconds() >= fcStopSandboxTimeout {
-			fc.Logger().Warnf("VM still running after waiting %ds", fcStopSandboxTimeout)
-			break
+		if int(time.Since(tInit).Seconds()) >= timeout {
+			return false
 		}
 
 		// Let's avoid to run a too busy loop
 		time.Sleep(time.Duration(50) * time.Millisecond)
 	}
-
-	// Let's try with a hammer now, a SIGKILL should get rid of the
-	// VM process.
-	return syscall.Kill(pid, syscall.SIGKILL)
 }
 
 func (fc *firecracker) client() *client.Firecracker {
@@ -520,6 +741,28 @@ func (fc *firecracker) fcRemountJailerRootWithExec() error {
 	return nil
 }
 
+// jailerRootNeedsRemount returns false when the fast boot profile is used
+// and the jailer root is already on a file system allowing exec.
+func (fc *firecracker) jailerRootNeedsRemount() bool {
+	if fc.config.BootProfile != BootProfileFast {
+		return true
+	}
+
+	// the jailer root itself is the remount target, check its parent
+	var st unix.Statfs_t
+	if err := unix.Statfs(fc.chrootBaseDir, &st); err != nil {
+		return true
+	}
+
+	return st.Flags&unix.ST_NOEXEC != 0
+}
+
+// debugConsole returns true when the guest console is forwarded to the
+// runtime logs.
+func (fc *firecracker) debugConsole() bool {
+	return fc.config.Debug && fc.stateful && fc.config.BootProfile != BootProfileFast
+}
+
 func (fc *firecracker) fcJailResource(src, dst string) (string, error) {
 	if src == "" || dst == "" {
 		return "", fmt.Errorf("fcJailResource: invalid jail locations: src:%v, dst:%v",
@@ -588,70 +831,228 @@ func (fc *firecracker) fcSetVMRootfs(path string) error {
 	return nil
 }
 
-func (fc *firecracker) fcSetVMBaseConfig(mem int64, vcpus int64, htEnabled bool) {
+func (fc *firecracker) fcSetVMBaseConfig(mem int64, vcpus int64, htEnabled bool, cpuTemplate models.CPUTemplate) {
 	span, _ := fc.trace("fcSetVMBaseConfig")
 	defer span.Finish()
 	fc.Logger().WithFields(logrus.Fields{"mem": mem,
-		"vcpus":     vcpus,
-		"htEnabled": htEnabled}).Debug("fcSetVMBaseConfig")
+		"vcpus":       vcpus,
+		"htEnabled":   htEnabled,
+		"cpuTemplate": cpuTemplate}).Debug("fcSetVMBaseConfig")
 
 	cfg := &models.MachineConfiguration{
-		HtEnabled:  &htEnabled,
-		MemSizeMib: &mem,
-		VcpuCount:  &vcpus,
+		CPUTemplate: cpuTemplate,
+		HtEnabled:   &htEnabled,
+		MemSizeMib:  &mem,
+		VcpuCount:   &vcpus,
 	}
 
 	fc.fcConfig.MachineConfig = cfg
 }
 
-func (fc *firecracker) fcSetLogger() error {
-	span, _ := fc.trace("fcSetLogger")
-	defer span.Finish()
+// fcSetHugePages backs the guest memory with 2M huge pages, the only size
+// firecracker supports.
+func (fc *firecracker) fcSetHugePages() error {
+	version, err := fc.getVersionNumber()
+	if err != nil {
+		return err
+	}
 
-	fcLogLevel := "Error"
-	if fc.config.Debug {
-		fcLogLevel = "Debug"
+	v, err := semver.Make(version)
+	if err != nil {
+		return fmt.Errorf("Malformed firecracker version: %v", err)
 	}
 
-	// listen to log fifo file and transfer error info
-	jailedLogFifo, err := fc.fcListenToFifo(fcLogFifo)
+	if v.LT(fcHugePagesMinVersion) {
+		return fmt.Errorf("firecracker %v doesn't support huge pages, version %v or newer is required", v, fcHugePagesMinVersion)
+	}
+
+	if err := checkFreeHugePages(fc.config.MemorySize, hugePages2MSizeKb); err != nil {
+		return err
+	}
+
+	fc.fcConfig.MachineConfig.HugePages = models.MachineConfigurationHugePagesNr2M
+
+	return nil
+}
+
+// fcLogLevel returns the level of the firecracker logs, the configured one
+// or Error, Debug when the hypervisor debug is enabled.
+func (fc *firecracker) fcLogLevel() (string, error) {
+	if fc.config.VMMLogLevel == "" {
+		if fc.config.Debug {
+			return "Debug", nil
+		}
+		return "Error", nil
+	}
+
+	for _, level := range fcLogLevels {
+		if strings.EqualFold(level, fc.config.VMMLogLevel) {
+			return level, nil
+		}
+	}
+
+	return "", fmt.Errorf("Invalid firecracker log level %q, expected one of %v", fc.config.VMMLogLevel, fcLogLevels)
+}
+
+// fcLogEntryLevel returns the logrus level of a line firecracker logged,
+// prefixed with its level, e.g. "[anonymous-instance:WARN:src/lib.rs:42]".
+// The metrics and the lines without a known level are logged as Info.
+func fcLogEntryLevel(line string) logrus.Level {
+	m := fcLogLevelRegexp.FindStringSubmatch(line)
+	if m == nil {
+		return logrus.InfoLevel
+	}
+
+	switch m[1] {
+	case "ERROR":
+		return logrus.ErrorLevel
+	case "WARN":
+		return logrus.WarnLevel
+	case "DEBUG", "TRACE":
+		return logrus.DebugLevel
+	default:
+		return logrus.InfoLevel
+	}
+}
+
+// fcHTEnabled returns if the guest vCPUs are hyperthreads, firecracker
+// doesn't provide any other CPU feature setting than "ht", which wins over
+// the guest SMT setting.
+func (fc *firecracker) fcHTEnabled() (bool, error) {
+	features, err := parseCPUFeatures(fc.config.CPUFeatures)
 	if err != nil {
-		return fmt.Errorf("Failed setting log: %s", err)
+		return false, err
 	}
 
-	// listen to metrics file and transfer error info
-	jailedMetricsFifo, err := fc.fcListenToFifo(fcMetricsFifo)
+	htEnabled := fc.config.EnableGuestSMT
+	if htEnabled {
+		if _, err := guestThreadsPerCore(&fc.config); err != nil {
+			return false, err
+		}
+	}
+
+	for _, f := range features {
+		if f.name != "ht" {
+			return false, fmt.Errorf("CPU feature %s is not supported by firecracker, only ht can be set", f.name)
+		}
+		htEnabled = f.enabled
+	}
+
+	// firecracker makes cores of 2 hyperthreads
+	if htEnabled && fc.config.NumVCPUs > 1 && fc.config.NumVCPUs%2 != 0 {
+		return false, fmt.Errorf("%d vCPUs can't be hyperthreads, firecracker requires 1 or an even number of vCPUs", fc.config.NumVCPUs)
+	}
+
+	return htEnabled, nil
+}
+
+// fcCPUTemplate returns the configured CPU template of the guest vCPUs, the
+// empty one exposing the host CPU features.
+func (fc *firecracker) fcCPUTemplate() (models.CPUTemplate, error) {
+	if fc.config.CPUTemplate == "" {
+		return "", nil
+	}
+
+	for _, template := range fcCPUTemplates {
+		if strings.EqualFold(string(template), fc.config.CPUTemplate) {
+			return template, nil
+		}
+	}
+
+	return "", fmt.Errorf("Invalid firecracker CPU template %q, expected one of %v", fc.config.CPUTemplate, fcCPUTemplates)
+}
+
+func (fc *firecracker) fcSetLogger() error {
+	span, _ := fc.trace("fcSetLogger")
+	defer span.Finish()
+
+	fcLogLevel, err := fc.fcLogLevel()
 	if err != nil {
-		return fmt.Errorf("Failed setting log: %s", err)
+		return err
+	}
+
+	var jailedLog, jailedMetrics string
+	if fc.config.VMMLogDir != "" {
+		if jailedLog, err = fc.fcLogToFile(fcLogFile); err != nil {
+			return fmt.Errorf("Failed setting log: %s", err)
+		}
+
+		if jailedMetrics, err = fc.fcLogToFile(fcMetricsFile); err != nil {
+			return fmt.Errorf("Failed setting log: %s", err)
+		}
+	} else {
+		// listen to log fifo file and transfer its lines at their level
+		if jailedLog, err = fc.fcListenToFifo(fcLogFifo, true); err != nil {
+			return fmt.Errorf("Failed setting log: %s", err)
+		}
+
+		// listen to metrics file, its content is only transferred
+		// when asked for
+		if jailedMetrics, err = fc.fcListenToFifo(fcMetricsFifo, fc.config.VMMForwardMetrics); err != nil {
+			return fmt.Errorf("Failed setting log: %s", err)
+		}
 	}
 
 	fc.fcConfig.Logger = &models.Logger{
 		Level:       &fcLogLevel,
-		LogFifo:     &jailedLogFifo,
-		MetricsFifo: &jailedMetricsFifo,
+		LogFifo:     &jailedLog,
+		MetricsFifo: &jailedMetrics,
+		ShowLevel:   swag.Bool(true),
 	}
 
 	return err
 }
 
-func (fc *firecracker) fcListenToFifo(fifoName string) (string, error) {
-	fcFifoPath := filepath.Join(fc.vmPath, fifoName)
-	fcFifo, err := fifo.OpenFifo(context.Background(), fcFifoPath, syscall.O_CREAT|syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
-	if err != nil {
-		return "", fmt.Errorf("Failed to open/create fifo file %s", err)
+// fcLogFile returns the host path of a firecracker log file of the sandbox,
+// kept in the log directory once the sandbox is gone.
+func (fc *firecracker) fcLogFile(fileName string) string {
+	return filepath.Join(fc.config.VMMLogDir, fmt.Sprintf("%s-%s", fc.id, fileName))
+}
+
+// fcLogToFile creates the firecracker log file and makes it available in the
+// jail, returning its path in the jail.
+func (fc *firecracker) fcLogToFile(fileName string) (string, error) {
+	if err := os.MkdirAll(fc.config.VMMLogDir, DirMode); err != nil {
+		return "", err
 	}
 
-	jailedFifoPath, err := fc.fcJailResource(fcFifoPath, fifoName)
+	logFile := fc.fcLogFile(fileName)
+	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
 	if err != nil {
+		return "", fmt.Errorf("Failed to create log file %s", err)
+	}
+	f.Close()
+
+	return fc.fcJailResource(logFile, fileName)
+}
+
+func (fc *firecracker) fcListenToFifo(fifoName string, forward bool) (string, error) {
+	fcFifoPath := filepath.Join(fc.vmPath, fifoName)
+	if err := fc.fcReadFifo(fcFifoPath, fifoName, forward); err != nil {
 		return "", err
 	}
 
+	return fc.fcJailResource(fcFifoPath, fifoName)
+}
+
+// fcReadFifo reads the fifo firecracker writes to until it closes it,
+// logging its content if forward is set.
+func (fc *firecracker) fcReadFifo(fcFifoPath, fifoName string, forward bool) error {
+	fcFifo, err := fifo.OpenFifo(context.Background(), fcFifoPath, syscall.O_CREAT|syscall.O_RDONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return fmt.Errorf("Failed to open/create fifo file %s", err)
+	}
+
 	go func() {
 		scanner := bufio.NewScanner(fcFifo)
 		for scanner.Scan() {
+			if !forward {
+				continue
+			}
+
 			fc.Logger().WithFields(logrus.Fields{
 				"fifoName": fifoName,
-				"contents": scanner.Text()}).Error("firecracker failed")
+				"contents": scanner.Text()}).Log(fcLogEntryLevel(scanner.Text()), "firecracker output")
 		}
 
 		if err := scanner.Err(); err != nil {
@@ -663,7 +1064,21 @@ func (fc *firecracker) fcListenToFifo(fifoName string) (string, error) {
 		}
 	}()
 
-	return jailedFifoPath, nil
+	return nil
+}
+
+// kernelParameters builds the kernel parameters of this instance. The
+// parameters from the configuration come first, so the firecracker
+// defaults take priority over them.
+func (fc *firecracker) kernelParameters() []Param {
+	modeParams := fcNonDebugKernelParams
+	if fc.debugConsole() {
+		modeParams = fcDebugKernelParams
+	}
+
+	params := append([]Param{}, fc.config.KernelParams...)
+
+	return append(params, mergeKernelParams(commonVirtioblkKernelRootParams, fcKernelParams, modeParams)...)
 }
 
 func (fc *firecracker) fcInitConfiguration() error {
@@ -673,6 +1088,9 @@ func (fc *firecracker) fcInitConfiguration() error {
 	if err != nil {
 		return err
 	}
+	if err = recordPathID(fc.vmPath, fc.sandboxID); err != nil {
+		return err
+	}
 	defer func() {
 		if err != nil {
 			if err := os.RemoveAll(fc.vmPath); err != nil {
@@ -683,31 +1101,38 @@ func (fc *firecracker) fcInitConfiguration() error {
 
 	if fc.config.JailerPath != "" {
 		fc.jailed = true
-		if err := fc.fcRemountJailerRootWithExec(); err != nil {
-			return err
+		if fc.jailerRootNeedsRemount() {
+			if err := fc.fcRemountJailerRootWithExec(); err != nil {
+				return err
+			}
 		}
 	}
 
-	fc.fcSetVMBaseConfig(int64(fc.config.MemorySize),
-		int64(fc.config.NumVCPUs), false)
+	htEnabled, err := fc.fcHTEnabled()
+	if err != nil {
+		return err
+	}
 
-	kernelPath, err := fc.config.KernelAssetPath()
+	cpuTemplate, err := fc.fcCPUTemplate()
 	if err != nil {
 		return err
 	}
 
-	if fc.config.Debug && fc.stateful {
-		fcKernelParams = append(fcKernelParams, Param{"console", "ttyS0"})
-	} else {
-		fcKernelParams = append(fcKernelParams, []Param{
-			{"8250.nr_uarts", "0"},
-			// Tell agent where to send the logs
-			{"agent.log_vport", fmt.Sprintf("%d", vSockLogsPort)},
-		}...)
+	fc.fcSetVMBaseConfig(int64(fc.config.MemorySize),
+		int64(fc.config.NumVCPUs), htEnabled, cpuTemplate)
+
+	if fc.config.HugePages {
+		if err = fc.fcSetHugePages(); err != nil {
+			return err
+		}
+	}
+
+	kernelPath, err := fc.config.KernelAssetPath()
+	if err != nil {
+		return err
 	}
 
-	kernelParams := append(fc.config.KernelParams, fcKernelParams...)
-	strParams := SerializeParams(kernelParams, "=")
+	strParams := SerializeParams(fc.kernelParameters(), "=")
 	formattedParams := strings.Join(strParams, " ")
 	if err := fc.fcSetBootSource(kernelPath, formattedParams); err != nil {
 		return err
@@ -733,11 +1158,15 @@ func (fc *firecracker) fcInitConfiguration() error {
 		return err
 	}
 
-	if err := fc.fcSetLogger(); err != nil {
-		return err
+	if fc.config.BootProfile != BootProfileFast {
+		if err := fc.fcSetLogger(); err != nil {
+			return err
+		}
 	}
 
-	fc.state.set(cfReady)
+	if err := fc.state.transition(cfReady); err != nil {
+		return err
+	}
 	for _, d := range fc.pendingDevices {
 		if err := fc.addDevice(d.dev, d.devType); err != nil {
 			return err
@@ -774,7 +1203,7 @@ func (fc *firecracker) startSandbox(timeout int) error {
 		}
 	}()
 
-	err = fc.fcInit(fcTimeout)
+	err = fc.fcInit(timeout)
 	if err != nil {
 		return err
 	}
@@ -785,14 +1214,48 @@ func (fc *firecracker) startSandbox(timeout int) error {
 		return fmt.Errorf("Could not change socket permissions: %v", err)
 	}
 
-	fc.state.set(vmReady)
-	return nil
+	return fc.state.transition(vmReady)
 }
 
 func fcDriveIndexToID(i int) string {
 	return "drive_" + strconv.Itoa(i)
 }
 
+// allocDiskPoolDrive returns the index of a free drive of the pool and
+// marks it used by the drive hot added.
+func (fc *firecracker) allocDiskPoolDrive(driveID string) (int, error) {
+	if fc.diskPool == nil {
+		fc.diskPool = make([]string, fcDiskPoolSize)
+	}
+
+	for i, id := range fc.diskPool {
+		if id == "" {
+			fc.diskPool[i] = driveID
+			return i, nil
+		}
+	}
+
+	return -1, vcTypes.Errorf(vcTypes.ErrCodeDeviceBusy, "Could not hot add drive %s: the %d drives of the firecracker disk pool are in use", driveID, fcDiskPoolSize)
+}
+
+// releaseDiskPoolDrive returns the index of the drive of the pool used by
+// the drive hot removed and frees it. The sandboxes created before the
+// pool was tracked used the block index of the drive.
+func (fc *firecracker) releaseDiskPoolDrive(drive config.BlockDrive) (int, error) {
+	for i, id := range fc.diskPool {
+		if id == drive.ID {
+			fc.diskPool[i] = ""
+			return i, nil
+		}
+	}
+
+	if fc.diskPool == nil && drive.Index < fcDiskPoolSize {
+		return drive.Index, nil
+	}
+
+	return -1, fmt.Errorf("Could not hot remove drive %s: it's not in the firecracker disk pool", drive.ID)
+}
+
 func (fc *firecracker) createDiskPool() error {
 	span, _ := fc.trace("createDiskPool")
 	defer span.Finish()
@@ -837,11 +1300,18 @@ func (fc *firecracker) cleanupJail() {
 
 	fc.umountResource(fcKernel)
 	fc.umountResource(fcRootfs)
-	fc.umountResource(fcLogFifo)
-	fc.umountResource(fcMetricsFifo)
+	if fc.config.BootProfile != BootProfileFast {
+		if fc.config.VMMLogDir != "" {
+			fc.umountResource(fcLogFile)
+			fc.umountResource(fcMetricsFile)
+		} else {
+			fc.umountResource(fcLogFifo)
+			fc.umountResource(fcMetricsFifo)
+		}
+	}
 	fc.umountResource(defaultFcConfig)
 	// if running with jailer, we also need to umount fc.jailerRoot
-	if fc.config.JailerPath != "" {
+	if fc.config.JailerPath != "" && fc.jailerRootNeedsRemount() {
 		if err := syscall.Unmount(fc.jailerRoot, syscall.MNT_DETACH); err != nil {
 			fc.Logger().WithField("JailerRoot", fc.jailerRoot).WithError(err).Error("Failed to umount")
 		}
@@ -861,7 +1331,26 @@ func (fc *firecracker) stopSandbox() (err error) {
 	return fc.fcEnd()
 }
 
+// fcSetVMState pauses or resumes the VM, the memory of a paused VM stays
+// allocated.
+func (fc *firecracker) fcSetVMState(state string) error {
+	param := ops.NewPatchVMParams()
+	param.SetBody(&models.VM{
+		State: &state,
+	})
+
+	_, err := fc.client().Operations.PatchVM(param)
+	return err
+}
+
 func (fc *firecracker) pauseSandbox() error {
+	span, _ := fc.trace("pauseSandbox")
+	defer span.Finish()
+
+	if err := fc.fcSetVMState(models.VMStatePaused); err != nil {
+		return errors.Wrapf(err, "failed to pause the firecracker VM")
+	}
+
 	return nil
 }
 
@@ -870,9 +1359,104 @@ func (fc *firecracker) saveSandbox() error {
 }
 
 func (fc *firecracker) resumeSandbox() error {
+	span, _ := fc.trace("resumeSandbox")
+	defer span.Finish()
+
+	if err := fc.fcSetVMState(models.VMStateResumed); err != nil {
+		return errors.Wrapf(err, "failed to resume the firecracker VM")
+	}
+
 	return nil
 }
 
+// rebootSandbox sends Ctrl+Alt+Del to the guest. Firecracker exits when its
+// guest reboots, it is started again with the configuration file of its
+// first start, the hot added drives being still mounted on the drives of
+// the pool.
+func (fc *firecracker) rebootSandbox() error {
+	span, _ := fc.trace("rebootSandbox")
+	defer span.Finish()
+
+	// Ctrl+Alt+Del is emulated by the i8042 controller of x86 guests only
+	if runtime.GOARCH != "amd64" {
+		return fmt.Errorf("firecracker does not support rebooting the VM on %s", runtime.GOARCH)
+	}
+
+	if fc.info.PID <= 0 {
+		return errors.New("firecracker VM not started, impossible to reboot it")
+	}
+	if status, exited := fc.vmmExited(); exited {
+		return fmt.Errorf("firecracker VM exited with status %d, impossible to reboot it", status)
+	}
+	if err := syscall.Kill(fc.info.PID, syscall.Signal(0)); err != nil {
+		return errors.Wrapf(err, "firecracker VM not running, impossible to reboot it")
+	}
+
+	actionType := models.InstanceActionInfoActionTypeSendCtrlAltDel
+	actionParams := ops.NewCreateSyncActionParams()
+	actionParams.SetInfo(&models.InstanceActionInfo{
+		ActionType: &actionType,
+	})
+	if _, err := fc.client().Operations.CreateSyncAction(actionParams); err != nil {
+		return errors.Wrapf(err, "failed to send Ctrl+Alt+Del to the firecracker VM")
+	}
+
+	fc.Logger().Info("Rebooting firecracker VM")
+	if !fc.fcWaitExit(fc.config.shutdownTimeout()) {
+		return fmt.Errorf("firecracker VM still running after waiting %ds for its guest to reboot", fc.config.shutdownTimeout())
+	}
+
+	if err := fc.state.transition(notReady); err != nil {
+		return err
+	}
+
+	// the sandbox may have been restored by another runtime instance,
+	// which only knows the host path of the configuration file
+	fc.jailed = fc.config.JailerPath != ""
+	fc.fcConfigPath = filepath.Join(fc.jailerRoot, defaultFcConfig)
+	if fc.jailed {
+		fc.fcConfigPath = filepath.Join("/", defaultFcConfig)
+
+		// the jailer creates the device nodes of the jail again
+		if err := os.RemoveAll(filepath.Join(fc.jailerRoot, "dev")); err != nil {
+			return err
+		}
+	}
+
+	// the sockets of the previous firecracker process are left behind
+	for _, socket := range []string{fc.socketPath, filepath.Join(fc.jailerRoot, defaultHybridVSocketName)} {
+		if err := os.Remove(socket); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// firecracker fails to open its fifos when nothing reads them
+	if fc.config.BootProfile != BootProfileFast && fc.config.VMMLogDir == "" {
+		if err := fc.fcReadFifo(filepath.Join(fc.vmPath, fcLogFifo), fcLogFifo, true); err != nil {
+			return err
+		}
+		if err := fc.fcReadFifo(filepath.Join(fc.vmPath, fcMetricsFifo), fcMetricsFifo, fc.config.VMMForwardMetrics); err != nil {
+			return err
+		}
+	}
+
+	if err := fc.state.transition(cfReady); err != nil {
+		return err
+	}
+
+	if err := fc.fcStartVMM(fc.config.bootTimeout()); err != nil {
+		fc.fcEnd()
+		return err
+	}
+
+	// make sure 'others' don't have access to this socket
+	if err := os.Chmod(filepath.Join(fc.jailerRoot, defaultHybridVSocketName), 0640); err != nil {
+		return fmt.Errorf("Could not change socket permissions: %v", err)
+	}
+
+	return fc.state.transition(vmReady)
+}
+
 func (fc *firecracker) fcAddVsock(hvs types.HybridVSock) {
 	span, _ := fc.trace("fcAddVsock")
 	defer span.Finish()
@@ -893,19 +1477,53 @@ func (fc *firecracker) fcAddVsock(hvs types.HybridVSock) {
 	fc.fcConfig.Vsock = vsock
 }
 
-func (fc *firecracker) fcAddNetDevice(endpoint Endpoint) {
+// fcEndpointTap returns the name of the TAP device backing the endpoint,
+// firecracker having no support for the other kinds of network devices.
+func fcEndpointTap(endpoint Endpoint) (string, error) {
+	netPair := endpoint.NetworkPair()
+	if netPair == nil {
+		return "", fmt.Errorf("Firecracker does not support %s network endpoint %s", endpoint.Type(), endpoint.Name())
+	}
+
+	return netPair.TapInterface.TAPIface.Name, nil
+}
+
+// fcAddNetDevice adds the endpoint to the interfaces configured before the
+// VM boots. Firecracker can't hotplug network devices, so every interface
+// found in the network namespace at creation, the secondary ones included,
+// goes through here and the guest sees them in the order they are added.
+func (fc *firecracker) fcAddNetDevice(endpoint Endpoint) error {
 	span, _ := fc.trace("fcAddNetDevice")
 	defer span.Finish()
 
+	tapName, err := fcEndpointTap(endpoint)
+	if err != nil {
+		return err
+	}
+
 	ifaceID := endpoint.Name()
+	for _, iface := range fc.fcConfig.NetworkInterfaces {
+		if *iface.IfaceID == ifaceID {
+			return fmt.Errorf("Network interface %s already added", ifaceID)
+		}
+	}
+
 	ifaceCfg := &models.NetworkInterface{
 		AllowMmdsRequests: false,
 		GuestMac:          endpoint.HardwareAddr(),
 		IfaceID:           &ifaceID,
-		HostDevName:       &endpoint.NetworkPair().TapInterface.TAPIface.Name,
+		HostDevName:       &tapName,
 	}
 
 	fc.fcConfig.NetworkInterfaces = append(fc.fcConfig.NetworkInterfaces, ifaceCfg)
+
+	fc.Logger().WithFields(logrus.Fields{
+		"iface-id":  ifaceID,
+		"guest-mac": ifaceCfg.GuestMac,
+		"tap":       tapName,
+	}).Debug("Network interface added")
+
+	return nil
 }
 
 func (fc *firecracker) fcAddBlockDrive(drive config.BlockDrive) error {
@@ -913,9 +1531,11 @@ func (fc *firecracker) fcAddBlockDrive(drive config.BlockDrive) error {
 	defer span.Finish()
 
 	driveID := drive.ID
-	isReadOnly := false
+	isReadOnly := drive.ReadOnly
 	isRootDevice := false
 
+	fc.warnDriveCacheMode(drive)
+
 	jailedDrive, err := fc.fcJailResource(drive.File, driveID)
 	if err != nil {
 		fc.Logger().WithField("fcAddBlockDrive failed", err).Error()
@@ -966,6 +1586,13 @@ func (fc *firecracker) addDevice(devInfo interface{}, devType deviceType) error
 	defer fc.state.RUnlock()
 
 	if fc.state.state == notReady {
+		// fail at attach time rather than when the VM starts
+		if endpoint, ok := devInfo.(Endpoint); ok {
+			if _, err := fcEndpointTap(endpoint); err != nil {
+				return err
+			}
+		}
+
 		dev := firecrackerDevice{
 			dev:     devInfo,
 			devType: devType,
@@ -979,7 +1606,7 @@ func (fc *firecracker) addDevice(devInfo interface{}, devType deviceType) error
 	switch v := devInfo.(type) {
 	case Endpoint:
 		fc.Logger().WithField("device-type-endpoint", devInfo).Info("Adding device")
-		fc.fcAddNetDevice(v)
+		err = fc.fcAddNetDevice(v)
 	case config.BlockDrive:
 		fc.Logger().WithField("device-type-blockdrive", devInfo).Info("Adding device")
 		err = fc.fcAddBlockDrive(v)
@@ -993,27 +1620,75 @@ func (fc *firecracker) addDevice(devInfo interface{}, devType deviceType) error
 	return err
 }
 
+// warnDriveCacheMode warns the cache mode of the drive is ignored, firecracker
+// not supporting any.
+func (fc *firecracker) warnDriveCacheMode(drive config.BlockDrive) {
+	if drive.CacheMode != "" {
+		fc.Logger().WithFields(logrus.Fields{
+			"drive":      drive.ID,
+			"cache-mode": drive.CacheMode,
+		}).Warn("firecracker does not support drive cache modes, ignoring it")
+	}
+}
+
 // hotplugBlockDevice supported in Firecracker VMM
 // hot add or remove a block device.
-func (fc *firecracker) hotplugBlockDevice(drive config.BlockDrive, op operation) (interface{}, error) {
+func (fc *firecracker) hotplugBlockDevice(drive *config.BlockDrive, op operation) (interface{}, error) {
 	var path string
-	var err error
-	driveID := fcDriveIndexToID(drive.Index)
 
 	if op == addDevice {
+		// the drives of the pool are read-write, only their host
+		// path can be updated once the VM runs: the guest still
+		// mounts the read-only drives read-only
+		if drive.ReadOnly {
+			fc.Logger().WithField("drive", drive.File).Warn("firecracker only hot adds read-write drives, adding the read-only drive read-write")
+		}
+		fc.warnDriveCacheMode(*drive)
+
+		index, err := fc.allocDiskPoolDrive(drive.ID)
+		if err != nil {
+			return nil, err
+		}
+		driveID := fcDriveIndexToID(index)
+
+		driveName, err := utils.GetVirtDriveName(fcDiskPoolFirstDrive + index)
+		if err != nil {
+			fc.diskPool[index] = ""
+			return nil, err
+		}
+
 		//The drive placeholder has to exist prior to Update
 		path, err = fc.fcJailResource(drive.File, driveID)
 		if err != nil {
+			fc.diskPool[index] = ""
 			fc.Logger().WithError(err).WithField("resource", drive.File).Error("Could not jail resource")
 			return nil, err
 		}
-	} else {
-		// umount the disk, it's no longer needed.
-		fc.umountResource(driveID)
-		// use previous raw file created at createDiskPool, that way
-		// the resource is released by firecracker and it can be destroyed in the host
-		path = filepath.Join(fc.jailerRoot, driveID)
+
+		if err = fc.fcUpdateBlockDrive(path, driveID); err != nil {
+			fc.umountResource(driveID)
+			fc.diskPool[index] = ""
+			return nil, err
+		}
+
+		// the guest names the drives in the order they were configured,
+		// and gets their ID as their serial number
+		drive.VirtPath = filepath.Join("/dev", driveName)
+		drive.Serial = driveID
+		return nil, nil
+	}
+
+	index, err := fc.releaseDiskPoolDrive(*drive)
+	if err != nil {
+		return nil, err
 	}
+	driveID := fcDriveIndexToID(index)
+
+	// umount the disk, it's no longer needed.
+	fc.umountResource(driveID)
+	// use previous raw file created at createDiskPool, that way
+	// the resource is released by firecracker and it can be destroyed in the host
+	path = filepath.Join(fc.jailerRoot, driveID)
 
 	return nil, fc.fcUpdateBlockDrive(path, driveID)
 }
@@ -1025,7 +1700,9 @@ func (fc *firecracker) hotplugAddDevice(devInfo interface{}, devType deviceType)
 
 	switch devType {
 	case blockDev:
-		return fc.hotplugBlockDevice(*devInfo.(*config.BlockDrive), addDevice)
+		return fc.hotplugBlockDevice(devInfo.(*config.BlockDrive), addDevice)
+	case netDev:
+		return nil, fmt.Errorf("Could not hot add network device: firecracker only supports the interfaces present in the network namespace when the sandbox is created")
 	default:
 		fc.Logger().WithFields(logrus.Fields{"devInfo": devInfo,
 			"deviceType": devType}).Warn("hotplugAddDevice: unsupported device")
@@ -1041,7 +1718,7 @@ func (fc *firecracker) hotplugRemoveDevice(devInfo interface{}, devType deviceTy
 
 	switch devType {
 	case blockDev:
-		return fc.hotplugBlockDevice(*devInfo.(*config.BlockDrive), removeDevice)
+		return fc.hotplugBlockDevice(devInfo.(*config.BlockDrive), removeDevice)
 	default:
 		fc.Logger().WithFields(logrus.Fields{"devInfo": devInfo,
 			"deviceType": devType}).Error("hotplugRemoveDevice: unsupported device")
@@ -1057,7 +1734,7 @@ func (fc *firecracker) getSandboxConsole(id string) (string, error) {
 }
 
 func (fc *firecracker) disconnect() {
-	fc.state.set(notReady)
+	fc.state.transition(notReady)
 }
 
 // Adds all capabilities supported by firecracker implementation of hypervisor interface
@@ -1067,6 +1744,11 @@ func (fc *firecracker) capabilities() types.Capabilities {
 	var caps types.Capabilities
 	caps.SetBlockDeviceHotplugSupport()
 
+	// the version is only known once the VM is started
+	if v, err := semver.Make(fc.info.Version); err == nil && v.GE(fcPauseMinVersion) {
+		caps.SetVMPauseSupport()
+	}
+
 	return caps
 }
 
@@ -1129,6 +1811,10 @@ func (fc *firecracker) getPids() []int {
 	return []int{fc.info.PID}
 }
 
+func (fc *firecracker) getVMPath() string {
+	return fc.vmPath
+}
+
 func (fc *firecracker) fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error {
 	return errors.New("firecracker is not supported by VM cache")
 }
@@ -1137,24 +1823,91 @@ func (fc *firecracker) toGrpc() ([]byte, error) {
 	return nil, errors.New("firecracker is not supported by VM cache")
 }
 
+// vmmExited returns the exit status of the firecracker process and if it
+// exited, as far as the runtime knows: only the runtime which started the
+// process can reap it.
+func (fc *firecracker) vmmExited() (int, bool) {
+	if fc.firecrackerd != nil {
+		return fc.firecrackerd.exited()
+	}
+
+	return fc.info.ExitStatus, fc.info.Exited
+}
+
 func (fc *firecracker) save() (s persistapi.HypervisorState) {
 	s.Pid = fc.info.PID
 	s.Type = string(FirecrackerHypervisor)
+	s.DiskPool = fc.diskPool
+	s.ExitStatus, s.Exited = fc.vmmExited()
 	return
 }
 
 func (fc *firecracker) load(s persistapi.HypervisorState) {
 	fc.info.PID = s.Pid
+	fc.info.Exited = s.Exited
+	fc.info.ExitStatus = s.ExitStatus
+	fc.diskPool = s.DiskPool
 }
 
 func (fc *firecracker) check() error {
+	if status, exited := fc.vmmExited(); exited {
+		return fmt.Errorf("fc process exited with status %d", status)
+	}
+
 	if err := syscall.Kill(fc.info.PID, syscall.Signal(0)); err != nil {
 		return errors.Wrapf(err, "failed to ping fc process")
 	}
 
+	// The process may be alive but stuck, once the VM is started
+	// its API must answer as well.
+	if fc.state.state == vmReady {
+		if _, err := fc.client().Operations.DescribeInstance(nil); err != nil {
+			return errors.Wrapf(err, "failed to ping fc API")
+		}
+	}
+
 	return nil
 }
 
+func (fc *firecracker) describe() (VMInfo, error) {
+	instance, err := fc.client().Operations.DescribeInstance(nil)
+	if err != nil {
+		return VMInfo{}, errors.Wrapf(err, "failed to describe fc instance")
+	}
+
+	machine, err := fc.client().Operations.GetMachineConfiguration(nil)
+	if err != nil {
+		return VMInfo{}, errors.Wrapf(err, "failed to get fc machine configuration")
+	}
+
+	info := VMInfo{
+		State:    swag.StringValue(instance.Payload.State),
+		VCPUs:    uint32(swag.Int64Value(machine.Payload.VcpuCount)),
+		MemoryMB: uint32(swag.Int64Value(machine.Payload.MemSizeMib)),
+		Details: map[string]string{
+			"vmm_version": swag.StringValue(instance.Payload.VmmVersion),
+			"api_socket":  fc.socketPath,
+			"vm_path":     fc.vmPath,
+			"jailed":      strconv.FormatBool(fc.jailed),
+		},
+	}
+
+	if fc.jailed {
+		info.Details["jailer_root"] = fc.jailerRoot
+		info.Details["chroot_base"] = fc.chrootBaseDir
+	}
+
+	return info, nil
+}
+
+func (fc *firecracker) migrateSandbox(uri string) error {
+	return errors.New("firecracker does not support migration")
+}
+
+func (fc *firecracker) launchMeasurement() (string, error) {
+	return "", errors.New("firecracker does not support confidential guests")
+}
+
 func (fc *firecracker) generateSocket(id string, useVsock bool) (interface{}, error) {
 	if !useVsock {
 		return nil, fmt.Errorf("Can't start firecracker: vsocks is disabled")
@@ -0,0 +1,69 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"os/exec"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fcProcess reaps the firecracker process started by the runtime as soon as
+// it exits. The shim isn't a subreaper and nothing else waits for its
+// children, the process would stay a zombie until the shim exits.
+type fcProcess struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+
+	// exitStatus is the exit status of the process once done is closed,
+	// -1 if it was killed by a signal.
+	exitStatus int
+}
+
+func newFcProcess(cmd *exec.Cmd, logger *logrus.Entry) *fcProcess {
+	p := &fcProcess{
+		cmd:  cmd,
+		done: make(chan struct{}),
+	}
+
+	go func() {
+		err := cmd.Wait()
+		p.exitStatus = cmd.ProcessState.ExitCode()
+		close(p.done)
+
+		logger.WithError(err).WithFields(logrus.Fields{
+			"pid":         cmd.Process.Pid,
+			"exit-status": p.exitStatus,
+		}).Info("Firecracker process exited")
+	}()
+
+	return p
+}
+
+// wait waits for timeout for the process to exit, it returns false if the
+// process is still running.
+func (p *fcProcess) wait(timeout time.Duration) bool {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+
+	select {
+	case <-p.done:
+		return true
+	case <-t.C:
+		return false
+	}
+}
+
+// exited returns the exit status of the process and if it exited.
+func (p *fcProcess) exited() (int, bool) {
+	select {
+	case <-p.done:
+		return p.exitStatus, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFcProcess(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd := exec.Command("sh", "-c", "exit 3")
+	assert.NoError(cmd.Start())
+
+	p := newFcProcess(cmd, virtLog)
+	assert.True(p.wait(5 * time.Second))
+	status, exited := p.exited()
+	assert.True(exited)
+	assert.Equal(3, status)
+
+	cmd = exec.Command("sleep", "10")
+	assert.NoError(cmd.Start())
+
+	p = newFcProcess(cmd, virtLog)
+	assert.False(p.wait(10 * time.Millisecond))
+	_, exited = p.exited()
+	assert.False(exited)
+
+	assert.NoError(cmd.Process.Kill())
+	assert.True(p.wait(5 * time.Second))
+	status, exited = p.exited()
+	assert.True(exited)
+	assert.Equal(-1, status)
+}
+
+func TestFCReapedProcess(t *testing.T) {
+	assert := assert.New(t)
+
+	cmd := exec.Command("true")
+	assert.NoError(cmd.Start())
+
+	fc := firecracker{}
+	fc.info.PID = cmd.Process.Pid
+	fc.firecrackerd = newFcProcess(cmd, virtLog)
+	assert.True(fc.fcWaitExit(5))
+
+	// the reaped process isn't signaled
+	assert.NoError(fc.fcEnd())
+	assert.Error(fc.check())
+	assert.Error(fc.rebootSandbox())
+
+	s := fc.save()
+	assert.True(s.Exited)
+	assert.Equal(0, s.ExitStatus)
+
+	// a restored firecracker knows the process exited
+	var restored firecracker
+	restored.load(s)
+	assert.Error(restored.check())
+	assert.NoError(restored.fcEnd())
+}
@@ -6,12 +6,40 @@
 package virtcontainers
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+	models "github.com/kata-containers/runtime/virtcontainers/pkg/firecracker/client/models"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/kata-containers/runtime/virtcontainers/utils"
+	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 )
 
+// newFcConfig returns a firecracker configuration whose assets exist.
+func newFcConfig(t *testing.T) HypervisorConfig {
+	fcPath := filepath.Join(testDir, "firecracker")
+	assert.NoError(t, ioutil.WriteFile(fcPath, nil, os.FileMode(0750)))
+
+	return HypervisorConfig{
+		KernelPath:     testQemuKernelPath,
+		ImagePath:      testQemuImagePath,
+		HypervisorPath: fcPath,
+	}
+}
+
 func TestFCGenerateSocket(t *testing.T) {
 	assert := assert.New(t)
 
@@ -30,18 +58,463 @@ func TestFCGenerateSocket(t *testing.T) {
 	assert.NotZero(hvsock.Port)
 }
 
-func TestFCTruncateID(t *testing.T) {
+func TestFCJailerID(t *testing.T) {
 	assert := assert.New(t)
 
-	fc := firecracker{}
+	chrootBase := "/run/vc/tmp/3ef98eb7c6416be1/firecracker"
 
 	testLongID := "3ef98eb7c6416be11e0accfed2f4e6560e07f8e33fa8d31922fd4d61747d7ead"
-	expectedID := "3ef98eb7c6416be11e0accfed2f4e656"
-	id := fc.truncateID(testLongID)
-	assert.Equal(expectedID, id)
+	id := fcJailerID(testLongID, chrootBase)
+	assert.True(strings.HasPrefix(id, "3ef98eb7c6416be11e0accfed2f4e"))
+	assert.True(len(filepath.Join(chrootBase, id, "root", "run", fcSocket)) <= utils.MaxSocketPathLen)
+	assert.Equal(id, fcJailerID(testLongID, chrootBase))
+	assert.NotEqual(id, fcJailerID(testLongID+"0", chrootBase))
 
 	testShortID := "3ef98eb7c6416be11"
-	expectedID = "3ef98eb7c6416be11"
-	id = fc.truncateID(testShortID)
-	assert.Equal(expectedID, id)
+	assert.Equal(testShortID, fcJailerID(testShortID, chrootBase))
+
+	// the jailer IDs have at most 64 characters
+	id = fcJailerID(testLongID+testLongID, "/srv")
+	assert.Len(id, fcJailerIDMaxLen)
+}
+
+func TestFCKernelParameters(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{
+		config: HypervisorConfig{
+			KernelParams: []Param{
+				{"panic", "0"},
+				{"foo", "bar"},
+			},
+		},
+	}
+
+	// the configured parameters come first, the defaults win
+	params := fc.kernelParameters()
+	assert.Equal(Param{"panic", "0"}, params[0])
+	assert.Equal(Param{"foo", "bar"}, params[1])
+	assert.Contains(params[2:], Param{"panic", "1"})
+	assert.Contains(params, Param{"8250.nr_uarts", "0"})
+	assert.NotContains(params, Param{"console", "ttyS0"})
+
+	// building them again gives the same parameters
+	assert.Equal(params, fc.kernelParameters())
+
+	fc.config.Debug = true
+	fc.stateful = true
+	params = fc.kernelParameters()
+	assert.Contains(params, Param{"console", "ttyS0"})
+	assert.NotContains(params, Param{"8250.nr_uarts", "0"})
+
+	// the fast profile has no debug console
+	fc.config.BootProfile = BootProfileFast
+	params = fc.kernelParameters()
+	assert.NotContains(params, Param{"console", "ttyS0"})
+	assert.Contains(params, Param{"8250.nr_uarts", "0"})
+
+	// other instances are not affected
+	other := firecracker{}
+	assert.NotContains(other.kernelParameters(), Param{"console", "ttyS0"})
+	assert.NotContains(other.kernelParameters(), Param{"foo", "bar"})
+}
+
+func TestFCCreateSandboxPmemRootfs(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	config := newFcConfig(t)
+	config.UsePmemRootfs = true
+
+	assert.NoError(fc.createSandbox(context.Background(), testSandboxID, NetworkNamespace{}, &config, false))
+	assert.False(fc.config.UsePmemRootfs)
+	assert.Contains(fc.kernelParameters(), Param{"root", "/dev/vda1"})
+}
+
+func TestFCCreateSandboxJailerChrootBase(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	config := newFcConfig(t)
+
+	assert.NoError(fc.createSandbox(context.Background(), testSandboxID, NetworkNamespace{}, &config, false))
+	assert.Equal(filepath.Join(sandboxTmpPath(testSandboxID), "firecracker", fc.id), fc.vmPath)
+
+	config.JailerChrootBase = "/var/lib/kata-containers/jail"
+	assert.NoError(fc.createSandbox(context.Background(), testSandboxID, NetworkNamespace{}, &config, false))
+	assert.Equal(filepath.Join("/var/lib/kata-containers/jail", "firecracker", fc.id), fc.vmPath)
+}
+
+func TestCheckJailerChrootBase(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	hypervisorPath := filepath.Join(dir, "firecracker")
+	assert.NoError(ioutil.WriteFile(hypervisorPath, []byte("firecracker"), 0755))
+
+	// created with the first sandbox
+	assert.NoError(CheckJailerChrootBase(filepath.Join(dir, "jail", "vc"), hypervisorPath))
+
+	assert.Error(CheckJailerChrootBase("jail", hypervisorPath))
+	assert.Error(CheckJailerChrootBase(filepath.Join(hypervisorPath, "jail"), hypervisorPath))
+	assert.Error(CheckJailerChrootBase(dir, filepath.Join(dir, "missing")))
+}
+
+func TestFCHotplugReadOnlyBlockDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	drive := config.BlockDrive{
+		File:     "/dev/sdb",
+		ID:       "drive",
+		ReadOnly: true,
+	}
+
+	for i := 0; i < fcDiskPoolSize; i++ {
+		_, err := fc.allocDiskPoolDrive(fmt.Sprintf("drive-%d", i))
+		assert.NoError(err)
+	}
+
+	// the read-only drives fall back to the read-write drives of the
+	// pool, which is full
+	_, err := fc.hotplugBlockDevice(&drive, addDevice)
+	assert.Error(err)
+	assert.Equal(vcTypes.ErrCodeDeviceBusy, vcTypes.ErrorCodeOf(err))
+}
+
+func TestFCDiskPool(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+
+	for i := 0; i < fcDiskPoolSize; i++ {
+		index, err := fc.allocDiskPoolDrive(fmt.Sprintf("drive-%d", i))
+		assert.NoError(err)
+		assert.Equal(i, index)
+	}
+
+	_, err := fc.allocDiskPoolDrive("drive-full")
+	assert.Error(err)
+
+	// the drives freed are reused whatever the block index
+	index, err := fc.releaseDiskPoolDrive(config.BlockDrive{ID: "drive-2", Index: 5})
+	assert.NoError(err)
+	assert.Equal(2, index)
+
+	_, err = fc.releaseDiskPoolDrive(config.BlockDrive{ID: "drive-2", Index: 5})
+	assert.Error(err)
+
+	index, err = fc.allocDiskPoolDrive("drive-new")
+	assert.NoError(err)
+	assert.Equal(2, index)
+
+	// the pool of the sandboxes saved before it was tracked
+	fc.load(persistapi.HypervisorState{})
+	index, err = fc.releaseDiskPoolDrive(config.BlockDrive{ID: "drive-old", Index: 3})
+	assert.NoError(err)
+	assert.Equal(3, index)
+}
+
+func TestFCAddNetDevices(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{
+		ctx:      context.Background(),
+		fcConfig: &types.FcConfig{},
+	}
+
+	// primary and secondary interfaces are all configured before boot
+	for i, name := range []string{"eth0", "net1", "net2"} {
+		endpoint, err := createVethNetworkEndpoint(i, name, NetXConnectTCFilterModel)
+		assert.NoError(err)
+
+		assert.NoError(fc.addDevice(endpoint, netDev))
+	}
+	assert.Len(fc.pendingDevices, 3)
+
+	for _, d := range fc.pendingDevices {
+		assert.NoError(fc.fcAddNetDevice(d.dev.(Endpoint)))
+	}
+
+	assert.Len(fc.fcConfig.NetworkInterfaces, 3)
+	for i, name := range []string{"eth0", "net1", "net2"} {
+		iface := fc.fcConfig.NetworkInterfaces[i]
+		assert.Equal(name, *iface.IfaceID)
+		assert.Equal(fmt.Sprintf("tap%d_kata", i), *iface.HostDevName)
+	}
+
+	// the same interface can't be added twice
+	assert.Error(fc.fcAddNetDevice(fc.pendingDevices[0].dev.(Endpoint)))
+
+	// non TAP based endpoints are not supported
+	assert.Error(fc.addDevice(&PhysicalEndpoint{IfaceName: "eth3"}, netDev))
+	assert.Len(fc.pendingDevices, 3)
+
+	_, err := fc.hotplugAddDevice(fc.pendingDevices[0].dev, netDev)
+	assert.Error(err)
+}
+
+func benchmarkFCInitConfiguration(b *testing.B, profile BootProfile) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		b.Skip(testDisabledAsNonRoot)
+	}
+
+	dir, err := ioutil.TempDir("", "fc-boot-profile")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	kernel := filepath.Join(dir, "vmlinux")
+	image := filepath.Join(dir, "image")
+	fcPath := filepath.Join(dir, "firecracker")
+	for _, f := range []string{kernel, image, fcPath} {
+		if err := ioutil.WriteFile(f, nil, 0640); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	config := HypervisorConfig{
+		HypervisorPath: fcPath,
+		KernelPath:     kernel,
+		ImagePath:      image,
+		MemorySize:     MinHypervisorMemory,
+		NumVCPUs:       1,
+		Debug:          true,
+		BootProfile:    profile,
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fc := firecracker{}
+		if err := fc.createSandbox(context.Background(), testSandboxID, NetworkNamespace{}, &config, true); err != nil {
+			b.Fatal(err)
+		}
+
+		if err := fc.fcInitConfiguration(); err != nil {
+			b.Fatal(err)
+		}
+
+		fc.cleanupJail()
+	}
+}
+
+func BenchmarkFCInitConfigurationDefaultBootProfile(b *testing.B) {
+	benchmarkFCInitConfiguration(b, BootProfileDefault)
+}
+
+func BenchmarkFCInitConfigurationFastBootProfile(b *testing.B) {
+	benchmarkFCInitConfiguration(b, BootProfileFast)
+}
+
+func TestFCLogLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	level, err := fc.fcLogLevel()
+	assert.NoError(err)
+	assert.Equal("Error", level)
+
+	fc.config.Debug = true
+	level, err = fc.fcLogLevel()
+	assert.NoError(err)
+	assert.Equal("Debug", level)
+
+	// the configured level wins over the debug one
+	fc.config.VMMLogLevel = "info"
+	level, err = fc.fcLogLevel()
+	assert.NoError(err)
+	assert.Equal("Info", level)
+
+	fc.config.VMMLogLevel = "Trace"
+	_, err = fc.fcLogLevel()
+	assert.Error(err)
+
+	config := newFcConfig(t)
+	config.VMMLogLevel = "Trace"
+	assert.Error(fc.createSandbox(context.Background(), testSandboxID, NetworkNamespace{}, &config, false))
+}
+
+func TestFCLogEntryLevel(t *testing.T) {
+	assert := assert.New(t)
+
+	for line, level := range map[string]logrus.Level{
+		"2020-10-14T10:00:00.000000000 [anonymous-instance:ERROR:src/vmm/src/lib.rs:42] boom": logrus.ErrorLevel,
+		"2020-10-14T10:00:00.000000000 [anonymous-instance:WARN:src/vmm/src/lib.rs:42] hmm":   logrus.WarnLevel,
+		"[anonymous-instance:INFO] started":                                                   logrus.InfoLevel,
+		"[anonymous-instance:DEBUG:src/vmm/src/lib.rs:42] detail":                             logrus.DebugLevel,
+		`{"utc_timestamp_ms":1602669600000,"api_server":{}}`:                                  logrus.InfoLevel,
+	} {
+		assert.Equal(level, fcLogEntryLevel(line), line)
+	}
+}
+
+func TestFCStateTransition(t *testing.T) {
+	assert := assert.New(t)
+
+	var state firecrackerState
+	assert.Error(state.transition(vmReady))
+	assert.NoError(state.transition(cfReady))
+	assert.Error(state.transition(cfReady))
+	assert.NoError(state.transition(vmReady))
+	assert.Error(state.transition(cfReady))
+	assert.Equal(vmReady, state.state)
+
+	assert.NoError(state.transition(notReady))
+	assert.NoError(state.transition(notReady))
+	assert.NoError(state.transition(cfReady))
+}
+
+func TestFCRebootSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	// the VMM was never started
+	fc := firecracker{}
+	assert.Error(fc.rebootSandbox())
+}
+
+func TestFCPauseResumeSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "fc")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fc := firecracker{
+		socketPath: filepath.Join(dir, "api.socket"),
+	}
+
+	l, err := net.Listen("unix", fc.socketPath)
+	assert.NoError(err)
+
+	var states []string
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var vm models.VM
+			if r.Method != http.MethodPatch || r.URL.Path != "/vm" || json.NewDecoder(r.Body).Decode(&vm) != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			states = append(states, *vm.State)
+			w.WriteHeader(http.StatusNoContent)
+		}),
+	}
+	go server.Serve(l)
+	defer server.Close()
+
+	assert.NoError(fc.pauseSandbox())
+	assert.NoError(fc.resumeSandbox())
+	assert.Equal([]string{models.VMStatePaused, models.VMStateResumed}, states)
+
+	server.Close()
+	fc.connection = nil
+	assert.Error(fc.pauseSandbox())
+}
+
+func TestFCCapabilitiesPause(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	caps := fc.capabilities()
+	assert.False(caps.IsVMPauseSupported())
+
+	fc.info.Version = "0.21.1"
+	caps = fc.capabilities()
+	assert.False(caps.IsVMPauseSupported())
+
+	fc.info.Version = fcPauseMinVersion.String()
+	caps = fc.capabilities()
+	assert.True(caps.IsVMPauseSupported())
+}
+
+func TestFCHTEnabled(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	htEnabled, err := fc.fcHTEnabled()
+	assert.NoError(err)
+	assert.False(htEnabled)
+
+	fc.config.CPUFeatures = "ht=on"
+	htEnabled, err = fc.fcHTEnabled()
+	assert.NoError(err)
+	assert.True(htEnabled)
+
+	fc.config.CPUFeatures = "-ht"
+	htEnabled, err = fc.fcHTEnabled()
+	assert.NoError(err)
+	assert.False(htEnabled)
+
+	fc.config.CPUFeatures = "ht=on,avx512f=off"
+	_, err = fc.fcHTEnabled()
+	assert.Error(err)
+
+	// firecracker cores are pairs of hyperthreads
+	fc.config.CPUFeatures = "ht=on"
+	fc.config.NumVCPUs = 3
+	_, err = fc.fcHTEnabled()
+	assert.Error(err)
+
+	cleanup := mockHostSMT(t, 2)
+	defer cleanup()
+
+	fc.config.CPUFeatures = ""
+	fc.config.NumVCPUs = 2
+	fc.config.EnableGuestSMT = true
+	htEnabled, err = fc.fcHTEnabled()
+	assert.NoError(err)
+	assert.True(htEnabled)
+}
+
+func TestFCCPUTemplate(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{}
+	template, err := fc.fcCPUTemplate()
+	assert.NoError(err)
+	assert.Empty(template)
+
+	fc.config.CPUTemplate = "c3"
+	template, err = fc.fcCPUTemplate()
+	assert.NoError(err)
+	assert.Equal(models.CPUTemplateC3, template)
+
+	fc.config.CPUTemplate = "M5"
+	_, err = fc.fcCPUTemplate()
+	assert.Error(err)
+}
+
+func TestFCLogFile(t *testing.T) {
+	assert := assert.New(t)
+
+	fc := firecracker{
+		id: testSandboxID,
+		config: HypervisorConfig{
+			VMMLogDir: "/var/log/kata-containers/firecracker",
+		},
+	}
+
+	assert.Equal(filepath.Join("/var/log/kata-containers/firecracker", testSandboxID+"-"+fcLogFile), fc.fcLogFile(fcLogFile))
+}
+
+func TestFirecrackerLogFiles(t *testing.T) {
+	assert := assert.New(t)
+
+	config := HypervisorConfig{
+		HypervisorPath:   "/usr/bin/firecracker",
+		JailerChrootBase: "/srv/jailer",
+	}
+
+	vmPath := filepath.Join("/srv/jailer", "firecracker", testSandboxID)
+	assert.Equal([]string{filepath.Join(vmPath, defaultFcConfig)}, FirecrackerLogFiles(testSandboxID, config))
+
+	config.VMMLogDir = "/var/log/kata-containers/firecracker"
+	assert.Equal([]string{
+		filepath.Join(vmPath, defaultFcConfig),
+		filepath.Join("/var/log/kata-containers/firecracker", testSandboxID+"-"+fcLogFile),
+		filepath.Join("/var/log/kata-containers/firecracker", testSandboxID+"-"+fcMetricsFile),
+	}, FirecrackerLogFiles(testSandboxID, config))
 }
@@ -0,0 +1,268 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// GuestImageType is how a guest rootfs boots.
+type GuestImageType string
+
+const (
+	// GuestImageUnknown is a file which isn't recognized as a guest rootfs.
+	GuestImageUnknown GuestImageType = "unknown"
+
+	// GuestImageInitrd is an initramfs, possibly compressed.
+	GuestImageInitrd GuestImageType = "initrd"
+
+	// GuestImageRaw is a disk image whose init couldn't be found.
+	GuestImageRaw GuestImageType = "image"
+
+	// GuestImageAgentInit is a disk image running the agent as init.
+	GuestImageAgentInit GuestImageType = "image-agent-init"
+
+	// GuestImageSystemd is a disk image running systemd as init, which
+	// starts the agent.
+	GuestImageSystemd GuestImageType = "image-systemd"
+)
+
+// IsDisk returns if the guest rootfs is a disk image.
+func (t GuestImageType) IsDisk() bool {
+	return t == GuestImageRaw || t == GuestImageAgentInit || t == GuestImageSystemd
+}
+
+// agentSupportedMajorVersion is the major version of the agents speaking
+// the protocol of this runtime.
+const agentSupportedMajorVersion = 1
+
+// vsockMinKernelVersion is the first guest kernel with the virtio vsock
+// transport.
+var vsockMinKernelVersion = semver.MustParse("4.8.0")
+
+// initrdMagics are the headers of the cpio archives and of the compressed
+// formats the kernel can unpack.
+var initrdMagics = [][]byte{
+	[]byte("070701"),
+	[]byte("070702"),
+	[]byte("070707"),
+	{0x1f, 0x8b},
+	{0xfd, '7', 'z', 'X', 'Z', 0x00},
+	{0x28, 0xb5, 0x2f, 0xfd},
+	{0x02, 0x21, 0x4c, 0x18},
+	[]byte("BZh"),
+	{0x5d, 0x00, 0x00},
+}
+
+const (
+	sectorSize    = 512
+	mbrSignature  = 0xaa55
+	mbrGPTType    = 0xee
+	gptHeaderSize = 92
+)
+
+// DetectGuestImage returns how the guest rootfs at path boots, the disk
+// images are expected to hold an ext filesystem, partitioned or not.
+func DetectGuestImage(path string) (GuestImageType, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return GuestImageUnknown, err
+	}
+	defer f.Close()
+
+	header := make([]byte, 2*sectorSize)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return GuestImageUnknown, err
+	}
+	header = header[:n]
+
+	for _, magic := range initrdMagics {
+		if bytes.HasPrefix(header, magic) {
+			return GuestImageInitrd, nil
+		}
+	}
+
+	fs, err := openExtFS(f, 0)
+	if err != nil {
+		offset, ok := firstPartitionOffset(f, header)
+		if !ok {
+			return GuestImageUnknown, nil
+		}
+
+		if fs, err = openExtFS(f, offset); err != nil {
+			return GuestImageRaw, nil
+		}
+	}
+
+	return guestImageInit(fs), nil
+}
+
+// firstPartitionOffset returns the offset of the first partition of the
+// MBR or GPT partition table of the disk, header being its first two
+// sectors.
+func firstPartitionOffset(r io.ReaderAt, header []byte) (int64, bool) {
+	if len(header) < 2*sectorSize || binary.LittleEndian.Uint16(header[510:]) != mbrSignature {
+		return 0, false
+	}
+
+	entry := header[446:462]
+	if entry[4] == 0 {
+		return 0, false
+	}
+
+	if entry[4] != mbrGPTType {
+		return int64(binary.LittleEndian.Uint32(entry[8:])) * sectorSize, true
+	}
+
+	gpt := header[sectorSize:]
+	if string(gpt[:8]) != "EFI PART" || binary.LittleEndian.Uint32(gpt[12:]) < gptHeaderSize {
+		return 0, false
+	}
+
+	entries := int64(binary.LittleEndian.Uint64(gpt[72:]))
+	first := make([]byte, 8)
+	if _, err := r.ReadAt(first, entries*sectorSize+32); err != nil {
+		return 0, false
+	}
+
+	lba := int64(binary.LittleEndian.Uint64(first))
+	return lba * sectorSize, lba != 0
+}
+
+func guestImageInit(fs *extFS) GuestImageType {
+	resolved, ino, err := fs.resolve("/sbin/init")
+	if err != nil || ino.mode&extModeMask != extModeFile {
+		virtLog.WithError(err).Debug("No init found in the guest image")
+		return GuestImageRaw
+	}
+
+	switch path.Base(resolved) {
+	case "systemd":
+		return GuestImageSystemd
+	case "kata-agent":
+		return GuestImageAgentInit
+	}
+
+	return GuestImageRaw
+}
+
+// guestKernelVersion returns the version of the kernel at path, empty if
+// it isn't found. The version is in the header of the compressed kernels,
+// the other ones are looked through for the banner printed at boot.
+func guestKernelVersion(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, 0x210)
+	if _, err := io.ReadFull(f, header); err != nil {
+		if err == io.ErrUnexpectedEOF || err == io.EOF {
+			return "", nil
+		}
+		return "", err
+	}
+
+	// the x86 boot protocol, kernel_version is relative to the setup
+	if string(header[0x202:0x206]) == "HdrS" {
+		offset := int64(binary.LittleEndian.Uint16(header[0x20e:]))
+		if offset != 0 {
+			version := make([]byte, 64)
+			n, _ := f.ReadAt(version, 0x200+offset)
+			return firstField(version[:n]), nil
+		}
+	}
+
+	banner := []byte("Linux version ")
+	chunk := make([]byte, 1<<20)
+	for off := int64(0); ; {
+		n, err := f.ReadAt(chunk, off)
+		if i := bytes.Index(chunk[:n], banner); i >= 0 {
+			version := make([]byte, 64)
+			m, _ := f.ReadAt(version, off+int64(i+len(banner)))
+			return firstField(version[:m]), nil
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return "", nil
+			}
+			return "", err
+		}
+
+		// the banner can straddle two chunks
+		off += int64(n - len(banner))
+	}
+}
+
+func firstField(b []byte) string {
+	if i := bytes.IndexAny(b, " \x00"); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+// checkAgentVersion fails if the agent doesn't speak the protocol of this
+// runtime, an unknown version isn't checked.
+func checkAgentVersion(version string) error {
+	if version == "" {
+		return nil
+	}
+
+	v, err := semver.Make(version)
+	if err != nil {
+		return fmt.Errorf("Malformed agent version %q: %v", version, err)
+	}
+
+	if v.Major != agentSupportedMajorVersion {
+		return fmt.Errorf("Agent version %s is not supported, the runtime needs a %d.x agent", version, agentSupportedMajorVersion)
+	}
+
+	return nil
+}
+
+// checkGuestKernel fails if the agent can't be reached in the guest kernel
+// at path, before booting a VM which would never answer. The kernels whose
+// version isn't found aren't checked.
+func checkGuestKernel(path string, conf *HypervisorConfig) error {
+	if !conf.UseVSock || path == "" {
+		return nil
+	}
+
+	version, err := guestKernelVersion(path)
+	if err != nil {
+		virtLog.WithError(err).WithField("kernel", path).Warn("Could not read the guest kernel version")
+		return nil
+	}
+
+	// the local version, e.g. "-kata" or "+", isn't semver
+	release := version
+	if i := strings.IndexFunc(version, func(r rune) bool { return r != '.' && (r < '0' || r > '9') }); i >= 0 {
+		release = version[:i]
+	}
+
+	v, err := semver.ParseTolerant(release)
+	if err != nil {
+		virtLog.WithField("kernel", path).WithField("version", version).Debug("Unknown guest kernel version")
+		return nil
+	}
+
+	if v.LT(vsockMinKernelVersion) {
+		return fmt.Errorf("Guest kernel %s has no vsock transport to reach the agent, %s or newer is needed", version, vsockMinKernelVersion)
+	}
+
+	return nil
+}
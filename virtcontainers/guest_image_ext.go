@@ -0,0 +1,381 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	extSuperblockOffset = 1024
+	extMagic            = 0xef53
+	extRootInode        = 2
+
+	extIncompat64Bit = 0x80
+
+	extExtentsFlag    = 0x80000
+	extInlineDataFlag = 0x10000000
+	extExtentMagic    = 0xf30a
+	extMaxExtentLen   = 32768
+	extDirectBlocks   = 12
+
+	extModeMask = 0xf000
+	extModeDir  = 0x4000
+	extModeFile = 0x8000
+	extModeLink = 0xa000
+
+	// limits of the files read, the guest image is not trusted
+	extMaxFileSize = 16 << 20
+	extMaxSymlinks = 16
+)
+
+// extFS reads the files of an ext2, ext3 or ext4 filesystem, enough to
+// find how the guest image boots without mounting it.
+type extFS struct {
+	r      io.ReaderAt
+	offset int64
+
+	blockSize      int64
+	inodeSize      int64
+	inodesPerGroup uint32
+	inodesCount    uint32
+	descSize       int64
+	descTable      int64
+	is64Bit        bool
+}
+
+type extInode struct {
+	mode   uint16
+	size   int64
+	flags  uint32
+	blocks [60]byte
+}
+
+// openExtFS returns the filesystem at offset, an error if there is no ext
+// filesystem there.
+func openExtFS(r io.ReaderAt, offset int64) (*extFS, error) {
+	sb := make([]byte, 1024)
+	if _, err := r.ReadAt(sb, offset+extSuperblockOffset); err != nil {
+		return nil, err
+	}
+
+	if binary.LittleEndian.Uint16(sb[56:]) != extMagic {
+		return nil, fmt.Errorf("No ext filesystem at offset %d", offset)
+	}
+
+	logBlockSize := binary.LittleEndian.Uint32(sb[24:])
+	if logBlockSize > 6 {
+		return nil, fmt.Errorf("Invalid ext block size 1024 << %d", logBlockSize)
+	}
+
+	fs := &extFS{
+		r:              r,
+		offset:         offset,
+		blockSize:      1024 << logBlockSize,
+		inodeSize:      128,
+		inodesCount:    binary.LittleEndian.Uint32(sb[0:]),
+		inodesPerGroup: binary.LittleEndian.Uint32(sb[40:]),
+		descSize:       32,
+	}
+
+	if binary.LittleEndian.Uint32(sb[76:]) >= 1 {
+		fs.inodeSize = int64(binary.LittleEndian.Uint16(sb[88:]))
+	}
+
+	if binary.LittleEndian.Uint32(sb[96:])&extIncompat64Bit != 0 {
+		fs.is64Bit = true
+		fs.descSize = int64(binary.LittleEndian.Uint16(sb[254:]))
+	}
+
+	if fs.inodesPerGroup == 0 || fs.inodeSize < 128 || fs.descSize < 32 {
+		return nil, fmt.Errorf("Invalid ext superblock at offset %d", offset)
+	}
+
+	// the group descriptors follow the block of the superblock
+	fs.descTable = int64(binary.LittleEndian.Uint32(sb[20:])+1) * fs.blockSize
+
+	return fs, nil
+}
+
+func (fs *extFS) readAt(b []byte, off int64) error {
+	_, err := fs.r.ReadAt(b, fs.offset+off)
+	return err
+}
+
+func (fs *extFS) inode(n uint32) (extInode, error) {
+	if n == 0 || n > fs.inodesCount {
+		return extInode{}, fmt.Errorf("Invalid inode %d", n)
+	}
+
+	group := int64((n - 1) / fs.inodesPerGroup)
+	index := int64((n - 1) % fs.inodesPerGroup)
+
+	desc := make([]byte, fs.descSize)
+	if err := fs.readAt(desc, fs.descTable+group*fs.descSize); err != nil {
+		return extInode{}, err
+	}
+
+	table := int64(binary.LittleEndian.Uint32(desc[8:]))
+	if fs.is64Bit && fs.descSize >= 64 {
+		table |= int64(binary.LittleEndian.Uint32(desc[40:])) << 32
+	}
+
+	raw := make([]byte, 128)
+	if err := fs.readAt(raw, table*fs.blockSize+index*fs.inodeSize); err != nil {
+		return extInode{}, err
+	}
+
+	ino := extInode{
+		mode:  binary.LittleEndian.Uint16(raw[0:]),
+		size:  int64(binary.LittleEndian.Uint32(raw[4:])) | int64(binary.LittleEndian.Uint32(raw[108:]))<<32,
+		flags: binary.LittleEndian.Uint32(raw[32:]),
+	}
+	copy(ino.blocks[:], raw[40:100])
+
+	return ino, nil
+}
+
+// readFile returns the content of the inode, the directories and the
+// symlinks are files too.
+func (fs *extFS) readFile(ino extInode) ([]byte, error) {
+	if ino.size > extMaxFileSize {
+		return nil, fmt.Errorf("File of %d bytes too large", ino.size)
+	}
+
+	// fast symlinks are stored in the inode
+	if ino.mode&extModeMask == extModeLink && ino.size < int64(len(ino.blocks)) && ino.flags&extExtentsFlag == 0 {
+		return append([]byte(nil), ino.blocks[:ino.size]...), nil
+	}
+
+	if ino.flags&extInlineDataFlag != 0 {
+		return nil, fmt.Errorf("Inline data not supported")
+	}
+
+	data := make([]byte, 0, ino.size)
+
+	var err error
+	if ino.flags&extExtentsFlag != 0 {
+		data, err = fs.readExtents(ino.blocks[:], data, ino.size, 0)
+	} else {
+		data, err = fs.readBlockMap(ino.blocks[:], data, ino.size)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if int64(len(data)) > ino.size {
+		data = data[:ino.size]
+	}
+
+	return data, nil
+}
+
+// readBlock appends the block to data, the files stop at the end of the
+// block they end in.
+func (fs *extFS) readBlock(block int64, data []byte, size int64) ([]byte, error) {
+	if int64(len(data)) >= size {
+		return data, nil
+	}
+
+	b := make([]byte, fs.blockSize)
+	if block != 0 {
+		if err := fs.readAt(b, block*fs.blockSize); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(data, b...), nil
+}
+
+func (fs *extFS) readExtents(node []byte, data []byte, size int64, depth int) ([]byte, error) {
+	if len(node) < 12 || binary.LittleEndian.Uint16(node[0:]) != extExtentMagic || depth > 5 {
+		return nil, fmt.Errorf("Invalid extent tree")
+	}
+
+	entries := int(binary.LittleEndian.Uint16(node[2:]))
+	leaf := binary.LittleEndian.Uint16(node[6:]) == 0
+
+	for i := 0; i < entries && int64(len(data)) < size; i++ {
+		if 24+12*i > len(node) {
+			return nil, fmt.Errorf("Invalid extent tree")
+		}
+		e := node[12+12*i:]
+
+		if !leaf {
+			child := int64(binary.LittleEndian.Uint32(e[4:])) | int64(binary.LittleEndian.Uint16(e[8:]))<<32
+			b := make([]byte, fs.blockSize)
+			if err := fs.readAt(b, child*fs.blockSize); err != nil {
+				return nil, err
+			}
+
+			var err error
+			if data, err = fs.readExtents(b, data, size, depth+1); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// the holes before the extent read as zeroes
+		logical := int64(binary.LittleEndian.Uint32(e[0:]))
+		for int64(len(data)) < logical*fs.blockSize && int64(len(data)) < size {
+			data = append(data, make([]byte, fs.blockSize)...)
+		}
+
+		length := int64(binary.LittleEndian.Uint16(e[4:]))
+		uninitialized := length > extMaxExtentLen
+		if uninitialized {
+			length -= extMaxExtentLen
+		}
+		start := int64(binary.LittleEndian.Uint16(e[6:]))<<32 | int64(binary.LittleEndian.Uint32(e[8:]))
+
+		for j := int64(0); j < length; j++ {
+			block := start + j
+			if uninitialized {
+				block = 0
+			}
+
+			var err error
+			if data, err = fs.readBlock(block, data, size); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return data, nil
+}
+
+// readBlockMap reads the files of the filesystems without extents, only
+// the direct and single indirect blocks since only small files are read.
+func (fs *extFS) readBlockMap(blocks []byte, data []byte, size int64) ([]byte, error) {
+	var err error
+	for i := 0; i < extDirectBlocks; i++ {
+		block := int64(binary.LittleEndian.Uint32(blocks[4*i:]))
+		if data, err = fs.readBlock(block, data, size); err != nil {
+			return nil, err
+		}
+	}
+
+	if int64(len(data)) >= size {
+		return data, nil
+	}
+
+	indirect := int64(binary.LittleEndian.Uint32(blocks[4*extDirectBlocks:]))
+	if indirect == 0 {
+		return nil, fmt.Errorf("Missing indirect block")
+	}
+
+	b := make([]byte, fs.blockSize)
+	if err := fs.readAt(b, indirect*fs.blockSize); err != nil {
+		return nil, err
+	}
+
+	for i := int64(0); i < fs.blockSize/4 && int64(len(data)) < size; i++ {
+		block := int64(binary.LittleEndian.Uint32(b[4*i:]))
+		if data, err = fs.readBlock(block, data, size); err != nil {
+			return nil, err
+		}
+	}
+
+	if int64(len(data)) < size {
+		return nil, fmt.Errorf("File of %d bytes too large for its direct blocks", size)
+	}
+
+	return data, nil
+}
+
+// lookupEntry returns the inode of name in the directory dir.
+func (fs *extFS) lookupEntry(dir extInode, name string) (uint32, error) {
+	data, err := fs.readFile(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	// the hashed directories keep the entries readable one after the other
+	for off := 0; off+8 <= len(data); {
+		n := binary.LittleEndian.Uint32(data[off:])
+		recLen := int(binary.LittleEndian.Uint16(data[off+4:]))
+		nameLen := int(data[off+6])
+
+		if recLen < 8 || off+recLen > len(data) {
+			break
+		}
+
+		if n != 0 && nameLen <= recLen-8 && string(data[off+8:off+8+nameLen]) == name {
+			return n, nil
+		}
+
+		off += recLen
+	}
+
+	return 0, fmt.Errorf("%s not found", name)
+}
+
+// resolve follows the symlinks of p and returns the path it resolves to
+// and its inode.
+func (fs *extFS) resolve(p string) (string, extInode, error) {
+	for hops := 0; hops <= extMaxSymlinks; hops++ {
+		resolved, ino, target, err := fs.walk(p)
+		if err != nil || target == "" {
+			return resolved, ino, err
+		}
+
+		p = target
+	}
+
+	return "", extInode{}, fmt.Errorf("Too many symlinks in %s", p)
+}
+
+// walk looks p up until its first symlink, it returns the path the
+// symlink points to in place of p if there is one.
+func (fs *extFS) walk(p string) (string, extInode, string, error) {
+	components := strings.Split(strings.Trim(path.Clean("/"+p), "/"), "/")
+
+	resolved := "/"
+	ino, err := fs.inode(extRootInode)
+	if err != nil {
+		return "", extInode{}, "", err
+	}
+
+	for i, name := range components {
+		if name == "" {
+			continue
+		}
+
+		if ino.mode&extModeMask != extModeDir {
+			return "", extInode{}, "", fmt.Errorf("%s is not a directory", resolved)
+		}
+
+		n, err := fs.lookupEntry(ino, name)
+		if err != nil {
+			return "", extInode{}, "", fmt.Errorf("%s: %v", resolved, err)
+		}
+
+		if ino, err = fs.inode(n); err != nil {
+			return "", extInode{}, "", err
+		}
+
+		if ino.mode&extModeMask == extModeLink {
+			target, err := fs.readFile(ino)
+			if err != nil {
+				return "", extInode{}, "", err
+			}
+
+			rest := strings.Join(components[i+1:], "/")
+			if path.IsAbs(string(target)) {
+				return "", extInode{}, path.Join(string(target), rest), nil
+			}
+			return "", extInode{}, path.Join(resolved, string(target), rest), nil
+		}
+
+		resolved = path.Join(resolved, name)
+	}
+
+	return resolved, ino, "", nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// makeGuestRootfs creates an ext image of a guest rootfs whose /sbin/init
+// points to init, through a merged /usr like the distros building the
+// guest images.
+func makeGuestRootfs(t *testing.T, dir, mkfs, init string) string {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir(dir, "rootfs")
+	assert.NoError(err)
+
+	for _, d := range []string{"usr/bin", "usr/sbin", "usr/lib/systemd"} {
+		assert.NoError(os.MkdirAll(filepath.Join(root, d), 0755))
+	}
+	for _, f := range []string{"usr/bin/kata-agent", "usr/lib/systemd/systemd"} {
+		assert.NoError(ioutil.WriteFile(filepath.Join(root, f), []byte("#!/bin/sh\n"), 0755))
+	}
+	assert.NoError(os.Symlink("usr/sbin", filepath.Join(root, "sbin")))
+	assert.NoError(os.Symlink("usr/lib", filepath.Join(root, "lib")))
+	assert.NoError(os.Symlink(init, filepath.Join(root, "usr/sbin/init")))
+
+	image := root + ".img"
+	out, err := exec.Command(mkfs, "-q", "-F", "-d", root, image, "8M").CombinedOutput()
+	assert.NoError(err, string(out))
+
+	return image
+}
+
+func TestDetectGuestImage(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "guest-image")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	initrd := filepath.Join(dir, "initrd.img")
+	assert.NoError(ioutil.WriteFile(initrd, []byte{0x1f, 0x8b, 0x08, 0x00}, 0644))
+
+	imageType, err := DetectGuestImage(initrd)
+	assert.NoError(err)
+	assert.Equal(GuestImageInitrd, imageType)
+	assert.False(imageType.IsDisk())
+
+	unknown := filepath.Join(dir, "unknown")
+	assert.NoError(ioutil.WriteFile(unknown, []byte("not a rootfs"), 0644))
+
+	imageType, err = DetectGuestImage(unknown)
+	assert.NoError(err)
+	assert.Equal(GuestImageUnknown, imageType)
+
+	_, err = DetectGuestImage(filepath.Join(dir, "missing"))
+	assert.Error(err)
+
+	mkfs, err := exec.LookPath("mkfs.ext4")
+	if err != nil {
+		t.Skip("mkfs.ext4 not found")
+	}
+
+	image := makeGuestRootfs(t, dir, mkfs, "../lib/systemd/systemd")
+	imageType, err = DetectGuestImage(image)
+	assert.NoError(err)
+	assert.Equal(GuestImageSystemd, imageType)
+	assert.True(imageType.IsDisk())
+
+	image = makeGuestRootfs(t, dir, mkfs, "/usr/bin/kata-agent")
+	imageType, err = DetectGuestImage(image)
+	assert.NoError(err)
+	assert.Equal(GuestImageAgentInit, imageType)
+
+	// a partitioned disk, the filesystem starting at 1MiB
+	fs, err := ioutil.ReadFile(image)
+	assert.NoError(err)
+
+	disk := make([]byte, 1<<20, 1<<20+len(fs))
+	disk[446+4] = 0x83
+	binary.LittleEndian.PutUint32(disk[446+8:], 2048)
+	binary.LittleEndian.PutUint16(disk[510:], mbrSignature)
+	disk = append(disk, fs...)
+
+	partitioned := filepath.Join(dir, "disk.img")
+	assert.NoError(ioutil.WriteFile(partitioned, disk, 0644))
+
+	imageType, err = DetectGuestImage(partitioned)
+	assert.NoError(err)
+	assert.Equal(GuestImageAgentInit, imageType)
+
+	// a dangling init
+	image = makeGuestRootfs(t, dir, mkfs, "/usr/bin/missing")
+	imageType, err = DetectGuestImage(image)
+	assert.NoError(err)
+	assert.Equal(GuestImageRaw, imageType)
+
+	// the filesystems without extents
+	if mkfs, err = exec.LookPath("mkfs.ext2"); err == nil {
+		image = makeGuestRootfs(t, dir, mkfs, "../lib/systemd/systemd")
+		imageType, err = DetectGuestImage(image)
+		assert.NoError(err)
+		assert.Equal(GuestImageSystemd, imageType)
+	}
+}
+
+func TestGuestKernelVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "guest-kernel")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	bzImage := make([]byte, 0x1000)
+	copy(bzImage[0x202:], "HdrS")
+	binary.LittleEndian.PutUint16(bzImage[0x20e:], 0x400)
+	copy(bzImage[0x600:], "5.4.60-kata (root@builder) #1 SMP\x00")
+
+	kernel := filepath.Join(dir, "vmlinuz")
+	assert.NoError(ioutil.WriteFile(kernel, bzImage, 0644))
+
+	version, err := guestKernelVersion(kernel)
+	assert.NoError(err)
+	assert.Equal("5.4.60-kata", version)
+
+	// the banner of an uncompressed kernel across two chunks
+	vmlinux := make([]byte, 1<<20+0x100)
+	copy(vmlinux[1<<20-6:], "Linux version 4.19.86+ (gcc version 9.3.0)")
+
+	kernel = filepath.Join(dir, "vmlinux")
+	assert.NoError(ioutil.WriteFile(kernel, vmlinux, 0644))
+
+	version, err = guestKernelVersion(kernel)
+	assert.NoError(err)
+	assert.Equal("4.19.86+", version)
+
+	kernel = filepath.Join(dir, "empty")
+	assert.NoError(ioutil.WriteFile(kernel, nil, 0644))
+
+	version, err = guestKernelVersion(kernel)
+	assert.NoError(err)
+	assert.Empty(version)
+}
+
+func TestCheckAgentVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(checkAgentVersion(""))
+	assert.NoError(checkAgentVersion("1.12.0-rc0-b3be1b9e"))
+	assert.Error(checkAgentVersion("2.0.0"))
+	assert.Error(checkAgentVersion("latest"))
+}
+
+func TestCheckGuestKernel(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "guest-kernel")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	kernel := filepath.Join(dir, "vmlinux")
+	writeBanner := func(version string) {
+		assert.NoError(ioutil.WriteFile(kernel, []byte("\x7fELF"+string(make([]byte, 0x300))+"Linux version "+version+" (gcc)"), 0644))
+	}
+
+	conf := &HypervisorConfig{UseVSock: true}
+
+	writeBanner("4.19.86+")
+	assert.NoError(checkGuestKernel(kernel, conf))
+
+	writeBanner("4.4.0-kata")
+	assert.Error(checkGuestKernel(kernel, conf))
+
+	// the kernels which aren't reached through vsock aren't checked
+	assert.NoError(checkGuestKernel(kernel, &HypervisorConfig{}))
+
+	// unknown versions
+	writeBanner("unknown")
+	assert.NoError(checkGuestKernel(kernel, conf))
+	assert.NoError(checkGuestKernel(filepath.Join(dir, "missing"), conf))
+}
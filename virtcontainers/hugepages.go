@@ -0,0 +1,101 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// hugePagesMountPoint is the hugetlbfs mount of the default huge
+	// page size backing the guest memory
+	hugePagesMountPoint = "/dev/hugepages"
+
+	// hugePages2MSizeKb is the only huge page size firecracker supports
+	hugePages2MSizeKb = 2048
+)
+
+// for mocking in unit tests
+var sysHugePagesDir = "/sys/kernel/mm/hugepages"
+
+// getDefaultHugePageSizeKb returns the default huge page size of the host
+func getDefaultHugePageSizeKb(memInfoPath string) (uint64, error) {
+	f, err := os.Open(memInfoPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// Expected format: ["Hugepagesize:", "2048", "kB"]
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 3 || parts[0] != "Hugepagesize:" || parts[2] != "kB" {
+			continue
+		}
+
+		return strconv.ParseUint(parts[1], 10, 64)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("Huge pages are not supported by the host kernel")
+}
+
+// checkHugePagesMount returns an error if path is not a hugetlbfs mount
+func checkHugePagesMount(path string) error {
+	var st unix.Statfs_t
+
+	if err := unix.Statfs(path, &st); err != nil {
+		return fmt.Errorf("Huge pages file system %s is not available: %v", path, err)
+	}
+
+	if st.Type != unix.HUGETLBFS_MAGIC {
+		return fmt.Errorf("%s is not a hugetlbfs mount, huge pages can't be used for the guest memory", path)
+	}
+
+	return nil
+}
+
+// checkFreeHugePages returns an error if there are not enough free huge pages
+// of pageSizeKb to back memoryMB of guest memory.
+func checkFreeHugePages(memoryMB uint32, pageSizeKb uint64) error {
+	if pageSizeKb == 0 {
+		return fmt.Errorf("Invalid huge page size")
+	}
+
+	path := filepath.Join(sysHugePagesDir, fmt.Sprintf("hugepages-%dkB", pageSizeKb), "free_hugepages")
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("Huge pages of %d kB are not supported by the host", pageSizeKb)
+		}
+		return err
+	}
+
+	free, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return fmt.Errorf("Invalid number of free huge pages in %s: %v", path, err)
+	}
+
+	needed := (uint64(memoryMB)*1024 + pageSizeKb - 1) / pageSizeKb
+	if free < needed {
+		return fmt.Errorf("Not enough free huge pages for %d MiB of guest memory: %d pages of %d kB are needed but only %d are free, increase vm.nr_hugepages or disable enable_hugepages",
+			memoryMB, needed, pageSizeKb, free)
+	}
+
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDefaultHugePageSizeKb(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "hugepages")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	memInfo := filepath.Join(dir, "meminfo")
+
+	_, err = getDefaultHugePageSizeKb(memInfo)
+	assert.Error(err)
+
+	assert.NoError(ioutil.WriteFile(memInfo, []byte("MemTotal:       16310532 kB\nHugePages_Total:       0\nHugepagesize:       2048 kB\n"), 0640))
+	size, err := getDefaultHugePageSizeKb(memInfo)
+	assert.NoError(err)
+	assert.Equal(uint64(2048), size)
+
+	assert.NoError(ioutil.WriteFile(memInfo, []byte("MemTotal:       16310532 kB\n"), 0640))
+	_, err = getDefaultHugePageSizeKb(memInfo)
+	assert.Error(err)
+}
+
+func TestCheckFreeHugePages(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "hugepages")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedDir := sysHugePagesDir
+	sysHugePagesDir = dir
+	defer func() {
+		sysHugePagesDir = savedDir
+	}()
+
+	// size not supported by the host
+	assert.Error(checkFreeHugePages(1024, hugePages2MSizeKb))
+	assert.Error(checkFreeHugePages(1024, 0))
+
+	pagesDir := filepath.Join(dir, "hugepages-2048kB")
+	assert.NoError(os.MkdirAll(pagesDir, DirMode))
+	freePages := filepath.Join(pagesDir, "free_hugepages")
+
+	assert.NoError(ioutil.WriteFile(freePages, []byte("512\n"), 0640))
+	assert.NoError(checkFreeHugePages(1024, hugePages2MSizeKb))
+	assert.Error(checkFreeHugePages(1025, hugePages2MSizeKb))
+
+	assert.NoError(ioutil.WriteFile(freePages, []byte("invalid\n"), 0640))
+	assert.Error(checkFreeHugePages(1024, hugePages2MSizeKb))
+}
+
+func TestCheckHugePagesMount(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "hugepages")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	assert.Error(checkHugePagesMount(dir))
+	assert.Error(checkHugePagesMount(filepath.Join(dir, "missing")))
+}
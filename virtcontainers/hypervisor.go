@@ -10,7 +10,6 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
@@ -76,8 +75,21 @@ const (
 	// where the hypervisor has no console.sock, i.e firecracker
 	vSockLogsPort = 1025
 
+	// Port where the agent serves its debug console, when enabled.
+	vSockDebugConsolePort = 1026
+
 	// MinHypervisorMemory is the minimum memory required for a VM.
 	MinHypervisorMemory = 256
+
+	// default timeouts in seconds, used when the configuration doesn't
+	// set them
+	defaultVMMAPITimeout   = 10
+	defaultBootTimeout     = 10
+	defaultShutdownTimeout = 15
+
+	// maxHypervisorTimeout is the longest timeout in seconds accepted in
+	// the configuration, a VMM not answering after that is stuck.
+	maxHypervisorTimeout = 600
 )
 
 // In some architectures the maximum number of vCPUs depends on the number of physical cores.
@@ -234,6 +246,21 @@ type HypervisorConfig struct {
 	//DefaultMaxVCPUs specifies the maximum number of vCPUs for the VM.
 	DefaultMaxVCPUs uint32
 
+	// CPUFeatures is a comma separated list of the CPU features enabled
+	// or disabled in the guest, e.g. "pmu=off,avx512f=on".
+	CPUFeatures string
+
+	// EnableGuestSMT exposes the vCPUs to the guest as hyperthreads of
+	// the same cores, as many threads per core as the host ones.
+	EnableGuestSMT bool
+
+	// CPUSockets, CPUCores and CPUThreads are the guest CPU topology, the
+	// sockets of cores of threads making the maximum vCPUs. The hypervisor
+	// topology is used when CPUSockets is 0.
+	CPUSockets uint32
+	CPUCores   uint32
+	CPUThreads uint32
+
 	// DefaultMem specifies default memory size in MiB for the VM.
 	MemorySize uint32
 
@@ -284,6 +311,10 @@ type HypervisorConfig struct {
 	// JailerPath is the jailer executable host path.
 	JailerPath string
 
+	// JailerChrootBase is the directory under which the jailed VMs are
+	// set up, the sandbox temporary tree being used when empty.
+	JailerChrootBase string
+
 	// BlockDeviceDriver specifies the driver to be used for block device
 	// either VirtioSCSI or VirtioBlock with the default driver being defaultBlockDriver
 	BlockDeviceDriver string
@@ -318,6 +349,14 @@ type HypervisorConfig struct {
 	// VirtioFSExtraArgs passes options to virtiofsd daemon
 	VirtioFSExtraArgs []string
 
+	// VirtioFSThreadPoolSize is the number of threads of virtiofsd
+	// handling the requests of the guest, 0 for its default.
+	VirtioFSThreadPoolSize uint32
+
+	// VirtioFSMaxRestarts is how many times virtiofsd is restarted after
+	// crashing before stopping the sandbox.
+	VirtioFSMaxRestarts uint32
+
 	// File based memory backend root directory
 	FileBackedMemRootDir string
 
@@ -338,9 +377,23 @@ type HypervisorConfig struct {
 	// Denotes whether flush requests for the device are ignored.
 	BlockDeviceCacheNoflush bool
 
+	// BlockDeviceQueues is the number of queues of the virtio-blk
+	// drives hot added, the hypervisor default being used when 0.
+	BlockDeviceQueues uint32
+
+	// BlockDeviceNaming is how the agent finds the drives hot added in
+	// the guest: by their index in the drive pool, or by the serial
+	// number reported by the hypervisor when it supports it.
+	BlockDeviceNaming string
+
 	// DisableBlockDeviceUse disallows a block device from being used.
 	DisableBlockDeviceUse bool
 
+	// BlockVolumeThreshold is the size in MiB from which the read-only
+	// volumes backed by a host block device are attached to the VM as
+	// block devices rather than shared, 0 to always share them.
+	BlockVolumeThreshold uint32
+
 	// EnableIOThreads enables IO to be processed in a separate thread.
 	// Supported currently for virtio-scsi driver.
 	EnableIOThreads bool
@@ -376,6 +429,10 @@ type HypervisorConfig struct {
 	// DisableImageNvdimm is used to disable guest rootfs image nvdimm devices
 	DisableImageNvdimm bool
 
+	// UsePmemRootfs exposes the guest rootfs image as a persistent memory
+	// device mounted with DAX, when the hypervisor supports it.
+	UsePmemRootfs bool
+
 	// HotplugVFIOOnRootBus is used to indicate if devices need to be hotplugged on the
 	// root bus instead of a bridge.
 	HotplugVFIOOnRootBus bool
@@ -390,9 +447,26 @@ type HypervisorConfig struct {
 	// BootFromTemplate used to indicate if the VM should be created from a template VM
 	BootFromTemplate bool
 
+	// IncomingMigrationURI is the URI of the migration stream the VM is
+	// restored from instead of being booted, see migrateSandbox().
+	IncomingMigrationURI string
+
 	// DisableVhostNet is used to indicate if host supports vhost_net
 	DisableVhostNet bool
 
+	// NetworkQueues is the number of queues of the network devices when
+	// the hypervisor supports multi queue devices, 0 meaning one per
+	// vCPU.
+	NetworkQueues uint32
+
+	// DisableNetOffloads is the list of offloads, e.g. "tso4", disabled
+	// on the tap devices and on the virtio-net devices of the VM.
+	DisableNetOffloads []string
+
+	// InterfaceNetOffloads overrides DisableNetOffloads for the network
+	// interfaces of the sandbox it lists by name, e.g. "eth0".
+	InterfaceNetOffloads map[string][]string
+
 	// EnableVhostUserStore is used to indicate if host supports vhost-user-blk/scsi
 	EnableVhostUserStore bool
 
@@ -403,6 +477,68 @@ type HypervisorConfig struct {
 	// GuestHookPath is the path within the VM that will be used for 'drop-in' hooks
 	GuestHookPath string
 
+	// EnableAnnotations is the list of hypervisor annotations, without
+	// their prefix, a sandbox is allowed to set. Each item is a regular
+	// expression matching the whole annotation name. All of them are
+	// allowed when the list is nil.
+	EnableAnnotations []string
+
+	// BootProfile selects the optional work done to boot the VM.
+	BootProfile BootProfile
+
+	// VMMAPITimeout is the time in seconds to wait for the VMM API to
+	// answer once the VMM process is started, 0 meaning the default.
+	VMMAPITimeout uint32
+
+	// BootTimeout is the time in seconds to wait for the VM to be
+	// running, 0 meaning the default.
+	BootTimeout uint32
+
+	// ShutdownTimeout is the time in seconds given to the VMM to stop
+	// gracefully before being killed, 0 meaning the default.
+	ShutdownTimeout uint32
+
+	// VMMLogLevel is the level of the VMM logs, the default depending on
+	// Debug.
+	VMMLogLevel string
+
+	// VMMLogDir is the directory the VMM logs and metrics files of the
+	// sandboxes are written to, they are forwarded to the runtime log
+	// instead when empty.
+	VMMLogDir string
+
+	// VMMForwardMetrics forwards the VMM metrics to the runtime log.
+	VMMForwardMetrics bool
+
+	// CPUTemplate is the firecracker CPU template masking the CPU features
+	// exposed to the guest, the host ones being exposed when empty.
+	CPUTemplate string
+
+	// VSockChannels are the guest vsock ports made reachable from the
+	// host, keyed by channel name, in addition to the agent ones.
+	VSockChannels map[string]uint32
+
+	// ConfidentialGuest encrypts the memory of the VM with the protection
+	// the host provides, AMD SEV only for now, so that the host can't read it.
+	ConfidentialGuest bool
+
+	// SEVPolicy is the SEV guest policy of confidential guests, 0 meaning
+	// the default policy which forbids debugging the VM.
+	SEVPolicy uint32
+
+	// EmulatorThreadsCPUs is the host CPU list, e.g. "0-1,4", the
+	// hypervisor threads other than the vCPUs are pinned to, so that the
+	// emulation doesn't run on the CPUs of the vCPUs. Not pinned if empty.
+	EmulatorThreadsCPUs string
+
+	// EmulatorThreadsNice is the nice value of the emulator threads, 0
+	// leaving it alone.
+	EmulatorThreadsNice int32
+
+	// EmulatorRTPriority runs the emulator threads with the SCHED_FIFO
+	// real-time policy at this priority, 0 leaving their policy alone.
+	EmulatorRTPriority uint32
+
 	// VMid is the id of the VM that create the hypervisor if the VM is created by the factory.
 	// VMid is "" if the hypervisor is not created by the factory.
 	VMid string
@@ -419,6 +555,10 @@ func (conf *HypervisorConfig) checkTemplateConfig() error {
 	}
 
 	if conf.BootToBeTemplate || conf.BootFromTemplate {
+		if conf.IncomingMigrationURI != "" {
+			return fmt.Errorf("Cannot restore a vm template from a migration")
+		}
+
 		if conf.MemoryPath == "" {
 			return fmt.Errorf("Missing MemoryPath for vm template")
 		}
@@ -440,10 +580,63 @@ func (conf *HypervisorConfig) valid() error {
 		return fmt.Errorf("Missing image and initrd path")
 	}
 
+	if conf.UsePmemRootfs && conf.DisableImageNvdimm {
+		return fmt.Errorf("Persistent memory rootfs and disabled image nvdimm are mutually exclusive")
+	}
+
 	if err := conf.checkTemplateConfig(); err != nil {
 		return err
 	}
 
+	for _, t := range []struct {
+		name    string
+		timeout uint32
+	}{
+		{"VMM API", conf.VMMAPITimeout},
+		{"boot", conf.BootTimeout},
+		{"shutdown", conf.ShutdownTimeout},
+	} {
+		if t.timeout > maxHypervisorTimeout {
+			return fmt.Errorf("Invalid %s timeout %ds, the maximum is %ds", t.name, t.timeout, maxHypervisorTimeout)
+		}
+	}
+
+	if err := checkVSockChannels(conf.VSockChannels); err != nil {
+		return err
+	}
+
+	if err := conf.checkNetOffloads(); err != nil {
+		return err
+	}
+
+	if err := config.ValidBlockNaming(conf.BlockDeviceNaming); err != nil {
+		return err
+	}
+
+	if conf.SharedFS == config.VirtioFS {
+		switch conf.VirtioFSCache {
+		case "", "none", "auto", virtioFsCacheAlways:
+		default:
+			return fmt.Errorf("Invalid virtio-fs cache mode %q, supported modes are none, auto and always", conf.VirtioFSCache)
+		}
+	}
+
+	if _, err := parseCPUFeatures(conf.CPUFeatures); err != nil {
+		return err
+	}
+
+	if err := conf.checkCPUTopology(); err != nil {
+		return err
+	}
+
+	if conf.ConfidentialGuest && conf.UsePmemRootfs {
+		return fmt.Errorf("Persistent memory rootfs can't be used by confidential guests")
+	}
+
+	if conf.ConfidentialGuest && (conf.BootToBeTemplate || conf.BootFromTemplate) {
+		return fmt.Errorf("Confidential guests can't be created from a vm template")
+	}
+
 	if conf.NumVCPUs == 0 {
 		conf.NumVCPUs = defaultVCPUs
 	}
@@ -471,6 +664,62 @@ func (conf *HypervisorConfig) valid() error {
 	return nil
 }
 
+// checkCPUTopology checks the guest CPU topology holds the vCPUs, the
+// maximum vCPUs being the ones of the topology.
+func (conf *HypervisorConfig) checkCPUTopology() error {
+	if conf.CPUSockets == 0 {
+		if conf.CPUCores != 0 || conf.CPUThreads != 0 {
+			return fmt.Errorf("The number of CPU sockets of the guest CPU topology is missing")
+		}
+		return nil
+	}
+
+	if conf.EnableGuestSMT {
+		return fmt.Errorf("Guest SMT can't be enabled with a guest CPU topology, set its threads instead")
+	}
+
+	if conf.CPUCores == 0 {
+		conf.CPUCores = 1
+	}
+
+	if conf.CPUThreads == 0 {
+		conf.CPUThreads = 1
+	}
+
+	vcpus := conf.CPUSockets * conf.CPUCores * conf.CPUThreads
+	if vcpus < conf.NumVCPUs {
+		return fmt.Errorf("The guest CPU topology of %d sockets of %d cores of %d threads can't hold %d vCPUs",
+			conf.CPUSockets, conf.CPUCores, conf.CPUThreads, conf.NumVCPUs)
+	}
+
+	conf.DefaultMaxVCPUs = vcpus
+
+	return nil
+}
+
+func timeoutOrDefault(timeout, def uint32) int {
+	if timeout == 0 {
+		return int(def)
+	}
+	return int(timeout)
+}
+
+// vmmAPITimeout returns in seconds how long to wait for the VMM API to answer.
+func (conf *HypervisorConfig) vmmAPITimeout() int {
+	return timeoutOrDefault(conf.VMMAPITimeout, defaultVMMAPITimeout)
+}
+
+// bootTimeout returns in seconds how long to wait for the VM to be running.
+func (conf *HypervisorConfig) bootTimeout() int {
+	return timeoutOrDefault(conf.BootTimeout, defaultBootTimeout)
+}
+
+// shutdownTimeout returns in seconds how long to wait for the VMM to stop
+// gracefully.
+func (conf *HypervisorConfig) shutdownTimeout() int {
+	return timeoutOrDefault(conf.ShutdownTimeout, defaultShutdownTimeout)
+}
+
 // AddKernelParam allows the addition of new kernel parameters to an existing
 // hypervisor configuration.
 func (conf *HypervisorConfig) AddKernelParam(p Param) error {
@@ -538,6 +787,23 @@ func (conf *HypervisorConfig) isCustomAsset(t types.AssetType) bool {
 	return ok
 }
 
+func (conf *HypervisorConfig) setAssetPath(t types.AssetType, path string) error {
+	switch t {
+	case types.KernelAsset:
+		conf.KernelPath = path
+	case types.ImageAsset:
+		conf.ImagePath = path
+	case types.InitrdAsset:
+		conf.InitrdPath = path
+	case types.FirmwareAsset:
+		conf.FirmwarePath = path
+	default:
+		return fmt.Errorf("Unsupported asset type %v", t)
+	}
+
+	return nil
+}
+
 // KernelAssetPath returns the guest kernel path
 func (conf *HypervisorConfig) KernelAssetPath() (string, error) {
 	return conf.assetPath(types.KernelAsset)
@@ -624,6 +890,77 @@ func SerializeParams(params []Param, delim string) []string {
 	return parameters
 }
 
+// repeatableKernelParams are the kernel parameters honoured by the kernel
+// each time they are given, they are never overridden.
+var repeatableKernelParams = map[string]bool{
+	"console": true,
+}
+
+// mergeKernelParams returns a new list made of the given lists of kernel
+// parameters. A parameter overrides any previous parameter with the same
+// key but keeps the position of the first one, so the result only depends
+// on the order of the lists. Duplicated parameters are removed.
+func mergeKernelParams(lists ...[]Param) []Param {
+	var params []Param
+	index := make(map[string]int)
+	seen := make(map[Param]bool)
+
+	for _, list := range lists {
+		for _, p := range list {
+			if p.Key == "" && p.Value == "" {
+				continue
+			}
+
+			if p.Key == "" || repeatableKernelParams[p.Key] {
+				if !seen[p] {
+					seen[p] = true
+					params = append(params, p)
+				}
+				continue
+			}
+
+			if i, ok := index[p.Key]; ok {
+				params[i].Value = p.Value
+				continue
+			}
+
+			index[p.Key] = len(params)
+			params = append(params, p)
+		}
+	}
+
+	return params
+}
+
+// appendKernelParams returns the defaults followed by the extra kernel
+// parameters, usually the ones from the configuration. The extra
+// parameters are kept as they are given, including repeated keys, and
+// only drop the defaults they set again.
+func appendKernelParams(defaults, extra []Param) []Param {
+	var params []Param
+	keys := make(map[string]bool)
+
+	for _, p := range extra {
+		if p.Key != "" && !repeatableKernelParams[p.Key] {
+			keys[p.Key] = true
+		}
+	}
+
+	for _, p := range defaults {
+		if !keys[p.Key] {
+			params = append(params, p)
+		}
+	}
+
+	for _, p := range extra {
+		if p.Key != "" || p.Value != "" {
+			params = append(params, p)
+		}
+	}
+
+	return params
+}
+
 // DeserializeParams converts []string to []Param
 func DeserializeParams(parameters []string) []Param {
 	var params []Param
@@ -745,7 +1082,7 @@ func generateVMSocket(id string, useVsock bool, vmStogarePath string) (interface
 		}, nil
 	}
 
-	path, err := utils.BuildSocketPath(filepath.Join(vmStogarePath, id), defaultSocketName)
+	path, err := utils.BuildSocketPath(SandboxVMPath(vmStogarePath, id), defaultSocketName)
 	if err != nil {
 		return nil, err
 	}
@@ -767,6 +1104,8 @@ type hypervisor interface {
 	pauseSandbox() error
 	saveSandbox() error
 	resumeSandbox() error
+	// rebootSandbox reboots the guest, keeping the devices of the VM.
+	rebootSandbox() error
 	addDevice(devInfo interface{}, devType deviceType) error
 	hotplugAddDevice(devInfo interface{}, devType deviceType) (interface{}, error)
 	hotplugRemoveDevice(devInfo interface{}, devType deviceType) (interface{}, error)
@@ -781,9 +1120,23 @@ type hypervisor interface {
 	// getPids returns a slice of hypervisor related process ids.
 	// The hypervisor pid must be put at index 0.
 	getPids() []int
+	// getVMPath returns the host directory of the files of the VM, e.g.
+	// its sockets.
+	getVMPath() string
 	fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error
 	toGrpc() ([]byte, error)
 	check() error
+	// describe queries the VMM for the live state of the VM.
+	describe() (VMInfo, error)
+	// migrateSandbox sends the state of the paused VM to uri, which is
+	// either "file://<path>", "tcp:<host>:<port>" or "unix:<path>". The
+	// VM is resumed elsewhere by booting with the same URI as
+	// IncomingMigrationURI.
+	migrateSandbox(uri string) error
+	// launchMeasurement returns the base64 encoded measurement of the
+	// initial state of a confidential guest, which attestation services
+	// check before trusting the VM.
+	launchMeasurement() (string, error)
 
 	save() persistapi.HypervisorState
 	load(persistapi.HypervisorState)
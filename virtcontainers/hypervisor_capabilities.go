@@ -0,0 +1,193 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/sirupsen/logrus"
+)
+
+// HypervisorCapabilities describes the features of a configured hypervisor
+// the orchestrators can rely on, e.g. to decide which pods to schedule on
+// the node.
+type HypervisorCapabilities struct {
+	Hypervisor HypervisorType `json:"hypervisor"`
+	Path       string         `json:"path"`
+	Version    string         `json:"version"`
+
+	BlockDeviceHotplug bool `json:"blockDeviceHotplug"`
+	MemoryHotplug      bool `json:"memoryHotplug"`
+	CPUHotplug         bool `json:"cpuHotplug"`
+	VirtioFS           bool `json:"virtioFS"`
+	Virtio9P           bool `json:"virtio9P"`
+	VFIOHotplug        bool `json:"vfioHotplug"`
+	VMSnapshot         bool `json:"vmSnapshot"`
+	MultiQueue         bool `json:"multiQueue"`
+}
+
+// driverCapabilities returns the capabilities the virtcontainers driver of
+// the hypervisor implements, without starting it.
+func driverCapabilities(ctx context.Context, hType HypervisorType, conf *HypervisorConfig) (types.Capabilities, string, error) {
+	path, err := conf.HypervisorAssetPath()
+	if err != nil {
+		return types.Capabilities{}, "", err
+	}
+
+	switch hType {
+	case QemuHypervisor:
+		arch := newQemuArch(*conf)
+		if path == "" {
+			if path, err = arch.qemuPath(); err != nil {
+				return types.Capabilities{}, "", err
+			}
+		}
+		return arch.capabilities(), path, nil
+	case AcrnHypervisor:
+		arch := newAcrnArch(*conf)
+		if path == "" {
+			if path, err = arch.acrnPath(); err != nil {
+				return types.Capabilities{}, "", err
+			}
+		}
+		return arch.capabilities(), path, nil
+	case FirecrackerHypervisor:
+		fc := &firecracker{ctx: ctx, config: *conf}
+		return fc.capabilities(), path, nil
+	case ClhHypervisor:
+		clh := &cloudHypervisor{ctx: ctx, config: *conf}
+		if path == "" {
+			path = defaultClhPath
+		}
+		return clh.capabilities(), path, nil
+	case MockHypervisor:
+		m := &mockHypervisor{}
+		return m.capabilities(), path, nil
+	}
+
+	return types.Capabilities{}, "", fmt.Errorf("Unknown hypervisor type %s", hType)
+}
+
+// getHypervisorVersion returns the version printed by the hypervisor
+// binary at path, the first word of its output looking like one.
+func getHypervisorVersion(path string) (string, error) {
+	out, err := exec.Command(path, "--version").CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("Could not get the version of hypervisor %s: %v", path, err)
+	}
+
+	lines := strings.SplitN(string(out), "\n", 2)
+	for _, word := range strings.Fields(lines[0]) {
+		word = strings.TrimRight(strings.TrimPrefix(word, "v"), ",")
+		if word != "" && word[0] >= '0' && word[0] <= '9' {
+			return word, nil
+		}
+	}
+
+	return "", fmt.Errorf("Could not parse the version of hypervisor %s: %q", path, lines[0])
+}
+
+// qemuHasVirtioFS returns if the QEMU binary at path provides the vhost-user
+// filesystem device, the distribution builds often don't.
+func qemuHasVirtioFS(path string) bool {
+	out, err := exec.Command(path, "-device", "help").CombinedOutput()
+	if err != nil {
+		virtLog.WithError(err).WithField("path", path).Warn("Could not list the QEMU devices")
+		return false
+	}
+
+	return strings.Contains(string(out), "vhost-user-fs")
+}
+
+// hypervisorCapabilities reports the features of the configured hypervisor,
+// those of its driver narrowed to what its binary and the configuration
+// provide.
+func hypervisorCapabilities(ctx context.Context, hType HypervisorType, conf HypervisorConfig) (HypervisorCapabilities, error) {
+	caps, path, err := driverCapabilities(ctx, hType, &conf)
+	if err != nil {
+		return HypervisorCapabilities{}, err
+	}
+
+	result := HypervisorCapabilities{
+		Hypervisor:         hType,
+		Path:               path,
+		BlockDeviceHotplug: caps.IsBlockDeviceHotplugSupported() && !conf.DisableBlockDeviceUse,
+		MemoryHotplug:      caps.IsMemoryHotplugSupported(),
+		CPUHotplug:         caps.IsCPUHotplugSupported(),
+		VirtioFS:           caps.IsVirtioFSSupported(),
+		Virtio9P:           caps.IsVirtio9PSupported(),
+		VFIOHotplug:        caps.IsVFIOHotplugSupported(),
+		VMSnapshot:         caps.IsVMSnapshotSupported(),
+		MultiQueue:         caps.IsMultiQueueSupported(),
+	}
+
+	if hType == MockHypervisor {
+		return result, nil
+	}
+
+	result.Version, err = cachedHypervisorVersion(path, func() (string, error) {
+		return getHypervisorVersion(path)
+	})
+	if err != nil {
+		return HypervisorCapabilities{}, err
+	}
+
+	if hType == QemuHypervisor && result.VirtioFS {
+		result.VirtioFS = qemuHasVirtioFS(path)
+	}
+
+	return result, nil
+}
+
+// NegotiateSharedFS sets the shared file system of conf, when it's left to
+// the runtime, to the best one the hypervisor of type hType supports:
+// virtio-fs when a virtiofsd daemon is configured, then virtio-9p, then
+// none. The choice is recorded in the configuration, persisted with the
+// sandbox.
+func NegotiateSharedFS(ctx context.Context, hType HypervisorType, conf *HypervisorConfig) error {
+	if conf.SharedFS != "" {
+		return nil
+	}
+
+	caps, path, err := driverCapabilities(ctx, hType, conf)
+	if err != nil {
+		return err
+	}
+
+	// virtiofsd can't be saved in the VM templates
+	template := conf.BootToBeTemplate || conf.BootFromTemplate
+
+	switch {
+	case caps.IsVirtioFSSupported() && conf.VirtioFSDaemon != "" && !template &&
+		(hType != QemuHypervisor || qemuHasVirtioFS(path)):
+		conf.SharedFS = config.VirtioFS
+	case caps.IsVirtio9PSupported():
+		conf.SharedFS = config.Virtio9P
+	default:
+		conf.SharedFS = config.NoSharedFS
+	}
+
+	virtLog.WithFields(logrus.Fields{
+		"hypervisor": hType,
+		"shared-fs":  conf.SharedFS,
+	}).Info("Shared file system negotiated")
+
+	return nil
+}
+
+// fsShareSupported returns whether the VM of h shares a file system with the
+// host, the hypervisor supporting it and no "none" shared file system being
+// negotiated. The mounts of the sandboxes sharing no file system are copied
+// to the guest through the agent.
+func fsShareSupported(h hypervisor) bool {
+	caps := h.capabilities()
+	return caps.IsFsSharingSupported() && h.hypervisorConfig().SharedFS != config.NoSharedFS
+}
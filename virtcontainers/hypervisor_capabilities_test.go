@@ -0,0 +1,186 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeFakeHypervisor(t *testing.T, dir, name, script string) string {
+	path := filepath.Join(dir, name)
+	err := ioutil.WriteFile(path, []byte("#!/bin/sh\n"+script), 0750)
+	assert.NoError(t, err)
+	return path
+}
+
+func TestGetHypervisorVersion(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "hypervisor-capabilities")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	for output, expected := range map[string]string{
+		"QEMU emulator version 5.0.0 (kata-static)\nCopyright (c) 2003-2020": "5.0.0",
+		"Firecracker v0.21.1":                    "0.21.1",
+		"cloud-hypervisor v0.10.0":               "0.10.0",
+		"DM version is: 1.6-unstable-2020-04-08": "1.6-unstable-2020-04-08",
+	} {
+		path := writeFakeHypervisor(t, dir, "hypervisor", "echo '"+output+"'\n")
+		version, err := getHypervisorVersion(path)
+		assert.NoError(err, output)
+		assert.Equal(expected, version)
+	}
+
+	path := writeFakeHypervisor(t, dir, "hypervisor", "echo 'no version here'\n")
+	_, err = getHypervisorVersion(path)
+	assert.Error(err)
+
+	path = writeFakeHypervisor(t, dir, "hypervisor", "exit 1\n")
+	_, err = getHypervisorVersion(path)
+	assert.Error(err)
+}
+
+func TestHypervisorCapabilitiesFirecracker(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "hypervisor-capabilities")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := writeFakeHypervisor(t, dir, "firecracker", "echo 'Firecracker v0.21.1'\n")
+
+	caps, err := GetHypervisorCapabilities(context.Background(), FirecrackerHypervisor, HypervisorConfig{HypervisorPath: path})
+	assert.NoError(err)
+	assert.Equal(HypervisorCapabilities{
+		Hypervisor:         FirecrackerHypervisor,
+		Path:               path,
+		Version:            "0.21.1",
+		BlockDeviceHotplug: true,
+	}, caps)
+
+	caps, err = GetHypervisorCapabilities(context.Background(), FirecrackerHypervisor, HypervisorConfig{
+		HypervisorPath:        path,
+		DisableBlockDeviceUse: true,
+	})
+	assert.NoError(err)
+	assert.False(caps.BlockDeviceHotplug)
+
+	_, err = GetHypervisorCapabilities(context.Background(), FirecrackerHypervisor, HypervisorConfig{HypervisorPath: filepath.Join(dir, "missing")})
+	assert.Error(err)
+
+	_, err = GetHypervisorCapabilities(context.Background(), HypervisorType("foo"), HypervisorConfig{HypervisorPath: path})
+	assert.Error(err)
+}
+
+func TestHypervisorCapabilitiesQemuVirtioFS(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "hypervisor-capabilities")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	script := `case "$1" in
+--version) echo 'QEMU emulator version 5.0.0';;
+-device) echo 'name "virtio-blk-pci", bus PCI';;
+esac
+`
+	path := writeFakeHypervisor(t, dir, "qemu-no-virtiofs", script)
+
+	caps, err := GetHypervisorCapabilities(context.Background(), QemuHypervisor, HypervisorConfig{HypervisorPath: path})
+	assert.NoError(err)
+	assert.Equal("5.0.0", caps.Version)
+	assert.True(caps.MultiQueue)
+	assert.False(caps.VirtioFS)
+
+	// the capabilities of the driver which depend on the architecture are
+	// covered by the qemuArch tests
+	archCaps := newQemuArch(HypervisorConfig{}).capabilities()
+	if !archCaps.IsVirtioFSSupported() {
+		return
+	}
+
+	path = writeFakeHypervisor(t, dir, "qemu-virtiofs", `case "$1" in
+--version) echo 'QEMU emulator version 5.0.0';;
+-device) echo 'name "vhost-user-fs-pci", bus PCI';;
+esac
+`)
+
+	caps, err = GetHypervisorCapabilities(context.Background(), QemuHypervisor, HypervisorConfig{HypervisorPath: path})
+	assert.NoError(err)
+	assert.True(caps.VirtioFS)
+}
+
+func TestNegotiateSharedFS(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "hypervisor-capabilities")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	noVirtioFS := writeFakeHypervisor(t, dir, "qemu-no-virtiofs", "echo 'name \"virtio-blk-pci\", bus PCI'\n")
+	virtioFS := writeFakeHypervisor(t, dir, "qemu-virtiofs", "echo 'name \"vhost-user-fs-pci\", bus PCI'\n")
+
+	// a configured shared file system is kept
+	conf := HypervisorConfig{HypervisorPath: virtioFS, SharedFS: config.Virtio9P, VirtioFSDaemon: "/usr/bin/virtiofsd"}
+	assert.NoError(NegotiateSharedFS(ctx, QemuHypervisor, &conf))
+	assert.Equal(config.Virtio9P, conf.SharedFS)
+
+	conf = HypervisorConfig{HypervisorPath: noVirtioFS, VirtioFSDaemon: "/usr/bin/virtiofsd"}
+	assert.NoError(NegotiateSharedFS(ctx, QemuHypervisor, &conf))
+	assert.Equal(config.Virtio9P, conf.SharedFS)
+
+	// virtio-fs requires a daemon
+	conf = HypervisorConfig{HypervisorPath: virtioFS}
+	assert.NoError(NegotiateSharedFS(ctx, QemuHypervisor, &conf))
+	assert.Equal(config.Virtio9P, conf.SharedFS)
+
+	if archCaps := newQemuArch(HypervisorConfig{}).capabilities(); archCaps.IsVirtioFSSupported() {
+		conf = HypervisorConfig{HypervisorPath: virtioFS, VirtioFSDaemon: "/usr/bin/virtiofsd"}
+		assert.NoError(NegotiateSharedFS(ctx, QemuHypervisor, &conf))
+		assert.Equal(config.VirtioFS, conf.SharedFS)
+
+		// virtiofsd can't be saved in the VM templates
+		conf = HypervisorConfig{HypervisorPath: virtioFS, VirtioFSDaemon: "/usr/bin/virtiofsd", BootToBeTemplate: true}
+		assert.NoError(NegotiateSharedFS(ctx, QemuHypervisor, &conf))
+		assert.Equal(config.Virtio9P, conf.SharedFS)
+	}
+
+	conf = HypervisorConfig{HypervisorPath: noVirtioFS, VirtioFSDaemon: "/usr/bin/virtiofsd"}
+	assert.NoError(NegotiateSharedFS(ctx, FirecrackerHypervisor, &conf))
+	assert.Equal(config.NoSharedFS, conf.SharedFS)
+
+	conf = HypervisorConfig{HypervisorPath: noVirtioFS, VirtioFSDaemon: "/usr/bin/virtiofsd"}
+	assert.NoError(NegotiateSharedFS(ctx, ClhHypervisor, &conf))
+	assert.Equal(config.VirtioFS, conf.SharedFS)
+
+	conf = HypervisorConfig{}
+	assert.Error(NegotiateSharedFS(ctx, HypervisorType("foo"), &conf))
+}
+
+func TestFsShareSupported(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.False(fsShareSupported(&mockHypervisor{}))
+
+	q := &qemu{
+		ctx:    context.Background(),
+		arch:   newQemuArch(HypervisorConfig{}),
+		config: HypervisorConfig{SharedFS: config.Virtio9P},
+	}
+	assert.True(fsShareSupported(q))
+
+	q.config.SharedFS = config.NoSharedFS
+	assert.False(fsShareSupported(q))
+}
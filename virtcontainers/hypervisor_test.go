@@ -13,6 +13,7 @@ import (
 	"testing"
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/stretchr/testify/assert"
 )
@@ -134,6 +135,124 @@ func TestHypervisorConfigIsValid(t *testing.T) {
 	testHypervisorConfigValid(t, hypervisorConfig, true)
 }
 
+func TestHypervisorConfigPmemRootfsNoNvdimm(t *testing.T) {
+	hypervisorConfig := &HypervisorConfig{
+		KernelPath:         fmt.Sprintf("%s/%s", testDir, testKernel),
+		ImagePath:          fmt.Sprintf("%s/%s", testDir, testImage),
+		HypervisorPath:     fmt.Sprintf("%s/%s", testDir, testHypervisor),
+		UsePmemRootfs:      true,
+		DisableImageNvdimm: true,
+	}
+	testHypervisorConfigValid(t, hypervisorConfig, false)
+
+	hypervisorConfig.DisableImageNvdimm = false
+	testHypervisorConfigValid(t, hypervisorConfig, true)
+}
+
+func TestHypervisorConfigConfidentialGuest(t *testing.T) {
+	hypervisorConfig := &HypervisorConfig{
+		KernelPath:        fmt.Sprintf("%s/%s", testDir, testKernel),
+		ImagePath:         fmt.Sprintf("%s/%s", testDir, testImage),
+		HypervisorPath:    fmt.Sprintf("%s/%s", testDir, testHypervisor),
+		ConfidentialGuest: true,
+	}
+	testHypervisorConfigValid(t, hypervisorConfig, true)
+
+	hypervisorConfig.UsePmemRootfs = true
+	testHypervisorConfigValid(t, hypervisorConfig, false)
+
+	hypervisorConfig.UsePmemRootfs = false
+	hypervisorConfig.BootToBeTemplate = true
+	hypervisorConfig.MemoryPath = "foobar"
+	testHypervisorConfigValid(t, hypervisorConfig, false)
+}
+
+func TestHypervisorConfigTimeouts(t *testing.T) {
+	assert := assert.New(t)
+
+	hypervisorConfig := &HypervisorConfig{
+		KernelPath:     fmt.Sprintf("%s/%s", testDir, testKernel),
+		ImagePath:      fmt.Sprintf("%s/%s", testDir, testImage),
+		HypervisorPath: fmt.Sprintf("%s/%s", testDir, testHypervisor),
+	}
+
+	assert.Equal(defaultVMMAPITimeout, hypervisorConfig.vmmAPITimeout())
+	assert.Equal(defaultBootTimeout, hypervisorConfig.bootTimeout())
+	assert.Equal(defaultShutdownTimeout, hypervisorConfig.shutdownTimeout())
+
+	hypervisorConfig.VMMAPITimeout = 30
+	hypervisorConfig.BootTimeout = maxHypervisorTimeout
+	hypervisorConfig.ShutdownTimeout = 1
+	testHypervisorConfigValid(t, hypervisorConfig, true)
+	assert.Equal(30, hypervisorConfig.vmmAPITimeout())
+	assert.Equal(maxHypervisorTimeout, hypervisorConfig.bootTimeout())
+	assert.Equal(1, hypervisorConfig.shutdownTimeout())
+
+	hypervisorConfig.BootTimeout = maxHypervisorTimeout + 1
+	testHypervisorConfigValid(t, hypervisorConfig, false)
+}
+
+func TestHypervisorConfigCPUTopology(t *testing.T) {
+	assert := assert.New(t)
+
+	hypervisorConfig := &HypervisorConfig{
+		KernelPath:      fmt.Sprintf("%s/%s", testDir, testKernel),
+		ImagePath:       fmt.Sprintf("%s/%s", testDir, testImage),
+		HypervisorPath:  fmt.Sprintf("%s/%s", testDir, testHypervisor),
+		NumVCPUs:        2,
+		DefaultMaxVCPUs: 16,
+		CPUSockets:      2,
+		CPUCores:        2,
+	}
+
+	// the topology makes the maximum vCPUs
+	testHypervisorConfigValid(t, hypervisorConfig, true)
+	assert.Equal(uint32(1), hypervisorConfig.CPUThreads)
+	assert.Equal(uint32(4), hypervisorConfig.DefaultMaxVCPUs)
+
+	hypervisorConfig.NumVCPUs = 5
+	testHypervisorConfigValid(t, hypervisorConfig, false)
+
+	hypervisorConfig.NumVCPUs = 2
+	hypervisorConfig.EnableGuestSMT = true
+	testHypervisorConfigValid(t, hypervisorConfig, false)
+
+	hypervisorConfig.EnableGuestSMT = false
+	hypervisorConfig.CPUSockets = 0
+	testHypervisorConfigValid(t, hypervisorConfig, false)
+}
+
+func TestHypervisorConfigBlockDeviceNaming(t *testing.T) {
+	hypervisorConfig := &HypervisorConfig{
+		KernelPath:        fmt.Sprintf("%s/%s", testDir, testKernel),
+		ImagePath:         fmt.Sprintf("%s/%s", testDir, testImage),
+		HypervisorPath:    fmt.Sprintf("%s/%s", testDir, testHypervisor),
+		BlockDeviceNaming: "serial",
+	}
+	testHypervisorConfigValid(t, hypervisorConfig, true)
+
+	hypervisorConfig.BlockDeviceNaming = "wwn"
+	testHypervisorConfigValid(t, hypervisorConfig, false)
+}
+
+func TestHypervisorConfigVirtioFSCache(t *testing.T) {
+	hypervisorConfig := &HypervisorConfig{
+		KernelPath:     fmt.Sprintf("%s/%s", testDir, testKernel),
+		ImagePath:      fmt.Sprintf("%s/%s", testDir, testImage),
+		HypervisorPath: fmt.Sprintf("%s/%s", testDir, testHypervisor),
+		SharedFS:       config.VirtioFS,
+		VirtioFSCache:  "auto",
+	}
+	testHypervisorConfigValid(t, hypervisorConfig, true)
+
+	hypervisorConfig.VirtioFSCache = "sometimes"
+	testHypervisorConfigValid(t, hypervisorConfig, false)
+
+	// the cache mode is only used by virtio-fs
+	hypervisorConfig.SharedFS = config.Virtio9P
+	testHypervisorConfigValid(t, hypervisorConfig, true)
+}
+
 func TestHypervisorConfigValidTemplateConfig(t *testing.T) {
 	hypervisorConfig := &HypervisorConfig{
 		KernelPath:       fmt.Sprintf("%s/%s", testDir, testKernel),
@@ -325,6 +444,95 @@ func TestDeserializeParams(t *testing.T) {
 	testDeserializeParams(t, parameters, expected)
 }
 
+func TestMergeKernelParams(t *testing.T) {
+	assert := assert.New(t)
+
+	defaults := []Param{
+		{"root", "/dev/vda1"},
+		{"console", "hvc0"},
+		{"quiet", ""},
+		{"", "init=/sbin/init"},
+	}
+	overrides := []Param{
+		{"root", "/dev/pmem0p1"},
+		{"console", "ttyS0"},
+		{"quiet", ""},
+		{"", "init=/sbin/init"},
+		{"", ""},
+		{"foo", "bar"},
+	}
+
+	expected := []Param{
+		{"root", "/dev/pmem0p1"},
+		{"console", "hvc0"},
+		{"quiet", ""},
+		{"", "init=/sbin/init"},
+		{"console", "ttyS0"},
+		{"foo", "bar"},
+	}
+
+	params := mergeKernelParams(defaults, overrides)
+	assert.Equal(expected, params)
+
+	// the lists are left untouched
+	assert.Equal(Param{"root", "/dev/vda1"}, defaults[0])
+
+	params[0].Value = "/dev/vdb1"
+	assert.Equal(Param{"root", "/dev/pmem0p1"}, overrides[0])
+
+	assert.Nil(mergeKernelParams())
+}
+
+func TestAppendKernelParams(t *testing.T) {
+	assert := assert.New(t)
+
+	defaults := []Param{
+		{"root", "/dev/vda1"},
+		{"console", "hvc0"},
+		{"quiet", ""},
+		{"", "init=/sbin/init"},
+	}
+	extra := []Param{
+		{"memmap", "1G!4G"},
+		{"hugepagesz", "1G"},
+		{"hugepages", "2"},
+		{"hugepagesz", "2M"},
+		{"hugepages", "512"},
+		{"systemd.setenv", "A=1"},
+		{"", ""},
+		{"systemd.setenv", "B=2"},
+		{"memmap", "2G!8G"},
+		{"root", "/dev/pmem0p1"},
+		{"console", "ttyS0"},
+	}
+
+	expected := []Param{
+		{"console", "hvc0"},
+		{"quiet", ""},
+		{"", "init=/sbin/init"},
+		{"memmap", "1G!4G"},
+		{"hugepagesz", "1G"},
+		{"hugepages", "2"},
+		{"hugepagesz", "2M"},
+		{"hugepages", "512"},
+		{"systemd.setenv", "A=1"},
+		{"systemd.setenv", "B=2"},
+		{"memmap", "2G!8G"},
+		{"root", "/dev/pmem0p1"},
+		{"console", "ttyS0"},
+	}
+
+	assert.Equal(expected, appendKernelParams(defaults, extra))
+
+	// the defaults are left untouched
+	assert.Len(defaults, 4)
+	assert.Equal(Param{"root", "/dev/vda1"}, defaults[0])
+
+	assert.Equal(defaults, appendKernelParams(defaults, nil))
+	assert.Equal([]Param{{"memmap", "1G!4G"}, {"memmap", "2G!8G"}},
+		appendKernelParams(nil, []Param{{"memmap", "1G!4G"}, {"memmap", "2G!8G"}}))
+}
+
 func TestAddKernelParamValid(t *testing.T) {
 	var config HypervisorConfig
 	assert := assert.New(t)
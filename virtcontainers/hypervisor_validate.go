@@ -0,0 +1,161 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+)
+
+// ConfigError is an invalid setting of a hypervisor configuration.
+type ConfigError struct {
+	// Field is the HypervisorConfig field of the setting, empty when the
+	// error is about several fields.
+	Field string `json:"field,omitempty"`
+
+	Message string `json:"message"`
+}
+
+func (e ConfigError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ConfigErrors are the invalid settings of a hypervisor configuration.
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	var msgs []string
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+
+	return fmt.Sprintf("Invalid hypervisor configuration: %s", strings.Join(msgs, "; "))
+}
+
+func (e *ConfigErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, ConfigError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// Validate checks the configuration can be used by a hypervisor of type
+// hypervisorType before creating a VM with it, unlike valid() it doesn't
+// set the defaults. It returns the ConfigErrors found, nil if there are
+// none.
+func (conf *HypervisorConfig) Validate(hypervisorType HypervisorType) error {
+	var errs ConfigErrors
+
+	// valid() stops at the first error and sets the defaults
+	defaults := *conf
+	if err := defaults.valid(); err != nil {
+		errs.add("", "%v", err)
+	}
+
+	conf.validateAssets(&errs)
+	conf.validateEmulatorThreads(&errs)
+
+	if conf.MemorySize != 0 && conf.MemorySize < MinHypervisorMemory {
+		errs.add("MemorySize", "%d MiB is less than the minimum of %d MiB", conf.MemorySize, MinHypervisorMemory)
+	}
+
+	if conf.DefaultMaxVCPUs != 0 && conf.NumVCPUs > conf.DefaultMaxVCPUs {
+		errs.add("NumVCPUs", "%d vCPUs are more than the maximum of %d", conf.NumVCPUs, conf.DefaultMaxVCPUs)
+	}
+
+	if conf.JailerPath != "" && hypervisorType != FirecrackerHypervisor {
+		errs.add("JailerPath", "the jailer is only supported by %s", FirecrackerHypervisor)
+	}
+
+	if conf.JailerChrootBase != "" && !filepath.IsAbs(conf.JailerChrootBase) {
+		errs.add("JailerChrootBase", "%s is not an absolute path", conf.JailerChrootBase)
+	}
+
+	if conf.CPUSockets != 0 && (hypervisorType == FirecrackerHypervisor || hypervisorType == AcrnHypervisor) {
+		errs.add("CPUSockets", "the guest CPU topology is not supported by %s", hypervisorType)
+	}
+
+	if conf.UsePmemRootfs && hypervisorType == QemuHypervisor {
+		errs.add("UsePmemRootfs", "not supported by %s, the guest image is plugged as a nvdimm device unless DisableImageNvdimm is set", hypervisorType)
+	}
+
+	if conf.SharedFS == config.VirtioFS {
+		if hypervisorType == FirecrackerHypervisor {
+			errs.add("SharedFS", "%s is not supported by %s", config.VirtioFS, hypervisorType)
+		} else if conf.VirtioFSDaemon == "" {
+			errs.add("VirtioFSDaemon", "%s requires a virtiofsd daemon", config.VirtioFS)
+		} else {
+			validateFile(&errs, "VirtioFSDaemon", conf.VirtioFSDaemon)
+		}
+	}
+
+	if hypervisorType != QemuHypervisor {
+		for _, f := range []struct {
+			field   string
+			enabled bool
+			feature string
+		}{
+			{"ConfidentialGuest", conf.ConfidentialGuest, "confidential guests are"},
+			{"VirtioMem", conf.VirtioMem, "virtio-mem is"},
+			{"IncomingMigrationURI", conf.IncomingMigrationURI != "", "migrations are"},
+			{"BootToBeTemplate", conf.BootToBeTemplate, "vm templates are"},
+			{"BootFromTemplate", conf.BootFromTemplate, "vm templates are"},
+			{"VirtioFSMaxRestarts", conf.VirtioFSMaxRestarts != 0, "virtiofsd restarts are"},
+		} {
+			if f.enabled {
+				errs.add(f.field, "%s only supported by %s", f.feature, QemuHypervisor)
+			}
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// validateAssets checks the files the hypervisor needs exist.
+func (conf *HypervisorConfig) validateAssets(errs *ConfigErrors) {
+	for _, a := range []struct {
+		field string
+		path  func() (string, error)
+	}{
+		{"KernelPath", conf.KernelAssetPath},
+		{"ImagePath", conf.ImageAssetPath},
+		{"InitrdPath", conf.InitrdAssetPath},
+		{"FirmwarePath", conf.FirmwareAssetPath},
+		{"HypervisorPath", conf.HypervisorAssetPath},
+		{"HypervisorCtlPath", conf.HypervisorCtlAssetPath},
+		{"JailerPath", conf.JailerAssetPath},
+	} {
+		path, err := a.path()
+		if err != nil {
+			errs.add(a.field, "%v", err)
+			continue
+		}
+
+		if path != "" {
+			validateFile(errs, a.field, path)
+		}
+	}
+}
+
+func validateFile(errs *ConfigErrors, field, path string) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		errs.add(field, "%s does not exist", path)
+	} else if err != nil {
+		errs.add(field, "%v", err)
+	}
+}
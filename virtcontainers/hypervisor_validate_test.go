@@ -0,0 +1,75 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHypervisorConfigValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := newQemuConfig()
+	assert.NoError(conf.Validate(QemuHypervisor))
+	// the defaults are not set
+	conf.NumVCPUs = 0
+	assert.NoError(conf.Validate(QemuHypervisor))
+	assert.Zero(conf.NumVCPUs)
+
+	conf = newQemuConfig()
+	conf.KernelPath = filepath.Join(testDir, "missing-kernel")
+	conf.MemorySize = 64
+	conf.NumVCPUs = conf.DefaultMaxVCPUs + 1
+	conf.JailerPath = testQemuPath
+	conf.SharedFS = config.VirtioFS
+	conf.ConfidentialGuest = true
+	conf.UsePmemRootfs = true
+
+	err := conf.Validate(ClhHypervisor)
+	assert.Error(err)
+	errs, ok := err.(ConfigErrors)
+	assert.True(ok)
+
+	var fields []string
+	for _, e := range errs {
+		fields = append(fields, e.Field)
+	}
+	assert.Equal([]string{"", "KernelPath", "MemorySize", "NumVCPUs", "JailerPath", "VirtioFSDaemon", "ConfidentialGuest"}, fields)
+
+	// virtio-fs clashes with firecracker whatever the daemon
+	conf = newQemuConfig()
+	conf.SharedFS = config.VirtioFS
+	conf.VirtioFSDaemon = testQemuPath
+	assert.NoError(conf.Validate(QemuHypervisor))
+	err = conf.Validate(FirecrackerHypervisor)
+	assert.Equal(ConfigErrors{{Field: "SharedFS", Message: "virtio-fs is not supported by firecracker"}}, err)
+	assert.Contains(err.Error(), "SharedFS: virtio-fs is not supported by firecracker")
+
+	conf = newQemuConfig()
+	conf.CPUSockets = 1
+	assert.NoError(conf.Validate(ClhHypervisor))
+	err = conf.Validate(FirecrackerHypervisor)
+	assert.Equal(ConfigErrors{{Field: "CPUSockets", Message: "the guest CPU topology is not supported by firecracker"}}, err)
+
+	conf = newQemuConfig()
+	conf.SharedFS = config.VirtioFS
+	conf.VirtioFSDaemon = testQemuPath
+	conf.VirtioFSMaxRestarts = 3
+	assert.NoError(conf.Validate(QemuHypervisor))
+	err = conf.Validate(ClhHypervisor)
+	assert.Equal(ConfigErrors{{Field: "VirtioFSMaxRestarts", Message: "virtiofsd restarts are only supported by qemu"}}, err)
+
+	conf = newQemuConfig()
+	conf.UsePmemRootfs = true
+	assert.NoError(conf.Validate(ClhHypervisor))
+	err = conf.Validate(QemuHypervisor)
+	assert.Error(err)
+	assert.Contains(err.Error(), "UsePmemRootfs")
+}
@@ -0,0 +1,327 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/sirupsen/logrus"
+)
+
+// idleCheckInterval is the period the CPU usage of the hypervisor is sampled
+// at to tell whether the sandbox is idle.
+var idleCheckInterval = 5 * time.Second
+
+const (
+	// idleCPUThreshold is the share of a host CPU under which the
+	// hypervisor is considered idle: the guest runs no process and
+	// handles no I/O, only its timers.
+	idleCPUThreshold = 0.01
+
+	// clockTicks is USER_HZ, the unit of the CPU times of /proc, 100 on
+	// all the supported architectures.
+	clockTicks = 100
+)
+
+// IdleStats describes the pauses of the VM of an idle sandbox.
+type IdleStats struct {
+	// Paused is whether the VM is paused because the sandbox is idle.
+	Paused bool
+
+	// Pauses counts the times the VM was paused.
+	Pauses uint64
+
+	// LastPause is when the VM was last paused.
+	LastPause time.Time
+}
+
+// processStat returns the fields of the stat of the host process pid which
+// follow its command name, from the 3rd.
+func processStat(pid int) ([]string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return nil, err
+	}
+
+	// the command name can contain spaces and parentheses
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return nil, fmt.Errorf("Invalid stat of process %d", pid)
+	}
+
+	// starttime, the 22nd field, is the last one read
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("Invalid stat of process %d", pid)
+	}
+
+	return fields, nil
+}
+
+// processStartTime returns the start time of the host process pid, in clock
+// ticks since boot, telling a reused PID apart.
+func processStartTime(pid int) (uint64, error) {
+	fields, err := processStat(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// processCPUTime returns the CPU time, user and system, the host process pid
+// used in clock ticks.
+func processCPUTime(pid int) (uint64, error) {
+	fields, err := processStat(pid)
+	if err != nil {
+		return 0, err
+	}
+
+	// utime and stime are the 14th and 15th fields, the 12th and 13th
+	// after the command name
+	var total uint64
+	for _, f := range fields[11:13] {
+		ticks, err := strconv.ParseUint(f, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid stat of process %d: %v", pid, err)
+		}
+		total += ticks
+	}
+
+	return total, nil
+}
+
+// idleMonitor pauses the VM of a sandbox once its hypervisor used almost no
+// CPU for IdlePauseTimeout seconds, i.e. when the guest runs no process and
+// handles no I/O, releasing the host CPU its timers use. The VM is resumed
+// on the next request needing the guest, e.g. an exec or a write to the
+// stdin of a process. The network traffic reaching a paused VM doesn't
+// resume it.
+type idleMonitor struct {
+	sync.Mutex
+
+	sandbox *Sandbox
+	timeout time.Duration
+	stats   IdleStats
+
+	// lastActive is when the sandbox was last found active, cpuTime the
+	// CPU time of the hypervisor at the last check.
+	lastActive time.Time
+	cpuTime    uint64
+
+	// containerStats are the last stats of the containers, returned while
+	// the VM is paused rather than resuming it.
+	containerStats map[string]ContainerStats
+
+	stopCh chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newIdleMonitor(s *Sandbox, timeout time.Duration) *idleMonitor {
+	return &idleMonitor{
+		sandbox:        s,
+		timeout:        timeout,
+		lastActive:     time.Now(),
+		containerStats: make(map[string]ContainerStats),
+		stopCh:         make(chan struct{}),
+	}
+}
+
+func (m *idleMonitor) start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+
+		tick := time.NewTicker(idleCheckInterval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-m.stopCh:
+				return
+			case <-tick.C:
+				m.check()
+			}
+		}
+	}()
+}
+
+// stop stops monitoring the sandbox, its VM being resumed if it's paused.
+func (m *idleMonitor) stop() {
+	close(m.stopCh)
+	m.wg.Wait()
+
+	if err := m.resume(); err != nil {
+		m.sandbox.Logger().WithError(err).Warn("Could not resume idle sandbox")
+	}
+}
+
+// hypervisorCPUTime returns the CPU time used by the hypervisor.
+func (m *idleMonitor) hypervisorCPUTime() (uint64, error) {
+	pids := m.sandbox.hypervisor.getPids()
+	if len(pids) == 0 || pids[0] <= 0 {
+		return 0, fmt.Errorf("Hypervisor PID unknown")
+	}
+
+	return processCPUTime(pids[0])
+}
+
+// check pauses the VM when the sandbox has been idle for the timeout.
+func (m *idleMonitor) check() {
+	m.Lock()
+	defer m.Unlock()
+
+	// the VM is also paused when the sandbox is paused or migrated
+	if m.stats.Paused || m.sandbox.state.State != types.StateRunning {
+		return
+	}
+
+	cpuTime, err := m.hypervisorCPUTime()
+	if err != nil {
+		m.sandbox.Logger().WithError(err).Debug("Could not get the CPU time of the hypervisor")
+		return
+	}
+
+	now := time.Now()
+	busy := uint64(idleCheckInterval.Seconds() * clockTicks * idleCPUThreshold)
+	if m.cpuTime == 0 || cpuTime-m.cpuTime > busy {
+		m.lastActive = now
+	}
+	m.cpuTime = cpuTime
+
+	idle := now.Sub(m.lastActive)
+	if idle < m.timeout {
+		return
+	}
+
+	if err := m.sandbox.hypervisor.pauseSandbox(); err != nil {
+		m.sandbox.Logger().WithError(err).Warn("Could not pause idle sandbox")
+		return
+	}
+
+	m.stats.Paused = true
+	m.stats.Pauses++
+	m.stats.LastPause = now
+
+	m.sandbox.Logger().WithField("idle", idle).Info("Sandbox idle, VM paused")
+}
+
+// resume resumes the VM when it's paused and records the sandbox activity.
+func (m *idleMonitor) resume() error {
+	m.Lock()
+	defer m.Unlock()
+
+	m.lastActive = time.Now()
+
+	if !m.stats.Paused {
+		return nil
+	}
+
+	if err := m.sandbox.hypervisor.resumeSandbox(); err != nil {
+		return err
+	}
+
+	m.stats.Paused = false
+
+	m.sandbox.Logger().WithFields(logrus.Fields{
+		"paused": time.Since(m.stats.LastPause),
+	}).Info("Sandbox active, VM resumed")
+
+	// the guest clock stopped with the VM
+	if ts := m.sandbox.timeSync; ts != nil {
+		go ts.syncTime()
+	}
+
+	return nil
+}
+
+func (m *idleMonitor) paused() bool {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.stats.Paused
+}
+
+func (m *idleMonitor) getStats() IdleStats {
+	m.Lock()
+	defer m.Unlock()
+
+	return m.stats
+}
+
+// cachedContainerStats returns the last stats of the container while the VM
+// is paused.
+func (m *idleMonitor) cachedContainerStats(containerID string) (ContainerStats, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.stats.Paused {
+		return ContainerStats{}, false
+	}
+
+	stats, ok := m.containerStats[containerID]
+	return stats, ok
+}
+
+func (m *idleMonitor) setContainerStats(containerID string, stats ContainerStats) {
+	m.Lock()
+	defer m.Unlock()
+
+	m.containerStats[containerID] = stats
+}
+
+// startIdleMonitor pauses the VM once the sandbox has been idle for
+// IdlePauseTimeout seconds, until the VM is stopped. Idle sandboxes are
+// only paused while the process managing the sandbox runs, and only when
+// the hypervisor can pause the VM: cloud-hypervisor, ACRN and firecracker
+// before 0.23.0 can't. The VM memory stays allocated, snapshotting the
+// idle firecracker VMs instead isn't implemented.
+func (s *Sandbox) startIdleMonitor() {
+	if s.config.IdlePauseTimeout == 0 || s.idleMonitor != nil {
+		return
+	}
+
+	if caps := s.hypervisor.capabilities(); !caps.IsVMPauseSupported() {
+		s.Logger().Warn("The hypervisor can't pause the VM, idle sandboxes won't be paused")
+		return
+	}
+
+	s.idleMonitor = newIdleMonitor(s, time.Duration(s.config.IdlePauseTimeout)*time.Second)
+	s.idleMonitor.start()
+}
+
+func (s *Sandbox) stopIdleMonitor() {
+	if s.idleMonitor == nil {
+		return
+	}
+
+	s.idleMonitor.stop()
+	s.idleMonitor = nil
+}
+
+// wakeUp resumes the VM of the sandbox when it was paused because the
+// sandbox was idle, before a request to the guest.
+func (s *Sandbox) wakeUp() error {
+	if s.idleMonitor == nil {
+		return nil
+	}
+
+	return s.idleMonitor.resume()
+}
+
+// idlePaused returns whether the VM is paused because the sandbox is idle,
+// the agent not answering then.
+func (s *Sandbox) idlePaused() bool {
+	return s.idleMonitor != nil && s.idleMonitor.paused()
+}
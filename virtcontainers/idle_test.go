@@ -0,0 +1,161 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// pausingMockHypervisor is a mock hypervisor which can pause the VM.
+type pausingMockHypervisor struct {
+	mockHypervisor
+}
+
+func (m *pausingMockHypervisor) capabilities() types.Capabilities {
+	var caps types.Capabilities
+	caps.SetVMPauseSupport()
+	return caps
+}
+
+func writeProcessCPUTime(t *testing.T, pid int, utime, stime uint64) {
+	dir := filepath.Join(procRoot, fmt.Sprint(pid))
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+
+	stat := fmt.Sprintf("%d (qemu (x86)) S 1 %d %d 0 -1 138412416 1000 0 0 0 %d %d 0 0 20 0 3 0 42 0\n", pid, pid, pid, utime, stime)
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "stat"), []byte(stat), 0644))
+}
+
+func TestProcessCPUTime(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "proc")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedProcRoot := procRoot
+	procRoot = dir
+	defer func() {
+		procRoot = savedProcRoot
+	}()
+
+	writeProcessCPUTime(t, 42, 120, 30)
+	ticks, err := processCPUTime(42)
+	assert.NoError(err)
+	assert.Equal(uint64(150), ticks)
+
+	_, err = processCPUTime(43)
+	assert.Error(err)
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "42", "stat"), []byte("42 (qemu) S 1"), 0644))
+	_, err = processCPUTime(42)
+	assert.Error(err)
+}
+
+func TestProcessStartTime(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "proc")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedProcRoot := procRoot
+	procRoot = dir
+	defer func() {
+		procRoot = savedProcRoot
+	}()
+
+	writeProcessCPUTime(t, 42, 120, 30)
+	startTime, err := processStartTime(42)
+	assert.NoError(err)
+	assert.Equal(uint64(42), startTime)
+
+	_, err = processStartTime(43)
+	assert.Error(err)
+}
+
+func TestIdleMonitor(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "proc")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedProcRoot := procRoot
+	procRoot = dir
+	defer func() {
+		procRoot = savedProcRoot
+	}()
+
+	s := &Sandbox{
+		id:         testSandboxID,
+		hypervisor: &mockHypervisor{mockPid: 42},
+		config:     &SandboxConfig{},
+		state:      types.SandboxState{State: types.StateRunning},
+	}
+
+	// disabled by default
+	s.startIdleMonitor()
+	assert.Nil(s.idleMonitor)
+	assert.NoError(s.wakeUp())
+	assert.False(s.idlePaused())
+
+	m := newIdleMonitor(s, time.Minute)
+	s.idleMonitor = m
+
+	// a busy hypervisor keeps the sandbox active
+	writeProcessCPUTime(t, 42, 100, 0)
+	m.check()
+	writeProcessCPUTime(t, 42, 200, 0)
+	m.lastActive = time.Now().Add(-2 * time.Minute)
+	m.check()
+	assert.False(s.idlePaused())
+
+	// an idle one doesn't
+	m.lastActive = time.Now().Add(-2 * time.Minute)
+	m.check()
+	assert.True(s.idlePaused())
+	assert.Equal(uint64(1), m.getStats().Pauses)
+
+	// the stats of the containers are the last ones while paused
+	_, ok := m.cachedContainerStats("foo")
+	assert.False(ok)
+	m.setContainerStats("foo", ContainerStats{NetworkStats: []*NetworkStats{{Name: "eth0"}}})
+	stats, err := s.StatsContainer("foo")
+	assert.NoError(err)
+	assert.Equal("eth0", stats.NetworkStats[0].Name)
+
+	assert.NoError(s.wakeUp())
+	assert.False(s.idlePaused())
+	_, ok = m.cachedContainerStats("foo")
+	assert.False(ok)
+
+	// a paused sandbox is left alone
+	m.lastActive = time.Now().Add(-2 * time.Minute)
+	s.state.State = types.StatePaused
+	m.check()
+	assert.False(s.idlePaused())
+
+	// not started when the hypervisor can't pause the VM
+	s.idleMonitor = nil
+	s.config.IdlePauseTimeout = 60
+	s.startIdleMonitor()
+	assert.Nil(s.idleMonitor)
+
+	s.hypervisor = &pausingMockHypervisor{}
+	s.startIdleMonitor()
+	assert.NotNil(s.idleMonitor)
+	assert.Equal(time.Minute, s.idleMonitor.timeout)
+	s.stopIdleMonitor()
+	assert.Nil(s.idleMonitor)
+}
@@ -11,6 +11,7 @@ package virtcontainers
 
 import (
 	"context"
+	"net"
 	"syscall"
 
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
@@ -117,6 +118,51 @@ func (impl *VCImpl) StatsSandbox(ctx context.Context, sandboxID string) (Sandbox
 	return StatsSandbox(ctx, sandboxID)
 }
 
+// InspectSandbox implements the VC function of the same name.
+func (impl *VCImpl) InspectSandbox(ctx context.Context, sandboxID string) (SandboxInfo, error) {
+	return InspectSandbox(ctx, sandboxID)
+}
+
+// MigrateSandbox implements the VC function of the same name.
+func (impl *VCImpl) MigrateSandbox(ctx context.Context, sandboxID, uri string) ([]byte, error) {
+	return MigrateSandbox(ctx, sandboxID, uri)
+}
+
+// RestoreSandbox implements the VC function of the same name.
+func (impl *VCImpl) RestoreSandbox(ctx context.Context, state []byte, uri string) (VCSandbox, error) {
+	return RestoreSandbox(ctx, state, uri)
+}
+
+// RebootSandbox implements the VC function of the same name.
+func (impl *VCImpl) RebootSandbox(ctx context.Context, sandboxID string) error {
+	return RebootSandbox(ctx, sandboxID)
+}
+
+// CleanupOrphans implements the VC function of the same name.
+func (impl *VCImpl) CleanupOrphans(ctx context.Context, jailerChrootBase string) ([]string, error) {
+	return CleanupOrphans(ctx, jailerChrootBase)
+}
+
+// SandboxLaunchMeasurement implements the VC function of the same name.
+func (impl *VCImpl) SandboxLaunchMeasurement(ctx context.Context, sandboxID string) (string, error) {
+	return SandboxLaunchMeasurement(ctx, sandboxID)
+}
+
+// SandboxBootTimes implements the VC function of the same name.
+func (impl *VCImpl) SandboxBootTimes(ctx context.Context, sandboxID string) (BootTimes, error) {
+	return SandboxBootTimes(ctx, sandboxID)
+}
+
+// SandboxOverhead implements the VC function of the same name.
+func (impl *VCImpl) SandboxOverhead(ctx context.Context, sandboxID string) (Overhead, error) {
+	return SandboxOverhead(ctx, sandboxID)
+}
+
+// SandboxMounts implements the VC function of the same name.
+func (impl *VCImpl) SandboxMounts(ctx context.Context, sandboxID string) (SandboxMountInfo, error) {
+	return SandboxMounts(ctx, sandboxID)
+}
+
 // KillContainer implements the VC function of the same name.
 func (impl *VCImpl) KillContainer(ctx context.Context, sandboxID, containerID string, signal syscall.Signal, all bool) error {
 	return KillContainer(ctx, sandboxID, containerID, signal, all)
@@ -147,6 +193,21 @@ func (impl *VCImpl) AddDevice(ctx context.Context, sandboxID string, info config
 	return AddDevice(ctx, sandboxID, info)
 }
 
+// ForwardSandboxPort implements the VC function of the same name.
+func (impl *VCImpl) ForwardSandboxPort(ctx context.Context, sandboxID string, port uint32, listener net.Listener) error {
+	return ForwardSandboxPort(ctx, sandboxID, port, listener)
+}
+
+// OpenSandboxDebugConsole implements the VC function of the same name.
+func (impl *VCImpl) OpenSandboxDebugConsole(ctx context.Context, sandboxID string) (net.Conn, error) {
+	return OpenSandboxDebugConsole(ctx, sandboxID)
+}
+
+// PrewarmContainerImage implements the VC function of the same name.
+func (impl *VCImpl) PrewarmContainerImage(ctx context.Context, sandboxID, containerID string, rootFs RootFs) error {
+	return PrewarmContainerImage(ctx, sandboxID, containerID, rootFs)
+}
+
 // AddInterface implements the VC function of the same name.
 func (impl *VCImpl) AddInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error) {
 	return AddInterface(ctx, sandboxID, inf)
@@ -178,3 +239,8 @@ func (impl *VCImpl) ListRoutes(ctx context.Context, sandboxID string) ([]*vcType
 func (impl *VCImpl) CleanupContainer(ctx context.Context, sandboxID, containerID string, force bool) error {
 	return CleanupContainer(ctx, sandboxID, containerID, force)
 }
+
+// GetHypervisorCapabilities implements the VC function of the same name.
+func (impl *VCImpl) GetHypervisorCapabilities(ctx context.Context, hType HypervisorType, conf HypervisorConfig) (HypervisorCapabilities, error) {
+	return GetHypervisorCapabilities(ctx, hType, conf)
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"github.com/kata-containers/runtime/virtcontainers/types"
+)
+
+// VMInfo is the state of a sandbox VM as reported by its VMM.
+type VMInfo struct {
+	State    string `json:"state"`
+	VCPUs    uint32 `json:"vcpus"`
+	MemoryMB uint32 `json:"memory_mb"`
+
+	// Details holds the VMM specific information, such as its
+	// version, API socket or jail paths.
+	Details map[string]string `json:"details,omitempty"`
+}
+
+// SandboxDeviceInfo describes a device attached to a sandbox.
+type SandboxDeviceInfo struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	AttachCount uint   `json:"attach_count"`
+}
+
+// SandboxEndpointInfo describes a network endpoint of a sandbox.
+type SandboxEndpointInfo struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	HardwareAddr string `json:"hardware_addr"`
+	TAP          string `json:"tap,omitempty"`
+}
+
+// SandboxInfo describes a sandbox and its VM, it's meant for debugging.
+type SandboxInfo struct {
+	ID             string                `json:"id"`
+	State          string                `json:"state"`
+	HypervisorType HypervisorType        `json:"hypervisor_type"`
+	HypervisorPid  int                   `json:"hypervisor_pid"`
+	SharedFS       string                `json:"shared_fs"`
+	AgentURL       string                `json:"agent_url"`
+	NetNsPath      string                `json:"netns_path,omitempty"`
+	Devices        []SandboxDeviceInfo   `json:"devices"`
+	Endpoints      []SandboxEndpointInfo `json:"endpoints"`
+
+	// VM is the live state of the VM, VMError the reason why the VMM
+	// could not be queried when it's nil.
+	VM      *VMInfo `json:"vm,omitempty"`
+	VMError string  `json:"vm_error,omitempty"`
+}
+
+// Inspect returns the description of the sandbox, querying its VMM. A VMM
+// which doesn't answer is reported in the description, not as an error,
+// since this is the case this is most useful for.
+func (s *Sandbox) Inspect() (SandboxInfo, error) {
+	info := SandboxInfo{
+		ID:             s.id,
+		State:          string(s.state.State),
+		HypervisorType: s.config.HypervisorType,
+		HypervisorPid:  s.hypervisor.save().Pid,
+		SharedFS:       s.config.HypervisorConfig.SharedFS,
+		NetNsPath:      s.networkNS.NetNsPath,
+		Devices:        []SandboxDeviceInfo{},
+		Endpoints:      []SandboxEndpointInfo{},
+	}
+
+	url, err := s.agent.getAgentURL()
+	if err != nil {
+		return SandboxInfo{}, err
+	}
+	info.AgentURL = url
+
+	if s.devManager != nil {
+		for _, d := range s.devManager.GetAllDevices() {
+			info.Devices = append(info.Devices, SandboxDeviceInfo{
+				ID:          d.DeviceID(),
+				Type:        string(d.DeviceType()),
+				AttachCount: d.GetAttachCount(),
+			})
+		}
+	}
+
+	for _, e := range s.networkNS.Endpoints {
+		endpoint := SandboxEndpointInfo{
+			Name:         e.Name(),
+			Type:         string(e.Type()),
+			HardwareAddr: e.HardwareAddr(),
+		}
+		if netPair := e.NetworkPair(); netPair != nil {
+			endpoint.TAP = netPair.TAPIface.Name
+		}
+		info.Endpoints = append(info.Endpoints, endpoint)
+	}
+
+	switch s.state.State {
+	case types.StateReady, types.StateRunning, types.StatePaused:
+	default:
+		info.VMError = "VM is not running"
+		return info, nil
+	}
+
+	vm, err := s.hypervisor.describe()
+	if err != nil {
+		s.Logger().WithError(err).Warn("Could not describe the VM")
+		info.VMError = err.Error()
+		return info, nil
+	}
+	info.VM = &vm
+
+	return info, nil
+}
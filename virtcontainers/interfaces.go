@@ -8,6 +8,7 @@ package virtcontainers
 import (
 	"context"
 	"io"
+	"net"
 	"syscall"
 
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
@@ -30,6 +31,15 @@ type VC interface {
 	RunSandbox(ctx context.Context, sandboxConfig SandboxConfig) (VCSandbox, error)
 	StartSandbox(ctx context.Context, sandboxID string) (VCSandbox, error)
 	StatusSandbox(ctx context.Context, sandboxID string) (SandboxStatus, error)
+	InspectSandbox(ctx context.Context, sandboxID string) (SandboxInfo, error)
+	MigrateSandbox(ctx context.Context, sandboxID, uri string) ([]byte, error)
+	RestoreSandbox(ctx context.Context, state []byte, uri string) (VCSandbox, error)
+	RebootSandbox(ctx context.Context, sandboxID string) error
+	SandboxLaunchMeasurement(ctx context.Context, sandboxID string) (string, error)
+	SandboxBootTimes(ctx context.Context, sandboxID string) (BootTimes, error)
+	SandboxOverhead(ctx context.Context, sandboxID string) (Overhead, error)
+	SandboxMounts(ctx context.Context, sandboxID string) (SandboxMountInfo, error)
+	CleanupOrphans(ctx context.Context, jailerChrootBase string) ([]string, error)
 	StopSandbox(ctx context.Context, sandboxID string, force bool) (VCSandbox, error)
 
 	CreateContainer(ctx context.Context, sandboxID string, containerConfig ContainerConfig) (VCSandbox, VCContainer, error)
@@ -47,6 +57,9 @@ type VC interface {
 	ResumeContainer(ctx context.Context, sandboxID, containerID string) error
 
 	AddDevice(ctx context.Context, sandboxID string, info config.DeviceInfo) (api.Device, error)
+	PrewarmContainerImage(ctx context.Context, sandboxID, containerID string, rootFs RootFs) error
+	ForwardSandboxPort(ctx context.Context, sandboxID string, port uint32, listener net.Listener) error
+	OpenSandboxDebugConsole(ctx context.Context, sandboxID string) (net.Conn, error)
 
 	AddInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
 	RemoveInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
@@ -55,6 +68,8 @@ type VC interface {
 	ListRoutes(ctx context.Context, sandboxID string) ([]*vcTypes.Route, error)
 
 	CleanupContainer(ctx context.Context, sandboxID, containerID string, force bool) error
+
+	GetHypervisorCapabilities(ctx context.Context, hType HypervisorType, conf HypervisorConfig) (HypervisorCapabilities, error)
 }
 
 // VCSandbox is the Sandbox interface
@@ -92,6 +107,8 @@ type VCSandbox interface {
 	IOStream(containerID, processID string) (io.WriteCloser, io.Reader, io.Reader, error)
 
 	AddDevice(info config.DeviceInfo) (api.Device, error)
+	PrewarmContainerImage(containerID string, rootFs RootFs) error
+	CreateVSockTunnel(port uint32) (string, error)
 
 	AddInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error)
 	RemoveInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error)
@@ -58,6 +58,10 @@ func (s *stdinStream) Write(data []byte) (n int, err error) {
 		return 0, errors.New("stream closed")
 	}
 
+	if err := s.sandbox.wakeUp(); err != nil {
+		return 0, err
+	}
+
 	return s.sandbox.agent.writeProcessStdin(s.container, s.process, data)
 }
 
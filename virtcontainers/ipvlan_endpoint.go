@@ -88,7 +88,7 @@ func (endpoint *IPVlanEndpoint) NetworkPair() *NetworkInterfacePair {
 // Attach for virtual endpoint bridges the network pair and adds the
 // tap interface of the network pair to the hypervisor.
 func (endpoint *IPVlanEndpoint) Attach(h hypervisor) error {
-	if err := xConnectVMNetwork(endpoint, h); err != nil {
+	if err := xConnectVMNetwork(endpoint, h, netQueues(h)); err != nil {
 		networkLogger().WithError(err).Error("Error bridging virtual ep")
 		return err
 	}
@@ -31,6 +31,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/uuid"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/volume"
 	"github.com/kata-containers/runtime/virtcontainers/store"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/opencontainers/runtime-spec/specs-go"
@@ -59,6 +60,8 @@ const (
 var (
 	checkRequestTimeout         = 30 * time.Second
 	defaultRequestTimeout       = 60 * time.Second
+	defaultRetryDelay           = 100 * time.Millisecond
+	maxRetryDelay               = 5 * time.Second
 	errorMissingProxy           = errors.New("Missing proxy pointer")
 	errorMissingOCISpec         = errors.New("Missing OCI specification")
 	defaultKataHostSharedDir    = "/run/kata-containers/shared/sandboxes/"
@@ -73,6 +76,7 @@ var (
 	kataBlkDevType              = "blk"
 	kataBlkCCWDevType           = "blk-ccw"
 	kataSCSIDevType             = "scsi"
+	kataBlkSerialDevType        = "blk-serial"
 	kataNvdimmDevType           = "nvdimm"
 	kataVirtioFSDevType         = "virtio-fs"
 	sharedDir9pOptions          = []string{"trans=virtio,version=9p2000.L,cache=mmap", "nodev"}
@@ -97,6 +101,14 @@ const (
 	defaultAgentTraceType = agentTraceTypeIsolated
 )
 
+const (
+	// agentDebugConsoleParam makes the agent start a shell of the guest
+	// on its debug console, served on the vsock port set with
+	// agentDebugConsoleVPortParam instead of the guest console.
+	agentDebugConsoleParam      = "agent.debug_console"
+	agentDebugConsoleVPortParam = "agent.debug_console_vport"
+)
+
 const (
 	grpcCheckRequest             = "grpc.CheckRequest"
 	grpcExecProcessRequest       = "grpc.ExecProcessRequest"
@@ -174,6 +186,27 @@ type KataAgentConfig struct {
 	TraceMode         string
 	TraceType         string
 	KernelModules     []string
+
+	// DialTimeout is the time in seconds to wait for the agent to accept
+	// a connection, 0 meaning the default.
+	DialTimeout uint32
+
+	// RequestTimeout is the time in seconds to wait for the agent to
+	// answer a request, 0 meaning the default. The process waits have no
+	// timeout.
+	RequestTimeout uint32
+
+	// RequestRetries is the number of times the connections and requests
+	// failing because the agent is unavailable are retried.
+	RequestRetries uint32
+
+	// RetryDelay is the time in milliseconds before the first retry, the
+	// delay doubling on each retry, 0 meaning the default.
+	RetryDelay uint32
+
+	// DebugConsoleEnabled makes the agent serve a shell of the guest on
+	// its debug console vsock port.
+	DebugConsoleEnabled bool
 }
 
 // KataAgentState is the structure describing the data stored from this
@@ -187,10 +220,15 @@ type kataAgent struct {
 	shim  shim
 	proxy proxy
 
-	// lock protects the client pointer
+	// lock protects the client pointer and its users
 	sync.Mutex
 	client *kataclient.AgentClient
 
+	// connUsers counts the requests using the client, which is closed
+	// once they're done when the connection isn't kept.
+	connUsers int
+	health    agentHealth
+
 	reqHandlers    map[string]reqFunc
 	state          KataAgentState
 	keepConn       bool
@@ -199,6 +237,11 @@ type kataAgent struct {
 	dead           bool
 	kmodules       []string
 
+	dialTimeout    time.Duration
+	requestTimeout time.Duration
+	requestRetries uint32
+	retryDelay     time.Duration
+
 	vmSocket interface{}
 	ctx      context.Context
 }
@@ -209,7 +252,7 @@ func (k *kataAgent) trace(name string) (opentracing.Span, context.Context) {
 		k.ctx = context.Background()
 	}
 
-	span, ctx := opentracing.StartSpanFromContext(k.ctx, name)
+	span, ctx := startSpanFromContext(k.ctx, "agent", name)
 
 	span.SetTag("subsystem", "agent")
 	span.SetTag("type", "kata")
@@ -275,6 +318,11 @@ func KataAgentKernelParams(config KataAgentConfig) []Param {
 		params = append(params, Param{Key: vcAnnotations.ContainerPipeSizeKernelParam, Value: containerPipeSize})
 	}
 
+	if config.DebugConsoleEnabled {
+		params = append(params, Param{Key: agentDebugConsoleParam, Value: ""})
+		params = append(params, Param{Key: agentDebugConsoleVPortParam, Value: strconv.Itoa(vSockDebugConsolePort)})
+	}
+
 	return params
 }
 
@@ -307,6 +355,10 @@ func (k *kataAgent) init(ctx context.Context, sandbox *Sandbox, config interface
 		disableVMShutdown = k.handleTraceSettings(c)
 		k.keepConn = c.LongLiveConn
 		k.kmodules = c.KernelModules
+		k.dialTimeout = time.Duration(c.DialTimeout) * time.Second
+		k.requestTimeout = time.Duration(c.RequestTimeout) * time.Second
+		k.requestRetries = c.RequestRetries
+		k.retryDelay = time.Duration(c.RetryDelay) * time.Millisecond
 	default:
 		return false, vcTypes.ErrInvalidConfigType
 	}
@@ -402,8 +454,7 @@ func (k *kataAgent) configure(h hypervisor, id, sharePath string, builtin bool,
 
 	// Neither create shared directory nor add 9p device if hypervisor
 	// doesn't support filesystem sharing.
-	caps := h.capabilities()
-	if !caps.IsFsSharingSupported() {
+	if !fsShareSupported(h) {
 		return nil
 	}
 
@@ -744,30 +795,6 @@ func (k *kataAgent) setProxyFromGrpc(proxy proxy, pid int, url string) {
 	k.state.URL = url
 }
 
-func (k *kataAgent) getDNS(sandbox *Sandbox) ([]string, error) {
-	ociSpec := sandbox.GetPatchedOCISpec()
-	if ociSpec == nil {
-		k.Logger().Debug("Sandbox OCI spec not found. Sandbox DNS will not be set.")
-		return nil, nil
-	}
-
-	ociMounts := ociSpec.Mounts
-
-	for _, m := range ociMounts {
-		if m.Destination == GuestDNSFile {
-			content, err := ioutil.ReadFile(m.Source)
-			if err != nil {
-				return nil, fmt.Errorf("Could not read file %s: %s", m.Source, err)
-			}
-			dns := strings.Split(string(content), "\n")
-			return dns, nil
-
-		}
-	}
-	k.Logger().Debug("DNS file not present in ociMounts. Sandbox DNS will not be set.")
-	return nil, nil
-}
-
 func (k *kataAgent) startSandbox(sandbox *Sandbox) error {
 	span, _ := k.trace("startSandbox")
 	defer span.Finish()
@@ -787,7 +814,7 @@ func (k *kataAgent) startSandbox(sandbox *Sandbox) error {
 		hostname = hostname[:maxHostnameLen]
 	}
 
-	dns, err := k.getDNS(sandbox)
+	dns, err := sandbox.sandboxDNS()
 	if err != nil {
 		return err
 	}
@@ -796,6 +823,7 @@ func (k *kataAgent) startSandbox(sandbox *Sandbox) error {
 	if err = k.check(); err != nil {
 		return err
 	}
+	sandbox.recordBootEvent(bootEventGuestReady, time.Now())
 
 	//
 	// Setup network interfaces and routes
@@ -811,7 +839,10 @@ func (k *kataAgent) startSandbox(sandbox *Sandbox) error {
 		return err
 	}
 
-	storages := setupStorages(sandbox)
+	storages, err := k.setupStorages(sandbox)
+	if err != nil {
+		return err
+	}
 
 	kmodules := setupKernelModules(k.kmodules)
 
@@ -827,6 +858,15 @@ func (k *kataAgent) startSandbox(sandbox *Sandbox) error {
 
 	_, err = k.sendReq(req)
 	if err != nil {
+		// the agent loads the kernel modules before anything else,
+		// give a hint about which ones the sandbox depends on
+		if len(kmodules) > 0 {
+			var names []string
+			for _, m := range kmodules {
+				names = append(names, m.Name)
+			}
+			return fmt.Errorf("Could not create the sandbox in the guest, which requires the kernel modules %s: %v", strings.Join(names, ", "), err)
+		}
 		return err
 	}
 
@@ -840,6 +880,42 @@ func (k *kataAgent) startSandbox(sandbox *Sandbox) error {
 	return nil
 }
 
+// restartSandbox starts the sandbox again in a rebooted guest. The answers
+// of the previous agent don't tell anything about the new one, which is
+// probed from scratch.
+func (k *kataAgent) restartSandbox(sandbox *Sandbox) error {
+	span, _ := k.trace("restartSandbox")
+	defer span.Finish()
+
+	if err := k.disconnect(); err != nil {
+		k.Logger().WithError(err).Warn("Could not close the connection to the agent of the rebooted guest")
+	}
+	k.health.reset()
+
+	return k.startSandbox(sandbox)
+}
+
+// resumeMigratedSandbox waits for the agent of a guest migrated from another
+// host. The proxy and URL of the agent on the source host are meaningless
+// here, the agent is reached through the VM started on this host.
+func (k *kataAgent) resumeMigratedSandbox(sandbox *Sandbox) error {
+	span, _ := k.trace("resumeMigratedSandbox")
+	defer span.Finish()
+
+	if err := k.disconnect(); err != nil {
+		k.Logger().WithError(err).Warn("Could not close the connection to the agent of the migrated guest")
+	}
+	k.health.reset()
+	k.state.ProxyPid = 0
+	k.state.URL = ""
+
+	if err := k.startProxy(sandbox); err != nil {
+		return err
+	}
+
+	return k.check()
+}
+
 func setupKernelModules(kmodules []string) []*grpc.KernelModule {
 	modules := []*grpc.KernelModule{}
 
@@ -861,12 +937,11 @@ func setupKernelModules(kmodules []string) []*grpc.KernelModule {
 	return modules
 }
 
-func setupStorages(sandbox *Sandbox) []*grpc.Storage {
+func (k *kataAgent) setupStorages(sandbox *Sandbox) ([]*grpc.Storage, error) {
 	storages := []*grpc.Storage{}
-	caps := sandbox.hypervisor.capabilities()
 
 	// append 9p shared volume to storages only if filesystem sharing is supported
-	if caps.IsFsSharingSupported() {
+	if fsShareSupported(sandbox.hypervisor) {
 		// We mount the shared directory in a predefined location
 		// in the guest.
 		// This is where at least some of the host config files
@@ -920,7 +995,25 @@ func setupStorages(sandbox *Sandbox) []*grpc.Storage {
 		storages = append(storages, shmStorage)
 	}
 
-	return storages
+	if id := sandbox.state.ScratchDeviceID; id != "" {
+		device := sandbox.devManager.GetDeviceByID(id)
+		if device == nil {
+			return nil, fmt.Errorf("Failed to find scratch disk device by id (id=%s)", id)
+		}
+
+		scratchStorage, err := k.handleDeviceBlockVolume(sandbox, device)
+		if err != nil {
+			return nil, err
+		}
+
+		scratchStorage.MountPoint = kataGuestScratchDir()
+		scratchStorage.Fstype = scratchDiskFstype
+		scratchStorage.Options = []string{"nodev", "nosuid"}
+
+		storages = append(storages, scratchStorage)
+	}
+
+	return storages, nil
 }
 
 func (k *kataAgent) stopSandbox(sandbox *Sandbox) error {
@@ -1013,7 +1106,35 @@ func (k *kataAgent) replaceOCIMountsForStorages(spec *specs.Spec, volumeStorages
 	return nil
 }
 
-func (k *kataAgent) constraintGRPCSpec(grpcSpec *grpc.Spec, passSeccomp bool) {
+// guestLSMEnabled tells whether the guest kernel parameters enable lsm, the
+// guest kernel also enables the LSMs of its build configuration but they
+// can't be known from the host.
+func guestLSMEnabled(params []Param, lsm string) bool {
+	enabled := false
+	for _, p := range params {
+		switch p.Key {
+		case "security":
+			enabled = p.Value == lsm
+		case "lsm":
+			enabled = false
+			for _, l := range strings.Split(p.Value, ",") {
+				if l == lsm {
+					enabled = true
+				}
+			}
+		case lsm:
+			// e.g. "selinux=0" disables SELinux whatever the
+			// LSMs selected
+			if p.Value == "0" {
+				return false
+			}
+		}
+	}
+
+	return enabled
+}
+
+func (k *kataAgent) constraintGRPCSpec(grpcSpec *grpc.Spec, passSeccomp, passSELinux, passAppArmor bool) {
 	// Disable Hooks since they have been handled on the host and there is
 	// no reason to send them to the agent. It would make no sense to try
 	// to apply them on the guest.
@@ -1025,10 +1146,19 @@ func (k *kataAgent) constraintGRPCSpec(grpcSpec *grpc.Spec, passSeccomp bool) {
 		grpcSpec.Linux.Seccomp = nil
 	}
 
-	// Disable selinux
-	if grpcSpec.Process.SelinuxLabel != "" {
-		k.Logger().Warn("Selinux label specified in config, but not supported in Kata yet, running container without selinux")
+	// Pass the SELinux labels and the AppArmor profile only if they are
+	// not disabled in configuration.toml and the guest kernel enables
+	// these LSMs, they fail the container creation otherwise.
+	if !passSELinux && (grpcSpec.Process.SelinuxLabel != "" || grpcSpec.Linux.MountLabel != "") {
+		k.Logger().Warn("Selinux label specified in config, but not supported by the guest, running container without selinux")
 		grpcSpec.Process.SelinuxLabel = ""
+		grpcSpec.Linux.MountLabel = ""
+	}
+
+	if !passAppArmor && grpcSpec.Process.ApparmorProfile != "" {
+		k.Logger().WithField("profile", grpcSpec.Process.ApparmorProfile).
+			Debug("AppArmor profile specified in config, but not supported by the guest, running container without apparmor")
+		grpcSpec.Process.ApparmorProfile = ""
 	}
 
 	// By now only CPU constraints are supported
@@ -1084,6 +1214,35 @@ func (k *kataAgent) constraintGRPCSpec(grpcSpec *grpc.Spec, passSeccomp bool) {
 	grpcSpec.Linux.Devices = linuxDevices
 }
 
+func isNetSysctlAllowed(key string, allowList []string) bool {
+	for _, pattern := range allowList {
+		if matched, err := filepath.Match(pattern, key); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// handleNetSysctls drops the network sysctls of the container that are not
+// allowed by the sandbox configuration, all of them being kept when no
+// allow-list is configured. They have no effect on the host, which the VM
+// is isolated from, and the agent applies them in the network namespace of
+// the guest the containers share.
+func (k *kataAgent) handleNetSysctls(grpcSpec *grpc.Spec, sandbox *Sandbox) {
+	allowList := sandbox.config.NetSysctlAllowList
+	if len(allowList) == 0 {
+		return
+	}
+
+	for key := range grpcSpec.Linux.Sysctl {
+		if strings.HasPrefix(key, "net.") && !isNetSysctlAllowed(key, allowList) {
+			k.Logger().WithField("sysctl", key).Warn("Network sysctl not allowed, not applied in the guest")
+			delete(grpcSpec.Linux.Sysctl, key)
+		}
+	}
+}
+
 func (k *kataAgent) handleShm(grpcSpec *grpc.Spec, sandbox *Sandbox) {
 	for idx, mnt := range grpcSpec.Mounts {
 		if mnt.Destination != "/dev/shm" {
@@ -1125,6 +1284,12 @@ func (k *kataAgent) appendBlockDevice(dev ContainerDevice, c *Container) *grpc.D
 		ContainerPath: dev.ContainerPath,
 	}
 
+	if useBlockSerial(&c.sandbox.config.HypervisorConfig, d) {
+		kataDevice.Type = kataBlkSerialDevType
+		kataDevice.Id = d.Serial
+		return kataDevice
+	}
+
 	switch c.sandbox.config.HypervisorConfig.BlockDeviceDriver {
 	case config.VirtioMmio:
 		kataDevice.Type = kataMmioBlkDevType
@@ -1230,6 +1395,9 @@ func (k *kataAgent) buildContainerRootfs(sandbox *Sandbox, c *Container, rootPat
 			return nil, fmt.Errorf("malformed block drive")
 		}
 		switch {
+		case useBlockSerial(&sandbox.config.HypervisorConfig, blockDrive):
+			rootfs.Driver = kataBlkSerialDevType
+			rootfs.Source = blockDrive.Serial
 		case sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioMmio:
 			rootfs.Driver = kataMmioBlkDevType
 			rootfs.Source = blockDrive.VirtPath
@@ -1279,7 +1447,7 @@ func (k *kataAgent) buildContainerRootfs(sandbox *Sandbox, c *Container, rootPat
 
 func (k *kataAgent) hasAgentDebugConsole(sandbox *Sandbox) bool {
 	for _, p := range sandbox.config.HypervisorConfig.KernelParams {
-		if p.Key == "agent.debug_console" {
+		if p.Key == agentDebugConsoleParam {
 			k.Logger().Info("agent has debug console")
 			return true
 		}
@@ -1331,7 +1499,7 @@ func (k *kataAgent) createContainer(sandbox *Sandbox, c *Container) (p *Process,
 	epheStorages := k.handleEphemeralStorage(ociSpec.Mounts)
 	ctrStorages = append(ctrStorages, epheStorages...)
 
-	localStorages := k.handleLocalStorage(ociSpec.Mounts, sandbox.id, c.rootfsSuffix)
+	localStorages := k.handleLocalStorage(ociSpec.Mounts, localStoragePath(sandbox, c.rootfsSuffix))
 	ctrStorages = append(ctrStorages, localStorages...)
 
 	// We replace all OCI mount sources that match our container mount
@@ -1374,12 +1542,18 @@ func (k *kataAgent) createContainer(sandbox *Sandbox, c *Container) (p *Process,
 
 	passSeccomp := !sandbox.config.DisableGuestSeccomp && sandbox.seccompSupported
 
+	kernelParams := sandbox.config.HypervisorConfig.KernelParams
+	passSELinux := !sandbox.config.DisableGuestSELinux && guestLSMEnabled(kernelParams, "selinux")
+	passAppArmor := !sandbox.config.DisableGuestAppArmor && guestLSMEnabled(kernelParams, "apparmor")
+
 	// We need to constraint the spec to make sure we're not passing
 	// irrelevant information to the agent.
-	k.constraintGRPCSpec(grpcSpec, passSeccomp)
+	k.constraintGRPCSpec(grpcSpec, passSeccomp, passSELinux, passAppArmor)
 
 	k.handleShm(grpcSpec, sandbox)
 
+	k.handleNetSysctls(grpcSpec, sandbox)
+
 	req := &grpc.CreateContainerRequest{
 		ContainerId:  c.id,
 		ExecId:       c.id,
@@ -1444,18 +1618,30 @@ func (k *kataAgent) handleEphemeralStorage(mounts []specs.Mount) []*grpc.Storage
 	return epheStorages
 }
 
+// localStoragePath returns the directory of the VM holding the local
+// storages of the sandbox.
+func localStoragePath(sandbox *Sandbox, rootfsSuffix string) string {
+	// The local storages are kept on the encrypted scratch disk of the
+	// sandbox when it has one.
+	if sandbox.state.ScratchDeviceID != "" {
+		return filepath.Join(kataGuestScratchDir(), KataLocalDevType)
+	}
+
+	// Otherwise they are located in the sandbox directory.
+	// We rely on the fact that the first container in the VM has the same ID as the sandbox ID.
+	// In Kubernetes, this is usually the pause container and we depend on it existing for
+	// local directories to work.
+	return filepath.Join(kataGuestSharedDir(), sandbox.id, rootfsSuffix, KataLocalDevType)
+}
+
 // handleLocalStorage handles local storage within the VM
 // by creating a directory in the VM from the source of the mount point.
-func (k *kataAgent) handleLocalStorage(mounts []specs.Mount, sandboxID string, rootfsSuffix string) []*grpc.Storage {
+func (k *kataAgent) handleLocalStorage(mounts []specs.Mount, localPath string) []*grpc.Storage {
 	var localStorages []*grpc.Storage
 	for idx, mnt := range mounts {
 		if mnt.Type == KataLocalDevType {
 			// Set the mount source path to a the desired directory point in the VM.
-			// In this case it is located in the sandbox directory.
-			// We rely on the fact that the first container in the VM has the same ID as the sandbox ID.
-			// In Kubernetes, this is usually the pause container and we depend on it existing for
-			// local directories to work.
-			mounts[idx].Source = filepath.Join(kataGuestSharedDir(), sandboxID, rootfsSuffix, KataLocalDevType, filepath.Base(mnt.Source))
+			mounts[idx].Source = filepath.Join(localPath, filepath.Base(mnt.Source))
 
 			// Create a storage struct so that the kata agent is able to create the
 			// directory inside the VM.
@@ -1472,9 +1658,15 @@ func (k *kataAgent) handleLocalStorage(mounts []specs.Mount, sandboxID string, r
 	return localStorages
 }
 
+// useBlockSerial returns true if the agent finds the drive from its serial
+// number rather than from its address or name.
+func useBlockSerial(hconf *HypervisorConfig, drive *config.BlockDrive) bool {
+	return hconf.BlockDeviceNaming == config.BlockNamingSerial && drive.Serial != ""
+}
+
 // handleDeviceBlockVolume handles volume that is block device file
 // and DeviceBlock type.
-func (k *kataAgent) handleDeviceBlockVolume(c *Container, device api.Device) (*grpc.Storage, error) {
+func (k *kataAgent) handleDeviceBlockVolume(sandbox *Sandbox, device api.Device) (*grpc.Storage, error) {
 	vol := &grpc.Storage{}
 
 	blockDrive, ok := device.GetDeviceInfo().(*config.BlockDrive)
@@ -1489,20 +1681,23 @@ func (k *kataAgent) handleDeviceBlockVolume(c *Container, device api.Device) (*g
 		vol.Source = fmt.Sprintf("/dev/pmem%s", blockDrive.NvdimmID)
 		vol.Fstype = blockDrive.Format
 		vol.Options = []string{"dax"}
-	case c.sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioBlockCCW:
+	case useBlockSerial(&sandbox.config.HypervisorConfig, blockDrive):
+		vol.Driver = kataBlkSerialDevType
+		vol.Source = blockDrive.Serial
+	case sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioBlockCCW:
 		vol.Driver = kataBlkCCWDevType
 		vol.Source = blockDrive.DevNo
-	case c.sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioBlock:
+	case sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioBlock:
 		vol.Driver = kataBlkDevType
 		vol.Source = blockDrive.PCIAddr
-	case c.sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioMmio:
+	case sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioMmio:
 		vol.Driver = kataMmioBlkDevType
 		vol.Source = blockDrive.VirtPath
-	case c.sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioSCSI:
+	case sandbox.config.HypervisorConfig.BlockDeviceDriver == config.VirtioSCSI:
 		vol.Driver = kataSCSIDevType
 		vol.Source = blockDrive.SCSIAddr
 	default:
-		return nil, fmt.Errorf("Unknown block device driver: %s", c.sandbox.config.HypervisorConfig.BlockDeviceDriver)
+		return nil, fmt.Errorf("Unknown block device driver: %s", sandbox.config.HypervisorConfig.BlockDeviceDriver)
 	}
 
 	return vol, nil
@@ -1553,7 +1748,7 @@ func (k *kataAgent) handleBlockVolumes(c *Container) ([]*grpc.Storage, error) {
 		var err error
 		switch device.DeviceType() {
 		case config.DeviceBlock:
-			vol, err = k.handleDeviceBlockVolume(c, device)
+			vol, err = k.handleDeviceBlockVolume(c.sandbox, device)
 		case config.VhostUserBlk:
 			vol, err = k.handleVhostUserBlkVolume(c, device)
 		default:
@@ -1566,11 +1761,23 @@ func (k *kataAgent) handleBlockVolumes(c *Container) ([]*grpc.Storage, error) {
 		}
 
 		vol.MountPoint = m.Destination
-		if vol.Fstype == "" {
-			vol.Fstype = "bind"
-		}
-		if len(vol.Options) == 0 {
-			vol.Options = []string{"bind"}
+		if mountInfo, err := volume.VolumeMountInfo(m.Source); err == nil {
+			// direct assigned volumes hold a filesystem to mount
+			// rather than a device to bind mount.
+			vol.Fstype = mountInfo.FsType
+			vol.Options = mountInfo.Options
+		} else if m.BlockFsType != "" {
+			// so do the block volumes, shared read-only with the
+			// host
+			vol.Fstype = m.BlockFsType
+			vol.Options = blockVolumeOptions(m.BlockFsType)
+		} else {
+			if vol.Fstype == "" {
+				vol.Fstype = "bind"
+			}
+			if len(vol.Options) == 0 {
+				vol.Options = []string{"bind"}
+			}
 		}
 
 		volumeStorages = append(volumeStorages, vol)
@@ -1773,28 +1980,42 @@ func (k *kataAgent) statsContainer(sandbox *Sandbox, c Container) (*ContainerSta
 	containerStats := &ContainerStats{
 		CgroupStats: &cgroupStats,
 	}
+
+	for _, n := range stats.NetworkStats {
+		containerStats.NetworkStats = append(containerStats.NetworkStats, &NetworkStats{
+			Name:      n.Name,
+			RxBytes:   n.RxBytes,
+			RxPackets: n.RxPackets,
+			RxErrors:  n.RxErrors,
+			RxDropped: n.RxDropped,
+			TxBytes:   n.TxBytes,
+			TxPackets: n.TxPackets,
+			TxErrors:  n.TxErrors,
+			TxDropped: n.TxDropped,
+		})
+	}
+
 	return containerStats, nil
 }
 
+// connect connects to the agent unless a client is already connected, the
+// concurrent requests share the same client. Every successful connect()
+// must be followed by a release() once the client is not used anymore.
 func (k *kataAgent) connect() error {
 	if k.dead {
 		return errors.New("Dead agent")
 	}
-	// lockless quick pass
-	if k.client != nil {
-		return nil
-	}
 
-	span, _ := k.trace("connect")
-	defer span.Finish()
-
-	// This is for the first connection only, to prevent race
 	k.Lock()
 	defer k.Unlock()
 	if k.client != nil {
+		k.connUsers++
 		return nil
 	}
 
+	span, _ := k.trace("connect")
+	defer span.Finish()
+
 	if k.state.ProxyPid > 0 {
 		// check that proxy is running before talk with it avoiding long timeouts
 		if err := syscall.Kill(k.state.ProxyPid, syscall.Signal(0)); err != nil {
@@ -1803,7 +2024,20 @@ func (k *kataAgent) connect() error {
 	}
 
 	k.Logger().WithField("url", k.state.URL).WithField("proxy", k.state.ProxyPid).Info("New client")
-	client, err := kataclient.NewAgentClient(k.ctx, k.state.URL, k.proxyBuiltIn)
+
+	var client *kataclient.AgentClient
+	var err error
+	for attempt := uint32(0); ; attempt++ {
+		client, err = k.dial()
+		if err == nil || attempt >= k.requestRetries {
+			break
+		}
+
+		k.Logger().WithError(err).WithField("attempt", attempt+1).Warn("Could not connect to the agent, retrying")
+		if k.waitRetry(attempt) != nil {
+			break
+		}
+	}
 	if err != nil {
 		k.dead = true
 		return err
@@ -1811,10 +2045,72 @@ func (k *kataAgent) connect() error {
 
 	k.installReqFunc(client)
 	k.client = client
+	k.connUsers = 1
 
 	return nil
 }
 
+// release drops a use of the client, it's closed once unused when the
+// connection isn't kept.
+func (k *kataAgent) release() {
+	k.Lock()
+	defer k.Unlock()
+
+	if k.connUsers > 0 {
+		k.connUsers--
+	}
+	if k.keepConn || k.connUsers > 0 || k.client == nil {
+		return
+	}
+
+	if err := k.closeClient(); err != nil {
+		k.Logger().WithError(err).Warn("Could not close the agent client")
+	}
+}
+
+// dial connects to the agent within the dial timeout. The agent client bounds
+// each dial to its own default timeout, a longer dial timeout is made of
+// several dials.
+func (k *kataAgent) dial() (*kataclient.AgentClient, error) {
+	if k.dialTimeout == 0 {
+		return kataclient.NewAgentClient(k.ctx, k.state.URL, k.proxyBuiltIn)
+	}
+
+	ctx, cancel := context.WithTimeout(k.ctx, k.dialTimeout)
+	defer cancel()
+
+	for {
+		client, err := kataclient.NewAgentClient(ctx, k.state.URL, k.proxyBuiltIn)
+		if err != context.DeadlineExceeded || ctx.Err() != nil {
+			return client, err
+		}
+	}
+}
+
+// waitRetry waits before retrying, the delay doubling with the attempts. It
+// returns an error when the context of the agent is done meanwhile.
+func (k *kataAgent) waitRetry(attempt uint32) error {
+	delay := k.retryDelay
+	if delay == 0 {
+		delay = defaultRetryDelay
+	}
+
+	for i := uint32(0); i < attempt && delay < maxRetryDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxRetryDelay {
+		delay = maxRetryDelay
+	}
+
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-k.ctx.Done():
+		return k.ctx.Err()
+	}
+}
+
 func (k *kataAgent) disconnect() error {
 	span, _ := k.trace("disconnect")
 	defer span.Finish()
@@ -1826,6 +2122,12 @@ func (k *kataAgent) disconnect() error {
 		return nil
 	}
 
+	// the requests in progress fail with the closed client
+	k.connUsers = 0
+	return k.closeClient()
+}
+
+func (k *kataAgent) closeClient() error {
 	if err := k.client.Close(); err != nil && grpcStatus.Convert(err).Code() != codes.Canceled {
 		return err
 	}
@@ -1836,16 +2138,28 @@ func (k *kataAgent) disconnect() error {
 	return nil
 }
 
-// check grpc server is serving
+// check grpc server is serving. The agent isn't probed when it answered a
+// request recently, and a failed probe is tolerated while the agent didn't
+// miss maxAgentHealth requests in a row since its last answer.
 func (k *kataAgent) check() error {
 	span, _ := k.trace("check")
 	defer span.Finish()
 
+	if k.health.healthy() {
+		return nil
+	}
+
 	_, err := k.sendReq(&grpc.CheckRequest{})
-	if err != nil {
-		err = fmt.Errorf("Failed to check if grpc server is working: %s", err)
+	if err == nil {
+		return nil
 	}
-	return err
+
+	if k.health.alive() {
+		k.Logger().WithError(err).WithField("health", k.health.get()).Warn("Agent missed a health check")
+		return nil
+	}
+
+	return fmt.Errorf("Failed to check if grpc server is working: %s", err)
 }
 
 func (k *kataAgent) waitProcess(c *Container, processID string) (int32, error) {
@@ -1899,91 +2213,91 @@ type reqFunc func(context.Context, interface{}, ...golangGrpc.CallOption) (inter
 func (k *kataAgent) installReqFunc(c *kataclient.AgentClient) {
 	k.reqHandlers = make(map[string]reqFunc)
 	k.reqHandlers[grpcCheckRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.Check(ctx, req.(*grpc.CheckRequest), opts...)
+		return c.Check(ctx, req.(*grpc.CheckRequest), opts...)
 	}
 	k.reqHandlers[grpcExecProcessRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.ExecProcess(ctx, req.(*grpc.ExecProcessRequest), opts...)
+		return c.ExecProcess(ctx, req.(*grpc.ExecProcessRequest), opts...)
 	}
 	k.reqHandlers[grpcCreateSandboxRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.CreateSandbox(ctx, req.(*grpc.CreateSandboxRequest), opts...)
+		return c.CreateSandbox(ctx, req.(*grpc.CreateSandboxRequest), opts...)
 	}
 	k.reqHandlers[grpcDestroySandboxRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.DestroySandbox(ctx, req.(*grpc.DestroySandboxRequest), opts...)
+		return c.DestroySandbox(ctx, req.(*grpc.DestroySandboxRequest), opts...)
 	}
 	k.reqHandlers[grpcCreateContainerRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.CreateContainer(ctx, req.(*grpc.CreateContainerRequest), opts...)
+		return c.CreateContainer(ctx, req.(*grpc.CreateContainerRequest), opts...)
 	}
 	k.reqHandlers[grpcStartContainerRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.StartContainer(ctx, req.(*grpc.StartContainerRequest), opts...)
+		return c.StartContainer(ctx, req.(*grpc.StartContainerRequest), opts...)
 	}
 	k.reqHandlers[grpcRemoveContainerRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.RemoveContainer(ctx, req.(*grpc.RemoveContainerRequest), opts...)
+		return c.RemoveContainer(ctx, req.(*grpc.RemoveContainerRequest), opts...)
 	}
 	k.reqHandlers[grpcSignalProcessRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.SignalProcess(ctx, req.(*grpc.SignalProcessRequest), opts...)
+		return c.SignalProcess(ctx, req.(*grpc.SignalProcessRequest), opts...)
 	}
 	k.reqHandlers[grpcUpdateRoutesRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.UpdateRoutes(ctx, req.(*grpc.UpdateRoutesRequest), opts...)
+		return c.UpdateRoutes(ctx, req.(*grpc.UpdateRoutesRequest), opts...)
 	}
 	k.reqHandlers[grpcUpdateInterfaceRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.UpdateInterface(ctx, req.(*grpc.UpdateInterfaceRequest), opts...)
+		return c.UpdateInterface(ctx, req.(*grpc.UpdateInterfaceRequest), opts...)
 	}
 	k.reqHandlers[grpcListInterfacesRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.ListInterfaces(ctx, req.(*grpc.ListInterfacesRequest), opts...)
+		return c.ListInterfaces(ctx, req.(*grpc.ListInterfacesRequest), opts...)
 	}
 	k.reqHandlers[grpcListRoutesRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.ListRoutes(ctx, req.(*grpc.ListRoutesRequest), opts...)
+		return c.ListRoutes(ctx, req.(*grpc.ListRoutesRequest), opts...)
 	}
 	k.reqHandlers[grpcOnlineCPUMemRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.OnlineCPUMem(ctx, req.(*grpc.OnlineCPUMemRequest), opts...)
+		return c.OnlineCPUMem(ctx, req.(*grpc.OnlineCPUMemRequest), opts...)
 	}
 	k.reqHandlers[grpcListProcessesRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.ListProcesses(ctx, req.(*grpc.ListProcessesRequest), opts...)
+		return c.ListProcesses(ctx, req.(*grpc.ListProcessesRequest), opts...)
 	}
 	k.reqHandlers[grpcUpdateContainerRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.UpdateContainer(ctx, req.(*grpc.UpdateContainerRequest), opts...)
+		return c.UpdateContainer(ctx, req.(*grpc.UpdateContainerRequest), opts...)
 	}
 	k.reqHandlers[grpcWaitProcessRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.WaitProcess(ctx, req.(*grpc.WaitProcessRequest), opts...)
+		return c.WaitProcess(ctx, req.(*grpc.WaitProcessRequest), opts...)
 	}
 	k.reqHandlers[grpcTtyWinResizeRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.TtyWinResize(ctx, req.(*grpc.TtyWinResizeRequest), opts...)
+		return c.TtyWinResize(ctx, req.(*grpc.TtyWinResizeRequest), opts...)
 	}
 	k.reqHandlers[grpcWriteStreamRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.WriteStdin(ctx, req.(*grpc.WriteStreamRequest), opts...)
+		return c.WriteStdin(ctx, req.(*grpc.WriteStreamRequest), opts...)
 	}
 	k.reqHandlers[grpcCloseStdinRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.CloseStdin(ctx, req.(*grpc.CloseStdinRequest), opts...)
+		return c.CloseStdin(ctx, req.(*grpc.CloseStdinRequest), opts...)
 	}
 	k.reqHandlers[grpcStatsContainerRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.StatsContainer(ctx, req.(*grpc.StatsContainerRequest), opts...)
+		return c.StatsContainer(ctx, req.(*grpc.StatsContainerRequest), opts...)
 	}
 	k.reqHandlers[grpcPauseContainerRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.PauseContainer(ctx, req.(*grpc.PauseContainerRequest), opts...)
+		return c.PauseContainer(ctx, req.(*grpc.PauseContainerRequest), opts...)
 	}
 	k.reqHandlers[grpcResumeContainerRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.ResumeContainer(ctx, req.(*grpc.ResumeContainerRequest), opts...)
+		return c.ResumeContainer(ctx, req.(*grpc.ResumeContainerRequest), opts...)
 	}
 	k.reqHandlers[grpcReseedRandomDevRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.ReseedRandomDev(ctx, req.(*grpc.ReseedRandomDevRequest), opts...)
+		return c.ReseedRandomDev(ctx, req.(*grpc.ReseedRandomDevRequest), opts...)
 	}
 	k.reqHandlers[grpcGuestDetailsRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.GetGuestDetails(ctx, req.(*grpc.GuestDetailsRequest), opts...)
+		return c.GetGuestDetails(ctx, req.(*grpc.GuestDetailsRequest), opts...)
 	}
 	k.reqHandlers[grpcMemHotplugByProbeRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.MemHotplugByProbe(ctx, req.(*grpc.MemHotplugByProbeRequest), opts...)
+		return c.MemHotplugByProbe(ctx, req.(*grpc.MemHotplugByProbeRequest), opts...)
 	}
 	k.reqHandlers[grpcCopyFileRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.CopyFile(ctx, req.(*grpc.CopyFileRequest), opts...)
+		return c.CopyFile(ctx, req.(*grpc.CopyFileRequest), opts...)
 	}
 	k.reqHandlers[grpcSetGuestDateTimeRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.SetGuestDateTime(ctx, req.(*grpc.SetGuestDateTimeRequest), opts...)
+		return c.SetGuestDateTime(ctx, req.(*grpc.SetGuestDateTimeRequest), opts...)
 	}
 	k.reqHandlers[grpcStartTracingRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.StartTracing(ctx, req.(*grpc.StartTracingRequest), opts...)
+		return c.StartTracing(ctx, req.(*grpc.StartTracingRequest), opts...)
 	}
 	k.reqHandlers[grpcStopTracingRequest] = func(ctx context.Context, req interface{}, opts ...golangGrpc.CallOption) (interface{}, error) {
-		return k.client.StopTracing(ctx, req.(*grpc.StopTracingRequest), opts...)
+		return c.StopTracing(ctx, req.(*grpc.StopTracingRequest), opts...)
 	}
 }
 
@@ -1993,9 +2307,17 @@ func (k *kataAgent) getReqContext(reqName string) (ctx context.Context, cancel c
 	case grpcWaitProcessRequest:
 		// Wait has no timeout
 	case grpcCheckRequest:
-		ctx, cancel = context.WithTimeout(ctx, checkRequestTimeout)
+		timeout := checkRequestTimeout
+		if k.requestTimeout > 0 && k.requestTimeout < timeout {
+			timeout = k.requestTimeout
+		}
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 	default:
-		ctx, cancel = context.WithTimeout(ctx, defaultRequestTimeout)
+		timeout := defaultRequestTimeout
+		if k.requestTimeout > 0 {
+			timeout = k.requestTimeout
+		}
+		ctx, cancel = context.WithTimeout(ctx, timeout)
 	}
 
 	return ctx, cancel
@@ -2009,23 +2331,48 @@ func (k *kataAgent) sendReq(request interface{}) (interface{}, error) {
 	if err := k.connect(); err != nil {
 		return nil, err
 	}
-	if !k.keepConn {
-		defer k.disconnect()
-	}
+	defer k.release()
 
 	msgName := proto.MessageName(request.(proto.Message))
+	k.Lock()
 	handler := k.reqHandlers[msgName]
+	k.Unlock()
 	if msgName == "" || handler == nil {
 		return nil, errors.New("Invalid request type")
 	}
 	message := request.(proto.Message)
+	k.Logger().WithField("name", msgName).WithField("req", message.String()).Debug("sending request")
+
+	// only the requests the agent could not receive are retried
+	for attempt := uint32(0); ; attempt++ {
+		resp, err := k.sendReqOnce(handler, msgName, request)
+		if grpcStatus.Code(err) == codes.DeadlineExceeded {
+			return resp, vcTypes.WithErrorCode(vcTypes.ErrCodeAgentTimeout, err)
+		}
+		if err == nil || attempt >= k.requestRetries || grpcStatus.Code(err) != codes.Unavailable {
+			return resp, err
+		}
+
+		k.Logger().WithError(err).WithFields(logrus.Fields{
+			"name":    msgName,
+			"attempt": attempt + 1,
+		}).Warn("Agent unavailable, retrying request")
+		if k.waitRetry(attempt) != nil {
+			return resp, err
+		}
+	}
+}
+
+func (k *kataAgent) sendReqOnce(handler reqFunc, msgName string, request interface{}) (interface{}, error) {
 	ctx, cancel := k.getReqContext(msgName)
 	if cancel != nil {
 		defer cancel()
 	}
-	k.Logger().WithField("name", msgName).WithField("req", message.String()).Debug("sending request")
 
-	return handler(ctx, request)
+	resp, err := handler(ctx, request)
+	k.health.record(err)
+
+	return resp, err
 }
 
 // readStdout and readStderr are special that we cannot differentiate them with the request types...
@@ -2033,11 +2380,16 @@ func (k *kataAgent) readProcessStdout(c *Container, processID string, data []byt
 	if err := k.connect(); err != nil {
 		return 0, err
 	}
-	if !k.keepConn {
-		defer k.disconnect()
+	defer k.release()
+
+	k.Lock()
+	client := k.client
+	k.Unlock()
+	if client == nil {
+		return 0, errors.New("Agent client closed")
 	}
 
-	return k.readProcessStream(c.id, processID, data, k.client.ReadStdout)
+	return k.readProcessStream(c.id, processID, data, client.ReadStdout)
 }
 
 // readStdout and readStderr are special that we cannot differentiate them with the request types...
@@ -2045,11 +2397,16 @@ func (k *kataAgent) readProcessStderr(c *Container, processID string, data []byt
 	if err := k.connect(); err != nil {
 		return 0, err
 	}
-	if !k.keepConn {
-		defer k.disconnect()
+	defer k.release()
+
+	k.Lock()
+	client := k.client
+	k.Unlock()
+	if client == nil {
+		return 0, errors.New("Agent client closed")
 	}
 
-	return k.readProcessStream(c.id, processID, data, k.client.ReadStderr)
+	return k.readProcessStream(c.id, processID, data, client.ReadStderr)
 }
 
 type readFn func(context.Context, *grpc.ReadStreamRequest, ...golangGrpc.CallOption) (*grpc.ReadStreamResponse, error)
@@ -0,0 +1,99 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+const (
+	// maxAgentHealth is the health of an agent which answered its last
+	// request, each request it doesn't answer takes one from it.
+	maxAgentHealth = 3
+
+	// agentHealthyPeriod is how long an answer of the agent makes it
+	// healthy, the agent isn't probed during that period.
+	agentHealthyPeriod = defaultCheckInterval
+)
+
+// agentHealth scores the agent from the answers to the requests sent to
+// it, so that a single missed probe doesn't make the agent dead.
+type agentHealth struct {
+	sync.Mutex
+
+	score int
+
+	// lastAnswer is the time of the last answer of the agent, zero until
+	// the agent answered once.
+	lastAnswer time.Time
+}
+
+// agentAnswered returns if err, returned by a request, has been sent back
+// by the agent. The requests which failed in the agent still show it's
+// alive.
+func agentAnswered(err error) bool {
+	switch grpcStatus.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return false
+	case codes.Unknown:
+		// the errors which don't come from gRPC, e.g. a closed client
+		_, ok := grpcStatus.FromError(err)
+		return ok
+	}
+	return true
+}
+
+func (h *agentHealth) record(err error) {
+	h.Lock()
+	defer h.Unlock()
+
+	if agentAnswered(err) {
+		h.score = maxAgentHealth
+		h.lastAnswer = time.Now()
+		return
+	}
+
+	if h.score > 0 {
+		h.score--
+	}
+}
+
+// healthy returns if the agent answered in the last agentHealthyPeriod.
+func (h *agentHealth) healthy() bool {
+	h.Lock()
+	defer h.Unlock()
+
+	return !h.lastAnswer.IsZero() && time.Since(h.lastAnswer) < agentHealthyPeriod
+}
+
+// alive returns if the agent can still be considered alive after a failed
+// probe: it answered before and didn't miss too many requests since.
+func (h *agentHealth) alive() bool {
+	h.Lock()
+	defer h.Unlock()
+
+	return !h.lastAnswer.IsZero() && h.score > 0
+}
+
+// reset forgets the answers of the agent, e.g. when the guest rebooted.
+func (h *agentHealth) reset() {
+	h.Lock()
+	defer h.Unlock()
+
+	h.score = 0
+	h.lastAnswer = time.Time{}
+}
+
+func (h *agentHealth) get() int {
+	h.Lock()
+	defer h.Unlock()
+
+	return h.score
+}
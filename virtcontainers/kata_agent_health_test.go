@@ -0,0 +1,60 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestAgentAnswered(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(agentAnswered(nil))
+	assert.True(agentAnswered(grpcStatus.Error(codes.NotFound, "no such container")))
+	assert.True(agentAnswered(grpcStatus.Error(codes.Unknown, "failed in the agent")))
+	assert.False(agentAnswered(grpcStatus.Error(codes.Unavailable, "connection refused")))
+	assert.False(agentAnswered(grpcStatus.Error(codes.DeadlineExceeded, "timeout")))
+	assert.False(agentAnswered(errors.New("client closed")))
+}
+
+func TestAgentHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	var h agentHealth
+	assert.False(h.healthy())
+	assert.False(h.alive())
+
+	unavailable := grpcStatus.Error(codes.Unavailable, "connection refused")
+	h.record(unavailable)
+	assert.False(h.alive())
+
+	h.record(nil)
+	assert.True(h.healthy())
+	assert.True(h.alive())
+	assert.Equal(maxAgentHealth, h.get())
+
+	h.lastAnswer = time.Now().Add(-agentHealthyPeriod)
+	assert.False(h.healthy())
+
+	for i := 1; i < maxAgentHealth; i++ {
+		h.record(unavailable)
+		assert.True(h.alive())
+	}
+	h.record(unavailable)
+	assert.False(h.alive())
+	assert.Equal(0, h.get())
+
+	h.record(nil)
+	h.reset()
+	assert.False(h.healthy())
+	assert.False(h.alive())
+}
@@ -18,6 +18,7 @@ import (
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
 	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
 
@@ -25,8 +26,11 @@ import (
 	specs "github.com/opencontainers/runtime-spec/specs-go"
 	"github.com/stretchr/testify/assert"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
 
 	aTypes "github.com/kata-containers/agent/pkg/types"
+	kataclient "github.com/kata-containers/agent/protocols/client"
 	pb "github.com/kata-containers/agent/protocols/grpc"
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
@@ -36,6 +40,7 @@ import (
 	"github.com/kata-containers/runtime/virtcontainers/pkg/mock"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/kata-containers/runtime/virtcontainers/pkg/volume"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 )
 
@@ -113,6 +118,48 @@ func TestKataAgentDisconnect(t *testing.T) {
 	assert.Nil(k.client)
 }
 
+func TestKataAgentConnectUsers(t *testing.T) {
+	assert := assert.New(t)
+	proxy := mock.ProxyUnixMock{
+		ClientHandler: proxyHandlerDiscard,
+	}
+
+	sockDir, err := testGenerateKataProxySockDir()
+	assert.NoError(err)
+	defer os.RemoveAll(sockDir)
+
+	testKataProxyURL := fmt.Sprintf(testKataProxyURLTempl, sockDir)
+	err = proxy.Start(testKataProxyURL)
+	assert.NoError(err)
+	defer proxy.Stop()
+
+	k := &kataAgent{
+		ctx: context.Background(),
+		state: KataAgentState{
+			URL: testKataProxyURL,
+		},
+	}
+
+	// the concurrent requests share the client
+	assert.NoError(k.connect())
+	client := k.client
+	assert.NoError(k.connect())
+	assert.Equal(client, k.client)
+
+	k.release()
+	assert.NotNil(k.client)
+	k.release()
+	assert.Nil(k.client)
+
+	// a kept connection isn't closed once unused
+	k.keepConn = true
+	assert.NoError(k.connect())
+	k.release()
+	assert.NotNil(k.client)
+	assert.NoError(k.disconnect())
+	assert.Nil(k.client)
+}
+
 type gRPCProxy struct{}
 
 var emptyResp = &gpb.Empty{}
@@ -382,7 +429,8 @@ func TestHandleLocalStorage(t *testing.T) {
 	rootfsSuffix := "rootfs"
 
 	ociMounts = append(ociMounts, mount)
-	localStorages := k.handleLocalStorage(ociMounts, sandboxID, rootfsSuffix)
+	sandbox := &Sandbox{id: sandboxID}
+	localStorages := k.handleLocalStorage(ociMounts, localStoragePath(sandbox, rootfsSuffix))
 
 	assert.NotNil(t, localStorages)
 	assert.Equal(t, len(localStorages), 1)
@@ -390,6 +438,15 @@ func TestHandleLocalStorage(t *testing.T) {
 	localMountPoint := localStorages[0].GetMountPoint()
 	expected := filepath.Join(kataGuestSharedDir(), sandboxID, rootfsSuffix, KataLocalDevType, filepath.Base(mountSource))
 	assert.Equal(t, localMountPoint, expected)
+
+	// the local storages are moved to the scratch disk of the sandbox
+	sandbox.state.ScratchDeviceID = "scratch"
+	ociMounts[0].Source = mountSource
+	localStorages = k.handleLocalStorage(ociMounts, localStoragePath(sandbox, rootfsSuffix))
+
+	localMountPoint = localStorages[0].GetMountPoint()
+	expected = filepath.Join(kataGuestScratchDir(), KataLocalDevType, filepath.Base(mountSource))
+	assert.Equal(t, localMountPoint, expected)
 }
 
 func TestHandleBlockVolume(t *testing.T) {
@@ -468,6 +525,106 @@ func TestHandleBlockVolume(t *testing.T) {
 	assert.Equal(t, bStorage, volumeStorages[1], "Error while handle BlockDevice type block volume")
 }
 
+func TestHandleDirectAssignedBlockVolume(t *testing.T) {
+	assert := assert.New(t)
+	k := kataAgent{}
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	savedRootPath := volume.RootPath
+	volume.RootPath = tmpDir
+	defer func() {
+		volume.RootPath = savedRootPath
+	}()
+
+	source := "/var/lib/kubelet/pods/abc/volumes/kubernetes.io~csi/pvc/mount"
+	err = volume.Add(source, `{"volume-type": "block", "device": "/dev/sdb", "fstype": "xfs", "options": ["ro"]}`)
+	assert.NoError(err)
+
+	devID := "MockDeviceBlock"
+	dev := drivers.NewBlockDevice(&config.DeviceInfo{ID: devID})
+	dev.BlockDrive = &config.BlockDrive{PCIAddr: testPCIAddr}
+
+	sConfig := SandboxConfig{}
+	sConfig.HypervisorConfig.BlockDeviceDriver = manager.VirtioBlock
+	c := &Container{
+		id: "100",
+		sandbox: &Sandbox{
+			id:         "100",
+			hypervisor: &mockHypervisor{},
+			devManager: manager.NewDeviceManager(manager.VirtioBlock, false, "", []api.Device{dev}),
+			ctx:        context.Background(),
+			config:     &sConfig,
+		},
+		mounts: []Mount{
+			{
+				Source:        source,
+				Destination:   "/data",
+				Type:          "bind",
+				BlockDeviceID: devID,
+			},
+		},
+	}
+
+	volumeStorages, err := k.handleBlockVolumes(c)
+	assert.NoError(err)
+	assert.Equal([]*pb.Storage{
+		{
+			MountPoint: "/data",
+			Fstype:     "xfs",
+			Options:    []string{"ro"},
+			Driver:     kataBlkDevType,
+			Source:     testPCIAddr,
+		},
+	}, volumeStorages)
+}
+
+func TestHandleBlockVolumeFilesystem(t *testing.T) {
+	assert := assert.New(t)
+	k := kataAgent{}
+
+	devID := "MockDeviceBlock"
+	dev := drivers.NewBlockDevice(&config.DeviceInfo{ID: devID})
+	dev.BlockDrive = &config.BlockDrive{PCIAddr: testPCIAddr}
+
+	sConfig := SandboxConfig{}
+	sConfig.HypervisorConfig.BlockDeviceDriver = manager.VirtioBlock
+	c := &Container{
+		id: "100",
+		sandbox: &Sandbox{
+			id:         "100",
+			hypervisor: &mockHypervisor{},
+			devManager: manager.NewDeviceManager(manager.VirtioBlock, false, "", []api.Device{dev}),
+			ctx:        context.Background(),
+			config:     &sConfig,
+		},
+		mounts: []Mount{
+			{
+				Source:        "/mnt/models",
+				Destination:   "/models",
+				Type:          "bind",
+				Options:       []string{"ro"},
+				BlockDeviceID: devID,
+				BlockFsType:   "ext4",
+			},
+		},
+	}
+
+	volumeStorages, err := k.handleBlockVolumes(c)
+	assert.NoError(err)
+	assert.Equal([]*pb.Storage{
+		{
+			MountPoint: "/models",
+			Fstype:     "ext4",
+			Options:    []string{"ro", "noload"},
+			Driver:     kataBlkDevType,
+			Source:     testPCIAddr,
+		},
+	}, volumeStorages)
+}
+
 func TestAppendDevicesEmptyContainerDeviceList(t *testing.T) {
 	k := kataAgent{}
 
@@ -533,6 +690,60 @@ func TestAppendDevices(t *testing.T) {
 		updatedDevList, expected)
 }
 
+func TestAppendDevicesBlockSerial(t *testing.T) {
+	assert := assert.New(t)
+	k := kataAgent{}
+
+	id := "test-append-block-serial"
+	ctrDevices := []api.Device{
+		&drivers.BlockDevice{
+			GenericDevice: &drivers.GenericDevice{
+				ID: id,
+			},
+			BlockDrive: &config.BlockDrive{
+				PCIAddr: testPCIAddr,
+				Serial:  "0123456789abcdef0123",
+			},
+		},
+	}
+
+	sandboxConfig := &SandboxConfig{
+		HypervisorConfig: HypervisorConfig{
+			BlockDeviceDriver: config.VirtioBlock,
+			BlockDeviceNaming: config.BlockNamingSerial,
+		},
+	}
+
+	c := &Container{
+		sandbox: &Sandbox{
+			devManager: manager.NewDeviceManager("virtio-blk", false, "", ctrDevices),
+			config:     sandboxConfig,
+		},
+	}
+	c.devices = append(c.devices, ContainerDevice{
+		ID:            id,
+		ContainerPath: testBlockDeviceCtrPath,
+	})
+
+	assert.Equal([]*pb.Device{
+		{
+			Type:          kataBlkSerialDevType,
+			ContainerPath: testBlockDeviceCtrPath,
+			Id:            "0123456789abcdef0123",
+		},
+	}, k.appendDevices([]*pb.Device{}, c))
+
+	// the drives without a serial number are found by their address
+	ctrDevices[0].(*drivers.BlockDevice).BlockDrive.Serial = ""
+	assert.Equal([]*pb.Device{
+		{
+			Type:          kataBlkDevType,
+			ContainerPath: testBlockDeviceCtrPath,
+			Id:            testPCIAddr,
+		},
+	}, k.appendDevices([]*pb.Device{}, c))
+}
+
 func TestAppendVhostUserBlkDevices(t *testing.T) {
 	k := kataAgent{}
 
@@ -624,12 +835,13 @@ func TestConstraintGRPCSpec(t *testing.T) {
 			},
 		},
 		Process: &pb.Process{
-			SelinuxLabel: "foo",
+			SelinuxLabel:    "foo",
+			ApparmorProfile: "docker-default",
 		},
 	}
 
 	k := kataAgent{}
-	k.constraintGRPCSpec(g, true)
+	k.constraintGRPCSpec(g, true, false, false)
 
 	// check nil fields
 	assert.Nil(g.Hooks)
@@ -642,6 +854,7 @@ func TestConstraintGRPCSpec(t *testing.T) {
 	assert.Nil(g.Linux.Resources.Network)
 	assert.NotNil(g.Linux.Resources.CPU)
 	assert.Equal(g.Process.SelinuxLabel, "")
+	assert.Equal(g.Process.ApparmorProfile, "")
 
 	// check namespaces
 	assert.Len(g.Linux.Namespaces, 1)
@@ -657,6 +870,90 @@ func TestConstraintGRPCSpec(t *testing.T) {
 	assert.Empty(g.Linux.Devices)
 }
 
+func TestHandleNetSysctls(t *testing.T) {
+	assert := assert.New(t)
+
+	newSpec := func() *pb.Spec {
+		return &pb.Spec{
+			Linux: &pb.Linux{
+				Sysctl: map[string]string{
+					"net.core.somaxconn":           "1024",
+					"net.ipv4.tcp_keepalive_time":  "600",
+					"net.ipv4.conf.all.forwarding": "1",
+					"kernel.shm_rmid_forced":       "1",
+				},
+			},
+		}
+	}
+
+	k := kataAgent{}
+	sandbox := &Sandbox{config: &SandboxConfig{}}
+
+	// all of them are kept without an allow-list
+	g := newSpec()
+	k.handleNetSysctls(g, sandbox)
+	assert.Equal(newSpec().Linux.Sysctl, g.Linux.Sysctl)
+
+	sandbox.config.NetSysctlAllowList = []string{"net.ipv4.conf.*.forwarding"}
+	g = newSpec()
+	k.handleNetSysctls(g, sandbox)
+	assert.Equal(map[string]string{
+		"net.ipv4.conf.all.forwarding": "1",
+		"kernel.shm_rmid_forced":       "1",
+	}, g.Linux.Sysctl)
+}
+
+func TestConstraintGRPCSpecLSM(t *testing.T) {
+	assert := assert.New(t)
+
+	newSpec := func() *pb.Spec {
+		return &pb.Spec{
+			Linux: &pb.Linux{
+				Resources:  &pb.LinuxResources{},
+				MountLabel: "system_u:object_r:container_file_t:s0:c1,c2",
+			},
+			Process: &pb.Process{
+				SelinuxLabel:    "system_u:system_r:container_t:s0:c1,c2",
+				ApparmorProfile: "docker-default",
+			},
+		}
+	}
+
+	k := kataAgent{}
+
+	g := newSpec()
+	k.constraintGRPCSpec(g, false, true, false)
+	assert.Equal(newSpec().Process.SelinuxLabel, g.Process.SelinuxLabel)
+	assert.Equal(newSpec().Linux.MountLabel, g.Linux.MountLabel)
+	assert.Empty(g.Process.ApparmorProfile)
+
+	g = newSpec()
+	k.constraintGRPCSpec(g, false, false, true)
+	assert.Empty(g.Process.SelinuxLabel)
+	assert.Empty(g.Linux.MountLabel)
+	assert.Equal("docker-default", g.Process.ApparmorProfile)
+}
+
+func TestGuestLSMEnabled(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, d := range []struct {
+		params  []Param
+		lsm     string
+		enabled bool
+	}{
+		{nil, "selinux", false},
+		{[]Param{{"security", "selinux"}}, "selinux", true},
+		{[]Param{{"security", "selinux"}}, "apparmor", false},
+		{[]Param{{"security", "selinux"}, {"selinux", "0"}}, "selinux", false},
+		{[]Param{{"selinux", "1"}}, "selinux", false},
+		{[]Param{{"lsm", "lockdown,yama,apparmor"}}, "apparmor", true},
+		{[]Param{{"security", "apparmor"}, {"lsm", "yama,selinux"}}, "apparmor", false},
+	} {
+		assert.Equal(d.enabled, guestLSMEnabled(d.params, d.lsm), "params: %v, lsm: %s", d.params, d.lsm)
+	}
+}
+
 func TestHandleShm(t *testing.T) {
 	assert := assert.New(t)
 	k := kataAgent{}
@@ -1152,6 +1449,12 @@ func TestKataAgentKernelParams(t *testing.T) {
 			assert.Containsf(params, p, "test %d (%+v)", i, d)
 		}
 	}
+
+	params := KataAgentKernelParams(KataAgentConfig{DebugConsoleEnabled: true})
+	assert.Equal([]Param{
+		{Key: "agent.debug_console", Value: ""},
+		{Key: "agent.debug_console_vport", Value: "1026"},
+	}, params)
 }
 
 func TestKataAgentHandleTraceSettings(t *testing.T) {
@@ -1286,3 +1589,130 @@ func TestKataAgentDirs(t *testing.T) {
 		assert.Equal(ephemeralPath(), defaultEphemeralPath)
 	}
 }
+
+func TestKataAgentReqContext(t *testing.T) {
+	assert := assert.New(t)
+
+	k := &kataAgent{}
+
+	ctx, cancel := k.getReqContext(grpcWaitProcessRequest)
+	assert.Nil(cancel)
+	_, ok := ctx.Deadline()
+	assert.False(ok)
+
+	ctx, cancel = k.getReqContext(grpcCheckRequest)
+	deadline, ok := ctx.Deadline()
+	cancel()
+	assert.True(ok)
+	assert.WithinDuration(time.Now().Add(checkRequestTimeout), deadline, time.Second)
+
+	k.requestTimeout = 90 * time.Second
+	ctx, cancel = k.getReqContext(grpcCreateContainerRequest)
+	deadline, _ = ctx.Deadline()
+	cancel()
+	assert.WithinDuration(time.Now().Add(k.requestTimeout), deadline, time.Second)
+
+	// the checks keep their shorter timeout
+	ctx, cancel = k.getReqContext(grpcCheckRequest)
+	deadline, _ = ctx.Deadline()
+	cancel()
+	assert.WithinDuration(time.Now().Add(checkRequestTimeout), deadline, time.Second)
+}
+
+func TestKataAgentDial(t *testing.T) {
+	assert := assert.New(t)
+
+	k := &kataAgent{
+		ctx:         context.Background(),
+		dialTimeout: time.Second,
+		state: KataAgentState{
+			URL: "unix:///run/kata-containers/no-such-agent.sock",
+		},
+	}
+
+	// the dial timeout bounds the dials
+	start := time.Now()
+	_, err := k.dial()
+	assert.Equal(context.DeadlineExceeded, err)
+	assert.WithinDuration(start.Add(k.dialTimeout), time.Now(), time.Second)
+}
+
+func TestKataAgentCheckHealth(t *testing.T) {
+	assert := assert.New(t)
+
+	var checkErr error
+	calls := 0
+	k := &kataAgent{
+		ctx:      context.Background(),
+		client:   &kataclient.AgentClient{},
+		keepConn: true,
+		reqHandlers: map[string]reqFunc{
+			grpcCheckRequest: func(ctx context.Context, req interface{}, opts ...grpc.CallOption) (interface{}, error) {
+				calls++
+				return &pb.HealthCheckResponse{}, checkErr
+			},
+		},
+	}
+
+	// the agent must answer once before its misses are tolerated
+	checkErr = grpcStatus.Error(codes.Unavailable, "connection refused")
+	assert.Error(k.check())
+
+	checkErr = nil
+	assert.NoError(k.check())
+	assert.Equal(2, calls)
+
+	// no probe right after an answer
+	assert.NoError(k.check())
+	assert.Equal(2, calls)
+
+	checkErr = grpcStatus.Error(codes.DeadlineExceeded, "timeout")
+	for i := 0; i < maxAgentHealth-1; i++ {
+		k.health.lastAnswer = time.Now().Add(-agentHealthyPeriod)
+		assert.NoError(k.check())
+	}
+	assert.Error(k.check())
+	assert.Equal(2+maxAgentHealth, calls)
+}
+
+func TestKataAgentSendReqRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	calls := 0
+	k := &kataAgent{
+		ctx:        context.Background(),
+		client:     &kataclient.AgentClient{},
+		keepConn:   true,
+		retryDelay: time.Millisecond,
+		reqHandlers: map[string]reqFunc{
+			grpcCheckRequest: func(ctx context.Context, req interface{}, opts ...grpc.CallOption) (interface{}, error) {
+				calls++
+				if calls < 3 {
+					return nil, grpcStatus.Error(codes.Unavailable, "connection refused")
+				}
+				return &pb.HealthCheckResponse{}, nil
+			},
+			grpcCreateContainerRequest: func(ctx context.Context, req interface{}, opts ...grpc.CallOption) (interface{}, error) {
+				calls++
+				return nil, grpcStatus.Error(codes.Internal, "failed")
+			},
+		},
+	}
+
+	// no retry by default
+	_, err := k.sendReq(&pb.CheckRequest{})
+	assert.Error(err)
+	assert.Equal(1, calls)
+
+	calls = 0
+	k.requestRetries = 2
+	_, err = k.sendReq(&pb.CheckRequest{})
+	assert.NoError(err)
+	assert.Equal(3, calls)
+
+	// the requests the agent received are not retried
+	calls = 0
+	_, err = k.sendReq(&pb.CreateContainerRequest{})
+	assert.Error(err)
+	assert.Equal(1, calls)
+}
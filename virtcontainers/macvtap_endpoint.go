@@ -59,13 +59,20 @@ func (endpoint *MacvtapEndpoint) SetProperties(properties NetworkInfo) {
 func (endpoint *MacvtapEndpoint) Attach(h hypervisor) error {
 	var err error
 
-	endpoint.VMFds, err = createMacvtapFds(endpoint.EndpointProperties.Iface.Index, int(h.hypervisorConfig().NumVCPUs))
+	// macvtap devices are passed to the hypervisor as file descriptors,
+	// one per queue, single queue devices need one as well.
+	queues := netQueues(h)
+	if queues == 0 {
+		queues = 1
+	}
+
+	endpoint.VMFds, err = createMacvtapFds(endpoint.EndpointProperties.Iface.Index, queues)
 	if err != nil {
 		return fmt.Errorf("Could not setup macvtap fds %s: %s", endpoint.EndpointProperties.Iface.Name, err)
 	}
 
 	if !h.hypervisorConfig().DisableVhostNet {
-		vhostFds, err := createVhostFds(int(h.hypervisorConfig().NumVCPUs))
+		vhostFds, err := createVhostFds(queues)
 		if err != nil {
 			return fmt.Errorf("Could not setup vhost fds %s : %s", endpoint.EndpointProperties.Iface.Name, err)
 		}
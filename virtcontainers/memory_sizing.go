@@ -0,0 +1,141 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MemorySizingPolicy selects how the memory of the sandboxes is sized.
+type MemorySizingPolicy string
+
+const (
+	// MemorySizingStatic gives the configured memory to every sandbox.
+	MemorySizingStatic MemorySizingPolicy = "static"
+
+	// MemorySizingDynamic sizes each sandbox for the memory its pod
+	// requests, within the memory left available by the host.
+	MemorySizingDynamic MemorySizingPolicy = "dynamic"
+)
+
+// MemorySizing computes the memory of the sandboxes when they are
+// created, for the hypervisors which can't resize the VM afterwards.
+type MemorySizing struct {
+	Policy MemorySizingPolicy
+
+	// Floor is the least memory in MiB a sandbox is created with.
+	Floor uint32
+
+	// Ceiling is the most memory in MiB a sandbox is created with, the
+	// pods requesting no memory get it.
+	Ceiling uint32
+
+	// HostMargin is the memory in MiB the sandboxes leave available to
+	// the host.
+	HostMargin uint32
+}
+
+// Valid checks the memory sizing settings.
+func (m MemorySizing) Valid() error {
+	switch m.Policy {
+	case "", MemorySizingStatic:
+		return nil
+	case MemorySizingDynamic:
+	default:
+		return fmt.Errorf("Unknown memory sizing policy %q, expecting %q or %q", m.Policy, MemorySizingStatic, MemorySizingDynamic)
+	}
+
+	if m.Floor < MinHypervisorMemory {
+		return fmt.Errorf("Memory sizing floor of %d MiB is less than the minimum of %d MiB", m.Floor, MinHypervisorMemory)
+	}
+
+	if m.Ceiling == 0 {
+		return fmt.Errorf("Memory sizing ceiling missing")
+	}
+
+	if m.Ceiling < m.Floor {
+		return fmt.Errorf("Memory sizing ceiling of %d MiB is less than the floor of %d MiB", m.Ceiling, m.Floor)
+	}
+
+	return nil
+}
+
+// Dynamic returns if the memory of the sandboxes is computed.
+func (m MemorySizing) Dynamic() bool {
+	return m.Policy == MemorySizingDynamic
+}
+
+// SandboxMemory returns the memory in MiB of a sandbox whose guest itself
+// uses base MiB and whose pod requests podMemory MiB, 0 if the pod doesn't
+// say. It fails if the host doesn't have the floor available above the
+// margin.
+func (m MemorySizing) SandboxMemory(base, podMemory uint32) (uint32, error) {
+	available, err := getHostMemAvailableKb(procMemInfo)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.sandboxMemory(base, podMemory, available>>10)
+}
+
+func (m MemorySizing) sandboxMemory(base, podMemory uint32, hostAvailable uint64) (uint32, error) {
+	var usable uint64
+	if hostAvailable > uint64(m.HostMargin) {
+		usable = hostAvailable - uint64(m.HostMargin)
+	}
+
+	if usable < uint64(m.Floor) {
+		return 0, fmt.Errorf("Not enough host memory for a sandbox: %d MiB available above the margin of %d MiB, %d MiB needed", usable, m.HostMargin, m.Floor)
+	}
+
+	size := uint64(m.Ceiling)
+	if podMemory > 0 {
+		size = uint64(base) + uint64(podMemory)
+	}
+
+	if size < uint64(m.Floor) {
+		size = uint64(m.Floor)
+	}
+
+	if size > uint64(m.Ceiling) {
+		size = uint64(m.Ceiling)
+	}
+
+	if size > usable {
+		virtLog.WithField("requested", size).WithField("available", usable).Warn("Sandbox memory cut to the host memory available")
+		size = usable
+	}
+
+	return uint32(size), nil
+}
+
+func getHostMemAvailableKb(memInfoPath string) (uint64, error) {
+	f, err := os.Open(memInfoPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) < 3 || parts[0] != "MemAvailable:" || parts[2] != "kB" {
+			continue
+		}
+
+		return strconv.ParseUint(parts[1], 10, 64)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, fmt.Errorf("unable get MemAvailable from %s", memInfoPath)
+}
@@ -0,0 +1,96 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemorySizingValid(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(MemorySizing{}.Valid())
+	assert.NoError(MemorySizing{Policy: MemorySizingStatic}.Valid())
+	assert.NoError(MemorySizing{Policy: MemorySizingDynamic, Floor: 256, Ceiling: 4096}.Valid())
+	assert.NoError(MemorySizing{Policy: MemorySizingDynamic, Floor: 512, Ceiling: 512}.Valid())
+
+	assert.Error(MemorySizing{Policy: "auto"}.Valid())
+	assert.Error(MemorySizing{Policy: MemorySizingDynamic, Floor: 128}.Valid())
+	assert.Error(MemorySizing{Policy: MemorySizingDynamic, Floor: 1024, Ceiling: 512}.Valid())
+	assert.Error(MemorySizing{Policy: MemorySizingDynamic, Floor: 512}.Valid())
+
+	assert.False(MemorySizing{}.Dynamic())
+	assert.True(MemorySizing{Policy: MemorySizingDynamic}.Dynamic())
+}
+
+func TestMemorySizingSandboxMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	sizing := MemorySizing{
+		Policy:     MemorySizingDynamic,
+		Floor:      256,
+		Ceiling:    4096,
+		HostMargin: 1024,
+	}
+
+	for _, d := range []struct {
+		base          uint32
+		podMemory     uint32
+		hostAvailable uint64
+		expected      uint32
+	}{
+		// the guest gets its base memory on top of the pod limits
+		{128, 1024, 16384, 1152},
+		// the small pods get the floor
+		{64, 64, 16384, 256},
+		// the pods requesting nothing get the ceiling
+		{128, 0, 16384, 4096},
+		{128, 8192, 16384, 4096},
+		// the margin is left to the host
+		{128, 4096, 2048, 1024},
+	} {
+		size, err := sizing.sandboxMemory(d.base, d.podMemory, d.hostAvailable)
+		assert.NoError(err, "%+v", d)
+		assert.Equal(d.expected, size, "%+v", d)
+	}
+
+	_, err := sizing.sandboxMemory(128, 1024, 1152)
+	assert.Error(err)
+
+	_, err = sizing.sandboxMemory(128, 1024, 512)
+	assert.Error(err)
+}
+
+func TestGetHostMemAvailableKb(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "meminfo")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	file := filepath.Join(dir, "meminfo")
+
+	assert.NoError(ioutil.WriteFile(file, []byte("MemTotal:       16384 kB\nMemFree:         1024 kB\nMemAvailable:    8192 kB\n"), 0644))
+	available, err := getHostMemAvailableKb(file)
+	assert.NoError(err)
+	assert.Equal(uint64(8192), available)
+
+	assert.NoError(ioutil.WriteFile(file, []byte("MemTotal:       16384 kB\n"), 0644))
+	_, err = getHostMemAvailableKb(file)
+	assert.Error(err)
+
+	_, err = getHostMemAvailableKb(filepath.Join(dir, "missing"))
+	assert.Error(err)
+
+	available, err = getHostMemAvailableKb(procMemInfo)
+	assert.NoError(err)
+	assert.NotZero(available)
+}
@@ -107,6 +107,10 @@ func (m *mockHypervisor) getPids() []int {
 	return []int{m.mockPid}
 }
 
+func (m *mockHypervisor) getVMPath() string {
+	return ""
+}
+
 func (m *mockHypervisor) fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig, j []byte) error {
 	return errors.New("mockHypervisor is not supported by VM cache")
 }
@@ -125,6 +129,22 @@ func (m *mockHypervisor) check() error {
 	return nil
 }
 
+func (m *mockHypervisor) describe() (VMInfo, error) {
+	return VMInfo{State: "running"}, nil
+}
+
+func (m *mockHypervisor) rebootSandbox() error {
+	return nil
+}
+
+func (m *mockHypervisor) migrateSandbox(uri string) error {
+	return nil
+}
+
+func (m *mockHypervisor) launchMeasurement() (string, error) {
+	return "", nil
+}
+
 func (m *mockHypervisor) generateSocket(id string, useVsock bool) (interface{}, error) {
 	return types.Socket{HostPath: "/tmp/socket", Name: "socket"}, nil
 }
@@ -6,15 +6,24 @@
 package virtcontainers
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
 	"sync"
 	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sys/unix"
 )
 
 const (
 	defaultCheckInterval = 1 * time.Second
 	watcherChannelSize   = 128
+
+	// hypervisorExitHookTimeout bounds the time the exit hook can take,
+	// the sandbox cleanup waits for it.
+	hypervisorExitHookTimeout = 30 * time.Second
 )
 
 type monitor struct {
@@ -25,14 +34,15 @@ type monitor struct {
 	watchers      []chan error
 	wg            sync.WaitGroup
 	running       bool
+	suspended     bool
 	stopCh        chan bool
+	exitHookOnce  sync.Once
 }
 
 func newMonitor(s *Sandbox) *monitor {
 	return &monitor{
 		sandbox:       s,
 		checkInterval: defaultCheckInterval,
-		stopCh:        make(chan bool, 1),
 	}
 }
 
@@ -43,30 +53,74 @@ func (m *monitor) newWatcher() (chan error, error) {
 	watcher := make(chan error, watcherChannelSize)
 	m.watchers = append(m.watchers, watcher)
 
-	if !m.running {
-		m.running = true
-		m.wg.Add(1)
-
-		// create and start agent watcher
-		go func() {
-			tick := time.NewTicker(m.checkInterval)
-			for {
-				select {
-				case <-m.stopCh:
-					tick.Stop()
-					m.wg.Done()
-					return
-				case <-tick.C:
-					m.watchHypervisor()
-					m.watchAgent()
-				}
-			}
-		}()
+	if !m.running && !m.suspended {
+		m.start()
 	}
 
 	return watcher, nil
 }
 
+// start starts watching the agent and the hypervisor, the monitor being
+// locked.
+func (m *monitor) start() {
+	m.running = true
+	m.stopCh = make(chan bool)
+	m.wg.Add(2)
+
+	// create and start agent watcher
+	go func(stopCh chan bool) {
+		tick := time.NewTicker(m.checkInterval)
+		for {
+			select {
+			case <-stopCh:
+				tick.Stop()
+				m.wg.Done()
+				return
+			case <-tick.C:
+				m.watchHypervisor()
+				m.watchAgent()
+			}
+		}
+	}(m.stopCh)
+
+	// create and start hypervisor exit watcher
+	go m.watchHypervisorExit(m.stopCh)
+}
+
+// suspend stops watching the agent and the hypervisor, the watchers
+// being kept, e.g. while the VM reboots and its hypervisor process is
+// replaced. It returns once the watching goroutines are done.
+func (m *monitor) suspend() {
+	m.Lock()
+	if !m.running {
+		m.Unlock()
+		return
+	}
+
+	close(m.stopCh)
+	m.running = false
+	m.suspended = true
+	m.Unlock()
+
+	m.wg.Wait()
+}
+
+// resume watches the agent and the hypervisor again after suspend(), the
+// exit of the current hypervisor process being waited for.
+func (m *monitor) resume() {
+	m.Lock()
+	defer m.Unlock()
+
+	if !m.suspended {
+		return
+	}
+	m.suspended = false
+
+	// the exit hook is run for the new hypervisor process
+	m.exitHookOnce = sync.Once{}
+	m.start()
+}
+
 func (m *monitor) notify(err error) {
 	m.sandbox.agent.markDead()
 
@@ -104,14 +158,18 @@ func (m *monitor) stop() {
 	m.Lock()
 	defer m.Unlock()
 
-	if !m.running {
+	if !m.running && len(m.watchers) == 0 {
 		return
 	}
 
-	m.stopCh <- true
+	// the monitor may have been suspended
+	if m.running {
+		close(m.stopCh)
+	}
 	defer func() {
 		m.watchers = nil
 		m.running = false
+		m.suspended = false
 	}()
 
 	// a watcher is not supposed to close the channel
@@ -128,6 +186,11 @@ func (m *monitor) stop() {
 }
 
 func (m *monitor) watchAgent() {
+	// the agent doesn't answer while the VM of an idle sandbox is paused
+	if m.sandbox.idlePaused() {
+		return
+	}
+
 	err := m.sandbox.agent.check()
 	if err != nil {
 		// TODO: define and export error types
@@ -138,7 +201,102 @@ func (m *monitor) watchAgent() {
 func (m *monitor) watchHypervisor() error {
 	if err := m.sandbox.hypervisor.check(); err != nil {
 		m.notify(errors.Wrapf(err, "failed to ping hypervisor process"))
+		m.runExitHook()
 		return err
 	}
 	return nil
 }
+
+func pidfdOpen(pid int) (int, error) {
+	fd, _, errno := unix.Syscall(unix.SYS_PIDFD_OPEN, uintptr(pid), 0, 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// watchHypervisorExit waits for the hypervisor process to exit, so that
+// the watchers are notified right away instead of at the next check.
+// The hypervisor is not always a child of the caller, a pidfd is used
+// to wait for it. Only the periodic checks are left if pidfds are not
+// supported by the host kernel.
+func (m *monitor) watchHypervisorExit(stopCh chan bool) {
+	defer m.wg.Done()
+
+	pids := m.sandbox.hypervisor.getPids()
+	if len(pids) == 0 || pids[0] <= 0 {
+		return
+	}
+	pid := pids[0]
+
+	fd, err := pidfdOpen(pid)
+	if err != nil {
+		virtLog.WithError(err).WithField("pid", pid).Debug("Could not watch hypervisor process exit")
+		return
+	}
+	defer unix.Close(fd)
+
+	fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+	for {
+		select {
+		case <-stopCh:
+			return
+		default:
+		}
+
+		// the pidfd is readable once the process exited, poll with
+		// a timeout to notice when the monitor is stopped.
+		n, err := unix.Poll(fds, int(m.checkInterval/time.Millisecond))
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			virtLog.WithError(err).WithField("pid", pid).Warn("Could not wait for hypervisor process exit")
+			return
+		}
+
+		if n > 0 {
+			err := fmt.Errorf("hypervisor process %d exited", pid)
+			m.sandbox.recordHypervisorExit(err.Error())
+			m.notify(err)
+			m.runExitHook()
+			return
+		}
+	}
+}
+
+// runExitHook runs the hypervisor exit hook of the sandbox, if any. It's
+// run at most once per monitor.
+func (m *monitor) runExitHook() {
+	hook := m.sandbox.config.HypervisorExitHook
+	if hook == "" {
+		return
+	}
+
+	m.exitHookOnce.Do(func() {
+		var pid int
+		if pids := m.sandbox.hypervisor.getPids(); len(pids) > 0 {
+			pid = pids[0]
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), hypervisorExitHookTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, hook, m.sandbox.id)
+		cmd.Env = append(os.Environ(),
+			fmt.Sprintf("KATA_SANDBOX_ID=%s", m.sandbox.id),
+			fmt.Sprintf("KATA_HYPERVISOR_PID=%d", pid))
+
+		logger := virtLog.WithFields(map[string]interface{}{
+			"sandbox": m.sandbox.id,
+			"hook":    hook,
+		})
+
+		if out, err := cmd.CombinedOutput(); err != nil {
+			logger.WithError(err).WithField("output", string(out)).Error("Hypervisor exit hook failed")
+			return
+		}
+
+		logger.Info("Hypervisor exit hook executed")
+	})
+}
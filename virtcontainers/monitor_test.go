@@ -7,8 +7,16 @@ package virtcontainers
 
 import (
 	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -58,3 +66,130 @@ func TestMonitorClosedChannel(t *testing.T) {
 
 	m.stop()
 }
+
+func TestMonitorHypervisorExit(t *testing.T) {
+	contID := "505"
+	contConfig := newTestContainerConfigNoop(contID)
+	hConfig := newHypervisorConfig(nil, nil)
+	assert := assert.New(t)
+
+	// create a sandbox
+	s, err := testCreateSandbox(t, testSandboxID, MockHypervisor, hConfig, NoopAgentType, NetworkConfig{}, []ContainerConfig{contConfig}, nil)
+	assert.NoError(err)
+	defer cleanUp()
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	hookOutput := filepath.Join(tmpdir, "hook.out")
+	hook := filepath.Join(tmpdir, "hook.sh")
+	err = ioutil.WriteFile(hook, []byte("#!/bin/sh\necho \"$1 $KATA_SANDBOX_ID\" >> "+hookOutput+"\n"), 0755)
+	assert.NoError(err)
+	s.config.HypervisorExitHook = hook
+
+	vmm := exec.Command("sleep", "30")
+	assert.NoError(vmm.Start())
+	s.hypervisor.(*mockHypervisor).mockPid = vmm.Process.Pid
+
+	m := newMonitor(s)
+	// make sure the exit is not detected by the periodic check
+	m.checkInterval = time.Hour
+
+	ch, err := m.newWatcher()
+	assert.Nil(err, "newWatcher failed: %v", err)
+
+	assert.NoError(vmm.Process.Kill())
+
+	select {
+	case err := <-ch:
+		assert.Error(err)
+		assert.Contains(err.Error(), "exited")
+	case <-time.After(10 * time.Second):
+		t.Fatal("hypervisor exit not detected")
+	}
+
+	// the exit is stored with the sandbox state
+	ss, _, err := s.newStore.FromDisk(s.id)
+	assert.NoError(err)
+	assert.Contains(ss.HypervisorExitReason, "exited")
+	assert.False(ss.HypervisorExitTime.IsZero())
+
+	vmm.Wait()
+	m.stop()
+
+	// the hook runs once
+	m.runExitHook()
+	out, err := ioutil.ReadFile(hookOutput)
+	assert.NoError(err)
+	assert.Equal(testSandboxID+" "+testSandboxID, strings.TrimSpace(string(out)))
+}
+
+// rebootingHypervisor replaces its process on reboot, as firecracker does.
+type rebootingHypervisor struct {
+	mockHypervisor
+	vmm *exec.Cmd
+}
+
+func (h *rebootingHypervisor) startVMM() error {
+	h.vmm = exec.Command("sleep", "30")
+	if err := h.vmm.Start(); err != nil {
+		return err
+	}
+	h.mockPid = h.vmm.Process.Pid
+	return nil
+}
+
+func (h *rebootingHypervisor) stopVMM() {
+	h.vmm.Process.Kill()
+	h.vmm.Wait()
+}
+
+func (h *rebootingHypervisor) rebootSandbox() error {
+	h.stopVMM()
+	return h.startVMM()
+}
+
+func TestMonitorSandboxReboot(t *testing.T) {
+	hConfig := newHypervisorConfig(nil, nil)
+	assert := assert.New(t)
+
+	s, err := testCreateSandbox(t, testSandboxID, MockHypervisor, hConfig, NoopAgentType, NetworkConfig{}, nil, nil)
+	assert.NoError(err)
+	defer cleanUp()
+
+	h := &rebootingHypervisor{}
+	assert.NoError(h.startVMM())
+	s.hypervisor = h
+	s.state.State = types.StateRunning
+
+	ch, err := s.Monitor()
+	assert.NoError(err)
+	defer s.monitor.stop()
+
+	// let the monitor wait for the first process
+	time.Sleep(s.monitor.checkInterval)
+
+	// the exit of the process replaced by the reboot isn't reported
+	assert.NoError(s.Reboot())
+	select {
+	case err := <-ch:
+		t.Fatalf("sandbox reported dead by its reboot: %v", err)
+	case <-time.After(2 * s.monitor.checkInterval):
+	}
+
+	// the new process is watched
+	h.stopVMM()
+	select {
+	case err := <-ch:
+		assert.Error(err)
+		assert.Contains(err.Error(), fmt.Sprintf("hypervisor process %d exited", h.mockPid))
+	case <-time.After(10 * time.Second):
+		t.Fatal("exit of the rebooted hypervisor not detected")
+	}
+
+	// only the exit of the new process is stored
+	ss, _, err := s.newStore.FromDisk(s.id)
+	assert.NoError(err)
+	assert.Equal(fmt.Sprintf("hypervisor process %d exited", h.mockPid), ss.HypervisorExitReason)
+}
@@ -9,6 +9,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -281,9 +282,30 @@ func bindMountContainerRootfs(ctx context.Context, sharedDir, sandboxID, cID, cR
 
 	rootfsDest := filepath.Join(sharedDir, sandboxID, cID, rootfsDir)
 
+	// The rootfs may already have been staged by PrewarmContainerImage,
+	// don't stack a second bind mount on top of it.
+	if isBindMountOf(cRootFs, rootfsDest) {
+		return nil
+	}
+
 	return bindMount(ctx, cRootFs, rootfsDest, readonly, "private")
 }
 
+// isBindMountOf returns true if destination is a bind mount of source.
+func isBindMountOf(source, destination string) bool {
+	srcInfo, err := os.Stat(source)
+	if err != nil {
+		return false
+	}
+
+	dstInfo, err := os.Stat(destination)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(srcInfo, dstInfo)
+}
+
 // Mount describes a container mount.
 type Mount struct {
 	Source      string
@@ -305,6 +327,15 @@ type Mount struct {
 	// VM in case this mount is a block device file or a directory
 	// backed by a block device.
 	BlockDeviceID string
+
+	// BlockFsType is the filesystem of the block device attached for
+	// a volume backed by a host filesystem, mounted by the agent rather
+	// than bind mounted.
+	BlockFsType string
+
+	// WatchedPath is the path in the guest a Kubernetes volume updated
+	// by the kubelet is copied to, rather than shared.
+	WatchedPath string
 }
 
 func isSymlink(path string) bool {
@@ -354,6 +385,26 @@ func bindUnmountAllRootfs(ctx context.Context, sharedDir string, sandbox *Sandbo
 			errors = merr.Append(errors, bindUnmountContainerRootfs(c.ctx, sharedDir, sandbox.id, c.id))
 		}
 	}
+
+	// Images prewarmed for containers that were never created are not
+	// tracked by the sandbox, look for them in the shared directory.
+	entries, err := ioutil.ReadDir(filepath.Join(sharedDir, sandbox.id))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			errors = merr.Append(errors, err)
+		}
+		return errors.ErrorOrNil()
+	}
+	for _, e := range entries {
+		if _, ok := sandbox.containers[e.Name()]; ok || !e.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(sharedDir, sandbox.id, e.Name(), rootfsDir)); err != nil {
+			continue
+		}
+		errors = merr.Append(errors, bindUnmountContainerRootfs(ctx, sharedDir, sandbox.id, e.Name()))
+	}
+
 	return errors.ErrorOrNil()
 }
 
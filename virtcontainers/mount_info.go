@@ -0,0 +1,270 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"path/filepath"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+)
+
+// The mechanisms by which the mounts of the containers reach the guest.
+const (
+	// MountMechanismSharedFS is a mount shared with the guest through
+	// the shared file system of the sandbox, virtio-fs or 9p.
+	MountMechanismSharedFS = "shared-fs"
+
+	// MountMechanismCopy is a file copied to the guest when the
+	// hypervisor doesn't support sharing a file system.
+	MountMechanismCopy = "copy"
+
+	// MountMechanismWatched is a Kubernetes volume copied to the guest
+	// and copied again when it's updated on the host.
+	MountMechanismWatched = "watched"
+
+	// MountMechanismBlock is a block device attached to the VM and
+	// mounted by the agent.
+	MountMechanismBlock = "block"
+
+	// MountMechanismEphemeral is a tmpfs created by the agent.
+	MountMechanismEphemeral = "ephemeral"
+
+	// MountMechanismLocal is a directory created by the agent.
+	MountMechanismLocal = "local"
+
+	// MountMechanismGuest is a mount the agent does from the OCI
+	// spec, e.g. proc or a tmpfs, nothing coming from the host.
+	MountMechanismGuest = "guest"
+
+	// MountMechanismNone is a mount of the host not passed to the guest.
+	MountMechanismNone = "none"
+)
+
+// The status of the mounts in the guest, the agent mounting the storages
+// and the mounts of a container when it creates it and unmounting them when
+// it removes it.
+const (
+	MountStatusMounted   = "mounted"
+	MountStatusUnmounted = "unmounted"
+	MountStatusIgnored   = "ignored"
+)
+
+// MountBlockDeviceInfo describes the block device backing a mount.
+type MountBlockDeviceInfo struct {
+	ID       string `json:"id"`
+	HostPath string `json:"host_path"`
+	VirtPath string `json:"virt_path,omitempty"`
+	PCIAddr  string `json:"pci_addr,omitempty"`
+	SCSIAddr string `json:"scsi_addr,omitempty"`
+	Serial   string `json:"serial,omitempty"`
+}
+
+// MountInfo describes how a mount of a container was translated to a mount
+// of the guest.
+type MountInfo struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Type        string `json:"type"`
+	Mechanism   string `json:"mechanism"`
+
+	// Reason is why the mount is not passed to the guest.
+	Reason string `json:"reason,omitempty"`
+
+	// HostPath is the path of the mount in the shared directory of the
+	// host, HostMounted whether it's still mounted there.
+	HostPath    string `json:"host_path,omitempty"`
+	HostMounted bool   `json:"host_mounted,omitempty"`
+
+	// GuestPath is the source of the mount in the guest.
+	GuestPath string `json:"guest_path,omitempty"`
+
+	BlockDevice *MountBlockDeviceInfo `json:"block_device,omitempty"`
+
+	// AgentStatus is the status of the mount in the guest.
+	AgentStatus string `json:"agent_status"`
+}
+
+// ContainerMountInfo describes the rootfs and the mounts of a container.
+type ContainerMountInfo struct {
+	ID     string      `json:"id"`
+	State  string      `json:"state"`
+	Rootfs MountInfo   `json:"rootfs"`
+	Mounts []MountInfo `json:"mounts"`
+}
+
+// SandboxMountInfo describes how the mounts of the containers of a sandbox
+// reach its guest, it's meant for debugging volumes not visible in the
+// containers.
+type SandboxMountInfo struct {
+	ID             string               `json:"id"`
+	SharedFS       string               `json:"shared_fs"`
+	HostSharedDir  string               `json:"host_shared_dir"`
+	GuestSharedDir string               `json:"guest_shared_dir"`
+	Containers     []ContainerMountInfo `json:"containers"`
+}
+
+// MountInfo returns how the mounts of the containers of the sandbox were
+// translated to mounts of the guest. The agent doesn't report the mounts
+// of the guest, their status in the guest is the one of the containers
+// they are mounted for.
+func (s *Sandbox) MountInfo() (SandboxMountInfo, error) {
+	hostSharedDir := filepath.Join(kataHostSharedDir(), s.id)
+
+	info := SandboxMountInfo{
+		ID:             s.id,
+		SharedFS:       s.config.HypervisorConfig.SharedFS,
+		HostSharedDir:  hostSharedDir,
+		GuestSharedDir: kataGuestSharedDir(),
+		Containers:     []ContainerMountInfo{},
+	}
+
+	caps := s.hypervisor.capabilities()
+	if !caps.IsFsSharingSupported() {
+		info.SharedFS = ""
+	} else if !fsShareSupported(s.hypervisor) {
+		info.SharedFS = config.NoSharedFS
+	} else if info.SharedFS == "" {
+		info.SharedFS = config.Virtio9P
+	}
+
+	mounts, err := treeMounts(hostSharedDir)
+	if err != nil {
+		return SandboxMountInfo{}, err
+	}
+
+	hostMounts := make(map[string]bool)
+	for _, m := range mounts {
+		hostMounts[m] = true
+	}
+
+	for _, c := range s.GetAllContainers() {
+		info.Containers = append(info.Containers, c.(*Container).mountInfo(hostSharedDir, hostMounts))
+	}
+
+	return info, nil
+}
+
+// agentMountStatus returns the status in the guest of the mounts of the
+// container.
+func (c *Container) agentMountStatus() string {
+	switch c.state.State {
+	case types.StateReady, types.StateRunning, types.StatePaused:
+		return MountStatusMounted
+	}
+
+	return MountStatusUnmounted
+}
+
+func (c *Container) mountInfo(hostSharedDir string, hostMounts map[string]bool) ContainerMountInfo {
+	status := c.agentMountStatus()
+	fsShare := fsShareSupported(c.sandbox.hypervisor)
+
+	info := ContainerMountInfo{
+		ID:    c.id,
+		State: string(c.state.State),
+		Rootfs: MountInfo{
+			Source:      c.rootFs.Source,
+			Destination: "/",
+			Type:        c.state.Fstype,
+			AgentStatus: status,
+		},
+		Mounts: []MountInfo{},
+	}
+
+	if c.state.BlockDeviceID != "" {
+		info.Rootfs.Mechanism = MountMechanismBlock
+		info.Rootfs.GuestPath = filepath.Join(kataGuestSharedDir(), c.id)
+		info.Rootfs.BlockDevice = c.blockDeviceInfo(c.state.BlockDeviceID)
+	} else {
+		info.Rootfs.Mechanism = MountMechanismSharedFS
+		info.Rootfs.HostPath = filepath.Join(hostSharedDir, c.id, rootfsDir)
+		info.Rootfs.HostMounted = hostMounts[info.Rootfs.HostPath]
+		info.Rootfs.GuestPath = filepath.Join(kataGuestSharedDir(), c.id, c.rootfsSuffix)
+	}
+
+	for _, m := range c.mounts {
+		mi := MountInfo{
+			Source:      m.Source,
+			Destination: m.Destination,
+			Type:        m.Type,
+			AgentStatus: status,
+		}
+
+		switch {
+		case m.Type == KataEphemeralDevType:
+			mi.Mechanism = MountMechanismEphemeral
+			mi.GuestPath = filepath.Join(ephemeralPath(), filepath.Base(m.Source))
+		case m.Type == KataLocalDevType:
+			mi.Mechanism = MountMechanismLocal
+			mi.GuestPath = filepath.Join(localStoragePath(c.sandbox, c.rootfsSuffix), filepath.Base(m.Source))
+		case m.Type != "bind":
+			mi.Mechanism = MountMechanismGuest
+		case m.BlockDeviceID != "":
+			mi.Mechanism = MountMechanismBlock
+			mi.BlockDevice = c.blockDeviceInfo(m.BlockDeviceID)
+		case m.WatchedPath != "":
+			mi.Mechanism = MountMechanismWatched
+			mi.GuestPath = m.WatchedPath
+		case m.HostPath != "":
+			mi.Mechanism = MountMechanismSharedFS
+			mi.HostPath = m.HostPath
+			mi.HostMounted = hostMounts[m.HostPath]
+			mi.GuestPath = filepath.Join(kataGuestSharedDir(), filepath.Base(m.HostPath))
+		case isSystemMount(m.Source):
+			mi.Mechanism = MountMechanismNone
+			mi.Reason = "system mount of the host"
+		case m.Destination == "/dev/shm":
+			mi.Mechanism = MountMechanismNone
+			mi.Reason = "shm is allocated in the guest"
+		case m.Destination == GuestDNSFile && c.sandbox.guestDNS():
+			mi.Mechanism = MountMechanismNone
+			mi.Reason = "generated by the agent"
+			mi.GuestPath = GuestDNSFile
+		case isHostDevice(m.Destination):
+			mi.Mechanism = MountMechanismNone
+			mi.Reason = "device of the host"
+		case !fsShare:
+			mi.Mechanism = MountMechanismCopy
+		default:
+			mi.Mechanism = MountMechanismNone
+			mi.Reason = "not shared with the guest"
+		}
+
+		if mi.Mechanism == MountMechanismNone {
+			mi.AgentStatus = MountStatusIgnored
+		}
+
+		info.Mounts = append(info.Mounts, mi)
+	}
+
+	return info
+}
+
+// blockDeviceInfo describes the block device identified by deviceID, only
+// its ID being known when the device manager doesn't have it.
+func (c *Container) blockDeviceInfo(deviceID string) *MountBlockDeviceInfo {
+	info := &MountBlockDeviceInfo{ID: deviceID}
+
+	if c.sandbox.devManager == nil {
+		return info
+	}
+
+	device := c.sandbox.devManager.GetDeviceByID(deviceID)
+	if device == nil {
+		return info
+	}
+
+	if drive, ok := device.GetDeviceInfo().(*config.BlockDrive); ok && drive != nil {
+		info.HostPath = drive.File
+		info.VirtPath = drive.VirtPath
+		info.PCIAddr = drive.PCIAddr
+		info.SCSIAddr = drive.SCSIAddr
+		info.Serial = drive.Serial
+	}
+
+	return info
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/api"
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/kata-containers/runtime/virtcontainers/device/drivers"
+	"github.com/kata-containers/runtime/virtcontainers/device/manager"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxMountInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedKataHostSharedDir := kataHostSharedDir
+	kataHostSharedDir = func() string {
+		return dir
+	}
+	savedMountInfoPath := mountInfoPath
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	defer func() {
+		kataHostSharedDir = savedKataHostSharedDir
+		mountInfoPath = savedMountInfoPath
+	}()
+
+	sharedDir := filepath.Join(dir, "100")
+	hostPath := filepath.Join(sharedDir, "100-abcd-data")
+	assert.NoError(ioutil.WriteFile(mountInfoPath, []byte(fmt.Sprintf(`22 1 0:21 / %s/100/rootfs rw shared:5 - overlay overlay rw
+23 1 0:22 / %s rw shared:5 - ext4 /dev/sda1 rw
+`, sharedDir, hostPath)), 0600))
+
+	dev := drivers.NewBlockDevice(&config.DeviceInfo{ID: "block"})
+	dev.BlockDrive = &config.BlockDrive{File: "/dev/sdb", PCIAddr: "01/02", Serial: "0123"}
+
+	s := &Sandbox{
+		id:         "100",
+		hypervisor: &mockHypervisor{},
+		devManager: manager.NewDeviceManager(manager.VirtioBlock, false, "", []api.Device{dev}),
+		ctx:        context.Background(),
+		config:     &SandboxConfig{},
+		containers: map[string]*Container{},
+	}
+
+	s.containers["100"] = &Container{
+		id:           "100",
+		sandbox:      s,
+		rootfsSuffix: "rootfs",
+		state:        types.ContainerState{State: types.StateRunning},
+		mounts: []Mount{
+			{Source: "/host/data", Destination: "/data", Type: "bind", HostPath: hostPath},
+			{Source: "/host/stale", Destination: "/stale", Type: "bind", HostPath: filepath.Join(sharedDir, "100-efgh-stale")},
+			{Source: "/dev/sdb", Destination: "/block", Type: "bind", BlockDeviceID: "block"},
+			{Source: "/sys/fs/cgroup", Destination: "/sys/fs/cgroup", Type: "bind"},
+			{Source: "/host/file", Destination: "/file", Type: "bind"},
+			{Source: "/host/scratch", Destination: "/scratch", Type: KataEphemeralDevType},
+			{Source: "proc", Destination: "/proc", Type: "proc"},
+		},
+	}
+
+	info, err := s.MountInfo()
+	assert.NoError(err)
+	assert.Equal("100", info.ID)
+	assert.Equal(sharedDir, info.HostSharedDir)
+	assert.Len(info.Containers, 1)
+
+	c := info.Containers[0]
+	assert.Equal(MountMechanismSharedFS, c.Rootfs.Mechanism)
+	assert.True(c.Rootfs.HostMounted)
+	assert.Equal(filepath.Join(kataGuestSharedDir(), "100", "rootfs"), c.Rootfs.GuestPath)
+
+	mounts := make(map[string]MountInfo)
+	for _, m := range c.Mounts {
+		mounts[m.Destination] = m
+	}
+
+	assert.Equal(MountMechanismSharedFS, mounts["/data"].Mechanism)
+	assert.True(mounts["/data"].HostMounted)
+	assert.Equal(filepath.Join(kataGuestSharedDir(), "100-abcd-data"), mounts["/data"].GuestPath)
+	assert.Equal(MountStatusMounted, mounts["/data"].AgentStatus)
+	assert.False(mounts["/stale"].HostMounted)
+
+	assert.Equal(MountMechanismBlock, mounts["/block"].Mechanism)
+	assert.Equal(&MountBlockDeviceInfo{ID: "block", HostPath: "/dev/sdb", PCIAddr: "01/02", Serial: "0123"}, mounts["/block"].BlockDevice)
+
+	assert.Equal(MountMechanismNone, mounts["/sys/fs/cgroup"].Mechanism)
+	assert.Equal(MountStatusIgnored, mounts["/sys/fs/cgroup"].AgentStatus)
+	assert.NotEmpty(mounts["/sys/fs/cgroup"].Reason)
+
+	// the mock hypervisor doesn't support sharing a file system
+	assert.Equal(MountMechanismCopy, mounts["/file"].Mechanism)
+	assert.Equal(MountMechanismEphemeral, mounts["/scratch"].Mechanism)
+	assert.Equal(MountMechanismGuest, mounts["/proc"].Mechanism)
+
+	// the agent unmounts the mounts of the stopped containers
+	s.containers["100"].state.State = types.StateStopped
+	info, err = s.MountInfo()
+	assert.NoError(err)
+	assert.Equal(MountStatusUnmounted, info.Containers[0].Mounts[0].AgentStatus)
+}
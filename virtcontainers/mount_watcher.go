@@ -0,0 +1,293 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// watchableVolumes are the kinds of Kubernetes volumes whose content the
+// kubelet updates while the pods run.
+var watchableVolumes = []string{
+	"kubernetes.io~configmap",
+	"kubernetes.io~secret",
+	"kubernetes.io~downward-api",
+	"kubernetes.io~projected",
+}
+
+// isWatchableMount returns whether source is a Kubernetes volume updated by
+// the kubelet, /var/lib/kubelet/pods/<pod>/volumes/<kind>/<name>. The subPath
+// mounts of these volumes are not updated.
+func isWatchableMount(source string) bool {
+	kind := filepath.Base(filepath.Dir(filepath.Clean(source)))
+	for _, v := range watchableVolumes {
+		if kind == v {
+			return true
+		}
+	}
+
+	return false
+}
+
+// watchablePath is the directory of the guest the watched mounts are copied
+// to, the agent only copying files under /run.
+func watchablePath() string {
+	return filepath.Join(kataGuestSandboxDir(), "watchable")
+}
+
+// watchedFile is the state of a file of a watched mount when it was last
+// copied.
+type watchedFile struct {
+	// path is the file the entry of the mount resolves to, the kubelet
+	// updating the volumes by switching the symlinks to a new directory.
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// watchedMount is a mount of a container copied to the guest and copied
+// again when its content changes.
+type watchedMount struct {
+	containerID string
+	source      string
+	guestPath   string
+	files       map[string]watchedFile
+}
+
+// regularFiles returns the regular files of source by their path relative
+// to it, following the symlinks and skipping the "..data" entries the
+// kubelet uses to update the volumes atomically.
+func regularFiles(source string) (map[string]watchedFile, error) {
+	files := make(map[string]watchedFile)
+
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		path, err := filepath.EvalSymlinks(filepath.Join(source, rel))
+		if err != nil {
+			return err
+		}
+
+		fi, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		if fi.Mode().IsRegular() {
+			files[rel] = watchedFile{
+				path:    path,
+				size:    fi.Size(),
+				modTime: fi.ModTime(),
+			}
+			return nil
+		}
+
+		if !fi.IsDir() {
+			return nil
+		}
+
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), "..") {
+				continue
+			}
+			if err := walk(filepath.Join(rel, e.Name())); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	if err := walk(""); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// mountWatcher periodically copies to the guest the files of the watched
+// mounts which changed on the host. The shared file systems don't always
+// propagate these updates, e.g. virtio-fs with cache=always, and the mounts
+// are only copied once when the hypervisor can't share a file system.
+type mountWatcher struct {
+	sync.Mutex
+
+	sandbox  *Sandbox
+	interval time.Duration
+	mounts   []*watchedMount
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newMountWatcher(s *Sandbox, interval time.Duration) *mountWatcher {
+	return &mountWatcher{
+		sandbox:  s,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (w *mountWatcher) start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+
+		tick := time.NewTicker(w.interval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-w.stopCh:
+				return
+			case <-tick.C:
+				w.syncMounts()
+			}
+		}
+	}()
+}
+
+func (w *mountWatcher) stop() {
+	close(w.stopCh)
+	w.wg.Wait()
+}
+
+func (w *mountWatcher) syncMounts() {
+	// the agent doesn't answer while the VM of an idle sandbox is paused
+	if w.sandbox.idlePaused() {
+		return
+	}
+
+	w.Lock()
+	defer w.Unlock()
+
+	for _, m := range w.mounts {
+		if err := w.syncMount(m); err != nil {
+			w.sandbox.Logger().WithError(err).WithFields(logrus.Fields{
+				"container": m.containerID,
+				"source":    m.source,
+			}).Warn("Could not update watched mount")
+		}
+	}
+}
+
+// syncMount copies to the guest the files of m which changed since they were
+// last copied. The agent can't remove files, the ones removed on the host
+// remain in the guest.
+func (w *mountWatcher) syncMount(m *watchedMount) error {
+	files, err := regularFiles(m.source)
+	if err != nil {
+		return err
+	}
+
+	for rel, f := range files {
+		if m.files[rel] == f {
+			continue
+		}
+
+		if err := w.sandbox.agent.copyFile(f.path, filepath.Join(m.guestPath, rel)); err != nil {
+			return err
+		}
+
+		if _, ok := m.files[rel]; ok {
+			w.sandbox.Logger().WithFields(logrus.Fields{
+				"container": m.containerID,
+				"file":      filepath.Join(m.source, rel),
+			}).Info("Watched file updated")
+		}
+
+		m.files[rel] = f
+	}
+
+	for rel := range m.files {
+		if _, ok := files[rel]; !ok {
+			w.sandbox.Logger().WithFields(logrus.Fields{
+				"container": m.containerID,
+				"file":      filepath.Join(m.source, rel),
+			}).Warn("Watched file removed on the host, it remains in the guest")
+			delete(m.files, rel)
+		}
+	}
+
+	return nil
+}
+
+// watchMount copies source to guestPath in the guest and watches it for
+// changes every MountWatchInterval seconds, until the mounts of the
+// container are unmounted or the VM is stopped. It returns false when source
+// has no file to copy.
+func (s *Sandbox) watchMount(containerID, source, guestPath string) (bool, error) {
+	if s.config.MountWatchInterval == 0 {
+		return false, fmt.Errorf("Mount watching is disabled")
+	}
+
+	if s.mountWatcher == nil {
+		s.mountWatcher = newMountWatcher(s, time.Duration(s.config.MountWatchInterval)*time.Second)
+		s.mountWatcher.start()
+	}
+
+	m := &watchedMount{
+		containerID: containerID,
+		source:      source,
+		guestPath:   guestPath,
+		files:       make(map[string]watchedFile),
+	}
+
+	w := s.mountWatcher
+	w.Lock()
+	defer w.Unlock()
+
+	if err := w.syncMount(m); err != nil {
+		return false, err
+	}
+
+	if len(m.files) == 0 {
+		return false, nil
+	}
+
+	w.mounts = append(w.mounts, m)
+
+	return true, nil
+}
+
+// unwatchMounts stops watching the mounts of the container.
+func (s *Sandbox) unwatchMounts(containerID string) {
+	if s.mountWatcher == nil {
+		return
+	}
+
+	w := s.mountWatcher
+	w.Lock()
+	defer w.Unlock()
+
+	var mounts []*watchedMount
+	for _, m := range w.mounts {
+		if m.containerID != containerID {
+			mounts = append(mounts, m)
+		}
+	}
+	w.mounts = mounts
+}
+
+func (s *Sandbox) stopMountWatcher() {
+	if s.mountWatcher == nil {
+		return
+	}
+
+	s.mountWatcher.stop()
+	s.mountWatcher = nil
+}
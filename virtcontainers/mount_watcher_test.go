@@ -0,0 +1,106 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mountWatcherAgent struct {
+	noopAgent
+	copies map[string]string
+}
+
+func (a *mountWatcherAgent) copyFile(src, dst string) error {
+	a.copies[dst] = src
+	return nil
+}
+
+func TestIsWatchableMount(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(isWatchableMount("/var/lib/kubelet/pods/1234/volumes/kubernetes.io~configmap/config"))
+	assert.True(isWatchableMount("/var/lib/kubelet/pods/1234/volumes/kubernetes.io~secret/token/"))
+	assert.True(isWatchableMount("/var/lib/kubelet/pods/1234/volumes/kubernetes.io~projected/kube-api-access"))
+	assert.False(isWatchableMount("/var/lib/kubelet/pods/1234/volumes/kubernetes.io~empty-dir/cache"))
+	assert.False(isWatchableMount("/var/lib/kubelet/pods/1234/volume-subpaths/config/app/0"))
+	assert.False(isWatchableMount("/etc/hosts"))
+}
+
+// writeKubeletVolume writes the files of a volume the way the kubelet does,
+// in a timestamped directory the "..data" symlink points to.
+func writeKubeletVolume(t *testing.T, dir, timestamp string, files map[string]string) {
+	data := filepath.Join(dir, timestamp)
+	assert.NoError(t, os.MkdirAll(data, DirMode))
+
+	for name, content := range files {
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(data, name), []byte(content), 0644))
+
+		link := filepath.Join(dir, name)
+		if _, err := os.Lstat(link); os.IsNotExist(err) {
+			assert.NoError(t, os.Symlink(filepath.Join("..data", name), link))
+		}
+	}
+
+	os.Remove(filepath.Join(dir, "..data"))
+	assert.NoError(t, os.Symlink(timestamp, filepath.Join(dir, "..data")))
+}
+
+func TestMountWatcher(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "kubernetes.io~configmap")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	writeKubeletVolume(t, dir, "..2020_01_01", map[string]string{"a": "1", "b": "2"})
+
+	agent := &mountWatcherAgent{copies: make(map[string]string)}
+	s := &Sandbox{
+		id:     testSandboxID,
+		agent:  agent,
+		config: &SandboxConfig{},
+	}
+
+	_, err = s.watchMount("container", dir, "/guest")
+	assert.Error(err)
+
+	s.config.MountWatchInterval = 3600
+	watched, err := s.watchMount("container", dir, "/guest")
+	assert.NoError(err)
+	assert.True(watched)
+	defer s.stopMountWatcher()
+
+	assert.Len(agent.copies, 2)
+	assert.Equal(filepath.Join(dir, "..2020_01_01", "a"), agent.copies["/guest/a"])
+
+	// only the updated files are copied
+	agent.copies = make(map[string]string)
+	s.mountWatcher.syncMounts()
+	assert.Empty(agent.copies)
+
+	writeKubeletVolume(t, dir, "..2020_01_02", map[string]string{"a": "1", "b": "3"})
+	s.mountWatcher.syncMounts()
+	assert.Len(agent.copies, 2)
+	assert.Equal(filepath.Join(dir, "..2020_01_02", "b"), agent.copies["/guest/b"])
+
+	s.unwatchMounts("container")
+	assert.Empty(s.mountWatcher.mounts)
+
+	// an empty volume is not watched
+	empty, err := ioutil.TempDir("", "kubernetes.io~secret")
+	assert.NoError(err)
+	defer os.RemoveAll(empty)
+
+	watched, err = s.watchMount("container", empty, "/guest")
+	assert.NoError(err)
+	assert.False(watched)
+}
@@ -25,6 +25,7 @@ import (
 	"github.com/vishvananda/netns"
 	"golang.org/x/sys/unix"
 
+	"github.com/kata-containers/runtime/virtcontainers/pkg/cni"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/uuid"
@@ -51,6 +52,11 @@ const (
 	// NetXConnectNoneModel can be used when the VM is in the host network namespace
 	NetXConnectNoneModel
 
+	// NetXConnectEBPFModel redirects traffic between the network interface
+	// and the tap interface like NetXConnectTCFilterModel, with tc bpf
+	// programs rather than u32 filters and mirred actions.
+	NetXConnectEBPFModel
+
 	// NetXConnectInvalidModel is the last item to check valid values by IsValid()
 	NetXConnectInvalidModel
 )
@@ -68,6 +74,8 @@ const (
 	tcFilterNetModelStr = "tcfilter"
 
 	noneNetModelStr = "none"
+
+	ebpfNetModelStr = "ebpf"
 )
 
 //SetModel change the model string value
@@ -85,6 +93,9 @@ func (n *NetInterworkingModel) SetModel(modelName string) error {
 	case noneNetModelStr:
 		*n = NetXConnectNoneModel
 		return nil
+	case ebpfNetModelStr:
+		*n = NetXConnectEBPFModel
+		return nil
 	}
 	return fmt.Errorf("Unknown type %s", modelName)
 }
@@ -98,6 +109,14 @@ var DefaultNetInterworkingModel = NetXConnectTCFilterModel
 const (
 	defaultFilePerms = 0600
 	defaultQlen      = 1500
+
+	// maxNetQueues is the maximum number of queues of tap and macvtap
+	// devices (MAX_TAP_QUEUES in the kernel)
+	maxNetQueues = 256
+
+	// minMTU and maxMTU are the MTU range of the ethernet devices
+	minMTU = 68
+	maxMTU = 65535
 )
 
 // DNSInfo describes the DNS setup related to a network interface.
@@ -137,6 +156,10 @@ type TapInterface struct {
 	TAPIface NetworkInterface
 	VMFds    []*os.File
 	VhostFds []*os.File
+
+	// DisabledOffloads are the offloads disabled on the tap device and
+	// on the virtio-net device of the VM, see setTapOffloads().
+	DisabledOffloads []string
 }
 
 // TuntapInterface defines a tap interface
@@ -152,13 +175,41 @@ type NetworkInterfacePair struct {
 	NetInterworkingModel
 }
 
+// CNIConfig is the configuration of the CNI plugins the runtime invokes to
+// set up the network namespaces it creates.
+type CNIConfig struct {
+	Enable  bool
+	ConfDir string
+	BinDirs []string
+}
+
 // NetworkConfig is the network configuration related to a network.
 type NetworkConfig struct {
 	NetNSPath         string
 	NetNsCreated      bool
 	DisableNewNetNs   bool
 	NetmonConfig      NetmonConfig
+	CNIConfig         CNIConfig
 	InterworkingModel NetInterworkingModel
+
+	// IngressBandwidth and EgressBandwidth limit in bits per second the
+	// traffic to and from the sandbox, 0 means unlimited.
+	IngressBandwidth uint64
+	EgressBandwidth  uint64
+
+	// VhostUserSocketPath is where the vhost-user sockets of the
+	// interfaces are looked for, %s being replaced by their addresses.
+	VhostUserSocketPath string
+
+	// MTU overrides the MTU of the interfaces of the sandbox, in the
+	// guest and on their tap devices, InterfaceMTUs overriding it by
+	// interface name. 0 keeps the MTU discovered in the network namespace.
+	MTU           int
+	InterfaceMTUs map[string]int
+
+	// ConntrackBypass untracks on the host the traffic of the veth
+	// endpoints using tc filters, see addConntrackBypass().
+	ConntrackBypass bool
 }
 
 func networkLogger() *logrus.Entry {
@@ -401,35 +452,86 @@ func getLinkByName(netHandle *netlink.Handle, name string, expectedLink netlink.
 	return nil, fmt.Errorf("Incorrect link type %s, expecting %s", link.Type(), expectedLink.Type())
 }
 
+// netQueues returns the number of queues of the network devices attached
+// to the VM when it boots, the configured one or one per vCPU if the
+// hypervisor supports multi queue devices, or 0 for single queue devices.
+func netQueues(h hypervisor) int {
+	caps := h.capabilities()
+	if !caps.IsMultiQueueSupported() {
+		return 0
+	}
+
+	config := h.hypervisorConfig()
+
+	queues := int(config.NetworkQueues)
+	if queues == 0 {
+		queues = int(config.NumVCPUs)
+	}
+	if queues > maxNetQueues {
+		queues = maxNetQueues
+	}
+
+	return queues
+}
+
+// hotplugNetQueues returns the number of queues of the network devices
+// hotplugged to the running VM. The queues of a device can't be changed
+// once it's plugged, so unless they are configured they are sized to the
+// current number of vCPUs, including the hotplugged ones.
+func hotplugNetQueues(h hypervisor) int {
+	queues := netQueues(h)
+	if queues == 0 || h.hypervisorConfig().NetworkQueues != 0 {
+		return queues
+	}
+
+	tids, err := h.getThreadIDs()
+	if err != nil {
+		networkLogger().WithError(err).Warn("Could not get the number of vCPUs, using the default one")
+		return queues
+	}
+
+	if vcpus := len(tids.vcpus); vcpus > queues {
+		queues = vcpus
+	}
+	if queues > maxNetQueues {
+		queues = maxNetQueues
+	}
+
+	return queues
+}
+
 // The endpoint type should dictate how the connection needs to happen.
-func xConnectVMNetwork(endpoint Endpoint, h hypervisor) error {
+func xConnectVMNetwork(endpoint Endpoint, h hypervisor, queues int) error {
 	netPair := endpoint.NetworkPair()
 
-	queues := 0
-	caps := h.capabilities()
-	if caps.IsMultiQueueSupported() {
-		queues = int(h.hypervisorConfig().NumVCPUs)
-	}
+	hconf := h.hypervisorConfig()
 
 	var disableVhostNet bool
 	if rootless.IsRootless() {
 		disableVhostNet = true
 	} else {
-		disableVhostNet = h.hypervisorConfig().DisableVhostNet
+		disableVhostNet = hconf.DisableVhostNet
 	}
 
 	if netPair.NetInterworkingModel == NetXConnectDefaultModel {
 		netPair.NetInterworkingModel = DefaultNetInterworkingModel
 	}
 
+	var err error
 	switch netPair.NetInterworkingModel {
 	case NetXConnectMacVtapModel:
-		return tapNetworkPair(endpoint, queues, disableVhostNet)
-	case NetXConnectTCFilterModel:
-		return setupTCFiltering(endpoint, queues, disableVhostNet)
+		err = tapNetworkPair(endpoint, queues, disableVhostNet)
+	case NetXConnectTCFilterModel, NetXConnectEBPFModel:
+		err = setupTCFiltering(endpoint, queues, disableVhostNet)
 	default:
 		return fmt.Errorf("Invalid internetworking model")
 	}
+
+	if err != nil {
+		return err
+	}
+
+	return setTapOffloads(&netPair.TapInterface, hconf.disabledNetOffloads(netPair.VirtIface.Name))
 }
 
 // The endpoint type should dictate how the disconnection needs to happen.
@@ -443,7 +545,7 @@ func xDisconnectVMNetwork(endpoint Endpoint) error {
 	switch netPair.NetInterworkingModel {
 	case NetXConnectMacVtapModel:
 		return untapNetworkPair(endpoint)
-	case NetXConnectTCFilterModel:
+	case NetXConnectTCFilterModel, NetXConnectEBPFModel:
 		return removeTCFiltering(endpoint)
 	default:
 		return fmt.Errorf("Invalid internetworking model")
@@ -569,8 +671,13 @@ func tapNetworkPair(endpoint Endpoint, queues int, disableVhostNet bool) error {
 	tapHardAddr := attrs.HardwareAddr
 	netPair.TAPIface.HardAddr = attrs.HardwareAddr.String()
 
-	if err := netHandle.LinkSetMTU(tapLink, attrs.MTU); err != nil {
-		return fmt.Errorf("Could not set TAP MTU %d: %s", attrs.MTU, err)
+	// the MTU of the interface in the guest, see interfaceMTU()
+	mtu := endpoint.Properties().Iface.MTU
+	if mtu == 0 {
+		mtu = attrs.MTU
+	}
+	if err := netHandle.LinkSetMTU(tapLink, mtu); err != nil {
+		return fmt.Errorf("Could not set TAP MTU %d: %s", mtu, err)
 	}
 
 	hardAddr, err := net.ParseMAC(netPair.VirtIface.HardAddr)
@@ -663,8 +770,13 @@ func setupTCFiltering(endpoint Endpoint, queues int, disableVhostNet bool) error
 	// to see traffic from this MAC address and not another one.
 	netPair.TAPIface.HardAddr = attrs.HardwareAddr.String()
 
-	if err := netHandle.LinkSetMTU(tapLink, attrs.MTU); err != nil {
-		return fmt.Errorf("Could not set TAP MTU %d: %s", attrs.MTU, err)
+	// the MTU of the interface in the guest, see interfaceMTU()
+	mtu := endpoint.Properties().Iface.MTU
+	if mtu == 0 {
+		mtu = attrs.MTU
+	}
+	if err := netHandle.LinkSetMTU(tapLink, mtu); err != nil {
+		return fmt.Errorf("Could not set TAP MTU %d: %s", mtu, err)
 	}
 
 	if err := netHandle.LinkSetUp(tapLink); err != nil {
@@ -681,11 +793,16 @@ func setupTCFiltering(endpoint Endpoint, queues int, disableVhostNet bool) error
 		return err
 	}
 
-	if err := addRedirectTCFilter(attrs.Index, tapAttrs.Index); err != nil {
+	addRedirectFilter := addRedirectTCFilter
+	if netPair.NetInterworkingModel == NetXConnectEBPFModel {
+		addRedirectFilter = addRedirectBPFFilter
+	}
+
+	if err := addRedirectFilter(attrs.Index, tapAttrs.Index); err != nil {
 		return err
 	}
 
-	if err := addRedirectTCFilter(tapAttrs.Index, attrs.Index); err != nil {
+	if err := addRedirectFilter(tapAttrs.Index, attrs.Index); err != nil {
 		return err
 	}
 
@@ -745,7 +862,7 @@ func addRedirectTCFilter(sourceIndex, destIndex int) error {
 	return nil
 }
 
-// removeRedirectTCFilter removes all tc u32 filters created on ingress qdisc for "link".
+// removeRedirectTCFilter removes all tc u32 and bpf filters created on ingress qdisc for "link".
 func removeRedirectTCFilter(link netlink.Link) error {
 	if link == nil {
 		return nil
@@ -758,13 +875,13 @@ func removeRedirectTCFilter(link netlink.Link) error {
 	}
 
 	for _, f := range filters {
-		u32, ok := f.(*netlink.U32)
-
-		if !ok {
+		switch f.(type) {
+		case *netlink.U32, *netlink.BpfFilter:
+		default:
 			continue
 		}
 
-		if err := netlink.FilterDel(u32); err != nil {
+		if err := netlink.FilterDel(f); err != nil {
 			return err
 		}
 	}
@@ -818,6 +935,10 @@ func untapNetworkPair(endpoint Endpoint) error {
 		return err
 	}
 
+	if err := removeBandwidthQdisc(netHandle, link); err != nil {
+		return err
+	}
+
 	hardAddr, err := net.ParseMAC(netPair.TAPIface.HardAddr)
 	if err != nil {
 		return err
@@ -871,6 +992,10 @@ func removeTCFiltering(endpoint Endpoint) error {
 		return err
 	}
 
+	if err := removeBandwidthQdisc(netHandle, link); err != nil {
+		return err
+	}
+
 	if err := netHandle.LinkSetDown(link); err != nil {
 		return fmt.Errorf("Could not disable veth %s: %s", netPair.VirtIface.Name, err)
 	}
@@ -921,6 +1046,10 @@ func doNetNS(netNSPath string, cb func(ns.NetNS) error) error {
 }
 
 func deleteNetNS(netNSPath string) error {
+	if err := rootless.StopSlirp4netns(netNSPath); err != nil {
+		return fmt.Errorf("Failed to stop slirp4netns for namespace %s: %v", netNSPath, err)
+	}
+
 	n, err := ns.GetNS(netNSPath)
 	if err != nil {
 		return err
@@ -1080,6 +1209,49 @@ func networkInfoFromLink(handle *netlink.Handle, link netlink.Link) (NetworkInfo
 	}, nil
 }
 
+// checkMTUs ensures the configured MTUs are within the range of the
+// ethernet devices.
+func (config *NetworkConfig) checkMTUs() error {
+	mtus := map[string]int{"": config.MTU}
+	for ifName, mtu := range config.InterfaceMTUs {
+		if ifName == "" {
+			return fmt.Errorf("Missing network interface name of MTU %d", mtu)
+		}
+		mtus[ifName] = mtu
+	}
+
+	for ifName, mtu := range mtus {
+		if mtu != 0 && (mtu < minMTU || mtu > maxMTU) {
+			return fmt.Errorf("Invalid MTU %d of interface %q, expecting a value between %d and %d", mtu, ifName, minMTU, maxMTU)
+		}
+	}
+
+	return nil
+}
+
+// interfaceMTU returns the MTU of the interface in the guest and of its tap
+// device. Unless overridden by the configuration, this is the MTU the CNI
+// plugins set on the interface in the network namespace, lowered to the
+// MTU of its routes as the guest doesn't get the MTU of the routes.
+func (config *NetworkConfig) interfaceMTU(netInfo NetworkInfo) int {
+	if mtu, ok := config.InterfaceMTUs[netInfo.Iface.Name]; ok && mtu != 0 {
+		return mtu
+	}
+
+	if config.MTU != 0 {
+		return config.MTU
+	}
+
+	mtu := netInfo.Iface.MTU
+	for _, r := range netInfo.Routes {
+		if r.MTU != 0 && (mtu == 0 || r.MTU < mtu) {
+			mtu = r.MTU
+		}
+	}
+
+	return mtu
+}
+
 func createEndpointsFromScan(networkNSPath string, config *NetworkConfig) ([]Endpoint, error) {
 	var endpoints []Endpoint
 
@@ -1125,8 +1297,17 @@ func createEndpointsFromScan(networkNSPath string, config *NetworkConfig) ([]End
 			continue
 		}
 
+		if mtu := config.interfaceMTU(netInfo); mtu != netInfo.Iface.MTU {
+			networkLogger().WithFields(logrus.Fields{
+				"interface": netInfo.Iface.Name,
+				"link-mtu":  netInfo.Iface.MTU,
+				"mtu":       mtu,
+			}).Info("Changing MTU of the interface in the guest")
+			netInfo.Iface.MTU = mtu
+		}
+
 		if err := doNetNS(networkNSPath, func(_ ns.NetNS) error {
-			endpoint, errCreate = createEndpoint(netInfo, idx, config.InterworkingModel, link)
+			endpoint, errCreate = createEndpoint(netInfo, idx, config, link)
 			return errCreate
 		}); err != nil {
 			return []Endpoint{}, err
@@ -1147,8 +1328,9 @@ func createEndpointsFromScan(networkNSPath string, config *NetworkConfig) ([]End
 	return endpoints, nil
 }
 
-func createEndpoint(netInfo NetworkInfo, idx int, model NetInterworkingModel, link netlink.Link) (Endpoint, error) {
+func createEndpoint(netInfo NetworkInfo, idx int, config *NetworkConfig, link netlink.Link) (Endpoint, error) {
 	var endpoint Endpoint
+	model := config.InterworkingModel
 	// TODO: This is the incoming interface
 	// based on the incoming interface we should create
 	// an appropriate EndPoint based on interface type
@@ -1168,7 +1350,7 @@ func createEndpoint(netInfo NetworkInfo, idx int, model NetInterworkingModel, li
 		var socketPath string
 
 		// Check if this is a dummy interface which has a vhost-user socket associated with it
-		socketPath, err = vhostUserSocketPath(netInfo)
+		socketPath, err = vhostUserSocketPath(netInfo, config.VhostUserSocketPath)
 		if err != nil {
 			return nil, err
 		}
@@ -1217,7 +1399,7 @@ type Network struct {
 }
 
 func (n *Network) trace(ctx context.Context, name string) (opentracing.Span, context.Context) {
-	span, ct := opentracing.StartSpanFromContext(ctx, name)
+	span, ct := startSpanFromContext(ctx, "network", name)
 
 	span.SetTag("subsystem", "network")
 	span.SetTag("type", "default")
@@ -1240,6 +1422,10 @@ func (n *Network) Add(ctx context.Context, config *NetworkConfig, hypervisor hyp
 	span, _ := n.trace(ctx, "add")
 	defer span.Finish()
 
+	if err := config.checkMTUs(); err != nil {
+		return []Endpoint{}, err
+	}
+
 	endpoints, err := createEndpointsFromScan(config.NetNSPath, config)
 	if err != nil {
 		return endpoints, err
@@ -1257,6 +1443,10 @@ func (n *Network) Add(ctx context.Context, config *NetworkConfig, hypervisor hyp
 					return err
 				}
 			}
+
+			if err := setupBandwidthLimits(endpoint, config.IngressBandwidth, config.EgressBandwidth); err != nil {
+				return err
+			}
 		}
 
 		return nil
@@ -1265,6 +1455,12 @@ func (n *Network) Add(ctx context.Context, config *NetworkConfig, hypervisor hyp
 		return []Endpoint{}, err
 	}
 
+	if config.ConntrackBypass {
+		if err := addConntrackBypass(config.NetNSPath, endpoints); err != nil {
+			return []Endpoint{}, err
+		}
+	}
+
 	networkLogger().Debug("Network added")
 
 	return endpoints, nil
@@ -1297,8 +1493,9 @@ func (n *Network) PostAdd(ctx context.Context, ns *NetworkNamespace, hotplug boo
 }
 
 // Remove network endpoints in the network namespace. It also deletes the network
-// namespace in case the namespace has been created by us.
-func (n *Network) Remove(ctx context.Context, ns *NetworkNamespace, hypervisor hypervisor) error {
+// namespace in case the namespace has been created by us, after the CNI plugins
+// which set it up tore it down.
+func (n *Network) Remove(ctx context.Context, ns *NetworkNamespace, config *NetworkConfig, hypervisor hypervisor) error {
 	span, _ := n.trace(ctx, "remove")
 	defer span.Finish()
 
@@ -1313,6 +1510,20 @@ func (n *Network) Remove(ctx context.Context, ns *NetworkNamespace, hypervisor h
 
 	networkLogger().Debug("Network removed")
 
+	if config.ConntrackBypass {
+		if err := removeConntrackBypass(ns.NetNsPath); err != nil {
+			networkLogger().WithError(err).WithField("netns", ns.NetNsPath).Warn("Connection tracking bypass removal failed")
+		}
+	}
+
+	if ns.NetNsCreated && config.CNIConfig.Enable {
+		if err := RemoveCNINetwork(ctx, ns.NetNsPath, config.CNIConfig); err != nil {
+			// the network namespace is deleted anyway, the leaked
+			// resources being those of the plugins
+			networkLogger().WithError(err).WithField("netns", ns.NetNsPath).Warn("CNI network teardown failed")
+		}
+	}
+
 	if ns.NetNsCreated {
 		networkLogger().Infof("Network namespace %q deleted", ns.NetNsPath)
 		return deleteNetNS(ns.NetNsPath)
@@ -1320,3 +1531,19 @@ func (n *Network) Remove(ctx context.Context, ns *NetworkNamespace, hypervisor h
 
 	return nil
 }
+
+// RemoveCNINetwork invokes the CNI plugins to tear down the network namespace
+// they set up, the namespace being left in place.
+func RemoveCNINetwork(ctx context.Context, netNSPath string, config CNIConfig) error {
+	network, err := cni.LoadNetwork(config.ConfDir)
+	if err != nil {
+		return err
+	}
+
+	networkLogger().WithFields(logrus.Fields{
+		"netns":   netNSPath,
+		"network": network.Name,
+	}).Info("Tearing down CNI network")
+
+	return network.Del(ctx, config.BinDirs, netNSPath)
+}
@@ -0,0 +1,163 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+// The offload flags of the TUNSETOFFLOAD ioctl, from linux/if_tun.h.
+const (
+	tunFCsum   = 0x01
+	tunFTSO4   = 0x02
+	tunFTSO6   = 0x04
+	tunFTSOECN = 0x08
+	tunFUFO    = 0x10
+)
+
+// netOffload is an offload of the tap devices, with the virtio-net features
+// letting the guest use it on receive and on transmit. The offload is only
+// enabled along with one of the offloads it requires.
+type netOffload struct {
+	name     string
+	flag     int
+	requires int
+	features []string
+}
+
+// netOffloads are the offloads which can be disabled, in the order they
+// are enabled.
+var netOffloads = []netOffload{
+	{"csum", tunFCsum, 0, []string{"csum", "guest_csum"}},
+	{"tso4", tunFTSO4, tunFCsum, []string{"host_tso4", "guest_tso4"}},
+	{"tso6", tunFTSO6, tunFCsum, []string{"host_tso6", "guest_tso6"}},
+	{"ecn", tunFTSOECN, tunFTSO4 | tunFTSO6, []string{"host_ecn", "guest_ecn"}},
+	{"ufo", tunFUFO, tunFCsum, []string{"host_ufo", "guest_ufo"}},
+}
+
+// tunSetOffload is mocked by the tests.
+var tunSetOffload = func(fd uintptr, flags int) error {
+	return unix.IoctlSetInt(int(fd), unix.TUNSETOFFLOAD, flags)
+}
+
+// checkNetOffloads ensures the offloads are known.
+func checkNetOffloads(offloads []string) error {
+	for _, name := range offloads {
+		known := false
+		for _, o := range netOffloads {
+			if o.name == name {
+				known = true
+				break
+			}
+		}
+
+		if !known {
+			return fmt.Errorf("Unknown network offload %q, expecting one of csum, tso4, tso6, ecn or ufo", name)
+		}
+	}
+
+	return nil
+}
+
+// checkNetOffloads ensures the disabled offloads of the sandbox and of its
+// interfaces are known.
+func (conf *HypervisorConfig) checkNetOffloads() error {
+	if err := checkNetOffloads(conf.DisableNetOffloads); err != nil {
+		return err
+	}
+
+	for ifName, offloads := range conf.InterfaceNetOffloads {
+		if ifName == "" {
+			return fmt.Errorf("Missing network interface name of disabled offloads %v", offloads)
+		}
+
+		if err := checkNetOffloads(offloads); err != nil {
+			return fmt.Errorf("Interface %s: %v", ifName, err)
+		}
+	}
+
+	return nil
+}
+
+// disabledNetOffloads returns the offloads disabled on the tap device of
+// the network interface.
+func (conf *HypervisorConfig) disabledNetOffloads(ifName string) []string {
+	if offloads, ok := conf.InterfaceNetOffloads[ifName]; ok {
+		return offloads
+	}
+
+	return conf.DisableNetOffloads
+}
+
+// setTapOffloads enables the offloads of the tap device but the disabled
+// ones, through its first queue, and saves the offloads left disabled. The
+// offloads the device doesn't support are disabled as well, as the guest
+// would use them otherwise.
+func setTapOffloads(tap *TapInterface, disabled []string) error {
+	// the offloads of the taps the hypervisor opens itself are left alone
+	probe := len(tap.VMFds) > 0
+
+	flags := 0
+	tap.DisabledOffloads = nil
+	for _, o := range netOffloads {
+		enable := o.requires == 0 || flags&o.requires != 0
+		for _, name := range disabled {
+			if name == o.name {
+				enable = false
+				break
+			}
+		}
+
+		if enable && probe {
+			err := tunSetOffload(tap.VMFds[0].Fd(), flags|o.flag)
+			if err != nil && err != unix.EINVAL {
+				return fmt.Errorf("Could not set offloads of tap %s: %v", tap.TAPIface.Name, err)
+			}
+
+			if err != nil {
+				networkLogger().WithFields(logrus.Fields{
+					"tap":     tap.TAPIface.Name,
+					"offload": o.name,
+				}).Warn("Network offload not supported by the tap device, disabling it")
+				enable = false
+			}
+		}
+
+		if enable {
+			flags |= o.flag
+		} else {
+			tap.DisabledOffloads = append(tap.DisabledOffloads, o.name)
+		}
+	}
+
+	if probe {
+		if err := tunSetOffload(tap.VMFds[0].Fd(), flags); err != nil {
+			return fmt.Errorf("Could not set offloads of tap %s: %v", tap.TAPIface.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// netOffloadFeatures returns the virtio-net features to turn off for the
+// disabled offloads.
+func netOffloadFeatures(disabled []string) []string {
+	var features []string
+
+	for _, o := range netOffloads {
+		for _, name := range disabled {
+			if name == o.name {
+				features = append(features, o.features...)
+				break
+			}
+		}
+	}
+
+	return features
+}
@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestCheckNetOffloads(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := &HypervisorConfig{}
+	assert.NoError(conf.checkNetOffloads())
+
+	conf.DisableNetOffloads = []string{"csum", "tso4", "tso6", "ecn", "ufo"}
+	conf.InterfaceNetOffloads = map[string][]string{"eth1": nil}
+	assert.NoError(conf.checkNetOffloads())
+
+	conf.InterfaceNetOffloads["eth2"] = []string{"gso"}
+	assert.Error(conf.checkNetOffloads())
+
+	conf.InterfaceNetOffloads = map[string][]string{"": {"tso4"}}
+	assert.Error(conf.checkNetOffloads())
+
+	conf.InterfaceNetOffloads = nil
+	conf.DisableNetOffloads = []string{"TSO4"}
+	assert.Error(conf.checkNetOffloads())
+}
+
+func TestDisabledNetOffloads(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := &HypervisorConfig{
+		DisableNetOffloads: []string{"ufo"},
+		InterfaceNetOffloads: map[string][]string{
+			"eth1": nil,
+			"eth2": {"tso4", "tso6"},
+		},
+	}
+
+	assert.Equal([]string{"ufo"}, conf.disabledNetOffloads("eth0"))
+	assert.Empty(conf.disabledNetOffloads("eth1"))
+	assert.Equal([]string{"tso4", "tso6"}, conf.disabledNetOffloads("eth2"))
+}
+
+func TestSetTapOffloads(t *testing.T) {
+	assert := assert.New(t)
+
+	savedTunSetOffload := tunSetOffload
+	defer func() {
+		tunSetOffload = savedTunSetOffload
+	}()
+
+	var flags int
+	tunSetOffload = func(fd uintptr, f int) error {
+		// UFO is not supported
+		if f&tunFUFO != 0 {
+			return unix.EINVAL
+		}
+		flags = f
+		return nil
+	}
+
+	f, err := os.Open(os.DevNull)
+	assert.NoError(err)
+	defer f.Close()
+
+	tap := &TapInterface{
+		TAPIface: NetworkInterface{Name: "tap0_kata"},
+		VMFds:    []*os.File{f},
+	}
+
+	assert.NoError(setTapOffloads(tap, nil))
+	assert.Equal(tunFCsum|tunFTSO4|tunFTSO6|tunFTSOECN, flags)
+	assert.Equal([]string{"ufo"}, tap.DisabledOffloads)
+
+	// ECN requires a TSO offload
+	assert.NoError(setTapOffloads(tap, []string{"tso4", "tso6"}))
+	assert.Equal(tunFCsum, flags)
+	assert.Equal([]string{"tso4", "tso6", "ecn", "ufo"}, tap.DisabledOffloads)
+
+	// the segmentation offloads require the checksum offload
+	assert.NoError(setTapOffloads(tap, []string{"csum"}))
+	assert.Equal(0, flags)
+	assert.Equal([]string{"csum", "tso4", "tso6", "ecn", "ufo"}, tap.DisabledOffloads)
+
+	tunSetOffload = func(fd uintptr, f int) error {
+		return unix.EBADF
+	}
+	assert.Error(setTapOffloads(tap, nil))
+
+	// the taps without queues are not probed
+	tap.VMFds = nil
+	assert.NoError(setTapOffloads(tap, []string{"tso6"}))
+	assert.Equal([]string{"tso6"}, tap.DisabledOffloads)
+}
+
+func TestNetOffloadFeatures(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(netOffloadFeatures(nil))
+	assert.Equal([]string{"csum", "guest_csum", "host_ufo", "guest_ufo"}, netOffloadFeatures([]string{"ufo", "csum"}))
+}
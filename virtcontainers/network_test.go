@@ -14,6 +14,7 @@ import (
 
 	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
 	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/vishvananda/netlink"
 )
@@ -127,6 +128,7 @@ func TestNetInterworkingModelIsValid(t *testing.T) {
 		{"Default Model", NetXConnectDefaultModel, true},
 		{"TC Filter Model", NetXConnectTCFilterModel, true},
 		{"Macvtap Model", NetXConnectMacVtapModel, true},
+		{"eBPF Model", NetXConnectEBPFModel, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -149,6 +151,7 @@ func TestNetInterworkingModelSetModel(t *testing.T) {
 		{"macvtap Model", macvtapNetModelStr, false},
 		{"tcfilter Model", tcFilterNetModelStr, false},
 		{"none Model", noneNetModelStr, false},
+		{"ebpf Model", ebpfNetModelStr, false},
 	}
 
 	for _, tt := range tests {
@@ -178,6 +181,111 @@ func TestGenerateRandomPrivateMacAdd(t *testing.T) {
 	assert.NotEqual(addr1, addr2)
 }
 
+type multiQueueHypervisor struct {
+	mockHypervisor
+	numVCPUs    uint32
+	multiQueue  bool
+	onlineVCPUs int
+	queues      uint32
+}
+
+func (m *multiQueueHypervisor) capabilities() types.Capabilities {
+	caps := types.Capabilities{}
+	if m.multiQueue {
+		caps.SetMultiQueueSupport()
+	}
+	return caps
+}
+
+func (m *multiQueueHypervisor) hypervisorConfig() HypervisorConfig {
+	return HypervisorConfig{NumVCPUs: m.numVCPUs, NetworkQueues: m.queues}
+}
+
+func (m *multiQueueHypervisor) getThreadIDs() (vcpuThreadIDs, error) {
+	tids := vcpuThreadIDs{vcpus: make(map[int]int)}
+	for i := 0; i < m.onlineVCPUs; i++ {
+		tids.vcpus[i] = i
+	}
+	return tids, nil
+}
+
+func TestNetQueues(t *testing.T) {
+	assert := assert.New(t)
+
+	h := &multiQueueHypervisor{numVCPUs: 4, onlineVCPUs: 6}
+	assert.Equal(0, netQueues(h))
+	assert.Equal(0, hotplugNetQueues(h))
+
+	h.multiQueue = true
+	assert.Equal(4, netQueues(h))
+	assert.Equal(6, hotplugNetQueues(h))
+
+	// the boot vCPUs are used when no vCPU is reported
+	h.onlineVCPUs = 0
+	assert.Equal(4, hotplugNetQueues(h))
+
+	h.numVCPUs = 1024
+	h.onlineVCPUs = 1024
+	assert.Equal(maxNetQueues, netQueues(h))
+	assert.Equal(maxNetQueues, hotplugNetQueues(h))
+
+	// configured queues don't follow the vCPUs
+	h.queues = 2
+	assert.Equal(2, netQueues(h))
+	assert.Equal(2, hotplugNetQueues(h))
+
+	h.queues = 1024
+	assert.Equal(maxNetQueues, netQueues(h))
+}
+
+func TestCheckMTUs(t *testing.T) {
+	assert := assert.New(t)
+
+	config := &NetworkConfig{}
+	assert.NoError(config.checkMTUs())
+
+	config.MTU = 1450
+	config.InterfaceMTUs = map[string]int{"eth1": 9000, "eth2": 0}
+	assert.NoError(config.checkMTUs())
+
+	config.MTU = 67
+	assert.Error(config.checkMTUs())
+
+	config.MTU = 0
+	config.InterfaceMTUs["eth1"] = 65536
+	assert.Error(config.checkMTUs())
+
+	config.InterfaceMTUs = map[string]int{"": 1500}
+	assert.Error(config.checkMTUs())
+}
+
+func TestInterfaceMTU(t *testing.T) {
+	assert := assert.New(t)
+
+	netInfo := NetworkInfo{
+		Iface: NetlinkIface{
+			LinkAttrs: netlink.LinkAttrs{Name: "eth0", MTU: 1500},
+		},
+		Routes: []netlink.Route{{}, {MTU: 1450}, {MTU: 1480}},
+	}
+
+	// the MTU of the routes lowers the MTU of the interface
+	config := &NetworkConfig{}
+	assert.Equal(1450, config.interfaceMTU(netInfo))
+
+	netInfo.Routes = nil
+	assert.Equal(1500, config.interfaceMTU(netInfo))
+
+	config.MTU = 1400
+	assert.Equal(1400, config.interfaceMTU(netInfo))
+
+	config.InterfaceMTUs = map[string]int{"eth0": 9000, "eth1": 1300}
+	assert.Equal(9000, config.interfaceMTU(netInfo))
+
+	netInfo.Iface.Name = "eth2"
+	assert.Equal(1400, config.interfaceMTU(netInfo))
+}
+
 func TestCreateGetTunTapLink(t *testing.T) {
 	if tc.NotValid(ktu.NeedRoot()) {
 		t.Skip(testDisabledAsNonRoot)
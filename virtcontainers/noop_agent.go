@@ -61,6 +61,16 @@ func (n *noopAgent) startSandbox(sandbox *Sandbox) error {
 	return nil
 }
 
+// restartSandbox is the Noop agent Sandbox restarting implementation. It does nothing.
+func (n *noopAgent) restartSandbox(sandbox *Sandbox) error {
+	return nil
+}
+
+// resumeMigratedSandbox is the Noop agent migrated Sandbox resuming implementation. It does nothing.
+func (n *noopAgent) resumeMigratedSandbox(sandbox *Sandbox) error {
+	return nil
+}
+
 // stopSandbox is the Noop agent Sandbox stopping implementation. It does nothing.
 func (n *noopAgent) stopSandbox(sandbox *Sandbox) error {
 	return nil
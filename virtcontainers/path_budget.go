@@ -0,0 +1,102 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kata-containers/runtime/virtcontainers/utils"
+)
+
+// The sandbox IDs are picked by the container managers and have no length
+// limit, while the paths of the unix sockets of a sandbox must stay within
+// UNIX_PATH_MAX and the jailer IDs within 64 characters. The directories
+// of a sandbox are named after its ID shortened to the budget of their
+// paths, the shortened IDs ending with a hash of the whole ID for the
+// directories of the sandboxes sharing a prefix to stay apart.
+
+const (
+	// pathIDHashLen is the number of hex digits of the hash ending the
+	// shortened IDs.
+	pathIDHashLen = 8
+
+	// vmSocketNameMaxLen is the budget of the names of the sockets
+	// created in the VM storage directory of a sandbox, the longest being
+	// the vsock tunnels ones, e.g. "vsock-1024.sock".
+	vmSocketNameMaxLen = 24
+
+	// pathIDFile is the file recording the whole sandbox ID in the
+	// directories named after a shortened ID.
+	pathIDFile = "sandbox-id"
+)
+
+// shortenPathID returns the ID when it fits in max characters, or its
+// prefix followed by the hash of the whole ID otherwise. The hash alone
+// is returned when there's no room for a prefix.
+func shortenPathID(id string, max int) string {
+	if len(id) <= max {
+		return id
+	}
+
+	sum := sha256.Sum256([]byte(id))
+	hash := hex.EncodeToString(sum[:])[:pathIDHashLen]
+
+	if max < pathIDHashLen+2 {
+		return hash
+	}
+
+	return fmt.Sprintf("%s-%s", id[:max-pathIDHashLen-1], hash)
+}
+
+// pathIDBudget returns the number of characters left for an ID naming a
+// directory of dir, for the paths of the files of that directory, whose
+// names have up to nameLen characters, to fit in UNIX_PATH_MAX.
+func pathIDBudget(dir string, nameLen int) int {
+	// the two path separators around the ID
+	return utils.MaxSocketPathLen - len(dir) - nameLen - 2
+}
+
+// vmStorageID returns the name of the VM storage directory of a sandbox,
+// i.e. its ID shortened for the sockets of the directory to fit in
+// UNIX_PATH_MAX.
+func vmStorageID(vmStoragePath, id string) string {
+	return shortenPathID(id, pathIDBudget(vmStoragePath, vmSocketNameMaxLen))
+}
+
+// VMStorageDir returns the VM storage directory of a sandbox, holding the
+// sockets of its VM.
+func VMStorageDir(vmStoragePath, id string) string {
+	return filepath.Join(vmStoragePath, vmStorageID(vmStoragePath, id))
+}
+
+// recordPathID records the sandbox ID in dir when its name is a shortened
+// ID, for the directory to be mapped back to its sandbox. It fails when the
+// directory belongs to another sandbox, whose ID shortens to the same one.
+func recordPathID(dir, id string) error {
+	if filepath.Base(dir) == id {
+		return nil
+	}
+
+	path := filepath.Join(dir, pathIDFile)
+
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		if owner := string(data); owner != id {
+			return fmt.Errorf("%s is already used by sandbox %s", dir, owner)
+		}
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	return ioutil.WriteFile(path, []byte(id), 0640)
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShortenPathID(t *testing.T) {
+	assert := assert.New(t)
+
+	id := "3ef98eb7c6416be11e0accfed2f4e6560e07f8e33fa8d31922fd4d61747d7ead"
+
+	assert.Equal(id, shortenPathID(id, len(id)))
+
+	short := shortenPathID(id, 32)
+	assert.Len(short, 32)
+	assert.True(strings.HasPrefix(short, id[:32-pathIDHashLen-1]+"-"))
+	assert.Equal(short, shortenPathID(id, 32))
+
+	// the IDs sharing a prefix are shortened apart
+	assert.NotEqual(short, shortenPathID(id+"0", 32))
+
+	// only the hash when there's no room for a prefix
+	assert.Len(shortenPathID(id, 4), pathIDHashLen)
+}
+
+func TestVMStorageDir(t *testing.T) {
+	assert := assert.New(t)
+
+	root := "/run/vc/vm"
+
+	id := "3ef98eb7c6416be11e0accfed2f4e6560e07f8e33fa8d31922fd4d61747d7ead"
+	assert.Equal(filepath.Join(root, id), VMStorageDir(root, id))
+
+	long := strings.Repeat(id, 2)
+	dir := VMStorageDir(root, long)
+	assert.NotEqual(filepath.Join(root, long), dir)
+	assert.True(len(filepath.Join(dir, "vsock-4294967295.sock")) <= utils.MaxSocketPathLen)
+
+	_, err := utils.BuildSocketPath(dir, consoleSocket)
+	assert.NoError(err)
+}
+
+func TestRecordPathID(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	// nothing is recorded when the directory is named after the ID
+	dir := filepath.Join(tmpdir, "sandbox")
+	assert.NoError(os.Mkdir(dir, DirMode))
+	assert.NoError(recordPathID(dir, "sandbox"))
+	_, err = os.Stat(filepath.Join(dir, pathIDFile))
+	assert.True(os.IsNotExist(err))
+
+	dir = filepath.Join(tmpdir, "sandbox-12345678")
+	assert.NoError(os.Mkdir(dir, DirMode))
+	assert.NoError(recordPathID(dir, "sandbox-with-a-long-id"))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, pathIDFile))
+	assert.NoError(err)
+	assert.Equal("sandbox-with-a-long-id", string(data))
+
+	// recording it again is fine
+	assert.NoError(recordPathID(dir, "sandbox-with-a-long-id"))
+
+	// another sandbox can't use the directory
+	assert.Error(recordPathID(dir, "sandbox-with-another-long-id"))
+}
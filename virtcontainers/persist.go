@@ -38,6 +38,21 @@ func (s *Sandbox) dumpState(ss *persistapi.SandboxState, cs map[string]persistap
 	ss.State = string(s.state.State)
 	ss.CgroupPath = s.state.CgroupPath
 	ss.CgroupPaths = s.state.CgroupPaths
+	ss.VSockChannels = s.state.VSockChannels
+	ss.ScratchDeviceID = s.state.ScratchDeviceID
+	ss.PrewarmedDevices = s.state.PrewarmedDevices
+	ss.BootTimeline = persistapi.BootTimeline(s.state.BootTimeline)
+	ss.HypervisorExitReason = s.state.HypervisorExitReason
+	ss.HypervisorExitTime = s.state.HypervisorExitTime
+	ss.Transitions = nil
+	for _, t := range s.state.Transitions {
+		ss.Transitions = append(ss.Transitions, persistapi.StateTransition{
+			From:     string(t.From),
+			To:       string(t.To),
+			Time:     t.Time,
+			Reverted: t.Reverted,
+		})
+	}
 
 	for id, cont := range s.containers {
 		state := persistapi.ContainerState{}
@@ -63,6 +78,10 @@ func (s *Sandbox) dumpState(ss *persistapi.SandboxState, cs map[string]persistap
 
 func (s *Sandbox) dumpHypervisor(ss *persistapi.SandboxState) {
 	ss.HypervisorState = s.hypervisor.save()
+	if ss.HypervisorState.Pid > 0 {
+		// the orphaned VM trees are told apart with it
+		ss.HypervisorState.PidStartTime, _ = processStartTime(ss.HypervisorState.Pid)
+	}
 	// BlockIndexMap will be moved from sandbox state to hypervisor state later
 	ss.HypervisorState.BlockIndexMap = s.state.BlockIndexMap
 }
@@ -144,6 +163,8 @@ func (s *Sandbox) dumpMounts(cs map[string]persistapi.ContainerState) {
 				HostPath:      m.HostPath,
 				ReadOnly:      m.ReadOnly,
 				BlockDeviceID: m.BlockDeviceID,
+				BlockFsType:   m.BlockFsType,
+				WatchedPath:   m.WatchedPath,
 			})
 		}
 
@@ -187,19 +208,50 @@ func (s *Sandbox) dumpConfig(ss *persistapi.SandboxState) {
 		},
 		ShimType: string(sconfig.ShimType),
 		NetworkConfig: persistapi.NetworkConfig{
-			NetNSPath:         sconfig.NetworkConfig.NetNSPath,
-			NetNsCreated:      sconfig.NetworkConfig.NetNsCreated,
-			DisableNewNetNs:   sconfig.NetworkConfig.DisableNewNetNs,
-			InterworkingModel: int(sconfig.NetworkConfig.InterworkingModel),
+			NetNSPath:           sconfig.NetworkConfig.NetNSPath,
+			NetNsCreated:        sconfig.NetworkConfig.NetNsCreated,
+			DisableNewNetNs:     sconfig.NetworkConfig.DisableNewNetNs,
+			InterworkingModel:   int(sconfig.NetworkConfig.InterworkingModel),
+			IngressBandwidth:    sconfig.NetworkConfig.IngressBandwidth,
+			EgressBandwidth:     sconfig.NetworkConfig.EgressBandwidth,
+			VhostUserSocketPath: sconfig.NetworkConfig.VhostUserSocketPath,
+			CNIConfig: persistapi.CNIConfig{
+				Enable:  sconfig.NetworkConfig.CNIConfig.Enable,
+				ConfDir: sconfig.NetworkConfig.CNIConfig.ConfDir,
+				BinDirs: sconfig.NetworkConfig.CNIConfig.BinDirs,
+			},
+			MTU:           sconfig.NetworkConfig.MTU,
+			InterfaceMTUs: sconfig.NetworkConfig.InterfaceMTUs,
+
+			ConntrackBypass: sconfig.NetworkConfig.ConntrackBypass,
 		},
 
-		ShmSize:             sconfig.ShmSize,
-		SharePidNs:          sconfig.SharePidNs,
-		Stateful:            sconfig.Stateful,
-		SystemdCgroup:       sconfig.SystemdCgroup,
-		SandboxCgroupOnly:   sconfig.SandboxCgroupOnly,
-		DisableGuestSeccomp: sconfig.DisableGuestSeccomp,
-		Cgroups:             sconfig.Cgroups,
+		ShmSize:                   sconfig.ShmSize,
+		SharePidNs:                sconfig.SharePidNs,
+		Stateful:                  sconfig.Stateful,
+		SystemdCgroup:             sconfig.SystemdCgroup,
+		SandboxCgroupOnly:         sconfig.SandboxCgroupOnly,
+		DisableGuestSeccomp:       sconfig.DisableGuestSeccomp,
+		DisableGuestSELinux:       sconfig.DisableGuestSELinux,
+		DisableGuestAppArmor:      sconfig.DisableGuestAppArmor,
+		PrivilegedDeviceAllowList: sconfig.PrivilegedDeviceAllowList,
+		SandboxTmpQuota:           sconfig.SandboxTmpQuota,
+		ScratchDiskSize:           sconfig.ScratchDiskSize,
+		VCPUIsolation:             string(sconfig.VCPUIsolation),
+		StatsVMMOverhead:          sconfig.StatsVMMOverhead,
+		GuestTimeSyncInterval:     sconfig.GuestTimeSyncInterval,
+		MountWatchInterval:        sconfig.MountWatchInterval,
+		IdlePauseTimeout:          sconfig.IdlePauseTimeout,
+		StaticResourceMgmt:        sconfig.StaticResourceMgmt,
+		StaticMemoryMgmt:          sconfig.StaticMemoryMgmt,
+		HypervisorExitHook:        sconfig.HypervisorExitHook,
+		AuditLog:                  sconfig.AuditLog,
+		NetSysctlAllowList:        sconfig.NetSysctlAllowList,
+		SandboxLogDir:             sconfig.SandboxLogDir,
+		SandboxLogFormat:          sconfig.SandboxLogFormat,
+		GuestDNS:                  sconfig.GuestDNS,
+		DNS:                       persistapi.DNSConfig(sconfig.DNS),
+		Cgroups:                   sconfig.Cgroups,
 	}
 
 	for _, e := range sconfig.Experimental {
@@ -209,6 +261,11 @@ func (s *Sandbox) dumpConfig(ss *persistapi.SandboxState) {
 	ss.Config.HypervisorConfig = persistapi.HypervisorConfig{
 		NumVCPUs:                sconfig.HypervisorConfig.NumVCPUs,
 		DefaultMaxVCPUs:         sconfig.HypervisorConfig.DefaultMaxVCPUs,
+		CPUFeatures:             sconfig.HypervisorConfig.CPUFeatures,
+		EnableGuestSMT:          sconfig.HypervisorConfig.EnableGuestSMT,
+		CPUSockets:              sconfig.HypervisorConfig.CPUSockets,
+		CPUCores:                sconfig.HypervisorConfig.CPUCores,
+		CPUThreads:              sconfig.HypervisorConfig.CPUThreads,
 		MemorySize:              sconfig.HypervisorConfig.MemorySize,
 		DefaultBridges:          sconfig.HypervisorConfig.DefaultBridges,
 		Msize9p:                 sconfig.HypervisorConfig.Msize9p,
@@ -224,6 +281,7 @@ func (s *Sandbox) dumpConfig(ss *persistapi.SandboxState) {
 		HypervisorPath:          sconfig.HypervisorConfig.HypervisorPath,
 		HypervisorCtlPath:       sconfig.HypervisorConfig.HypervisorCtlPath,
 		JailerPath:              sconfig.HypervisorConfig.JailerPath,
+		JailerChrootBase:        sconfig.HypervisorConfig.JailerChrootBase,
 		BlockDeviceDriver:       sconfig.HypervisorConfig.BlockDeviceDriver,
 		HypervisorMachineType:   sconfig.HypervisorConfig.HypervisorMachineType,
 		MemoryPath:              sconfig.HypervisorConfig.MemoryPath,
@@ -233,29 +291,54 @@ func (s *Sandbox) dumpConfig(ss *persistapi.SandboxState) {
 		VirtioFSDaemon:          sconfig.HypervisorConfig.VirtioFSDaemon,
 		VirtioFSCache:           sconfig.HypervisorConfig.VirtioFSCache,
 		VirtioFSExtraArgs:       sconfig.HypervisorConfig.VirtioFSExtraArgs[:],
+		VirtioFSThreadPoolSize:  sconfig.HypervisorConfig.VirtioFSThreadPoolSize,
+		VirtioFSMaxRestarts:     sconfig.HypervisorConfig.VirtioFSMaxRestarts,
 		BlockDeviceCacheSet:     sconfig.HypervisorConfig.BlockDeviceCacheSet,
 		BlockDeviceCacheDirect:  sconfig.HypervisorConfig.BlockDeviceCacheDirect,
 		BlockDeviceCacheNoflush: sconfig.HypervisorConfig.BlockDeviceCacheNoflush,
+		BlockDeviceQueues:       sconfig.HypervisorConfig.BlockDeviceQueues,
+		BlockDeviceNaming:       sconfig.HypervisorConfig.BlockDeviceNaming,
 		DisableBlockDeviceUse:   sconfig.HypervisorConfig.DisableBlockDeviceUse,
+		BlockVolumeThreshold:    sconfig.HypervisorConfig.BlockVolumeThreshold,
 		EnableIOThreads:         sconfig.HypervisorConfig.EnableIOThreads,
 		Debug:                   sconfig.HypervisorConfig.Debug,
 		MemPrealloc:             sconfig.HypervisorConfig.MemPrealloc,
 		HugePages:               sconfig.HypervisorConfig.HugePages,
+		BootProfile:             string(sconfig.HypervisorConfig.BootProfile),
 		FileBackedMemRootDir:    sconfig.HypervisorConfig.FileBackedMemRootDir,
 		Realtime:                sconfig.HypervisorConfig.Realtime,
 		Mlock:                   sconfig.HypervisorConfig.Mlock,
 		DisableNestingChecks:    sconfig.HypervisorConfig.DisableNestingChecks,
 		UseVSock:                sconfig.HypervisorConfig.UseVSock,
 		DisableImageNvdimm:      sconfig.HypervisorConfig.DisableImageNvdimm,
+		UsePmemRootfs:           sconfig.HypervisorConfig.UsePmemRootfs,
 		HotplugVFIOOnRootBus:    sconfig.HypervisorConfig.HotplugVFIOOnRootBus,
 		PCIeRootPort:            sconfig.HypervisorConfig.PCIeRootPort,
 		BootToBeTemplate:        sconfig.HypervisorConfig.BootToBeTemplate,
 		BootFromTemplate:        sconfig.HypervisorConfig.BootFromTemplate,
+		IncomingMigrationURI:    sconfig.HypervisorConfig.IncomingMigrationURI,
 		DisableVhostNet:         sconfig.HypervisorConfig.DisableVhostNet,
+		NetworkQueues:           sconfig.HypervisorConfig.NetworkQueues,
 		EnableVhostUserStore:    sconfig.HypervisorConfig.EnableVhostUserStore,
 		VhostUserStorePath:      sconfig.HypervisorConfig.VhostUserStorePath,
 		GuestHookPath:           sconfig.HypervisorConfig.GuestHookPath,
+		VMMAPITimeout:           sconfig.HypervisorConfig.VMMAPITimeout,
+		BootTimeout:             sconfig.HypervisorConfig.BootTimeout,
+		ShutdownTimeout:         sconfig.HypervisorConfig.ShutdownTimeout,
+		VMMLogLevel:             sconfig.HypervisorConfig.VMMLogLevel,
+		VMMLogDir:               sconfig.HypervisorConfig.VMMLogDir,
+		VMMForwardMetrics:       sconfig.HypervisorConfig.VMMForwardMetrics,
+		CPUTemplate:             sconfig.HypervisorConfig.CPUTemplate,
+		VSockChannels:           sconfig.HypervisorConfig.VSockChannels,
+		ConfidentialGuest:       sconfig.HypervisorConfig.ConfidentialGuest,
+		SEVPolicy:               sconfig.HypervisorConfig.SEVPolicy,
+		EmulatorThreadsCPUs:     sconfig.HypervisorConfig.EmulatorThreadsCPUs,
+		EmulatorThreadsNice:     sconfig.HypervisorConfig.EmulatorThreadsNice,
+		EmulatorRTPriority:      sconfig.HypervisorConfig.EmulatorRTPriority,
 		VMid:                    sconfig.HypervisorConfig.VMid,
+
+		DisableNetOffloads:   sconfig.HypervisorConfig.DisableNetOffloads,
+		InterfaceNetOffloads: sconfig.HypervisorConfig.InterfaceNetOffloads,
 	}
 
 	if sconfig.AgentType == "kata" {
@@ -265,8 +348,14 @@ func (s *Sandbox) dumpConfig(ss *persistapi.SandboxState) {
 			s.Logger().WithError(err).Error("internal error: KataAgentConfig failed to decode")
 		} else {
 			ss.Config.KataAgentConfig = &persistapi.KataAgentConfig{
-				LongLiveConn: sagent.LongLiveConn,
-				UseVSock:     sagent.UseVSock,
+				LongLiveConn:   sagent.LongLiveConn,
+				UseVSock:       sagent.UseVSock,
+				DialTimeout:    sagent.DialTimeout,
+				RequestTimeout: sagent.RequestTimeout,
+				RequestRetries: sagent.RequestRetries,
+				RetryDelay:     sagent.RetryDelay,
+
+				DebugConsoleEnabled: sagent.DebugConsoleEnabled,
 			}
 		}
 	}
@@ -324,6 +413,21 @@ func (s *Sandbox) loadState(ss persistapi.SandboxState) {
 	s.state.State = types.StateString(ss.State)
 	s.state.CgroupPath = ss.CgroupPath
 	s.state.CgroupPaths = ss.CgroupPaths
+	s.state.VSockChannels = ss.VSockChannels
+	s.state.ScratchDeviceID = ss.ScratchDeviceID
+	s.state.PrewarmedDevices = ss.PrewarmedDevices
+	s.state.BootTimeline = types.BootTimeline(ss.BootTimeline)
+	s.state.HypervisorExitReason = ss.HypervisorExitReason
+	s.state.HypervisorExitTime = ss.HypervisorExitTime
+	s.state.Transitions = nil
+	for _, t := range ss.Transitions {
+		s.state.Transitions = append(s.state.Transitions, types.StateTransition{
+			From:     types.StateString(t.From),
+			To:       types.StateString(t.To),
+			Time:     t.Time,
+			Reverted: t.Reverted,
+		})
+	}
 	s.state.GuestMemoryHotplugProbe = ss.GuestMemoryHotplugProbe
 }
 
@@ -373,6 +477,8 @@ func (c *Container) loadContMounts(cs persistapi.ContainerState) {
 			HostPath:      m.HostPath,
 			ReadOnly:      m.ReadOnly,
 			BlockDeviceID: m.BlockDeviceID,
+			BlockFsType:   m.BlockFsType,
+			WatchedPath:   m.WatchedPath,
 		})
 	}
 }
@@ -475,19 +581,50 @@ func loadSandboxConfig(id string) (*SandboxConfig, error) {
 		},
 		ShimType: ShimType(savedConf.ShimType),
 		NetworkConfig: NetworkConfig{
-			NetNSPath:         savedConf.NetworkConfig.NetNSPath,
-			NetNsCreated:      savedConf.NetworkConfig.NetNsCreated,
-			DisableNewNetNs:   savedConf.NetworkConfig.DisableNewNetNs,
-			InterworkingModel: NetInterworkingModel(savedConf.NetworkConfig.InterworkingModel),
+			NetNSPath:           savedConf.NetworkConfig.NetNSPath,
+			NetNsCreated:        savedConf.NetworkConfig.NetNsCreated,
+			DisableNewNetNs:     savedConf.NetworkConfig.DisableNewNetNs,
+			InterworkingModel:   NetInterworkingModel(savedConf.NetworkConfig.InterworkingModel),
+			IngressBandwidth:    savedConf.NetworkConfig.IngressBandwidth,
+			EgressBandwidth:     savedConf.NetworkConfig.EgressBandwidth,
+			VhostUserSocketPath: savedConf.NetworkConfig.VhostUserSocketPath,
+			CNIConfig: CNIConfig{
+				Enable:  savedConf.NetworkConfig.CNIConfig.Enable,
+				ConfDir: savedConf.NetworkConfig.CNIConfig.ConfDir,
+				BinDirs: savedConf.NetworkConfig.CNIConfig.BinDirs,
+			},
+			MTU:           savedConf.NetworkConfig.MTU,
+			InterfaceMTUs: savedConf.NetworkConfig.InterfaceMTUs,
+
+			ConntrackBypass: savedConf.NetworkConfig.ConntrackBypass,
 		},
 
-		ShmSize:             savedConf.ShmSize,
-		SharePidNs:          savedConf.SharePidNs,
-		Stateful:            savedConf.Stateful,
-		SystemdCgroup:       savedConf.SystemdCgroup,
-		SandboxCgroupOnly:   savedConf.SandboxCgroupOnly,
-		DisableGuestSeccomp: savedConf.DisableGuestSeccomp,
-		Cgroups:             savedConf.Cgroups,
+		ShmSize:                   savedConf.ShmSize,
+		SharePidNs:                savedConf.SharePidNs,
+		Stateful:                  savedConf.Stateful,
+		SystemdCgroup:             savedConf.SystemdCgroup,
+		SandboxCgroupOnly:         savedConf.SandboxCgroupOnly,
+		DisableGuestSeccomp:       savedConf.DisableGuestSeccomp,
+		DisableGuestSELinux:       savedConf.DisableGuestSELinux,
+		DisableGuestAppArmor:      savedConf.DisableGuestAppArmor,
+		PrivilegedDeviceAllowList: savedConf.PrivilegedDeviceAllowList,
+		SandboxTmpQuota:           savedConf.SandboxTmpQuota,
+		ScratchDiskSize:           savedConf.ScratchDiskSize,
+		VCPUIsolation:             VCPUIsolation(savedConf.VCPUIsolation),
+		StatsVMMOverhead:          savedConf.StatsVMMOverhead,
+		GuestTimeSyncInterval:     savedConf.GuestTimeSyncInterval,
+		MountWatchInterval:        savedConf.MountWatchInterval,
+		IdlePauseTimeout:          savedConf.IdlePauseTimeout,
+		StaticResourceMgmt:        savedConf.StaticResourceMgmt,
+		StaticMemoryMgmt:          savedConf.StaticMemoryMgmt,
+		HypervisorExitHook:        savedConf.HypervisorExitHook,
+		AuditLog:                  savedConf.AuditLog,
+		NetSysctlAllowList:        savedConf.NetSysctlAllowList,
+		SandboxLogDir:             savedConf.SandboxLogDir,
+		SandboxLogFormat:          savedConf.SandboxLogFormat,
+		GuestDNS:                  savedConf.GuestDNS,
+		DNS:                       DNSConfig(savedConf.DNS),
+		Cgroups:                   savedConf.Cgroups,
 	}
 
 	for _, name := range savedConf.Experimental {
@@ -498,6 +635,11 @@ func loadSandboxConfig(id string) (*SandboxConfig, error) {
 	sconfig.HypervisorConfig = HypervisorConfig{
 		NumVCPUs:                hconf.NumVCPUs,
 		DefaultMaxVCPUs:         hconf.DefaultMaxVCPUs,
+		CPUFeatures:             hconf.CPUFeatures,
+		EnableGuestSMT:          hconf.EnableGuestSMT,
+		CPUSockets:              hconf.CPUSockets,
+		CPUCores:                hconf.CPUCores,
+		CPUThreads:              hconf.CPUThreads,
 		MemorySize:              hconf.MemorySize,
 		DefaultBridges:          hconf.DefaultBridges,
 		Msize9p:                 hconf.Msize9p,
@@ -513,6 +655,7 @@ func loadSandboxConfig(id string) (*SandboxConfig, error) {
 		HypervisorPath:          hconf.HypervisorPath,
 		HypervisorCtlPath:       hconf.HypervisorCtlPath,
 		JailerPath:              hconf.JailerPath,
+		JailerChrootBase:        hconf.JailerChrootBase,
 		BlockDeviceDriver:       hconf.BlockDeviceDriver,
 		HypervisorMachineType:   hconf.HypervisorMachineType,
 		MemoryPath:              hconf.MemoryPath,
@@ -522,35 +665,66 @@ func loadSandboxConfig(id string) (*SandboxConfig, error) {
 		VirtioFSDaemon:          hconf.VirtioFSDaemon,
 		VirtioFSCache:           hconf.VirtioFSCache,
 		VirtioFSExtraArgs:       hconf.VirtioFSExtraArgs[:],
+		VirtioFSThreadPoolSize:  hconf.VirtioFSThreadPoolSize,
+		VirtioFSMaxRestarts:     hconf.VirtioFSMaxRestarts,
 		BlockDeviceCacheSet:     hconf.BlockDeviceCacheSet,
 		BlockDeviceCacheDirect:  hconf.BlockDeviceCacheDirect,
 		BlockDeviceCacheNoflush: hconf.BlockDeviceCacheNoflush,
+		BlockDeviceQueues:       hconf.BlockDeviceQueues,
+		BlockDeviceNaming:       hconf.BlockDeviceNaming,
 		DisableBlockDeviceUse:   hconf.DisableBlockDeviceUse,
+		BlockVolumeThreshold:    hconf.BlockVolumeThreshold,
 		EnableIOThreads:         hconf.EnableIOThreads,
 		Debug:                   hconf.Debug,
 		MemPrealloc:             hconf.MemPrealloc,
 		HugePages:               hconf.HugePages,
+		BootProfile:             BootProfile(hconf.BootProfile),
 		FileBackedMemRootDir:    hconf.FileBackedMemRootDir,
 		Realtime:                hconf.Realtime,
 		Mlock:                   hconf.Mlock,
 		DisableNestingChecks:    hconf.DisableNestingChecks,
 		UseVSock:                hconf.UseVSock,
 		DisableImageNvdimm:      hconf.DisableImageNvdimm,
+		UsePmemRootfs:           hconf.UsePmemRootfs,
 		HotplugVFIOOnRootBus:    hconf.HotplugVFIOOnRootBus,
 		PCIeRootPort:            hconf.PCIeRootPort,
 		BootToBeTemplate:        hconf.BootToBeTemplate,
 		BootFromTemplate:        hconf.BootFromTemplate,
+		IncomingMigrationURI:    hconf.IncomingMigrationURI,
 		DisableVhostNet:         hconf.DisableVhostNet,
+		NetworkQueues:           hconf.NetworkQueues,
 		EnableVhostUserStore:    hconf.EnableVhostUserStore,
 		VhostUserStorePath:      hconf.VhostUserStorePath,
 		GuestHookPath:           hconf.GuestHookPath,
+		VMMAPITimeout:           hconf.VMMAPITimeout,
+		BootTimeout:             hconf.BootTimeout,
+		ShutdownTimeout:         hconf.ShutdownTimeout,
+		VMMLogLevel:             hconf.VMMLogLevel,
+		VMMLogDir:               hconf.VMMLogDir,
+		VMMForwardMetrics:       hconf.VMMForwardMetrics,
+		CPUTemplate:             hconf.CPUTemplate,
+		VSockChannels:           hconf.VSockChannels,
+		ConfidentialGuest:       hconf.ConfidentialGuest,
+		SEVPolicy:               hconf.SEVPolicy,
+		EmulatorThreadsCPUs:     hconf.EmulatorThreadsCPUs,
+		EmulatorThreadsNice:     hconf.EmulatorThreadsNice,
+		EmulatorRTPriority:      hconf.EmulatorRTPriority,
 		VMid:                    hconf.VMid,
+
+		DisableNetOffloads:   hconf.DisableNetOffloads,
+		InterfaceNetOffloads: hconf.InterfaceNetOffloads,
 	}
 
 	if savedConf.AgentType == "kata" {
 		sconfig.AgentConfig = KataAgentConfig{
-			LongLiveConn: savedConf.KataAgentConfig.LongLiveConn,
-			UseVSock:     savedConf.KataAgentConfig.UseVSock,
+			LongLiveConn:   savedConf.KataAgentConfig.LongLiveConn,
+			UseVSock:       savedConf.KataAgentConfig.UseVSock,
+			DialTimeout:    savedConf.KataAgentConfig.DialTimeout,
+			RequestTimeout: savedConf.KataAgentConfig.RequestTimeout,
+			RequestRetries: savedConf.KataAgentConfig.RequestRetries,
+			RetryDelay:     savedConf.KataAgentConfig.RetryDelay,
+
+			DebugConsoleEnabled: savedConf.KataAgentConfig.DebugConsoleEnabled,
 		}
 	}
 
@@ -19,6 +19,17 @@ type HypervisorConfig struct {
 	//DefaultMaxVCPUs specifies the maximum number of vCPUs for the VM.
 	DefaultMaxVCPUs uint32
 
+	// CPUFeatures lists the CPU features enabled or disabled in the guest
+	CPUFeatures string
+
+	// EnableGuestSMT exposes the vCPUs as hyperthreads in the guest
+	EnableGuestSMT bool
+
+	// CPUSockets, CPUCores and CPUThreads are the guest CPU topology
+	CPUSockets uint32
+	CPUCores   uint32
+	CPUThreads uint32
+
 	// DefaultMem specifies default memory size in MiB for the VM.
 	MemorySize uint32
 
@@ -63,6 +74,9 @@ type HypervisorConfig struct {
 	// JailerPath is the jailer executable host path.
 	JailerPath string
 
+	// JailerChrootBase is the directory under which the jailed VM is set up
+	JailerChrootBase string
+
 	// BlockDeviceDriver specifies the driver to be used for block device
 	// either VirtioSCSI or VirtioBlock with the default driver being defaultBlockDriver
 	BlockDeviceDriver string
@@ -97,6 +111,14 @@ type HypervisorConfig struct {
 	// VirtioFSExtraArgs passes options to virtiofsd daemon
 	VirtioFSExtraArgs []string
 
+	// VirtioFSThreadPoolSize is the number of threads of virtiofsd
+	// handling the requests of the guest, 0 for its default.
+	VirtioFSThreadPoolSize uint32
+
+	// VirtioFSMaxRestarts is how many times virtiofsd is restarted after
+	// crashing before stopping the sandbox.
+	VirtioFSMaxRestarts uint32
+
 	// File based memory backend root directory
 	FileBackedMemRootDir string
 
@@ -111,9 +133,19 @@ type HypervisorConfig struct {
 	// Denotes whether flush requests for the device are ignored.
 	BlockDeviceCacheNoflush bool
 
+	// BlockDeviceQueues is the number of queues of the virtio-blk drives
+	BlockDeviceQueues uint32
+
+	// BlockDeviceNaming is how the agent finds the drives hot added
+	BlockDeviceNaming string
+
 	// DisableBlockDeviceUse disallows a block device from being used.
 	DisableBlockDeviceUse bool
 
+	// BlockVolumeThreshold is the size in MiB from which the read-only
+	// volumes backed by a host block device are attached as block devices
+	BlockVolumeThreshold uint32
+
 	// EnableIOThreads enables IO to be processed in a separate thread.
 	// Supported currently for virtio-scsi driver.
 	EnableIOThreads bool
@@ -128,6 +160,9 @@ type HypervisorConfig struct {
 	// HugePages specifies if the memory should be pre-allocated from huge pages
 	HugePages bool
 
+	// BootProfile selects the optional work done to boot the VM
+	BootProfile string
+
 	// VirtioMem is used to enable/disable virtio-mem
 	VirtioMem bool
 
@@ -149,6 +184,9 @@ type HypervisorConfig struct {
 	// DisableImageNvdimm disables nvdimm for guest rootfs image
 	DisableImageNvdimm bool
 
+	// UsePmemRootfs exposes the guest rootfs image as a persistent memory device
+	UsePmemRootfs bool
+
 	// HotplugVFIOOnRootBus is used to indicate if devices need to be hotplugged on the
 	// root bus instead of a bridge.
 	HotplugVFIOOnRootBus bool
@@ -163,9 +201,21 @@ type HypervisorConfig struct {
 	// BootFromTemplate used to indicate if the VM should be created from a template VM
 	BootFromTemplate bool
 
+	// IncomingMigrationURI is the URI of the migration stream the VM is
+	// restored from instead of being booted
+	IncomingMigrationURI string
+
 	// DisableVhostNet is used to indicate if host supports vhost_net
 	DisableVhostNet bool
 
+	// NetworkQueues is the number of queues of the network devices
+	NetworkQueues uint32
+
+	// DisableNetOffloads and InterfaceNetOffloads are the offloads
+	// disabled on the tap devices, by default and per interface
+	DisableNetOffloads   []string
+	InterfaceNetOffloads map[string][]string
+
 	// EnableVhostUserStore is used to indicate if host supports vhost-user-blk/scsi
 	EnableVhostUserStore bool
 
@@ -176,16 +226,60 @@ type HypervisorConfig struct {
 	// GuestHookPath is the path within the VM that will be used for 'drop-in' hooks
 	GuestHookPath string
 
+	// VMMAPITimeout, BootTimeout and ShutdownTimeout are the hypervisor
+	// timeouts in seconds
+	VMMAPITimeout   uint32
+	BootTimeout     uint32
+	ShutdownTimeout uint32
+
+	// VMMLogLevel, VMMLogDir and VMMForwardMetrics configure the VMM
+	// logger
+	VMMLogLevel       string
+	VMMLogDir         string
+	VMMForwardMetrics bool
+
+	// CPUTemplate is the firecracker CPU template
+	CPUTemplate string
+
+	// VSockChannels are the guest vsock ports reachable from the host
+	VSockChannels map[string]uint32
+
+	// ConfidentialGuest encrypts the VM memory, SEVPolicy being the
+	// policy of the AMD SEV guests
+	ConfidentialGuest bool
+	SEVPolicy         uint32
+
+	// EmulatorThreadsCPUs, EmulatorThreadsNice and EmulatorRTPriority
+	// pin and prioritize the hypervisor threads other than the vCPUs
+	EmulatorThreadsCPUs string
+	EmulatorThreadsNice int32
+	EmulatorRTPriority  uint32
+
 	// VMid is the id of the VM that create the hypervisor if the VM is created by the factory.
 	// VMid is "" if the hypervisor is not created by the factory.
 	VMid string
 }
 
+// DNSConfig is the DNS configuration of the sandbox
+type DNSConfig struct {
+	Servers  []string
+	Searches []string
+	Options  []string
+}
+
 // KataAgentConfig is a structure storing information needed
 // to reach the Kata Containers agent.
 type KataAgentConfig struct {
 	LongLiveConn bool
 	UseVSock     bool
+
+	// the timeouts are in seconds, the retry delay in milliseconds
+	DialTimeout    uint32
+	RequestTimeout uint32
+	RequestRetries uint32
+	RetryDelay     uint32
+
+	DebugConsoleEnabled bool
 }
 
 // ProxyConfig is a structure storing information needed from any
@@ -202,12 +296,26 @@ type ShimConfig struct {
 	Debug bool
 }
 
+// CNIConfig is the configuration of the CNI plugins the runtime invokes.
+type CNIConfig struct {
+	Enable  bool
+	ConfDir string
+	BinDirs []string
+}
+
 // NetworkConfig is the network configuration related to a network.
 type NetworkConfig struct {
-	NetNSPath         string
-	NetNsCreated      bool
-	DisableNewNetNs   bool
-	InterworkingModel int
+	NetNSPath           string
+	NetNsCreated        bool
+	DisableNewNetNs     bool
+	CNIConfig           CNIConfig
+	InterworkingModel   int
+	IngressBandwidth    uint64
+	EgressBandwidth     uint64
+	VhostUserSocketPath string
+	MTU                 int
+	InterfaceMTUs       map[string]int
+	ConntrackBypass     bool
 }
 
 type ContainerConfig struct {
@@ -253,6 +361,58 @@ type SandboxConfig struct {
 
 	DisableGuestSeccomp bool
 
+	DisableGuestSELinux  bool
+	DisableGuestAppArmor bool
+
+	// PrivilegedDeviceAllowList lists the host devices privileged containers can access
+	PrivilegedDeviceAllowList []string
+
+	// SandboxTmpQuota is the size in MiB of the sandbox temporary tree
+	SandboxTmpQuota uint32
+
+	// ScratchDiskSize is the size in MiB of the encrypted scratch disk
+	ScratchDiskSize uint32
+
+	// VCPUIsolation is how the vCPU threads are isolated on the host
+	VCPUIsolation string
+
+	// StatsVMMOverhead accounts the VMM host memory in the sandbox stats
+	StatsVMMOverhead bool
+
+	// GuestTimeSyncInterval is the period in seconds the guest clock is synced at
+	GuestTimeSyncInterval uint32
+
+	// MountWatchInterval is the period in seconds the watchable mounts are
+	// checked for updates at
+	MountWatchInterval uint32
+
+	// IdlePauseTimeout is the time in seconds after which the VM of an idle
+	// sandbox is paused
+	IdlePauseTimeout uint32
+
+	// StaticResourceMgmt disables the resizing of the VM
+	StaticResourceMgmt bool
+
+	// StaticMemoryMgmt disables the resizing of the VM memory
+	StaticMemoryMgmt bool
+
+	// HypervisorExitHook is run when the hypervisor exits unexpectedly
+	HypervisorExitHook string
+
+	// AuditLog is the sink of the sandbox lifecycle audit events
+	AuditLog string
+
+	// NetSysctlAllowList lists the network sysctls applied in the guest
+	NetSysctlAllowList []string
+
+	// SandboxLogDir and SandboxLogFormat configure the sandbox log file
+	SandboxLogDir    string
+	SandboxLogFormat string
+
+	// GuestDNS and DNS configure the resolv.conf generated by the agent
+	GuestDNS bool
+	DNS      DNSConfig
+
 	// Experimental enables experimental features
 	Experimental []string
 
@@ -54,6 +54,13 @@ type Mount struct {
 	// VM in case this mount is a block device file or a directory
 	// backed by a block device.
 	BlockDeviceID string
+
+	// BlockFsType is the filesystem of the block device attached for
+	// a volume backed by a host filesystem.
+	BlockFsType string
+
+	// WatchedPath is the path in the guest a watched volume is copied to.
+	WatchedPath string
 }
 
 // RootfsState saves state of container rootfs
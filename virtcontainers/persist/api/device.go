@@ -39,12 +39,21 @@ type BlockDrive struct {
 	// VirtPath at which the device appears inside the VM, outside of the container mount namespace
 	VirtPath string
 
+	// Serial is the ID the hypervisor reports to the guest for the drive
+	Serial string
+
 	// DevNo
 	DevNo string
 
 	// Pmem enabled persistent memory. Use File as backing file
 	// for a nvdimm device in the guest.
 	Pmem bool
+
+	// ReadOnly sets the device file readonly
+	ReadOnly bool
+
+	// CacheMode is the host cache mode of the drive
+	CacheMode string
 }
 
 // VFIODev represents a VFIO drive used for hotplugging
@@ -28,11 +28,18 @@ type CPUDevice struct {
 
 type HypervisorState struct {
 	Pid int
+	// PidStartTime is the start time of the hypervisor process, in clock
+	// ticks since boot, telling a reused Pid apart
+	PidStartTime uint64
 	// Type of hypervisor, E.g. qemu/firecracker/acrn.
 	Type          string
 	BlockIndexMap map[int]struct{}
 	UUID          string
 
+	// acrn specific: refer to 'virtcontainers/acrn.go:AcrnState'
+	// BlkDevSlots are the PCI slots of the virtio-blk devices by drive index
+	BlkDevSlots []int
+
 	// Belows are qemu specific
 	// Refs: virtcontainers/qemu.go:QemuState
 	Bridges []Bridge
@@ -40,9 +47,18 @@ type HypervisorState struct {
 	HotpluggedVCPUs      []CPUDevice
 	HotpluggedMemory     int
 	VirtiofsdPid         int
+	VirtiofsdRestarts    int
 	HotplugVFIOOnRootBus bool
 	PCIeRootPort         int
 
 	// clh sepcific: refer to 'virtcontainers/clh.go:CloudHypervisorState'
 	APISocket string
+
+	// firecracker specific: refer to 'virtcontainers/fc.go:firecracker'
+	// DiskPool has the IDs of the drives hot added to the disk pool
+	DiskPool []string
+	// Exited is set once the runtime which started firecracker reaped
+	// it, with its ExitStatus
+	Exited     bool
+	ExitStatus int
 }
@@ -24,6 +24,7 @@ type TapInterface struct {
 	Name     string
 	TAPIface NetworkInterface
 	// remove VMFds and VhostFds
+	DisabledOffloads []string
 }
 
 // TuntapInterface defines a tap interface
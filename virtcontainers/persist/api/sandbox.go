@@ -6,6 +6,10 @@
 
 package persistapi
 
+import (
+	"time"
+)
+
 // ============= sandbox level resources =============
 
 // AgentState save agent state data
@@ -17,6 +21,24 @@ type AgentState struct {
 	URL string
 }
 
+// BootTimeline is the time of the boot steps of a sandbox
+type BootTimeline struct {
+	Create              time.Time
+	VMMStart            time.Time
+	VMMReady            time.Time
+	GuestReady          time.Time
+	AgentReady          time.Time
+	FirstContainerStart time.Time
+}
+
+// StateTransition is a change of the state of a sandbox
+type StateTransition struct {
+	From     string
+	To       string
+	Time     time.Time
+	Reverted bool
+}
+
 // SandboxState contains state information of sandbox
 // nolint: maligned
 type SandboxState struct {
@@ -44,6 +66,29 @@ type SandboxState struct {
 	// including the hypervisor are placed.
 	CgroupPaths map[string]string
 
+	// VSockChannels are the URLs of the guest vsock ports reachable from
+	// the host, keyed by channel name
+	VSockChannels map[string]string
+
+	// ScratchDeviceID is the ID of the block device of the encrypted
+	// scratch disk
+	ScratchDeviceID string
+
+	// PrewarmedDevices are the IDs of the rootfs devices of the prewarmed
+	// container images, keyed by container ID
+	PrewarmedDevices map[string]string
+
+	// BootTimeline records when the sandbox went through its boot steps
+	BootTimeline BootTimeline
+
+	// Transitions are the last state transitions of the sandbox
+	Transitions []StateTransition
+
+	// HypervisorExitReason and HypervisorExitTime are why and when the
+	// hypervisor process exited on its own
+	HypervisorExitReason string
+	HypervisorExitTime   time.Time
+
 	// Devices plugged to sandbox(hypervisor)
 	Devices []DeviceState
 
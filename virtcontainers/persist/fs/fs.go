@@ -12,7 +12,6 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
-	"syscall"
 
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
 	"github.com/sirupsen/logrus"
@@ -239,6 +238,9 @@ func (fs *FS) Destroy(sandboxID string) error {
 	return nil
 }
 
+// Lock locks the sandbox store. A lock held by a dead process, which
+// happens when its file descriptor has been inherited, is broken rather than
+// waited for.
 func (fs *FS) Lock(sandboxID string, exclusive bool) (func() error, error) {
 	if sandboxID == "" {
 		return nil, fmt.Errorf("sandbox container id required")
@@ -249,30 +251,13 @@ func (fs *FS) Lock(sandboxID string, exclusive bool) (func() error, error) {
 		return nil, err
 	}
 
-	f, err := os.Open(sandboxDir)
+	l, err := lockSandboxDir(sandboxDir, exclusive)
 	if err != nil {
 		return nil, err
 	}
 
-	var lockType int
-	if exclusive {
-		lockType = syscall.LOCK_EX
-	} else {
-		lockType = syscall.LOCK_SH
-	}
-
-	if err := syscall.Flock(int(f.Fd()), lockType); err != nil {
-		f.Close()
-		return nil, err
-	}
-
 	unlockFunc := func() error {
-		defer f.Close()
-		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
-			return err
-		}
-
-		return nil
+		return unlockSandboxDir(l)
 	}
 	return unlockFunc, nil
 }
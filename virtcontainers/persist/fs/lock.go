@@ -0,0 +1,371 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package fs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	// lockFile is the file of the sandbox directory locked to serialize
+	// the accesses to the sandbox store, it records the lock holders.
+	lockFile = "lock"
+
+	// lockGuardFile is locked to serialize the updates of the lock
+	// holders records and the lock breakers. Unlike the lock file, it's
+	// never replaced.
+	lockGuardFile = "lock.guard"
+)
+
+// variables rather than consts to allow tests to modify them
+var (
+	procRoot          = "/proc"
+	lockRetryInterval = 50 * time.Millisecond
+)
+
+// LockOwner identifies a process holding the lock of a sandbox store, there
+// is one per holder of a shared lock.
+type LockOwner struct {
+	PID int `json:"pid"`
+
+	// StartTime is the start time of the process, in clock ticks since
+	// boot, telling a reused PID apart.
+	StartTime uint64 `json:"start_time"`
+
+	// PidNS is the inode of the PID namespace of the process, the PID
+	// is meaningless from the other namespaces.
+	PidNS uint64 `json:"pid_ns"`
+}
+
+func processStartTime(pid int) (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return 0, err
+	}
+
+	// the command name can contain spaces and parentheses
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return 0, fmt.Errorf("invalid stat of process %d", pid)
+	}
+
+	// starttime is the 22nd field, the 20th after the command name
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("invalid stat of process %d", pid)
+	}
+
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+func pidNamespace(pid string) (uint64, error) {
+	var st syscall.Stat_t
+	if err := syscall.Stat(filepath.Join(procRoot, pid, "ns", "pid"), &st); err != nil {
+		return 0, err
+	}
+
+	return st.Ino, nil
+}
+
+func currentLockOwner() (LockOwner, error) {
+	pid := os.Getpid()
+
+	startTime, err := processStartTime(pid)
+	if err != nil {
+		return LockOwner{}, err
+	}
+
+	ns, err := pidNamespace("self")
+	if err != nil {
+		return LockOwner{}, err
+	}
+
+	return LockOwner{PID: pid, StartTime: startTime, PidNS: ns}, nil
+}
+
+// Alive returns whether the owner may still be running. Owners from another
+// PID namespace are always considered alive.
+func (o LockOwner) Alive() bool {
+	if ns, err := pidNamespace("self"); err != nil || ns != o.PidNS {
+		return true
+	}
+
+	startTime, err := processStartTime(o.PID)
+	return err == nil && startTime == o.StartTime
+}
+
+// readLockHolders returns the holders recorded in the lock file f.
+func readLockHolders(f *os.File) ([]LockOwner, error) {
+	data, err := ioutil.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+	if err != nil || len(data) == 0 {
+		return nil, err
+	}
+
+	var holders []LockOwner
+	if err := json.Unmarshal(data, &holders); err != nil {
+		return nil, err
+	}
+
+	return holders, nil
+}
+
+func writeLockHolders(f *os.File, holders []LockOwner) error {
+	if err := f.Truncate(0); err != nil || len(holders) == 0 {
+		return err
+	}
+
+	data, err := json.Marshal(holders)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.WriteAt(data, 0)
+	return err
+}
+
+// isLockFile returns whether f is still the lock file of the sandbox
+// directory, it's replaced when its lock is broken.
+func isLockFile(f *os.File, path string) (bool, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+
+	pi, err := os.Stat(path)
+	if err != nil {
+		return false, err
+	}
+
+	return os.SameFile(fi, pi), nil
+}
+
+// withGuardLock runs fn with the guard file of the sandbox directory dir
+// locked, so that a lock file is never replaced once it has been taken over.
+func withGuardLock(dir string, fn func() error) error {
+	g, err := os.OpenFile(filepath.Join(dir, lockGuardFile), os.O_RDWR|os.O_CREATE, fileMode)
+	if err != nil {
+		return err
+	}
+	defer g.Close()
+
+	if err := syscall.Flock(int(g.Fd()), syscall.LOCK_EX); err != nil {
+		return err
+	}
+	defer syscall.Flock(int(g.Fd()), syscall.LOCK_UN)
+
+	return fn()
+}
+
+// BreakStaleLock breaks the lock of the sandbox directory dir if it's held
+// while all its recorded holders are dead. It returns the holders of the
+// lock, none if the lock is free, and whether it was broken.
+func BreakStaleLock(dir string) (holders []LockOwner, broken bool, err error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, false, err
+	}
+
+	err = withGuardLock(dir, func() error {
+		holders, broken, err = breakStaleLock(dir)
+		return err
+	})
+
+	return holders, broken, err
+}
+
+func breakStaleLock(dir string) ([]LockOwner, bool, error) {
+	path := filepath.Join(dir, lockFile)
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	// probe for any holder, shared ones included
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err == nil {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		return nil, false, nil
+	} else if err != syscall.EWOULDBLOCK {
+		return nil, false, err
+	}
+
+	holders, err := readLockHolders(f)
+	if err != nil || len(holders) == 0 {
+		return holders, false, err
+	}
+
+	for _, h := range holders {
+		if h.Alive() {
+			return holders, false, nil
+		}
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+lockFile)
+	if err != nil {
+		return holders, false, err
+	}
+	tmp.Close()
+
+	if err := os.Chmod(tmp.Name(), fileMode); err != nil {
+		os.Remove(tmp.Name())
+		return holders, false, err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return holders, false, err
+	}
+
+	for _, h := range holders {
+		fsLog.WithField("dir", dir).WithField("pid", h.PID).Warn("broke stale sandbox lock")
+	}
+
+	return holders, true, nil
+}
+
+// sandboxLock is a lock of a sandbox directory taken by lockSandboxDir.
+type sandboxLock struct {
+	file  *os.File
+	dir   *os.File
+	owner LockOwner
+}
+
+// lockSandboxDir locks the sandbox directory dir, waiting for the current
+// holders to release it unless they are dead.
+//
+// The directory itself is locked too once the lock file is, that's the lock
+// taken by the runtimes predating the lock file. It can't be broken, it's
+// kept only for them to be serialized with this runtime during an upgrade,
+// and will be dropped in the next release.
+func lockSandboxDir(dir string, exclusive bool) (*sandboxLock, error) {
+	var lockType int
+	if exclusive {
+		lockType = syscall.LOCK_EX
+	} else {
+		lockType = syscall.LOCK_SH
+	}
+
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+
+	owner, err := currentLockOwner()
+	if err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(dir, lockFile)
+
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, fileMode)
+		if err != nil {
+			return nil, err
+		}
+
+		err = syscall.Flock(int(f.Fd()), lockType|syscall.LOCK_NB)
+		if err == syscall.EWOULDBLOCK {
+			f.Close()
+			if _, _, err := BreakStaleLock(dir); err != nil {
+				return nil, err
+			}
+			time.Sleep(lockRetryInterval)
+			continue
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		same := false
+		err = withGuardLock(dir, func() error {
+			// the lock may have been broken, or the sandbox
+			// destroyed, since the file was opened
+			var err error
+			if same, err = isLockFile(f, path); err != nil || !same {
+				return err
+			}
+
+			var holders []LockOwner
+			if !exclusive {
+				// the records of the holders which crashed are
+				// dropped by the next exclusive holder
+				if holders, err = readLockHolders(f); err != nil {
+					return err
+				}
+			}
+
+			return writeLockHolders(f, append(holders, owner))
+		})
+
+		if err != nil || !same {
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		l := &sandboxLock{file: f, owner: owner}
+
+		if l.dir, err = os.Open(dir); err == nil {
+			if err = syscall.Flock(int(l.dir.Fd()), lockType); err != nil {
+				l.dir.Close()
+			}
+		}
+		if err != nil {
+			l.dir = nil
+			unlockSandboxDir(l)
+			return nil, err
+		}
+
+		return l, nil
+	}
+}
+
+func unlockSandboxDir(l *sandboxLock) error {
+	defer l.file.Close()
+
+	if l.dir != nil {
+		syscall.Flock(int(l.dir.Fd()), syscall.LOCK_UN)
+		l.dir.Close()
+	}
+
+	// the next holders must not look stale until they record themselves
+	err := withGuardLock(filepath.Dir(l.file.Name()), func() error {
+		holders, err := readLockHolders(l.file)
+		if err != nil {
+			return err
+		}
+
+		for i, h := range holders {
+			if h == l.owner {
+				holders = append(holders[:i], holders[i+1:]...)
+				break
+			}
+		}
+
+		return writeLockHolders(l.file, holders)
+	})
+	// the sandbox may have been destroyed meanwhile
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return syscall.Flock(int(l.file.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package fs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// holdLock takes the lock of dir through its own file description, as
+// another process would, and records holders.
+func holdLock(t *testing.T, dir string, lockType int, holders ...LockOwner) *os.File {
+	f, err := os.OpenFile(filepath.Join(dir, lockFile), os.O_RDWR|os.O_CREATE, fileMode)
+	assert.NoError(t, err)
+	assert.NoError(t, syscall.Flock(int(f.Fd()), lockType))
+	assert.NoError(t, writeLockHolders(f, holders))
+	return f
+}
+
+// dirLocked returns whether the directory dir itself is locked.
+func dirLocked(t *testing.T, dir string) bool {
+	d, err := os.Open(dir)
+	assert.NoError(t, err)
+	defer d.Close()
+
+	err = syscall.Flock(int(d.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err == nil {
+		syscall.Flock(int(d.Fd()), syscall.LOCK_UN)
+		return false
+	}
+	assert.Equal(t, syscall.EWOULDBLOCK, err)
+	return true
+}
+
+func TestProcessStartTime(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedProcRoot := procRoot
+	procRoot = dir
+	defer func() {
+		procRoot = savedProcRoot
+	}()
+
+	assert.NoError(os.MkdirAll(filepath.Join(dir, "42"), 0755))
+	stat := "42 (kata (shim) v2) S 1 42 42 0 -1 4194560 1000 0 0 0 10 5 0 0 20 0 12 0 123456 1000000 200 18446744073709551615\n"
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "42", "stat"), []byte(stat), 0644))
+
+	startTime, err := processStartTime(42)
+	assert.NoError(err)
+	assert.Equal(uint64(123456), startTime)
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "42", "stat"), []byte("42 (kata) S 1"), 0644))
+	_, err = processStartTime(42)
+	assert.Error(err)
+
+	_, err = processStartTime(43)
+	assert.Error(err)
+}
+
+func TestLockOwnerAlive(t *testing.T) {
+	assert := assert.New(t)
+
+	owner, err := currentLockOwner()
+	assert.NoError(err)
+	assert.Equal(os.Getpid(), owner.PID)
+	assert.True(owner.Alive())
+
+	// reused PID
+	reused := owner
+	reused.StartTime++
+	assert.False(reused.Alive())
+
+	// can't tell from another namespace
+	reused.PidNS++
+	assert.True(reused.Alive())
+}
+
+func TestLockSandboxDirOwner(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	current, err := currentLockOwner()
+	assert.NoError(err)
+
+	l, err := lockSandboxDir(dir, true)
+	assert.NoError(err)
+	assert.True(dirLocked(t, dir))
+
+	holders, err := readLockHolders(l.file)
+	assert.NoError(err)
+	assert.Equal([]LockOwner{current}, holders)
+
+	// a live holder is waited for
+	holders, broken, err := BreakStaleLock(dir)
+	assert.NoError(err)
+	assert.False(broken)
+	assert.Equal([]LockOwner{current}, holders)
+
+	assert.NoError(unlockSandboxDir(l))
+	assert.False(dirLocked(t, dir))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, lockFile))
+	assert.NoError(err)
+	assert.Empty(data)
+
+	// one record per shared holder
+	l1, err := lockSandboxDir(dir, false)
+	assert.NoError(err)
+	l2, err := lockSandboxDir(dir, false)
+	assert.NoError(err)
+	assert.True(dirLocked(t, dir))
+
+	holders, broken, err = BreakStaleLock(dir)
+	assert.NoError(err)
+	assert.False(broken)
+	assert.Equal([]LockOwner{current, current}, holders)
+
+	assert.NoError(unlockSandboxDir(l1))
+	holders, err = readLockHolders(l2.file)
+	assert.NoError(err)
+	assert.Equal([]LockOwner{current}, holders)
+
+	assert.NoError(unlockSandboxDir(l2))
+	assert.False(dirLocked(t, dir))
+
+	// the sandbox destroyed while locked
+	l, err = lockSandboxDir(dir, true)
+	assert.NoError(err)
+	assert.NoError(os.RemoveAll(dir))
+	assert.NoError(unlockSandboxDir(l))
+
+	_, err = lockSandboxDir(filepath.Join(dir, "missing"), true)
+	assert.Error(err)
+}
+
+func TestLockSandboxDirStale(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	current, err := currentLockOwner()
+	assert.NoError(err)
+
+	stale := current
+	stale.StartTime++
+
+	for _, lockType := range []int{syscall.LOCK_EX, syscall.LOCK_SH} {
+		held := holdLock(t, dir, lockType, stale, stale)
+		defer held.Close()
+
+		l, err := lockSandboxDir(dir, true)
+		assert.NoError(err)
+
+		// the stale lock file has been replaced
+		same, err := isLockFile(held, filepath.Join(dir, lockFile))
+		assert.NoError(err)
+		assert.False(same)
+
+		holders, broken, err := BreakStaleLock(dir)
+		assert.NoError(err)
+		assert.False(broken)
+		assert.Equal([]LockOwner{current}, holders)
+
+		assert.NoError(unlockSandboxDir(l))
+	}
+
+	// a single live shared holder is enough
+	held := holdLock(t, dir, syscall.LOCK_SH, stale, current)
+	defer held.Close()
+
+	holders, broken, err := BreakStaleLock(dir)
+	assert.NoError(err)
+	assert.False(broken)
+	assert.Equal([]LockOwner{stale, current}, holders)
+
+	assert.NoError(syscall.Flock(int(held.Fd()), syscall.LOCK_UN))
+
+	// free lock
+	holders, broken, err = BreakStaleLock(dir)
+	assert.NoError(err)
+	assert.False(broken)
+	assert.Empty(holders)
+}
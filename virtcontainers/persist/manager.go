@@ -12,6 +12,7 @@ import (
 	exp "github.com/kata-containers/runtime/virtcontainers/experimental"
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
 	"github.com/kata-containers/runtime/virtcontainers/persist/fs"
+	"github.com/kata-containers/runtime/virtcontainers/persist/plugin/kv"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
 )
 
@@ -36,6 +37,9 @@ var (
 		RootlessFSName: fs.RootlessInit,
 	}
 	mockTesting = false
+
+	// remoteStore mirrors the persist data of the fs drivers when set
+	remoteStore kv.Store
 )
 
 func init() {
@@ -46,6 +50,13 @@ func EnableMockTesting() {
 	mockTesting = true
 }
 
+// SetRemoteStore makes the drivers returned by GetDriver mirror the sandbox
+// persist data in store, so that it can be recovered if the node loses its
+// local state. Passing nil disables the remote store.
+func SetRemoteStore(store kv.Store) {
+	remoteStore = store
+}
+
 // GetDriver returns new PersistDriver according to driver name
 func GetDriverByName(name string) (persistapi.PersistDriver, error) {
 	if expErr != nil {
@@ -71,6 +82,15 @@ func GetDriver() (persistapi.PersistDriver, error) {
 		return fs.MockFSInit()
 	}
 
+	driver, err := getFSDriver()
+	if err != nil || remoteStore == nil {
+		return driver, err
+	}
+
+	return kv.Init(driver, remoteStore)
+}
+
+func getFSDriver() (persistapi.PersistDriver, error) {
 	if rootless.IsRootless() {
 		if f, ok := supportedDrivers[RootlessFSName]; ok {
 			return f()
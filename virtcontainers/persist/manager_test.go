@@ -12,6 +12,7 @@ import (
 
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
 	"github.com/kata-containers/runtime/virtcontainers/persist/fs"
+	"github.com/kata-containers/runtime/virtcontainers/persist/plugin/kv"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -55,3 +56,48 @@ func TestGetDriver(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(expectedFS, fsd)
 }
+
+func TestGetDriverRemoteStore(t *testing.T) {
+	assert := assert.New(t)
+	orgMockTesting := mockTesting
+	defer func() {
+		mockTesting = orgMockTesting
+		SetRemoteStore(nil)
+	}()
+
+	mockTesting = false
+
+	SetRemoteStore(&fakeStore{})
+	fsd, err := GetDriver()
+	assert.NoError(err)
+	_, ok := fsd.(*kv.KV)
+	assert.True(ok)
+
+	SetRemoteStore(nil)
+	fsd, err = GetDriver()
+	assert.NoError(err)
+	_, ok = fsd.(*kv.KV)
+	assert.False(ok)
+}
+
+type fakeStore struct{}
+
+func (f *fakeStore) Put(key string, value []byte) error {
+	return nil
+}
+
+func (f *fakeStore) Get(key string) ([]byte, error) {
+	return nil, kv.ErrKeyNotFound
+}
+
+func (f *fakeStore) List(prefix string) (map[string][]byte, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) Delete(key string) error {
+	return nil
+}
+
+func (f *fakeStore) DeletePrefix(prefix string) error {
+	return nil
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package kv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// etcdTimeout is the timeout of the requests to etcd
+const etcdTimeout = 10 * time.Second
+
+// Etcd is a Store backed by an etcd v3 cluster, reached through the JSON
+// gateway of its endpoint so that no etcd client library is needed.
+type Etcd struct {
+	endpoint string
+	client   *http.Client
+}
+
+// etcdKV is a key-value pair of the etcd JSON gateway, keys and values being
+// base64 encoded by encoding/json as they are byte slices.
+type etcdKV struct {
+	Key   []byte `json:"key,omitempty"`
+	Value []byte `json:"value,omitempty"`
+}
+
+type etcdRange struct {
+	Key      []byte `json:"key"`
+	RangeEnd []byte `json:"range_end,omitempty"`
+}
+
+type etcdRangeResponse struct {
+	Kvs []etcdKV `json:"kvs"`
+}
+
+// NewEtcd returns a Store backed by the etcd endpoint, e.g.
+// "http://10.0.0.1:2379".
+func NewEtcd(endpoint string) (*Etcd, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid etcd endpoint %q, expected an http or https URL", endpoint)
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid etcd endpoint %q, missing host", endpoint)
+	}
+
+	return &Etcd{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		client:   &http.Client{Timeout: etcdTimeout},
+	}, nil
+}
+
+// prefixEnd returns the end of the range of the keys starting with prefix.
+func prefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+
+	// All the keys are greater than or equal to the prefix.
+	return []byte{0}
+}
+
+func (e *Etcd) call(method string, req, resp interface{}) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	r, err := e.client.Post(e.endpoint+"/v3/kv/"+method, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode != http.StatusOK {
+		return fmt.Errorf("etcd %s failed: %s", method, r.Status)
+	}
+
+	if resp == nil {
+		return nil
+	}
+
+	return json.NewDecoder(r.Body).Decode(resp)
+}
+
+// Put stores value under key.
+func (e *Etcd) Put(key string, value []byte) error {
+	return e.call("put", etcdKV{Key: []byte(key), Value: value}, nil)
+}
+
+// Get returns the value stored under key or ErrKeyNotFound.
+func (e *Etcd) Get(key string) ([]byte, error) {
+	var resp etcdRangeResponse
+	if err := e.call("range", etcdRange{Key: []byte(key)}, &resp); err != nil {
+		return nil, err
+	}
+
+	if len(resp.Kvs) == 0 {
+		return nil, ErrKeyNotFound
+	}
+
+	return resp.Kvs[0].Value, nil
+}
+
+// List returns all the key/value pairs whose key starts with prefix.
+func (e *Etcd) List(prefix string) (map[string][]byte, error) {
+	var resp etcdRangeResponse
+	if err := e.call("range", etcdRange{Key: []byte(prefix), RangeEnd: prefixEnd(prefix)}, &resp); err != nil {
+		return nil, err
+	}
+
+	res := make(map[string][]byte, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		res[string(kv.Key)] = kv.Value
+	}
+
+	return res, nil
+}
+
+// Delete removes key.
+func (e *Etcd) Delete(key string) error {
+	return e.call("deleterange", etcdRange{Key: []byte(key)}, nil)
+}
+
+// DeletePrefix removes all the keys starting with prefix.
+func (e *Etcd) DeletePrefix(prefix string) error {
+	return e.call("deleterange", etcdRange{Key: []byte(prefix), RangeEnd: prefixEnd(prefix)}, nil)
+}
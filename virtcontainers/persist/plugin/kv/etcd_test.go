@@ -0,0 +1,125 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package kv
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// etcdGateway fakes the JSON gateway of etcd on top of a memStore.
+func etcdGateway(store *memStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Key      []byte `json:"key"`
+			Value    []byte `json:"value"`
+			RangeEnd []byte `json:"range_end"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		inRange := func(k string) bool {
+			if req.RangeEnd == nil {
+				return k == string(req.Key)
+			}
+			return k >= string(req.Key) && k < string(req.RangeEnd)
+		}
+
+		var resp etcdRangeResponse
+
+		switch strings.TrimPrefix(r.URL.Path, "/v3/kv/") {
+		case "put":
+			store.Put(string(req.Key), req.Value)
+		case "range":
+			for k, v := range store.data {
+				if inRange(k) {
+					resp.Kvs = append(resp.Kvs, etcdKV{Key: []byte(k), Value: v})
+				}
+			}
+		case "deleterange":
+			for k := range store.data {
+				if inRange(k) {
+					delete(store.data, k)
+				}
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		json.NewEncoder(w).Encode(resp)
+	}
+}
+
+func TestNewEtcd(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, endpoint := range []string{"", "10.0.0.1:2379", "unix:///run/etcd.sock", "http://"} {
+		_, err := NewEtcd(endpoint)
+		assert.Error(err, endpoint)
+	}
+
+	e, err := NewEtcd("https://10.0.0.1:2379/")
+	assert.NoError(err)
+	assert.Equal("https://10.0.0.1:2379", e.endpoint)
+}
+
+func TestPrefixEnd(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]byte("/kata/sbs0"), prefixEnd("/kata/sbs/"))
+	assert.Equal([]byte("b"), prefixEnd("a\xff"))
+	assert.Equal([]byte{0}, prefixEnd("\xff"))
+}
+
+func TestEtcdStore(t *testing.T) {
+	assert := assert.New(t)
+
+	store := newMemStore()
+	server := httptest.NewServer(etcdGateway(store))
+	defer server.Close()
+
+	e, err := NewEtcd(server.URL)
+	assert.NoError(err)
+
+	_, err = e.Get("/kata/sbs/s1/persist.json")
+	assert.Equal(ErrKeyNotFound, err)
+
+	assert.NoError(e.Put("/kata/sbs/s1/persist.json", []byte("{}")))
+	assert.NoError(e.Put("/kata/sbs/s1/containers/c1", []byte("c1")))
+	assert.NoError(e.Put("/kata/sbs/s1/containers/c10", []byte("c10")))
+	assert.NoError(e.Put("/kata/sbs/s10/persist.json", []byte("{}")))
+
+	data, err := e.Get("/kata/sbs/s1/persist.json")
+	assert.NoError(err)
+	assert.True(bytes.Equal([]byte("{}"), data))
+
+	list, err := e.List("/kata/sbs/s1/containers/")
+	assert.NoError(err)
+	assert.Equal(map[string][]byte{
+		"/kata/sbs/s1/containers/c1":  []byte("c1"),
+		"/kata/sbs/s1/containers/c10": []byte("c10"),
+	}, list)
+
+	assert.NoError(e.Delete("/kata/sbs/s1/containers/c1"))
+	assert.Len(store.data, 3)
+
+	assert.NoError(e.DeletePrefix("/kata/sbs/s1/"))
+	assert.Len(store.data, 1)
+	assert.Contains(store.data, "/kata/sbs/s10/persist.json")
+
+	server.Close()
+	assert.Error(e.Put("/kata/sbs/s1/persist.json", []byte("{}")))
+}
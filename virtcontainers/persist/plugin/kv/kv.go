@@ -0,0 +1,224 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package kv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+	"github.com/sirupsen/logrus"
+)
+
+// sandboxesPrefix is the key prefix under which all sandboxes are stored
+const sandboxesPrefix = "/kata/sbs"
+
+// sandboxKey is the key of the sandbox state, relative to the sandbox prefix
+const sandboxKey = "persist.json"
+
+// containersPrefix is the key prefix of the container states, relative to
+// the sandbox prefix
+const containersPrefix = "containers"
+
+// ErrKeyNotFound must be returned by Store.Get when the key doesn't exist
+var ErrKeyNotFound = errors.New("key not found")
+
+// Store is the interface a remote key-value store (etcd, consul...) has to
+// implement to back the persist data of the sandboxes.
+type Store interface {
+	// Put stores value under key, overwriting any existing value.
+	Put(key string, value []byte) error
+	// Get returns the value stored under key or ErrKeyNotFound.
+	Get(key string) ([]byte, error)
+	// List returns all the key/value pairs whose key starts with prefix.
+	List(prefix string) (map[string][]byte, error)
+	// Delete removes key, if it exists.
+	Delete(key string) error
+	// DeletePrefix removes all the keys starting with prefix.
+	DeletePrefix(prefix string) error
+}
+
+// KV persist driver implementation.
+//
+// The state is always written to the local driver first, so that runtime
+// paths, locks and global data keep working the same way, then mirrored
+// to the remote store. When the local state is lost, FromDisk restores it
+// from the remote store.
+type KV struct {
+	local persistapi.PersistDriver
+	store Store
+}
+
+var kvLog = logrus.WithField("source", "virtcontainers/persist/plugin/kv")
+
+// Logger returns a logrus logger appropriate for logging KV messages
+func (kv *KV) Logger() *logrus.Entry {
+	return kvLog.WithFields(logrus.Fields{
+		"subsystem": "persist",
+		"driver":    "kv",
+	})
+}
+
+// Init KV persist driver on top of local and return abstract PersistDriver
+func Init(local persistapi.PersistDriver, store Store) (persistapi.PersistDriver, error) {
+	if local == nil {
+		return nil, fmt.Errorf("local persist driver required")
+	}
+
+	if store == nil {
+		return nil, fmt.Errorf("remote store required")
+	}
+
+	return &KV{
+		local: local,
+		store: store,
+	}, nil
+}
+
+func sandboxPrefix(sid string) string {
+	return path.Join(sandboxesPrefix, sid) + "/"
+}
+
+func containerPrefix(sid string) string {
+	return path.Join(sandboxesPrefix, sid, containersPrefix) + "/"
+}
+
+// ToDisk saves sandboxState and containerState locally and in the remote store
+func (kv *KV) ToDisk(ss persistapi.SandboxState, cs map[string]persistapi.ContainerState) error {
+	if err := kv.local.ToDisk(ss, cs); err != nil {
+		return err
+	}
+
+	id := ss.SandboxContainer
+
+	data, err := json.Marshal(ss)
+	if err != nil {
+		return err
+	}
+
+	if err := kv.store.Put(path.Join(sandboxesPrefix, id, sandboxKey), data); err != nil {
+		return fmt.Errorf("failed to store sandbox %s: %v", id, err)
+	}
+
+	for cid, cstate := range cs {
+		data, err := json.Marshal(cstate)
+		if err != nil {
+			return err
+		}
+
+		if err := kv.store.Put(containerPrefix(id)+cid, data); err != nil {
+			return fmt.Errorf("failed to store container %s: %v", cid, err)
+		}
+	}
+
+	stored, err := kv.store.List(containerPrefix(id))
+	if err != nil {
+		return err
+	}
+
+	// Remove non-existing containers
+	for key := range stored {
+		cid := strings.TrimPrefix(key, containerPrefix(id))
+		if _, ok := cs[cid]; !ok {
+			if err := kv.store.Delete(key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// FromDisk restores state for sandbox with name sid, falling back to the
+// remote store if the local state doesn't exist
+func (kv *KV) FromDisk(sid string) (persistapi.SandboxState, map[string]persistapi.ContainerState, error) {
+	ss, cs, err := kv.local.FromDisk(sid)
+	if err == nil || !os.IsNotExist(err) {
+		return ss, cs, err
+	}
+
+	localErr := err
+
+	data, err := kv.store.Get(path.Join(sandboxesPrefix, sid, sandboxKey))
+	if err == ErrKeyNotFound {
+		return ss, nil, localErr
+	} else if err != nil {
+		return ss, nil, err
+	}
+
+	if err := json.Unmarshal(data, &ss); err != nil {
+		return ss, nil, err
+	}
+
+	stored, err := kv.store.List(containerPrefix(sid))
+	if err != nil {
+		return ss, nil, err
+	}
+
+	cs = make(map[string]persistapi.ContainerState)
+	for key, data := range stored {
+		var cstate persistapi.ContainerState
+		if err := json.Unmarshal(data, &cstate); err != nil {
+			return ss, nil, err
+		}
+
+		cs[strings.TrimPrefix(key, containerPrefix(sid))] = cstate
+	}
+
+	kv.Logger().WithField("sandbox", sid).Info("sandbox state restored from remote store")
+
+	// Recreate the local state so that following operations find it.
+	if err := kv.local.ToDisk(ss, cs); err != nil {
+		return ss, nil, err
+	}
+
+	return ss, cs, nil
+}
+
+// Destroy removes everything from the local driver and the remote store
+func (kv *KV) Destroy(sandboxID string) error {
+	if err := kv.local.Destroy(sandboxID); err != nil {
+		return err
+	}
+
+	return kv.store.DeletePrefix(sandboxPrefix(sandboxID))
+}
+
+// Lock locks the sandbox with the local driver. Sandboxes are only
+// ever managed by the node running them, so no distributed lock is needed.
+func (kv *KV) Lock(sandboxID string, exclusive bool) (func() error, error) {
+	unlock, err := kv.local.Lock(sandboxID, exclusive)
+	if err == nil || !os.IsNotExist(err) {
+		return unlock, err
+	}
+
+	// The local state may have been lost, restore it before locking.
+	if _, _, rerr := kv.FromDisk(sandboxID); rerr != nil {
+		return nil, err
+	}
+
+	return kv.local.Lock(sandboxID, exclusive)
+}
+
+func (kv *KV) GlobalWrite(relativePath string, data []byte) error {
+	return kv.local.GlobalWrite(relativePath, data)
+}
+
+func (kv *KV) GlobalRead(relativePath string) ([]byte, error) {
+	return kv.local.GlobalRead(relativePath)
+}
+
+func (kv *KV) RunStoragePath() string {
+	return kv.local.RunStoragePath()
+}
+
+func (kv *KV) RunVMStoragePath() string {
+	return kv.local.RunVMStoragePath()
+}
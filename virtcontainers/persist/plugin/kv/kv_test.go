@@ -0,0 +1,153 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package kv
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+	"github.com/kata-containers/runtime/virtcontainers/persist/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+type memStore struct {
+	sync.Mutex
+	data map[string][]byte
+}
+
+func newMemStore() *memStore {
+	return &memStore{data: make(map[string][]byte)}
+}
+
+func (m *memStore) Put(key string, value []byte) error {
+	m.Lock()
+	defer m.Unlock()
+	m.data[key] = value
+	return nil
+}
+
+func (m *memStore) Get(key string) ([]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	if v, ok := m.data[key]; ok {
+		return v, nil
+	}
+	return nil, ErrKeyNotFound
+}
+
+func (m *memStore) List(prefix string) (map[string][]byte, error) {
+	m.Lock()
+	defer m.Unlock()
+	res := make(map[string][]byte)
+	for k, v := range m.data {
+		if strings.HasPrefix(k, prefix) {
+			res[k] = v
+		}
+	}
+	return res, nil
+}
+
+func (m *memStore) Delete(key string) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.data, key)
+	return nil
+}
+
+func (m *memStore) DeletePrefix(prefix string) error {
+	m.Lock()
+	defer m.Unlock()
+	for k := range m.data {
+		if strings.HasPrefix(k, prefix) {
+			delete(m.data, k)
+		}
+	}
+	return nil
+}
+
+func getKVDriver(t *testing.T) (*KV, *memStore) {
+	local, err := fs.MockFSInit()
+	assert.NoError(t, err)
+
+	store := newMemStore()
+	driver, err := Init(local, store)
+	assert.NoError(t, err)
+
+	kv, ok := driver.(*KV)
+	assert.True(t, ok)
+
+	return kv, store
+}
+
+func TestKVInit(t *testing.T) {
+	local, err := fs.MockFSInit()
+	assert.NoError(t, err)
+
+	_, err = Init(nil, newMemStore())
+	assert.Error(t, err)
+
+	_, err = Init(local, nil)
+	assert.Error(t, err)
+}
+
+func TestKVToFromDisk(t *testing.T) {
+	defer fs.MockStorageDestroy()
+	assert := assert.New(t)
+
+	kv, store := getKVDriver(t)
+
+	sid := "test-kv-driver"
+	ss := persistapi.SandboxState{
+		SandboxContainer: sid,
+		State:            "running",
+	}
+	cs := map[string]persistapi.ContainerState{
+		"c1":  {State: "ready"},
+		"c2":  {State: "running"},
+		"c20": {State: "running"},
+	}
+
+	assert.NoError(kv.ToDisk(ss, cs))
+	assert.Len(store.data, 4)
+
+	// removed containers are removed from the store as well, but not
+	// the ones whose ID they prefix
+	delete(cs, "c2")
+	assert.NoError(kv.ToDisk(ss, cs))
+	assert.Len(store.data, 3)
+
+	// local state is lost
+	assert.NoError(kv.local.Destroy(sid))
+
+	// locking the sandbox restores it
+	unlock, err := kv.Lock(sid, true)
+	assert.NoError(err)
+	assert.NoError(unlock())
+
+	assert.NoError(kv.local.Destroy(sid))
+
+	newSS, newCS, err := kv.FromDisk(sid)
+	assert.NoError(err)
+	assert.Equal(ss, newSS)
+	assert.Equal(cs, newCS)
+
+	// local state has been recreated
+	newSS, newCS, err = kv.local.FromDisk(sid)
+	assert.NoError(err)
+	assert.Equal(ss, newSS)
+	assert.Equal(cs["c1"], newCS["c1"])
+
+	assert.NoError(kv.Destroy(sid))
+	assert.Empty(store.data)
+
+	_, _, err = kv.FromDisk(sid)
+	assert.Error(err)
+
+	_, err = kv.Lock(sid, false)
+	assert.Error(err)
+}
@@ -21,6 +21,39 @@ const (
 	ContainerTypeKey = kataAnnotationsPrefix + "pkg.oci.container_type"
 
 	SandboxConfigPathKey = kataAnnotationsPrefix + "config_path"
+
+	// PodUIDKey is the annotation key to fetch the UID of the pod a
+	// container belongs to, as reported by the CRI server.
+	PodUIDKey = kataAnnotationsPrefix + "pkg.oci.pod_uid"
+
+	// PodNameKey is the annotation key to fetch the name of the pod a
+	// container belongs to.
+	PodNameKey = kataAnnotationsPrefix + "pkg.oci.pod_name"
+
+	// PodNamespaceKey is the annotation key to fetch the namespace of the
+	// pod a container belongs to.
+	PodNamespaceKey = kataAnnotationsPrefix + "pkg.oci.pod_namespace"
+
+	// ContainerImageKey is the annotation key to fetch the image a
+	// container is created from.
+	ContainerImageKey = kataAnnotationsPrefix + "pkg.oci.image"
+
+	// KataAnnotationHypervisorPrefix is the prefix of the annotations
+	// overriding the hypervisor configuration.
+	KataAnnotationHypervisorPrefix = kataAnnotHypervisorPrefix
+)
+
+const (
+	kataAnnotVolumePrefix = kataAnnotationsPrefix + "volume."
+
+	// BlockVolumes is a container annotation listing the destinations of the read-only
+	// volumes attached to the VM as block devices whatever their size, comma separated.
+	// The volumes must be the root of a filesystem of a host block device.
+	BlockVolumes = kataAnnotVolumePrefix + "block"
+
+	// SharedFSVolumes is a container annotation listing the destinations of the volumes
+	// shared with the VM through the shared file system whatever their size, comma separated.
+	SharedFSVolumes = kataAnnotVolumePrefix + "shared_fs"
 )
 
 // Annotations related to Hypervisor configuration
@@ -84,6 +117,14 @@ const (
 	// DisableVhostNet is a sandbox annotation to specify if vhost-net is not available on the host.
 	DisableVhostNet = kataAnnotHypervisorPrefix + "disable_vhost_net"
 
+	// DisableNetOffloads is a sandbox annotation to specify the comma separated offloads, e.g. "tso4,tso6",
+	// disabled on the tap devices and on the virtio-net devices.
+	DisableNetOffloads = kataAnnotHypervisorPrefix + "disable_net_offloads"
+
+	// InterfaceNetOffloadsPrefix is the prefix of the sandbox annotations overriding DisableNetOffloads
+	// for a network interface, the interface name following the prefix, e.g. "disable_net_offloads.eth0".
+	InterfaceNetOffloadsPrefix = DisableNetOffloads + "."
+
 	// EnableVhostUserStore is a sandbox annotation to specify if vhost-user-blk/scsi is abailable on the host
 	EnableVhostUserStore = kataAnnotHypervisorPrefix + "enable_vhost_user_store"
 
@@ -100,6 +141,9 @@ const (
 	// DisableImageNvdimm is a sandbox annotation to specify use of nvdimm device for guest rootfs image.
 	DisableImageNvdimm = kataAnnotHypervisorPrefix + "disable_image_nvdimm"
 
+	// UsePmemRootfs is a sandbox annotation to specify use of a persistent memory device for guest rootfs image.
+	UsePmemRootfs = kataAnnotHypervisorPrefix + "use_pmem_rootfs"
+
 	// HotplugVFIOOnRootBus is a sandbox annotation used to indicate if devices need to be hotplugged on the
 	// root bus instead of a bridge.
 	HotplugVFIOOnRootBus = kataAnnotHypervisorPrefix + "hotplug_vfio_on_root_bus"
@@ -112,6 +156,15 @@ const (
 	// entropy (/dev/random, /dev/urandom or real hardware RNG device)
 	EntropySource = kataAnnotHypervisorPrefix + "entropy_source"
 
+	// VMMLogLevel is a sandbox annotation to specify the level of the VMM logs.
+	VMMLogLevel = kataAnnotHypervisorPrefix + "vmm_log_level"
+
+	// VMMForwardMetrics is a sandbox annotation to specify if the VMM metrics are forwarded to the runtime log.
+	VMMForwardMetrics = kataAnnotHypervisorPrefix + "vmm_forward_metrics"
+
+	// CPUTemplate is a sandbox annotation to specify the firecracker CPU template.
+	CPUTemplate = kataAnnotHypervisorPrefix + "cpu_template"
+
 	//
 	//	CPU Annotations
 	//
@@ -122,6 +175,9 @@ const (
 	// DefaultVCPUs is a sandbox annotation that specifies the maximum number of vCPUs allocated for the VM by the hypervisor.
 	DefaultMaxVCPUs = kataAnnotHypervisorPrefix + "default_max_vcpus"
 
+	// CPUFeatures is a sandbox annotation that specifies the CPU features enabled or disabled in the guest.
+	CPUFeatures = kataAnnotHypervisorPrefix + "cpu_features"
+
 	//
 	//	Memory related annotations
 	//
@@ -206,6 +262,12 @@ const (
 	// DisableGuestSeccomp is a sandbox annotation that determines if seccomp should be applied inside guest.
 	DisableGuestSeccomp = kataAnnotRuntimePrefix + "disable_guest_seccomp"
 
+	// DisableGuestSELinux is a sandbox annotation that determines if SELinux labels should be applied inside guest.
+	DisableGuestSELinux = kataAnnotRuntimePrefix + "disable_guest_selinux"
+
+	// DisableGuestAppArmor is a sandbox annotation that determines if AppArmor profiles should be applied inside guest.
+	DisableGuestAppArmor = kataAnnotRuntimePrefix + "disable_guest_apparmor"
+
 	// SandboxCgroupOnly is a sandbox annotation that determines if kata processes are managed only in sandbox cgroup.
 	SandboxCgroupOnly = kataAnnotRuntimePrefix + "sandbox_cgroup_only"
 
@@ -218,6 +280,41 @@ const (
 
 	// DisableNewNetNs is a sandbox annotation that determines if create a netns for hypervisor process.
 	DisableNewNetNs = kataAnnotRuntimePrefix + "disable_new_netns"
+
+	// NetworkMTU is a sandbox annotation overriding the MTU of the network interfaces in the guest.
+	NetworkMTU = kataAnnotRuntimePrefix + "network_mtu"
+
+	// InterfaceMTUPrefix is the prefix of the sandbox annotations overriding NetworkMTU for a network
+	// interface, the interface name following the prefix, e.g. "network_mtu.eth0".
+	InterfaceMTUPrefix = NetworkMTU + "."
+
+	// VhostUserSocketPath is a sandbox annotation that determines where the vhost-user sockets
+	// of the interfaces are looked for, %s being replaced by the interface addresses.
+	VhostUserSocketPath = kataAnnotRuntimePrefix + "vhost_user_socket_path"
+
+	// BootProfile is a sandbox annotation selecting the optional work done to boot the sandbox,
+	// "fast" skips everything not required to run the workload.
+	BootProfile = kataAnnotRuntimePrefix + "boot_profile"
+
+	// AssetProfile is a sandbox annotation selecting the asset profile of the configuration
+	// the sandbox is booted with, e.g. to use another guest kernel.
+	AssetProfile = kataAnnotRuntimePrefix + "asset_profile"
+
+	// VCPUIsolation is a sandbox annotation keeping the vCPU threads from sharing the host cores with
+	// other sandboxes, "core-sched" using core scheduling and "exclusive-cores" reserved cores.
+	VCPUIsolation = kataAnnotRuntimePrefix + "vcpu_isolation"
+
+	// DNSServers is a sandbox annotation overriding the name servers of the resolv.conf of the guest
+	// and of the containers, a comma separated list of IP addresses.
+	DNSServers = kataAnnotRuntimePrefix + "dns_servers"
+
+	// DNSSearches is a sandbox annotation overriding the search domains of the resolv.conf of the
+	// guest and of the containers, a comma separated list.
+	DNSSearches = kataAnnotRuntimePrefix + "dns_searches"
+
+	// DNSOptions is a sandbox annotation overriding the resolver options of the resolv.conf of the
+	// guest and of the containers, a comma separated list, e.g. "ndots:2,timeout:1".
+	DNSOptions = kataAnnotRuntimePrefix + "dns_options"
 )
 
 const (
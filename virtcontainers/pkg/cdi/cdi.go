@@ -0,0 +1,343 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package cdi resolves the devices requested through the Container Device
+// Interface annotations of an OCI spec, e.g. "cdi.k8s.io/gpu":
+// "vendor.com/gpu=gpu0", with the CDI specs the device vendors install on
+// the host, and applies their container edits to the OCI spec. The device
+// nodes added to the spec are then attached to the VM like any other
+// device, the VFIO groups being passed through.
+package cdi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	vcAnnotations "github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// AnnotationPrefix is the prefix of the annotations requesting CDI devices,
+// their value being a comma separated list of fully qualified device names.
+const AnnotationPrefix = "cdi.k8s.io/"
+
+// DefaultSpecDirs are the directories the CDI specs are loaded from, the
+// specs of the latter directories overriding the former ones.
+var DefaultSpecDirs = []string{"/etc/cdi", "/var/run/cdi"}
+
+// Spec is a CDI spec, describing the devices of a vendor and class. The
+// guest kernel modules a device needs are listed by the
+// io.katacontainers.config.agent.kernel_modules annotation of its spec or
+// of the device itself. The hooks of the container edits are not
+// supported, they would have to run in the guest.
+type Spec struct {
+	Version        string            `yaml:"cdiVersion"`
+	Kind           string            `yaml:"kind"`
+	Annotations    map[string]string `yaml:"annotations,omitempty"`
+	Devices        []Device          `yaml:"devices"`
+	ContainerEdits ContainerEdits    `yaml:"containerEdits,omitempty"`
+}
+
+// Device is a device of a CDI spec.
+type Device struct {
+	Name           string            `yaml:"name"`
+	Annotations    map[string]string `yaml:"annotations,omitempty"`
+	ContainerEdits ContainerEdits    `yaml:"containerEdits"`
+}
+
+// ContainerEdits are the changes of the OCI spec a device requires.
+type ContainerEdits struct {
+	Env         []string     `yaml:"env,omitempty"`
+	DeviceNodes []DeviceNode `yaml:"deviceNodes,omitempty"`
+	Mounts      []Mount      `yaml:"mounts,omitempty"`
+}
+
+// DeviceNode is a device node of the container. The type and numbers of the
+// host device, at HostPath or Path, are used when not set.
+type DeviceNode struct {
+	Path        string       `yaml:"path"`
+	HostPath    string       `yaml:"hostPath,omitempty"`
+	Type        string       `yaml:"type,omitempty"`
+	Major       int64        `yaml:"major,omitempty"`
+	Minor       int64        `yaml:"minor,omitempty"`
+	FileMode    *os.FileMode `yaml:"fileMode,omitempty"`
+	Permissions string       `yaml:"permissions,omitempty"`
+	UID         *uint32      `yaml:"uid,omitempty"`
+	GID         *uint32      `yaml:"gid,omitempty"`
+}
+
+// Mount is a host path mounted in the container.
+type Mount struct {
+	HostPath      string   `yaml:"hostPath"`
+	ContainerPath string   `yaml:"containerPath"`
+	Type          string   `yaml:"type,omitempty"`
+	Options       []string `yaml:"options,omitempty"`
+}
+
+// device is a device of a loaded spec.
+type device struct {
+	spec   *Spec
+	device *Device
+}
+
+// RequestedDevices returns the fully qualified names of the CDI devices
+// requested by the annotations, in the order of the annotations.
+func RequestedDevices(annotations map[string]string) ([]string, error) {
+	var keys []string
+	for k := range annotations {
+		if strings.HasPrefix(k, AnnotationPrefix) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var names []string
+	for _, k := range keys {
+		for _, name := range strings.Split(annotations[k], ",") {
+			name = strings.TrimSpace(name)
+			if _, _, err := parseQualifiedName(name); err != nil {
+				return nil, fmt.Errorf("Invalid CDI device in annotation %s: %v", k, err)
+			}
+			names = append(names, name)
+		}
+	}
+
+	return names, nil
+}
+
+// parseQualifiedName splits a "vendor.com/class=name" device name into its
+// kind and name.
+func parseQualifiedName(qualified string) (string, string, error) {
+	i := strings.LastIndex(qualified, "=")
+	if i <= 0 || i == len(qualified)-1 {
+		return "", "", fmt.Errorf("%q is not a vendor.com/class=name device name", qualified)
+	}
+
+	kind := qualified[:i]
+	if j := strings.Index(kind, "/"); j <= 0 || j == len(kind)-1 {
+		return "", "", fmt.Errorf("%q is not a vendor.com/class=name device name", qualified)
+	}
+
+	return kind, qualified[i+1:], nil
+}
+
+// loadSpecs loads the CDI specs of the directories and returns their
+// devices keyed by qualified name, the directories missing being skipped.
+func loadSpecs(dirs []string) (map[string]device, error) {
+	devices := make(map[string]device)
+
+	for _, dir := range dirs {
+		entries, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			switch filepath.Ext(e.Name()) {
+			case ".json", ".yaml":
+			default:
+				continue
+			}
+
+			path := filepath.Join(dir, e.Name())
+
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+
+			// JSON being YAML, both are parsed the same way
+			spec := &Spec{}
+			if err := yaml.Unmarshal(data, spec); err != nil {
+				return nil, fmt.Errorf("Invalid CDI spec %s: %v", path, err)
+			}
+
+			if _, _, err := parseQualifiedName(spec.Kind + "=x"); err != nil {
+				return nil, fmt.Errorf("Invalid kind %q of CDI spec %s", spec.Kind, path)
+			}
+
+			for i := range spec.Devices {
+				d := &spec.Devices[i]
+				devices[spec.Kind+"="+d.Name] = device{spec: spec, device: d}
+			}
+		}
+	}
+
+	return devices, nil
+}
+
+// InjectDevices applies to the OCI spec the container edits of the CDI
+// devices its annotations request, with the specs of the directories. It
+// returns the guest kernel modules the devices need.
+func InjectDevices(ociSpec *specs.Spec, dirs []string) ([]string, error) {
+	names, err := RequestedDevices(ociSpec.Annotations)
+	if err != nil || len(names) == 0 {
+		return nil, err
+	}
+
+	devices, err := loadSpecs(dirs)
+	if err != nil {
+		return nil, err
+	}
+
+	var modules []string
+	addModules := func(annotations map[string]string) {
+		value, ok := annotations[vcAnnotations.KernelModules]
+		if !ok {
+			return
+		}
+
+		for _, m := range strings.Split(value, ";") {
+			if m = strings.TrimSpace(m); m != "" && !contains(modules, m) {
+				modules = append(modules, m)
+			}
+		}
+	}
+
+	applied := make(map[*Spec]bool)
+	for _, name := range names {
+		d, ok := devices[name]
+		if !ok {
+			return nil, fmt.Errorf("Unknown CDI device %s", name)
+		}
+
+		// the edits of a spec apply once, whatever the number of its
+		// devices requested
+		if !applied[d.spec] {
+			if err := d.spec.ContainerEdits.apply(ociSpec); err != nil {
+				return nil, fmt.Errorf("CDI device %s: %v", name, err)
+			}
+			addModules(d.spec.Annotations)
+			applied[d.spec] = true
+		}
+
+		if err := d.device.ContainerEdits.apply(ociSpec); err != nil {
+			return nil, fmt.Errorf("CDI device %s: %v", name, err)
+		}
+		addModules(d.device.Annotations)
+	}
+
+	return modules, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// apply applies the container edits to the OCI spec.
+func (e *ContainerEdits) apply(ociSpec *specs.Spec) error {
+	if len(e.Env) > 0 {
+		if ociSpec.Process == nil {
+			ociSpec.Process = &specs.Process{}
+		}
+		ociSpec.Process.Env = append(ociSpec.Process.Env, e.Env...)
+	}
+
+	for _, n := range e.DeviceNodes {
+		dev, err := n.linuxDevice()
+		if err != nil {
+			return err
+		}
+
+		if ociSpec.Linux == nil {
+			ociSpec.Linux = &specs.Linux{}
+		}
+		ociSpec.Linux.Devices = append(ociSpec.Linux.Devices, dev)
+
+		if ociSpec.Linux.Resources == nil {
+			ociSpec.Linux.Resources = &specs.LinuxResources{}
+		}
+
+		access := n.Permissions
+		if access == "" {
+			access = "rwm"
+		}
+
+		major, minor := dev.Major, dev.Minor
+		ociSpec.Linux.Resources.Devices = append(ociSpec.Linux.Resources.Devices, specs.LinuxDeviceCgroup{
+			Allow:  true,
+			Type:   dev.Type,
+			Major:  &major,
+			Minor:  &minor,
+			Access: access,
+		})
+	}
+
+	for _, m := range e.Mounts {
+		mntType := m.Type
+		if mntType == "" {
+			mntType = "bind"
+		}
+
+		ociSpec.Mounts = append(ociSpec.Mounts, specs.Mount{
+			Source:      m.HostPath,
+			Destination: m.ContainerPath,
+			Type:        mntType,
+			Options:     m.Options,
+		})
+	}
+
+	return nil
+}
+
+// linuxDevice returns the OCI device of the node, completed with the type
+// and numbers of the host device.
+func (n *DeviceNode) linuxDevice() (specs.LinuxDevice, error) {
+	if n.Path == "" {
+		return specs.LinuxDevice{}, fmt.Errorf("Missing path of device node")
+	}
+
+	dev := specs.LinuxDevice{
+		Path:     n.Path,
+		Type:     n.Type,
+		Major:    n.Major,
+		Minor:    n.Minor,
+		FileMode: n.FileMode,
+		UID:      n.UID,
+		GID:      n.GID,
+	}
+
+	if dev.Type != "" && (dev.Major != 0 || dev.Minor != 0) {
+		return dev, nil
+	}
+
+	hostPath := n.HostPath
+	if hostPath == "" {
+		hostPath = n.Path
+	}
+
+	var st unix.Stat_t
+	if err := unix.Stat(hostPath, &st); err != nil {
+		return specs.LinuxDevice{}, fmt.Errorf("Could not stat device %s: %v", hostPath, err)
+	}
+
+	switch st.Mode & unix.S_IFMT {
+	case unix.S_IFCHR:
+		dev.Type = "c"
+	case unix.S_IFBLK:
+		dev.Type = "b"
+	default:
+		return specs.LinuxDevice{}, fmt.Errorf("%s is not a device", hostPath)
+	}
+
+	dev.Major = int64(unix.Major(st.Rdev))
+	dev.Minor = int64(unix.Minor(st.Rdev))
+
+	return dev, nil
+}
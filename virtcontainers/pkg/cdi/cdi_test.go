@@ -0,0 +1,172 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package cdi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const testGPUSpec = `{
+	"cdiVersion": "0.2.0",
+	"kind": "vendor.com/gpu",
+	"annotations": {
+		"io.katacontainers.config.agent.kernel_modules": "gpu_core"
+	},
+	"containerEdits": {
+		"env": ["GPU_DRIVER=1"]
+	},
+	"devices": [
+		{
+			"name": "gpu0",
+			"annotations": {
+				"io.katacontainers.config.agent.kernel_modules": "gpu_core;gpu_uvm debug=1"
+			},
+			"containerEdits": {
+				"deviceNodes": [
+					{"path": "/dev/vfio/42", "type": "c", "major": 240, "minor": 42}
+				],
+				"mounts": [
+					{"hostPath": "/usr/lib/gpu", "containerPath": "/usr/lib/gpu", "options": ["ro", "rbind"]}
+				]
+			}
+		},
+		{
+			"name": "gpu1",
+			"containerEdits": {
+				"deviceNodes": [
+					{"path": "/dev/gpu1", "hostPath": "/dev/null"}
+				]
+			}
+		}
+	]
+}`
+
+const testNICSpec = `cdiVersion: 0.2.0
+kind: vendor.com/nic
+devices:
+  - name: nic0
+    containerEdits:
+      env:
+        - NIC=0
+`
+
+func TestRequestedDevices(t *testing.T) {
+	assert := assert.New(t)
+
+	names, err := RequestedDevices(map[string]string{
+		"io.kubernetes.cri.sandbox-id": "foo",
+		"cdi.k8s.io/nic":               "vendor.com/nic=nic0",
+		"cdi.k8s.io/gpu":               "vendor.com/gpu=gpu0, vendor.com/gpu=gpu1",
+	})
+	assert.NoError(err)
+	assert.Equal([]string{"vendor.com/gpu=gpu0", "vendor.com/gpu=gpu1", "vendor.com/nic=nic0"}, names)
+
+	names, err = RequestedDevices(nil)
+	assert.NoError(err)
+	assert.Empty(names)
+
+	for _, name := range []string{"gpu0", "vendor.com/gpu", "vendor.com/gpu=", "gpu=gpu0", "/gpu=gpu0"} {
+		_, err = RequestedDevices(map[string]string{"cdi.k8s.io/gpu": name})
+		assert.Error(err, name)
+	}
+}
+
+func TestInjectDevices(t *testing.T) {
+	assert := assert.New(t)
+
+	etcDir, err := ioutil.TempDir("", "cdi")
+	assert.NoError(err)
+	defer os.RemoveAll(etcDir)
+
+	runDir, err := ioutil.TempDir("", "cdi")
+	assert.NoError(err)
+	defer os.RemoveAll(runDir)
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(etcDir, "gpu.json"), []byte(testGPUSpec), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(etcDir, "README"), []byte("not a spec"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(runDir, "nic.yaml"), []byte(testNICSpec), 0644))
+
+	dirs := []string{etcDir, runDir, "/does/not/exist"}
+
+	ociSpec := &specs.Spec{
+		Annotations: map[string]string{
+			"cdi.k8s.io/gpu": "vendor.com/gpu=gpu0,vendor.com/gpu=gpu1",
+			"cdi.k8s.io/nic": "vendor.com/nic=nic0",
+		},
+	}
+
+	modules, err := InjectDevices(ociSpec, dirs)
+	assert.NoError(err)
+	assert.Equal([]string{"gpu_core", "gpu_uvm debug=1"}, modules)
+
+	// the edits of the gpu spec apply once
+	assert.Equal([]string{"GPU_DRIVER=1", "NIC=0"}, ociSpec.Process.Env)
+
+	assert.Equal([]specs.LinuxDevice{
+		{Path: "/dev/vfio/42", Type: "c", Major: 240, Minor: 42},
+		{Path: "/dev/gpu1", Type: "c", Major: 1, Minor: 3},
+	}, ociSpec.Linux.Devices)
+
+	assert.Len(ociSpec.Linux.Resources.Devices, 2)
+	assert.True(ociSpec.Linux.Resources.Devices[0].Allow)
+	assert.Equal("rwm", ociSpec.Linux.Resources.Devices[0].Access)
+
+	assert.Equal([]specs.Mount{
+		{Source: "/usr/lib/gpu", Destination: "/usr/lib/gpu", Type: "bind", Options: []string{"ro", "rbind"}},
+	}, ociSpec.Mounts)
+
+	// no CDI device requested
+	ociSpec = &specs.Spec{}
+	modules, err = InjectDevices(ociSpec, dirs)
+	assert.NoError(err)
+	assert.Empty(modules)
+	assert.Nil(ociSpec.Linux)
+
+	ociSpec = &specs.Spec{
+		Annotations: map[string]string{
+			"cdi.k8s.io/gpu": "vendor.com/gpu=gpu7",
+		},
+	}
+	_, err = InjectDevices(ociSpec, dirs)
+	assert.Error(err)
+}
+
+func TestInjectDevicesInvalidSpec(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "cdi")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	ociSpec := &specs.Spec{
+		Annotations: map[string]string{
+			"cdi.k8s.io/gpu": "vendor.com/gpu=gpu0",
+		},
+	}
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "gpu.yaml"), []byte("kind: gpu\n"), 0644))
+	_, err = InjectDevices(ociSpec, []string{dir})
+	assert.Error(err)
+
+	// the host device is not a device
+	spec := `kind: vendor.com/gpu
+devices:
+  - name: gpu0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/gpu0
+          hostPath: ` + dir + `
+`
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "gpu.yaml"), []byte(spec), 0644))
+	_, err = InjectDevices(ociSpec, []string{dir})
+	assert.Error(err)
+}
@@ -257,7 +257,13 @@ func (m *Manager) moveToParent() error {
 		}
 
 		cgroupParentPath := filepath.Dir(filepath.Clean(cgroupPath))
-		if err = writePids(pids, cgroupParentPath); err != nil {
+		err = writePids(pids, cgroupParentPath)
+		if err != nil && IsCgroupV2() && strings.Contains(err.Error(), "device or resource busy") {
+			// cgroups v2 doesn't allow processes in a cgroup that has controllers
+			// enabled for its children, the root cgroup is the only exception.
+			err = writePids(pids, cgroupV2Root)
+		}
+		if err != nil {
 			if !strings.Contains(err.Error(), "no such process") {
 				return err
 			}
@@ -0,0 +1,443 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package cgroups
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/containerd/cgroups"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// file in the cgroup that contains the threads, only for threaded cgroups
+	cgroupThreads = "cgroup.threads"
+
+	cgroupControllers    = "cgroup.controllers"
+	cgroupSubtreeControl = "cgroup.subtree_control"
+	cgroupFreeze         = "cgroup.freeze"
+
+	// unlimited value for the v2 interface files
+	cgroupV2Max = "max"
+)
+
+var (
+	// cgroupV2Root is the mount point of the unified hierarchy
+	cgroupV2Root = "/sys/fs/cgroup"
+
+	// controllers kata enables for the cgroups it creates
+	cgroupV2Controllers = []string{"cpuset", "cpu", "io", "memory", "pids"}
+)
+
+// IsCgroupV2 returns true if the host uses the cgroups v2 unified hierarchy
+func IsCgroupV2() bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(cgroupV2Root, &st); err != nil {
+		return false
+	}
+	return st.Type == unix.CGROUP2_SUPER_MAGIC
+}
+
+// V2Cgroup is a cgroup of the unified hierarchy. It implements the
+// containerd cgroups.Cgroup interface, so that callers can deal with both
+// hierarchies the same way.
+type V2Cgroup struct {
+	// path relative to the unified hierarchy mount point
+	path string
+}
+
+// NewV2 creates the cgroup path in the unified hierarchy, enables the
+// controllers kata relies on and applies resources to it.
+func NewV2(path string, resources *specs.LinuxResources) (cgroups.Cgroup, error) {
+	cg := &V2Cgroup{path: filepath.Clean("/" + path)}
+
+	if err := os.MkdirAll(cg.dir(), 0755); err != nil {
+		return nil, err
+	}
+
+	cg.enableControllers()
+
+	if err := cg.Update(resources); err != nil {
+		return nil, err
+	}
+
+	return cg, nil
+}
+
+// LoadV2 loads an existing cgroup of the unified hierarchy
+func LoadV2(path string) (cgroups.Cgroup, error) {
+	cg := &V2Cgroup{path: filepath.Clean("/" + path)}
+
+	if _, err := os.Stat(cg.dir()); err != nil {
+		if os.IsNotExist(err) {
+			return nil, cgroups.ErrCgroupDeleted
+		}
+		return nil, err
+	}
+
+	return cg, nil
+}
+
+func (c *V2Cgroup) dir() string {
+	return filepath.Join(cgroupV2Root, c.path)
+}
+
+func (c *V2Cgroup) write(file, value string) error {
+	return ioutil.WriteFile(filepath.Join(c.dir(), file), []byte(value), os.FileMode(0))
+}
+
+func (c *V2Cgroup) read(file string) (string, error) {
+	data, err := ioutil.ReadFile(filepath.Join(c.dir(), file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// enableControllers enables the kata controllers in the subtree_control
+// of all the ancestors, controllers not available on the host are skipped.
+func (c *V2Cgroup) enableControllers() {
+	dir := cgroupV2Root
+	for _, elem := range strings.Split(strings.Trim(c.path, "/"), "/") {
+		data, err := ioutil.ReadFile(filepath.Join(dir, cgroupControllers))
+		if err != nil {
+			cgroupsLogger.WithError(err).WithField("cgroup", dir).Debug("Could not read available controllers")
+			return
+		}
+
+		available := strings.Fields(string(data))
+		for _, ctrl := range cgroupV2Controllers {
+			if !contains(available, ctrl) {
+				continue
+			}
+			if err := ioutil.WriteFile(filepath.Join(dir, cgroupSubtreeControl), []byte("+"+ctrl), os.FileMode(0)); err != nil {
+				cgroupsLogger.WithError(err).WithField("controller", ctrl).Debug("Could not enable controller")
+			}
+		}
+
+		dir = filepath.Join(dir, elem)
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// New creates a new cgroup under the calling cgroup
+func (c *V2Cgroup) New(name string, resources *specs.LinuxResources) (cgroups.Cgroup, error) {
+	return NewV2(filepath.Join(c.path, name), resources)
+}
+
+// Add adds a process to the cgroup
+func (c *V2Cgroup) Add(p cgroups.Process) error {
+	return c.write(cgroupProcs, strconv.Itoa(p.Pid))
+}
+
+// AddTask adds a thread to the cgroup, this only works for threaded cgroups
+func (c *V2Cgroup) AddTask(p cgroups.Process) error {
+	return c.write(cgroupThreads, strconv.Itoa(p.Pid))
+}
+
+// Delete removes the cgroup
+func (c *V2Cgroup) Delete() error {
+	if err := unix.Rmdir(c.dir()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// MoveTo moves all the processes of the cgroup to destination
+func (c *V2Cgroup) MoveTo(destination cgroups.Cgroup) error {
+	dest, ok := destination.(*V2Cgroup)
+	if !ok {
+		return fmt.Errorf("Could not move processes to a non cgroup v2 destination")
+	}
+
+	pids, err := readPids(c.dir())
+	if err != nil {
+		return err
+	}
+
+	if err := writePids(pids, dest.dir()); err != nil {
+		if !strings.Contains(err.Error(), "no such process") {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Stat returns the cpu, memory and pids stats of the cgroup
+func (c *V2Cgroup) Stat(handlers ...cgroups.ErrorHandler) (*cgroups.Metrics, error) {
+	handleErr := func(err error) error {
+		for _, h := range handlers {
+			if err = h(err); err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+
+	metrics := &cgroups.Metrics{
+		CPU: &cgroups.CPUStat{
+			Usage:      &cgroups.CPUUsage{},
+			Throttling: &cgroups.Throttle{},
+		},
+		Memory: &cgroups.MemoryStat{
+			Usage: &cgroups.MemoryEntry{},
+		},
+		Pids: &cgroups.PidsStat{},
+	}
+
+	cpuStat, err := c.readKeyValues("cpu.stat")
+	if err != nil {
+		if err = handleErr(err); err != nil {
+			return nil, err
+		}
+	}
+	// cgroups v2 reports microseconds, v1 nanoseconds
+	metrics.CPU.Usage.Total = cpuStat["usage_usec"] * 1000
+	metrics.CPU.Usage.User = cpuStat["user_usec"] * 1000
+	metrics.CPU.Usage.Kernel = cpuStat["system_usec"] * 1000
+	metrics.CPU.Throttling.Periods = cpuStat["nr_periods"]
+	metrics.CPU.Throttling.ThrottledPeriods = cpuStat["nr_throttled"]
+	metrics.CPU.Throttling.ThrottledTime = cpuStat["throttled_usec"] * 1000
+
+	for file, value := range map[string]*uint64{
+		"memory.current": &metrics.Memory.Usage.Usage,
+		"memory.max":     &metrics.Memory.Usage.Limit,
+		"pids.current":   &metrics.Pids.Current,
+		"pids.max":       &metrics.Pids.Limit,
+	} {
+		v, err := c.readUint(file)
+		if err != nil {
+			if err = handleErr(err); err != nil {
+				return nil, err
+			}
+		}
+		*value = v
+	}
+
+	return metrics, nil
+}
+
+func (c *V2Cgroup) readUint(file string) (uint64, error) {
+	v, err := c.read(file)
+	if err != nil {
+		return 0, err
+	}
+
+	if v == cgroupV2Max {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(v, 10, 64)
+}
+
+func (c *V2Cgroup) readKeyValues(file string) (map[string]uint64, error) {
+	values := make(map[string]uint64)
+
+	f, err := os.Open(filepath.Join(c.dir(), file))
+	if err != nil {
+		return values, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = v
+	}
+
+	return values, scanner.Err()
+}
+
+// convertCPUSharesToWeight converts cpu.shares [2-262144] to cpu.weight [1-10000]
+func convertCPUSharesToWeight(shares uint64) uint64 {
+	if shares == 0 {
+		return 0
+	}
+	return 1 + ((shares-2)*9999)/262142
+}
+
+// convertBlkIOWeightToIOWeight converts blkio.weight [10-1000] to io.weight [1-10000]
+func convertBlkIOWeightToIOWeight(weight uint16) uint64 {
+	if weight == 0 {
+		return 0
+	}
+	return 1 + (uint64(weight)-10)*9999/990
+}
+
+// Update applies resources to the cgroup: cpuset, cpu.max, cpu.weight,
+// memory.max, io.weight and pids.max are supported.
+func (c *V2Cgroup) Update(resources *specs.LinuxResources) error {
+	if resources == nil {
+		return nil
+	}
+
+	var values [][2]string
+
+	if cpu := resources.CPU; cpu != nil {
+		if cpu.Cpus != "" {
+			values = append(values, [2]string{"cpuset.cpus", cpu.Cpus})
+		}
+		if cpu.Mems != "" {
+			values = append(values, [2]string{"cpuset.mems", cpu.Mems})
+		}
+		if cpu.Shares != nil && *cpu.Shares > 0 {
+			values = append(values, [2]string{"cpu.weight", strconv.FormatUint(convertCPUSharesToWeight(*cpu.Shares), 10)})
+		}
+		if cpu.Period != nil && *cpu.Period > 0 {
+			quota := cgroupV2Max
+			if cpu.Quota != nil && *cpu.Quota > 0 {
+				quota = strconv.FormatInt(*cpu.Quota, 10)
+			}
+			values = append(values, [2]string{"cpu.max", fmt.Sprintf("%s %d", quota, *cpu.Period)})
+		}
+	}
+
+	if mem := resources.Memory; mem != nil && mem.Limit != nil && *mem.Limit != 0 {
+		limit := cgroupV2Max
+		if *mem.Limit > 0 {
+			limit = strconv.FormatInt(*mem.Limit, 10)
+		}
+		values = append(values, [2]string{"memory.max", limit})
+	}
+
+	if blkio := resources.BlockIO; blkio != nil && blkio.Weight != nil && *blkio.Weight > 0 {
+		values = append(values, [2]string{"io.weight", strconv.FormatUint(convertBlkIOWeightToIOWeight(*blkio.Weight), 10)})
+	}
+
+	if pids := resources.Pids; pids != nil && pids.Limit != 0 {
+		limit := cgroupV2Max
+		if pids.Limit > 0 {
+			limit = strconv.FormatInt(pids.Limit, 10)
+		}
+		values = append(values, [2]string{"pids.max", limit})
+	}
+
+	for _, v := range values {
+		if err := c.write(v[0], v[1]); err != nil {
+			return fmt.Errorf("Could not write %q to %s: %v", v[1], v[0], err)
+		}
+	}
+
+	return nil
+}
+
+// Processes returns the processes of the cgroup, there is a single
+// hierarchy so the subsystem is ignored
+func (c *V2Cgroup) Processes(_ cgroups.Name, recursive bool) ([]cgroups.Process, error) {
+	var processes []cgroups.Process
+
+	err := c.walk(recursive, func(dir string) error {
+		pids, err := readPids(dir)
+		if err != nil {
+			return err
+		}
+		for _, pid := range pids {
+			processes = append(processes, cgroups.Process{Pid: pid, Path: dir})
+		}
+		return nil
+	})
+
+	return processes, err
+}
+
+// Tasks returns the threads of the cgroup, there is a single hierarchy
+// so the subsystem is ignored
+func (c *V2Cgroup) Tasks(_ cgroups.Name, recursive bool) ([]cgroups.Task, error) {
+	var tasks []cgroups.Task
+
+	err := c.walk(recursive, func(dir string) error {
+		data, err := ioutil.ReadFile(filepath.Join(dir, cgroupThreads))
+		if err != nil {
+			return err
+		}
+		for _, t := range strings.Fields(string(data)) {
+			tid, err := strconv.Atoi(t)
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, cgroups.Task{Pid: tid, Path: dir})
+		}
+		return nil
+	})
+
+	return tasks, err
+}
+
+func (c *V2Cgroup) walk(recursive bool, fn func(dir string) error) error {
+	if !recursive {
+		return fn(c.dir())
+	}
+
+	return filepath.Walk(c.dir(), func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		return fn(p)
+	})
+}
+
+// Freeze freezes all the processes of the cgroup
+func (c *V2Cgroup) Freeze() error {
+	return c.write(cgroupFreeze, "1")
+}
+
+// Thaw resumes all the processes of the cgroup
+func (c *V2Cgroup) Thaw() error {
+	return c.write(cgroupFreeze, "0")
+}
+
+// OOMEventFD is not supported, cgroups v2 reports OOM events in memory.events
+func (c *V2Cgroup) OOMEventFD() (uintptr, error) {
+	return 0, fmt.Errorf("OOM event fd is not supported by cgroups v2")
+}
+
+// State returns the freezer state of the cgroup
+func (c *V2Cgroup) State() cgroups.State {
+	if _, err := os.Stat(c.dir()); err != nil {
+		return cgroups.Deleted
+	}
+
+	v, err := c.read(cgroupFreeze)
+	if err != nil {
+		return cgroups.Unknown
+	}
+
+	if v == "1" {
+		return cgroups.Frozen
+	}
+	return cgroups.Thawed
+}
+
+// Subsystems returns nil, cgroups v2 has no per controller hierarchy
+func (c *V2Cgroup) Subsystems() []cgroups.Subsystem {
+	return nil
+}
@@ -0,0 +1,212 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package cgroups
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/containerd/cgroups"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCgroupV2Root(t *testing.T) func() {
+	tmpdir, err := ioutil.TempDir("", "cgroupv2")
+	assert.NoError(t, err)
+
+	savedRoot := cgroupV2Root
+	cgroupV2Root = tmpdir
+
+	return func() {
+		cgroupV2Root = savedRoot
+		os.RemoveAll(tmpdir)
+	}
+}
+
+func readCgroupV2File(t *testing.T, path, file string) string {
+	data, err := ioutil.ReadFile(filepath.Join(cgroupV2Root, path, file))
+	assert.NoError(t, err)
+	return string(data)
+}
+
+func TestIsCgroupV2(t *testing.T) {
+	defer mockCgroupV2Root(t)()
+
+	// a temporary directory is not a cgroup2 file system
+	assert.False(t, IsCgroupV2())
+
+	cgroupV2Root = "/does/not/exist"
+	assert.False(t, IsCgroupV2())
+}
+
+func TestConvertCgroupV2Weights(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(uint64(0), convertCPUSharesToWeight(0))
+	assert.Equal(uint64(1), convertCPUSharesToWeight(2))
+	assert.Equal(uint64(39), convertCPUSharesToWeight(1024))
+	assert.Equal(uint64(10000), convertCPUSharesToWeight(262144))
+
+	assert.Equal(uint64(0), convertBlkIOWeightToIOWeight(0))
+	assert.Equal(uint64(1), convertBlkIOWeightToIOWeight(10))
+	assert.Equal(uint64(10000), convertBlkIOWeightToIOWeight(1000))
+}
+
+func TestV2CgroupUpdate(t *testing.T) {
+	defer mockCgroupV2Root(t)()
+	assert := assert.New(t)
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(cgroupV2Root, cgroupControllers), []byte("cpuset cpu io memory pids"), 0644))
+
+	shares := uint64(1024)
+	quota := int64(50000)
+	period := uint64(100000)
+	memLimit := int64(512 * 1024 * 1024)
+	weight := uint16(500)
+
+	resources := &specs.LinuxResources{
+		CPU: &specs.LinuxCPU{
+			Cpus:   "0-1",
+			Mems:   "0",
+			Shares: &shares,
+			Quota:  &quota,
+			Period: &period,
+		},
+		Memory: &specs.LinuxMemory{
+			Limit: &memLimit,
+		},
+		BlockIO: &specs.LinuxBlockIO{
+			Weight: &weight,
+		},
+		Pids: &specs.LinuxPids{
+			Limit: -1,
+		},
+	}
+
+	cg, err := NewV2("/kata/sandbox", resources)
+	assert.NoError(err)
+
+	assert.Equal("+pids", readCgroupV2File(t, "/", cgroupSubtreeControl))
+	assert.Equal("0-1", readCgroupV2File(t, "/kata/sandbox", "cpuset.cpus"))
+	assert.Equal("0", readCgroupV2File(t, "/kata/sandbox", "cpuset.mems"))
+	assert.Equal("39", readCgroupV2File(t, "/kata/sandbox", "cpu.weight"))
+	assert.Equal("50000 100000", readCgroupV2File(t, "/kata/sandbox", "cpu.max"))
+	assert.Equal("536870912", readCgroupV2File(t, "/kata/sandbox", "memory.max"))
+	assert.Equal("4950", readCgroupV2File(t, "/kata/sandbox", "io.weight"))
+	assert.Equal("max", readCgroupV2File(t, "/kata/sandbox", "pids.max"))
+
+	// no quota means unlimited
+	resources.CPU.Quota = nil
+	assert.NoError(cg.Update(resources))
+	assert.Equal("max 100000", readCgroupV2File(t, "/kata/sandbox", "cpu.max"))
+
+	assert.NoError(cg.Update(nil))
+}
+
+func TestV2CgroupLoad(t *testing.T) {
+	defer mockCgroupV2Root(t)()
+	assert := assert.New(t)
+
+	_, err := LoadV2("/kata/notfound")
+	assert.Equal(cgroups.ErrCgroupDeleted, err)
+
+	cg, err := NewV2("/kata/sandbox", nil)
+	assert.NoError(err)
+
+	child, err := cg.New("child", nil)
+	assert.NoError(err)
+
+	_, err = LoadV2("/kata/sandbox/child")
+	assert.NoError(err)
+
+	assert.NoError(child.Delete())
+	_, err = LoadV2("/kata/sandbox/child")
+	assert.Equal(cgroups.ErrCgroupDeleted, err)
+
+	// deleting twice is not an error
+	assert.NoError(child.Delete())
+	assert.Equal(cgroups.Deleted, child.State())
+}
+
+func TestV2CgroupProcesses(t *testing.T) {
+	defer mockCgroupV2Root(t)()
+	assert := assert.New(t)
+
+	cg, err := NewV2("/kata/sandbox", nil)
+	assert.NoError(err)
+
+	assert.NoError(cg.Add(cgroups.Process{Pid: 1234}))
+	assert.NoError(cg.AddTask(cgroups.Process{Pid: 5678}))
+
+	procs, err := cg.Processes(cgroups.Cpu, false)
+	assert.NoError(err)
+	assert.Len(procs, 1)
+	assert.Equal(1234, procs[0].Pid)
+
+	tasks, err := cg.Tasks(cgroups.Cpu, false)
+	assert.NoError(err)
+	assert.Len(tasks, 1)
+	assert.Equal(5678, tasks[0].Pid)
+
+	dest, err := NewV2("/kata/dest", nil)
+	assert.NoError(err)
+
+	assert.NoError(cg.MoveTo(dest))
+	assert.Equal("1234", readCgroupV2File(t, "/kata/dest", cgroupProcs))
+}
+
+func TestV2CgroupStat(t *testing.T) {
+	defer mockCgroupV2Root(t)()
+	assert := assert.New(t)
+
+	cg, err := NewV2("/kata/sandbox", nil)
+	assert.NoError(err)
+
+	_, err = cg.Stat()
+	assert.Error(err)
+
+	dir := filepath.Join(cgroupV2Root, "/kata/sandbox")
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "cpu.stat"), []byte("usage_usec 30\nuser_usec 20\nsystem_usec 10\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "memory.current"), []byte("4096\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "memory.max"), []byte("max\n"), 0644))
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "pids.current"), []byte("3\n"), 0644))
+
+	// pids.max is missing
+	_, err = cg.Stat()
+	assert.Error(err)
+
+	metrics, err := cg.Stat(cgroups.IgnoreNotExist)
+	assert.NoError(err)
+	assert.Equal(uint64(30000), metrics.CPU.Usage.Total)
+	assert.Equal(uint64(20000), metrics.CPU.Usage.User)
+	assert.Equal(uint64(10000), metrics.CPU.Usage.Kernel)
+	assert.Equal(uint64(4096), metrics.Memory.Usage.Usage)
+	assert.Equal(uint64(0), metrics.Memory.Usage.Limit)
+	assert.Equal(uint64(3), metrics.Pids.Current)
+}
+
+func TestV2CgroupFreeze(t *testing.T) {
+	defer mockCgroupV2Root(t)()
+	assert := assert.New(t)
+
+	cg, err := NewV2("/kata/sandbox", nil)
+	assert.NoError(err)
+
+	assert.Equal(cgroups.Unknown, cg.State())
+
+	assert.NoError(cg.Freeze())
+	assert.Equal(cgroups.Frozen, cg.State())
+
+	assert.NoError(cg.Thaw())
+	assert.Equal(cgroups.Thawed, cg.State())
+
+	_, err = cg.OOMEventFD()
+	assert.Error(err)
+	assert.Nil(cg.Subsystems())
+}
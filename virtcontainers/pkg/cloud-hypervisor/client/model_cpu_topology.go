@@ -0,0 +1,18 @@
+/*
+ * Cloud Hypervisor API
+ *
+ * Local HTTP based API for managing and inspecting a cloud-hypervisor virtual machine.
+ *
+ * API version: 0.3.0
+ * Generated by: OpenAPI Generator (https://openapi-generator.tech)
+ */
+
+package openapi
+
+// CpuTopology struct for CpuTopology
+type CpuTopology struct {
+	ThreadsPerCore int32 `json:"threads_per_core,omitempty"`
+	CoresPerDie    int32 `json:"cores_per_die,omitempty"`
+	DiesPerPackage int32 `json:"dies_per_package,omitempty"`
+	Packages       int32 `json:"packages,omitempty"`
+}
@@ -8,8 +8,10 @@
  */
 
 package openapi
+
 // CpusConfig struct for CpusConfig
 type CpusConfig struct {
-	BootVcpus int32 `json:"boot_vcpus"`
-	MaxVcpus int32 `json:"max_vcpus"`
+	BootVcpus int32        `json:"boot_vcpus"`
+	MaxVcpus  int32        `json:"max_vcpus"`
+	Topology  *CpuTopology `json:"topology,omitempty"`
 }
@@ -0,0 +1,254 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package cni invokes the CNI plugins of a network configuration to set up
+// and tear down the network namespaces the runtime creates, for the users
+// not running a container manager doing it for them.
+//
+// The plugins are invoked as by libcni: the first configuration of the
+// configuration directory, in lexical order, is used, its plugins being
+// chained on ADD and invoked in reverse order on DEL. The name of the
+// network namespace is the container ID passed to the plugins.
+package cni
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/containernetworking/cni/pkg/types"
+)
+
+const (
+	// DefaultConfDir is the default directory of the network configurations.
+	DefaultConfDir = "/etc/cni/net.d"
+
+	// DefaultBinDir is the default directory of the plugin binaries.
+	DefaultBinDir = "/opt/cni/bin"
+
+	// IfName is the name of the interface the plugins create in the network
+	// namespace.
+	IfName = "eth0"
+)
+
+// CacheDir is where the results of the ADD commands are kept, to be passed
+// to the DEL commands.
+var CacheDir = "/var/lib/cni/results"
+
+// Network is a network configuration, a list of plugins.
+type Network struct {
+	Name       string
+	CNIVersion string
+	Path       string
+
+	plugins []map[string]interface{}
+}
+
+// LoadNetwork loads the first network configuration of the directory, a
+// .conflist file or a single plugin .conf or .json file.
+func LoadNetwork(confDir string) (*Network, error) {
+	entries, err := ioutil.ReadDir(confDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".conflist", ".conf", ".json":
+			files = append(files, e.Name())
+		}
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("No CNI network configuration found in %s", confDir)
+	}
+
+	path := filepath.Join(confDir, files[0])
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var conf struct {
+		Name       string                   `json:"name"`
+		CNIVersion string                   `json:"cniVersion"`
+		Type       string                   `json:"type"`
+		Plugins    []map[string]interface{} `json:"plugins"`
+	}
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, fmt.Errorf("Invalid CNI network configuration %s: %v", path, err)
+	}
+
+	n := &Network{
+		Name:       conf.Name,
+		CNIVersion: conf.CNIVersion,
+		Path:       path,
+		plugins:    conf.Plugins,
+	}
+
+	if filepath.Ext(path) != ".conflist" {
+		var plugin map[string]interface{}
+		if err := json.Unmarshal(data, &plugin); err != nil {
+			return nil, fmt.Errorf("Invalid CNI network configuration %s: %v", path, err)
+		}
+		n.plugins = []map[string]interface{}{plugin}
+	}
+
+	if n.Name == "" {
+		return nil, fmt.Errorf("Missing name of CNI network %s", path)
+	}
+
+	if len(n.plugins) == 0 {
+		return nil, fmt.Errorf("No plugin in CNI network %s", path)
+	}
+
+	for _, p := range n.plugins {
+		if t, _ := p["type"].(string); t == "" {
+			return nil, fmt.Errorf("Missing plugin type in CNI network %s", path)
+		}
+	}
+
+	return n, nil
+}
+
+// ContainerID returns the container ID passed to the plugins for the
+// network namespace.
+func ContainerID(netNSPath string) string {
+	return filepath.Base(netNSPath)
+}
+
+// cachePath returns the file caching the result of the network namespace.
+func (n *Network) cachePath(netNSPath string) string {
+	return filepath.Join(CacheDir, fmt.Sprintf("%s-%s-%s", n.Name, ContainerID(netNSPath), IfName))
+}
+
+// Add sets up the network namespace, returning the result of the last
+// plugin.
+func (n *Network) Add(ctx context.Context, binDirs []string, netNSPath string) ([]byte, error) {
+	var result []byte
+
+	for _, p := range n.plugins {
+		out, err := n.exec(ctx, "ADD", p, result, binDirs, netNSPath)
+		if err != nil {
+			return nil, err
+		}
+		result = out
+	}
+
+	if err := os.MkdirAll(CacheDir, 0700); err != nil {
+		return nil, err
+	}
+
+	if err := ioutil.WriteFile(n.cachePath(netNSPath), result, 0600); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// Del tears down the network namespace, all the plugins being invoked even
+// when some of them fail.
+func (n *Network) Del(ctx context.Context, binDirs []string, netNSPath string) error {
+	cache := n.cachePath(netNSPath)
+
+	// the plugins cope without the result of ADD
+	result, err := ioutil.ReadFile(cache)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	var delErr error
+	for i := len(n.plugins) - 1; i >= 0; i-- {
+		if _, err := n.exec(ctx, "DEL", n.plugins[i], result, binDirs, netNSPath); err != nil && delErr == nil {
+			delErr = err
+		}
+	}
+
+	if delErr != nil {
+		return delErr
+	}
+
+	if err := os.Remove(cache); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// exec invokes the plugin, passing it the network configuration and the
+// previous result on its standard input.
+func (n *Network) exec(ctx context.Context, command string, plugin map[string]interface{}, prevResult []byte, binDirs []string, netNSPath string) ([]byte, error) {
+	pluginType, _ := plugin["type"].(string)
+
+	path, err := findPlugin(pluginType, binDirs)
+	if err != nil {
+		return nil, err
+	}
+
+	conf := make(map[string]interface{})
+	for k, v := range plugin {
+		conf[k] = v
+	}
+	conf["name"] = n.Name
+	conf["cniVersion"] = n.CNIVersion
+	if len(prevResult) > 0 {
+		conf["prevResult"] = json.RawMessage(prevResult)
+	}
+
+	stdin, err := json.Marshal(conf)
+	if err != nil {
+		return nil, err
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Env = append(os.Environ(),
+		"CNI_COMMAND="+command,
+		"CNI_CONTAINERID="+ContainerID(netNSPath),
+		"CNI_NETNS="+netNSPath,
+		"CNI_IFNAME="+IfName,
+		"CNI_PATH="+strings.Join(binDirs, string(os.PathListSeparator)))
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		// the plugins report their errors on their standard output
+		cniErr := &types.Error{}
+		if json.Unmarshal(stdout.Bytes(), cniErr) == nil && cniErr.Msg != "" {
+			return nil, fmt.Errorf("CNI plugin %s %s failed: %v", pluginType, command, cniErr)
+		}
+
+		return nil, fmt.Errorf("CNI plugin %s %s failed: %v: %s", pluginType, command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// findPlugin returns the path of the plugin binary in the directories.
+func findPlugin(pluginType string, binDirs []string) (string, error) {
+	if strings.ContainsRune(pluginType, os.PathSeparator) {
+		return "", fmt.Errorf("Invalid CNI plugin type %q", pluginType)
+	}
+
+	for _, dir := range binDirs {
+		path := filepath.Join(dir, pluginType)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			return path, nil
+		}
+	}
+
+	return "", fmt.Errorf("CNI plugin %s not found in %v", pluginType, binDirs)
+}
@@ -0,0 +1,197 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package cni
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testNetNS = "/var/run/netns/cni-0123"
+
+const testConfList = `{
+	"cniVersion": "0.4.0",
+	"name": "kata-net",
+	"plugins": [
+		{"type": "fake-bridge", "bridge": "kata0"},
+		{"type": "fake-portmap"}
+	]
+}`
+
+// testPlugin logs its command and standard input, and prints its result.
+const testPlugin = `#!/bin/sh
+echo "$(basename $0) $CNI_COMMAND $CNI_CONTAINERID $CNI_NETNS $CNI_IFNAME" >> %[1]s/calls
+cat >> %[1]s/stdin
+echo >> %[1]s/stdin
+[ "$CNI_COMMAND" = ADD ] && echo '{"cniVersion": "0.4.0", "ips": [{"version": "4", "address": "10.88.0.2/16"}]}'
+exit 0
+`
+
+const testFailingPlugin = `#!/bin/sh
+echo '{"code": 7, "msg": "no IP addresses available"}'
+exit 1
+`
+
+func setupTestNetwork(t *testing.T, conf string) (string, string, string, func()) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "cni")
+	assert.NoError(err)
+
+	confDir := filepath.Join(dir, "net.d")
+	binDir := filepath.Join(dir, "bin")
+	logDir := filepath.Join(dir, "log")
+
+	for _, d := range []string{confDir, binDir, logDir} {
+		assert.NoError(os.MkdirAll(d, 0755))
+	}
+
+	for _, p := range []string{"fake-bridge", "fake-portmap"} {
+		script := fmt.Sprintf(testPlugin, logDir)
+		assert.NoError(ioutil.WriteFile(filepath.Join(binDir, p), []byte(script), 0755))
+	}
+	assert.NoError(ioutil.WriteFile(filepath.Join(binDir, "fake-ipam"), []byte(testFailingPlugin), 0755))
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(confDir, "10-kata.conflist"), []byte(conf), 0644))
+
+	savedCacheDir := CacheDir
+	CacheDir = filepath.Join(dir, "results")
+
+	return confDir, binDir, logDir, func() {
+		CacheDir = savedCacheDir
+		os.RemoveAll(dir)
+	}
+}
+
+func TestLoadNetwork(t *testing.T) {
+	assert := assert.New(t)
+
+	confDir, _, _, cleanup := setupTestNetwork(t, testConfList)
+	defer cleanup()
+
+	n, err := LoadNetwork(confDir)
+	assert.NoError(err)
+	assert.Equal("kata-net", n.Name)
+	assert.Equal("0.4.0", n.CNIVersion)
+	assert.Len(n.plugins, 2)
+
+	// the first file in lexical order wins, a .conf being a single plugin
+	conf := `{"cniVersion": "0.3.1", "name": "single", "type": "fake-bridge"}`
+	assert.NoError(ioutil.WriteFile(filepath.Join(confDir, "05-single.conf"), []byte(conf), 0644))
+
+	n, err = LoadNetwork(confDir)
+	assert.NoError(err)
+	assert.Equal("single", n.Name)
+	assert.Len(n.plugins, 1)
+	assert.Equal("fake-bridge", n.plugins[0]["type"])
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(confDir, "01-invalid.conflist"), []byte(`{"name": "invalid", "plugins": [{}]}`), 0644))
+	_, err = LoadNetwork(confDir)
+	assert.Error(err)
+
+	empty, err := ioutil.TempDir("", "cni")
+	assert.NoError(err)
+	defer os.RemoveAll(empty)
+
+	_, err = LoadNetwork(empty)
+	assert.Error(err)
+}
+
+func TestNetworkAddDel(t *testing.T) {
+	assert := assert.New(t)
+
+	confDir, binDir, logDir, cleanup := setupTestNetwork(t, testConfList)
+	defer cleanup()
+
+	n, err := LoadNetwork(confDir)
+	assert.NoError(err)
+
+	ctx := context.Background()
+
+	result, err := n.Add(ctx, []string{"/does/not/exist", binDir}, testNetNS)
+	assert.NoError(err)
+	assert.Contains(string(result), "10.88.0.2/16")
+
+	assert.NoError(n.Del(ctx, []string{binDir}, testNetNS))
+
+	calls, err := ioutil.ReadFile(filepath.Join(logDir, "calls"))
+	assert.NoError(err)
+	assert.Equal(`fake-bridge ADD cni-0123 /var/run/netns/cni-0123 eth0
+fake-portmap ADD cni-0123 /var/run/netns/cni-0123 eth0
+fake-portmap DEL cni-0123 /var/run/netns/cni-0123 eth0
+fake-bridge DEL cni-0123 /var/run/netns/cni-0123 eth0
+`, string(calls))
+
+	stdin, err := ioutil.ReadFile(filepath.Join(logDir, "stdin"))
+	assert.NoError(err)
+
+	var confs []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(stdin)), "\n") {
+		var conf map[string]interface{}
+		assert.NoError(json.Unmarshal([]byte(line), &conf))
+		confs = append(confs, conf)
+	}
+	assert.Len(confs, 4)
+
+	// the network name and version are passed to the plugins, the
+	// result of a plugin to the next one and to DEL
+	assert.Equal("kata-net", confs[0]["name"])
+	assert.Equal("0.4.0", confs[0]["cniVersion"])
+	assert.Equal("kata0", confs[0]["bridge"])
+	assert.Nil(confs[0]["prevResult"])
+	assert.NotNil(confs[1]["prevResult"])
+	assert.NotNil(confs[2]["prevResult"])
+	assert.NotNil(confs[3]["prevResult"])
+
+	// the cached result is removed
+	_, err = os.Stat(n.cachePath(testNetNS))
+	assert.True(os.IsNotExist(err))
+}
+
+func TestNetworkAddFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	conf := `{
+	"cniVersion": "0.4.0",
+	"name": "kata-net",
+	"plugins": [
+		{"type": "fake-ipam"}
+	]
+}`
+
+	confDir, binDir, _, cleanup := setupTestNetwork(t, conf)
+	defer cleanup()
+
+	n, err := LoadNetwork(confDir)
+	assert.NoError(err)
+
+	_, err = n.Add(context.Background(), []string{binDir}, testNetNS)
+	assert.Error(err)
+	assert.Contains(err.Error(), "no IP addresses available")
+
+	// the plugin binary is missing
+	_, err = n.Add(context.Background(), []string{"/does/not/exist"}, testNetNS)
+	assert.Error(err)
+}
+
+func TestFindPlugin(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := findPlugin("../../bin/sh", []string{"/opt/cni/bin"})
+	assert.Error(err)
+
+	path, err := findPlugin("sh", []string{"/does/not/exist", "/bin"})
+	assert.NoError(err)
+	assert.Equal("/bin/sh", path)
+}
@@ -7,10 +7,13 @@ package oci
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"path/filepath"
+	"regexp"
 	goruntime "runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"syscall"
@@ -55,6 +58,55 @@ var (
 		{dockershimAnnotations.ContainerTypeLabelSandbox, vc.PodSandbox},
 		{dockershimAnnotations.ContainerTypeLabelContainer, vc.PodContainer},
 	}
+
+	// bandwidthSuffixes lists the binary and decimal suffixes of the
+	// kubernetes quantities.
+	bandwidthSuffixes = []struct {
+		suffix     string
+		multiplier float64
+	}{
+		{"Ki", 1 << 10},
+		{"Mi", 1 << 20},
+		{"Gi", 1 << 30},
+		{"Ti", 1 << 40},
+		{"Pi", 1 << 50},
+		{"k", 1e3},
+		{"M", 1e6},
+		{"G", 1e9},
+		{"T", 1e12},
+		{"P", 1e15},
+	}
+)
+
+const (
+	// IngressBandwidthKey is the pod annotation limiting the rate of the
+	// traffic received by the sandbox.
+	IngressBandwidthKey = "kubernetes.io/ingress-bandwidth"
+
+	// EgressBandwidthKey is the pod annotation limiting the rate of the
+	// traffic sent by the sandbox.
+	EgressBandwidthKey = "kubernetes.io/egress-bandwidth"
+
+	minBandwidth = 1e3
+	maxBandwidth = 1e15
+
+	// The pod identity annotations of the containerd CRI plugin, more
+	// recent than the vendored cri-containerd package.
+	criContainerdSandboxUID       = "io.kubernetes.cri.sandbox-uid"
+	criContainerdSandboxName      = "io.kubernetes.cri.sandbox-name"
+	criContainerdSandboxNamespace = "io.kubernetes.cri.sandbox-namespace"
+	criContainerdImageName        = "io.kubernetes.cri.image-name"
+
+	// The sandbox sizing annotations of the containerd CRI plugin, the
+	// sums of the limits of the containers of the pod.
+	criContainerdSandboxCPUPeriod = "io.kubernetes.cri.sandbox-cpu-period"
+	criContainerdSandboxCPUQuota  = "io.kubernetes.cri.sandbox-cpu-quota"
+	criContainerdSandboxMemory    = "io.kubernetes.cri.sandbox-memory"
+
+	// The kubelet labels CRI-O reports through its labels annotation.
+	kubePodUIDLabel       = "io.kubernetes.pod.uid"
+	kubePodNameLabel      = "io.kubernetes.pod.name"
+	kubePodNamespaceLabel = "io.kubernetes.pod.namespace"
 )
 
 const (
@@ -89,6 +141,21 @@ type FactoryConfig struct {
 	VMCacheEndpoint string
 }
 
+// AssetProfile is a named set of guest assets, selected by the pods
+// needing another guest kernel than the configured one.
+type AssetProfile struct {
+	// KernelPath replaces the configured kernel, if set.
+	KernelPath string
+
+	// ImagePath or InitrdPath replace the configured guest rootfs, at most
+	// one of them is set.
+	ImagePath  string
+	InitrdPath string
+
+	// KernelParams are added to the configured kernel parameters.
+	KernelParams []vc.Param
+}
+
 // RuntimeConfig aggregates all runtime specific settings
 type RuntimeConfig struct {
 	HypervisorType   vc.HypervisorType
@@ -96,6 +163,8 @@ type RuntimeConfig struct {
 
 	NetmonConfig vc.NetmonConfig
 
+	CNIConfig vc.CNIConfig
+
 	AgentType   vc.AgentType
 	AgentConfig interface{}
 
@@ -114,15 +183,102 @@ type RuntimeConfig struct {
 	Debug             bool
 	Trace             bool
 
+	// TraceCollectorEndpoint and TraceAgentEndpoint are where the
+	// spans are reported, a collector URL having precedence over an
+	// agent host:port.
+	TraceCollectorEndpoint string
+	TraceAgentEndpoint     string
+
+	// TraceSampleRate is the ratio of the traces which are reported
+	TraceSampleRate float64
+
+	// TraceDisabledSubsystems lists the subsystems which are not traced
+	TraceDisabledSubsystems []string
+
 	//Determines if seccomp should be applied inside guest
 	DisableGuestSeccomp bool
 
+	//Determine if SELinux labels and AppArmor profiles should be applied
+	//inside guest, when its kernel enables these LSMs
+	DisableGuestSELinux  bool
+	DisableGuestAppArmor bool
+
 	//Determines if create a netns for hypervisor process
 	DisableNewNetNs bool
 
+	//Overrides the MTU of the network interfaces in the guest
+	NetworkMTU int
+
+	//Determines if the host bypasses the connection tracking of the
+	//traffic of the tcfilter endpoints
+	ConntrackBypass bool
+
+	//Determines if the shim serves the pprof endpoints and the execution
+	//traces of the Go runtime
+	EnablePprof bool
+
+	//Path of the vhost-user sockets of the interfaces, %s being
+	//replaced by their addresses
+	VhostUserSocketPath string
+
 	//Determines kata processes are managed only in sandbox cgroup
 	SandboxCgroupOnly bool
 
+	//Host devices privileged containers are allowed to access
+	PrivilegedDeviceAllowList []string
+
+	//Size in MiB of the sandbox temporary tree, unlimited if 0
+	SandboxTmpQuota uint32
+
+	//Size in MiB of the encrypted scratch disk of the sandbox, none if 0
+	ScratchDiskSize uint32
+
+	//Determines if the host memory of the VMM is accounted in the pod stats
+	StatsVMMOverhead bool
+
+	//Period in seconds the guest clock is synced with the host at, never if 0
+	GuestTimeSyncInterval uint32
+
+	//Period in seconds the copies of the Kubernetes volumes are updated at, shared if 0
+	MountWatchInterval uint32
+
+	//Time in seconds after which the VM of an idle sandbox is paused, never if 0
+	IdlePauseTimeout uint32
+
+	//Determines if the VM is sized once from the pod limits, without hotplug
+	StaticSandboxResourceMgmt bool
+
+	//Sizing of the VM memory from the host memory available
+	MemorySizing vc.MemorySizing
+
+	//Determines if the runtime and the VMM run without root privileges
+	Rootless bool
+
+	//slirp4netns binary providing network to rootless sandboxes
+	Slirp4netnsPath string
+
+	//Executable run when the hypervisor exits unexpectedly
+	HypervisorExitHook string
+
+	//Sink of the sandbox lifecycle audit events
+	AuditLog string
+
+	//Directory and format of the per sandbox log files
+	SandboxLogDir    string
+	SandboxLogFormat string
+
+	//Generate the resolv.conf in the guest rather than sharing the host one
+	GuestDNS bool
+
+	//Expected digests and sources of the guest assets
+	AssetRegistry vc.AssetRegistryConfig
+
+	//Guest asset profiles pods can select by annotation
+	AssetProfiles map[string]AssetProfile
+
+	//Network sysctls of the pod applied in the guest
+	NetSysctlAllowList []string
+
 	//Experimental features enabled
 	Experimental []exp.Feature
 }
@@ -265,6 +421,7 @@ func networkConfig(ocispec specs.Spec, config RuntimeConfig) (vc.NetworkConfig,
 	}
 	netConf.InterworkingModel = config.InterNetworkModel
 	netConf.DisableNewNetNs = config.DisableNewNetNs
+	netConf.VhostUserSocketPath = config.VhostUserSocketPath
 
 	netConf.NetmonConfig = vc.NetmonConfig{
 		Path:   config.NetmonConfig.Path,
@@ -272,9 +429,75 @@ func networkConfig(ocispec specs.Spec, config RuntimeConfig) (vc.NetworkConfig,
 		Enable: config.NetmonConfig.Enable,
 	}
 
+	netConf.CNIConfig = config.CNIConfig
+	netConf.MTU = config.NetworkMTU
+	netConf.ConntrackBypass = config.ConntrackBypass
+
+	var err error
+	if value, ok := ocispec.Annotations[vcAnnotations.NetworkMTU]; ok {
+		if netConf.MTU, err = strconv.Atoi(value); err != nil {
+			return vc.NetworkConfig{}, fmt.Errorf("Error parsing annotation for %s: %v", vcAnnotations.NetworkMTU, err)
+		}
+	}
+
+	for key, value := range ocispec.Annotations {
+		if !strings.HasPrefix(key, vcAnnotations.InterfaceMTUPrefix) {
+			continue
+		}
+
+		mtu, err := strconv.Atoi(value)
+		if err != nil {
+			return vc.NetworkConfig{}, fmt.Errorf("Error parsing annotation for %s: %v", key, err)
+		}
+
+		if netConf.InterfaceMTUs == nil {
+			netConf.InterfaceMTUs = make(map[string]int)
+		}
+		netConf.InterfaceMTUs[strings.TrimPrefix(key, vcAnnotations.InterfaceMTUPrefix)] = mtu
+	}
+
+	if value, ok := ocispec.Annotations[IngressBandwidthKey]; ok {
+		if netConf.IngressBandwidth, err = parseBandwidth(value); err != nil {
+			return vc.NetworkConfig{}, fmt.Errorf("Error parsing annotation for %s: %v", IngressBandwidthKey, err)
+		}
+	}
+
+	if value, ok := ocispec.Annotations[EgressBandwidthKey]; ok {
+		if netConf.EgressBandwidth, err = parseBandwidth(value); err != nil {
+			return vc.NetworkConfig{}, fmt.Errorf("Error parsing annotation for %s: %v", EgressBandwidthKey, err)
+		}
+	}
+
 	return netConf, nil
 }
 
+// parseBandwidth converts a kubernetes quantity such as "10M" or "1Gi" into
+// a rate in bits per second, within the range accepted by the kubelet.
+func parseBandwidth(value string) (uint64, error) {
+	multiplier := float64(1)
+	number := value
+
+	for _, suffix := range bandwidthSuffixes {
+		if strings.HasSuffix(value, suffix.suffix) {
+			multiplier = suffix.multiplier
+			number = strings.TrimSuffix(value, suffix.suffix)
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid bandwidth %q", value)
+	}
+
+	rate := n * multiplier
+	if rate < minBandwidth || rate > maxBandwidth {
+		return 0, fmt.Errorf("bandwidth %q is out of range [1k, 1P]", value)
+	}
+
+	return uint64(rate), nil
+}
+
 // GetContainerType determines which type of container matches the annotations
 // table provided.
 func GetContainerType(annotations map[string]string) (vc.ContainerType, error) {
@@ -326,7 +549,57 @@ func SandboxID(spec specs.Spec) (string, error) {
 	return "", fmt.Errorf("Could not find sandbox ID")
 }
 
+// checkHypervisorAnnotations returns an error if the spec has a hypervisor
+// annotation which is not in the enabled list of the configuration. All
+// the annotations are allowed when the list is not set.
+func checkHypervisorAnnotations(ocispec specs.Spec, enabled []string) error {
+	if enabled == nil {
+		return nil
+	}
+
+	var allowed []*regexp.Regexp
+
+	for _, e := range enabled {
+		re, err := regexp.Compile("^(?:" + e + ")$")
+		if err != nil {
+			return fmt.Errorf("Invalid enable_annotations entry %q: %v", e, err)
+		}
+		allowed = append(allowed, re)
+	}
+
+	var keys []string
+	for key := range ocispec.Annotations {
+		if strings.HasPrefix(key, vcAnnotations.KataAnnotationHypervisorPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, vcAnnotations.KataAnnotationHypervisorPrefix)
+		if !isAnnotationEnabled(allowed, name) {
+			return fmt.Errorf("Annotation %s is not enabled in the hypervisor configuration", key)
+		}
+	}
+
+	return nil
+}
+
+func isAnnotationEnabled(allowed []*regexp.Regexp, name string) bool {
+	for _, re := range allowed {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+
+	return false
+}
+
 func addAnnotations(ocispec specs.Spec, config *vc.SandboxConfig) error {
+	if err := checkHypervisorAnnotations(ocispec, config.HypervisorConfig.EnableAnnotations); err != nil {
+		return err
+	}
+
 	addAssetAnnotations(ocispec, config)
 	if err := addHypervisorConfigOverrides(ocispec, config); err != nil {
 		return err
@@ -414,6 +687,10 @@ func addHypervisorConfigOverrides(ocispec specs.Spec, config *vc.SandboxConfig)
 		config.HypervisorConfig.DisableVhostNet = disableVhostNet
 	}
 
+	if err := addHypervisorNetOffloadsOverrides(ocispec, config); err != nil {
+		return err
+	}
+
 	if value, ok := ocispec.Annotations[vcAnnotations.GuestHookPath]; ok {
 		if value != "" {
 			config.HypervisorConfig.GuestHookPath = value
@@ -438,6 +715,15 @@ func addHypervisorConfigOverrides(ocispec specs.Spec, config *vc.SandboxConfig)
 		config.HypervisorConfig.DisableImageNvdimm = disableNvdimm
 	}
 
+	if value, ok := ocispec.Annotations[vcAnnotations.UsePmemRootfs]; ok {
+		usePmemRootfs, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation for use_pmem_rootfs: Please specify boolean value 'true|false'")
+		}
+
+		config.HypervisorConfig.UsePmemRootfs = usePmemRootfs
+	}
+
 	if value, ok := ocispec.Annotations[vcAnnotations.HotplugVFIOOnRootBus]; ok {
 		hotplugVFIOOnRootBus, err := strconv.ParseBool(value)
 		if err != nil {
@@ -461,6 +747,27 @@ func addHypervisorConfigOverrides(ocispec specs.Spec, config *vc.SandboxConfig)
 		}
 	}
 
+	if value, ok := ocispec.Annotations[vcAnnotations.VMMLogLevel]; ok {
+		if value != "" {
+			config.HypervisorConfig.VMMLogLevel = value
+		}
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.VMMForwardMetrics]; ok {
+		forwardMetrics, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation for vmm_forward_metrics: Please specify boolean value 'true|false'")
+		}
+
+		config.HypervisorConfig.VMMForwardMetrics = forwardMetrics
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.CPUTemplate]; ok {
+		if value != "" {
+			config.HypervisorConfig.CPUTemplate = value
+		}
+	}
+
 	return nil
 }
 
@@ -578,6 +885,10 @@ func addHypervisorCPUOverrides(ocispec specs.Spec, sbConfig *vc.SandboxConfig) e
 		sbConfig.HypervisorConfig.DefaultMaxVCPUs = max
 	}
 
+	if value, ok := ocispec.Annotations[vcAnnotations.CPUFeatures]; ok {
+		sbConfig.HypervisorConfig.CPUFeatures = value
+	}
+
 	return nil
 }
 
@@ -705,6 +1016,24 @@ func addRuntimeConfigOverrides(ocispec specs.Spec, sbConfig *vc.SandboxConfig) e
 		sbConfig.DisableGuestSeccomp = disableGuestSeccomp
 	}
 
+	if value, ok := ocispec.Annotations[vcAnnotations.DisableGuestSELinux]; ok {
+		disableGuestSELinux, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation for disable_guest_selinux: Please specify boolean value 'true|false'")
+		}
+
+		sbConfig.DisableGuestSELinux = disableGuestSELinux
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.DisableGuestAppArmor]; ok {
+		disableGuestAppArmor, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation for disable_guest_apparmor: Please specify boolean value 'true|false'")
+		}
+
+		sbConfig.DisableGuestAppArmor = disableGuestAppArmor
+	}
+
 	if value, ok := ocispec.Annotations[vcAnnotations.SandboxCgroupOnly]; ok {
 		sandboxCgroupOnly, err := strconv.ParseBool(value)
 		if err != nil {
@@ -744,9 +1073,98 @@ func addRuntimeConfigOverrides(ocispec specs.Spec, sbConfig *vc.SandboxConfig) e
 		sbConfig.NetworkConfig.InterworkingModel = runtimeConfig.InterNetworkModel
 	}
 
+	if value, ok := ocispec.Annotations[vcAnnotations.VhostUserSocketPath]; ok {
+		if err := vc.CheckVhostUserSocketPath(value); err != nil {
+			return fmt.Errorf("Error parsing annotation %s: %v", vcAnnotations.VhostUserSocketPath, err)
+		}
+
+		sbConfig.NetworkConfig.VhostUserSocketPath = value
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.BootProfile]; ok {
+		profile, err := vc.ParseBootProfile(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation %s: %v", vcAnnotations.BootProfile, err)
+		}
+
+		sbConfig.HypervisorConfig.BootProfile = profile
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.VCPUIsolation]; ok {
+		isolation, err := vc.ParseVCPUIsolation(value)
+		if err != nil {
+			return fmt.Errorf("Error parsing annotation %s: %v", vcAnnotations.VCPUIsolation, err)
+		}
+
+		sbConfig.VCPUIsolation = isolation
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.DNSServers]; ok {
+		sbConfig.DNS.Servers = splitAnnotationList(value)
+		if err := vc.CheckDNSConfig(sbConfig.DNS); err != nil {
+			return fmt.Errorf("Error parsing annotation %s: %v", vcAnnotations.DNSServers, err)
+		}
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.DNSSearches]; ok {
+		sbConfig.DNS.Searches = splitAnnotationList(value)
+	}
+
+	if value, ok := ocispec.Annotations[vcAnnotations.DNSOptions]; ok {
+		sbConfig.DNS.Options = splitAnnotationList(value)
+	}
+
 	return nil
 }
 
+// addHypervisorNetOffloadsOverrides sets the offloads disabled on the tap
+// devices of the sandbox and of its interfaces, an empty list of an
+// interface enabling all its offloads.
+func addHypervisorNetOffloadsOverrides(ocispec specs.Spec, sbConfig *vc.SandboxConfig) error {
+	if value, ok := ocispec.Annotations[vcAnnotations.DisableNetOffloads]; ok {
+		sbConfig.HypervisorConfig.DisableNetOffloads = splitAnnotationList(value)
+	}
+
+	var offloads map[string][]string
+	for key, value := range ocispec.Annotations {
+		if !strings.HasPrefix(key, vcAnnotations.InterfaceNetOffloadsPrefix) {
+			continue
+		}
+
+		ifName := strings.TrimPrefix(key, vcAnnotations.InterfaceNetOffloadsPrefix)
+		if ifName == "" {
+			return fmt.Errorf("Error parsing annotation %s: missing interface name", key)
+		}
+
+		// the configured map is shared with the other sandboxes
+		if offloads == nil {
+			offloads = make(map[string][]string)
+			for k, v := range sbConfig.HypervisorConfig.InterfaceNetOffloads {
+				offloads[k] = v
+			}
+			sbConfig.HypervisorConfig.InterfaceNetOffloads = offloads
+		}
+
+		offloads[ifName] = splitAnnotationList(value)
+	}
+
+	return nil
+}
+
+// splitAnnotationList returns the elements of a comma separated list
+// annotation, ignoring the spaces around them and the empty ones.
+func splitAnnotationList(value string) []string {
+	var list []string
+
+	for _, e := range strings.Split(value, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+
+	return list
+}
+
 func addAgentConfigOverrides(ocispec specs.Spec, config *vc.SandboxConfig) error {
 	c, ok := config.AgentConfig.(vc.KataAgentConfig)
 	if !ok {
@@ -839,6 +1257,39 @@ func SandboxConfig(ocispec specs.Spec, runtime RuntimeConfig, bundlePath, cid, c
 
 		DisableGuestSeccomp: runtime.DisableGuestSeccomp,
 
+		DisableGuestSELinux: runtime.DisableGuestSELinux,
+
+		DisableGuestAppArmor: runtime.DisableGuestAppArmor,
+
+		PrivilegedDeviceAllowList: runtime.PrivilegedDeviceAllowList,
+
+		SandboxTmpQuota: runtime.SandboxTmpQuota,
+
+		ScratchDiskSize: runtime.ScratchDiskSize,
+
+		StatsVMMOverhead: runtime.StatsVMMOverhead,
+
+		GuestTimeSyncInterval: runtime.GuestTimeSyncInterval,
+
+		MountWatchInterval: runtime.MountWatchInterval,
+
+		IdlePauseTimeout: runtime.IdlePauseTimeout,
+
+		StaticResourceMgmt: runtime.StaticSandboxResourceMgmt,
+
+		HypervisorExitHook: runtime.HypervisorExitHook,
+
+		AuditLog: runtime.AuditLog,
+
+		NetSysctlAllowList: runtime.NetSysctlAllowList,
+
+		SandboxLogDir:    runtime.SandboxLogDir,
+		SandboxLogFormat: runtime.SandboxLogFormat,
+
+		GuestDNS: runtime.GuestDNS,
+
+		AssetRegistry: runtime.AssetRegistry,
+
 		// Q: Is this really necessary? @weizhang555
 		// Spec: &ocispec,
 
@@ -847,13 +1298,176 @@ func SandboxConfig(ocispec specs.Spec, runtime RuntimeConfig, bundlePath, cid, c
 		HasCRIContainerType: HasCRIContainerType(ocispec.Annotations),
 	}
 
+	// the asset annotations override the profile
+	if err := addAssetProfile(ocispec, runtime.AssetProfiles, &sandboxConfig); err != nil {
+		return vc.SandboxConfig{}, err
+	}
+
 	if err := addAnnotations(ocispec, &sandboxConfig); err != nil {
 		return vc.SandboxConfig{}, err
 	}
 
+	// the memory requested by annotation is kept as is
+	_, memoryOverridden := ocispec.Annotations[vcAnnotations.DefaultMemory]
+	sizeMemory := runtime.MemorySizing.Dynamic() && !memoryOverridden
+
+	if sizeMemory {
+		if err := addDynamicSandboxMemory(ocispec, runtime.MemorySizing, &sandboxConfig); err != nil {
+			return vc.SandboxConfig{}, err
+		}
+	}
+
+	if sandboxConfig.StaticResourceMgmt {
+		if err := addStaticSandboxResources(ocispec, !sizeMemory, &sandboxConfig); err != nil {
+			return vc.SandboxConfig{}, err
+		}
+	}
+
 	return sandboxConfig, nil
 }
 
+// podMemoryLimit returns the sum of the memory limits in bytes of the
+// containers of the pod, 0 if unknown.
+func podMemoryLimit(ocispec specs.Spec) (int64, error) {
+	value, ok := ocispec.Annotations[criContainerdSandboxMemory]
+	if !ok {
+		return 0, nil
+	}
+
+	memory, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Error parsing annotation %s: %v", criContainerdSandboxMemory, err)
+	}
+
+	return memory, nil
+}
+
+// addDynamicSandboxMemory sizes the VM memory for the pod from the host
+// memory available: the configured memory, used by the guest itself, plus
+// the memory limits of the pod. The memory isn't hot added afterwards as
+// the containers are created.
+func addDynamicSandboxMemory(ocispec specs.Spec, sizing vc.MemorySizing, sbConfig *vc.SandboxConfig) error {
+	memory, err := podMemoryLimit(ocispec)
+	if err != nil {
+		return err
+	}
+
+	var podMemory uint32
+	if memory > 0 {
+		podMemory = uint32(memory >> 20)
+	}
+
+	size, err := sizing.SandboxMemory(sbConfig.HypervisorConfig.MemorySize, podMemory)
+	if err != nil {
+		return err
+	}
+
+	ociLog.WithField("pod-memory", podMemory).WithField("memory", size).Debug("Sandbox memory sized from the host memory")
+	sbConfig.HypervisorConfig.MemorySize = size
+	sbConfig.StaticMemoryMgmt = true
+
+	return nil
+}
+
+// addStaticSandboxResources sizes the VM for the limits of all the containers
+// of the pod, found in the sizing annotations of the sandbox. The default
+// vCPUs and memory of the configuration are the overhead of the sandbox,
+// added on top of the limits. The memory is left alone unless addMemory,
+// when it has already been sized for the pod.
+func addStaticSandboxResources(ocispec specs.Spec, addMemory bool, sbConfig *vc.SandboxConfig) error {
+	var period uint64
+	var quota int64
+	var err error
+
+	if value, ok := ocispec.Annotations[criContainerdSandboxCPUPeriod]; ok {
+		if period, err = strconv.ParseUint(value, 10, 64); err != nil {
+			return fmt.Errorf("Error parsing annotation %s: %v", criContainerdSandboxCPUPeriod, err)
+		}
+	}
+
+	if value, ok := ocispec.Annotations[criContainerdSandboxCPUQuota]; ok {
+		if quota, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return fmt.Errorf("Error parsing annotation %s: %v", criContainerdSandboxCPUQuota, err)
+		}
+	}
+
+	memory, err := podMemoryLimit(ocispec)
+	if err != nil {
+		return err
+	}
+
+	hConfig := &sbConfig.HypervisorConfig
+
+	// a quota of -1 means no CPU limit, the pod only gets the overhead
+	if period > 0 && quota > 0 {
+		hConfig.NumVCPUs += uint32((uint64(quota) + period - 1) / period)
+	}
+
+	if memory > 0 && addMemory {
+		hConfig.MemorySize += uint32(memory >> 20)
+	}
+
+	if hConfig.DefaultMaxVCPUs < hConfig.NumVCPUs {
+		hConfig.DefaultMaxVCPUs = hConfig.NumVCPUs
+	}
+
+	return nil
+}
+
+// addAssetProfile boots the sandbox with the guest assets of the profile
+// selected by its annotation. The profiles are set by the configuration,
+// unlike the asset annotations they don't need to be enabled.
+func addAssetProfile(ocispec specs.Spec, profiles map[string]AssetProfile, config *vc.SandboxConfig) error {
+	name, ok := ocispec.Annotations[vcAnnotations.AssetProfile]
+	if !ok || name == "" {
+		return nil
+	}
+
+	profile, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("Unknown asset profile %q in annotation %s", name, vcAnnotations.AssetProfile)
+	}
+
+	hConfig := &config.HypervisorConfig
+
+	var replaced []types.AssetType
+	if profile.KernelPath != "" {
+		hConfig.KernelPath = profile.KernelPath
+		replaced = append(replaced, types.KernelAsset)
+	}
+
+	if profile.ImagePath != "" || profile.InitrdPath != "" {
+		hConfig.ImagePath = profile.ImagePath
+		hConfig.InitrdPath = profile.InitrdPath
+		replaced = append(replaced, types.ImageAsset, types.InitrdAsset)
+	}
+
+	for _, param := range profile.KernelParams {
+		if err := hConfig.AddKernelParam(param); err != nil {
+			return fmt.Errorf("Error adding kernel parameters of asset profile %s: %v", name, err)
+		}
+	}
+
+	// the digests of the registry are the ones of the configured assets,
+	// the manifest still covers the assets of the profile
+	if len(replaced) > 0 && len(config.AssetRegistry.Assets) > 0 {
+		assets := make(map[types.AssetType]vc.AssetSource)
+		for t, source := range config.AssetRegistry.Assets {
+			assets[t] = source
+		}
+
+		for _, t := range replaced {
+			delete(assets, t)
+		}
+
+		config.AssetRegistry.Assets = assets
+	}
+
+	ociLog.WithField("asset-profile", name).Info("Using the asset profile")
+
+	return nil
+}
+
 // ContainerConfig converts an OCI compatible runtime configuration
 // file to a virtcontainers container configuration structure.
 func ContainerConfig(ocispec specs.Spec, bundlePath, cid, console string, detach bool) (vc.ContainerConfig, error) {
@@ -914,9 +1528,52 @@ func ContainerConfig(ocispec specs.Spec, bundlePath, cid, console string, detach
 
 	containerConfig.Annotations[vcAnnotations.ContainerTypeKey] = string(cType)
 
+	for key, value := range podIdentity(ocispec.Annotations) {
+		containerConfig.Annotations[key] = value
+	}
+
+	// the volume policies are applied when the container is created
+	for _, key := range []string{vcAnnotations.BlockVolumes, vcAnnotations.SharedFSVolumes} {
+		if value, ok := ocispec.Annotations[key]; ok {
+			containerConfig.Annotations[key] = value
+		}
+	}
+
 	return containerConfig, nil
 }
 
+// podIdentity returns the annotations identifying the pod and the image of a
+// container, as reported by the CRI server, so that they are kept with the
+// container configuration for auditing.
+func podIdentity(annotations map[string]string) map[string]string {
+	identity := make(map[string]string)
+
+	set := func(key, value string) {
+		if value != "" {
+			identity[key] = value
+		}
+	}
+
+	if labelsJSON, ok := annotations[crioAnnotations.Labels]; ok {
+		var labels map[string]string
+		if err := json.Unmarshal([]byte(labelsJSON), &labels); err != nil {
+			ociLog.WithError(err).Warn("Invalid CRI-O labels annotation")
+		}
+
+		set(vcAnnotations.PodUIDKey, labels[kubePodUIDLabel])
+		set(vcAnnotations.PodNameKey, labels[kubePodNameLabel])
+		set(vcAnnotations.PodNamespaceKey, labels[kubePodNamespaceLabel])
+	}
+	set(vcAnnotations.ContainerImageKey, annotations[crioAnnotations.ImageName])
+
+	set(vcAnnotations.PodUIDKey, annotations[criContainerdSandboxUID])
+	set(vcAnnotations.PodNameKey, annotations[criContainerdSandboxName])
+	set(vcAnnotations.PodNamespaceKey, annotations[criContainerdSandboxNamespace])
+	set(vcAnnotations.ContainerImageKey, annotations[criContainerdImageName])
+
+	return identity
+}
+
 func getShmSize(c vc.ContainerConfig) (uint64, error) {
 	var shmSize uint64
 
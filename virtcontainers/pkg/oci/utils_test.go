@@ -498,6 +498,44 @@ func TestSandboxIDFailure(t *testing.T) {
 	assert.Empty(sandboxID)
 }
 
+func TestPodIdentity(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Empty(podIdentity(map[string]string{}))
+
+	// CRI-O
+	identity := podIdentity(map[string]string{
+		annotations.Labels:    `{"io.kubernetes.pod.uid": "6f3c2a1e", "io.kubernetes.pod.name": "nginx", "io.kubernetes.pod.namespace": "default"}`,
+		annotations.ImageName: "docker.io/library/nginx:latest",
+	})
+	assert.Equal(map[string]string{
+		vcAnnotations.PodUIDKey:         "6f3c2a1e",
+		vcAnnotations.PodNameKey:        "nginx",
+		vcAnnotations.PodNamespaceKey:   "default",
+		vcAnnotations.ContainerImageKey: "docker.io/library/nginx:latest",
+	}, identity)
+
+	// containerd
+	identity = podIdentity(map[string]string{
+		criContainerdSandboxUID:       "6f3c2a1e",
+		criContainerdSandboxName:      "nginx",
+		criContainerdSandboxNamespace: "default",
+		criContainerdImageName:        "docker.io/library/nginx:latest",
+	})
+	assert.Equal(map[string]string{
+		vcAnnotations.PodUIDKey:         "6f3c2a1e",
+		vcAnnotations.PodNameKey:        "nginx",
+		vcAnnotations.PodNamespaceKey:   "default",
+		vcAnnotations.ContainerImageKey: "docker.io/library/nginx:latest",
+	}, identity)
+
+	// invalid labels are ignored
+	identity = podIdentity(map[string]string{
+		annotations.Labels: "{",
+	})
+	assert.Empty(identity)
+}
+
 func TestAddKernelParamValid(t *testing.T) {
 	var config RuntimeConfig
 	assert := assert.New(t)
@@ -675,6 +713,9 @@ func TestAddAssetAnnotations(t *testing.T) {
 
 	config := vc.SandboxConfig{
 		Annotations: make(map[string]string),
+		HypervisorConfig: vc.HypervisorConfig{
+			EnableAnnotations: []string{".*"},
+		},
 	}
 
 	ocispec := specs.Spec{
@@ -738,6 +779,9 @@ func TestAddHypervisorAnnotations(t *testing.T) {
 
 	config := vc.SandboxConfig{
 		Annotations: make(map[string]string),
+		HypervisorConfig: vc.HypervisorConfig{
+			EnableAnnotations: []string{".*"},
+		},
 	}
 
 	ocispec := specs.Spec{
@@ -745,6 +789,7 @@ func TestAddHypervisorAnnotations(t *testing.T) {
 	}
 
 	expectedHyperConfig := vc.HypervisorConfig{
+		EnableAnnotations: []string{".*"},
 		KernelParams: []vc.Param{
 			{
 				Key:   "vsyscall",
@@ -784,16 +829,24 @@ func TestAddHypervisorAnnotations(t *testing.T) {
 	ocispec.Annotations[vcAnnotations.MachineType] = "q35"
 	ocispec.Annotations[vcAnnotations.MachineAccelerators] = "nofw"
 	ocispec.Annotations[vcAnnotations.DisableVhostNet] = "true"
+	ocispec.Annotations[vcAnnotations.DisableNetOffloads] = "tso4, tso6"
+	ocispec.Annotations[vcAnnotations.InterfaceNetOffloadsPrefix+"eth1"] = ""
 	ocispec.Annotations[vcAnnotations.GuestHookPath] = "/usr/bin/"
 	ocispec.Annotations[vcAnnotations.UseVSock] = "true"
 	ocispec.Annotations[vcAnnotations.DisableImageNvdimm] = "true"
+	ocispec.Annotations[vcAnnotations.UsePmemRootfs] = "true"
 	ocispec.Annotations[vcAnnotations.HotplugVFIOOnRootBus] = "true"
 	ocispec.Annotations[vcAnnotations.PCIeRootPort] = "2"
 	ocispec.Annotations[vcAnnotations.EntropySource] = "/dev/urandom"
+	ocispec.Annotations[vcAnnotations.VMMLogLevel] = "Info"
+	ocispec.Annotations[vcAnnotations.VMMForwardMetrics] = "true"
+	ocispec.Annotations[vcAnnotations.CPUTemplate] = "T2"
+	ocispec.Annotations[vcAnnotations.CPUFeatures] = "pmu=off"
 
 	addAnnotations(ocispec, &config)
 	assert.Equal(config.HypervisorConfig.NumVCPUs, uint32(1))
 	assert.Equal(config.HypervisorConfig.DefaultMaxVCPUs, uint32(1))
+	assert.Equal(config.HypervisorConfig.CPUFeatures, "pmu=off")
 	assert.Equal(config.HypervisorConfig.MemorySize, uint32(1024))
 	assert.Equal(config.HypervisorConfig.MemSlots, uint32(20))
 	assert.Equal(config.HypervisorConfig.MemOffset, uint32(512))
@@ -815,12 +868,18 @@ func TestAddHypervisorAnnotations(t *testing.T) {
 	assert.Equal(config.HypervisorConfig.HypervisorMachineType, "q35")
 	assert.Equal(config.HypervisorConfig.MachineAccelerators, "nofw")
 	assert.Equal(config.HypervisorConfig.DisableVhostNet, true)
+	assert.Equal(config.HypervisorConfig.DisableNetOffloads, []string{"tso4", "tso6"})
+	assert.Equal(config.HypervisorConfig.InterfaceNetOffloads, map[string][]string{"eth1": nil})
 	assert.Equal(config.HypervisorConfig.GuestHookPath, "/usr/bin/")
 	assert.Equal(config.HypervisorConfig.UseVSock, true)
 	assert.Equal(config.HypervisorConfig.DisableImageNvdimm, true)
+	assert.Equal(config.HypervisorConfig.UsePmemRootfs, true)
 	assert.Equal(config.HypervisorConfig.HotplugVFIOOnRootBus, true)
 	assert.Equal(config.HypervisorConfig.PCIeRootPort, uint32(2))
 	assert.Equal(config.HypervisorConfig.EntropySource, "/dev/urandom")
+	assert.Equal(config.HypervisorConfig.VMMLogLevel, "Info")
+	assert.Equal(config.HypervisorConfig.VMMForwardMetrics, true)
+	assert.Equal(config.HypervisorConfig.CPUTemplate, "T2")
 
 	// In case an absurd large value is provided, the config value if not over-ridden
 	ocispec.Annotations[vcAnnotations.DefaultVCPUs] = "655536"
@@ -841,6 +900,53 @@ func TestAddHypervisorAnnotations(t *testing.T) {
 	assert.Error(err)
 }
 
+func TestAddHypervisorAnnotationsNotEnabled(t *testing.T) {
+	assert := assert.New(t)
+
+	config := vc.SandboxConfig{
+		Annotations: make(map[string]string),
+	}
+
+	ocispec := specs.Spec{
+		Annotations: map[string]string{
+			vcAnnotations.DefaultVCPUs:  "1",
+			vcAnnotations.DefaultMemory: "1024",
+		},
+	}
+
+	// all the hypervisor annotations are enabled when the list is not set
+	assert.NoError(addAnnotations(ocispec, &config))
+	assert.Equal(uint32(1), config.HypervisorConfig.NumVCPUs)
+
+	// an empty list enables none of them
+	config.HypervisorConfig = vc.HypervisorConfig{EnableAnnotations: []string{}}
+	assert.Error(addAnnotations(ocispec, &config))
+	assert.Zero(config.HypervisorConfig.NumVCPUs)
+
+	config.HypervisorConfig.EnableAnnotations = []string{"default_vcpus"}
+	assert.Error(addAnnotations(ocispec, &config))
+
+	// the whole name must match
+	config.HypervisorConfig.EnableAnnotations = []string{"default_vcpus", "memory"}
+	assert.Error(addAnnotations(ocispec, &config))
+
+	config.HypervisorConfig.EnableAnnotations = []string{"default_vcpus", "default_mem.*"}
+	assert.NoError(addAnnotations(ocispec, &config))
+	assert.Equal(uint32(1), config.HypervisorConfig.NumVCPUs)
+	assert.Equal(uint32(1024), config.HypervisorConfig.MemorySize)
+
+	config.HypervisorConfig.EnableAnnotations = []string{"("}
+	assert.Error(addAnnotations(ocispec, &config))
+
+	// other annotations are not restricted
+	ocispec.Annotations = map[string]string{
+		vcAnnotations.DisableGuestSeccomp: "true",
+	}
+	config.HypervisorConfig.EnableAnnotations = nil
+	assert.NoError(addAnnotations(ocispec, &config))
+	assert.True(config.DisableGuestSeccomp)
+}
+
 func TestAddRuntimeAnnotations(t *testing.T) {
 	assert := assert.New(t)
 
@@ -856,10 +962,294 @@ func TestAddRuntimeAnnotations(t *testing.T) {
 	ocispec.Annotations[vcAnnotations.SandboxCgroupOnly] = "true"
 	ocispec.Annotations[vcAnnotations.DisableNewNetNs] = "true"
 	ocispec.Annotations[vcAnnotations.InterNetworkModel] = "macvtap"
+	ocispec.Annotations[vcAnnotations.DisableGuestSELinux] = "true"
+	ocispec.Annotations[vcAnnotations.DisableGuestAppArmor] = "true"
 
 	addAnnotations(ocispec, &config)
 	assert.Equal(config.DisableGuestSeccomp, true)
+	assert.Equal(config.DisableGuestSELinux, true)
+	assert.Equal(config.DisableGuestAppArmor, true)
 	assert.Equal(config.SandboxCgroupOnly, true)
 	assert.Equal(config.NetworkConfig.DisableNewNetNs, true)
 	assert.Equal(config.NetworkConfig.InterworkingModel, vc.NetXConnectMacVtapModel)
 }
+
+func TestAddBootProfileAnnotation(t *testing.T) {
+	assert := assert.New(t)
+
+	config := vc.SandboxConfig{
+		Annotations: make(map[string]string),
+	}
+
+	ocispec := specs.Spec{
+		Annotations: make(map[string]string),
+	}
+
+	ocispec.Annotations[vcAnnotations.BootProfile] = "fast"
+	assert.NoError(addAnnotations(ocispec, &config))
+	assert.Equal(vc.BootProfileFast, config.HypervisorConfig.BootProfile)
+
+	ocispec.Annotations[vcAnnotations.BootProfile] = "slow"
+	assert.Error(addAnnotations(ocispec, &config))
+}
+
+func TestAddVCPUIsolationAnnotation(t *testing.T) {
+	assert := assert.New(t)
+
+	config := vc.SandboxConfig{
+		Annotations: make(map[string]string),
+	}
+
+	ocispec := specs.Spec{
+		Annotations: make(map[string]string),
+	}
+
+	ocispec.Annotations[vcAnnotations.VCPUIsolation] = "exclusive-cores"
+	assert.NoError(addAnnotations(ocispec, &config))
+	assert.Equal(vc.VCPUIsolationExclusiveCores, config.VCPUIsolation)
+
+	ocispec.Annotations[vcAnnotations.VCPUIsolation] = "none"
+	assert.NoError(addAnnotations(ocispec, &config))
+	assert.Equal(vc.VCPUIsolationNone, config.VCPUIsolation)
+
+	ocispec.Annotations[vcAnnotations.VCPUIsolation] = "smt-off"
+	assert.Error(addAnnotations(ocispec, &config))
+}
+
+func TestAddDNSAnnotations(t *testing.T) {
+	assert := assert.New(t)
+
+	config := vc.SandboxConfig{
+		Annotations: make(map[string]string),
+	}
+
+	ocispec := specs.Spec{
+		Annotations: make(map[string]string),
+	}
+
+	ocispec.Annotations[vcAnnotations.DNSServers] = "10.96.0.10, 2001:db8::53"
+	ocispec.Annotations[vcAnnotations.DNSSearches] = "default.svc.cluster.local,svc.cluster.local,"
+	ocispec.Annotations[vcAnnotations.DNSOptions] = "ndots:5"
+	assert.NoError(addAnnotations(ocispec, &config))
+	assert.Equal(vc.DNSConfig{
+		Servers:  []string{"10.96.0.10", "2001:db8::53"},
+		Searches: []string{"default.svc.cluster.local", "svc.cluster.local"},
+		Options:  []string{"ndots:5"},
+	}, config.DNS)
+
+	ocispec.Annotations[vcAnnotations.DNSServers] = "kube-dns"
+	assert.Error(addAnnotations(ocispec, &config))
+
+	ocispec.Annotations[vcAnnotations.DNSServers] = "10.0.0.1,10.0.0.2,10.0.0.3,10.0.0.4"
+	assert.Error(addAnnotations(ocispec, &config))
+}
+
+func TestAddAssetProfile(t *testing.T) {
+	assert := assert.New(t)
+
+	digest := "sha256:" + strings.Repeat("ab", 32)
+	registry := map[types.AssetType]vc.AssetSource{
+		types.KernelAsset:     {Digest: digest},
+		types.HypervisorAsset: {Digest: digest},
+	}
+
+	config := vc.SandboxConfig{
+		Annotations: make(map[string]string),
+		HypervisorConfig: vc.HypervisorConfig{
+			KernelPath: "/usr/share/kata-containers/vmlinux",
+			ImagePath:  "/usr/share/kata-containers/kata-containers.img",
+		},
+		AssetRegistry: vc.AssetRegistryConfig{Assets: registry},
+	}
+
+	ocispec := specs.Spec{
+		Annotations: make(map[string]string),
+	}
+
+	profiles := map[string]AssetProfile{
+		"rt-kernel": {
+			KernelPath:   "/usr/share/kata-containers/vmlinux-rt",
+			InitrdPath:   "/usr/share/kata-containers/initrd-rt.img",
+			KernelParams: []vc.Param{{Key: "isolcpus", Value: "1"}},
+		},
+	}
+
+	// no profile selected
+	assert.NoError(addAssetProfile(ocispec, profiles, &config))
+	assert.Equal("/usr/share/kata-containers/vmlinux", config.HypervisorConfig.KernelPath)
+
+	ocispec.Annotations[vcAnnotations.AssetProfile] = "large"
+	assert.Error(addAssetProfile(ocispec, profiles, &config))
+
+	ocispec.Annotations[vcAnnotations.AssetProfile] = "rt-kernel"
+	assert.NoError(addAssetProfile(ocispec, profiles, &config))
+	assert.Equal("/usr/share/kata-containers/vmlinux-rt", config.HypervisorConfig.KernelPath)
+	assert.Empty(config.HypervisorConfig.ImagePath)
+	assert.Equal("/usr/share/kata-containers/initrd-rt.img", config.HypervisorConfig.InitrdPath)
+	assert.Equal([]vc.Param{{Key: "isolcpus", Value: "1"}}, config.HypervisorConfig.KernelParams)
+
+	// the registry of the runtime configuration is left alone
+	assert.Equal(map[types.AssetType]vc.AssetSource{
+		types.HypervisorAsset: {Digest: digest},
+	}, config.AssetRegistry.Assets)
+	assert.Len(registry, 2)
+}
+
+func TestAddVhostUserSocketPathAnnotation(t *testing.T) {
+	assert := assert.New(t)
+
+	config := vc.SandboxConfig{
+		Annotations: make(map[string]string),
+	}
+
+	ocispec := specs.Spec{
+		Annotations: make(map[string]string),
+	}
+
+	ocispec.Annotations[vcAnnotations.VhostUserSocketPath] = "/run/vpp/%s.sock"
+	assert.NoError(addAnnotations(ocispec, &config))
+	assert.Equal("/run/vpp/%s.sock", config.NetworkConfig.VhostUserSocketPath)
+
+	ocispec.Annotations[vcAnnotations.VhostUserSocketPath] = "/run/vpp/vhu.sock"
+	assert.Error(addAnnotations(ocispec, &config))
+}
+
+func TestAddStaticSandboxResources(t *testing.T) {
+	assert := assert.New(t)
+
+	ocispec := specs.Spec{
+		Annotations: map[string]string{
+			criContainerdSandboxCPUPeriod: "100000",
+			criContainerdSandboxCPUQuota:  "250000",
+			criContainerdSandboxMemory:    "2147483648",
+		},
+	}
+
+	config := vc.SandboxConfig{
+		HypervisorConfig: vc.HypervisorConfig{
+			NumVCPUs:        1,
+			DefaultMaxVCPUs: 2,
+			MemorySize:      256,
+		},
+	}
+	assert.NoError(addStaticSandboxResources(ocispec, true, &config))
+	assert.Equal(uint32(4), config.HypervisorConfig.NumVCPUs)
+	assert.Equal(uint32(4), config.HypervisorConfig.DefaultMaxVCPUs)
+	assert.Equal(uint32(2304), config.HypervisorConfig.MemorySize)
+
+	// no CPU limit, only the overhead
+	ocispec.Annotations[criContainerdSandboxCPUQuota] = "-1"
+	config = vc.SandboxConfig{
+		HypervisorConfig: vc.HypervisorConfig{
+			NumVCPUs:   1,
+			MemorySize: 256,
+		},
+	}
+	assert.NoError(addStaticSandboxResources(ocispec, true, &config))
+	assert.Equal(uint32(1), config.HypervisorConfig.NumVCPUs)
+
+	// the memory already sized for the pod
+	config.HypervisorConfig.MemorySize = 1024
+	assert.NoError(addStaticSandboxResources(ocispec, false, &config))
+	assert.Equal(uint32(1024), config.HypervisorConfig.MemorySize)
+
+	ocispec.Annotations[criContainerdSandboxMemory] = "foo"
+	assert.Error(addStaticSandboxResources(ocispec, true, &config))
+}
+
+func TestAddDynamicSandboxMemory(t *testing.T) {
+	assert := assert.New(t)
+
+	ocispec := specs.Spec{
+		Annotations: map[string]string{
+			criContainerdSandboxMemory: "2147483648",
+		},
+	}
+
+	sizing := vc.MemorySizing{
+		Policy:  vc.MemorySizingDynamic,
+		Floor:   vc.MinHypervisorMemory,
+		Ceiling: 8192,
+	}
+
+	config := vc.SandboxConfig{
+		HypervisorConfig: vc.HypervisorConfig{
+			MemorySize: 256,
+		},
+	}
+	assert.NoError(addDynamicSandboxMemory(ocispec, sizing, &config))
+	assert.Equal(uint32(2304), config.HypervisorConfig.MemorySize)
+	assert.True(config.StaticMemoryMgmt)
+
+	ocispec.Annotations[criContainerdSandboxMemory] = "foo"
+	assert.Error(addDynamicSandboxMemory(ocispec, sizing, &config))
+}
+
+func TestParseBandwidth(t *testing.T) {
+	assert := assert.New(t)
+
+	for value, expected := range map[string]uint64{
+		"1000": 1000,
+		"500k": 500000,
+		"10M":  10000000,
+		"1.5G": 1500000000,
+		"1Mi":  1 << 20,
+		"1P":   1000000000000000,
+	} {
+		rate, err := parseBandwidth(value)
+		assert.NoError(err, value)
+		assert.Equal(expected, rate, value)
+	}
+
+	for _, value := range []string{"", "M", "10X", "-10M", "999", "2P"} {
+		_, err := parseBandwidth(value)
+		assert.Error(err, value)
+	}
+}
+
+func TestNetworkConfigBandwidth(t *testing.T) {
+	assert := assert.New(t)
+
+	ocispec := specs.Spec{
+		Linux: &specs.Linux{},
+		Annotations: map[string]string{
+			IngressBandwidthKey: "10M",
+			EgressBandwidthKey:  "1G",
+		},
+	}
+
+	netConf, err := networkConfig(ocispec, RuntimeConfig{})
+	assert.NoError(err)
+	assert.Equal(uint64(10000000), netConf.IngressBandwidth)
+	assert.Equal(uint64(1000000000), netConf.EgressBandwidth)
+
+	ocispec.Annotations[EgressBandwidthKey] = "fast"
+	_, err = networkConfig(ocispec, RuntimeConfig{})
+	assert.Error(err)
+}
+
+func TestNetworkConfigMTU(t *testing.T) {
+	assert := assert.New(t)
+
+	ocispec := specs.Spec{
+		Linux:       &specs.Linux{},
+		Annotations: map[string]string{},
+	}
+
+	netConf, err := networkConfig(ocispec, RuntimeConfig{NetworkMTU: 1450})
+	assert.NoError(err)
+	assert.Equal(1450, netConf.MTU)
+	assert.Nil(netConf.InterfaceMTUs)
+
+	ocispec.Annotations[vcAnnotations.NetworkMTU] = "1400"
+	ocispec.Annotations[vcAnnotations.InterfaceMTUPrefix+"eth1"] = "9000"
+
+	netConf, err = networkConfig(ocispec, RuntimeConfig{NetworkMTU: 1450})
+	assert.NoError(err)
+	assert.Equal(1400, netConf.MTU)
+	assert.Equal(map[string]int{"eth1": 9000}, netConf.InterfaceMTUs)
+
+	ocispec.Annotations[vcAnnotations.InterfaceMTUPrefix+"eth1"] = "jumbo"
+	_, err = networkConfig(ocispec, RuntimeConfig{})
+	assert.Error(err)
+}
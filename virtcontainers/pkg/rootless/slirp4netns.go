@@ -0,0 +1,168 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package rootless
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+)
+
+const (
+	// slirp4netnsTap is the tap interface slirp4netns creates in the
+	// network namespace, kata connects it to the VM like any other tap.
+	slirp4netnsTap = "tap0"
+
+	// slirp4netnsPidSuffix is appended to the network namespace path to
+	// get the file tracking the slirp4netns process serving it.
+	slirp4netnsPidSuffix = ".slirp4netns"
+
+	slirp4netnsReadyTimeout = 10 * time.Second
+)
+
+var (
+	// slirp4netnsPath is the slirp4netns binary providing user mode
+	// networking to the namespaces created by rootless kata, there is
+	// no network connectivity if empty.
+	slirp4netnsPath string
+
+	// for mocking in unit tests
+	slirp4netnsCommand = exec.Command
+)
+
+// SetSlirp4netnsPath sets the slirp4netns binary used for rootless networking
+func SetSlirp4netnsPath(path string) {
+	rLock.Lock()
+	defer rLock.Unlock()
+	slirp4netnsPath = path
+}
+
+func getSlirp4netnsPath() string {
+	rLock.Lock()
+	defer rLock.Unlock()
+	return slirp4netnsPath
+}
+
+func slirp4netnsPidFile(nsPath string) string {
+	return nsPath + slirp4netnsPidSuffix
+}
+
+// StartSlirp4netns starts slirp4netns in the background to provide a tap
+// interface with user mode networking in the network namespace nsPath.
+// Nothing is done if no slirp4netns binary has been set.
+func StartSlirp4netns(nsPath string) error {
+	path := getSlirp4netnsPath()
+	if path == "" {
+		rootlessLog.WithField("netns", nsPath).Info("slirp4netns not configured, sandbox has no network connectivity")
+		return nil
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	// the first extra file is fd 3 in the child
+	cmd := slirp4netnsCommand(path, "--configure", "--mtu=65520", "--disable-host-loopback",
+		"--ready-fd=3", "--netns-type=path", nsPath, slirp4netnsTap)
+	cmd.ExtraFiles = []*os.File{readyW}
+	// slirp4netns must outlive the runtime, it's stopped when the
+	// network namespace is removed
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+
+	if err := cmd.Start(); err != nil {
+		readyW.Close()
+		return fmt.Errorf("failed to start slirp4netns: %v", err)
+	}
+	readyW.Close()
+
+	ready := make(chan error, 1)
+	go func() {
+		buf := make([]byte, 1)
+		if _, err := readyR.Read(buf); err != nil {
+			ready <- err
+			return
+		}
+		ready <- nil
+	}()
+
+	select {
+	case err = <-ready:
+	case <-time.After(slirp4netnsReadyTimeout):
+		err = fmt.Errorf("timeout")
+	}
+
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return fmt.Errorf("slirp4netns is not ready: %v", err)
+	}
+
+	pid := cmd.Process.Pid
+
+	// the start time tells the process apart from another one reusing
+	// its pid once it's gone
+	stat, err := system.Stat(pid)
+	if err == nil {
+		err = ioutil.WriteFile(slirp4netnsPidFile(nsPath), []byte(fmt.Sprintf("%d %d", pid, stat.StartTime)), 0600)
+	}
+	if err != nil {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return err
+	}
+
+	// don't wait for it, the process is reparented when the runtime exits
+	cmd.Process.Release()
+
+	rootlessLog.WithFields(map[string]interface{}{
+		"netns": nsPath,
+		"pid":   pid,
+	}).Info("slirp4netns started")
+
+	return nil
+}
+
+// StopSlirp4netns stops the slirp4netns process serving the network
+// namespace nsPath, if any.
+func StopSlirp4netns(nsPath string) error {
+	pidFile := slirp4netnsPidFile(nsPath)
+
+	data, err := ioutil.ReadFile(pidFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var pid int
+	var startTime uint64
+	if _, err := fmt.Sscanf(strings.TrimSpace(string(data)), "%d %d", &pid, &startTime); err != nil {
+		return fmt.Errorf("invalid slirp4netns pid file %s: %v", pidFile, err)
+	}
+
+	// the pid may belong to another process if slirp4netns already exited
+	if stat, err := system.Stat(pid); err == nil && stat.StartTime == startTime {
+		if err := syscall.Kill(pid, syscall.SIGTERM); err != nil && err != syscall.ESRCH {
+			return fmt.Errorf("failed to stop slirp4netns: %v", err)
+		}
+	} else {
+		rootlessLog.WithFields(map[string]interface{}{
+			"netns": nsPath,
+			"pid":   pid,
+		}).Info("slirp4netns already exited")
+	}
+
+	return os.Remove(pidFile)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package rootless
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/opencontainers/runc/libcontainer/system"
+	"github.com/stretchr/testify/assert"
+)
+
+func mockSlirp4netns(script string) func() {
+	savedCommand := slirp4netnsCommand
+	slirp4netnsCommand = func(name string, arg ...string) *exec.Cmd {
+		return exec.Command("sh", "-c", script)
+	}
+
+	return func() {
+		slirp4netnsCommand = savedCommand
+		SetSlirp4netnsPath("")
+	}
+}
+
+func TestStartSlirp4netnsNotConfigured(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "slirp4netns")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmpdir)
+
+	nsPath := filepath.Join(tmpdir, "netns")
+
+	SetSlirp4netnsPath("")
+	assert.NoError(t, StartSlirp4netns(nsPath))
+
+	_, err = os.Stat(slirp4netnsPidFile(nsPath))
+	assert.True(t, os.IsNotExist(err))
+
+	// nothing to stop
+	assert.NoError(t, StopSlirp4netns(nsPath))
+}
+
+func TestStartStopSlirp4netns(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "slirp4netns")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	nsPath := filepath.Join(tmpdir, "netns")
+
+	defer mockSlirp4netns("echo 1 >&3; exec sleep 30")()
+	SetSlirp4netnsPath("/usr/bin/slirp4netns")
+
+	assert.NoError(StartSlirp4netns(nsPath))
+
+	data, err := ioutil.ReadFile(slirp4netnsPidFile(nsPath))
+	assert.NoError(err)
+	pid, err := strconv.Atoi(strings.Fields(string(data))[0])
+	assert.NoError(err)
+	assert.NoError(syscall.Kill(pid, 0))
+
+	assert.NoError(StopSlirp4netns(nsPath))
+	_, err = os.Stat(slirp4netnsPidFile(nsPath))
+	assert.True(os.IsNotExist(err))
+
+	// reap the process, it's a child of the test
+	var ws syscall.WaitStatus
+	syscall.Wait4(pid, &ws, 0, nil)
+	assert.Error(syscall.Kill(pid, 0))
+}
+
+func TestStopSlirp4netnsPidReused(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "slirp4netns")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	nsPath := filepath.Join(tmpdir, "netns")
+
+	// another process now has the pid slirp4netns had
+	cmd := exec.Command("sleep", "30")
+	assert.NoError(cmd.Start())
+	defer func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}()
+
+	stat, err := system.Stat(cmd.Process.Pid)
+	assert.NoError(err)
+
+	data := fmt.Sprintf("%d %d", cmd.Process.Pid, stat.StartTime+1)
+	assert.NoError(ioutil.WriteFile(slirp4netnsPidFile(nsPath), []byte(data), 0600))
+
+	assert.NoError(StopSlirp4netns(nsPath))
+	_, err = os.Stat(slirp4netnsPidFile(nsPath))
+	assert.True(os.IsNotExist(err))
+
+	// it's not killed
+	time.Sleep(100 * time.Millisecond)
+	stat, err = system.Stat(cmd.Process.Pid)
+	assert.NoError(err)
+	assert.NotEqual(system.Zombie, stat.State)
+}
+
+func TestStartSlirp4netnsFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "slirp4netns")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	nsPath := filepath.Join(tmpdir, "netns")
+
+	defer mockSlirp4netns("exit 1")()
+	SetSlirp4netnsPath("/usr/bin/slirp4netns")
+
+	start := time.Now()
+	assert.Error(StartSlirp4netns(nsPath))
+	assert.True(time.Since(start) < slirp4netnsReadyTimeout)
+
+	_, err = os.Stat(slirp4netnsPidFile(nsPath))
+	assert.True(os.IsNotExist(err))
+
+	assert.NoError(ioutil.WriteFile(slirp4netnsPidFile(nsPath), []byte("invalid"), 0600))
+	assert.Error(StopSlirp4netns(nsPath))
+}
@@ -6,15 +6,98 @@
 package types
 
 import (
-	"errors"
+	"fmt"
 )
 
+// ErrorCode classifies the virtcontainers errors, for the callers to react
+// to them without parsing their messages.
+type ErrorCode string
+
+const (
+	// ErrCodeUnknown is the code of the errors which have none.
+	ErrCodeUnknown ErrorCode = ""
+
+	// ErrCodeInvalidArgument is returned for the invalid requests.
+	ErrCodeInvalidArgument ErrorCode = "InvalidArgument"
+
+	// ErrCodeNotFound is returned when a sandbox, container or device
+	// doesn't exist.
+	ErrCodeNotFound ErrorCode = "NotFound"
+
+	// ErrCodeNotRunning is returned when the sandbox or container isn't in
+	// the state the request needs.
+	ErrCodeNotRunning ErrorCode = "NotRunning"
+
+	// ErrCodeHypervisorNotRunning is returned when the hypervisor can't be
+	// reached, it exited or never came up.
+	ErrCodeHypervisorNotRunning ErrorCode = "HypervisorNotRunning"
+
+	// ErrCodeDeviceBusy is returned when a device is still in use.
+	ErrCodeDeviceBusy ErrorCode = "DeviceBusy"
+
+	// ErrCodeAgentTimeout is returned when the agent didn't answer a
+	// request in time.
+	ErrCodeAgentTimeout ErrorCode = "AgentTimeout"
+
+	// ErrCodeNotSupported is returned for the features the hypervisor or
+	// the agent don't implement.
+	ErrCodeNotSupported ErrorCode = "NotSupported"
+)
+
+// Error is an error with a code.
+type Error struct {
+	Code ErrorCode
+	err  error
+}
+
+func (e *Error) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error the code was given to.
+func (e *Error) Unwrap() error {
+	return e.err
+}
+
+// Errorf formats an error with the code.
+func Errorf(code ErrorCode, format string, args ...interface{}) error {
+	return &Error{Code: code, err: fmt.Errorf(format, args...)}
+}
+
+// WithErrorCode gives the code to err, keeping its message.
+func WithErrorCode(code ErrorCode, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &Error{Code: code, err: err}
+}
+
+// ErrorCodeOf returns the code of err, or of the first error with a code
+// it wraps.
+func ErrorCodeOf(err error) ErrorCode {
+	for err != nil {
+		switch e := err.(type) {
+		case *Error:
+			return e.Code
+		case interface{ Cause() error }:
+			err = e.Cause()
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		default:
+			return ErrCodeUnknown
+		}
+	}
+
+	return ErrCodeUnknown
+}
+
 // common error objects used for argument checking
 var (
-	ErrNeedSandbox       = errors.New("Sandbox must be specified")
-	ErrNeedSandboxID     = errors.New("Sandbox ID cannot be empty")
-	ErrNeedContainerID   = errors.New("Container ID cannot be empty")
-	ErrNeedState         = errors.New("State cannot be empty")
-	ErrNoSuchContainer   = errors.New("Container does not exist")
-	ErrInvalidConfigType = errors.New("Invalid config type")
+	ErrNeedSandbox       = Errorf(ErrCodeInvalidArgument, "Sandbox must be specified")
+	ErrNeedSandboxID     = Errorf(ErrCodeInvalidArgument, "Sandbox ID cannot be empty")
+	ErrNeedContainerID   = Errorf(ErrCodeInvalidArgument, "Container ID cannot be empty")
+	ErrNeedState         = Errorf(ErrCodeInvalidArgument, "State cannot be empty")
+	ErrNoSuchContainer   = Errorf(ErrCodeNotFound, "Container does not exist")
+	ErrInvalidConfigType = Errorf(ErrCodeInvalidArgument, "Invalid config type")
 )
@@ -18,6 +18,7 @@ package vcmock
 import (
 	"context"
 	"fmt"
+	"net"
 	"syscall"
 
 	vc "github.com/kata-containers/runtime/virtcontainers"
@@ -191,6 +192,87 @@ func (m *VCMock) StatsSandbox(ctx context.Context, sandboxID string) (vc.Sandbox
 	return vc.SandboxStats{}, []vc.ContainerStats{}, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
 }
 
+// InspectSandbox implements the VC function of the same name.
+func (m *VCMock) InspectSandbox(ctx context.Context, sandboxID string) (vc.SandboxInfo, error) {
+	if m.InspectSandboxFunc != nil {
+		return m.InspectSandboxFunc(ctx, sandboxID)
+	}
+
+	return vc.SandboxInfo{}, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
+// MigrateSandbox implements the VC function of the same name.
+func (m *VCMock) MigrateSandbox(ctx context.Context, sandboxID, uri string) ([]byte, error) {
+	if m.MigrateSandboxFunc != nil {
+		return m.MigrateSandboxFunc(ctx, sandboxID, uri)
+	}
+
+	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
+// RestoreSandbox implements the VC function of the same name.
+func (m *VCMock) RestoreSandbox(ctx context.Context, state []byte, uri string) (vc.VCSandbox, error) {
+	if m.RestoreSandboxFunc != nil {
+		return m.RestoreSandboxFunc(ctx, state, uri)
+	}
+
+	return nil, fmt.Errorf("%s: %s (%+v): uri: %v", mockErrorPrefix, getSelf(), m, uri)
+}
+
+// RebootSandbox implements the VC function of the same name.
+func (m *VCMock) RebootSandbox(ctx context.Context, sandboxID string) error {
+	if m.RebootSandboxFunc != nil {
+		return m.RebootSandboxFunc(ctx, sandboxID)
+	}
+
+	return fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
+// SandboxLaunchMeasurement implements the VC function of the same name.
+func (m *VCMock) SandboxLaunchMeasurement(ctx context.Context, sandboxID string) (string, error) {
+	if m.SandboxLaunchMeasurementFunc != nil {
+		return m.SandboxLaunchMeasurementFunc(ctx, sandboxID)
+	}
+
+	return "", fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
+// SandboxBootTimes implements the VC function of the same name.
+func (m *VCMock) SandboxBootTimes(ctx context.Context, sandboxID string) (vc.BootTimes, error) {
+	if m.SandboxBootTimesFunc != nil {
+		return m.SandboxBootTimesFunc(ctx, sandboxID)
+	}
+
+	return vc.BootTimes{}, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
+// SandboxOverhead implements the VC function of the same name.
+func (m *VCMock) SandboxOverhead(ctx context.Context, sandboxID string) (vc.Overhead, error) {
+	if m.SandboxOverheadFunc != nil {
+		return m.SandboxOverheadFunc(ctx, sandboxID)
+	}
+
+	return vc.Overhead{}, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
+// SandboxMounts implements the VC function of the same name.
+func (m *VCMock) SandboxMounts(ctx context.Context, sandboxID string) (vc.SandboxMountInfo, error) {
+	if m.SandboxMountsFunc != nil {
+		return m.SandboxMountsFunc(ctx, sandboxID)
+	}
+
+	return vc.SandboxMountInfo{}, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
+// CleanupOrphans implements the VC function of the same name.
+func (m *VCMock) CleanupOrphans(ctx context.Context, jailerChrootBase string) ([]string, error) {
+	if m.CleanupOrphansFunc != nil {
+		return m.CleanupOrphansFunc(ctx, jailerChrootBase)
+	}
+
+	return nil, fmt.Errorf("%s: %s (%+v)", mockErrorPrefix, getSelf(), m)
+}
+
 // KillContainer implements the VC function of the same name.
 func (m *VCMock) KillContainer(ctx context.Context, sandboxID, containerID string, signal syscall.Signal, all bool) error {
 	if m.KillContainerFunc != nil {
@@ -245,6 +327,33 @@ func (m *VCMock) AddDevice(ctx context.Context, sandboxID string, info config.De
 	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
 }
 
+// PrewarmContainerImage implements the VC function of the same name.
+func (m *VCMock) PrewarmContainerImage(ctx context.Context, sandboxID, containerID string, rootFs vc.RootFs) error {
+	if m.PrewarmContainerImageFunc != nil {
+		return m.PrewarmContainerImageFunc(ctx, sandboxID, containerID, rootFs)
+	}
+
+	return fmt.Errorf("%s: %s (%+v): sandboxID: %v, containerID: %v", mockErrorPrefix, getSelf(), m, sandboxID, containerID)
+}
+
+// ForwardSandboxPort implements the VC function of the same name.
+func (m *VCMock) ForwardSandboxPort(ctx context.Context, sandboxID string, port uint32, listener net.Listener) error {
+	if m.ForwardSandboxPortFunc != nil {
+		return m.ForwardSandboxPortFunc(ctx, sandboxID, port, listener)
+	}
+
+	return fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
+// OpenSandboxDebugConsole implements the VC function of the same name.
+func (m *VCMock) OpenSandboxDebugConsole(ctx context.Context, sandboxID string) (net.Conn, error) {
+	if m.OpenSandboxDebugConsoleFunc != nil {
+		return m.OpenSandboxDebugConsoleFunc(ctx, sandboxID)
+	}
+
+	return nil, fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
+}
+
 // AddInterface implements the VC function of the same name.
 func (m *VCMock) AddInterface(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error) {
 	if m.AddInterfaceFunc != nil {
@@ -296,3 +405,12 @@ func (m *VCMock) CleanupContainer(ctx context.Context, sandboxID, containerID st
 	}
 	return fmt.Errorf("%s: %s (%+v): sandboxID: %v", mockErrorPrefix, getSelf(), m, sandboxID)
 }
+
+// GetHypervisorCapabilities implements the VC function of the same name.
+func (m *VCMock) GetHypervisorCapabilities(ctx context.Context, hType vc.HypervisorType, conf vc.HypervisorConfig) (vc.HypervisorCapabilities, error) {
+	if m.GetHypervisorCapabilitiesFunc != nil {
+		return m.GetHypervisorCapabilitiesFunc(ctx, hType, conf)
+	}
+
+	return vc.HypervisorCapabilities{}, fmt.Errorf("%s: %s (%+v): hypervisor: %v", mockErrorPrefix, getSelf(), m, hType)
+}
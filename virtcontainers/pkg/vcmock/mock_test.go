@@ -7,9 +7,11 @@ package vcmock
 
 import (
 	"context"
+	"net"
 	"reflect"
 	"syscall"
 	"testing"
+	"time"
 
 	vc "github.com/kata-containers/runtime/virtcontainers"
 	"github.com/kata-containers/runtime/virtcontainers/factory"
@@ -683,6 +685,111 @@ func TestVCMockSetVMFactory(t *testing.T) {
 	assert.Equal(factoryTriggered, 1)
 }
 
+func TestVCMockInspectSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.InspectSandboxFunc)
+
+	ctx := context.Background()
+	_, err := m.InspectSandbox(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.InspectSandboxFunc = func(ctx context.Context, sandboxID string) (vc.SandboxInfo, error) {
+		return vc.SandboxInfo{ID: sandboxID}, nil
+	}
+
+	info, err := m.InspectSandbox(ctx, testSandboxID)
+	assert.NoError(err)
+	assert.Equal(testSandboxID, info.ID)
+
+	// reset
+	m.InspectSandboxFunc = nil
+
+	_, err = m.InspectSandbox(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockPrewarmContainerImage(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.PrewarmContainerImageFunc)
+
+	ctx := context.Background()
+	err := m.PrewarmContainerImage(ctx, testSandboxID, testContainerID, vc.RootFs{})
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.PrewarmContainerImageFunc = func(ctx context.Context, sandboxID, containerID string, rootFs vc.RootFs) error {
+		return nil
+	}
+
+	err = m.PrewarmContainerImage(ctx, testSandboxID, testContainerID, vc.RootFs{})
+	assert.NoError(err)
+
+	// reset
+	m.PrewarmContainerImageFunc = nil
+
+	err = m.PrewarmContainerImage(ctx, testSandboxID, testContainerID, vc.RootFs{})
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockForwardSandboxPort(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.ForwardSandboxPortFunc)
+
+	ctx := context.Background()
+	err := m.ForwardSandboxPort(ctx, testSandboxID, 2000, nil)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.ForwardSandboxPortFunc = func(ctx context.Context, sandboxID string, port uint32, listener net.Listener) error {
+		return nil
+	}
+
+	err = m.ForwardSandboxPort(ctx, testSandboxID, 2000, nil)
+	assert.NoError(err)
+
+	// reset
+	m.ForwardSandboxPortFunc = nil
+
+	err = m.ForwardSandboxPort(ctx, testSandboxID, 2000, nil)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockOpenSandboxDebugConsole(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.OpenSandboxDebugConsoleFunc)
+
+	ctx := context.Background()
+	_, err := m.OpenSandboxDebugConsole(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.OpenSandboxDebugConsoleFunc = func(ctx context.Context, sandboxID string) (net.Conn, error) {
+		return nil, nil
+	}
+
+	_, err = m.OpenSandboxDebugConsole(ctx, testSandboxID)
+	assert.NoError(err)
+
+	// reset
+	m.OpenSandboxDebugConsoleFunc = nil
+
+	_, err = m.OpenSandboxDebugConsole(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
 func TestVCMockAddInterface(t *testing.T) {
 	assert := assert.New(t)
 
@@ -817,3 +924,247 @@ func TestVCMockListRoutes(t *testing.T) {
 	assert.Error(err)
 	assert.True(IsMockError(err))
 }
+
+func TestVCMockSandboxLaunchMeasurement(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.SandboxLaunchMeasurementFunc)
+
+	ctx := context.Background()
+	_, err := m.SandboxLaunchMeasurement(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.SandboxLaunchMeasurementFunc = func(ctx context.Context, sandboxID string) (string, error) {
+		return "c2V2", nil
+	}
+
+	measurement, err := m.SandboxLaunchMeasurement(ctx, testSandboxID)
+	assert.NoError(err)
+	assert.Equal("c2V2", measurement)
+
+	// reset
+	m.SandboxLaunchMeasurementFunc = nil
+
+	_, err = m.SandboxLaunchMeasurement(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockSandboxBootTimes(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.SandboxBootTimesFunc)
+
+	ctx := context.Background()
+	_, err := m.SandboxBootTimes(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.SandboxBootTimesFunc = func(ctx context.Context, sandboxID string) (vc.BootTimes, error) {
+		return vc.BootTimes{Total: time.Second}, nil
+	}
+
+	times, err := m.SandboxBootTimes(ctx, testSandboxID)
+	assert.NoError(err)
+	assert.Equal(time.Second, times.Total)
+
+	// reset
+	m.SandboxBootTimesFunc = nil
+
+	_, err = m.SandboxBootTimes(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockSandboxOverhead(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.SandboxOverheadFunc)
+
+	ctx := context.Background()
+	_, err := m.SandboxOverhead(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.SandboxOverheadFunc = func(ctx context.Context, sandboxID string) (vc.Overhead, error) {
+		return vc.Overhead{ID: sandboxID, ActualMemory: 1024}, nil
+	}
+
+	overhead, err := m.SandboxOverhead(ctx, testSandboxID)
+	assert.NoError(err)
+	assert.Equal(testSandboxID, overhead.ID)
+	assert.Equal(uint64(1024), overhead.ActualMemory)
+
+	// reset
+	m.SandboxOverheadFunc = nil
+
+	_, err = m.SandboxOverhead(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockSandboxMounts(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.SandboxMountsFunc)
+
+	ctx := context.Background()
+	_, err := m.SandboxMounts(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.SandboxMountsFunc = func(ctx context.Context, sandboxID string) (vc.SandboxMountInfo, error) {
+		return vc.SandboxMountInfo{ID: sandboxID, SharedFS: "virtio-fs"}, nil
+	}
+
+	info, err := m.SandboxMounts(ctx, testSandboxID)
+	assert.NoError(err)
+	assert.Equal(testSandboxID, info.ID)
+	assert.Equal("virtio-fs", info.SharedFS)
+
+	// reset
+	m.SandboxMountsFunc = nil
+
+	_, err = m.SandboxMounts(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockCleanupOrphans(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.CleanupOrphansFunc)
+
+	ctx := context.Background()
+	_, err := m.CleanupOrphans(ctx, "")
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.CleanupOrphansFunc = func(ctx context.Context, jailerChrootBase string) ([]string, error) {
+		return []string{"/run/vc/vm/" + testSandboxID}, nil
+	}
+
+	removed, err := m.CleanupOrphans(ctx, "")
+	assert.NoError(err)
+	assert.Equal([]string{"/run/vc/vm/" + testSandboxID}, removed)
+
+	// reset
+	m.CleanupOrphansFunc = nil
+
+	_, err = m.CleanupOrphans(ctx, "")
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockRebootSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.RebootSandboxFunc)
+
+	ctx := context.Background()
+	err := m.RebootSandbox(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.RebootSandboxFunc = func(ctx context.Context, sandboxID string) error {
+		return nil
+	}
+
+	err = m.RebootSandbox(ctx, testSandboxID)
+	assert.NoError(err)
+
+	// reset
+	m.RebootSandboxFunc = nil
+
+	err = m.RebootSandbox(ctx, testSandboxID)
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockMigrateSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.MigrateSandboxFunc)
+
+	ctx := context.Background()
+	_, err := m.MigrateSandbox(ctx, testSandboxID, "tcp:localhost:4444")
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.MigrateSandboxFunc = func(ctx context.Context, sandboxID, uri string) ([]byte, error) {
+		return []byte("{}"), nil
+	}
+
+	state, err := m.MigrateSandbox(ctx, testSandboxID, "tcp:localhost:4444")
+	assert.NoError(err)
+	assert.Equal([]byte("{}"), state)
+
+	// reset
+	m.MigrateSandboxFunc = nil
+
+	_, err = m.MigrateSandbox(ctx, testSandboxID, "tcp:localhost:4444")
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockRestoreSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.RestoreSandboxFunc)
+
+	ctx := context.Background()
+	_, err := m.RestoreSandbox(ctx, []byte("{}"), "tcp:0:4444")
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.RestoreSandboxFunc = func(ctx context.Context, state []byte, uri string) (vc.VCSandbox, error) {
+		return &Sandbox{MockID: testSandboxID}, nil
+	}
+
+	sandbox, err := m.RestoreSandbox(ctx, []byte("{}"), "tcp:0:4444")
+	assert.NoError(err)
+	assert.Equal(testSandboxID, sandbox.ID())
+
+	// reset
+	m.RestoreSandboxFunc = nil
+
+	_, err = m.RestoreSandbox(ctx, []byte("{}"), "tcp:0:4444")
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
+
+func TestVCMockGetHypervisorCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &VCMock{}
+	assert.Nil(m.GetHypervisorCapabilitiesFunc)
+
+	ctx := context.Background()
+	_, err := m.GetHypervisorCapabilities(ctx, vc.QemuHypervisor, vc.HypervisorConfig{})
+	assert.Error(err)
+	assert.True(IsMockError(err))
+
+	m.GetHypervisorCapabilitiesFunc = func(ctx context.Context, hType vc.HypervisorType, conf vc.HypervisorConfig) (vc.HypervisorCapabilities, error) {
+		return vc.HypervisorCapabilities{Hypervisor: hType, MemoryHotplug: true}, nil
+	}
+
+	caps, err := m.GetHypervisorCapabilities(ctx, vc.QemuHypervisor, vc.HypervisorConfig{})
+	assert.NoError(err)
+	assert.Equal(vc.HypervisorCapabilities{Hypervisor: vc.QemuHypervisor, MemoryHotplug: true}, caps)
+
+	// reset
+	m.GetHypervisorCapabilitiesFunc = nil
+
+	_, err = m.GetHypervisorCapabilities(ctx, vc.QemuHypervisor, vc.HypervisorConfig{})
+	assert.Error(err)
+	assert.True(IsMockError(err))
+}
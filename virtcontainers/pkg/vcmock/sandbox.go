@@ -188,6 +188,16 @@ func (s *Sandbox) AddDevice(info config.DeviceInfo) (api.Device, error) {
 	return nil, nil
 }
 
+// PrewarmContainerImage implements the VCSandbox function of the same name.
+func (s *Sandbox) PrewarmContainerImage(containerID string, rootFs vc.RootFs) error {
+	return nil
+}
+
+// CreateVSockTunnel implements the VCSandbox function of the same name.
+func (s *Sandbox) CreateVSockTunnel(port uint32) (string, error) {
+	return "", nil
+}
+
 // AddInterface implements the VCSandbox function of the same name.
 func (s *Sandbox) AddInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error) {
 	return nil, nil
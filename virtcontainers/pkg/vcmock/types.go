@@ -7,6 +7,7 @@ package vcmock
 
 import (
 	"context"
+	"net"
 	"syscall"
 
 	vc "github.com/kata-containers/runtime/virtcontainers"
@@ -44,16 +45,25 @@ type VCMock struct {
 	SetLoggerFunc  func(ctx context.Context, logger *logrus.Entry)
 	SetFactoryFunc func(ctx context.Context, factory vc.Factory)
 
-	CreateSandboxFunc  func(ctx context.Context, sandboxConfig vc.SandboxConfig) (vc.VCSandbox, error)
-	DeleteSandboxFunc  func(ctx context.Context, sandboxID string) (vc.VCSandbox, error)
-	ListSandboxFunc    func(ctx context.Context) ([]vc.SandboxStatus, error)
-	FetchSandboxFunc   func(ctx context.Context, sandboxID string) (vc.VCSandbox, error)
-	RunSandboxFunc     func(ctx context.Context, sandboxConfig vc.SandboxConfig) (vc.VCSandbox, error)
-	StartSandboxFunc   func(ctx context.Context, sandboxID string) (vc.VCSandbox, error)
-	StatusSandboxFunc  func(ctx context.Context, sandboxID string) (vc.SandboxStatus, error)
-	StatsContainerFunc func(ctx context.Context, sandboxID, containerID string) (vc.ContainerStats, error)
-	StatsSandboxFunc   func(ctx context.Context, sandboxID string) (vc.SandboxStats, []vc.ContainerStats, error)
-	StopSandboxFunc    func(ctx context.Context, sandboxID string, force bool) (vc.VCSandbox, error)
+	CreateSandboxFunc            func(ctx context.Context, sandboxConfig vc.SandboxConfig) (vc.VCSandbox, error)
+	DeleteSandboxFunc            func(ctx context.Context, sandboxID string) (vc.VCSandbox, error)
+	ListSandboxFunc              func(ctx context.Context) ([]vc.SandboxStatus, error)
+	FetchSandboxFunc             func(ctx context.Context, sandboxID string) (vc.VCSandbox, error)
+	RunSandboxFunc               func(ctx context.Context, sandboxConfig vc.SandboxConfig) (vc.VCSandbox, error)
+	StartSandboxFunc             func(ctx context.Context, sandboxID string) (vc.VCSandbox, error)
+	StatusSandboxFunc            func(ctx context.Context, sandboxID string) (vc.SandboxStatus, error)
+	StatsContainerFunc           func(ctx context.Context, sandboxID, containerID string) (vc.ContainerStats, error)
+	StatsSandboxFunc             func(ctx context.Context, sandboxID string) (vc.SandboxStats, []vc.ContainerStats, error)
+	InspectSandboxFunc           func(ctx context.Context, sandboxID string) (vc.SandboxInfo, error)
+	MigrateSandboxFunc           func(ctx context.Context, sandboxID, uri string) ([]byte, error)
+	RestoreSandboxFunc           func(ctx context.Context, state []byte, uri string) (vc.VCSandbox, error)
+	RebootSandboxFunc            func(ctx context.Context, sandboxID string) error
+	SandboxLaunchMeasurementFunc func(ctx context.Context, sandboxID string) (string, error)
+	SandboxBootTimesFunc         func(ctx context.Context, sandboxID string) (vc.BootTimes, error)
+	SandboxOverheadFunc          func(ctx context.Context, sandboxID string) (vc.Overhead, error)
+	SandboxMountsFunc            func(ctx context.Context, sandboxID string) (vc.SandboxMountInfo, error)
+	CleanupOrphansFunc           func(ctx context.Context, jailerChrootBase string) ([]string, error)
+	StopSandboxFunc              func(ctx context.Context, sandboxID string, force bool) (vc.VCSandbox, error)
 
 	CreateContainerFunc      func(ctx context.Context, sandboxID string, containerConfig vc.ContainerConfig) (vc.VCSandbox, vc.VCContainer, error)
 	DeleteContainerFunc      func(ctx context.Context, sandboxID, containerID string) (vc.VCContainer, error)
@@ -67,7 +77,10 @@ type VCMock struct {
 	PauseContainerFunc       func(ctx context.Context, sandboxID, containerID string) error
 	ResumeContainerFunc      func(ctx context.Context, sandboxID, containerID string) error
 
-	AddDeviceFunc func(ctx context.Context, sandboxID string, info config.DeviceInfo) (api.Device, error)
+	AddDeviceFunc               func(ctx context.Context, sandboxID string, info config.DeviceInfo) (api.Device, error)
+	PrewarmContainerImageFunc   func(ctx context.Context, sandboxID, containerID string, rootFs vc.RootFs) error
+	ForwardSandboxPortFunc      func(ctx context.Context, sandboxID string, port uint32, listener net.Listener) error
+	OpenSandboxDebugConsoleFunc func(ctx context.Context, sandboxID string) (net.Conn, error)
 
 	AddInterfaceFunc     func(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
 	RemoveInterfaceFunc  func(ctx context.Context, sandboxID string, inf *vcTypes.Interface) (*vcTypes.Interface, error)
@@ -75,4 +88,6 @@ type VCMock struct {
 	UpdateRoutesFunc     func(ctx context.Context, sandboxID string, routes []*vcTypes.Route) ([]*vcTypes.Route, error)
 	ListRoutesFunc       func(ctx context.Context, sandboxID string) ([]*vcTypes.Route, error)
 	CleanupContainerFunc func(ctx context.Context, sandboxID, containerID string, force bool) error
+
+	GetHypervisorCapabilitiesFunc func(ctx context.Context, hType vc.HypervisorType, conf vc.HypervisorConfig) (vc.HypervisorCapabilities, error)
 }
@@ -0,0 +1,145 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+// Package volume implements the protocol CSI drivers use to assign a block
+// device directly to a sandbox: instead of mounting the volume on the host,
+// the driver publishes a mount information file keyed by the volume path and
+// the runtime hotplugs the device to the VM, the agent mounting it inside the
+// guest.
+package volume
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+)
+
+const (
+	mountInfoFileName = "mountInfo.json"
+
+	// BlockVolumeType is the type of the volumes backed by a host block
+	// device, the only type supported.
+	BlockVolumeType = "block"
+)
+
+// RootPath is the directory holding the mount information of the direct
+// assigned volumes, it's declared this way for mocking in unit tests.
+var RootPath = "/run/kata-containers/shared/direct-volumes"
+
+// MountInfo describes how a direct assigned volume is mounted in the guest.
+type MountInfo struct {
+	// VolumeType is the type of the volume, see BlockVolumeType.
+	VolumeType string `json:"volume-type"`
+
+	// Device is the host path of the block device backing the volume.
+	Device string `json:"device"`
+
+	// FsType is the filesystem of the device, used by the agent to
+	// mount it.
+	FsType string `json:"fstype"`
+
+	// Metadata is free form information of the CSI driver.
+	Metadata map[string]string `json:"metadata,omitempty"`
+
+	// Options are the options used by the agent to mount the device.
+	Options []string `json:"options,omitempty"`
+
+	// CacheMode is the host cache mode of the device drive, see
+	// config.BlockCacheNone.
+	CacheMode string `json:"cache-mode,omitempty"`
+}
+
+// ReadOnly returns true if the volume is mounted read-only in the guest.
+func (m *MountInfo) ReadOnly() bool {
+	for _, o := range m.Options {
+		if o == "ro" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *MountInfo) validate() error {
+	if m.VolumeType != BlockVolumeType {
+		return fmt.Errorf("Unsupported volume type %q", m.VolumeType)
+	}
+
+	if m.Device == "" {
+		return fmt.Errorf("Missing volume device")
+	}
+
+	if m.FsType == "" {
+		return fmt.Errorf("Missing volume filesystem type")
+	}
+
+	return config.ValidBlockCacheMode(m.CacheMode)
+}
+
+// mountInfoDir returns the directory holding the mount information of the
+// volume, named after the hash of the volume path so that it's a single path
+// component whatever the length of the volume path.
+func mountInfoDir(volumePath string) string {
+	sum := sha256.Sum256([]byte(volumePath))
+	return filepath.Join(RootPath, hex.EncodeToString(sum[:]))
+}
+
+// Add records the mount information of the volume published at volumePath,
+// mountInfo being its JSON representation.
+func Add(volumePath string, mountInfo string) error {
+	var info MountInfo
+	if err := json.Unmarshal([]byte(mountInfo), &info); err != nil {
+		return fmt.Errorf("Invalid mount info: %v", err)
+	}
+
+	if err := info.validate(); err != nil {
+		return err
+	}
+
+	dir := mountInfoDir(volumePath)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(dir, mountInfoFileName), data, 0600)
+}
+
+// Remove deletes the mount information of the volume published at
+// volumePath.
+func Remove(volumePath string) error {
+	return os.RemoveAll(mountInfoDir(volumePath))
+}
+
+// VolumeMountInfo returns the mount information of the volume published at
+// volumePath, the error satisfies os.IsNotExist() when the volume is not a
+// direct assigned one.
+func VolumeMountInfo(volumePath string) (*MountInfo, error) {
+	data, err := ioutil.ReadFile(filepath.Join(mountInfoDir(volumePath), mountInfoFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var info MountInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		return nil, fmt.Errorf("Invalid mount info of volume %s: %v", volumePath, err)
+	}
+
+	if err := info.validate(); err != nil {
+		return nil, fmt.Errorf("Invalid mount info of volume %s: %v", volumePath, err)
+	}
+
+	return &info, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package volume
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVolumeMountInfo(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	savedRootPath := RootPath
+	RootPath = tmpDir
+	defer func() {
+		RootPath = savedRootPath
+	}()
+
+	volumePath := "/var/lib/kubelet/pods/abc/volumes/kubernetes.io~csi/pvc/mount"
+
+	_, err = VolumeMountInfo(volumePath)
+	assert.True(os.IsNotExist(err))
+
+	for _, mountInfo := range []string{
+		"",
+		`{"volume-type": "block"`,
+		`{"volume-type": "file", "device": "/dev/sdb", "fstype": "ext4"}`,
+		`{"volume-type": "block", "fstype": "ext4"}`,
+		`{"volume-type": "block", "device": "/dev/sdb"}`,
+		`{"volume-type": "block", "device": "/dev/sdb", "fstype": "ext4", "cache-mode": "unsafe"}`,
+	} {
+		assert.Error(Add(volumePath, mountInfo), "mount info: %s", mountInfo)
+	}
+
+	err = Add(volumePath, `{"volume-type": "block", "device": "/dev/sdb", "fstype": "ext4", "options": ["ro"], "cache-mode": "none"}`)
+	assert.NoError(err)
+
+	info, err := VolumeMountInfo(volumePath)
+	assert.NoError(err)
+	assert.Equal(&MountInfo{
+		VolumeType: BlockVolumeType,
+		Device:     "/dev/sdb",
+		FsType:     "ext4",
+		Options:    []string{"ro"},
+		CacheMode:  "none",
+	}, info)
+	assert.True(info.ReadOnly())
+
+	info.Options = []string{"rw", "noatime"}
+	assert.False(info.ReadOnly())
+
+	// the volumes are keyed by their path
+	_, err = VolumeMountInfo(volumePath + "/foo")
+	assert.True(os.IsNotExist(err))
+
+	assert.NoError(Remove(volumePath))
+	_, err = VolumeMountInfo(volumePath)
+	assert.True(os.IsNotExist(err))
+
+	// the volume paths can be longer than a file name
+	volumePath = "/var/lib/kubelet/pods/" + strings.Repeat("a", 300) + "/mount"
+	assert.NoError(Add(volumePath, `{"volume-type": "block", "device": "/dev/sdb", "fstype": "ext4"}`))
+	_, err = VolumeMountInfo(volumePath)
+	assert.NoError(err)
+}
@@ -15,7 +15,6 @@ import (
 	"math"
 	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -32,6 +31,7 @@ import (
 
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
 	"github.com/kata-containers/runtime/virtcontainers/pkg/uuid"
 	"github.com/kata-containers/runtime/virtcontainers/types"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
@@ -51,6 +51,7 @@ type qmpChannel struct {
 	sync.Mutex
 	ctx     context.Context
 	path    string
+	rawPath string
 	qmp     *govmmQemu.QMP
 	disconn chan struct{}
 }
@@ -70,6 +71,7 @@ type QemuState struct {
 	UUID                 string
 	HotplugVFIOOnRootBus bool
 	VirtiofsdPid         int
+	VirtiofsdRestarts    int
 	PCIeRootPort         int
 }
 
@@ -97,6 +99,9 @@ type qemu struct {
 
 	stopped bool
 
+	// virtiofsd supervises the virtiofsd started with the VM
+	virtiofsd *virtiofsdSupervisor
+
 	store persistapi.PersistDriver
 }
 
@@ -105,9 +110,16 @@ const (
 	qmpSocket     = "qmp.sock"
 	vhostFSSocket = "vhost-fs.sock"
 
+	// virtiofsdReconnect is the delay in seconds between the attempts of
+	// QEMU to reconnect to a restarted virtiofsd
+	virtiofsdReconnect = 1
+
 	qmpCapErrMsg  = "Failed to negoatiate QMP capabilities"
 	qmpExecCatCmd = "exec:cat"
 
+	// unlike templating, a sandbox migration sends the whole VM memory
+	qmpSandboxMigrationTimeout = 10 * time.Minute
+
 	scsiControllerID         = "scsi0"
 	rngID                    = "rng0"
 	vsockKernelOption        = "agent.use_vsock"
@@ -171,7 +183,7 @@ func (q *qemu) kernelParameters() string {
 	// add the params specified by the provided config. As the kernel
 	// honours the last parameter value set and since the config-provided
 	// params are added here, they will take priority over the defaults.
-	params = append(params, q.config.KernelParams...)
+	params = appendKernelParams(params, q.config.KernelParams)
 
 	paramsStr := SerializeParams(params, "=")
 
@@ -217,7 +229,7 @@ func (q *qemu) trace(name string) (opentracing.Span, context.Context) {
 		q.ctx = context.Background()
 	}
 
-	span, ctx := opentracing.StartSpanFromContext(q.ctx, name)
+	span, ctx := startSpanFromContext(q.ctx, "hypervisor", name)
 
 	span.SetTag("subsystem", "hypervisor")
 	span.SetTag("type", "qemu")
@@ -235,6 +247,10 @@ func (q *qemu) setup(id string, hypervisorConfig *HypervisorConfig) error {
 		return err
 	}
 
+	if hypervisorConfig.UsePmemRootfs {
+		return fmt.Errorf("Persistent memory rootfs is not supported, the guest image is plugged as a nvdimm device unless image nvdimm is disabled")
+	}
+
 	q.id = id
 	q.config = *hypervisorConfig
 	q.arch = newQemuArch(q.config)
@@ -299,8 +315,38 @@ func (q *qemu) setup(id string, hypervisorConfig *HypervisorConfig) error {
 	return nil
 }
 
-func (q *qemu) cpuTopology() govmmQemu.SMP {
-	return q.arch.cpuTopology(q.config.NumVCPUs, q.config.DefaultMaxVCPUs)
+func (q *qemu) cpuTopology() (govmmQemu.SMP, error) {
+	smp := q.arch.cpuTopology(q.config.NumVCPUs, q.config.DefaultMaxVCPUs)
+
+	if q.config.CPUSockets != 0 {
+		maxCPUs := q.config.CPUSockets * q.config.CPUCores * q.config.CPUThreads
+		if smp.MaxCPUs != maxCPUs {
+			return govmmQemu.SMP{}, fmt.Errorf("The guest CPU topology of %d vCPUs can't be used with %d maximum vCPUs", maxCPUs, smp.MaxCPUs)
+		}
+
+		smp.Sockets = q.config.CPUSockets
+		smp.Cores = q.config.CPUCores
+		smp.Threads = q.config.CPUThreads
+
+		return smp, nil
+	}
+
+	threads, err := guestThreadsPerCore(&q.config)
+	if err != nil || threads == 1 {
+		return smp, err
+	}
+
+	// the maximum vCPUs are whole cores of the guest
+	maxCPUs := smp.MaxCPUs - smp.MaxCPUs%threads
+	if maxCPUs < smp.CPUs {
+		return govmmQemu.SMP{}, fmt.Errorf("%d vCPUs don't fit in the %d maximum vCPUs of cores of %d threads", smp.CPUs, smp.MaxCPUs, threads)
+	}
+
+	smp.Threads = threads
+	smp.Sockets = maxCPUs / (smp.Cores * threads)
+	smp.MaxCPUs = maxCPUs
+
+	return smp, nil
 }
 
 func (q *qemu) hostMemMB() (uint64, error) {
@@ -327,7 +373,7 @@ func (q *qemu) memoryTopology() (govmmQemu.Memory, error) {
 }
 
 func (q *qemu) qmpSocketPath(id string) (string, error) {
-	return utils.BuildSocketPath(q.store.RunVMStoragePath(), id, qmpSocket)
+	return utils.BuildSocketPath(SandboxVMPath(q.store.RunVMStoragePath(), id), qmpSocket)
 }
 
 func (q *qemu) getQemuMachine() (govmmQemu.Machine, error) {
@@ -347,6 +393,21 @@ func (q *qemu) getQemuMachine() (govmmQemu.Machine, error) {
 	return machine, nil
 }
 
+// enableProtection enables the memory encryption of confidential guests,
+// which can only boot a firmware, OVMF for SEV or TDVF for TDX.
+func (q *qemu) enableProtection() error {
+	firmwarePath, err := q.config.FirmwareAssetPath()
+	if err != nil {
+		return err
+	}
+
+	if firmwarePath == "" {
+		return fmt.Errorf("Confidential guests require an OVMF firmware")
+	}
+
+	return q.arch.enableProtection()
+}
+
 func (q *qemu) appendImage(devices []govmmQemu.Device) ([]govmmQemu.Device, error) {
 	imagePath, err := q.config.ImageAssetPath()
 	if err != nil {
@@ -369,9 +430,15 @@ func (q *qemu) createQmpSocket() ([]govmmQemu.QMPSocket, error) {
 		return nil, err
 	}
 
+	rawSockPath, err := utils.BuildSocketPath(SandboxVMPath(q.store.RunVMStoragePath(), q.id), qmpRawSocket)
+	if err != nil {
+		return nil, err
+	}
+
 	q.qmpMonitorCh = qmpChannel{
-		ctx:  q.ctx,
-		path: monitorSockPath,
+		ctx:     q.ctx,
+		path:    monitorSockPath,
+		rawPath: rawSockPath,
 	}
 
 	return []govmmQemu.QMPSocket{
@@ -381,6 +448,12 @@ func (q *qemu) createQmpSocket() ([]govmmQemu.QMPSocket, error) {
 			Server: true,
 			NoWait: true,
 		},
+		{
+			Type:   "unix",
+			Name:   q.qmpMonitorCh.rawPath,
+			Server: true,
+			NoWait: true,
+		},
 	}, nil
 }
 
@@ -465,12 +538,21 @@ func (q *qemu) createSandbox(ctx context.Context, id string, networkNS NetworkNa
 		return err
 	}
 
+	if q.config.ConfidentialGuest {
+		if err := q.enableProtection(); err != nil {
+			return err
+		}
+	}
+
 	machine, err := q.getQemuMachine()
 	if err != nil {
 		return err
 	}
 
-	smp := q.cpuTopology()
+	smp, err := q.cpuTopology()
+	if err != nil {
+		return err
+	}
 
 	memory, err := q.memoryTopology()
 	if err != nil {
@@ -505,6 +587,9 @@ func (q *qemu) createSandbox(ctx context.Context, id string, networkNS NetworkNa
 	}
 
 	incoming := q.setupTemplate(&knobs, &memory)
+	if q.config.IncomingMigrationURI != "" {
+		incoming.MigrationType = govmmQemu.MigrationDefer
+	}
 
 	// With the current implementations, VM templating will not work with file
 	// based memory (stand-alone) or virtiofs. This is because VM templating
@@ -550,8 +635,24 @@ func (q *qemu) createSandbox(ctx context.Context, id string, networkNS NetworkNa
 		return err
 	}
 
+	devices, err = q.arch.appendProtectionDevice(devices)
+	if err != nil {
+		return err
+	}
+
 	cpuModel := q.arch.cpuModel()
 
+	cpuFeatures, err := parseCPUFeatures(q.config.CPUFeatures)
+	if err != nil {
+		return err
+	}
+	if len(cpuFeatures) > 0 {
+		if err := checkCPUFeatures(cpuFeatures, procCPUInfo); err != nil {
+			return err
+		}
+		cpuModel += "," + qemuCPUFeatures(cpuFeatures)
+	}
+
 	firmwarePath, err := q.config.FirmwareAssetPath()
 	if err != nil {
 		return err
@@ -580,7 +681,7 @@ func (q *qemu) createSandbox(ctx context.Context, id string, networkNS NetworkNa
 		VGA:         "none",
 		GlobalParam: "kvm-pit.lost_tick_policy=discard",
 		Bios:        firmwarePath,
-		PidFile:     filepath.Join(q.store.RunVMStoragePath(), q.id, "pid"),
+		PidFile:     filepath.Join(SandboxVMPath(q.store.RunVMStoragePath(), q.id), "pid"),
 	}
 
 	if ioThread != nil {
@@ -609,13 +710,12 @@ func (q *qemu) createSandbox(ctx context.Context, id string, networkNS NetworkNa
 }
 
 func (q *qemu) vhostFSSocketPath(id string) (string, error) {
-	return utils.BuildSocketPath(q.store.RunVMStoragePath(), id, vhostFSSocket)
+	return utils.BuildSocketPath(SandboxVMPath(q.store.RunVMStoragePath(), id), vhostFSSocket)
 }
 
 func (q *qemu) virtiofsdArgs(fd uintptr) []string {
 	// The daemon will terminate when the vhost-user socket
-	// connection with QEMU closes.  Therefore we do not keep track
-	// of this child process after returning from this function.
+	// connection with QEMU closes.
 	sourcePath := filepath.Join(kataHostSharedDir(), q.id)
 	args := []string{
 		fmt.Sprintf("--fd=%v", fd),
@@ -628,6 +728,10 @@ func (q *qemu) virtiofsdArgs(fd uintptr) []string {
 		args = append(args, "-f")
 	}
 
+	if q.config.VirtioFSThreadPoolSize != 0 {
+		args = append(args, fmt.Sprintf("--thread-pool-size=%d", q.config.VirtioFSThreadPoolSize))
+	}
+
 	if len(q.config.VirtioFSExtraArgs) != 0 {
 		args = append(args, q.config.VirtioFSExtraArgs...)
 	}
@@ -659,32 +763,37 @@ func (q *qemu) setupVirtiofsd() (err error) {
 		return err
 	}
 
-	const sockFd = 3 // Cmd.ExtraFiles[] fds are numbered starting from 3
-	cmd := exec.Command(q.config.VirtioFSDaemon, q.virtiofsdArgs(sockFd)...)
-	cmd.ExtraFiles = append(cmd.ExtraFiles, fd)
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return err
+	// The supervisor restarts virtiofsd on the same socket when it
+	// crashes, QEMU reconnecting to it, and stops the sandbox when
+	// virtiofsd quits.
+	q.virtiofsd = &virtiofsdSupervisor{
+		path:        q.config.VirtioFSDaemon,
+		args:        q.virtiofsdArgs,
+		socket:      fd,
+		maxRestarts: q.config.VirtioFSMaxRestarts,
+		exited: func() {
+			q.stopSandbox()
+		},
+		logger: q.Logger(),
 	}
 
-	err = cmd.Start()
-	if err == nil {
-		q.state.VirtiofsdPid = cmd.Process.Pid
+	if err = q.virtiofsd.start(); err != nil {
+		fd.Close()
+		q.virtiofsd = nil
+		return err
 	}
-	fd.Close()
 
-	// Monitor virtiofsd's stderr and stop sandbox if virtiofsd quits
-	go func() {
-		scanner := bufio.NewScanner(stderr)
-		for scanner.Scan() {
-			q.Logger().WithField("source", "virtiofsd").Info(scanner.Text())
-		}
-		q.Logger().Info("virtiofsd quits")
-		// Wait to release resources of virtiofsd process
-		cmd.Process.Wait()
-		q.stopSandbox()
-	}()
-	return err
+	q.state.VirtiofsdPid, q.state.VirtiofsdRestarts = q.virtiofsd.status()
+
+	return nil
+}
+
+// updateVirtiofsdState updates the state with the virtiofsd restarted by
+// its supervisor.
+func (q *qemu) updateVirtiofsdState() {
+	if q.virtiofsd != nil {
+		q.state.VirtiofsdPid, q.state.VirtiofsdRestarts = q.virtiofsd.status()
+	}
 }
 
 func (q *qemu) getMemArgs() (bool, string, string, error) {
@@ -750,6 +859,21 @@ func (q *qemu) setupVirtioMem() error {
 	return err
 }
 
+// checkHugePages makes sure the guest memory can be allocated from the
+// default huge pages of the host.
+func (q *qemu) checkHugePages() error {
+	if err := checkHugePagesMount(hugePagesMountPoint); err != nil {
+		return err
+	}
+
+	pageSizeKb, err := getDefaultHugePageSizeKb(procMemInfo)
+	if err != nil {
+		return err
+	}
+
+	return checkFreeHugePages(q.config.MemorySize, pageSizeKb)
+}
+
 // startSandbox will start the Sandbox's VM.
 func (q *qemu) startSandbox(timeout int) error {
 	span, _ := q.trace("startSandbox")
@@ -767,6 +891,12 @@ func (q *qemu) startSandbox(timeout int) error {
 		q.Logger().WithField("default-kernel-parameters", formatted).Debug()
 	}
 
+	if q.config.HugePages {
+		if err := q.checkHugePages(); err != nil {
+			return err
+		}
+	}
+
 	defer func() {
 		for _, fd := range q.fds {
 			if err := fd.Close(); err != nil {
@@ -776,8 +906,7 @@ func (q *qemu) startSandbox(timeout int) error {
 		q.fds = []*os.File{}
 	}()
 
-	vmPath := filepath.Join(q.store.RunVMStoragePath(), q.id)
-	err := os.MkdirAll(vmPath, DirMode)
+	vmPath, err := createSandboxVMPath(q.store.RunVMStoragePath(), q.id)
 	if err != nil {
 		return err
 	}
@@ -826,6 +955,12 @@ func (q *qemu) startSandbox(timeout int) error {
 		}
 	}
 
+	if q.config.IncomingMigrationURI != "" {
+		if err = q.migrateIncoming(); err != nil {
+			return err
+		}
+	}
+
 	if q.config.VirtioMem {
 		err = q.setupVirtioMem()
 	}
@@ -850,7 +985,36 @@ func (q *qemu) bootFromTemplate() error {
 	if err != nil {
 		return err
 	}
-	return q.waitMigration()
+	return q.waitMigration(qmpMigrationWaitTimeout)
+}
+
+// migrateIncoming restores the VM from the migration stream sent by
+// migrateSandbox() on the source host.
+func (q *qemu) migrateIncoming() error {
+	uri, err := qmpMigrationURI(q.config.IncomingMigrationURI, true)
+	if err != nil {
+		return err
+	}
+
+	err = q.qmpSetup()
+	if err != nil {
+		return err
+	}
+	defer q.qmpShutdown()
+
+	q.Logger().WithField("uri", q.config.IncomingMigrationURI).Info("Restoring sandbox from migration")
+
+	err = q.qmpMonitorCh.qmp.ExecuteMigrationIncoming(q.qmpMonitorCh.ctx, uri)
+	if err != nil {
+		return err
+	}
+
+	if err = q.waitMigration(qmpSandboxMigrationTimeout); err != nil {
+		return err
+	}
+
+	// the VM was paused on the source host to be migrated
+	return q.qmpMonitorCh.qmp.ExecuteCont(q.qmpMonitorCh.ctx)
 }
 
 // waitSandbox will wait for the Sandbox's VM to be up and running.
@@ -880,7 +1044,7 @@ func (q *qemu) waitSandbox(timeout int) error {
 		}
 
 		if int(time.Since(timeStart).Seconds()) > timeout {
-			return fmt.Errorf("Failed to connect to QEMU instance (timeout %ds): %v", timeout, err)
+			return vcTypes.Errorf(vcTypes.ErrCodeHypervisorNotRunning, "Failed to connect to QEMU instance (timeout %ds): %v", timeout, err)
 		}
 
 		time.Sleep(time.Duration(50) * time.Millisecond)
@@ -923,6 +1087,11 @@ func (q *qemu) stopSandbox() error {
 		q.stopped = true
 	}()
 
+	// virtiofsd quits with QEMU
+	if q.virtiofsd != nil {
+		q.virtiofsd.stop()
+	}
+
 	if q.config.Debug && q.qemuConfig.LogFile != "" {
 		f, err := os.OpenFile(q.qemuConfig.LogFile, os.O_RDONLY, 0)
 		if err == nil {
@@ -941,19 +1110,41 @@ func (q *qemu) stopSandbox() error {
 		return err
 	}
 
+	pid := q.getPids()[0]
+
 	err = q.qmpMonitorCh.qmp.ExecuteQuit(q.qmpMonitorCh.ctx)
 	if err != nil {
 		q.Logger().WithError(err).Error("Fail to execute qmp QUIT")
 		return err
 	}
 
-	return nil
+	if pid <= 0 {
+		return nil
+	}
+
+	// QUIT only asks QEMU to exit, give it some time to do it properly
+	// before using a hammer.
+	tInit := time.Now()
+	for {
+		if err = syscall.Kill(pid, syscall.Signal(0)); err != nil {
+			return nil
+		}
+
+		if int(time.Since(tInit).Seconds()) >= q.config.shutdownTimeout() {
+			q.Logger().Warnf("VM still running after waiting %ds", q.config.shutdownTimeout())
+			break
+		}
+
+		time.Sleep(time.Duration(50) * time.Millisecond)
+	}
+
+	return syscall.Kill(pid, syscall.SIGKILL)
 }
 
 func (q *qemu) cleanupVM() error {
 
 	// cleanup vm path
-	dir := filepath.Join(q.store.RunVMStoragePath(), q.id)
+	dir := SandboxVMPath(q.store.RunVMStoragePath(), q.id)
 
 	// If it's a symlink, remove both dir and the target.
 	// This can happen when vm template links a sandbox to a vm.
@@ -978,6 +1169,9 @@ func (q *qemu) cleanupVM() error {
 		if err := os.RemoveAll(dir); err != nil {
 			q.Logger().WithError(err).WithField("path", dir).Warnf("failed to remove vm path")
 		}
+		if err := cleanupSandboxTmpDir(q.config.VMid); err != nil {
+			q.Logger().WithError(err).WithField("vm", q.config.VMid).Warn("failed to remove vm temporary tree")
+		}
 	}
 
 	return nil
@@ -1018,12 +1212,19 @@ func (q *qemu) qmpSetup() error {
 	// Auto-closed by QMPStart().
 	disconnectCh := make(chan struct{})
 
-	qmp, _, err := govmmQemu.QMPStart(q.qmpMonitorCh.ctx, q.qmpMonitorCh.path, cfg, disconnectCh)
+	ctx, cancel := context.WithTimeout(q.qmpMonitorCh.ctx, time.Duration(q.config.vmmAPITimeout())*time.Second)
+	defer cancel()
+
+	qmp, ver, err := govmmQemu.QMPStart(ctx, q.qmpMonitorCh.path, cfg, disconnectCh)
 	if err != nil {
 		q.Logger().WithError(err).Error("Failed to connect to QEMU instance")
-		return err
+		return vcTypes.WithErrorCode(vcTypes.ErrCodeHypervisorNotRunning, err)
 	}
 
+	// the runtime may not have started the VM, e.g. when it's restored
+	qemuMajorVersion = ver.Major
+	qemuMinorVersion = ver.Minor
+
 	err = qmp.ExecuteQMPCapabilities(q.qmpMonitorCh.ctx)
 	if err != nil {
 		qmp.Shutdown()
@@ -1050,6 +1251,71 @@ func (q *qemu) qmpShutdown() {
 	}
 }
 
+// blockDeviceCache returns whether cache options are set for the hot added
+// drive and their direct and no-flush values, the cache mode of the drive
+// overriding the hypervisor configuration.
+func (q *qemu) blockDeviceCache(drive *config.BlockDrive) (bool, bool, bool, error) {
+	switch drive.CacheMode {
+	case "":
+		return q.config.BlockDeviceCacheSet, q.config.BlockDeviceCacheDirect, q.config.BlockDeviceCacheNoflush, nil
+	case config.BlockCacheNone:
+		return true, true, false, nil
+	case config.BlockCacheWriteback:
+		return true, false, false, nil
+	case config.BlockCacheWritethrough:
+		// the write cache of a drive is a property of the guest
+		// device, which can't be set through QMP by the runtime
+		return false, false, false, fmt.Errorf("Cache mode %q is not supported for the drives hot added to QEMU", drive.CacheMode)
+	}
+
+	return false, false, false, config.ValidBlockCacheMode(drive.CacheMode)
+}
+
+// blockdevAddReadOnlyArgs returns the arguments of the blockdev-add of a
+// read-only drive, the ones govmm sends for read-write drives plus the
+// read-only flag.
+func blockdevAddReadOnlyArgs(drive *config.BlockDrive, cacheSet, direct, noFlush bool) map[string]interface{} {
+	args := map[string]interface{}{
+		"driver":    "raw",
+		"node-name": drive.ID,
+		"read-only": true,
+		"file": map[string]interface{}{
+			"driver":   "file",
+			"filename": drive.File,
+		},
+	}
+
+	if cacheSet {
+		args["cache"] = map[string]interface{}{
+			"direct":   direct,
+			"no-flush": noFlush,
+		}
+	}
+
+	return args
+}
+
+// blockDeviceAddArgs returns the arguments of the device_add of a drive
+// shared by the virtio-blk and SCSI drivers: the ones govmm sends plus the
+// serial number reported to the guest, if any, which govmm doesn't take.
+func blockDeviceAddArgs(drive *config.BlockDrive, devID, driver string) map[string]interface{} {
+	args := map[string]interface{}{
+		"id":     devID,
+		"driver": driver,
+		"drive":  drive.ID,
+	}
+
+	if drive.Serial != "" {
+		args["serial"] = drive.Serial
+	}
+
+	if qemuMajorVersion > 2 || (qemuMajorVersion == 2 && qemuMinorVersion >= 10) {
+		args["share-rw"] = "on"
+	}
+
+	return args
+}
+
 func (q *qemu) hotplugAddBlockDevice(drive *config.BlockDrive, op operation, devID string) (err error) {
 	// drive can be a pmem device, in which case it's used as backing file for a nvdimm device
 	if q.config.BlockDeviceDriver == config.Nvdimm || drive.Pmem {
@@ -1081,8 +1347,15 @@ func (q *qemu) hotplugAddBlockDevice(drive *config.BlockDrive, op operation, dev
 		return nil
 	}
 
-	if q.config.BlockDeviceCacheSet {
-		err = q.qmpMonitorCh.qmp.ExecuteBlockdevAddWithCache(q.qmpMonitorCh.ctx, drive.File, drive.ID, q.config.BlockDeviceCacheDirect, q.config.BlockDeviceCacheNoflush)
+	cacheSet, direct, noFlush, err := q.blockDeviceCache(drive)
+	if err != nil {
+		return err
+	}
+
+	if drive.ReadOnly {
+		err = q.qmpExecute("blockdev-add", blockdevAddReadOnlyArgs(drive, cacheSet, direct, noFlush), nil)
+	} else if cacheSet {
+		err = q.qmpMonitorCh.qmp.ExecuteBlockdevAddWithCache(q.qmpMonitorCh.ctx, drive.File, drive.ID, direct, noFlush)
 	} else {
 		err = q.qmpMonitorCh.qmp.ExecuteBlockdevAdd(q.qmpMonitorCh.ctx, drive.File, drive.ID)
 	}
@@ -1096,6 +1369,12 @@ func (q *qemu) hotplugAddBlockDevice(drive *config.BlockDrive, op operation, dev
 		}
 	}()
 
+	// the serial number lets the agent find the drive whatever the name
+	// the guest gives it
+	if q.config.BlockDeviceNaming == config.BlockNamingSerial {
+		drive.Serial = config.DriveSerial(drive.ID)
+	}
+
 	switch {
 	case q.config.BlockDeviceDriver == config.VirtioBlockCCW:
 		driver := "virtio-blk-ccw"
@@ -1113,7 +1392,14 @@ func (q *qemu) hotplugAddBlockDevice(drive *config.BlockDrive, op operation, dev
 		if err != nil {
 			return err
 		}
-		if err = q.qmpMonitorCh.qmp.ExecuteDeviceAdd(q.qmpMonitorCh.ctx, drive.ID, devID, driver, devNoHotplug, "", true, false); err != nil {
+		if drive.Serial != "" {
+			args := blockDeviceAddArgs(drive, devID, driver)
+			args["devno"] = devNoHotplug
+			err = q.qmpExecute("device_add", args, nil)
+		} else {
+			err = q.qmpMonitorCh.qmp.ExecuteDeviceAdd(q.qmpMonitorCh.ctx, drive.ID, devID, driver, devNoHotplug, "", true, false)
+		}
+		if err != nil {
 			return err
 		}
 	case q.config.BlockDeviceDriver == config.VirtioBlock:
@@ -1132,7 +1418,23 @@ func (q *qemu) hotplugAddBlockDevice(drive *config.BlockDrive, op operation, dev
 		// PCI address is in the format bridge-addr/device-addr eg. "03/02"
 		drive.PCIAddr = fmt.Sprintf("%02x", bridge.Addr) + "/" + addr
 
-		if err = q.qmpMonitorCh.qmp.ExecutePCIDeviceAdd(q.qmpMonitorCh.ctx, drive.ID, devID, driver, addr, bridge.ID, romFile, 0, true, defaultDisableModern); err != nil {
+		queues := int(q.config.BlockDeviceQueues)
+		if drive.Serial != "" {
+			args := blockDeviceAddArgs(drive, devID, driver)
+			args["addr"] = addr
+			args["bus"] = bridge.ID
+			args["romfile"] = romFile
+			if queues > 0 {
+				args["num-queues"] = strconv.Itoa(queues)
+			}
+			if defaultDisableModern {
+				args["disable-modern"] = true
+			}
+			err = q.qmpExecute("device_add", args, nil)
+		} else {
+			err = q.qmpMonitorCh.qmp.ExecutePCIDeviceAdd(q.qmpMonitorCh.ctx, drive.ID, devID, driver, addr, bridge.ID, romFile, queues, true, defaultDisableModern)
+		}
+		if err != nil {
 			return err
 		}
 	case q.config.BlockDeviceDriver == config.VirtioSCSI:
@@ -1147,7 +1449,14 @@ func (q *qemu) hotplugAddBlockDevice(drive *config.BlockDrive, op operation, dev
 			return err
 		}
 
-		if err = q.qmpMonitorCh.qmp.ExecuteSCSIDeviceAdd(q.qmpMonitorCh.ctx, drive.ID, devID, driver, bus, romFile, scsiID, lun, true, defaultDisableModern); err != nil {
+		// the WWN gives the drive a persistent name in the guest,
+		// which govmm can't pass
+		args := blockDeviceAddArgs(drive, devID, driver)
+		args["bus"] = bus
+		args["scsi-id"] = scsiID
+		args["lun"] = lun
+		args["wwn"] = config.DriveWWN(drive.ID)
+		if err = q.qmpExecute("device_add", args, nil); err != nil {
 			return err
 		}
 	default:
@@ -1356,6 +1665,44 @@ func (q *qemu) hotAddNetDevice(name, hardAddr string, VMFds, VhostFds []*os.File
 	return q.qmpMonitorCh.qmp.ExecuteNetdevAddByFds(q.qmpMonitorCh.ctx, "tap", name, VMFdNames, VhostFdNames)
 }
 
+// hotAddNetDeviceWithoutFeatures adds the virtio-net device of a tap with
+// the virtio-net features turned off, which govmm's ExecuteNetPCIDeviceAdd
+// and ExecuteNetCCWDeviceAdd don't take, passing the other arguments they
+// would pass.
+func (q *qemu) hotAddNetDeviceWithoutFeatures(machine govmmQemu.Machine, netdevID, devID, macAddr, addr, bus, devNo string, queues int, features []string) error {
+	args := map[string]interface{}{
+		"id":     devID,
+		"netdev": netdevID,
+		"mac":    macAddr,
+	}
+
+	if machine.Type == QemuCCWVirtio {
+		args["driver"] = govmmQemu.VirtioNetCCW
+		args["devno"] = devNo
+		if queues > 0 {
+			args["mq"] = "on"
+		}
+	} else {
+		args["driver"] = govmmQemu.VirtioNetPCI
+		args["romfile"] = romFile
+		args["bus"] = bus
+		args["addr"] = addr
+		if defaultDisableModern {
+			args["disable-modern"] = true
+		}
+		if queues > 0 {
+			args["mq"] = "on"
+			args["vectors"] = 2*queues + 2
+		}
+	}
+
+	for _, feature := range features {
+		args[feature] = "off"
+	}
+
+	return q.qmpExecute("device_add", args, nil)
+}
+
 func (q *qemu) hotplugNetDevice(endpoint Endpoint, op operation) (err error) {
 	err = q.qmpSetup()
 	if err != nil {
@@ -1376,6 +1723,9 @@ func (q *qemu) hotplugNetDevice(endpoint Endpoint, op operation) (err error) {
 
 	devID := "virtio-" + tap.ID
 	if op == addDevice {
+		// the device has as many queues as the tap
+		queues := len(tap.VMFds)
+
 		if err = q.hotAddNetDevice(tap.Name, endpoint.HardwareAddr(), tap.VMFds, tap.VhostFds); err != nil {
 			return err
 		}
@@ -1405,11 +1755,16 @@ func (q *qemu) hotplugNetDevice(endpoint Endpoint, op operation) (err error) {
 		if err != nil {
 			return err
 		}
+		devNoHotplug := fmt.Sprintf("fe.%x.%x", bridge.Addr, addr)
+
+		if features := netOffloadFeatures(tap.DisabledOffloads); len(features) > 0 {
+			return q.hotAddNetDeviceWithoutFeatures(machine, tap.Name, devID, endpoint.HardwareAddr(), addr, bridge.ID, devNoHotplug, queues, features)
+		}
+
 		if machine.Type == QemuCCWVirtio {
-			devNoHotplug := fmt.Sprintf("fe.%x.%x", bridge.Addr, addr)
-			return q.qmpMonitorCh.qmp.ExecuteNetCCWDeviceAdd(q.qmpMonitorCh.ctx, tap.Name, devID, endpoint.HardwareAddr(), devNoHotplug, int(q.config.NumVCPUs))
+			return q.qmpMonitorCh.qmp.ExecuteNetCCWDeviceAdd(q.qmpMonitorCh.ctx, tap.Name, devID, endpoint.HardwareAddr(), devNoHotplug, queues)
 		}
-		return q.qmpMonitorCh.qmp.ExecuteNetPCIDeviceAdd(q.qmpMonitorCh.ctx, tap.Name, devID, endpoint.HardwareAddr(), addr, bridge.ID, romFile, int(q.config.NumVCPUs), defaultDisableModern)
+		return q.qmpMonitorCh.qmp.ExecuteNetPCIDeviceAdd(q.qmpMonitorCh.ctx, tap.Name, devID, endpoint.HardwareAddr(), addr, bridge.ID, romFile, queues, defaultDisableModern)
 
 	}
 
@@ -1482,6 +1837,10 @@ func (q *qemu) hotplugCPUs(vcpus uint32, op operation) (uint32, error) {
 		return 0, nil
 	}
 
+	if !q.arch.supportGuestCPUHotplug() {
+		return 0, fmt.Errorf("guest vCPU hotplug not supported")
+	}
+
 	err := q.qmpSetup()
 	if err != nil {
 		return 0, err
@@ -1695,6 +2054,22 @@ func (q *qemu) resumeSandbox() error {
 	return q.togglePauseSandbox(false)
 }
 
+// rebootSandbox resets the VM, its hot added devices are kept.
+func (q *qemu) rebootSandbox() error {
+	span, _ := q.trace("rebootSandbox")
+	defer span.Finish()
+
+	// the memory of the guest is encrypted for its first boot only
+	if q.config.ConfidentialGuest {
+		return fmt.Errorf("Confidential guests can't be rebooted")
+	}
+
+	q.Logger().Info("Rebooting sandbox")
+
+	// govmm has no system_reset
+	return q.qmpExecute("system_reset", nil, nil)
+}
+
 // addDevice will add extra devices to Qemu command line.
 func (q *qemu) addDevice(devInfo interface{}, devType deviceType) error {
 	var err error
@@ -1725,6 +2100,9 @@ func (q *qemu) addDevice(devInfo interface{}, devType deviceType) error {
 				CacheSize: q.config.VirtioFSCacheSize,
 				Cache:     q.config.VirtioFSCache,
 			}
+			if q.config.VirtioFSMaxRestarts != 0 {
+				vhostDev.Reconnect = virtiofsdReconnect
+			}
 			vhostDev.SocketPath = sockPath
 			vhostDev.DevID = id
 
@@ -1759,7 +2137,7 @@ func (q *qemu) getSandboxConsole(id string) (string, error) {
 	span, _ := q.trace("getSandboxConsole")
 	defer span.Finish()
 
-	return utils.BuildSocketPath(q.store.RunVMStoragePath(), id, consoleSocket)
+	return utils.BuildSocketPath(SandboxVMPath(q.store.RunVMStoragePath(), id), consoleSocket)
 }
 
 func (q *qemu) saveSandbox() error {
@@ -1786,11 +2164,65 @@ func (q *qemu) saveSandbox() error {
 		return err
 	}
 
-	return q.waitMigration()
+	return q.waitMigration(qmpMigrationWaitTimeout)
 }
 
-func (q *qemu) waitMigration() error {
-	t := time.NewTimer(qmpMigrationWaitTimeout)
+// qmpMigrationURI converts a migration URI, see migrateSandbox(), to the
+// URI of the outgoing or incoming QEMU migration.
+func qmpMigrationURI(uri string, incoming bool) (string, error) {
+	switch {
+	case strings.HasPrefix(uri, "file://"):
+		path := strings.TrimPrefix(uri, "file://")
+		if !filepath.IsAbs(path) || strings.ContainsAny(path, " \t\n") {
+			return "", fmt.Errorf("Invalid migration file %q", path)
+		}
+		if incoming {
+			return fmt.Sprintf("%s %s", qmpExecCatCmd, path), nil
+		}
+		return fmt.Sprintf("%s>%s", qmpExecCatCmd, path), nil
+	case strings.HasPrefix(uri, "tcp:"), strings.HasPrefix(uri, "unix:"):
+		return uri, nil
+	}
+
+	return "", fmt.Errorf("Unsupported migration URI %q", uri)
+}
+
+func (q *qemu) migrateSandbox(uri string) error {
+	span, _ := q.trace("migrateSandbox")
+	defer span.Finish()
+
+	// the VM is started from its configuration on the destination host
+	if q.state.HotpluggedMemory != 0 || len(q.state.HotpluggedVCPUs) != 0 {
+		return fmt.Errorf("Sandboxes with hotplugged memory or vCPUs can't be migrated")
+	}
+
+	if q.config.ConfidentialGuest {
+		return fmt.Errorf("Confidential guests can't be migrated")
+	}
+
+	qmpURI, err := qmpMigrationURI(uri, false)
+	if err != nil {
+		return err
+	}
+
+	err = q.qmpSetup()
+	if err != nil {
+		return err
+	}
+
+	q.Logger().WithField("uri", uri).Info("Migrating sandbox")
+
+	err = q.qmpMonitorCh.qmp.ExecSetMigrateArguments(q.qmpMonitorCh.ctx, qmpURI)
+	if err != nil {
+		q.Logger().WithError(err).Error("exec migration")
+		return err
+	}
+
+	return q.waitMigration(qmpSandboxMigrationTimeout)
+}
+
+func (q *qemu) waitMigration(timeout time.Duration) error {
+	t := time.NewTimer(timeout)
 	defer t.Stop()
 	for {
 		status, err := q.qmpMonitorCh.qmp.ExecuteQueryMigration(q.qmpMonitorCh.ctx)
@@ -1805,7 +2237,7 @@ func (q *qemu) waitMigration() error {
 		select {
 		case <-t.C:
 			q.Logger().WithField("migration-status", status).Error("timeout waiting for qemu migration")
-			return fmt.Errorf("timed out after %d seconds waiting for qemu migration", qmpMigrationWaitTimeout)
+			return fmt.Errorf("timed out after %v waiting for qemu migration", timeout)
 		default:
 			// migration in progress
 			q.Logger().WithField("migration-status", status).Debug("migration in progress")
@@ -1842,6 +2274,11 @@ func (q *qemu) resizeMemory(reqMemMB uint32, memoryBlockSizeMB uint32, probe boo
 		return 0, memoryDevice{}, err
 	}
 	var addMemDevice memoryDevice
+	// the memory of TDX guests is fixed, containers share the boot memory
+	if q.arch.getProtection() == tdxProtection && currentMemory != reqMemMB {
+		q.Logger().WithField("protection", tdxProtection).Warnf("guest memory hotplug not supported, keeping %dMB", currentMemory)
+		return currentMemory, memoryDevice{}, nil
+	}
 	if q.config.VirtioMem && currentMemory != reqMemMB {
 		q.Logger().WithField("hotplug", "memory").Debugf("resize memory from %dMB to %dMB", currentMemory, reqMemMB)
 		sizeByte := (reqMemMB - q.config.MemorySize) * 1024 * 1024
@@ -2060,6 +2497,10 @@ func (q *qemu) resizeVCPUs(reqVCPUs uint32) (currentVCPUs uint32, newVCPUs uint3
 
 	currentVCPUs = q.config.NumVCPUs + uint32(len(q.state.HotpluggedVCPUs))
 	newVCPUs = currentVCPUs
+	if !q.arch.supportGuestCPUHotplug() && currentVCPUs != reqVCPUs {
+		q.Logger().WithField("protection", q.arch.getProtection()).Warnf("guest vCPU hotplug not supported, keeping %d vCPUs", currentVCPUs)
+		return currentVCPUs, newVCPUs, nil
+	}
 	switch {
 	case currentVCPUs < reqVCPUs:
 		//hotplug
@@ -2118,6 +2559,7 @@ func (q *qemu) getPids() []int {
 
 	var pids []int
 	pids = append(pids, pid)
+	q.updateVirtiofsdState()
 	if q.state.VirtiofsdPid != 0 {
 		pids = append(pids, q.state.VirtiofsdPid)
 	}
@@ -2125,11 +2567,16 @@ func (q *qemu) getPids() []int {
 	return pids
 }
 
+func (q *qemu) getVMPath() string {
+	return SandboxVMPath(q.store.RunVMStoragePath(), q.id)
+}
+
 type qemuGrpc struct {
-	ID             string
-	QmpChannelpath string
-	State          QemuState
-	NvdimmCount    int
+	ID                string
+	QmpChannelpath    string
+	QmpRawChannelpath string
+	State             QemuState
+	NvdimmCount       int
 
 	// Most members of q.qemuConfig are just to generate
 	// q.qemuConfig.qemuParams that is used by LaunchQemu except
@@ -2149,6 +2596,7 @@ func (q *qemu) fromGrpc(ctx context.Context, hypervisorConfig *HypervisorConfig,
 	q.config = *hypervisorConfig
 	q.qmpMonitorCh.ctx = ctx
 	q.qmpMonitorCh.path = qp.QmpChannelpath
+	q.qmpMonitorCh.rawPath = qp.QmpRawChannelpath
 	q.qemuConfig.Ctx = ctx
 	q.state = qp.State
 	q.arch = newQemuArch(q.config)
@@ -2166,10 +2614,11 @@ func (q *qemu) toGrpc() ([]byte, error) {
 
 	q.cleanup()
 	qp := qemuGrpc{
-		ID:             q.id,
-		QmpChannelpath: q.qmpMonitorCh.path,
-		State:          q.state,
-		NvdimmCount:    q.nvdimmCount,
+		ID:                q.id,
+		QmpChannelpath:    q.qmpMonitorCh.path,
+		QmpRawChannelpath: q.qmpMonitorCh.rawPath,
+		State:             q.state,
+		NvdimmCount:       q.nvdimmCount,
 
 		QemuSMP: q.qemuConfig.SMP,
 	}
@@ -2183,6 +2632,7 @@ func (q *qemu) save() (s persistapi.HypervisorState) {
 		s.Pid = pids[0]
 	}
 	s.VirtiofsdPid = q.state.VirtiofsdPid
+	s.VirtiofsdRestarts = q.state.VirtiofsdRestarts
 	s.Type = string(QemuHypervisor)
 	s.UUID = q.state.UUID
 	s.HotpluggedMemory = q.state.HotpluggedMemory
@@ -2211,6 +2661,7 @@ func (q *qemu) load(s persistapi.HypervisorState) {
 	q.state.HotpluggedMemory = s.HotpluggedMemory
 	q.state.HotplugVFIOOnRootBus = s.HotplugVFIOOnRootBus
 	q.state.VirtiofsdPid = s.VirtiofsdPid
+	q.state.VirtiofsdRestarts = s.VirtiofsdRestarts
 	q.state.PCIeRootPort = s.PCIeRootPort
 
 	for _, bridge := range s.Bridges {
@@ -2242,6 +2693,73 @@ func (q *qemu) check() error {
 	return nil
 }
 
+func (q *qemu) launchMeasurement() (string, error) {
+	if !q.config.ConfidentialGuest {
+		return "", fmt.Errorf("The VM is not a confidential guest, it has no launch measurement")
+	}
+
+	// the measurement of TDX guests is part of the quotes the guest gets
+	if protection := q.arch.getProtection(); protection != sevProtection {
+		return "", fmt.Errorf("Launch measurements of %s guests can't be retrieved from the host", protection)
+	}
+
+	var measurement struct {
+		Data string `json:"data"`
+	}
+	if err := q.qmpExecute("query-sev-launch-measure", nil, &measurement); err != nil {
+		return "", err
+	}
+
+	return measurement.Data, nil
+}
+
+func (q *qemu) describe() (VMInfo, error) {
+	err := q.qmpSetup()
+	if err != nil {
+		return VMInfo{}, err
+	}
+
+	status, err := q.qmpMonitorCh.qmp.ExecuteQueryStatus(q.qmpMonitorCh.ctx)
+	if err != nil {
+		return VMInfo{}, err
+	}
+
+	cpus, err := q.qmpMonitorCh.qmp.ExecQueryCpus(q.qmpMonitorCh.ctx)
+	if err != nil {
+		return VMInfo{}, err
+	}
+
+	memoryDevices, err := q.qmpMonitorCh.qmp.ExecQueryMemoryDevices(q.qmpMonitorCh.ctx)
+	if err != nil {
+		return VMInfo{}, err
+	}
+
+	// the boot memory isn't a memory device
+	memoryMB := q.config.MemorySize
+	for _, d := range memoryDevices {
+		memoryMB += uint32(d.Data.Size >> 20)
+	}
+
+	info := VMInfo{
+		State:    status.Status,
+		VCPUs:    uint32(len(cpus)),
+		MemoryMB: memoryMB,
+		Details: map[string]string{
+			"qmp_socket":     q.qmpMonitorCh.path,
+			"uuid":           q.state.UUID,
+			"memory_devices": strconv.Itoa(len(memoryDevices)),
+		},
+	}
+
+	for _, b := range q.state.Bridges {
+		for addr, id := range b.Devices {
+			info.Details[fmt.Sprintf("%s/%d", b.ID, addr)] = id
+		}
+	}
+
+	return info, nil
+}
+
 func (q *qemu) generateSocket(id string, useVsock bool) (interface{}, error) {
 	return generateVMSocket(id, useVsock, q.store.RunVMStoragePath())
 }
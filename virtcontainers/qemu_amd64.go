@@ -6,6 +6,12 @@
 package virtcontainers
 
 import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/kata-containers/runtime/virtcontainers/types"
@@ -18,6 +24,8 @@ type qemuAmd64 struct {
 	qemuArchBase
 
 	vmFactory bool
+
+	sevPolicy uint32
 }
 
 const (
@@ -28,8 +36,43 @@ const (
 	defaultQemuMachineOptions = "accel=kvm,kernel_irqchip"
 
 	qmpMigrationWaitTimeout = 5 * time.Second
+
+	// sevPolicyNoDebug is the default SEV policy, sevPolicyES being the
+	// bit asking for SEV-ES.
+	sevPolicyNoDebug = 0x1
+	sevPolicyES      = 0x4
+
+	sevGuestID = "sev0"
+	tdxGuestID = "tdx0"
+
+	// sevCPUIDLeaf is the CPUID leaf describing the AMD memory encryption,
+	// its EBX register holding the position of the C-bit in the page table
+	// entries and the number of physical address bits it takes.
+	sevCPUIDLeaf = 0x8000001f
 )
 
+// kvmParametersPath is where the parameters of the kvm_intel and kvm_amd
+// modules, telling if TDX, SEV and SEV-ES are enabled, are found.
+var kvmParametersPath = "/sys/module"
+
+// readSEVCPUID returns the EBX register of the SEV CPUID leaf, read through
+// the cpuid module. It's declared this way for mocking in unit tests.
+var readSEVCPUID = func() (uint32, error) {
+	f, err := os.Open("/dev/cpu/0/cpuid")
+	if err != nil {
+		return 0, fmt.Errorf("Could not read the CPUID of the host, is the cpuid module loaded: %v", err)
+	}
+	defer f.Close()
+
+	// the file offset selects the leaf, the read giving EAX, EBX, ECX and EDX
+	regs := make([]byte, 16)
+	if _, err := f.ReadAt(regs, sevCPUIDLeaf); err != nil {
+		return 0, fmt.Errorf("Could not read the CPUID leaf %#x: %v", sevCPUIDLeaf, err)
+	}
+
+	return binary.LittleEndian.Uint32(regs[4:8]), nil
+}
+
 var qemuPaths = map[string]string{
 	QemuPCLite: "/usr/bin/qemu-lite-system-x86_64",
 	QemuPC:     defaultQemuPath,
@@ -98,10 +141,12 @@ func newQemuArch(config HypervisorConfig) qemuArch {
 			kernelParamsNonDebug:  kernelParamsNonDebug,
 			kernelParamsDebug:     kernelParamsDebug,
 			kernelParams:          kernelParams,
-			disableNvdimm:         config.DisableImageNvdimm,
-			dax:                   true,
+			// the guest image would be read through the encrypted memory
+			disableNvdimm: config.DisableImageNvdimm || config.ConfidentialGuest,
+			dax:           true,
 		},
 		vmFactory: factory,
+		sevPolicy: config.SEVPolicy,
 	}
 
 	q.handleImagePath(config)
@@ -120,6 +165,13 @@ func (q *qemuAmd64) capabilities() types.Capabilities {
 
 	caps.SetMultiQueueSupport()
 	caps.SetFsSharingSupport()
+	caps.SetVirtioFSSupport()
+	caps.SetVirtio9PSupport()
+	caps.SetMemoryHotplugSupport()
+	caps.SetCPUHotplugSupport()
+	caps.SetVFIOHotplugSupport()
+	caps.SetVMSnapshotSupport()
+	caps.SetVMPauseSupport()
 
 	return caps
 }
@@ -159,3 +211,156 @@ func (q *qemuAmd64) appendImage(devices []govmmQemu.Device, path string) ([]govm
 func (q *qemuAmd64) appendBridges(devices []govmmQemu.Device) []govmmQemu.Device {
 	return genericAppendBridges(devices, q.Bridges, q.machineType)
 }
+
+func kvmParameterEnabled(module, name string) bool {
+	value, err := ioutil.ReadFile(filepath.Join(kvmParametersPath, module, "parameters", name))
+	if err != nil {
+		return false
+	}
+
+	v := strings.TrimSpace(string(value))
+	return v == "1" || v == "Y"
+}
+
+func (q *qemuAmd64) availableGuestProtection() guestProtection {
+	if kvmParameterEnabled("kvm_intel", "tdx") {
+		return tdxProtection
+	}
+
+	if kvmParameterEnabled("kvm_amd", "sev") {
+		return sevProtection
+	}
+
+	return noneProtection
+}
+
+func (q *qemuAmd64) enableProtection() error {
+	protection := q.availableGuestProtection()
+
+	switch protection {
+	case tdxProtection:
+		if q.machineType != QemuQ35 {
+			return fmt.Errorf("Intel TDX guests require the %s machine type, not %s", QemuQ35, q.machineType)
+		}
+
+		if q.sevPolicy != 0 {
+			return fmt.Errorf("The SEV policy %#x can't be used by Intel TDX guests", q.sevPolicy)
+		}
+
+	case sevProtection:
+		if q.sevPolicy&sevPolicyES != 0 && !kvmParameterEnabled("kvm_amd", "sev_es") {
+			return fmt.Errorf("AMD SEV-ES is not enabled on the host, the SEV policy %#x can't be used", q.sevPolicy)
+		}
+
+	default:
+		return fmt.Errorf("Neither Intel TDX nor AMD SEV is enabled on the host, confidential guests can't be created")
+	}
+
+	q.protection = protection
+
+	virtLog.WithField("subsystem", "qemuAmd64").WithField("protection", protection).Info("Confidential guest enabled")
+
+	return nil
+}
+
+// supportGuestMemoryHotplug returns false for TDX guests, the hotplugged
+// memory would not be part of the trust domain.
+func (q *qemuAmd64) supportGuestMemoryHotplug() bool {
+	return q.protection != tdxProtection
+}
+
+// supportGuestCPUHotplug returns false for TDX guests, the vCPUs of a trust
+// domain being fixed once it's built.
+func (q *qemuAmd64) supportGuestCPUHotplug() bool {
+	return q.protection != tdxProtection
+}
+
+func (q *qemuAmd64) cpuTopology(vcpus, maxvcpus uint32) govmmQemu.SMP {
+	if q.protection == tdxProtection {
+		maxvcpus = vcpus
+	}
+
+	return q.qemuArchBase.cpuTopology(vcpus, maxvcpus)
+}
+
+func (q *qemuAmd64) machine() (govmmQemu.Machine, error) {
+	machine, err := q.qemuArchBase.machine()
+	if err != nil {
+		return machine, err
+	}
+
+	switch q.protection {
+	case sevProtection:
+		machine.Options += ",memory-encryption=" + sevGuestID
+	case tdxProtection:
+		machine.Options += ",kvm-type=tdx,confidential-guest-support=" + tdxGuestID
+	}
+
+	return machine, nil
+}
+
+func (q *qemuAmd64) appendProtectionDevice(devices []govmmQemu.Device) ([]govmmQemu.Device, error) {
+	switch q.protection {
+	case sevProtection:
+		sevGuest, err := q.sevGuestObject()
+		if err != nil {
+			return nil, err
+		}
+		return append(devices, sevGuest), nil
+	case tdxProtection:
+		return append(devices, tdxGuestObject{id: tdxGuestID}), nil
+	}
+
+	return q.qemuArchBase.appendProtectionDevice(devices)
+}
+
+func (q *qemuAmd64) sevGuestObject() (sevGuestObject, error) {
+	ebx, err := readSEVCPUID()
+	if err != nil {
+		return sevGuestObject{}, err
+	}
+
+	policy := q.sevPolicy
+	if policy == 0 {
+		policy = sevPolicyNoDebug
+	}
+
+	return sevGuestObject{
+		id:              sevGuestID,
+		cbitPos:         ebx & 0x3f,
+		reducedPhysBits: (ebx >> 6) & 0x3f,
+		policy:          policy,
+	}, nil
+}
+
+// sevGuestObject is the sev-guest object the memory encryption of the
+// machine refers to.
+type sevGuestObject struct {
+	id              string
+	cbitPos         uint32
+	reducedPhysBits uint32
+	policy          uint32
+}
+
+func (o sevGuestObject) Valid() bool {
+	return o.id != "" && o.cbitPos != 0
+}
+
+func (o sevGuestObject) QemuParams(config *govmmQemu.Config) []string {
+	return []string{"-object", fmt.Sprintf("sev-guest,id=%s,cbitpos=%d,reduced-phys-bits=%d,policy=%#x",
+		o.id, o.cbitPos, o.reducedPhysBits, o.policy)}
+}
+
+// tdxGuestObject is the tdx-guest object the machine refers to as its
+// confidential guest support.
+type tdxGuestObject struct {
+	id string
+}
+
+func (o tdxGuestObject) Valid() bool {
+	return o.id != ""
+}
+
+func (o tdxGuestObject) QemuParams(config *govmmQemu.Config) []string {
+	return []string{"-object", fmt.Sprintf("tdx-guest,id=%s", o.id)}
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	govmmQemu "github.com/intel/govmm/qemu"
@@ -239,3 +240,116 @@ func TestQemuAmd64WithInitrd(t *testing.T) {
 		assert.NotContains(m.Options, qemuNvdimmOption)
 	}
 }
+
+func writeKvmParameter(t *testing.T, dir, module, name, value string) {
+	dir = filepath.Join(dir, module, "parameters")
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(value+"\n"), 0644))
+}
+
+func TestQemuAmd64Protection(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	savedKvmParametersPath := kvmParametersPath
+	savedReadSEVCPUID := readSEVCPUID
+	kvmParametersPath = tmpdir
+	readSEVCPUID = func() (uint32, error) {
+		// C-bit 47, 1 physical address bit
+		return 1<<6 | 47, nil
+	}
+	defer func() {
+		kvmParametersPath = savedKvmParametersPath
+		readSEVCPUID = savedReadSEVCPUID
+	}()
+
+	config := qemuConfig(QemuQ35)
+	config.ConfidentialGuest = true
+	amd64 := newQemuArch(config)
+	assert.True(amd64.(*qemuAmd64).disableNvdimm)
+
+	// no protection without SEV
+	devices, err := amd64.appendProtectionDevice(nil)
+	assert.NoError(err)
+	assert.Empty(devices)
+	assert.Error(amd64.enableProtection())
+
+	writeKvmParameter(t, tmpdir, "kvm_amd", "sev", "1")
+	assert.NoError(amd64.enableProtection())
+
+	machine, err := amd64.machine()
+	assert.NoError(err)
+	assert.Equal(defaultQemuMachineOptions+",memory-encryption=sev0", machine.Options)
+
+	devices, err = amd64.appendProtectionDevice(nil)
+	assert.NoError(err)
+	assert.Len(devices, 1)
+	assert.True(devices[0].Valid())
+	assert.Equal([]string{"-object", "sev-guest,id=sev0,cbitpos=47,reduced-phys-bits=1,policy=0x1"},
+		devices[0].QemuParams(nil))
+
+	// SEV-ES must be enabled on the host
+	config.SEVPolicy = sevPolicyNoDebug | sevPolicyES
+	amd64 = newQemuArch(config)
+	assert.Error(amd64.enableProtection())
+
+	writeKvmParameter(t, tmpdir, "kvm_amd", "sev_es", "Y")
+	assert.NoError(amd64.enableProtection())
+
+	devices, err = amd64.appendProtectionDevice(nil)
+	assert.NoError(err)
+	assert.Equal([]string{"-object", "sev-guest,id=sev0,cbitpos=47,reduced-phys-bits=1,policy=0x5"},
+		devices[0].QemuParams(nil))
+}
+
+func TestQemuAmd64TDXProtection(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	savedKvmParametersPath := kvmParametersPath
+	kvmParametersPath = tmpdir
+	defer func() {
+		kvmParametersPath = savedKvmParametersPath
+	}()
+
+	writeKvmParameter(t, tmpdir, "kvm_intel", "tdx", "Y")
+
+	config := qemuConfig(QemuPC)
+	config.ConfidentialGuest = true
+	amd64 := newQemuArch(config)
+	assert.Equal(tdxProtection, amd64.availableGuestProtection())
+
+	// TDX guests require q35 and have no SEV policy
+	assert.Error(amd64.enableProtection())
+
+	config.HypervisorMachineType = QemuQ35
+	config.SEVPolicy = sevPolicyNoDebug
+	amd64 = newQemuArch(config)
+	assert.Error(amd64.enableProtection())
+
+	config.SEVPolicy = 0
+	amd64 = newQemuArch(config)
+	assert.True(amd64.supportGuestCPUHotplug())
+	assert.NoError(amd64.enableProtection())
+	assert.Equal(tdxProtection, amd64.getProtection())
+	assert.False(amd64.supportGuestCPUHotplug())
+	assert.False(amd64.supportGuestMemoryHotplug())
+
+	machine, err := amd64.machine()
+	assert.NoError(err)
+	assert.Equal(defaultQemuMachineOptions+",kvm-type=tdx,confidential-guest-support=tdx0", machine.Options)
+
+	smp := amd64.cpuTopology(2, 8)
+	assert.Equal(uint32(2), smp.CPUs)
+	assert.Equal(uint32(2), smp.MaxCPUs)
+
+	devices, err := amd64.appendProtectionDevice(nil)
+	assert.NoError(err)
+	assert.Equal([]string{"-object", "tdx-guest,id=tdx0"}, devices[0].QemuParams(nil))
+}
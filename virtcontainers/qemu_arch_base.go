@@ -125,11 +125,60 @@ type qemuArch interface {
 	// supportGuestMemoryHotplug returns if the guest supports memory hotplug
 	supportGuestMemoryHotplug() bool
 
+	// supportGuestCPUHotplug returns if the guest supports vCPU hotplug
+	supportGuestCPUHotplug() bool
+
 	// setIgnoreSharedMemoryMigrationCaps set bypass-shared-memory capability for migration
 	setIgnoreSharedMemoryMigrationCaps(context.Context, *govmmQemu.QMP) error
 
 	// appendPCIeRootPortDevice appends a pcie-root-port device to pcie.0 bus
 	appendPCIeRootPortDevice(devices []govmmQemu.Device, number uint32) []govmmQemu.Device
+
+	// availableGuestProtection returns the memory protection of
+	// confidential guests the host provides
+	availableGuestProtection() guestProtection
+
+	// enableProtection enables the memory protection of confidential guests
+	enableProtection() error
+
+	// getProtection returns the enabled memory protection
+	getProtection() guestProtection
+
+	// appendProtectionDevice appends the object configuring the memory
+	// protection of confidential guests
+	appendProtectionDevice(devices []govmmQemu.Device) ([]govmmQemu.Device, error)
+}
+
+// guestProtection is the memory protection of confidential guests.
+type guestProtection int
+
+const (
+	noneProtection guestProtection = iota
+
+	// sevProtection is AMD Secure Encrypted Virtualization
+	sevProtection
+
+	// tdxProtection is Intel Trust Domain Extensions
+	tdxProtection
+)
+
+func (p guestProtection) String() string {
+	switch p {
+	case noneProtection:
+		return "none"
+	case sevProtection:
+		return "sev"
+	case tdxProtection:
+		return "tdx"
+	}
+
+	return fmt.Sprintf("unknown(%d)", int(p))
+}
+
+// AvailableGuestProtection returns the memory protection of the confidential
+// guests qemu can create on the host, "none" when there is none.
+func AvailableGuestProtection() string {
+	return newQemuArch(HypervisorConfig{}).availableGuestProtection().String()
 }
 
 type qemuArchBase struct {
@@ -146,6 +195,7 @@ type qemuArchBase struct {
 	kernelParamsDebug     []Param
 	kernelParams          []Param
 	Bridges               []types.Bridge
+	protection            guestProtection
 }
 
 const (
@@ -256,15 +306,11 @@ func (q *qemuArchBase) qemuPath() (string, error) {
 }
 
 func (q *qemuArchBase) kernelParameters(debug bool) []Param {
-	params := q.kernelParams
-
 	if debug {
-		params = append(params, q.kernelParamsDebug...)
-	} else {
-		params = append(params, q.kernelParamsNonDebug...)
+		return mergeKernelParams(q.kernelParams, q.kernelParamsDebug)
 	}
 
-	return params
+	return mergeKernelParams(q.kernelParams, q.kernelParamsNonDebug)
 }
 
 func (q *qemuArchBase) capabilities() types.Capabilities {
@@ -272,6 +318,13 @@ func (q *qemuArchBase) capabilities() types.Capabilities {
 	caps.SetBlockDeviceHotplugSupport()
 	caps.SetMultiQueueSupport()
 	caps.SetFsSharingSupport()
+	caps.SetVirtioFSSupport()
+	caps.SetVirtio9PSupport()
+	caps.SetMemoryHotplugSupport()
+	caps.SetCPUHotplugSupport()
+	caps.SetVFIOHotplugSupport()
+	caps.SetVMSnapshotSupport()
+	caps.SetVMPauseSupport()
 	return caps
 }
 
@@ -577,13 +630,59 @@ func genericNetwork(endpoint Endpoint, vhost, nestedRun bool, index int) (govmmQ
 	return d, nil
 }
 
+// netDevice is a virtio-net device with some of its features turned off,
+// which govmm's NetDevice can't express.
+type netDevice struct {
+	govmmQemu.NetDevice
+
+	// disabledFeatures is the list of virtio-net features turned off on
+	// the device, e.g. guest_tso4.
+	disabledFeatures []string
+}
+
+// QemuParams returns the qemu parameters of the govmm device, with the
+// disabled features appended to those of -device.
+func (netdev netDevice) QemuParams(config *govmmQemu.Config) []string {
+	qemuParams := netdev.NetDevice.QemuParams(config)
+
+	for i := 1; i < len(qemuParams); i++ {
+		if qemuParams[i-1] != "-device" {
+			continue
+		}
+
+		for _, feature := range netdev.disabledFeatures {
+			qemuParams[i] += fmt.Sprintf(",%s=off", feature)
+		}
+	}
+
+	return qemuParams
+}
+
+// networkDevice returns the device to add to the VM for the network device
+// of the endpoint, with the features of its disabled offloads turned off.
+func networkDevice(d govmmQemu.NetDevice, endpoint Endpoint) govmmQemu.Device {
+	var disabled []string
+
+	switch ep := endpoint.(type) {
+	case *VethEndpoint, *BridgedMacvlanEndpoint, *IPVlanEndpoint, *TuntapEndpoint:
+		disabled = ep.NetworkPair().DisabledOffloads
+	}
+
+	features := netOffloadFeatures(disabled)
+	if len(features) == 0 {
+		return d
+	}
+
+	return netDevice{NetDevice: d, disabledFeatures: features}
+}
+
 func (q *qemuArchBase) appendNetwork(devices []govmmQemu.Device, endpoint Endpoint) ([]govmmQemu.Device, error) {
 	d, err := genericNetwork(endpoint, q.vhost, q.nestedRun, q.networkIndex)
 	if err != nil {
 		return devices, fmt.Errorf("Failed to append network %v", err)
 	}
 	q.networkIndex++
-	devices = append(devices, d)
+	devices = append(devices, networkDevice(d, endpoint))
 	return devices, nil
 }
 
@@ -618,6 +717,30 @@ func (q *qemuArchBase) appendBlockDevice(devices []govmmQemu.Device, drive confi
 	return devices, nil
 }
 
+// vhostUserDevice is a vhost-user device whose chardev reconnects to the
+// daemon, which govmm's VhostUserDevice can't express.
+type vhostUserDevice struct {
+	govmmQemu.VhostUserDevice
+
+	// reconnect is the delay in seconds between the attempts to
+	// reconnect to the daemon.
+	reconnect uint32
+}
+
+// QemuParams returns the qemu parameters of the govmm device, with the
+// reconnect delay appended to those of -chardev.
+func (vhostuserDev vhostUserDevice) QemuParams(config *govmmQemu.Config) []string {
+	qemuParams := vhostuserDev.VhostUserDevice.QemuParams(config)
+
+	for i := 1; i < len(qemuParams); i++ {
+		if qemuParams[i-1] == "-chardev" {
+			qemuParams[i] += fmt.Sprintf(",reconnect=%d", vhostuserDev.reconnect)
+		}
+	}
+
+	return qemuParams
+}
+
 func (q *qemuArchBase) appendVhostUserDevice(devices []govmmQemu.Device, attr config.VhostUserDeviceAttrs) ([]govmmQemu.Device, error) {
 	qemuVhostUserDevice := govmmQemu.VhostUserDevice{}
 
@@ -641,6 +764,11 @@ func (q *qemuArchBase) appendVhostUserDevice(devices []govmmQemu.Device, attr co
 	qemuVhostUserDevice.SocketPath = attr.SocketPath
 	qemuVhostUserDevice.CharDevID = utils.MakeNameID("char", attr.DevID, maxDevIDSize)
 
+	if attr.Reconnect != 0 {
+		devices = append(devices, vhostUserDevice{VhostUserDevice: qemuVhostUserDevice, reconnect: attr.Reconnect})
+		return devices, nil
+	}
+
 	devices = append(devices, qemuVhostUserDevice)
 
 	return devices, nil
@@ -700,6 +828,10 @@ func (q *qemuArchBase) supportGuestMemoryHotplug() bool {
 	return true
 }
 
+func (q *qemuArchBase) supportGuestCPUHotplug() bool {
+	return true
+}
+
 func (q *qemuArchBase) setIgnoreSharedMemoryMigrationCaps(ctx context.Context, qmp *govmmQemu.QMP) error {
 	err := qmp.ExecSetMigrationCaps(ctx, []map[string]interface{}{
 		{
@@ -766,3 +898,23 @@ func (q *qemuArchBase) addBridge(b types.Bridge) {
 func (q *qemuArchBase) appendPCIeRootPortDevice(devices []govmmQemu.Device, number uint32) []govmmQemu.Device {
 	return genericAppendPCIeRootPort(devices, number, q.machineType)
 }
+
+func (q *qemuArchBase) availableGuestProtection() guestProtection {
+	return noneProtection
+}
+
+func (q *qemuArchBase) getProtection() guestProtection {
+	return q.protection
+}
+
+func (q *qemuArchBase) enableProtection() error {
+	return fmt.Errorf("Confidential guests are not supported on this architecture")
+}
+
+func (q *qemuArchBase) appendProtectionDevice(devices []govmmQemu.Device) ([]govmmQemu.Device, error) {
+	if q.protection != noneProtection {
+		return nil, fmt.Errorf("Unsupported guest protection %s", q.protection)
+	}
+
+	return devices, nil
+}
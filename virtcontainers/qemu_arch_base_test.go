@@ -441,6 +441,28 @@ func TestQemuArchBaseAppendVhostUserDevice(t *testing.T) {
 	testQemuArchBaseAppend(t, vhostUserDevice, expectedOut)
 }
 
+func TestVhostUserDeviceQemuParams(t *testing.T) {
+	assert := assert.New(t)
+
+	d := vhostUserDevice{
+		VhostUserDevice: govmmQemu.VhostUserDevice{
+			SocketPath:    "nonexistentpath.sock",
+			CharDevID:     "char-deadbeef",
+			TypeDevID:     "fs-deadbeef",
+			Tag:           "kataShared",
+			VhostUserType: govmmQemu.VhostUserFS,
+		},
+		reconnect: 1,
+	}
+
+	params := d.QemuParams(&govmmQemu.Config{})
+	expected := d.VhostUserDevice.QemuParams(&govmmQemu.Config{})
+	assert.Equal(len(expected), len(params))
+	assert.Equal("-chardev", params[0])
+	assert.Equal(expected[1]+",reconnect=1", params[1])
+	assert.Equal(expected[2:], params[2:])
+}
+
 func TestQemuArchBaseAppendVFIODevice(t *testing.T) {
 	bdf := "02:10.1"
 
@@ -516,6 +538,7 @@ func TestQemuArchBaseAppendNetwork(t *testing.T) {
 				TAPIface: NetworkInterface{
 					Name: "tap4_kata",
 				},
+				DisabledOffloads: []string{"ufo"},
 			},
 			VirtIface: NetworkInterface{
 				Name:     "eth4",
@@ -536,16 +559,19 @@ func TestQemuArchBaseAppendNetwork(t *testing.T) {
 	}
 
 	expectedOut := []govmmQemu.Device{
-		govmmQemu.NetDevice{
-			Type:       networkModelToQemuType(macvlanEp.NetPair.NetInterworkingModel),
-			Driver:     govmmQemu.VirtioNet,
-			ID:         fmt.Sprintf("network-%d", 0),
-			IFName:     macvlanEp.NetPair.TAPIface.Name,
-			MACAddress: macvlanEp.NetPair.TAPIface.HardAddr,
-			DownScript: "no",
-			Script:     "no",
-			FDs:        macvlanEp.NetPair.VMFds,
-			VhostFDs:   macvlanEp.NetPair.VhostFds,
+		netDevice{
+			NetDevice: govmmQemu.NetDevice{
+				Type:       networkModelToQemuType(macvlanEp.NetPair.NetInterworkingModel),
+				Driver:     govmmQemu.VirtioNet,
+				ID:         fmt.Sprintf("network-%d", 0),
+				IFName:     macvlanEp.NetPair.TAPIface.Name,
+				MACAddress: macvlanEp.NetPair.TAPIface.HardAddr,
+				DownScript: "no",
+				Script:     "no",
+				FDs:        macvlanEp.NetPair.VMFds,
+				VhostFDs:   macvlanEp.NetPair.VhostFds,
+			},
+			disabledFeatures: []string{"host_ufo", "guest_ufo"},
 		},
 		govmmQemu.NetDevice{
 			Type:       govmmQemu.MACVTAP,
@@ -566,3 +592,27 @@ func TestQemuArchBaseAppendNetwork(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(expectedOut, devices)
 }
+
+func TestNetDeviceQemuParams(t *testing.T) {
+	assert := assert.New(t)
+
+	d := netDevice{
+		NetDevice: govmmQemu.NetDevice{
+			Type:       govmmQemu.TAP,
+			Driver:     govmmQemu.VirtioNet,
+			ID:         "network-0",
+			IFName:     "tap0_kata",
+			MACAddress: "02:00:ca:fe:00:00",
+			DownScript: "no",
+			Script:     "no",
+		},
+		disabledFeatures: []string{"host_ufo", "guest_ufo"},
+	}
+
+	params := d.QemuParams(&govmmQemu.Config{})
+	expected := d.NetDevice.QemuParams(&govmmQemu.Config{})
+	assert.Len(params, 4)
+	assert.Equal(expected[1], params[1])
+	assert.Equal("-device", params[2])
+	assert.Equal(expected[3]+",host_ufo=off,guest_ufo=off", params[3])
+}
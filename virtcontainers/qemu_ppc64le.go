@@ -95,6 +95,10 @@ func (q *qemuPPC64le) capabilities() types.Capabilities {
 	}
 
 	caps.SetMultiQueueSupport()
+	caps.SetMemoryHotplugSupport()
+	caps.SetCPUHotplugSupport()
+	caps.SetVFIOHotplugSupport()
+	caps.SetVMPauseSupport()
 
 	return caps
 }
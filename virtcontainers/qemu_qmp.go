@@ -0,0 +1,110 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// qmpRawSocket is the second QMP socket of the QEMU VMs, through which the
+// runtime executes the commands govmm doesn't provide, see qmpExecute().
+const qmpRawSocket = "qmp-raw.sock"
+
+// qmpMessage is a message of a QEMU QMP server: its greeting, the answer to
+// a command or an asynchronous event.
+type qmpMessage struct {
+	QMP    json.RawMessage `json:"QMP"`
+	Return json.RawMessage `json:"return"`
+	Error  *struct {
+		Class string `json:"class"`
+		Desc  string `json:"desc"`
+	} `json:"error"`
+	Event string `json:"event"`
+}
+
+// qmpExecute connects to the QMP socket at path and executes command, with
+// args if not nil, decoding what it returns into result if not nil. The
+// connection is closed afterwards, the commands executed this way aren't
+// frequent enough to keep it open.
+func qmpExecute(ctx context.Context, path, command string, args map[string]interface{}, result interface{}) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", path)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	dec := json.NewDecoder(conn)
+	enc := json.NewEncoder(conn)
+
+	var greeting qmpMessage
+	if err := dec.Decode(&greeting); err != nil {
+		return fmt.Errorf("Could not read the QMP greeting: %v", err)
+	}
+	if greeting.QMP == nil {
+		return fmt.Errorf("Unexpected QMP greeting")
+	}
+
+	if _, err := qmpCommand(dec, enc, "qmp_capabilities", nil); err != nil {
+		return err
+	}
+
+	ret, err := qmpCommand(dec, enc, command, args)
+	if err != nil {
+		return err
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.Unmarshal(ret, result)
+}
+
+// qmpCommand sends command to a QMP server and returns what it returned,
+// skipping the events received meanwhile.
+func qmpCommand(dec *json.Decoder, enc *json.Encoder, command string, args map[string]interface{}) (json.RawMessage, error) {
+	cmd := map[string]interface{}{
+		"execute": command,
+	}
+	if args != nil {
+		cmd["arguments"] = args
+	}
+
+	if err := enc.Encode(cmd); err != nil {
+		return nil, err
+	}
+
+	for {
+		var msg qmpMessage
+		if err := dec.Decode(&msg); err != nil {
+			return nil, fmt.Errorf("Could not read the answer to QMP command %s: %v", command, err)
+		}
+
+		switch {
+		case msg.Error != nil:
+			return nil, fmt.Errorf("QMP command %s failed: %s: %s", command, msg.Error.Class, msg.Error.Desc)
+		case msg.Return != nil:
+			return msg.Return, nil
+		}
+	}
+}
+
+// qmpExecute executes a QMP command govmm doesn't provide on the VM.
+func (q *qemu) qmpExecute(command string, args map[string]interface{}, result interface{}) error {
+	ctx, cancel := context.WithTimeout(q.qmpMonitorCh.ctx, time.Duration(q.config.vmmAPITimeout())*time.Second)
+	defer cancel()
+
+	return qmpExecute(ctx, q.qmpMonitorCh.rawPath, command, args, result)
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeQMPServer answers the QMP commands it receives on its socket with
+// answers, keyed by command, after sending an event. The connections are
+// served one after the other.
+func fakeQMPServer(t *testing.T, path string, answers map[string]string) (map[string]interface{}, func()) {
+	l, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+
+	received := make(map[string]interface{})
+	done := make(chan struct{})
+
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+
+		fmt.Fprintln(conn, `{"QMP": {"version": {"qemu": {"major": 5, "minor": 0, "micro": 0}}, "capabilities": []}}`)
+
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var cmd struct {
+				Execute   string                 `json:"execute"`
+				Arguments map[string]interface{} `json:"arguments"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &cmd); err != nil {
+				return
+			}
+			received[cmd.Execute] = cmd.Arguments
+
+			fmt.Fprintln(conn, `{"event": "RESUME", "timestamp": {"seconds": 0, "microseconds": 0}}`)
+			answer, ok := answers[cmd.Execute]
+			if !ok {
+				answer = `{"return": {}}`
+			}
+			fmt.Fprintln(conn, answer)
+		}
+	}
+
+	go func() {
+		defer close(done)
+
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			serve(conn)
+		}
+	}()
+
+	return received, func() {
+		l.Close()
+		<-done
+	}
+}
+
+func TestQMPExecute(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "qmp")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, qmpRawSocket)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	received, wait := fakeQMPServer(t, path, map[string]string{
+		"query-name": `{"return": {"name": "sandbox"}}`,
+	})
+	var result struct {
+		Name string `json:"name"`
+	}
+	err = qmpExecute(ctx, path, "query-name", nil, &result)
+	wait()
+	assert.NoError(err)
+	assert.Equal("sandbox", result.Name)
+	assert.Contains(received, "qmp_capabilities")
+
+	received, wait = fakeQMPServer(t, path, nil)
+	err = qmpExecute(ctx, path, "blockdev-add", map[string]interface{}{"read-only": true}, nil)
+	wait()
+	assert.NoError(err)
+	assert.Equal(map[string]interface{}{"read-only": true}, received["blockdev-add"])
+
+	_, wait = fakeQMPServer(t, path, map[string]string{
+		"blockdev-add": `{"error": {"class": "GenericError", "desc": "Could not open '/dev/sdb'"}}`,
+	})
+	err = qmpExecute(ctx, path, "blockdev-add", nil, nil)
+	wait()
+	assert.Error(err)
+
+	// no server
+	assert.Error(qmpExecute(ctx, filepath.Join(dir, "missing"), "query-name", nil, nil))
+}
+
+func TestQemuRebootSandbox(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "qmp")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	q := &qemu{
+		qmpMonitorCh: qmpChannel{
+			ctx:     context.Background(),
+			rawPath: filepath.Join(dir, qmpRawSocket),
+		},
+	}
+
+	received, wait := fakeQMPServer(t, q.qmpMonitorCh.rawPath, nil)
+	err = q.rebootSandbox()
+	wait()
+	assert.NoError(err)
+	assert.Contains(received, "system_reset")
+
+	q.config.ConfidentialGuest = true
+	assert.Error(q.rebootSandbox())
+}
+
+func TestQemuHotplugSCSIDriveWWN(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "qmp")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	q := &qemu{
+		config: HypervisorConfig{
+			BlockDeviceDriver: config.VirtioSCSI,
+		},
+		qmpMonitorCh: qmpChannel{
+			ctx:     context.Background(),
+			rawPath: filepath.Join(dir, qmpRawSocket),
+		},
+	}
+
+	// read-only drives are added through the raw socket too
+	drive := &config.BlockDrive{
+		File:     "/dev/sdb",
+		ID:       "drive-sdb",
+		Index:    1,
+		ReadOnly: true,
+	}
+
+	received, wait := fakeQMPServer(t, q.qmpMonitorCh.rawPath, nil)
+	err = q.hotplugAddBlockDevice(drive, addDevice, "scsi-drive-sdb")
+	wait()
+	assert.NoError(err)
+
+	assert.Contains(received, "device_add")
+	args := received["device_add"].(map[string]interface{})
+	assert.Equal("scsi-hd", args["driver"])
+	assert.Equal(scsiControllerID+".0", args["bus"])
+	assert.Equal(float64(config.DriveWWN(drive.ID)), args["wwn"])
+	assert.NotContains(args, "serial")
+}
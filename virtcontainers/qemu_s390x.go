@@ -181,7 +181,7 @@ func (q *qemuS390x) appendNetwork(devices []govmmQemu.Device, endpoint Endpoint)
 		return devices, fmt.Errorf("Failed to append network %v", err)
 	}
 
-	devices = append(devices, d)
+	devices = append(devices, networkDevice(d, endpoint))
 	return devices, nil
 }
 
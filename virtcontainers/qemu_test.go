@@ -73,6 +73,19 @@ func TestQemuKernelParameters(t *testing.T) {
 	testQemuKernelParameters(t, params, expectedOut, false)
 }
 
+func TestQemuKernelParametersRepeated(t *testing.T) {
+	expectedOut := fmt.Sprintf("nr_cpus=%d agent.use_vsock=false hugepagesz=1G hugepages=2 hugepagesz=2M hugepages=512 panic=0", MaxQemuVCPUs())
+	params := []Param{
+		{"hugepagesz", "1G"},
+		{"hugepages", "2"},
+		{"hugepagesz", "2M"},
+		{"hugepages", "512"},
+		{"panic", "0"},
+	}
+
+	testQemuKernelParameters(t, params, expectedOut, false)
+}
+
 func TestQemuCreateSandbox(t *testing.T) {
 	qemuConfig := newQemuConfig()
 	assert := assert.New(t)
@@ -155,10 +168,71 @@ func TestQemuCPUTopology(t *testing.T) {
 		MaxCPUs: uint32(vcpus),
 	}
 
-	smp := q.cpuTopology()
+	smp, err := q.cpuTopology()
+	assert.NoError(err)
 	assert.Exactly(smp, expectedOut)
 }
 
+func TestQemuCPUTopologyGuest(t *testing.T) {
+	assert := assert.New(t)
+
+	q := &qemu{
+		arch: &qemuArchBase{},
+		config: HypervisorConfig{
+			NumVCPUs:        2,
+			DefaultMaxVCPUs: 8,
+			CPUSockets:      2,
+			CPUCores:        2,
+			CPUThreads:      2,
+		},
+	}
+
+	smp, err := q.cpuTopology()
+	assert.NoError(err)
+	assert.Exactly(govmmQemu.SMP{
+		CPUs:    2,
+		Sockets: 2,
+		Cores:   2,
+		Threads: 2,
+		MaxCPUs: 8,
+	}, smp)
+
+	q.config.DefaultMaxVCPUs = 4
+	_, err = q.cpuTopology()
+	assert.Error(err)
+}
+
+func TestQemuCPUTopologyGuestSMT(t *testing.T) {
+	assert := assert.New(t)
+
+	cleanup := mockHostSMT(t, 2)
+	defer cleanup()
+
+	q := &qemu{
+		arch: &qemuArchBase{},
+		config: HypervisorConfig{
+			NumVCPUs:        3,
+			DefaultMaxVCPUs: 7,
+			EnableGuestSMT:  true,
+		},
+	}
+
+	// the maximum vCPUs are rounded down to whole cores
+	smp, err := q.cpuTopology()
+	assert.NoError(err)
+	assert.Exactly(govmmQemu.SMP{
+		CPUs:    3,
+		Sockets: 3,
+		Cores:   defaultCores,
+		Threads: 2,
+		MaxCPUs: 6,
+	}, smp)
+
+	q.config.NumVCPUs = 7
+	_, err = q.cpuTopology()
+	assert.Error(err)
+}
+
 func TestQemuMemoryTopology(t *testing.T) {
 	mem := uint32(1000)
 	slots := uint32(8)
@@ -312,7 +386,7 @@ func TestQemuGetSandboxConsole(t *testing.T) {
 		store: store,
 	}
 	sandboxID := "testSandboxID"
-	expected := filepath.Join(q.store.RunVMStoragePath(), sandboxID, consoleSocket)
+	expected := filepath.Join(sandboxTmpPath(sandboxID), "vm", consoleSocket)
 
 	result, err := q.getSandboxConsole(sandboxID)
 	assert.NoError(err)
@@ -428,6 +502,9 @@ func TestQemuGrpc(t *testing.T) {
 	q := &qemu{
 		id:     "testqemu",
 		config: config,
+		qmpMonitorCh: qmpChannel{
+			rawPath: "/run/vc/vm/testqemu/qmp-raw.sock",
+		},
 	}
 
 	json, err := q.toGrpc()
@@ -438,6 +515,7 @@ func TestQemuGrpc(t *testing.T) {
 	assert.Nil(err)
 
 	assert.True(q.id == q2.id)
+	assert.Equal(q.qmpMonitorCh.rawPath, q2.qmpMonitorCh.rawPath)
 }
 
 func TestQemuFileBackedMem(t *testing.T) {
@@ -563,6 +641,11 @@ func TestQemuVirtiofsdArgs(t *testing.T) {
 	result = "--fd=123 -o source=test-share-dir/foo -o cache=none --syslog -o no_posix_lock -f"
 	args = q.virtiofsdArgs(123)
 	assert.Equal(strings.Join(args, " "), result)
+
+	q.config.VirtioFSThreadPoolSize = 16
+	result = "--fd=123 -o source=test-share-dir/foo -o cache=none --syslog -o no_posix_lock -f --thread-pool-size=16"
+	args = q.virtiofsdArgs(123)
+	assert.Equal(strings.Join(args, " "), result)
 }
 
 func TestQemuGetpids(t *testing.T) {
@@ -601,3 +684,120 @@ func TestQemuGetpids(t *testing.T) {
 	assert.True(pids[0] == 100)
 	assert.True(pids[1] == 200)
 }
+
+func TestQMPMigrationURI(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, d := range []struct {
+		uri      string
+		incoming bool
+		expected string
+	}{
+		{"file:///run/sandbox.state", false, "exec:cat>/run/sandbox.state"},
+		{"file:///run/sandbox.state", true, "exec:cat /run/sandbox.state"},
+		{"tcp:192.168.0.2:4444", false, "tcp:192.168.0.2:4444"},
+		{"tcp:0:4444", true, "tcp:0:4444"},
+		{"unix:/run/migration.sock", false, "unix:/run/migration.sock"},
+	} {
+		uri, err := qmpMigrationURI(d.uri, d.incoming)
+		assert.NoError(err)
+		assert.Equal(d.expected, uri)
+	}
+
+	for _, uri := range []string{"", "file://sandbox.state", "file:///run/sandbox state", "exec:cat>/tmp/foo", "rdma:host:4444"} {
+		_, err := qmpMigrationURI(uri, false)
+		assert.Error(err, "uri: %s", uri)
+	}
+}
+
+func TestQemuBlockDeviceCache(t *testing.T) {
+	assert := assert.New(t)
+
+	q := &qemu{
+		config: HypervisorConfig{
+			BlockDeviceCacheSet:     true,
+			BlockDeviceCacheNoflush: true,
+		},
+	}
+
+	drive := &config.BlockDrive{}
+
+	// the hypervisor configuration is the default
+	set, direct, noFlush, err := q.blockDeviceCache(drive)
+	assert.NoError(err)
+	assert.True(set)
+	assert.False(direct)
+	assert.True(noFlush)
+
+	drive.CacheMode = config.BlockCacheNone
+	set, direct, noFlush, err = q.blockDeviceCache(drive)
+	assert.NoError(err)
+	assert.True(set)
+	assert.True(direct)
+	assert.False(noFlush)
+
+	drive.CacheMode = config.BlockCacheWriteback
+	set, direct, noFlush, err = q.blockDeviceCache(drive)
+	assert.NoError(err)
+	assert.True(set)
+	assert.False(direct)
+	assert.False(noFlush)
+
+	drive.CacheMode = config.BlockCacheWritethrough
+	_, _, _, err = q.blockDeviceCache(drive)
+	assert.Error(err)
+
+	drive.CacheMode = "unsafe"
+	_, _, _, err = q.blockDeviceCache(drive)
+	assert.Error(err)
+}
+
+func TestBlockdevAddReadOnlyArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	drive := &config.BlockDrive{
+		File: "/dev/sdb",
+		ID:   "drive-sdb",
+	}
+
+	args := blockdevAddReadOnlyArgs(drive, false, false, false)
+	assert.Equal("drive-sdb", args["node-name"])
+	assert.Equal(true, args["read-only"])
+	assert.NotContains(args, "cache")
+
+	args = blockdevAddReadOnlyArgs(drive, true, true, false)
+	assert.Equal(map[string]interface{}{"direct": true, "no-flush": false}, args["cache"])
+}
+
+func TestBlockDeviceAddArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	savedMajor, savedMinor := qemuMajorVersion, qemuMinorVersion
+	defer func() {
+		qemuMajorVersion, qemuMinorVersion = savedMajor, savedMinor
+	}()
+
+	drive := &config.BlockDrive{
+		File:   "/dev/sdb",
+		ID:     "drive-sdb",
+		Serial: config.DriveSerial("drive-sdb"),
+	}
+
+	qemuMajorVersion, qemuMinorVersion = 4, 1
+	args := blockDeviceAddArgs(drive, "virtio-drive-sdb", "virtio-blk-pci")
+	assert.Equal(map[string]interface{}{
+		"id":       "virtio-drive-sdb",
+		"driver":   "virtio-blk-pci",
+		"drive":    "drive-sdb",
+		"serial":   drive.Serial,
+		"share-rw": "on",
+	}, args)
+
+	qemuMajorVersion, qemuMinorVersion = 2, 9
+	args = blockDeviceAddArgs(drive, "virtio-drive-sdb", "virtio-blk-pci")
+	assert.NotContains(args, "share-rw")
+
+	drive.Serial = ""
+	args = blockDeviceAddArgs(drive, "scsi-drive-sdb", "scsi-hd")
+	assert.NotContains(args, "serial")
+}
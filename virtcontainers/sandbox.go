@@ -7,14 +7,17 @@ package virtcontainers
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math"
 	"net"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/containerd/cgroups"
 	"github.com/containernetworking/plugins/pkg/ns"
@@ -24,6 +27,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
 
 	"github.com/kata-containers/agent/protocols/grpc"
 	"github.com/kata-containers/runtime/virtcontainers/device/api"
@@ -44,10 +48,6 @@ import (
 )
 
 const (
-	// vmStartTimeout represents the time in seconds a sandbox can wait before
-	// to consider the VM starting operation failed.
-	vmStartTimeout = 10
-
 	// DirMode is the permission bits used for creating a directory
 	DirMode = os.FileMode(0750) | os.ModeDir
 )
@@ -61,6 +61,13 @@ type SandboxStatus struct {
 	Agent            AgentType
 	ContainersStatus []ContainerStatus
 
+	// HypervisorPid is the PID of the hypervisor, 0 when the VM is not
+	// running, VMPath the host directory of the files of the VM and
+	// NetNsPath the network namespace of the sandbox.
+	HypervisorPid int
+	VMPath        string
+	NetNsPath     string
+
 	// Annotations allow clients to store arbitrary values,
 	// for example to add additional status values required
 	// to support particular specifications.
@@ -71,6 +78,8 @@ type SandboxStatus struct {
 type SandboxStats struct {
 	CgroupStats CgroupStats
 	Cpus        int
+	TimeSync    TimeSyncStats
+	Idle        IdleStats
 }
 
 // SandboxConfig is a Sandbox configuration.
@@ -123,6 +132,92 @@ type SandboxConfig struct {
 
 	DisableGuestSeccomp bool
 
+	// DisableGuestSELinux and DisableGuestAppArmor prevent the SELinux
+	// labels and the AppArmor profile of the containers from being
+	// applied by the guest, even if its kernel enables these LSMs.
+	DisableGuestSELinux  bool
+	DisableGuestAppArmor bool
+
+	// PrivilegedDeviceAllowList lists the path patterns of the host devices
+	// privileged containers can access, all the others are filtered out.
+	PrivilegedDeviceAllowList []string
+
+	// SandboxTmpQuota is the size in MiB of the tmpfs backing the sandbox
+	// temporary tree, 0 means the tree is not size limited.
+	SandboxTmpQuota uint32
+
+	// ScratchDiskSize is the size in MiB of the encrypted scratch disk
+	// backing the local storages of the sandbox, 0 means there is none.
+	ScratchDiskSize uint32
+
+	// VCPUIsolation keeps the vCPU threads from sharing host cores with
+	// the other sandboxes.
+	VCPUIsolation VCPUIsolation
+
+	// StatsVMMOverhead adds the memory used on the host by the hypervisor
+	// and the runtime to the stats of the sandbox container, so that the
+	// pod level stats reflect the real cost of the sandbox.
+	StatsVMMOverhead bool
+
+	// GuestTimeSyncInterval is the period in seconds the guest clock is
+	// set to the host clock at, 0 means the guest clock is never synced.
+	GuestTimeSyncInterval uint32
+
+	// MountWatchInterval is the period in seconds the Kubernetes volumes
+	// updated by the kubelet (configmaps, secrets, downward API) are
+	// checked for updates at, their content being copied to the guest
+	// rather than shared. 0 means these volumes are shared as the others.
+	MountWatchInterval uint32
+
+	// IdlePauseTimeout is the time in seconds the hypervisor must use
+	// almost no CPU for the VM to be paused, until the next request to
+	// the guest. 0 means the VM is never paused because it's idle.
+	IdlePauseTimeout uint32
+
+	// StaticResourceMgmt creates the VM with all the resources of the pod,
+	// its vCPUs and memory are not resized as containers are added.
+	StaticResourceMgmt bool
+
+	// StaticMemoryMgmt is set when the VM is created with the memory of
+	// the pod, its memory is not resized as containers are added.
+	StaticMemoryMgmt bool
+
+	// HypervisorExitHook is executed with the sandbox ID as argument when
+	// the hypervisor process exits unexpectedly.
+	HypervisorExitHook string
+
+	// AuditLog is the sink of the sandbox lifecycle audit events, either
+	// the path of the file the JSON events are appended to or "journal".
+	// The events are not recorded when empty.
+	AuditLog string
+
+	// NetSysctlAllowList lists the patterns of the network sysctls of the
+	// containers applied in the guest, the other network sysctls being
+	// dropped. All of them are applied when empty.
+	NetSysctlAllowList []string
+
+	// SandboxLogDir is the directory of the sandbox log file, holding the
+	// runtime, hypervisor and guest console logs of the sandbox. The logs
+	// are only sent to the runtime log when empty.
+	SandboxLogDir string
+
+	// SandboxLogFormat is the format of the sandbox log file, "text"
+	// (default) or "json".
+	SandboxLogFormat string
+
+	// GuestDNS makes the agent generate the resolv.conf of the guest and
+	// of the containers, instead of sharing the one of the host.
+	GuestDNS bool
+
+	// DNS overrides the DNS configuration of the resolv.conf mounted in
+	// the sandbox container, the resolv.conf being generated by the agent
+	// when set.
+	DNS DNSConfig
+
+	// AssetRegistry lists the expected digests and sources of the guest
+	// assets, verified when the sandbox is created.
+	AssetRegistry AssetRegistryConfig
+
 	// HasCRIContainerType specifies whether container type was set explicitly through annotations or not.
 	HasCRIContainerType bool
 
@@ -140,7 +235,7 @@ func (s *Sandbox) trace(name string) (opentracing.Span, context.Context) {
 		s.ctx = context.Background()
 	}
 
-	span, ctx := opentracing.StartSpanFromContext(s.ctx, name)
+	span, ctx := startSpanFromContext(s.ctx, "sandbox", name)
 
 	span.SetTag("subsystem", "sandbox")
 
@@ -190,8 +285,13 @@ type Sandbox struct {
 	// store is used to replace VCStore step by step
 	newStore persistapi.PersistDriver
 
-	network Network
-	monitor *monitor
+	network  Network
+	monitor  *monitor
+	timeSync *timeSync
+
+	mountWatcher *mountWatcher
+
+	idleMonitor *idleMonitor
 
 	config *SandboxConfig
 
@@ -217,6 +317,14 @@ type Sandbox struct {
 
 	cgroupMgr *vccgroups.Manager
 
+	// vsockTunnels are the tunnels to the guest vsock ports created in
+	// this process, keyed by port.
+	vsockTunnels     map[uint32]*vsockTunnel
+	vsockTunnelsLock sync.Mutex
+
+	// logHook writes the runtime logs to the sandbox log file.
+	logHook *sandboxLogHook
+
 	ctx context.Context
 }
 
@@ -298,6 +406,9 @@ func (s *Sandbox) Release() error {
 	if s.monitor != nil {
 		s.monitor.stop()
 	}
+	s.stopTimeSync()
+	s.stopMountWatcher()
+	s.stopIdleMonitor()
 	s.hypervisor.disconnect()
 	return s.agent.disconnect()
 }
@@ -330,7 +441,7 @@ func (s *Sandbox) Status() SandboxStatus {
 		})
 	}
 
-	return SandboxStatus{
+	status := SandboxStatus{
 		ID:               s.id,
 		State:            s.state,
 		Hypervisor:       s.config.HypervisorType,
@@ -338,13 +449,24 @@ func (s *Sandbox) Status() SandboxStatus {
 		Agent:            s.config.AgentType,
 		ContainersStatus: contStatusList,
 		Annotations:      s.config.Annotations,
+		VMPath:           s.hypervisor.getVMPath(),
+		NetNsPath:        s.networkNS.NetNsPath,
 	}
+
+	switch s.state.State {
+	case types.StateReady, types.StateRunning, types.StatePaused:
+		if pids := s.hypervisor.getPids(); len(pids) > 0 {
+			status.HypervisorPid = pids[0]
+		}
+	}
+
+	return status
 }
 
 // Monitor returns a error channel for watcher to watch at
 func (s *Sandbox) Monitor() (chan error, error) {
 	if s.state.State != types.StateRunning {
-		return nil, fmt.Errorf("Sandbox is not running")
+		return nil, vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox is not running")
 	}
 
 	s.Lock()
@@ -359,7 +481,7 @@ func (s *Sandbox) Monitor() (chan error, error) {
 // WaitProcess waits on a container process and return its exit code
 func (s *Sandbox) WaitProcess(containerID, processID string) (int32, error) {
 	if s.state.State != types.StateRunning {
-		return 0, fmt.Errorf("Sandbox not running")
+		return 0, vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running")
 	}
 
 	c, err := s.findContainer(containerID)
@@ -374,7 +496,11 @@ func (s *Sandbox) WaitProcess(containerID, processID string) (int32, error) {
 // When all is true, it sends the signal to all processes of a container.
 func (s *Sandbox) SignalProcess(containerID, processID string, signal syscall.Signal, all bool) error {
 	if s.state.State != types.StateRunning {
-		return fmt.Errorf("Sandbox not running")
+		return vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running")
+	}
+
+	if err := s.wakeUp(); err != nil {
+		return err
 	}
 
 	c, err := s.findContainer(containerID)
@@ -388,7 +514,11 @@ func (s *Sandbox) SignalProcess(containerID, processID string, signal syscall.Si
 // WinsizeProcess resizes the tty window of a process
 func (s *Sandbox) WinsizeProcess(containerID, processID string, height, width uint32) error {
 	if s.state.State != types.StateRunning {
-		return fmt.Errorf("Sandbox not running")
+		return vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running")
+	}
+
+	if err := s.wakeUp(); err != nil {
+		return err
 	}
 
 	c, err := s.findContainer(containerID)
@@ -402,7 +532,11 @@ func (s *Sandbox) WinsizeProcess(containerID, processID string, height, width ui
 // IOStream returns stdin writer, stdout reader and stderr reader of a process
 func (s *Sandbox) IOStream(containerID, processID string) (io.WriteCloser, io.Reader, io.Reader, error) {
 	if s.state.State != types.StateRunning {
-		return nil, nil, nil, fmt.Errorf("Sandbox not running")
+		return nil, nil, nil, vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running")
+	}
+
+	if err := s.wakeUp(); err != nil {
+		return nil, nil, nil, err
 	}
 
 	c, err := s.findContainer(containerID)
@@ -442,7 +576,7 @@ func createAssets(ctx context.Context, sandboxConfig *SandboxConfig) error {
 		}
 	}
 
-	return nil
+	return applyAssetRegistry(sandboxConfig.AssetRegistry, &sandboxConfig.HypervisorConfig)
 }
 
 func (s *Sandbox) getAndStoreGuestDetails() error {
@@ -457,6 +591,9 @@ func (s *Sandbox) getAndStoreGuestDetails() error {
 	if guestDetailRes != nil {
 		s.state.GuestMemoryBlockSizeMB = uint32(guestDetailRes.MemBlockSizeBytes >> 20)
 		if guestDetailRes.AgentDetails != nil {
+			if err := checkAgentVersion(guestDetailRes.AgentDetails.Version); err != nil {
+				return err
+			}
 			s.seccompSupported = guestDetailRes.AgentDetails.SupportsSeccomp
 		}
 		s.state.GuestMemoryHotplugProbe = guestDetailRes.SupportMemHotplugProbe
@@ -471,6 +608,12 @@ func (s *Sandbox) getAndStoreGuestDetails() error {
 // to physically create that sandbox i.e. starts a VM for that sandbox to eventually
 // be started.
 func createSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Factory) (*Sandbox, error) {
+	createTime := time.Now()
+
+	if sandboxConfig.HypervisorConfig.BootProfile == BootProfileFast {
+		ctx = withoutTracing(ctx)
+	}
+
 	span, ctx := trace(ctx, "createSandbox")
 	defer span.Finish()
 
@@ -495,6 +638,17 @@ func createSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Fac
 		return s, nil
 	}
 
+	kernelPath, err := s.config.HypervisorConfig.KernelAssetPath()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkGuestKernel(kernelPath, &s.config.HypervisorConfig); err != nil {
+		return nil, err
+	}
+
+	s.recordBootEvent(bootEventCreate, createTime)
+
 	// Below code path is called only during create, because of earlier check.
 	if err := s.agent.createSandbox(s); err != nil {
 		return nil, err
@@ -549,14 +703,25 @@ func newSandbox(ctx context.Context, sandboxConfig SandboxConfig, factory Factor
 		return nil, err
 	}
 
+	if logErr := s.startSandboxLog(); logErr != nil {
+		s.Logger().WithError(logErr).Warn("failed to open sandbox log file")
+	}
+
 	defer func() {
 		if err != nil {
 			s.Logger().WithError(err).WithField("sandboxid", s.id).Error("Create new sandbox failed")
 			globalSandboxList.removeSandbox(s.id)
 			s.newStore.Destroy(s.id)
+			s.stopSandboxLog()
 		}
 	}()
 
+	// The hypervisor creates its fifos, sockets and configs in the sandbox
+	// temporary tree, it must be ready before the hypervisor is created.
+	if err = setupSandboxTmpDir(s.id, sandboxConfig.SandboxTmpQuota); err != nil {
+		return nil, err
+	}
+
 	if useOldStore(ctx) {
 		vcStore, err := store.NewVCSandboxStore(ctx, s.id)
 		if err != nil {
@@ -801,6 +966,9 @@ func (s *Sandbox) Delete() error {
 	if s.monitor != nil {
 		s.monitor.stop()
 	}
+	s.stopTimeSync()
+	s.stopMountWatcher()
+	s.stopIdleMonitor()
 
 	if err := s.hypervisor.cleanup(); err != nil {
 		s.Logger().WithError(err).Error("failed to cleanup hypervisor")
@@ -808,6 +976,21 @@ func (s *Sandbox) Delete() error {
 
 	s.agent.cleanup(s)
 
+	// the scratch disk outlives the VMs that were not stopped cleanly
+	if err := s.removeScratchDisk(); err != nil {
+		s.Logger().WithError(err).Error("failed to remove scratch disk")
+	}
+
+	if err := s.releaseVCPUCores(); err != nil {
+		s.Logger().WithError(err).Error("failed to release vCPU cores")
+	}
+
+	if err := cleanupSandboxTmpDir(s.id); err != nil {
+		s.Logger().WithError(err).Error("failed to cleanup sandbox temporary tree")
+	}
+
+	s.stopSandboxLog()
+
 	return s.newStore.Destroy(s.id)
 }
 
@@ -894,7 +1077,7 @@ func (s *Sandbox) removeNetwork() error {
 		}
 	}
 
-	return s.network.Remove(s.ctx, &s.networkNS, s.hypervisor)
+	return s.network.Remove(s.ctx, &s.networkNS, &s.config.NetworkConfig, s.hypervisor)
 }
 
 func (s *Sandbox) generateNetInfo(inf *vcTypes.Interface) (NetworkInfo, error) {
@@ -929,12 +1112,16 @@ func (s *Sandbox) generateNetInfo(inf *vcTypes.Interface) (NetworkInfo, error) {
 
 // AddInterface adds new nic to the sandbox.
 func (s *Sandbox) AddInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	netInfo, err := s.generateNetInfo(inf)
 	if err != nil {
 		return nil, err
 	}
 
-	endpoint, err := createEndpoint(netInfo, len(s.networkNS.Endpoints), s.config.NetworkConfig.InterworkingModel, nil)
+	endpoint, err := createEndpoint(netInfo, len(s.networkNS.Endpoints), &s.config.NetworkConfig, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -960,6 +1147,10 @@ func (s *Sandbox) AddInterface(inf *vcTypes.Interface) (*vcTypes.Interface, erro
 
 // RemoveInterface removes a nic of the sandbox.
 func (s *Sandbox) RemoveInterface(inf *vcTypes.Interface) (*vcTypes.Interface, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	for i, endpoint := range s.networkNS.Endpoints {
 		if endpoint.HardwareAddr() == inf.HwAddr {
 			s.Logger().WithField("endpoint-type", endpoint.Type()).Info("Hot detaching endpoint")
@@ -980,16 +1171,28 @@ func (s *Sandbox) RemoveInterface(inf *vcTypes.Interface) (*vcTypes.Interface, e
 
 // ListInterfaces lists all nics and their configurations in the sandbox.
 func (s *Sandbox) ListInterfaces() ([]*vcTypes.Interface, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	return s.agent.listInterfaces()
 }
 
 // UpdateRoutes updates the sandbox route table (e.g. for portmapping support).
 func (s *Sandbox) UpdateRoutes(routes []*vcTypes.Route) ([]*vcTypes.Route, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	return s.agent.updateRoutes(routes)
 }
 
 // ListRoutes lists all routes and their configurations in the sandbox.
 func (s *Sandbox) ListRoutes() ([]*vcTypes.Route, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	return s.agent.listRoutes()
 }
 
@@ -1000,6 +1203,10 @@ func (s *Sandbox) startVM() (err error) {
 
 	s.Logger().Info("Starting VM")
 
+	s.state.HypervisorExitReason = ""
+	s.state.HypervisorExitTime = time.Time{}
+
+	s.recordBootEvent(bootEventVMMStart, time.Now())
 	if err := s.network.Run(s.networkNS.NetNsPath, func() error {
 		if s.factory != nil {
 			vm, err := s.factory.GetVM(ctx, VMConfig{
@@ -1017,14 +1224,16 @@ func (s *Sandbox) startVM() (err error) {
 			return vm.assignSandbox(s)
 		}
 
-		return s.hypervisor.startSandbox(vmStartTimeout)
+		return s.hypervisor.startSandbox(s.config.HypervisorConfig.bootTimeout())
 	}); err != nil {
 		return err
 	}
+	s.recordBootEvent(bootEventVMMReady, time.Now())
 
 	defer func() {
 		if err != nil {
 			s.hypervisor.stopSandbox()
+			s.removeScratchDisk()
 		}
 	}()
 
@@ -1047,15 +1256,37 @@ func (s *Sandbox) startVM() (err error) {
 
 	s.Logger().Info("VM started")
 
-	// Once the hypervisor is done starting the sandbox,
-	// we want to guarantee that it is manageable.
-	// For that we need to ask the agent to start the
-	// sandbox inside the VM.
-	if err := s.agent.startSandbox(s); err != nil {
+	if s.config.HypervisorConfig.IncomingMigrationURI != "" {
+		// The sandbox was started inside the VM on the source host
+		// of its migration.
+		if err := s.agent.resumeMigratedSandbox(s); err != nil {
+			return err
+		}
+	} else {
+		if err := s.createScratchDisk(); err != nil {
+			return err
+		}
+
+		// Once the hypervisor is done starting the sandbox,
+		// we want to guarantee that it is manageable.
+		// For that we need to ask the agent to start the
+		// sandbox inside the VM.
+		if err := s.agent.startSandbox(s); err != nil {
+			return err
+		}
+		s.recordBootEvent(bootEventAgentReady, time.Now())
+
+		s.Logger().Info("Agent started in the sandbox")
+	}
+
+	channels, err := s.vsockChannels()
+	if err != nil {
 		return err
 	}
+	s.state.VSockChannels = channels
 
-	s.Logger().Info("Agent started in the sandbox")
+	s.startTimeSync()
+	s.startIdleMonitor()
 
 	return nil
 }
@@ -1065,6 +1296,15 @@ func (s *Sandbox) stopVM() error {
 	span, _ := s.trace("stopVM")
 	defer span.Finish()
 
+	// The hypervisor exiting is expected from now on, it must not be
+	// reported as a sandbox failure.
+	if s.monitor != nil {
+		s.monitor.stop()
+	}
+	s.stopTimeSync()
+	s.stopMountWatcher()
+	s.stopIdleMonitor()
+
 	s.Logger().Info("Stopping sandbox in the VM")
 	if err := s.agent.stopSandbox(s); err != nil {
 		s.Logger().WithError(err).WithField("sandboxid", s.id).Warning("Agent did not stop sandbox")
@@ -1120,6 +1360,10 @@ func (s *Sandbox) fetchContainers() error {
 // This should be called only when the sandbox is already created.
 // It will add new container config to sandbox.config.Containers
 func (s *Sandbox) CreateContainer(contConfig ContainerConfig) (VCContainer, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	// Create the container.
 	c, err := newContainer(s, &contConfig)
 	if err != nil {
@@ -1143,6 +1387,11 @@ func (s *Sandbox) CreateContainer(contConfig ContainerConfig) (VCContainer, erro
 		return nil, err
 	}
 
+	// The container holds its own reference on its rootfs device now.
+	if err = s.releasePrewarmedDevice(c.id); err != nil {
+		return nil, err
+	}
+
 	// Add the container to the containers list in the sandbox.
 	if err = s.addContainer(c); err != nil {
 		return nil, err
@@ -1176,6 +1425,10 @@ func (s *Sandbox) CreateContainer(contConfig ContainerConfig) (VCContainer, erro
 
 // StartContainer starts a container in the sandbox
 func (s *Sandbox) StartContainer(containerID string) (VCContainer, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	// Fetch the container.
 	c, err := s.findContainer(containerID)
 	if err != nil {
@@ -1206,6 +1459,10 @@ func (s *Sandbox) StartContainer(containerID string) (VCContainer, error) {
 
 // StopContainer stops a container in the sandbox
 func (s *Sandbox) StopContainer(containerID string, force bool) (VCContainer, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	// Fetch the container.
 	c, err := s.findContainer(containerID)
 	if err != nil {
@@ -1225,6 +1482,10 @@ func (s *Sandbox) StopContainer(containerID string, force bool) (VCContainer, er
 
 // KillContainer signals a container in the sandbox
 func (s *Sandbox) KillContainer(containerID string, signal syscall.Signal, all bool) error {
+	if err := s.wakeUp(); err != nil {
+		return err
+	}
+
 	// Fetch the container.
 	c, err := s.findContainer(containerID)
 	if err != nil {
@@ -1245,6 +1506,10 @@ func (s *Sandbox) KillContainer(containerID string, signal syscall.Signal, all b
 
 // DeleteContainer deletes a container from the sandbox
 func (s *Sandbox) DeleteContainer(containerID string) (VCContainer, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	if containerID == "" {
 		return nil, vcTypes.ErrNeedContainerID
 	}
@@ -1278,6 +1543,10 @@ func (s *Sandbox) DeleteContainer(containerID string) (VCContainer, error) {
 // ProcessListContainer lists every process running inside a specific
 // container in the sandbox.
 func (s *Sandbox) ProcessListContainer(containerID string, options ProcessListOptions) (ProcessList, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	// Fetch the container.
 	c, err := s.findContainer(containerID)
 	if err != nil {
@@ -1317,6 +1586,10 @@ func (s *Sandbox) StatusContainer(containerID string) (ContainerStatus, error) {
 // EnterContainer is the virtcontainers container command execution entry point.
 // EnterContainer enters an already running container and runs a given command.
 func (s *Sandbox) EnterContainer(containerID string, cmd types.Cmd) (VCContainer, *Process, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, nil, err
+	}
+
 	// Fetch the container.
 	c, err := s.findContainer(containerID)
 	if err != nil {
@@ -1334,6 +1607,10 @@ func (s *Sandbox) EnterContainer(containerID string, cmd types.Cmd) (VCContainer
 
 // UpdateContainer update a running container.
 func (s *Sandbox) UpdateContainer(containerID string, resources specs.LinuxResources) error {
+	if err := s.wakeUp(); err != nil {
+		return err
+	}
+
 	// Fetch the container.
 	c, err := s.findContainer(containerID)
 	if err != nil {
@@ -1357,6 +1634,18 @@ func (s *Sandbox) UpdateContainer(containerID string, resources specs.LinuxResou
 
 // StatsContainer return the stats of a running container
 func (s *Sandbox) StatsContainer(containerID string) (ContainerStats, error) {
+	// the stats of an idle sandbox don't change, its VM isn't resumed
+	// for them
+	if s.idleMonitor != nil {
+		if stats, ok := s.idleMonitor.cachedContainerStats(containerID); ok {
+			return stats, nil
+		}
+	}
+
+	if err := s.wakeUp(); err != nil {
+		return ContainerStats{}, err
+	}
+
 	// Fetch the container.
 	c, err := s.findContainer(containerID)
 	if err != nil {
@@ -1367,6 +1656,17 @@ func (s *Sandbox) StatsContainer(containerID string) (ContainerStats, error) {
 	if err != nil {
 		return ContainerStats{}, err
 	}
+
+	// the pod stats are the sum of the stats of its containers, the
+	// sandbox container carries the host memory of the VMM
+	if s.config.StatsVMMOverhead && c.GetAnnotations()[annotations.ContainerTypeKey] == string(PodSandbox) {
+		addMemoryOverhead(stats, s.vmmOverhead())
+	}
+
+	if s.idleMonitor != nil {
+		s.idleMonitor.setContainerStats(containerID, *stats)
+	}
+
 	return *stats, nil
 }
 
@@ -1401,6 +1701,12 @@ func (s *Sandbox) Stats() (SandboxStats, error) {
 
 	stats.CgroupStats.CPUStats.CPUUsage.TotalUsage = metrics.CPU.Usage.Total
 	stats.CgroupStats.MemoryStats.Usage.Usage = metrics.Memory.Usage.Usage
+	if s.timeSync != nil {
+		stats.TimeSync = s.timeSync.getStats()
+	}
+	if s.idleMonitor != nil {
+		stats.Idle = s.idleMonitor.getStats()
+	}
 	tids, err := s.hypervisor.getThreadIDs()
 	if err != nil {
 		return stats, err
@@ -1412,6 +1718,10 @@ func (s *Sandbox) Stats() (SandboxStats, error) {
 
 // PauseContainer pauses a running container.
 func (s *Sandbox) PauseContainer(containerID string) error {
+	if err := s.wakeUp(); err != nil {
+		return err
+	}
+
 	// Fetch the container.
 	c, err := s.findContainer(containerID)
 	if err != nil {
@@ -1431,6 +1741,10 @@ func (s *Sandbox) PauseContainer(containerID string) error {
 
 // ResumeContainer resumes a paused container.
 func (s *Sandbox) ResumeContainer(containerID string) error {
+	if err := s.wakeUp(); err != nil {
+		return err
+	}
+
 	// Fetch the container.
 	c, err := s.findContainer(containerID)
 	if err != nil {
@@ -1448,6 +1762,260 @@ func (s *Sandbox) ResumeContainer(containerID string) error {
 	return nil
 }
 
+// LaunchMeasurement returns the base64 encoded launch measurement of the VM
+// of a confidential sandbox.
+func (s *Sandbox) LaunchMeasurement() (string, error) {
+	if s.state.State != types.StateRunning {
+		return "", vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running, impossible to get its launch measurement")
+	}
+
+	if !s.config.HypervisorConfig.ConfidentialGuest {
+		return "", fmt.Errorf("Sandbox %s is not a confidential guest", s.id)
+	}
+
+	return s.hypervisor.launchMeasurement()
+}
+
+// migrationState is the persisted state of a migrated sandbox, sent to the
+// destination host along with the migration stream of its VM.
+type migrationState struct {
+	Sandbox    persistapi.SandboxState
+	Containers map[string]persistapi.ContainerState
+}
+
+// Migrate sends the state of the sandbox VM to uri and returns the persisted
+// state of the sandbox, to restore it on another host with restoreSandbox().
+// The VM is paused and the sandbox state saved beforehand, so that the state
+// returned matches the VM state sent. The sandbox is left paused once
+// migrated, resuming it rolls the migration back.
+//
+// The VM is started again from the sandbox configuration on the destination
+// host, it can't have devices, memory or vCPUs hotplugged since it booted.
+// Neither can it share a file system with the host, whose daemon state
+// wouldn't follow the VM.
+func (s *Sandbox) Migrate(uri string) (_ []byte, err error) {
+	if s.state.State != types.StateRunning {
+		return nil, vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running, impossible to migrate")
+	}
+
+	if fsShareSupported(s.hypervisor) {
+		return nil, fmt.Errorf("Sandboxes sharing a %s file system can't be migrated", s.config.HypervisorConfig.SharedFS)
+	}
+
+	for _, d := range s.devManager.GetAllDevices() {
+		if d.GetAttachCount() > 0 {
+			return nil, fmt.Errorf("Sandboxes with attached devices can't be migrated, device %s is attached", d.DeviceID())
+		}
+	}
+
+	if err = s.wakeUp(); err != nil {
+		return nil, err
+	}
+
+	if err = s.hypervisor.pauseSandbox(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if err := s.hypervisor.resumeSandbox(); err != nil {
+			s.Logger().WithError(err).Error("Could not resume sandbox after failed migration")
+			return
+		}
+
+		if err := s.setSandboxState(types.StateRunning); err != nil {
+			s.Logger().WithError(err).Error("Could not restore sandbox state after failed migration")
+			return
+		}
+
+		if err := s.storeSandbox(); err != nil {
+			s.Logger().WithError(err).Error("Could not store sandbox after failed migration")
+		}
+	}()
+
+	if err = s.setSandboxState(types.StatePaused); err != nil {
+		return nil, err
+	}
+
+	if err = s.storeSandbox(); err != nil {
+		return nil, err
+	}
+
+	var ms migrationState
+	if ms.Sandbox, ms.Containers, err = s.newStore.FromDisk(s.id); err != nil {
+		return nil, err
+	}
+
+	state, err := json.Marshal(ms)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = s.hypervisor.migrateSandbox(uri); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// restoreSandbox recreates a sandbox migrated from another host with
+// Sandbox.Migrate(), from the persisted state it returned, and restores its
+// VM from the migration stream at uri. The network namespace of the sandbox
+// must exist on this host, with the same interfaces as on the source host.
+func restoreSandbox(ctx context.Context, state []byte, uri string) (_ *Sandbox, err error) {
+	var ms migrationState
+	if err := json.Unmarshal(state, &ms); err != nil {
+		return nil, fmt.Errorf("Invalid migration state: %v", err)
+	}
+
+	id := ms.Sandbox.SandboxContainer
+	if id == "" {
+		return nil, vcTypes.ErrNeedSandboxID
+	}
+
+	store, err := persist.GetDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, _, err := store.FromDisk(id); err == nil {
+		return nil, fmt.Errorf("Sandbox %s already exists", id)
+	}
+
+	// The VM isn't running on this host until it's restored.
+	ms.Sandbox.State = string(types.StateReady)
+	ms.Sandbox.Config.HypervisorConfig.IncomingMigrationURI = uri
+	if err = store.ToDisk(ms.Sandbox, ms.Containers); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			store.Destroy(id)
+		}
+	}()
+
+	s, err := fetchSandbox(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			globalSandboxList.removeSandbox(id)
+		}
+	}()
+
+	if s.config.SandboxCgroupOnly {
+		if err = s.setupSandboxCgroup(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The devices of the agent are part of the VM configuration, which
+	// isn't persisted.
+	if err = s.agent.createSandbox(s); err != nil {
+		return nil, err
+	}
+
+	if err = s.createNetwork(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			s.removeNetwork()
+		}
+	}()
+
+	if err = s.startVM(); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if err != nil {
+			s.stopVM()
+		}
+	}()
+
+	s.postCreatedNetwork()
+
+	// Booting the VM again, e.g. when rebooting it, must not restore it.
+	s.config.HypervisorConfig.IncomingMigrationURI = ""
+
+	if err = s.setSandboxState(types.StateRunning); err != nil {
+		return nil, err
+	}
+
+	if err = s.storeSandbox(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Reboot reboots the guest of the sandbox, e.g. to recover from a wedged
+// guest kernel, without recreating the sandbox: its VM, network and devices
+// are kept. The containers don't survive the reboot of their guest, they're
+// stopped beforehand and have to be created again.
+func (s *Sandbox) Reboot() (err error) {
+	span, _ := s.trace("reboot")
+	defer span.Finish()
+
+	if s.state.State != types.StateRunning {
+		return vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running, impossible to reboot")
+	}
+
+	defer func() {
+		s.audit(auditSandboxReboot, nil, err)
+	}()
+
+	if err = s.wakeUp(); err != nil {
+		return err
+	}
+
+	for _, c := range s.containers {
+		if err := c.stop(true); err != nil {
+			return err
+		}
+	}
+
+	s.stopTimeSync()
+	s.stopIdleMonitor()
+
+	// The hypervisor process may exit and be started again, e.g. for
+	// firecracker, which mustn't be reported as the death of the VM. The
+	// monitor watches the new process once the guest is back.
+	if s.monitor != nil {
+		s.monitor.suspend()
+		defer s.monitor.resume()
+	}
+
+	s.Logger().Info("Rebooting sandbox")
+	if err = s.hypervisor.rebootSandbox(); err != nil {
+		return err
+	}
+
+	if err = s.agent.restartSandbox(s); err != nil {
+		return err
+	}
+
+	s.startTimeSync()
+	s.startIdleMonitor()
+
+	// the hypervisor may have been started again
+	if err = s.cgroupsUpdate(); err != nil {
+		return err
+	}
+
+	s.Logger().Info("Sandbox rebooted")
+
+	return s.storeSandbox()
+}
+
 // createContainers registers all containers to the proxy, create the
 // containers in the guest and starts one shim per container.
 func (s *Sandbox) createContainers() error {
@@ -1487,12 +2055,14 @@ func (s *Sandbox) createContainers() error {
 
 // Start starts a sandbox. The containers that are making the sandbox
 // will be started.
-func (s *Sandbox) Start() error {
+func (s *Sandbox) Start() (err error) {
 	if err := s.state.ValidTransition(s.state.State, types.StateRunning); err != nil {
 		return err
 	}
 
-	prevState := s.state.State
+	defer func() {
+		s.audit(auditSandboxStart, nil, err)
+	}()
 
 	if err := s.setSandboxState(types.StateRunning); err != nil {
 		return err
@@ -1501,7 +2071,9 @@ func (s *Sandbox) Start() error {
 	var startErr error
 	defer func() {
 		if startErr != nil {
-			s.setSandboxState(prevState)
+			if err := s.revertSandboxState(); err != nil {
+				s.Logger().WithError(err).Error("Could not revert sandbox state after failed start")
+			}
 		}
 	}()
 	for _, c := range s.containers {
@@ -1522,7 +2094,7 @@ func (s *Sandbox) Start() error {
 // Stop stops a sandbox. The containers that are making the sandbox
 // will be destroyed.
 // When force is true, ignore guest related stop failures.
-func (s *Sandbox) Stop(force bool) error {
+func (s *Sandbox) Stop(force bool) (err error) {
 	span, _ := s.trace("stop")
 	defer span.Finish()
 
@@ -1535,16 +2107,38 @@ func (s *Sandbox) Stop(force bool) error {
 		return err
 	}
 
+	defer func() {
+		s.audit(auditSandboxStop, nil, err)
+	}()
+
+	if err := s.wakeUp(); err != nil && !force {
+		return err
+	}
+
+	s.closeVSockTunnels()
+
 	for _, c := range s.containers {
 		if err := c.stop(force); err != nil {
 			return err
 		}
 	}
 
+	if err := s.releasePrewarmedDevices(); err != nil && !force {
+		return err
+	}
+
 	if err := s.stopVM(); err != nil && !force {
 		return err
 	}
 
+	if err := s.removeScratchDisk(); err != nil && !force {
+		return err
+	}
+
+	if err := s.releaseVCPUCores(); err != nil && !force {
+		return err
+	}
+
 	if err := s.setSandboxState(types.StateStopped); err != nil {
 		return err
 	}
@@ -1572,15 +2166,51 @@ func (s *Sandbox) enter(args []string) error {
 }
 
 // setSandboxState sets both the in-memory and on-disk state of the
-// sandbox.
+// sandbox, failing if state can't be reached from the current one.
 func (s *Sandbox) setSandboxState(state types.StateString) error {
 	if state == "" {
 		return vcTypes.ErrNeedState
 	}
 
 	// update in-memory state
-	s.state.State = state
+	prevState := s.state.State
+	if err := s.state.Transition(state); err != nil {
+		return err
+	}
+	s.Logger().WithField("from", prevState).WithField("to", state).Debug("Sandbox state changed")
+
+	return s.storeSandboxState()
+}
+
+// recordHypervisorExit stores in the sandbox state that its hypervisor
+// process exited, for the death of the VM to be known once the sandbox
+// is fetched again, e.g. by the runtime cleaning it up.
+func (s *Sandbox) recordHypervisorExit(reason string) {
+	// called from the monitor goroutine
+	s.Lock()
+	defer s.Unlock()
+
+	s.state.HypervisorExitReason = reason
+	s.state.HypervisorExitTime = time.Now()
+
+	s.Logger().WithField("reason", reason).Warn("Hypervisor exited")
 
+	if err := s.Save(); err != nil {
+		s.Logger().WithError(err).Error("Could not store hypervisor exit")
+	}
+}
+
+// revertSandboxState undoes the last state change of the sandbox, when the
+// operation it was made for failed.
+func (s *Sandbox) revertSandboxState() error {
+	if err := s.state.Revert(); err != nil {
+		return err
+	}
+
+	return s.storeSandboxState()
+}
+
+func (s *Sandbox) storeSandboxState() error {
 	if useOldStore(s.ctx) {
 		return s.store.Store(store.State, s.state)
 	}
@@ -1631,10 +2261,14 @@ func (s *Sandbox) unsetSandboxBlockIndex(index int) error {
 
 // HotplugAddDevice is used for add a device to sandbox
 // Sandbox implement DeviceReceiver interface from device/api/interface.go
-func (s *Sandbox) HotplugAddDevice(device api.Device, devType config.DeviceType) error {
+func (s *Sandbox) HotplugAddDevice(device api.Device, devType config.DeviceType) (err error) {
 	span, _ := s.trace("HotplugAddDevice")
 	defer span.Finish()
 
+	defer func() {
+		s.audit(auditDeviceHotplug, newAuditDevice(device, devType), err)
+	}()
+
 	switch devType {
 	case config.DeviceVFIO:
 		vfioDevices, ok := device.GetDeviceInfo().([]*config.VFIODev)
@@ -1678,7 +2312,11 @@ func (s *Sandbox) HotplugAddDevice(device api.Device, devType config.DeviceType)
 
 // HotplugRemoveDevice is used for removing a device from sandbox
 // Sandbox implement DeviceReceiver interface from device/api/interface.go
-func (s *Sandbox) HotplugRemoveDevice(device api.Device, devType config.DeviceType) error {
+func (s *Sandbox) HotplugRemoveDevice(device api.Device, devType config.DeviceType) (err error) {
+	defer func() {
+		s.audit(auditDeviceHotunplug, newAuditDevice(device, devType), err)
+	}()
+
 	switch devType {
 	case config.DeviceVFIO:
 		vfioDevices, ok := device.GetDeviceInfo().([]*config.VFIODev)
@@ -1745,6 +2383,10 @@ func (s *Sandbox) AppendDevice(device api.Device) error {
 
 // AddDevice will add a device to sandbox
 func (s *Sandbox) AddDevice(info config.DeviceInfo) (api.Device, error) {
+	if err := s.wakeUp(); err != nil {
+		return nil, err
+	}
+
 	if s.devManager == nil {
 		return nil, fmt.Errorf("device manager isn't initialized")
 	}
@@ -1772,6 +2414,131 @@ func (s *Sandbox) AddDevice(info config.DeviceInfo) (api.Device, error) {
 	return b, nil
 }
 
+// PrewarmContainerImage stages the rootfs of a container that has not been
+// created yet into the running sandbox. Block based rootfs are hotplugged to
+// the VM and other rootfs are bind mounted into the shared directory, so that
+// the following CreateContainer call for containerID finds them in place.
+func (s *Sandbox) PrewarmContainerImage(containerID string, rootFs RootFs) error {
+	if containerID == "" {
+		return vcTypes.ErrNeedContainerID
+	}
+
+	if s.state.State != types.StateRunning {
+		return vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running, impossible to prewarm container image")
+	}
+
+	if err := s.wakeUp(); err != nil {
+		return err
+	}
+
+	if _, ok := s.containers[containerID]; ok {
+		return fmt.Errorf("Container %s already exists in sandbox %s", containerID, s.id)
+	}
+
+	s.Logger().WithFields(logrus.Fields{
+		"container": containerID,
+		"source":    rootFs.Source,
+		"target":    rootFs.Target,
+	}).Info("Prewarming container image")
+
+	if !rootFs.Mounted {
+		return s.prewarmBlockRootfs(containerID, rootFs.Source)
+	}
+
+	if rootFs.Target == "" {
+		return fmt.Errorf("Container rootfs target cannot be empty")
+	}
+
+	return bindMountContainerRootfs(s.ctx, kataHostSharedDir(), s.id, containerID, rootFs.Target, false)
+}
+
+func (s *Sandbox) prewarmBlockRootfs(containerID, devicePath string) error {
+	if devicePath == "" {
+		return fmt.Errorf("Container rootfs source cannot be empty")
+	}
+
+	agentCaps := s.agent.capabilities()
+	hypervisorCaps := s.hypervisor.capabilities()
+
+	if s.config.HypervisorConfig.DisableBlockDeviceUse ||
+		!agentCaps.IsBlockDeviceSupported() ||
+		!hypervisorCaps.IsBlockDeviceHotplugSupported() {
+		return fmt.Errorf("Block device hotplug is not supported by sandbox %s", s.id)
+	}
+
+	devicePath, err := filepath.EvalSymlinks(devicePath)
+	if err != nil {
+		return err
+	}
+
+	var stat unix.Stat_t
+	if err := unix.Stat(devicePath, &stat); err != nil {
+		return fmt.Errorf("stat %q failed: %v", devicePath, err)
+	}
+
+	if stat.Mode&unix.S_IFBLK != unix.S_IFBLK {
+		return fmt.Errorf("Container rootfs source %s is not a block device", devicePath)
+	}
+
+	// The device manager hands the same device over to the container
+	// once it gets created, as it is looked up by major/minor.
+	device, err := s.AddDevice(config.DeviceInfo{
+		HostPath:      devicePath,
+		ContainerPath: filepath.Join(kataGuestSharedDir(), containerID),
+		DevType:       "b",
+		Major:         int64(unix.Major(stat.Rdev)),
+		Minor:         int64(unix.Minor(stat.Rdev)),
+	})
+	if err != nil {
+		return err
+	}
+
+	if s.state.PrewarmedDevices == nil {
+		s.state.PrewarmedDevices = make(map[string]string)
+	}
+	s.state.PrewarmedDevices[containerID] = device.DeviceID()
+
+	return s.Save()
+}
+
+// releasePrewarmedDevice drops the reference PrewarmContainerImage holds on
+// the rootfs device of containerID. The device stays attached as long as the
+// container uses it, and is removed from the VM otherwise.
+func (s *Sandbox) releasePrewarmedDevice(containerID string) error {
+	id, ok := s.state.PrewarmedDevices[containerID]
+	if !ok {
+		return nil
+	}
+
+	delete(s.state.PrewarmedDevices, containerID)
+
+	if s.devManager.GetDeviceByID(id) == nil {
+		return nil
+	}
+
+	if err := s.devManager.DetachDevice(id, s); err != nil {
+		return err
+	}
+
+	if s.devManager.IsDeviceAttached(id) {
+		return nil
+	}
+
+	return s.devManager.RemoveDevice(id)
+}
+
+// releasePrewarmedDevices releases the devices of the prewarmed images that
+// no container got created from.
+func (s *Sandbox) releasePrewarmedDevices() error {
+	for containerID := range s.state.PrewarmedDevices {
+		if err := s.releasePrewarmedDevice(containerID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // updateResources will calculate the resources required for the virtual machine, and
 // adjust the virtual machine sizing accordingly. For a given sandbox, it will calculate the
 // number of vCPUs required based on the sum of container requests, plus default CPUs for the VM.
@@ -1786,6 +2553,11 @@ func (s *Sandbox) updateResources() error {
 		return fmt.Errorf("sandbox config is nil")
 	}
 
+	if s.config.StaticResourceMgmt {
+		s.Logger().Debug("Static resource management, the sandbox is not resized")
+		return nil
+	}
+
 	sandboxVCPUs := s.calculateSandboxCPUs()
 	// Add default vcpus for sandbox
 	sandboxVCPUs += s.hypervisor.hypervisorConfig().NumVCPUs
@@ -1810,6 +2582,11 @@ func (s *Sandbox) updateResources() error {
 	}
 	s.Logger().Debugf("Sandbox CPUs: %d", newCPUs)
 
+	if s.config.StaticMemoryMgmt {
+		s.Logger().Debug("Static memory management, the sandbox memory is not resized")
+		return nil
+	}
+
 	// Update Memory
 	s.Logger().WithField("memory-sandbox-size-byte", sandboxMemoryByte).Debugf("Request to hypervisor to update memory")
 	newMemory, updatedMemoryDevice, err := s.hypervisor.resizeMemory(uint32(sandboxMemoryByte>>utils.MibToBytesShift), s.state.GuestMemoryBlockSizeMB, s.state.GuestMemoryHotplugProbe)
@@ -1876,7 +2653,21 @@ func (s *Sandbox) GetHypervisorType() string {
 //  1) get the v1constraints cgroup associated with the stored cgroup path
 //  2) (re-)add hypervisor vCPU threads to the appropriate cgroup
 //  3) If we are managing sandbox cgroup, update the v1constraints cgroup size
+//  4) isolate the vCPU threads, whose affinity is reset by the cgroup moves
+//  5) pin and reprioritize the other threads of the hypervisor
 func (s *Sandbox) cgroupsUpdate() error {
+	if err := s.updateCgroups(); err != nil {
+		return err
+	}
+
+	if err := s.isolateVCPUs(); err != nil {
+		return err
+	}
+
+	return s.tuneEmulatorThreads()
+}
+
+func (s *Sandbox) updateCgroups() error {
 
 	// If Kata is configured for SandboxCgroupOnly, the VMM and its processes are already
 	// in the Kata sandbox cgroup (inherited). No need to move threads/processes, and we should
@@ -1982,6 +2773,23 @@ func (s *Sandbox) constrainHypervisor(cgroup cgroups.Cgroup) error {
 		return fmt.Errorf("Invalid hypervisor PID: %+v", pids)
 	}
 
+	if vccgroups.IsCgroupV2() {
+		// cgroups v2 doesn't allow to spread the threads of a process across
+		// domain cgroups, hence the whole VMM is placed into the constrained cgroup.
+		s.Logger().Info("cgroups v2: placing the hypervisor into the constrained cgroup")
+		for _, pid := range pids {
+			if pid <= 0 {
+				s.Logger().Warnf("Invalid hypervisor pid: %d", pid)
+				continue
+			}
+
+			if err := cgroup.Add(cgroups.Process{Pid: pid}); err != nil {
+				return fmt.Errorf("Could not add hypervisor PID %d to cgroup: %v", pid, err)
+			}
+		}
+		return nil
+	}
+
 	// VMM threads are only placed into the constrained cgroup if SandboxCgroupOnly is being set.
 	// This is the "correct" behavior, but if the parent cgroup isn't set up correctly to take
 	// Kata/VMM into account, Kata may fail to boot due to being overconstrained.
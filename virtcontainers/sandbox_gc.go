@@ -0,0 +1,273 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
+)
+
+// variables rather than consts to allow tests to modify them
+var (
+	// orphanGracePeriod is the age under which a VM tree is never
+	// collected, the hypervisor of a sandbox being created may not be
+	// running yet.
+	orphanGracePeriod = 5 * time.Minute
+
+	mountInfoPath = "/proc/self/mountinfo"
+)
+
+// mountInfoUnescaper decodes the characters mountinfo escapes in paths
+var mountInfoUnescaper = strings.NewReplacer(`\040`, " ", `\011`, "\t", `\012`, "\n", `\134`, `\`)
+
+// hypervisorRunning returns whether the hypervisor recorded in the store of
+// the sandbox sandboxID may still be running. A sandbox whose hypervisor
+// wasn't recorded yet, or whose store can't be read, is considered running.
+func hypervisorRunning(store persistapi.PersistDriver, sandboxID string) bool {
+	ss, _, err := store.FromDisk(sandboxID)
+	if err != nil || ss.HypervisorState.Pid <= 0 {
+		return true
+	}
+
+	startTime, err := processStartTime(ss.HypervisorState.Pid)
+	if err != nil {
+		return false
+	}
+
+	// the stores of the older runtimes have no start time
+	return ss.HypervisorState.PidStartTime == 0 || startTime == ss.HypervisorState.PidStartTime
+}
+
+// vmTrees returns the VM directories and the temporary trees holding the
+// jails, in the default jailer root or in jailerChrootBase if not empty, of
+// the sandboxes which were deleted or whose hypervisor exited, and of the
+// cached VMs.
+func vmTrees(jailerChrootBase string) ([]string, error) {
+	store, err := persist.GetDriver()
+	if err != nil {
+		return nil, err
+	}
+
+	var sandboxIDs []string
+	if entries, err := ioutil.ReadDir(store.RunStoragePath()); err == nil {
+		for _, e := range entries {
+			sandboxIDs = append(sandboxIDs, e.Name())
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	vmRoot := store.RunVMStoragePath()
+	roots := []string{vmRoot, sandboxTmpRoot}
+
+	// the jails are in a directory named after the hypervisor binary
+	jailRoots := make(map[string]bool)
+	if jailerChrootBase != "" {
+		dirs, err := filepath.Glob(filepath.Join(jailerChrootBase, "*"))
+		if err != nil {
+			return nil, err
+		}
+
+		for _, d := range dirs {
+			jailRoots[d] = true
+			roots = append(roots, d)
+		}
+	}
+
+	// the trees are named after the sandbox ID shortened to the budget
+	// of their paths
+	sandboxOf := func(root, name string) string {
+		for _, id := range sandboxIDs {
+			switch {
+			case id == name,
+				root == vmRoot && name == vmStorageID(vmRoot, id),
+				root == sandboxTmpRoot && name == shortenPathID(id, sandboxTmpIDLen),
+				jailRoots[root] && name == fcJailerID(id, root):
+				return id
+			}
+		}
+		return ""
+	}
+
+	var trees []string
+	for _, root := range roots {
+		entries, err := ioutil.ReadDir(root)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+
+			if id := sandboxOf(root, e.Name()); id != "" && hypervisorRunning(store, id) {
+				continue
+			}
+
+			path := filepath.Join(root, e.Name())
+
+			// the VM templates are file systems mounted in the VM
+			// storage, they outlive their VM
+			if root == vmRoot {
+				if mounted, err := isMountPoint(path); err != nil || mounted {
+					continue
+				}
+			}
+
+			trees = append(trees, path)
+		}
+	}
+
+	return trees, nil
+}
+
+// treesInUse returns the trees a process refers to through its root, its
+// working directory or its command line, which have the paths of the VM
+// sockets.
+func treesInUse(trees []string) map[string]bool {
+	inUse := make(map[string]bool)
+
+	procs, _ := filepath.Glob(filepath.Join(procRoot, "[0-9]*"))
+	for _, p := range procs {
+		var refs []string
+
+		for _, link := range []string{"root", "cwd"} {
+			if target, err := os.Readlink(filepath.Join(p, link)); err == nil {
+				refs = append(refs, target+"/")
+			}
+		}
+
+		if cmdline, err := ioutil.ReadFile(filepath.Join(p, "cmdline")); err == nil {
+			refs = append(refs, strings.Split(string(cmdline), "\x00")...)
+		}
+
+		for _, tree := range trees {
+			for _, ref := range refs {
+				if strings.Contains(ref, tree+"/") {
+					inUse[tree] = true
+					break
+				}
+			}
+		}
+	}
+
+	return inUse
+}
+
+// treeMounts returns the mount points in dir, the deepest first
+func treeMounts(dir string) ([]string, error) {
+	f, err := os.Open(mountInfoPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []string
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		mp := mountInfoUnescaper.Replace(fields[4])
+		if mp == dir || strings.HasPrefix(mp, dir+"/") {
+			mounts = append(mounts, mp)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(mounts, func(i, j int) bool {
+		return len(mounts[i]) > len(mounts[j])
+	})
+
+	return mounts, nil
+}
+
+// removeOrphanTree unmounts what a crashed sandbox left mounted in dir and
+// removes it.
+func removeOrphanTree(dir string) error {
+	mounts, err := treeMounts(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range mounts {
+		if err := syscall.Unmount(m, syscall.MNT_DETACH); err != nil && err != syscall.EINVAL {
+			return fmt.Errorf("Could not unmount %s: %v", m, err)
+		}
+	}
+
+	// never remove through a mount, the container files may be bind
+	// mounted in the jail
+	if mounts, err = treeMounts(dir); err != nil {
+		return err
+	}
+	if len(mounts) != 0 {
+		return fmt.Errorf("%s is still mounted", mounts[0])
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// cleanupOrphans removes the VM trees of no running sandbox, see vmTrees(),
+// that no process refers to anymore and returns their paths. A tree which
+// can't be removed doesn't stop the collection, it's retried next time.
+func cleanupOrphans(jailerChrootBase string) ([]string, error) {
+	trees, err := vmTrees(jailerChrootBase)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	for _, tree := range trees {
+		fi, err := os.Stat(tree)
+		if err == nil && time.Since(fi.ModTime()) >= orphanGracePeriod {
+			candidates = append(candidates, tree)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	inUse := treesInUse(candidates)
+
+	var removed []string
+	for _, tree := range candidates {
+		if inUse[tree] {
+			continue
+		}
+
+		if err := removeOrphanTree(tree); err != nil {
+			virtLog.WithError(err).WithField("path", tree).Warn("Could not remove orphaned VM tree")
+			continue
+		}
+
+		virtLog.WithField("path", tree).Info("Removed orphaned VM tree")
+		removed = append(removed, tree)
+	}
+
+	return removed, nil
+}
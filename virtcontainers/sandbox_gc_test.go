@@ -0,0 +1,154 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kata-containers/runtime/virtcontainers/persist/fs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTreeMounts(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedMountInfoPath := mountInfoPath
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	defer func() {
+		mountInfoPath = savedMountInfoPath
+	}()
+
+	assert.NoError(ioutil.WriteFile(mountInfoPath, []byte(`22 1 0:21 / /run rw,nosuid shared:5 - tmpfs tmpfs rw
+40 22 0:35 / /run/vc/tmp/abc rw shared:20 - tmpfs tmpfs rw,size=64m
+41 40 8:1 /var/lib/vmlinux /run/vc/tmp/abc/firecracker/abc/root/vmlinux rw shared:1 - ext4 /dev/sda1 rw
+42 40 8:1 /var/lib/with\040space /run/vc/tmp/abc/firecracker/abc/root/with\040space rw shared:1 - ext4 /dev/sda1 rw
+43 22 0:36 / /run/vc/tmp/abcdef rw shared:21 - tmpfs tmpfs rw
+`), 0644))
+
+	mounts, err := treeMounts("/run/vc/tmp/abc")
+	assert.NoError(err)
+	assert.Equal([]string{
+		"/run/vc/tmp/abc/firecracker/abc/root/with space",
+		"/run/vc/tmp/abc/firecracker/abc/root/vmlinux",
+		"/run/vc/tmp/abc",
+	}, mounts)
+
+	mounts, err = treeMounts("/run/vc/vm/abc")
+	assert.NoError(err)
+	assert.Empty(mounts)
+}
+
+func TestCleanupOrphans(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	defer os.RemoveAll(fs.MockRunVMStoragePath())
+	defer os.RemoveAll(fs.MockRunStoragePath())
+
+	savedProcRoot := procRoot
+	savedMountInfoPath := mountInfoPath
+	savedGracePeriod := orphanGracePeriod
+	procRoot = filepath.Join(dir, "proc")
+	mountInfoPath = filepath.Join(dir, "mountinfo")
+	orphanGracePeriod = time.Hour
+	defer func() {
+		procRoot = savedProcRoot
+		mountInfoPath = savedMountInfoPath
+		orphanGracePeriod = savedGracePeriod
+	}()
+
+	assert.NoError(ioutil.WriteFile(mountInfoPath, nil, 0644))
+
+	old := time.Now().Add(-2 * orphanGracePeriod)
+	mkTree := func(path string, mtime time.Time) {
+		assert.NoError(os.MkdirAll(filepath.Join(path, "root"), DirMode))
+		assert.NoError(os.Chtimes(path, mtime, mtime))
+	}
+
+	// the store of a sandbox whose hypervisor, pid 42 started at 42, is
+	// running or was
+	writeStore := func(id string, pid int) {
+		dir := filepath.Join(fs.MockRunStoragePath(), id)
+		assert.NoError(os.MkdirAll(dir, DirMode))
+		if pid > 0 {
+			state := fmt.Sprintf(`{"HypervisorState":{"Pid":%d,"PidStartTime":42}}`, pid)
+			assert.NoError(ioutil.WriteFile(filepath.Join(dir, "persist.json"), []byte(state), 0644))
+		}
+	}
+
+	crashedID := testSandboxID + "-crashed"
+	jailRoot := filepath.Join(dir, "jail", "firecracker")
+
+	orphan := filepath.Join(fs.MockRunVMStoragePath(), "orphan")
+	young := filepath.Join(fs.MockRunVMStoragePath(), "young")
+	running := filepath.Join(fs.MockRunVMStoragePath(), "running")
+	stored := filepath.Join(fs.MockRunVMStoragePath(), testSandboxID)
+	crashed := filepath.Join(fs.MockRunVMStoragePath(), crashedID)
+	jail := sandboxTmpPath("0123456789abcdef0123")
+	storedJail := sandboxTmpPath(testSandboxID)
+	crashedJail := filepath.Join(jailRoot, fcJailerID(crashedID, jailRoot))
+	storedChrootJail := filepath.Join(jailRoot, fcJailerID(testSandboxID, jailRoot))
+
+	mkTree(orphan, old)
+	mkTree(young, time.Now())
+	mkTree(running, old)
+	mkTree(stored, old)
+	mkTree(crashed, old)
+	mkTree(jail, old)
+	mkTree(storedJail, old)
+	mkTree(crashedJail, old)
+	mkTree(storedChrootJail, old)
+	writeStore(testSandboxID, 42)
+	writeStore(crashedID, 43)
+
+	// the hypervisor of the running tree
+	assert.NoError(os.MkdirAll(filepath.Join(procRoot, "41"), 0755))
+	assert.NoError(ioutil.WriteFile(filepath.Join(procRoot, "41", "cmdline"), []byte("qemu\x00-qmp\x00unix:"+running+"/qmp.sock,server,nowait\x00"), 0644))
+
+	// the hypervisor of the stored sandbox, pid 43 was reused
+	writeProcessCPUTime(t, 42, 0, 0)
+	writeProcessCPUTime(t, 43, 0, 0)
+	assert.NoError(ioutil.WriteFile(filepath.Join(procRoot, "43", "stat"), []byte("43 (sh) S 1 43 43 0 -1 0 0 0 0 0 0 0 0 0 20 0 1 0 4242 0\n"), 0644))
+
+	removed, err := cleanupOrphans(filepath.Dir(jailRoot))
+	assert.NoError(err)
+	assert.Equal([]string{crashed, orphan, jail, crashedJail}, removed)
+
+	for _, path := range []string{young, running, stored, storedJail, storedChrootJail} {
+		_, err := os.Stat(path)
+		assert.NoError(err, "path %s", path)
+	}
+
+	for _, path := range removed {
+		_, err := os.Stat(path)
+		assert.True(os.IsNotExist(err), "path %s", path)
+	}
+
+	// a store not written yet
+	mkTree(crashed, old)
+	assert.NoError(os.Remove(filepath.Join(fs.MockRunStoragePath(), crashedID, "persist.json")))
+	removed, err = cleanupOrphans("")
+	assert.NoError(err)
+	assert.Empty(removed)
+
+	// a tree still mounted after the unmount is kept
+	mkTree(orphan, old)
+	assert.NoError(ioutil.WriteFile(mountInfoPath, []byte("40 22 0:35 / "+orphan+"/root rw - tmpfs tmpfs rw\n"), 0644))
+	assert.Error(removeOrphanTree(orphan))
+	_, err = os.Stat(orphan)
+	assert.NoError(err)
+}
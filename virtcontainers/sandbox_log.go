@@ -0,0 +1,146 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	sandboxLogFormatText = "text"
+	sandboxLogFormatJSON = "json"
+)
+
+// CheckSandboxLogFormat checks the format of the sandbox log files is known,
+// the empty format being the text one.
+func CheckSandboxLogFormat(format string) error {
+	switch format {
+	case "", sandboxLogFormatText, sandboxLogFormatJSON:
+		return nil
+	}
+
+	return fmt.Errorf("Invalid sandbox log format %q, expected %q or %q", format, sandboxLogFormatText, sandboxLogFormatJSON)
+}
+
+// sandboxLogHook is a logrus hook writing the log entries of the runtime to
+// the log file of a sandbox. A runtime process serves a single sandbox, so
+// the entries of the hypervisor log fifo and of the guest console forwarded
+// to the runtime log are all about that sandbox.
+type sandboxLogHook struct {
+	sync.Mutex
+	file      *os.File
+	formatter logrus.Formatter
+}
+
+func newSandboxLogHook(path, format string) (*sandboxLogHook, error) {
+	if err := CheckSandboxLogFormat(format); err != nil {
+		return nil, err
+	}
+
+	var formatter logrus.Formatter = &logrus.TextFormatter{
+		DisableColors:   true,
+		TimestampFormat: time.RFC3339Nano,
+	}
+	if format == sandboxLogFormatJSON {
+		formatter = &logrus.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), DirMode); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sandboxLogHook{
+		file:      f,
+		formatter: formatter,
+	}, nil
+}
+
+func (h *sandboxLogHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+// Fire writes the entry to the sandbox log file, once the file is closed
+// the entries are dropped.
+func (h *sandboxLogHook) Fire(e *logrus.Entry) error {
+	data, err := h.formatter.Format(e)
+	if err != nil {
+		return err
+	}
+
+	h.Lock()
+	defer h.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+
+	_, err = h.file.Write(data)
+	return err
+}
+
+func (h *sandboxLogHook) close() error {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.file == nil {
+		return nil
+	}
+
+	err := h.file.Close()
+	h.file = nil
+
+	return err
+}
+
+// sandboxLogFile returns the path of the log file of the sandbox.
+func (s *Sandbox) sandboxLogFile() string {
+	return filepath.Join(s.config.SandboxLogDir, fmt.Sprintf("%s.log", s.id))
+}
+
+// startSandboxLog sends the runtime logs to the sandbox log file, when the
+// sandbox log directory is set. The file is appended to, it keeps the logs
+// of all the runtime processes of the sandbox.
+func (s *Sandbox) startSandboxLog() error {
+	if s.config.SandboxLogDir == "" || s.logHook != nil {
+		return nil
+	}
+
+	hook, err := newSandboxLogHook(s.sandboxLogFile(), s.config.SandboxLogFormat)
+	if err != nil {
+		return err
+	}
+
+	virtLog.Logger.AddHook(hook)
+	s.logHook = hook
+
+	return nil
+}
+
+// stopSandboxLog closes the sandbox log file, the file itself is kept for
+// the logs to be collected once the sandbox is gone.
+func (s *Sandbox) stopSandboxLog() {
+	if s.logHook == nil {
+		return
+	}
+
+	if err := s.logHook.close(); err != nil {
+		s.Logger().WithError(err).Warn("failed to close sandbox log file")
+	}
+	s.logHook = nil
+}
@@ -0,0 +1,79 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSandboxLogFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(CheckSandboxLogFormat(""))
+	assert.NoError(CheckSandboxLogFormat("text"))
+	assert.NoError(CheckSandboxLogFormat("json"))
+	assert.Error(CheckSandboxLogFormat("logfmt"))
+}
+
+func TestSandboxLog(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	savedLogger := virtLog
+	defer func() {
+		virtLog = savedLogger
+	}()
+	virtLog = logrus.NewEntry(logrus.New())
+	virtLog.Logger.Out = ioutil.Discard
+
+	s := &Sandbox{
+		id: testSandboxID,
+		config: &SandboxConfig{
+			SandboxLogDir:    filepath.Join(tmpdir, "sandboxes"),
+			SandboxLogFormat: "json",
+		},
+	}
+
+	assert.NoError(s.startSandboxLog())
+	assert.NotNil(s.logHook)
+
+	virtLog.WithField("vmconsole", "guest booted").Info("reading guest console")
+	s.stopSandboxLog()
+	assert.Nil(s.logHook)
+
+	// the entries logged once the sandbox is deleted are dropped
+	virtLog.Info("sandbox deleted")
+
+	data, err := ioutil.ReadFile(filepath.Join(tmpdir, "sandboxes", testSandboxID+".log"))
+	assert.NoError(err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Len(lines, 1)
+
+	var entry map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal("guest booted", entry["vmconsole"])
+	assert.Equal("reading guest console", entry["msg"])
+
+	// no file is written without log directory
+	s = &Sandbox{
+		id:     testSandboxID,
+		config: &SandboxConfig{},
+	}
+	assert.NoError(s.startSandboxLog())
+	assert.Nil(s.logHook)
+}
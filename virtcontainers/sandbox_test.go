@@ -247,6 +247,38 @@ func TestValidTransitionFailingOldStateMismatch(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestSandboxStateTransitionHistory(t *testing.T) {
+	assert := assert.New(t)
+
+	state := types.SandboxState{}
+	assert.Error(state.Revert())
+	assert.Error(state.Transition(types.StateRunning))
+	assert.Empty(state.Transitions)
+
+	assert.NoError(state.Transition(types.StateReady))
+	assert.NoError(state.Transition(types.StateRunning))
+	assert.Error(state.Transition(types.StateReady))
+	assert.Equal(types.StateRunning, state.State)
+
+	assert.NoError(state.Revert())
+	assert.Equal(types.StateReady, state.State)
+	// a revert can't be reverted
+	assert.Error(state.Revert())
+
+	assert.Len(state.Transitions, 3)
+	assert.Equal(types.StateTransition{From: types.StateRunning, To: types.StateReady, Reverted: true},
+		types.StateTransition{From: state.Transitions[2].From, To: state.Transitions[2].To, Reverted: state.Transitions[2].Reverted})
+	assert.False(state.Transitions[0].Time.IsZero())
+
+	// the history is bounded
+	for i := 0; i < 50; i++ {
+		assert.NoError(state.Transition(types.StateRunning))
+		assert.NoError(state.Transition(types.StateStopped))
+	}
+	assert.Len(state.Transitions, 32)
+	assert.Equal(types.StateStopped, state.Transitions[31].To)
+}
+
 func TestVolumesSetSuccessful(t *testing.T) {
 	volumes := &types.Volumes{}
 
@@ -515,8 +547,12 @@ func TestSandboxSetSandboxAndContainerState(t *testing.T) {
 	}
 
 	// revert sandbox state to allow it to be deleted
-	err = p.setSandboxState(initialSandboxState.State)
+	err = p.revertSandboxState()
 	assert.NoError(err)
+	assert.Equal(initialSandboxState.State, p.state.State)
+
+	// a sandbox can't go back to ready
+	assert.Error(p.setSandboxState(types.StateReady))
 
 	// clean up
 	err = p.Delete()
@@ -1383,6 +1419,54 @@ func TestPreAddDevice(t *testing.T) {
 		"ignoreMounts should contain nothing because it only contains a block device")
 }
 
+func TestReleasePrewarmedDevice(t *testing.T) {
+	assert := assert.New(t)
+
+	dm := manager.NewDeviceManager(config.VirtioBlock, false, "", nil)
+	sandbox := &Sandbox{
+		id:         testSandboxID,
+		hypervisor: &mockHypervisor{},
+		config: &SandboxConfig{
+			HypervisorConfig: HypervisorConfig{
+				BlockDeviceDriver: config.VirtioBlock,
+			},
+		},
+		devManager: dm,
+		ctx:        context.Background(),
+		state:      types.SandboxState{BlockIndexMap: make(map[int]struct{})},
+	}
+
+	deviceInfo := config.DeviceInfo{
+		HostPath:      "/dev/hda",
+		ContainerPath: "/dev/hda",
+		DevType:       "b",
+	}
+
+	dev, err := sandbox.AddDevice(deviceInfo)
+	assert.NoError(err)
+	sandbox.state.PrewarmedDevices = map[string]string{"100": dev.DeviceID()}
+
+	// the container took its own reference on the device
+	assert.NoError(dm.AttachDevice(dev.DeviceID(), sandbox))
+	assert.NoError(sandbox.releasePrewarmedDevice("100"))
+	assert.Empty(sandbox.state.PrewarmedDevices)
+	assert.True(dm.IsDeviceAttached(dev.DeviceID()))
+
+	// nothing is left to release
+	assert.NoError(sandbox.releasePrewarmedDevice("100"))
+	assert.NoError(dm.DetachDevice(dev.DeviceID(), sandbox))
+	assert.NoError(dm.RemoveDevice(dev.DeviceID()))
+
+	// no container got created from the prewarmed image
+	dev, err = sandbox.AddDevice(deviceInfo)
+	assert.NoError(err)
+	sandbox.state.PrewarmedDevices = map[string]string{"200": dev.DeviceID()}
+
+	assert.NoError(sandbox.releasePrewarmedDevices())
+	assert.Empty(sandbox.state.PrewarmedDevices)
+	assert.Nil(dm.GetDeviceByID(dev.DeviceID()))
+}
+
 func TestGetNetNs(t *testing.T) {
 	s := Sandbox{}
 
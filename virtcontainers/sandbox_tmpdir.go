@@ -0,0 +1,137 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/kata-containers/runtime/virtcontainers/pkg/rootless"
+)
+
+// sandboxTmpIDLen is the number of characters of the shortened sandbox ID
+// naming its temporary tree. Jailed VMMs create their unix sockets in
+// this tree, keep it short to stay within UNIX_PATH_MAX.
+const sandboxTmpIDLen = 16
+
+// sandboxTmpRoot is where the temporary trees of the sandboxes live.
+// Like the persist storage it's under /run, but it can't be handled by
+// the persist driver since jailed VMMs need exec permissions there.
+var sandboxTmpRoot = filepath.Join("/run", storagePathSuffix, "tmp")
+
+// sandboxTmpPath returns the single directory tree holding all the
+// temporary artifacts (fifos, sockets, configs) of a sandbox.
+func sandboxTmpPath(sandboxID string) string {
+	return filepath.Join(sandboxTmpRoot, shortenPathID(sandboxID, sandboxTmpIDLen))
+}
+
+// SandboxVMPath returns the directory holding the sockets, pid file and
+// logs of the VM of a sandbox, in its temporary tree. The VMs started
+// before the tree existed keep using their VM storage directory.
+func SandboxVMPath(vmStoragePath, id string) string {
+	legacy := VMStorageDir(vmStoragePath, id)
+	if _, err := os.Lstat(legacy); err == nil {
+		return legacy
+	}
+
+	return filepath.Join(sandboxTmpPath(id), "vm")
+}
+
+// createSandboxVMPath creates the VM directory of a sandbox and records
+// the sandbox ID in the tree it belongs to.
+func createSandboxVMPath(vmStoragePath, id string) (string, error) {
+	vmPath := SandboxVMPath(vmStoragePath, id)
+
+	if err := os.MkdirAll(vmPath, DirMode); err != nil {
+		return "", err
+	}
+
+	tree := sandboxTmpPath(id)
+	if filepath.Dir(vmPath) != tree {
+		tree = vmPath
+	}
+
+	if err := recordPathID(tree, id); err != nil {
+		return "", err
+	}
+
+	return vmPath, nil
+}
+
+// isMountPoint returns true if path is the root of a mounted file system
+func isMountPoint(path string) (bool, error) {
+	var st, parentSt syscall.Stat_t
+
+	if err := syscall.Stat(path, &st); err != nil {
+		return false, err
+	}
+
+	if err := syscall.Stat(filepath.Dir(path), &parentSt); err != nil {
+		return false, err
+	}
+
+	return st.Dev != parentSt.Dev, nil
+}
+
+// setupSandboxTmpDir creates the temporary tree of the sandbox. When
+// quota is not 0, the tree is backed by its own tmpfs of quota MiB.
+// It's called every time the sandbox is created or fetched, so an
+// already mounted tree is left untouched.
+func setupSandboxTmpDir(sandboxID string, quota uint32) error {
+	dir := sandboxTmpPath(sandboxID)
+
+	if err := os.MkdirAll(dir, DirMode); err != nil {
+		return err
+	}
+
+	if quota == 0 {
+		return recordPathID(dir, sandboxID)
+	}
+
+	if rootless.IsRootless() {
+		virtLog.WithField("sandbox", sandboxID).Warn("Sandbox temporary tree quota is ignored when running rootless")
+		return recordPathID(dir, sandboxID)
+	}
+
+	mounted, err := isMountPoint(dir)
+	if err != nil {
+		return err
+	}
+
+	if !mounted {
+		// No MS_NOEXEC, jailed VMMs are executed from this tree
+		options := fmt.Sprintf("size=%dm,mode=0%o", quota, DirMode.Perm())
+		if err := syscall.Mount("tmpfs", dir, "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, options); err != nil {
+			return fmt.Errorf("Could not mount sandbox temporary tree %s: %v", dir, err)
+		}
+	}
+
+	return recordPathID(dir, sandboxID)
+}
+
+// cleanupSandboxTmpDir removes the temporary tree of the sandbox and
+// whatever has been left in it.
+func cleanupSandboxTmpDir(sandboxID string) error {
+	dir := sandboxTmpPath(sandboxID)
+
+	mounted, err := isMountPoint(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	if mounted {
+		if err := syscall.Unmount(dir, syscall.MNT_DETACH); err != nil {
+			return fmt.Errorf("Could not unmount sandbox temporary tree %s: %v", dir, err)
+		}
+	}
+
+	return os.RemoveAll(dir)
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	ktu "github.com/kata-containers/runtime/pkg/katatestutils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandboxTmpPath(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(filepath.Join(sandboxTmpRoot, "abc"), sandboxTmpPath("abc"))
+
+	id := "0123456789abcdef0123456789abcdef"
+	path := sandboxTmpPath(id)
+	assert.Equal(sandboxTmpRoot, filepath.Dir(path))
+	assert.Len(filepath.Base(path), sandboxTmpIDLen)
+	assert.True(strings.HasPrefix(filepath.Base(path), id[:7]))
+
+	// the IDs sharing a prefix have their own tree
+	assert.NotEqual(path, sandboxTmpPath(id+"0"))
+}
+
+func TestSandboxVMPath(t *testing.T) {
+	assert := assert.New(t)
+
+	vmStoragePath, err := ioutil.TempDir("", "vm-storage")
+	assert.NoError(err)
+	defer os.RemoveAll(vmStoragePath)
+
+	id := "test-sandbox-vmpath"
+	defer cleanupSandboxTmpDir(id)
+
+	path, err := createSandboxVMPath(vmStoragePath, id)
+	assert.NoError(err)
+	assert.Equal(filepath.Join(sandboxTmpPath(id), "vm"), path)
+	assert.Equal(path, SandboxVMPath(vmStoragePath, id))
+
+	// the tree records the sandbox it belongs to
+	assert.Error(recordPathID(sandboxTmpPath(id), id+"-other"))
+
+	// the VMs started before the temporary tree keep their directory
+	legacy := VMStorageDir(vmStoragePath, id)
+	assert.NoError(os.MkdirAll(legacy, DirMode))
+	assert.Equal(legacy, SandboxVMPath(vmStoragePath, id))
+
+	path, err = createSandboxVMPath(vmStoragePath, id)
+	assert.NoError(err)
+	assert.Equal(legacy, path)
+}
+
+func TestSetupCleanupSandboxTmpDir(t *testing.T) {
+	assert := assert.New(t)
+
+	sid := "test-sandbox-tmpdir"
+	dir := sandboxTmpPath(sid)
+
+	assert.NoError(setupSandboxTmpDir(sid, 0))
+	_, err := os.Stat(dir)
+	assert.NoError(err)
+
+	mounted, err := isMountPoint(dir)
+	assert.NoError(err)
+	assert.False(mounted)
+
+	// calling it twice is fine
+	assert.NoError(setupSandboxTmpDir(sid, 0))
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "fifo"), []byte("data"), 0640))
+
+	assert.NoError(cleanupSandboxTmpDir(sid))
+	_, err = os.Stat(dir)
+	assert.True(os.IsNotExist(err))
+
+	// already removed
+	assert.NoError(cleanupSandboxTmpDir(sid))
+}
+
+func TestSandboxTmpDirQuota(t *testing.T) {
+	if tc.NotValid(ktu.NeedRoot()) {
+		t.Skip(testDisabledAsNonRoot)
+	}
+
+	assert := assert.New(t)
+
+	sid := "test-sandbox-tmpdir-quota"
+	dir := sandboxTmpPath(sid)
+
+	assert.NoError(setupSandboxTmpDir(sid, 1))
+	defer cleanupSandboxTmpDir(sid)
+
+	mounted, err := isMountPoint(dir)
+	assert.NoError(err)
+	assert.True(mounted)
+
+	// an already mounted tree is not mounted again
+	assert.NoError(setupSandboxTmpDir(sid, 1))
+
+	var st syscall.Statfs_t
+	assert.NoError(syscall.Statfs(dir, &st))
+	assert.Equal(uint64(1024*1024), st.Blocks*uint64(st.Bsize))
+
+	// the quota is enforced
+	err = ioutil.WriteFile(filepath.Join(dir, "big"), make([]byte, 2*1024*1024), 0640)
+	assert.Error(err)
+
+	assert.NoError(cleanupSandboxTmpDir(sid))
+	_, err = os.Stat(dir)
+	assert.True(os.IsNotExist(err))
+}
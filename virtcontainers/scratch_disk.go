@@ -0,0 +1,182 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+
+	"github.com/kata-containers/runtime/virtcontainers/device/config"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	scratchDiskCipher = "aes-xts-plain64"
+
+	// scratchDiskKeySize is the size in bytes of the random key of the
+	// scratch disk, AES-256 in XTS mode using two keys.
+	scratchDiskKeySize = 64
+
+	scratchDiskFstype = "ext4"
+)
+
+// scratchDiskRoot is where the backing files of the scratch disks live,
+// they are not kept under /run since it's usually memory backed.
+var scratchDiskRoot = filepath.Join("/var/lib", storagePathSuffix, "scratch")
+
+// runScratchDiskCommand runs the host command setting up a scratch disk,
+// stdin being fed to it. It's declared this way for mocking in unit tests.
+var runScratchDiskCommand = func(stdin io.Reader, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = stdin
+
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", name, err, bytes.TrimSpace(output))
+	}
+
+	return nil
+}
+
+// kataGuestScratchDir is where the scratch disk is mounted in the guest.
+func kataGuestScratchDir() string {
+	return filepath.Join(kataGuestSandboxDir(), "scratch")
+}
+
+func scratchDiskFile(sandboxID string) string {
+	return filepath.Join(scratchDiskRoot, sandboxID+".img")
+}
+
+// scratchDiskName is the name of the device mapper device of the scratch
+// disk, found in /dev/mapper.
+func scratchDiskName(sandboxID string) string {
+	return "kata-scratch-" + sandboxID
+}
+
+// openScratchDisk maps the backing file of the scratch disk to a dm-crypt
+// device keyed with a random key, which is given to cryptsetup through its
+// stdin and only lives in the host kernel afterwards.
+func openScratchDisk(file, name string) error {
+	key := make([]byte, scratchDiskKeySize)
+	defer func() {
+		for i := range key {
+			key[i] = 0
+		}
+	}()
+
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+
+	return runScratchDiskCommand(bytes.NewReader(key), "cryptsetup", "open",
+		"--type", "plain",
+		"--cipher", scratchDiskCipher,
+		"--key-size", strconv.Itoa(scratchDiskKeySize*8),
+		"--key-file", "-",
+		file, name)
+}
+
+// createScratchDisk creates the encrypted scratch disk of the sandbox and
+// hotplugs it to the VM, the agent mounting it at kataGuestScratchDir.
+func (s *Sandbox) createScratchDisk() (err error) {
+	if s.config.ScratchDiskSize == 0 {
+		return nil
+	}
+
+	agentCaps := s.agent.capabilities()
+	hypervisorCaps := s.hypervisor.capabilities()
+
+	if s.config.HypervisorConfig.DisableBlockDeviceUse ||
+		!agentCaps.IsBlockDeviceSupported() ||
+		!hypervisorCaps.IsBlockDeviceHotplugSupported() {
+		return fmt.Errorf("The scratch disk of sandbox %s requires block device hotplug", s.id)
+	}
+
+	if err := os.MkdirAll(scratchDiskRoot, DirMode); err != nil {
+		return err
+	}
+
+	file := scratchDiskFile(s.id)
+	name := scratchDiskName(s.id)
+
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			s.removeScratchDisk()
+		}
+	}()
+
+	// the file is sparse, the host only stores what the guest writes
+	err = f.Truncate(int64(s.config.ScratchDiskSize) << 20)
+	f.Close()
+	if err != nil {
+		return err
+	}
+
+	if err = openScratchDisk(file, name); err != nil {
+		return err
+	}
+
+	devicePath := filepath.Join("/dev/mapper", name)
+	if err = runScratchDiskCommand(nil, "mkfs."+scratchDiskFstype, "-q", "-F", devicePath); err != nil {
+		return err
+	}
+
+	var stat unix.Stat_t
+	if err = unix.Stat(devicePath, &stat); err != nil {
+		return fmt.Errorf("stat %q failed: %v", devicePath, err)
+	}
+
+	device, err := s.AddDevice(config.DeviceInfo{
+		HostPath:      devicePath,
+		ContainerPath: kataGuestScratchDir(),
+		DevType:       "b",
+		Major:         int64(unix.Major(stat.Rdev)),
+		Minor:         int64(unix.Minor(stat.Rdev)),
+	})
+	if err != nil {
+		return err
+	}
+
+	s.state.ScratchDeviceID = device.DeviceID()
+
+	s.Logger().WithField("size", s.config.ScratchDiskSize).Info("Scratch disk created")
+
+	return nil
+}
+
+// removeScratchDisk closes the dm-crypt device of the scratch disk, the key
+// going away with it, and removes its backing file. The VM is expected to
+// be stopped, so that the device is not in use anymore.
+func (s *Sandbox) removeScratchDisk() error {
+	if s.config.ScratchDiskSize == 0 {
+		return nil
+	}
+
+	name := scratchDiskName(s.id)
+
+	if _, err := os.Stat(filepath.Join("/dev/mapper", name)); err == nil {
+		if err := runScratchDiskCommand(nil, "cryptsetup", "close", name); err != nil {
+			return err
+		}
+	}
+
+	s.state.ScratchDeviceID = ""
+
+	if err := os.Remove(scratchDiskFile(s.id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
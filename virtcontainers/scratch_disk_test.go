@@ -0,0 +1,82 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenScratchDisk(t *testing.T) {
+	assert := assert.New(t)
+
+	var key []byte
+	var cmd []string
+
+	savedRunScratchDiskCommand := runScratchDiskCommand
+	runScratchDiskCommand = func(stdin io.Reader, name string, args ...string) (err error) {
+		key, err = ioutil.ReadAll(stdin)
+		cmd = append([]string{name}, args...)
+		return err
+	}
+	defer func() {
+		runScratchDiskCommand = savedRunScratchDiskCommand
+	}()
+
+	assert.NoError(openScratchDisk("/var/lib/vc/scratch/foo.img", "kata-scratch-foo"))
+	assert.Len(key, scratchDiskKeySize)
+	assert.NotEqual(make([]byte, scratchDiskKeySize), key)
+	assert.Equal([]string{"cryptsetup", "open",
+		"--type", "plain",
+		"--cipher", "aes-xts-plain64",
+		"--key-size", "512",
+		"--key-file", "-",
+		"/var/lib/vc/scratch/foo.img", "kata-scratch-foo"}, cmd)
+}
+
+func TestCreateScratchDisk(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	savedScratchDiskRoot := scratchDiskRoot
+	scratchDiskRoot = tmpdir
+	defer func() {
+		scratchDiskRoot = savedScratchDiskRoot
+	}()
+
+	s := &Sandbox{
+		id:         testSandboxID,
+		agent:      &kataAgent{},
+		hypervisor: &mockHypervisor{},
+		config:     &SandboxConfig{},
+	}
+
+	// no scratch disk by default
+	assert.NoError(s.createScratchDisk())
+	assert.NoError(s.removeScratchDisk())
+	assert.Empty(s.state.ScratchDeviceID)
+
+	// the disk is hotplugged to the VM
+	s.config.ScratchDiskSize = 64
+	assert.Error(s.createScratchDisk())
+
+	file := filepath.Join(tmpdir, testSandboxID+".img")
+	assert.NoError(ioutil.WriteFile(file, nil, 0600))
+	s.state.ScratchDeviceID = "scratch"
+
+	assert.NoError(s.removeScratchDisk())
+	assert.Empty(s.state.ScratchDeviceID)
+	_, err = os.Stat(file)
+	assert.True(os.IsNotExist(err))
+}
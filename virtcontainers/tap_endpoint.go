@@ -83,11 +83,17 @@ func (endpoint *TapEndpoint) Detach(netNsCreated bool, netNsPath string) error {
 // HotAttach for the tap endpoint uses hot plug device
 func (endpoint *TapEndpoint) HotAttach(h hypervisor) error {
 	networkLogger().Info("Hot attaching tap endpoint")
-	if err := tapNetwork(endpoint, h.hypervisorConfig().NumVCPUs, h.hypervisorConfig().DisableVhostNet); err != nil {
+	if err := tapNetwork(endpoint, hotplugNetQueues(h), h.hypervisorConfig().DisableVhostNet); err != nil {
 		networkLogger().WithError(err).Error("Error bridging tap ep")
 		return err
 	}
 
+	hconf := h.hypervisorConfig()
+	if err := setTapOffloads(&endpoint.TapInterface, hconf.disabledNetOffloads(endpoint.TapInterface.Name)); err != nil {
+		networkLogger().WithError(err).Error("Error setting tap ep offloads")
+		return err
+	}
+
 	if _, err := h.hotplugAddDevice(endpoint, netDev); err != nil {
 		networkLogger().WithError(err).Error("Error attach tap ep")
 		return err
@@ -134,20 +140,20 @@ func createTapNetworkEndpoint(idx int, ifName string) (*TapEndpoint, error) {
 	return endpoint, nil
 }
 
-func tapNetwork(endpoint *TapEndpoint, numCPUs uint32, disableVhostNet bool) error {
+func tapNetwork(endpoint *TapEndpoint, queues int, disableVhostNet bool) error {
 	netHandle, err := netlink.NewHandle()
 	if err != nil {
 		return err
 	}
 	defer netHandle.Delete()
 
-	tapLink, fds, err := createLink(netHandle, endpoint.TapInterface.TAPIface.Name, &netlink.Tuntap{}, int(numCPUs))
+	tapLink, fds, err := createLink(netHandle, endpoint.TapInterface.TAPIface.Name, &netlink.Tuntap{}, queues)
 	if err != nil {
 		return fmt.Errorf("Could not create TAP interface: %s", err)
 	}
 	endpoint.TapInterface.VMFds = fds
 	if !disableVhostNet {
-		vhostFds, err := createVhostFds(int(numCPUs))
+		vhostFds, err := createVhostFds(queues)
 		if err != nil {
 			return fmt.Errorf("Could not setup vhost fds %s : %s", endpoint.TapInterface.Name, err)
 		}
@@ -0,0 +1,130 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TimeSyncStats describes the synchronizations of the guest clock with the
+// host clock.
+type TimeSyncStats struct {
+	// Syncs and Failures count the synchronizations done and failed.
+	Syncs    uint64
+	Failures uint64
+
+	// LastSync is the host time the guest clock was last set to.
+	LastSync time.Time
+
+	// MaxOffset is the round trip of the last synchronization request,
+	// the guest clock was set with an offset smaller than it.
+	MaxOffset time.Duration
+}
+
+// timeSync periodically sets the guest clock to the host clock through the
+// agent. The guest clock drifts when the vCPUs are not scheduled for a while,
+// e.g. when the VM is paused or the host is overcommitted.
+type timeSync struct {
+	sync.Mutex
+
+	sandbox  *Sandbox
+	interval time.Duration
+	stats    TimeSyncStats
+	stopCh   chan struct{}
+	wg       sync.WaitGroup
+}
+
+func newTimeSync(s *Sandbox, interval time.Duration) *timeSync {
+	return &timeSync{
+		sandbox:  s,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+func (t *timeSync) start() {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+
+		tick := time.NewTicker(t.interval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-t.stopCh:
+				return
+			case <-tick.C:
+				t.syncTime()
+			}
+		}
+	}()
+}
+
+func (t *timeSync) stop() {
+	close(t.stopCh)
+	t.wg.Wait()
+}
+
+func (t *timeSync) syncTime() {
+	// the guest clock is synced when the VM is resumed
+	if t.sandbox.idlePaused() {
+		return
+	}
+
+	now := time.Now()
+	err := t.sandbox.agent.setGuestDateTime(now)
+	rtt := time.Since(now)
+
+	t.Lock()
+	defer t.Unlock()
+
+	if err != nil {
+		t.stats.Failures++
+		t.sandbox.Logger().WithError(err).Warn("Could not sync guest time")
+		return
+	}
+
+	t.stats.Syncs++
+	t.stats.LastSync = now
+	t.stats.MaxOffset = rtt
+
+	t.sandbox.Logger().WithFields(logrus.Fields{
+		"time":       now,
+		"max-offset": rtt,
+	}).Debug("Guest time synced")
+}
+
+func (t *timeSync) getStats() TimeSyncStats {
+	t.Lock()
+	defer t.Unlock()
+
+	return t.stats
+}
+
+// startTimeSync syncs the guest clock every GuestTimeSyncInterval seconds
+// until the VM is stopped, the sync only happens while the process managing
+// the sandbox runs.
+func (s *Sandbox) startTimeSync() {
+	if s.config.GuestTimeSyncInterval == 0 || s.timeSync != nil {
+		return
+	}
+
+	s.timeSync = newTimeSync(s, time.Duration(s.config.GuestTimeSyncInterval)*time.Second)
+	s.timeSync.start()
+}
+
+func (s *Sandbox) stopTimeSync() {
+	if s.timeSync == nil {
+		return
+	}
+
+	s.timeSync.stop()
+	s.timeSync = nil
+}
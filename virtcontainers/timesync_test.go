@@ -0,0 +1,62 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type timeSyncAgent struct {
+	noopAgent
+	err   error
+	times []time.Time
+}
+
+func (a *timeSyncAgent) setGuestDateTime(tv time.Time) error {
+	a.times = append(a.times, tv)
+	return a.err
+}
+
+func TestTimeSync(t *testing.T) {
+	assert := assert.New(t)
+
+	agent := &timeSyncAgent{}
+	s := &Sandbox{
+		id:     testSandboxID,
+		agent:  agent,
+		config: &SandboxConfig{},
+	}
+
+	// disabled by default
+	s.startTimeSync()
+	assert.Nil(s.timeSync)
+	s.stopTimeSync()
+
+	ts := newTimeSync(s, time.Second)
+	ts.syncTime()
+	stats := ts.getStats()
+	assert.Equal(uint64(1), stats.Syncs)
+	assert.Equal(uint64(0), stats.Failures)
+	assert.Equal(agent.times[0], stats.LastSync)
+
+	agent.err = errors.New("agent is gone")
+	ts.syncTime()
+	stats = ts.getStats()
+	assert.Equal(uint64(1), stats.Syncs)
+	assert.Equal(uint64(1), stats.Failures)
+	assert.Equal(agent.times[0], stats.LastSync)
+
+	s.config.GuestTimeSyncInterval = 1
+	s.startTimeSync()
+	assert.NotNil(s.timeSync)
+	assert.Equal(time.Second, s.timeSync.interval)
+	s.stopTimeSync()
+	assert.Nil(s.timeSync)
+}
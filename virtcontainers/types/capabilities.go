@@ -10,6 +10,13 @@ const (
 	blockDeviceHotplugSupport
 	multiQueueSupport
 	fsSharingSupported
+	memoryHotplugSupport
+	cpuHotplugSupport
+	vfioHotplugSupport
+	vmSnapshotSupport
+	virtioFSSupport
+	virtio9PSupport
+	vmPauseSupport
 )
 
 // Capabilities describe a virtcontainers hypervisor capabilities
@@ -57,3 +64,77 @@ func (caps *Capabilities) IsFsSharingSupported() bool {
 func (caps *Capabilities) SetFsSharingSupport() {
 	caps.flags |= fsSharingSupported
 }
+
+// IsMemoryHotplugSupported tells if an hypervisor supports resizing the VM memory.
+func (caps *Capabilities) IsMemoryHotplugSupported() bool {
+	return caps.flags&memoryHotplugSupport != 0
+}
+
+// SetMemoryHotplugSupport sets the memory hotplugging capability to true.
+func (caps *Capabilities) SetMemoryHotplugSupport() {
+	caps.flags |= memoryHotplugSupport
+}
+
+// IsCPUHotplugSupported tells if an hypervisor supports resizing the VM vCPUs.
+func (caps *Capabilities) IsCPUHotplugSupported() bool {
+	return caps.flags&cpuHotplugSupport != 0
+}
+
+// SetCPUHotplugSupport sets the vCPU hotplugging capability to true.
+func (caps *Capabilities) SetCPUHotplugSupport() {
+	caps.flags |= cpuHotplugSupport
+}
+
+// IsVFIOHotplugSupported tells if an hypervisor supports hotplugging VFIO devices.
+func (caps *Capabilities) IsVFIOHotplugSupported() bool {
+	return caps.flags&vfioHotplugSupport != 0
+}
+
+// SetVFIOHotplugSupport sets the VFIO device hotplugging capability to true.
+func (caps *Capabilities) SetVFIOHotplugSupport() {
+	caps.flags |= vfioHotplugSupport
+}
+
+// IsVMSnapshotSupported tells if an hypervisor supports saving the VM state,
+// as the VM templates do.
+func (caps *Capabilities) IsVMSnapshotSupported() bool {
+	return caps.flags&vmSnapshotSupport != 0
+}
+
+// SetVMSnapshotSupport sets the VM snapshot capability to true.
+func (caps *Capabilities) SetVMSnapshotSupport() {
+	caps.flags |= vmSnapshotSupport
+}
+
+// IsVirtioFSSupported tells if an hypervisor supports sharing the host
+// filesystem through virtio-fs.
+func (caps *Capabilities) IsVirtioFSSupported() bool {
+	return caps.flags&virtioFSSupport != 0
+}
+
+// SetVirtioFSSupport sets the virtio-fs capability to true.
+func (caps *Capabilities) SetVirtioFSSupport() {
+	caps.flags |= virtioFSSupport
+}
+
+// IsVirtio9PSupported tells if an hypervisor supports sharing the host
+// filesystem through virtio-9p.
+func (caps *Capabilities) IsVirtio9PSupported() bool {
+	return caps.flags&virtio9PSupport != 0
+}
+
+// SetVirtio9PSupport sets the virtio-9p capability to true.
+func (caps *Capabilities) SetVirtio9PSupport() {
+	caps.flags |= virtio9PSupport
+}
+
+// IsVMPauseSupported tells if an hypervisor supports pausing and resuming
+// the VM.
+func (caps *Capabilities) IsVMPauseSupported() bool {
+	return caps.flags&vmPauseSupport != 0
+}
+
+// SetVMPauseSupport sets the VM pause capability to true.
+func (caps *Capabilities) SetVMPauseSupport() {
+	caps.flags |= vmPauseSupport
+}
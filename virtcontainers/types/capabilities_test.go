@@ -34,3 +34,55 @@ func TestFsSharingCapability(t *testing.T) {
 	caps.SetFsSharingSupport()
 	assert.True(t, caps.IsFsSharingSupported())
 }
+
+func TestResizeCapabilities(t *testing.T) {
+	var caps Capabilities
+
+	assert.False(t, caps.IsMemoryHotplugSupported())
+	assert.False(t, caps.IsCPUHotplugSupported())
+	caps.SetMemoryHotplugSupport()
+	caps.SetCPUHotplugSupport()
+	assert.True(t, caps.IsMemoryHotplugSupported())
+	assert.True(t, caps.IsCPUHotplugSupported())
+}
+
+func TestVFIOHotplugCapability(t *testing.T) {
+	var caps Capabilities
+
+	assert.False(t, caps.IsVFIOHotplugSupported())
+	caps.SetVFIOHotplugSupport()
+	assert.True(t, caps.IsVFIOHotplugSupported())
+}
+
+func TestVMSnapshotCapability(t *testing.T) {
+	var caps Capabilities
+
+	assert.False(t, caps.IsVMSnapshotSupported())
+	caps.SetVMSnapshotSupport()
+	assert.True(t, caps.IsVMSnapshotSupported())
+}
+
+func TestVirtioFSCapability(t *testing.T) {
+	var caps Capabilities
+
+	assert.False(t, caps.IsVirtioFSSupported())
+	caps.SetVirtioFSSupport()
+	assert.True(t, caps.IsVirtioFSSupported())
+	assert.False(t, caps.IsFsSharingSupported())
+}
+
+func TestVirtio9PCapability(t *testing.T) {
+	var caps Capabilities
+
+	assert.False(t, caps.IsVirtio9PSupported())
+	caps.SetVirtio9PSupport()
+	assert.True(t, caps.IsVirtio9PSupported())
+}
+
+func TestVMPauseCapability(t *testing.T) {
+	var caps Capabilities
+
+	assert.False(t, caps.IsVMPauseSupported())
+	caps.SetVMPauseSupport()
+	assert.True(t, caps.IsVMPauseSupported())
+}
@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/opencontainers/runtime-spec/specs-go"
 )
@@ -30,6 +31,10 @@ const (
 	StateStopped StateString = "stopped"
 )
 
+// maxStateTransitions is the number of transitions kept in the history of
+// a sandbox state.
+const maxStateTransitions = 32
+
 const (
 	HybridVSockScheme = "hvsock"
 	VSockScheme       = "vsock"
@@ -56,12 +61,69 @@ type SandboxState struct {
 	// with the value as the path.
 	CgroupPaths map[string]string `json:"cgroupPaths"`
 
+	// VSockChannels are the URLs of the guest vsock ports reachable from
+	// the host, keyed by channel name.
+	VSockChannels map[string]string `json:"vsockChannels,omitempty"`
+
+	// ScratchDeviceID is the ID of the block device of the encrypted
+	// scratch disk of the sandbox.
+	ScratchDeviceID string `json:"scratchDeviceID,omitempty"`
+
+	// PrewarmedDevices are the IDs of the rootfs devices of the prewarmed
+	// container images, keyed by container ID.
+	PrewarmedDevices map[string]string `json:"prewarmedDevices,omitempty"`
+
+	// BootTimeline records when the sandbox went through its boot steps.
+	BootTimeline BootTimeline `json:"bootTimeline"`
+
+	// Transitions are the last state transitions of the sandbox, the
+	// oldest first.
+	Transitions []StateTransition `json:"transitions,omitempty"`
+
+	// HypervisorExitReason and HypervisorExitTime are why and when the
+	// hypervisor process exited on its own, empty if it didn't.
+	HypervisorExitReason string    `json:"hypervisorExitReason,omitempty"`
+	HypervisorExitTime   time.Time `json:"hypervisorExitTime,omitempty"`
+
 	// PersistVersion indicates current storage api version.
 	// It's also known as ABI version of kata-runtime.
 	// Note: it won't be written to disk
 	PersistVersion uint `json:"-"`
 }
 
+// BootTimeline is the time of the boot steps of a sandbox, the steps
+// which didn't happen yet are zero.
+type BootTimeline struct {
+	// Create is when the sandbox creation was requested.
+	Create time.Time `json:"create"`
+
+	// VMMStart and VMMReady are when the VMM was spawned and when it
+	// reported the VM as started.
+	VMMStart time.Time `json:"vmmStart"`
+	VMMReady time.Time `json:"vmmReady"`
+
+	// GuestReady is when the agent first answered, once the guest kernel
+	// booted and started it.
+	GuestReady time.Time `json:"guestReady"`
+
+	// AgentReady is when the agent was done setting up the sandbox.
+	AgentReady time.Time `json:"agentReady"`
+
+	// FirstContainerStart is when the first container was started.
+	FirstContainerStart time.Time `json:"firstContainerStart"`
+}
+
+// StateTransition is a change of the state of a sandbox.
+type StateTransition struct {
+	From StateString `json:"from"`
+	To   StateString `json:"to"`
+	Time time.Time   `json:"time"`
+
+	// Reverted is set for the transitions undoing the previous one,
+	// when the operation it was made for failed.
+	Reverted bool `json:"reverted,omitempty"`
+}
+
 // Valid checks that the sandbox state is valid.
 func (state *SandboxState) Valid() bool {
 	return state.State.valid()
@@ -73,6 +135,44 @@ func (state *SandboxState) ValidTransition(oldState StateString, newState StateS
 	return state.State.validTransition(oldState, newState)
 }
 
+// Transition moves the sandbox to newState, recording the transition in
+// its history. It returns an error, leaving the state untouched, if
+// newState can't be reached from the current state.
+func (state *SandboxState) Transition(newState StateString) error {
+	if err := state.ValidTransition(state.State, newState); err != nil {
+		return err
+	}
+
+	state.record(StateTransition{From: state.State, To: newState})
+	return nil
+}
+
+// Revert undoes the last transition of the sandbox, which can't be a
+// revert itself.
+func (state *SandboxState) Revert() error {
+	if len(state.Transitions) == 0 {
+		return fmt.Errorf("No state transition to revert")
+	}
+
+	last := state.Transitions[len(state.Transitions)-1]
+	if last.Reverted || last.To != state.State {
+		return fmt.Errorf("Can not revert the transition from %v to %v", last.From, last.To)
+	}
+
+	state.record(StateTransition{From: state.State, To: last.From, Reverted: true})
+	return nil
+}
+
+func (state *SandboxState) record(transition StateTransition) {
+	transition.Time = time.Now()
+	state.State = transition.To
+
+	state.Transitions = append(state.Transitions, transition)
+	if len(state.Transitions) > maxStateTransitions {
+		state.Transitions = state.Transitions[len(state.Transitions)-maxStateTransitions:]
+	}
+}
+
 func (state *StateString) valid() bool {
 	for _, validState := range []StateString{StateReady, StateRunning, StatePaused, StateStopped} {
 		if *state == validState {
@@ -85,10 +185,16 @@ func (state *StateString) valid() bool {
 
 func (state *StateString) validTransition(oldState StateString, newState StateString) error {
 	if *state != oldState {
-		return fmt.Errorf("Invalid state %v (Expecting %v)", state, oldState)
+		return fmt.Errorf("Invalid state %q (Expecting %q)", *state, oldState)
 	}
 
 	switch *state {
+	case "":
+		// initial state
+		if newState == StateReady {
+			return nil
+		}
+
 	case StateReady:
 		if newState == StateRunning || newState == StateStopped {
 			return nil
@@ -110,8 +216,8 @@ func (state *StateString) validTransition(oldState StateString, newState StateSt
 		}
 	}
 
-	return fmt.Errorf("Can not move from %v to %v",
-		state, newState)
+	return fmt.Errorf("Can not move from %q to %q",
+		*state, newState)
 }
 
 // Volume is a shared volume between the host and the VM,
@@ -0,0 +1,340 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// VCPUIsolation selects how the vCPU threads of a sandbox are kept from
+// sharing the hyperthreads of a host core with other sandboxes.
+type VCPUIsolation string
+
+const (
+	// VCPUIsolationNone leaves the vCPU threads to the host scheduler.
+	VCPUIsolationNone VCPUIsolation = ""
+
+	// VCPUIsolationCoreSched tags the threads of the hypervisor with a
+	// core scheduling cookie, the host kernel then never runs them on a
+	// core next to threads with another cookie.
+	VCPUIsolationCoreSched VCPUIsolation = "core-sched"
+
+	// VCPUIsolationExclusiveCores pins the vCPU threads to host cores
+	// reserved for the sandbox, all their hyperthreads included.
+	VCPUIsolationExclusiveCores VCPUIsolation = "exclusive-cores"
+)
+
+// ParseVCPUIsolation returns the vCPU isolation named by value, an empty
+// value or "none" disabling it.
+func ParseVCPUIsolation(value string) (VCPUIsolation, error) {
+	switch i := VCPUIsolation(value); i {
+	case VCPUIsolationNone, "none":
+		return VCPUIsolationNone, nil
+	case VCPUIsolationCoreSched, VCPUIsolationExclusiveCores:
+		return i, nil
+	}
+
+	return "", fmt.Errorf("Unknown vCPU isolation %q, expecting %q or %q", value, VCPUIsolationCoreSched, VCPUIsolationExclusiveCores)
+}
+
+// prctl core scheduling commands, see Documentation/admin-guide/hw-vuln/core-scheduling.rst
+const (
+	prSchedCore       = 62
+	prSchedCoreCreate = 1
+	pidTypeTGID       = 1
+)
+
+var (
+	// sysCPUPath is where the host CPU topology is read from
+	sysCPUPath = "/sys/devices/system/cpu"
+
+	// vcpuCoresDir holds a file per host core reserved by a sandbox,
+	// shared by all the runtime instances of the host.
+	vcpuCoresDir = "/run/vc/vcpu-cores"
+)
+
+// isolateVCPUs applies the vCPU isolation of the sandbox to the current
+// threads of the hypervisor, it's run again after vCPUs are hotplugged.
+func (s *Sandbox) isolateVCPUs() error {
+	if s.config.VCPUIsolation == VCPUIsolationNone {
+		return nil
+	}
+
+	pids := s.hypervisor.getPids()
+	if len(pids) == 0 || pids[0] <= 0 {
+		return fmt.Errorf("Invalid hypervisor PID: %+v", pids)
+	}
+
+	switch s.config.VCPUIsolation {
+	case VCPUIsolationCoreSched:
+		return setCoreSchedCookie(pids[0])
+	case VCPUIsolationExclusiveCores:
+		return s.pinVCPUsToExclusiveCores(pids[0])
+	}
+
+	return fmt.Errorf("Unknown vCPU isolation %q", s.config.VCPUIsolation)
+}
+
+// setCoreSchedCookie gives a new cookie to all the threads of the process,
+// the threads it creates later inheriting it.
+func setCoreSchedCookie(pid int) error {
+	err := unix.Prctl(prSchedCore, prSchedCoreCreate, uintptr(pid), pidTypeTGID, 0)
+	switch err {
+	case nil:
+		return nil
+	case unix.ENODEV:
+		// no SMT, the cores are not shared
+		virtLog.Debug("No SMT on the host, the vCPU threads are not isolated")
+		return nil
+	case unix.EINVAL:
+		return fmt.Errorf("The host kernel doesn't support core scheduling, 5.14 or newer with CONFIG_SCHED_CORE is needed")
+	}
+
+	return fmt.Errorf("Could not set the core scheduling cookie of hypervisor PID %d: %v", pid, err)
+}
+
+// pinVCPUsToExclusiveCores reserves enough cores among the ones the
+// hypervisor can run on for its vCPU threads and pins them there. Only the
+// cores whose hyperthreads are all available are reserved.
+func (s *Sandbox) pinVCPUsToExclusiveCores(pid int) error {
+	tids, err := s.hypervisor.getThreadIDs()
+	if err != nil {
+		return fmt.Errorf("failed to get thread ids from hypervisor: %v", err)
+	}
+	if len(tids.vcpus) == 0 {
+		return nil
+	}
+
+	var allowed unix.CPUSet
+	if err := unix.SchedGetaffinity(pid, &allowed); err != nil {
+		return fmt.Errorf("Could not get the CPU affinity of hypervisor PID %d: %v", pid, err)
+	}
+
+	cores, err := hostCores(allowed)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(vcpuCoresDir, DirMode); err != nil {
+		return err
+	}
+
+	// the cores reserved before the vCPUs were hotplugged come first
+	var owned, free [][]int
+	for _, core := range cores {
+		if id, _, _ := readCoreOwner(vcpuCoreFile(core[0])); id == s.id {
+			owned = append(owned, core)
+		} else {
+			free = append(free, core)
+		}
+	}
+	cores = append(owned, free...)
+
+	var cpus unix.CPUSet
+	for _, core := range cores {
+		if cpus.Count() >= len(tids.vcpus) {
+			break
+		}
+
+		reserved, err := reserveCore(core[0], s.id, pid)
+		if err != nil {
+			return err
+		}
+
+		if reserved {
+			for _, cpu := range core {
+				cpus.Set(cpu)
+			}
+		}
+	}
+
+	if cpus.Count() < len(tids.vcpus) {
+		return fmt.Errorf("Not enough free host cores for the %d vCPUs of sandbox %s", len(tids.vcpus), s.id)
+	}
+
+	for _, tid := range tids.vcpus {
+		if err := unix.SchedSetaffinity(tid, &cpus); err != nil {
+			return fmt.Errorf("Could not pin vCPU thread %d: %v", tid, err)
+		}
+	}
+
+	s.Logger().WithField("vcpus", len(tids.vcpus)).WithField("cpus", cpus.Count()).Info("vCPU threads pinned to exclusive cores")
+
+	return nil
+}
+
+// hostCores returns the CPUs of the host cores all of whose hyperthreads
+// are allowed, ordered by their first CPU.
+func hostCores(allowed unix.CPUSet) ([][]int, error) {
+	seen := make(map[int]bool)
+	var cores [][]int
+
+	for cpu := 0; cpu < len(allowed)*64; cpu++ {
+		if !allowed.IsSet(cpu) || seen[cpu] {
+			continue
+		}
+
+		siblings, err := ioutil.ReadFile(filepath.Join(sysCPUPath, fmt.Sprintf("cpu%d", cpu), "topology", "thread_siblings_list"))
+		if err != nil {
+			return nil, err
+		}
+
+		core, err := parseCPUList(strings.TrimSpace(string(siblings)))
+		if err != nil {
+			return nil, err
+		}
+
+		whole := true
+		for _, sibling := range core {
+			seen[sibling] = true
+			whole = whole && allowed.IsSet(sibling)
+		}
+
+		if whole && len(core) > 0 {
+			cores = append(cores, core)
+		}
+	}
+
+	sort.Slice(cores, func(i, j int) bool { return cores[i][0] < cores[j][0] })
+
+	return cores, nil
+}
+
+// parseCPUList parses a kernel CPU list like "0-3,8", the CPUs are sorted.
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+
+	for _, r := range strings.Split(list, ",") {
+		if r == "" {
+			continue
+		}
+
+		bounds := strings.SplitN(r, "-", 2)
+		first, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, fmt.Errorf("Invalid CPU list %q: %v", list, err)
+		}
+
+		last := first
+		if len(bounds) == 2 {
+			if last, err = strconv.Atoi(bounds[1]); err != nil || last < first {
+				return nil, fmt.Errorf("Invalid CPU list %q", list)
+			}
+		}
+
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+
+	sort.Ints(cpus)
+
+	return cpus, nil
+}
+
+func vcpuCoreFile(core int) string {
+	return filepath.Join(vcpuCoresDir, strconv.Itoa(core))
+}
+
+// reserveCore reserves the core for the sandbox whose hypervisor is pid,
+// it returns false if another sandbox has it. The reservations of the
+// hypervisors which are gone are taken over.
+func reserveCore(core int, sandboxID string, pid int) (bool, error) {
+	file := vcpuCoreFile(core)
+	owner := fmt.Sprintf("%s %d\n", sandboxID, pid)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err == nil {
+			_, err = f.WriteString(owner)
+			f.Close()
+			return err == nil, err
+		}
+
+		if !os.IsExist(err) {
+			return false, err
+		}
+
+		id, ownerPid, err := readCoreOwner(file)
+		if err != nil {
+			return false, err
+		}
+
+		if id == sandboxID {
+			return true, nil
+		}
+
+		if ownerPid > 0 && syscall.Kill(ownerPid, 0) != syscall.ESRCH {
+			return false, nil
+		}
+
+		virtLog.WithField("core", core).WithField("sandbox", id).Info("Taking over the core of a stopped sandbox")
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return false, err
+		}
+	}
+
+	return false, nil
+}
+
+func readCoreOwner(file string) (string, int, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return "", 0, nil
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	var id string
+	var pid int
+	fmt.Sscanf(string(data), "%s %d", &id, &pid)
+
+	return id, pid, nil
+}
+
+// releaseVCPUCores drops the reservations of the cores of the sandbox.
+func (s *Sandbox) releaseVCPUCores() error {
+	if s.config.VCPUIsolation != VCPUIsolationExclusiveCores {
+		return nil
+	}
+
+	files, err := ioutil.ReadDir(vcpuCoresDir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		file := filepath.Join(vcpuCoresDir, f.Name())
+
+		id, _, err := readCoreOwner(file)
+		if err != nil {
+			return err
+		}
+
+		if id != s.id {
+			continue
+		}
+
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
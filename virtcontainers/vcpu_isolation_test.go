@@ -0,0 +1,191 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/sys/unix"
+)
+
+func TestParseVCPUIsolation(t *testing.T) {
+	assert := assert.New(t)
+
+	for value, expected := range map[string]VCPUIsolation{
+		"":                VCPUIsolationNone,
+		"none":            VCPUIsolationNone,
+		"core-sched":      VCPUIsolationCoreSched,
+		"exclusive-cores": VCPUIsolationExclusiveCores,
+	} {
+		isolation, err := ParseVCPUIsolation(value)
+		assert.NoError(err)
+		assert.Equal(expected, isolation)
+	}
+
+	_, err := ParseVCPUIsolation("nosmt")
+	assert.Error(err)
+}
+
+func TestParseCPUList(t *testing.T) {
+	assert := assert.New(t)
+
+	cpus, err := parseCPUList("8,0-2")
+	assert.NoError(err)
+	assert.Equal([]int{0, 1, 2, 8}, cpus)
+
+	cpus, err = parseCPUList("")
+	assert.NoError(err)
+	assert.Empty(cpus)
+
+	for _, list := range []string{"a", "3-1", "1-b"} {
+		_, err = parseCPUList(list)
+		assert.Error(err, list)
+	}
+}
+
+// mockCPUTopology creates a sysfs CPU tree of the host CPUs, siblings
+// returning the hyperthreads of the core of each CPU.
+func mockCPUTopology(t *testing.T, dir string, cpus int, siblings func(cpu int) string) {
+	for cpu := 0; cpu < cpus; cpu++ {
+		topology := filepath.Join(dir, fmt.Sprintf("cpu%d", cpu), "topology")
+		assert.NoError(t, os.MkdirAll(topology, DirMode))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(topology, "thread_siblings_list"), []byte(siblings(cpu)+"\n"), 0644))
+	}
+}
+
+func TestHostCores(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "cpu")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedSysCPUPath := sysCPUPath
+	sysCPUPath = dir
+	defer func() {
+		sysCPUPath = savedSysCPUPath
+	}()
+
+	// 4 cores of 2 hyperthreads, the siblings numbered like on x86
+	mockCPUTopology(t, dir, 8, func(cpu int) string {
+		return fmt.Sprintf("%d,%d", cpu%4, cpu%4+4)
+	})
+
+	var allowed unix.CPUSet
+	for _, cpu := range []int{0, 1, 2, 4, 5, 7} {
+		allowed.Set(cpu)
+	}
+
+	cores, err := hostCores(allowed)
+	assert.NoError(err)
+	assert.Equal([][]int{{0, 4}, {1, 5}}, cores)
+}
+
+func TestReserveCore(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "vcpu-cores")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedVCPUCoresDir := vcpuCoresDir
+	vcpuCoresDir = dir
+	defer func() {
+		vcpuCoresDir = savedVCPUCoresDir
+	}()
+
+	reserved, err := reserveCore(0, "foo", os.Getpid())
+	assert.NoError(err)
+	assert.True(reserved)
+
+	reserved, err = reserveCore(0, "foo", os.Getpid())
+	assert.NoError(err)
+	assert.True(reserved)
+
+	// the core of a running hypervisor is kept
+	reserved, err = reserveCore(0, "bar", os.Getpid())
+	assert.NoError(err)
+	assert.False(reserved)
+
+	// the core of a hypervisor which is gone is taken over
+	assert.NoError(ioutil.WriteFile(vcpuCoreFile(1), []byte("baz 999999999\n"), 0600))
+	reserved, err = reserveCore(1, "bar", os.Getpid())
+	assert.NoError(err)
+	assert.True(reserved)
+
+	s := &Sandbox{
+		id:     "foo",
+		config: &SandboxConfig{VCPUIsolation: VCPUIsolationExclusiveCores},
+	}
+	assert.NoError(s.releaseVCPUCores())
+
+	_, err = os.Stat(vcpuCoreFile(0))
+	assert.True(os.IsNotExist(err))
+
+	id, _, err := readCoreOwner(vcpuCoreFile(1))
+	assert.NoError(err)
+	assert.Equal("bar", id)
+}
+
+func TestPinVCPUsToExclusiveCores(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "vcpu-isolation")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	savedSysCPUPath := sysCPUPath
+	savedVCPUCoresDir := vcpuCoresDir
+	sysCPUPath = filepath.Join(dir, "cpu")
+	vcpuCoresDir = filepath.Join(dir, "cores")
+	defer func() {
+		sysCPUPath = savedSysCPUPath
+		vcpuCoresDir = savedVCPUCoresDir
+	}()
+
+	// the mock vCPU is the thread of the test
+	var saved unix.CPUSet
+	assert.NoError(unix.SchedGetaffinity(os.Getpid(), &saved))
+	defer unix.SchedSetaffinity(os.Getpid(), &saved)
+
+	mockCPUTopology(t, sysCPUPath, len(saved)*64, func(cpu int) string {
+		return fmt.Sprintf("%d", cpu)
+	})
+
+	s := &Sandbox{
+		id:         testSandboxID,
+		hypervisor: &mockHypervisor{mockPid: os.Getpid()},
+		config:     &SandboxConfig{VCPUIsolation: VCPUIsolationExclusiveCores},
+	}
+
+	assert.NoError(s.isolateVCPUs())
+
+	var pinned unix.CPUSet
+	assert.NoError(unix.SchedGetaffinity(os.Getpid(), &pinned))
+	assert.Equal(1, pinned.Count())
+
+	files, err := ioutil.ReadDir(vcpuCoresDir)
+	assert.NoError(err)
+	assert.Len(files, 1)
+
+	// the reservation is kept when the vCPUs are isolated again
+	assert.NoError(unix.SchedSetaffinity(os.Getpid(), &saved))
+	assert.NoError(s.isolateVCPUs())
+
+	files, err = ioutil.ReadDir(vcpuCoresDir)
+	assert.NoError(err)
+	assert.Len(files, 1)
+
+	assert.NoError(s.releaseVCPUCores())
+	files, err = ioutil.ReadDir(vcpuCoresDir)
+	assert.NoError(err)
+	assert.Empty(files)
+}
@@ -88,7 +88,7 @@ func (endpoint *VethEndpoint) SetProperties(properties NetworkInfo) {
 // Attach for veth endpoint bridges the network pair and adds the
 // tap interface of the network pair to the hypervisor.
 func (endpoint *VethEndpoint) Attach(h hypervisor) error {
-	if err := xConnectVMNetwork(endpoint, h); err != nil {
+	if err := xConnectVMNetwork(endpoint, h, netQueues(h)); err != nil {
 		networkLogger().WithError(err).Error("Error bridging virtual endpoint")
 		return err
 	}
@@ -112,7 +112,7 @@ func (endpoint *VethEndpoint) Detach(netNsCreated bool, netNsPath string) error
 
 // HotAttach for the veth endpoint uses hot plug device
 func (endpoint *VethEndpoint) HotAttach(h hypervisor) error {
-	if err := xConnectVMNetwork(endpoint, h); err != nil {
+	if err := xConnectVMNetwork(endpoint, h, hotplugNetQueues(h)); err != nil {
 		networkLogger().WithError(err).Error("Error bridging virtual ep")
 		return err
 	}
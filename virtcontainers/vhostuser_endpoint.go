@@ -9,18 +9,35 @@ import (
 	"encoding/hex"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/kata-containers/runtime/virtcontainers/device/config"
 	persistapi "github.com/kata-containers/runtime/virtcontainers/persist/api"
 	"github.com/kata-containers/runtime/virtcontainers/utils"
 )
 
-// Long term, this should be made more configurable.  For now matching path
-// provided by CNM VPP and OVS-DPDK plugins, available at github.com/clearcontainers/vpp and
-// github.com/clearcontainers/ovsdpdk.  The plugins create the socket on the host system
-// using this path.
+// Default path matching the one provided by CNM VPP and OVS-DPDK plugins, available at
+// github.com/clearcontainers/vpp and github.com/clearcontainers/ovsdpdk.  The plugins
+// create the socket on the host system using this path. It can be changed through
+// NetworkConfig.VhostUserSocketPath.
 const hostSocketSearchPath = "/tmp/vhostuser_%s/vhu.sock"
 
+// CheckVhostUserSocketPath checks the path of the vhost-user sockets is
+// absolute and has a single %s, replaced by the IP addresses of the
+// interfaces.
+func CheckVhostUserSocketPath(path string) error {
+	if !filepath.IsAbs(path) {
+		return fmt.Errorf("vhost-user socket path %q is not absolute", path)
+	}
+
+	if strings.Count(path, "%") != 1 || strings.Count(path, "%s") != 1 {
+		return fmt.Errorf("vhost-user socket path %q must have a single %%s for the interface address", path)
+	}
+
+	return nil
+}
+
 // VhostUserEndpoint represents a vhost-user socket based network interface
 type VhostUserEndpoint struct {
 	// Path to the vhost-user socket on the host system
@@ -75,6 +92,12 @@ func (endpoint *VhostUserEndpoint) NetworkPair() *NetworkInterfacePair {
 
 // Attach for vhostuser endpoint
 func (endpoint *VhostUserEndpoint) Attach(h hypervisor) error {
+	// The vhost-user backend accesses the virtqueues and the packets
+	// in the guest memory, which has to be shared with it.
+	if !h.hypervisorConfig().HugePages {
+		return fmt.Errorf("vhost-user interface %s requires the guest memory to be backed by huge pages, set enable_hugepages", endpoint.IfaceName)
+	}
+
 	// Generate a unique ID to be used for hypervisor commandline fields
 	randBytes, err := utils.GenerateRandomBytes(8)
 	if err != nil {
@@ -121,14 +144,18 @@ func createVhostUserEndpoint(netInfo NetworkInfo, socket string) (*VhostUserEndp
 
 // findVhostUserNetSocketPath checks if an interface is a dummy placeholder
 // for a vhost-user socket, and if it is it returns the path to the socket
-func findVhostUserNetSocketPath(netInfo NetworkInfo) (string, error) {
+func findVhostUserNetSocketPath(netInfo NetworkInfo, searchPath string) (string, error) {
 	if netInfo.Iface.Name == "lo" {
 		return "", nil
 	}
 
+	if searchPath == "" {
+		searchPath = hostSocketSearchPath
+	}
+
 	// check for socket file existence at known location.
 	for _, addr := range netInfo.Addrs {
-		socketPath := fmt.Sprintf(hostSocketSearchPath, addr.IPNet.IP)
+		socketPath := fmt.Sprintf(searchPath, addr.IPNet.IP)
 		if _, err := os.Stat(socketPath); err == nil {
 			return socketPath, nil
 		}
@@ -137,14 +164,15 @@ func findVhostUserNetSocketPath(netInfo NetworkInfo) (string, error) {
 	return "", nil
 }
 
-// vhostUserSocketPath returns the path of the socket discovered.  This discovery
-// will vary depending on the type of vhost-user socket.
+// vhostUserSocketPath returns the path of the socket discovered in searchPath,
+// the default one if empty.  This discovery will vary depending on the type of
+// vhost-user socket.
 //  Today only VhostUserNetDevice is supported.
-func vhostUserSocketPath(info interface{}) (string, error) {
+func vhostUserSocketPath(info interface{}, searchPath string) (string, error) {
 
 	switch v := info.(type) {
 	case NetworkInfo:
-		return findVhostUserNetSocketPath(v)
+		return findVhostUserNetSocketPath(v, searchPath)
 	default:
 		return "", nil
 	}
@@ -7,8 +7,10 @@ package virtcontainers
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -47,7 +49,7 @@ func TestVhostUserSocketPath(t *testing.T) {
 		Addrs: addresses,
 	}
 
-	path, _ := vhostUserSocketPath(netinfo)
+	path, _ := vhostUserSocketPath(netinfo, "")
 	assert.Equal(path, expectedResult)
 
 	// Second test case: search doesn't include matching vsock:
@@ -63,11 +65,48 @@ func TestVhostUserSocketPath(t *testing.T) {
 		Addrs: addressesFalse,
 	}
 
-	path, _ = vhostUserSocketPath(netinfoFail)
+	path, _ = vhostUserSocketPath(netinfoFail, "")
 	assert.Empty(path)
 
 	assert.NoError(os.Remove(expectedResult))
 	assert.NoError(os.Remove(expectedPath))
+
+	// Third test case: search in a configured path:
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	expectedResult = filepath.Join(dir, "192.168.0.2.sock")
+	_, err = os.Create(expectedResult)
+	assert.NoError(err)
+
+	path, _ = vhostUserSocketPath(netinfo, filepath.Join(dir, "%s.sock"))
+	assert.Equal(expectedResult, path)
+}
+
+func TestCheckVhostUserSocketPath(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(CheckVhostUserSocketPath(hostSocketSearchPath))
+	assert.NoError(CheckVhostUserSocketPath("/run/vpp/%s/sock"))
+
+	for _, path := range []string{
+		"vhostuser_%s/vhu.sock",
+		"/tmp/vhu.sock",
+		"/tmp/%s/%s.sock",
+		"/tmp/%d/vhu.sock",
+		"/tmp/%s/%%.sock",
+	} {
+		assert.Error(CheckVhostUserSocketPath(path), "path %s", path)
+	}
+}
+
+type hugePagesHypervisor struct {
+	mockHypervisor
+}
+
+func (h *hugePagesHypervisor) hypervisorConfig() HypervisorConfig {
+	return HypervisorConfig{HugePages: true}
 }
 
 func TestVhostUserEndpointAttach(t *testing.T) {
@@ -78,9 +117,11 @@ func TestVhostUserEndpointAttach(t *testing.T) {
 		EndpointType: VhostUserEndpointType,
 	}
 
-	h := &mockHypervisor{}
+	// the guest memory isn't shared with the vhost-user backend
+	err := v.Attach(&mockHypervisor{})
+	assert.Error(err)
 
-	err := v.Attach(h)
+	err = v.Attach(&hugePagesHypervisor{})
 	assert.NoError(err)
 }
 
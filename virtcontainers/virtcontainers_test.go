@@ -135,6 +135,9 @@ func TestMain(m *testing.M) {
 		os.Exit(1)
 	}
 
+	sandboxTmpRoot = filepath.Join(testDir, "tmp")
+	hypervisorVersionCacheDir = filepath.Join(testDir, "versions")
+
 	utils.StartCmd = func(c *exec.Cmd) error {
 		//startSandbox will check if the hypervisor is alive and
 		// checks for the PID is running, lets fake it using our
@@ -46,6 +46,8 @@ type virtiofsd struct {
 	cache string
 	// extraArgs list of extra args to append to virtiofsd command
 	extraArgs []string
+	// threads is the size of the thread pool, 0 for the default
+	threads uint32
 	// sourcePath path that daemon will help to share
 	sourcePath string
 	// debug flag
@@ -130,6 +132,10 @@ func (v *virtiofsd) args() ([]string, error) {
 		"-o", "source=" + v.sourcePath,
 		"-o", "cache=" + v.cache}
 
+	if v.threads != 0 {
+		args = append(args, fmt.Sprintf("--thread-pool-size=%d", v.threads))
+	}
+
 	if len(v.extraArgs) != 0 {
 		args = append(args, v.extraArgs...)
 	}
@@ -163,7 +169,7 @@ func (v *virtiofsd) trace(name string) (opentracing.Span, context.Context) {
 		v.ctx = context.Background()
 	}
 
-	span, ctx := opentracing.StartSpanFromContext(v.ctx, name)
+	span, ctx := startSpanFromContext(v.ctx, "virtiofsd", name)
 
 	span.SetTag("subsystem", "virtiofds")
 
@@ -0,0 +1,169 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// virtiofsdRestartDelay is the delay before restarting a crashed virtiofsd,
+// doubled at each restart.
+var virtiofsdRestartDelay = 100 * time.Millisecond
+
+// virtiofsdSupervisor runs virtiofsd serving the vhost-user socket the
+// hypervisor connects to, the listening socket being owned by the
+// supervisor so that a virtiofsd restarted after a crash serves the same
+// socket.
+type virtiofsdSupervisor struct {
+	// path of virtiofsd and args building its arguments from the fd
+	// number of the listening socket
+	path string
+	args func(fd uintptr) []string
+
+	// socket is the listening vhost-user socket
+	socket *os.File
+
+	// maxRestarts is how many times virtiofsd is restarted after
+	// crashing, it's not restarted when it exits because the hypervisor
+	// closed the connection.
+	maxRestarts uint32
+
+	// exited is called when virtiofsd exits and isn't restarted while
+	// the supervisor isn't stopped.
+	exited func()
+
+	logger *logrus.Entry
+
+	sync.Mutex
+	pid      int
+	restarts int
+	stopped  bool
+}
+
+// start starts virtiofsd and supervises it until stop is called.
+func (s *virtiofsdSupervisor) start() error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.startLocked()
+}
+
+func (s *virtiofsdSupervisor) startLocked() error {
+	const sockFd = 3 // Cmd.ExtraFiles[] fds are numbered starting from 3
+	cmd := exec.Command(s.path, s.args(sockFd)...)
+	cmd.ExtraFiles = append(cmd.ExtraFiles, s.socket)
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	s.pid = cmd.Process.Pid
+	s.logger.WithField("pid", s.pid).Info("virtiofsd started")
+
+	go s.wait(cmd, stderr)
+
+	return nil
+}
+
+// wait logs the messages of virtiofsd until it exits, restarting it when
+// it crashed.
+func (s *virtiofsdSupervisor) wait(cmd *exec.Cmd, stderr io.ReadCloser) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		s.logger.WithField("source", "virtiofsd").Info(scanner.Text())
+	}
+
+	// Wait to release resources of virtiofsd process
+	err := cmd.Wait()
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.stopped {
+		s.pid = 0
+		return
+	}
+
+	logger := s.logger.WithField("pid", cmd.Process.Pid)
+
+	// virtiofsd exits successfully once the hypervisor closes the
+	// connection
+	if err == nil {
+		logger.Info("virtiofsd quits")
+		s.exitedLocked()
+		return
+	}
+
+	logger = logger.WithError(err)
+
+	if s.restarts >= int(s.maxRestarts) {
+		logger.WithField("restarts", s.restarts).Error("virtiofsd crashed, the shared file system is unavailable")
+		s.exitedLocked()
+		return
+	}
+
+	delay := virtiofsdRestartDelay << uint(s.restarts)
+	s.restarts++
+	logger.WithField("restarts", s.restarts).Warn("virtiofsd crashed, restarting it")
+
+	s.Unlock()
+	time.Sleep(delay)
+	s.Lock()
+
+	if s.stopped {
+		s.pid = 0
+		return
+	}
+
+	if err := s.startLocked(); err != nil {
+		s.logger.WithError(err).Error("Could not restart virtiofsd")
+		s.exitedLocked()
+	}
+}
+
+func (s *virtiofsdSupervisor) exitedLocked() {
+	s.pid = 0
+	s.stopped = true
+	s.socket.Close()
+
+	if s.exited != nil {
+		// exited may stop the supervisor
+		go s.exited()
+	}
+}
+
+// stop stops supervising virtiofsd, which exits with the hypervisor.
+func (s *virtiofsdSupervisor) stop() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.stopped {
+		return
+	}
+
+	s.stopped = true
+	s.socket.Close()
+}
+
+// status returns the PID of virtiofsd, 0 once it exited, and how many
+// times it was restarted.
+func (s *virtiofsdSupervisor) status() (int, int) {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.pid, s.restarts
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestVirtiofsdSupervisor(t *testing.T, script string, maxRestarts uint32) (*virtiofsdSupervisor, chan struct{}) {
+	socket, err := os.Open(os.DevNull)
+	assert.NoError(t, err)
+
+	exited := make(chan struct{})
+	return &virtiofsdSupervisor{
+		path: "/bin/sh",
+		args: func(fd uintptr) []string {
+			return []string{"-c", script}
+		},
+		socket:      socket,
+		maxRestarts: maxRestarts,
+		exited: func() {
+			close(exited)
+		},
+		logger: virtLog.WithField("subsystem", "virtiofsd"),
+	}, exited
+}
+
+func TestVirtiofsdSupervisorRestarts(t *testing.T) {
+	assert := assert.New(t)
+
+	savedDelay := virtiofsdRestartDelay
+	virtiofsdRestartDelay = time.Millisecond
+	defer func() {
+		virtiofsdRestartDelay = savedDelay
+	}()
+
+	s, exited := newTestVirtiofsdSupervisor(t, "exit 1", 2)
+	assert.NoError(s.start())
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("virtiofsd crashes not reported")
+	}
+
+	pid, restarts := s.status()
+	assert.Zero(pid)
+	assert.Equal(2, restarts)
+}
+
+func TestVirtiofsdSupervisorQuits(t *testing.T) {
+	assert := assert.New(t)
+
+	// virtiofsd isn't restarted when the hypervisor closes the connection
+	s, exited := newTestVirtiofsdSupervisor(t, "exit 0", 2)
+	assert.NoError(s.start())
+
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("virtiofsd exit not reported")
+	}
+
+	_, restarts := s.status()
+	assert.Zero(restarts)
+}
+
+func TestVirtiofsdSupervisorStop(t *testing.T) {
+	assert := assert.New(t)
+
+	s, exited := newTestVirtiofsdSupervisor(t, "sleep 0.1; exit 1", 2)
+	assert.NoError(s.start())
+
+	pid, _ := s.status()
+	assert.NotZero(pid)
+
+	s.stop()
+
+	select {
+	case <-exited:
+		t.Fatal("stopped virtiofsd exit reported")
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	pid, restarts := s.status()
+	assert.Zero(pid)
+	assert.Zero(restarts)
+}
+
+func TestVirtiofsdSupervisorStartError(t *testing.T) {
+	s, _ := newTestVirtiofsdSupervisor(t, "", 0)
+	s.path = "/does/not/exist"
+	assert.Error(t, s.start())
+}
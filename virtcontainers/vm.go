@@ -184,7 +184,7 @@ func NewVM(ctx context.Context, config VMConfig) (*VM, error) {
 	}
 
 	// 3. boot up guest vm
-	if err = hypervisor.startSandbox(vmStartTimeout); err != nil {
+	if err = hypervisor.startSandbox(config.HypervisorConfig.bootTimeout()); err != nil {
 		return nil, err
 	}
 
@@ -284,7 +284,7 @@ func NewVMFromGrpc(ctx context.Context, v *pb.GrpcVM, config VMConfig) (*VM, err
 }
 
 func buildVMSharePath(id string, vmStoragePath string) string {
-	return filepath.Join(vmStoragePath, id, "shared")
+	return filepath.Join(SandboxVMPath(vmStoragePath, id), "shared")
 }
 
 func (v *VM) logger() logrus.FieldLogger {
@@ -312,7 +312,8 @@ func (v *VM) Resume() error {
 // Start kicks off a configured VM.
 func (v *VM) Start() error {
 	v.logger().Info("start vm")
-	return v.hypervisor.startSandbox(vmStartTimeout)
+	config := v.hypervisor.hypervisorConfig()
+	return v.hypervisor.startSandbox(config.bootTimeout())
 }
 
 // Disconnect agent and proxy connections to a VM
@@ -337,6 +338,10 @@ func (v *VM) Stop() error {
 		return err
 	}
 
+	if err := cleanupSandboxTmpDir(v.id); err != nil {
+		return err
+	}
+
 	return v.store.Destroy(v.id)
 }
 
@@ -407,13 +412,13 @@ func (v *VM) SyncTime() error {
 
 func (v *VM) assignSandbox(s *Sandbox) error {
 	// add vm symlinks
-	// - link vm socket from sandbox dir (/run/vc/vm/sbid/<kata.sock>) to vm dir (/run/vc/vm/vmid/<kata.sock>)
-	// - link 9pfs share path from sandbox dir (/run/kata-containers/shared/sandboxes/sbid/) to vm dir (/run/vc/vm/vmid/shared/)
+	// - link vm socket from sandbox dir (/run/vc/tmp/sbid/vm/<kata.sock>) to vm dir (/run/vc/tmp/vmid/vm/<kata.sock>)
+	// - link 9pfs share path from sandbox dir (/run/kata-containers/shared/sandboxes/sbid/) to vm dir (/run/vc/tmp/vmid/vm/shared/)
 
 	vmSharePath := buildVMSharePath(v.id, v.store.RunVMStoragePath())
-	vmSockDir := filepath.Join(v.store.RunVMStoragePath(), v.id)
+	vmSockDir := SandboxVMPath(v.store.RunVMStoragePath(), v.id)
 	sbSharePath := s.agent.getSharePath(s.id)
-	sbSockDir := filepath.Join(v.store.RunVMStoragePath(), s.id)
+	sbSockDir := SandboxVMPath(v.store.RunVMStoragePath(), s.id)
 
 	v.logger().WithFields(logrus.Fields{
 		"vmSharePath": vmSharePath,
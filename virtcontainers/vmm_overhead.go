@@ -0,0 +1,282 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/kata-containers/runtime/virtcontainers/utils"
+)
+
+// for mocking in unit tests
+var procRoot = "/proc"
+
+// shimPidFile is the file of the bundle the containerd v2 shim writes its
+// pid to.
+const shimPidFile = "shim.pid"
+
+// OverheadProcess is a host process of a sandbox with its resident memory.
+type OverheadProcess struct {
+	Name string `json:"name"`
+	Pid  int    `json:"pid"`
+	RSS  uint64 `json:"rss_bytes"`
+}
+
+// Overhead is the host resources used by a sandbox on top of the
+// ones of its containers, what the pod overhead of a runtime class covers.
+type Overhead struct {
+	ID string `json:"id"`
+
+	// ExpectedVCPUs and ExpectedMemory are the vCPUs and memory of the VM
+	// which are not given to the containers limits.
+	ExpectedVCPUs  uint32 `json:"expected_vcpus"`
+	ExpectedMemory uint64 `json:"expected_memory_bytes"`
+
+	// Processes are the host processes of the sandbox: the hypervisor and
+	// its helpers, the proxy and the shims.
+	Processes []OverheadProcess `json:"processes"`
+
+	// GuestMemory is the memory used by the containers in the guest,
+	// ActualMemory the memory of the processes not used by them.
+	GuestMemory  uint64 `json:"guest_memory_bytes"`
+	ActualMemory uint64 `json:"actual_memory_bytes"`
+}
+
+// processRSS returns the resident set size in bytes of the host process pid.
+func processRSS(pid int) (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(procRoot, strconv.Itoa(pid), "statm"))
+	if err != nil {
+		return 0, err
+	}
+
+	// size resident shared text lib data dt, in pages
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("Invalid statm of process %d: %q", pid, data)
+	}
+
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid statm of process %d: %v", pid, err)
+	}
+
+	return pages * uint64(os.Getpagesize()), nil
+}
+
+// guestMappingMinSize is the size from which a mapping of a host process of
+// the sandbox is taken for guest memory: the guest RAM regions are at least
+// one memory block, the largest mappings of the VMM itself, the malloc
+// arenas, are 64MiB.
+const guestMappingMinSize = 128 << 20
+
+// smapsValue returns in bytes the value of a field of a smaps mapping, e.g.
+// "Pss:    1024 kB".
+func smapsValue(fields []string) (uint64, error) {
+	if len(fields) != 3 || fields[2] != "kB" {
+		return 0, fmt.Errorf("Invalid smaps field %q", strings.Join(fields, " "))
+	}
+
+	kb, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid smaps field %q: %v", strings.Join(fields, " "), err)
+	}
+
+	return kb << 10, nil
+}
+
+// processOverheadMemory returns in bytes the proportional set size of the
+// mappings of the host process pid, but for the guest memory ones: the guest
+// memory used by the containers is already accounted in their stats.
+func processOverheadMemory(pid int) (uint64, error) {
+	f, err := os.Open(filepath.Join(procRoot, strconv.Itoa(pid), "smaps"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var total, size, pss uint64
+	account := func() {
+		if size < guestMappingMinSize {
+			total += pss
+		}
+		size, pss = 0, 0
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "Size:":
+			// the first field of a mapping
+			account()
+			size, err = smapsValue(fields)
+		case "Pss:":
+			pss, err = smapsValue(fields)
+		}
+
+		if err != nil {
+			return 0, fmt.Errorf("Invalid smaps of process %d: %v", pid, err)
+		}
+	}
+	account()
+
+	return total, scanner.Err()
+}
+
+// vmmOverhead returns the host memory used by the host processes of the
+// sandbox, the hypervisor, proxy and shim, out of the guest memory.
+func (s *Sandbox) vmmOverhead() uint64 {
+	var overhead uint64
+
+	for _, p := range s.overheadProcesses() {
+		if p.Pid <= 0 {
+			continue
+		}
+
+		memory, err := processOverheadMemory(p.Pid)
+		if err != nil {
+			s.Logger().WithError(err).WithField("pid", p.Pid).Warn("Could not get the memory usage of the process")
+			continue
+		}
+
+		overhead += memory
+	}
+
+	return overhead
+}
+
+// addMemoryOverhead accounts overhead bytes of host memory in the memory
+// usage and RSS of stats.
+func addMemoryOverhead(stats *ContainerStats, overhead uint64) {
+	if stats.CgroupStats == nil {
+		stats.CgroupStats = &CgroupStats{}
+	}
+
+	memory := &stats.CgroupStats.MemoryStats
+
+	memory.Usage.Usage += overhead
+	if memory.Usage.MaxUsage < memory.Usage.Usage {
+		memory.Usage.MaxUsage = memory.Usage.Usage
+	}
+
+	if memory.Stats == nil {
+		memory.Stats = make(map[string]uint64)
+	}
+
+	// the shim reports either of them depending on the hierarchy mode
+	memory.Stats["rss"] += overhead
+	memory.Stats["total_rss"] += overhead
+}
+
+// overheadProcesses returns the host processes of the sandbox, the
+// built-in shim being found from the pid file containerd has it write
+// in the bundle of the sandbox container.
+func (s *Sandbox) overheadProcesses() []OverheadProcess {
+	var processes []OverheadProcess
+
+	for _, pid := range s.hypervisor.getPids() {
+		processes = append(processes, OverheadProcess{Name: "hypervisor", Pid: pid})
+	}
+
+	if pid := s.agent.save().ProxyPid; pid > 0 {
+		processes = append(processes, OverheadProcess{Name: "proxy", Pid: pid})
+	}
+
+	switch s.config.ShimType {
+	case KataShimType:
+		for _, c := range s.containers {
+			processes = append(processes, OverheadProcess{Name: "shim", Pid: c.process.Pid})
+		}
+	case KataBuiltInShimType:
+		if c, ok := s.containers[s.id]; ok {
+			bundle := c.config.Annotations[annotations.BundlePathKey]
+			if data, err := ioutil.ReadFile(filepath.Join(bundle, shimPidFile)); err == nil {
+				pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+				processes = append(processes, OverheadProcess{Name: "shim", Pid: pid})
+			}
+		}
+	}
+
+	return processes
+}
+
+// Overhead returns the expected and actual overhead of the sandbox, the
+// memory used by the containers being queried from the agent.
+func (s *Sandbox) Overhead() (Overhead, error) {
+	hConfig := s.config.HypervisorConfig
+
+	overhead := Overhead{
+		ID:             s.id,
+		ExpectedVCPUs:  hConfig.NumVCPUs,
+		ExpectedMemory: uint64(hConfig.MemorySize) << utils.MibToBytesShift,
+		Processes:      []OverheadProcess{},
+	}
+
+	// the VM is sized for the containers limits from the start, instead
+	// of being resized on top of its default size
+	if s.config.StaticResourceMgmt {
+		if vcpus := s.calculateSandboxCPUs(); vcpus < overhead.ExpectedVCPUs {
+			overhead.ExpectedVCPUs -= vcpus
+		}
+	}
+	if s.config.StaticResourceMgmt || s.config.StaticMemoryMgmt {
+		if memory := uint64(s.calculateSandboxMemory()); memory < overhead.ExpectedMemory {
+			overhead.ExpectedMemory -= memory
+		}
+	}
+
+	var rss uint64
+	for _, p := range s.overheadProcesses() {
+		if p.Pid <= 0 {
+			continue
+		}
+
+		usage, err := processRSS(p.Pid)
+		if err != nil {
+			s.Logger().WithError(err).WithField("pid", p.Pid).Warn("Could not get the memory usage of the process")
+			continue
+		}
+
+		p.RSS = usage
+		rss += usage
+		overhead.Processes = append(overhead.Processes, p)
+	}
+
+	for _, c := range s.containers {
+		if c.state.State != types.StateRunning {
+			continue
+		}
+
+		// the container stats, without the VMM memory accounted to the
+		// sandbox container
+		stats, err := c.stats()
+		if err != nil {
+			return Overhead{}, err
+		}
+
+		if stats.CgroupStats != nil {
+			overhead.GuestMemory += stats.CgroupStats.MemoryStats.Usage.Usage
+		}
+	}
+
+	if rss > overhead.GuestMemory {
+		overhead.ActualMemory = rss - overhead.GuestMemory
+	}
+
+	return overhead, nil
+}
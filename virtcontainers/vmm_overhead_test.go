@@ -0,0 +1,200 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/pkg/annotations"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeStatm(t *testing.T, root string, pid int, statm string) {
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "statm"), []byte(statm), 0644))
+}
+
+func writeSmaps(t *testing.T, root string, pid int, smaps string) {
+	dir := filepath.Join(root, strconv.Itoa(pid))
+	assert.NoError(t, os.MkdirAll(dir, 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "smaps"), []byte(smaps), 0644))
+}
+
+// qemuSmaps are the mappings of a VMM: its heap, a library and its guest
+// RAM, which is left out of the overhead.
+const qemuSmaps = `5600c0000000-5600c4000000 rw-p 00000000 00:00 0                          [heap]
+Size:              65536 kB
+Rss:                4096 kB
+Pss:                4096 kB
+VmFlags: rd wr mr mw me ac sd
+7f0000000000-7f0080000000 rw-p 00000000 00:00 0
+Size:            2097152 kB
+Rss:              524288 kB
+Pss:              524288 kB
+VmFlags: rd wr mr mw me ac sd hg
+7f0100000000-7f0100200000 r-xp 00000000 fd:01 1234                       /usr/lib64/libc-2.31.so
+Size:               2048 kB
+Rss:                1024 kB
+Pss:                 256 kB
+VmFlags: rd ex mr mw me sd
+`
+
+func TestVMMOverhead(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	savedProcRoot := procRoot
+	procRoot = tmpdir
+	defer func() {
+		procRoot = savedProcRoot
+	}()
+
+	pageSize := uint64(os.Getpagesize())
+
+	writeStatm(t, tmpdir, 1234, "5000 100 20 1 0 300 0\n")
+	writeStatm(t, tmpdir, 4321, "invalid\n")
+
+	rss, err := processRSS(1234)
+	assert.NoError(err)
+	assert.Equal(100*pageSize, rss)
+
+	_, err = processRSS(4321)
+	assert.Error(err)
+
+	_, err = processRSS(5678)
+	assert.Error(err)
+
+	writeSmaps(t, tmpdir, 1234, qemuSmaps)
+	writeSmaps(t, tmpdir, 2345, "Size: 1024 kB\nPss: 512 kB\n")
+	writeSmaps(t, tmpdir, 4321, "Size: 1024 kB\nPss: invalid kB\n")
+
+	memory, err := processOverheadMemory(1234)
+	assert.NoError(err)
+	assert.Equal(uint64((4096+256)<<10), memory)
+
+	_, err = processOverheadMemory(4321)
+	assert.Error(err)
+
+	// the built-in shim writes its pid in the bundle
+	assert.NoError(ioutil.WriteFile(filepath.Join(tmpdir, shimPidFile), []byte("2345"), 0644))
+
+	s := &Sandbox{
+		id:         testSandboxID,
+		hypervisor: &mockHypervisor{mockPid: 1234},
+		agent:      &noopAgent{},
+		config: &SandboxConfig{
+			ShimType: KataBuiltInShimType,
+		},
+		containers: map[string]*Container{
+			testSandboxID: {
+				id: testSandboxID,
+				config: &ContainerConfig{
+					Annotations: map[string]string{
+						annotations.BundlePathKey: tmpdir,
+					},
+				},
+			},
+		},
+	}
+	assert.Equal(uint64((4096+256+512)<<10), s.vmmOverhead())
+
+	// the processes whose usage is unknown are not accounted
+	s.hypervisor = &mockHypervisor{mockPid: 4321}
+	assert.Equal(uint64(512<<10), s.vmmOverhead())
+}
+
+func TestAddMemoryOverhead(t *testing.T) {
+	assert := assert.New(t)
+
+	stats := &ContainerStats{}
+	addMemoryOverhead(stats, 1024)
+	assert.Equal(uint64(1024), stats.CgroupStats.MemoryStats.Usage.Usage)
+	assert.Equal(uint64(1024), stats.CgroupStats.MemoryStats.Usage.MaxUsage)
+	assert.Equal(uint64(1024), stats.CgroupStats.MemoryStats.Stats["rss"])
+
+	stats = &ContainerStats{
+		CgroupStats: &CgroupStats{
+			MemoryStats: MemoryStats{
+				Usage: MemoryData{
+					Usage:    4096,
+					MaxUsage: 8192,
+				},
+				Stats: map[string]uint64{
+					"rss":       2048,
+					"total_rss": 2048,
+				},
+			},
+		},
+	}
+	addMemoryOverhead(stats, 1024)
+	assert.Equal(uint64(5120), stats.CgroupStats.MemoryStats.Usage.Usage)
+	assert.Equal(uint64(8192), stats.CgroupStats.MemoryStats.Usage.MaxUsage)
+	assert.Equal(uint64(3072), stats.CgroupStats.MemoryStats.Stats["rss"])
+	assert.Equal(uint64(3072), stats.CgroupStats.MemoryStats.Stats["total_rss"])
+}
+
+func TestSandboxOverheadProcesses(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	savedProcRoot := procRoot
+	procRoot = tmpdir
+	defer func() {
+		procRoot = savedProcRoot
+	}()
+
+	pageSize := uint64(os.Getpagesize())
+
+	writeStatm(t, tmpdir, 1234, "5000 1000 20 1 0 300 0\n")
+	writeStatm(t, tmpdir, 4321, "800 100 5 1 0 30 0\n")
+
+	// the built-in shim writes its pid in the bundle
+	assert.NoError(ioutil.WriteFile(filepath.Join(tmpdir, shimPidFile), []byte("4321"), 0644))
+
+	s := &Sandbox{
+		id:         testSandboxID,
+		hypervisor: &mockHypervisor{mockPid: 1234},
+		agent:      &noopAgent{},
+		config: &SandboxConfig{
+			ShimType: KataBuiltInShimType,
+			HypervisorConfig: HypervisorConfig{
+				NumVCPUs:   1,
+				MemorySize: 2048,
+			},
+		},
+		containers: map[string]*Container{
+			testSandboxID: {
+				id: testSandboxID,
+				config: &ContainerConfig{
+					Annotations: map[string]string{
+						annotations.BundlePathKey: tmpdir,
+					},
+				},
+			},
+		},
+	}
+
+	overhead, err := s.Overhead()
+	assert.NoError(err)
+	assert.Equal(uint32(1), overhead.ExpectedVCPUs)
+	assert.Equal(uint64(2048<<20), overhead.ExpectedMemory)
+	assert.Equal([]OverheadProcess{
+		{Name: "hypervisor", Pid: 1234, RSS: 1000 * pageSize},
+		{Name: "shim", Pid: 4321, RSS: 100 * pageSize},
+	}, overhead.Processes)
+	assert.Equal(1100*pageSize, overhead.ActualMemory)
+}
@@ -0,0 +1,309 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kataclient "github.com/kata-containers/agent/protocols/client"
+	vcTypes "github.com/kata-containers/runtime/virtcontainers/pkg/types"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/kata-containers/runtime/virtcontainers/utils"
+	"github.com/mdlayher/vsock"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// agentVSockChannel, logsVSockChannel and debugConsoleVSockChannel
+	// are the names of the vsock channels always reserved for the agent.
+	agentVSockChannel        = "agent"
+	logsVSockChannel         = "logs"
+	debugConsoleVSockChannel = "debug-console"
+
+	vsockTunnelDialTimeout = 10 * time.Second
+)
+
+// checkVSockChannels ensures the configured vsock channels don't use
+// privileged or reserved ports and don't share a port.
+func checkVSockChannels(channels map[string]uint32) error {
+	ports := map[uint32]string{
+		vSockPort:             agentVSockChannel,
+		vSockLogsPort:         logsVSockChannel,
+		vSockDebugConsolePort: debugConsoleVSockChannel,
+	}
+
+	for name, port := range channels {
+		if name == "" {
+			return fmt.Errorf("Missing vsock channel name")
+		}
+
+		if name == agentVSockChannel || name == logsVSockChannel || name == debugConsoleVSockChannel {
+			return fmt.Errorf("The vsock channel name %q is reserved", name)
+		}
+
+		if port < vSockPort {
+			return fmt.Errorf("Invalid port %d of vsock channel %q, ports below %d are privileged", port, name, vSockPort)
+		}
+
+		if other, ok := ports[port]; ok {
+			return fmt.Errorf("The vsock channels %q and %q use the same port %d", name, other, port)
+		}
+		ports[port] = name
+	}
+
+	return nil
+}
+
+func isVSockURL(url string) bool {
+	return strings.HasPrefix(url, types.VSockScheme+"://") || isHybridVSockURL(url)
+}
+
+func isHybridVSockURL(url string) bool {
+	return strings.HasPrefix(url, types.HybridVSockScheme+"://")
+}
+
+// vsockChannelURL returns the URL of the guest port reachable through the
+// vsock or hybrid vsock the agent is connected with, the port being the last
+// element of the URLs.
+func vsockChannelURL(agentURL string, port uint32) (string, error) {
+	if !isVSockURL(agentURL) {
+		return "", fmt.Errorf("The agent is not connected through a vsock: %s", agentURL)
+	}
+
+	i := strings.LastIndex(agentURL, ":")
+	if i < strings.Index(agentURL, "://")+len("://") {
+		return "", fmt.Errorf("Invalid vsock URL %s", agentURL)
+	}
+
+	return fmt.Sprintf("%s:%d", agentURL[:i], port), nil
+}
+
+// vsockChannels returns the URLs of the agent and configured vsock channels
+// of the sandbox, keyed by name. The sandboxes whose agent isn't connected
+// through a vsock have no channel.
+func (s *Sandbox) vsockChannels() (map[string]string, error) {
+	agentURL, err := s.agent.getAgentURL()
+	if err != nil {
+		return nil, err
+	}
+
+	if !isVSockURL(agentURL) {
+		if len(s.config.HypervisorConfig.VSockChannels) > 0 {
+			return nil, fmt.Errorf("vsock channels require the agent to use a vsock")
+		}
+		return nil, nil
+	}
+
+	ports := map[string]uint32{
+		agentVSockChannel: vSockPort,
+	}
+
+	// the agent only sends its logs through the hybrid vsocks
+	if isHybridVSockURL(agentURL) {
+		ports[logsVSockChannel] = vSockLogsPort
+	}
+
+	for name, port := range s.config.HypervisorConfig.VSockChannels {
+		ports[name] = port
+	}
+
+	channels := make(map[string]string)
+	for name, port := range ports {
+		if channels[name], err = vsockChannelURL(agentURL, port); err != nil {
+			return nil, err
+		}
+	}
+
+	return channels, nil
+}
+
+// dialVSock connects to the guest port of a vsock channel URL.
+func dialVSock(channelURL string) (net.Conn, error) {
+	if isHybridVSockURL(channelURL) {
+		return kataclient.HybridVSockDialer(channelURL, vsockTunnelDialTimeout)
+	}
+
+	// vsock://<cid>:<port>
+	addr := strings.Split(strings.TrimPrefix(channelURL, types.VSockScheme+"://"), ":")
+	if len(addr) != 2 {
+		return nil, fmt.Errorf("Invalid vsock URL %s", channelURL)
+	}
+
+	cid, err := strconv.ParseUint(addr[0], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid vsock URL %s: %v", channelURL, err)
+	}
+
+	port, err := strconv.ParseUint(addr[1], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid vsock URL %s: %v", channelURL, err)
+	}
+
+	return vsock.Dial(uint32(cid), uint32(port))
+}
+
+// vsockTunnel forwards the connections accepted by a host listener to a
+// guest vsock port.
+type vsockTunnel struct {
+	channelURL string
+	listener   net.Listener
+	logger     *logrus.Entry
+	wg         sync.WaitGroup
+}
+
+func newVSockTunnel(listener net.Listener, channelURL string, logger *logrus.Entry) *vsockTunnel {
+	t := &vsockTunnel{
+		channelURL: channelURL,
+		listener:   listener,
+		logger: logger.WithFields(logrus.Fields{
+			"address": listener.Addr().String(),
+			"vsock":   channelURL,
+		}),
+	}
+
+	t.wg.Add(1)
+	go t.serve()
+
+	return t
+}
+
+func (t *vsockTunnel) serve() {
+	defer t.wg.Done()
+
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			// the listener is closed with the tunnel
+			return
+		}
+
+		go t.forward(conn)
+	}
+}
+
+func (t *vsockTunnel) forward(conn net.Conn) {
+	defer conn.Close()
+
+	guestConn, err := dialVSock(t.channelURL)
+	if err != nil {
+		t.logger.WithError(err).Warn("Could not connect to the guest vsock port")
+		return
+	}
+	defer guestConn.Close()
+
+	done := make(chan struct{}, 2)
+	copyConn := func(dst, src net.Conn) {
+		io.Copy(dst, src)
+		done <- struct{}{}
+	}
+
+	go copyConn(guestConn, conn)
+	go copyConn(conn, guestConn)
+
+	// one side is done, closing both connections ends the other copy
+	<-done
+}
+
+// wait returns once the listener of the tunnel is closed.
+func (t *vsockTunnel) wait() {
+	t.wg.Wait()
+}
+
+// close closes the listener of the tunnel, a unix socket being removed.
+func (t *vsockTunnel) close() {
+	t.listener.Close()
+	t.wait()
+}
+
+// vsockPortURL returns the URL of a guest vsock port of the running sandbox.
+func (s *Sandbox) vsockPortURL(port uint32) (string, error) {
+	if s.state.State != types.StateRunning {
+		return "", vcTypes.Errorf(vcTypes.ErrCodeNotRunning, "Sandbox not running, impossible to reach a guest vsock port")
+	}
+
+	if port == 0 {
+		return "", fmt.Errorf("Missing vsock port")
+	}
+
+	agentURL, err := s.agent.getAgentURL()
+	if err != nil {
+		return "", err
+	}
+
+	return vsockChannelURL(agentURL, port)
+}
+
+// debugConsoleURL returns the URL of the vsock port on which the agent of
+// the running sandbox serves its debug console.
+func (s *Sandbox) debugConsoleURL() (string, error) {
+	if c, ok := s.config.AgentConfig.(KataAgentConfig); !ok || !c.DebugConsoleEnabled {
+		return "", fmt.Errorf("The agent of sandbox %s has no debug console, it must be enabled with debug_console_enabled", s.id)
+	}
+
+	return s.vsockPortURL(vSockDebugConsolePort)
+}
+
+// CreateVSockTunnel makes the guest vsock port reachable from the host
+// through a unix socket, whose path is returned. The tunnel lives as long as
+// the sandbox runs in this process and is closed when it is stopped.
+func (s *Sandbox) CreateVSockTunnel(port uint32) (string, error) {
+	channelURL, err := s.vsockPortURL(port)
+	if err != nil {
+		return "", err
+	}
+
+	s.vsockTunnelsLock.Lock()
+	defer s.vsockTunnelsLock.Unlock()
+
+	if t, ok := s.vsockTunnels[port]; ok {
+		return t.listener.Addr().String(), nil
+	}
+
+	socketPath, err := utils.BuildSocketPath(SandboxVMPath(s.newStore.RunVMStoragePath(), s.id), fmt.Sprintf("vsock-%d.sock", port))
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(socketPath), DirMode); err != nil {
+		return "", err
+	}
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return "", err
+	}
+
+	if s.vsockTunnels == nil {
+		s.vsockTunnels = make(map[uint32]*vsockTunnel)
+	}
+	s.vsockTunnels[port] = newVSockTunnel(listener, channelURL, s.Logger())
+
+	s.Logger().WithFields(logrus.Fields{
+		"port":   port,
+		"socket": socketPath,
+	}).Info("vsock tunnel created")
+
+	return socketPath, nil
+}
+
+// closeVSockTunnels closes the vsock tunnels of the sandbox.
+func (s *Sandbox) closeVSockTunnels() {
+	s.vsockTunnelsLock.Lock()
+	defer s.vsockTunnelsLock.Unlock()
+
+	for port, t := range s.vsockTunnels {
+		t.close()
+		delete(s.vsockTunnels, port)
+	}
+}
@@ -0,0 +1,268 @@
+// Copyright (c) 2020 Kata Containers Authors
+//
+// SPDX-License-Identifier: Apache-2.0
+//
+
+package virtcontainers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kata-containers/runtime/virtcontainers/persist"
+	"github.com/kata-containers/runtime/virtcontainers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckVSockChannels(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(checkVSockChannels(nil))
+	assert.NoError(checkVSockChannels(map[string]uint32{"port-forward": 2000, "metrics": 2001}))
+
+	for _, channels := range []map[string]uint32{
+		{"": 2000},
+		{agentVSockChannel: 2000},
+		{logsVSockChannel: 2000},
+		{debugConsoleVSockChannel: 2000},
+		{"port-forward": 22},
+		{"port-forward": vSockPort},
+		{"port-forward": vSockLogsPort},
+		{"port-forward": vSockDebugConsolePort},
+		{"port-forward": 2000, "metrics": 2000},
+	} {
+		assert.Error(checkVSockChannels(channels), "channels: %v", channels)
+	}
+}
+
+func TestVSockChannelURL(t *testing.T) {
+	assert := assert.New(t)
+
+	url, err := vsockChannelURL("vsock://3:1024", 2000)
+	assert.NoError(err)
+	assert.Equal("vsock://3:2000", url)
+
+	url, err = vsockChannelURL("hvsock:///run/vc/vm/foo/kata.hvsock:1024", 2000)
+	assert.NoError(err)
+	assert.Equal("hvsock:///run/vc/vm/foo/kata.hvsock:2000", url)
+
+	_, err = vsockChannelURL("/run/vc/vm/foo/kata.sock", 2000)
+	assert.Error(err)
+
+	_, err = vsockChannelURL("vsock://3", 2000)
+	assert.Error(err)
+}
+
+func TestSandboxVSockChannels(t *testing.T) {
+	assert := assert.New(t)
+
+	k := &kataAgent{
+		vmSocket: types.HybridVSock{
+			UdsPath: "/run/vc/vm/foo/kata.hvsock",
+			Port:    vSockPort,
+		},
+	}
+
+	s := &Sandbox{
+		agent: k,
+		config: &SandboxConfig{
+			HypervisorConfig: HypervisorConfig{
+				VSockChannels: map[string]uint32{"port-forward": 2000},
+			},
+		},
+	}
+
+	channels, err := s.vsockChannels()
+	assert.NoError(err)
+	assert.Equal(map[string]string{
+		agentVSockChannel: "hvsock:///run/vc/vm/foo/kata.hvsock:1024",
+		logsVSockChannel:  "hvsock:///run/vc/vm/foo/kata.hvsock:1025",
+		"port-forward":    "hvsock:///run/vc/vm/foo/kata.hvsock:2000",
+	}, channels)
+
+	// the agent doesn't send its logs through a vsock
+	k.vmSocket = types.VSock{ContextID: 3, Port: vSockPort}
+	channels, err = s.vsockChannels()
+	assert.NoError(err)
+	assert.Equal(map[string]string{
+		agentVSockChannel: "vsock://3:1024",
+		"port-forward":    "vsock://3:2000",
+	}, channels)
+
+	k.vmSocket = types.Socket{HostPath: "/run/vc/vm/foo/kata.sock"}
+	_, err = s.vsockChannels()
+	assert.Error(err)
+
+	s.config.HypervisorConfig.VSockChannels = nil
+	channels, err = s.vsockChannels()
+	assert.NoError(err)
+	assert.Empty(channels)
+}
+
+func TestSandboxDebugConsoleURL(t *testing.T) {
+	assert := assert.New(t)
+
+	s := &Sandbox{
+		id: testSandboxID,
+		agent: &kataAgent{
+			vmSocket: types.HybridVSock{
+				UdsPath: "/run/vc/vm/foo/kata.hvsock",
+				Port:    vSockPort,
+			},
+		},
+		config: &SandboxConfig{
+			AgentConfig: KataAgentConfig{},
+		},
+		state: types.SandboxState{
+			State: types.StateRunning,
+		},
+	}
+
+	_, err := s.debugConsoleURL()
+	assert.Error(err)
+
+	s.config.AgentConfig = KataAgentConfig{DebugConsoleEnabled: true}
+	url, err := s.debugConsoleURL()
+	assert.NoError(err)
+	assert.Equal("hvsock:///run/vc/vm/foo/kata.hvsock:1026", url)
+
+	s.state.State = types.StateReady
+	_, err = s.debugConsoleURL()
+	assert.Error(err)
+}
+
+// serveHybridVSock mimics the host side of a hybrid vsock, the guest port
+// echoing what it receives.
+func serveHybridVSock(l net.Listener, port uint32) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+
+		go func(conn net.Conn) {
+			defer conn.Close()
+
+			reader := bufio.NewReader(conn)
+			cmd, err := reader.ReadString('\n')
+			if err != nil || cmd != fmt.Sprintf("CONNECT %d\n", port) {
+				return
+			}
+
+			if _, err := conn.Write([]byte("OK 1073741824\n")); err != nil {
+				return
+			}
+
+			io.Copy(conn, reader)
+		}(conn)
+	}
+}
+
+func TestCreateVSockTunnel(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	udsPath := filepath.Join(tmpdir, "kata.hvsock")
+	l, err := net.Listen("unix", udsPath)
+	assert.NoError(err)
+	defer l.Close()
+
+	go serveHybridVSock(l, 2000)
+
+	store, err := persist.GetDriver()
+	assert.NoError(err)
+
+	s := &Sandbox{
+		id: testSandboxID,
+		agent: &kataAgent{
+			vmSocket: types.HybridVSock{
+				UdsPath: udsPath,
+				Port:    vSockPort,
+			},
+		},
+		newStore: store,
+		state: types.SandboxState{
+			State: types.StateReady,
+		},
+	}
+
+	_, err = s.CreateVSockTunnel(2000)
+	assert.Error(err)
+
+	s.state.State = types.StateRunning
+
+	_, err = s.CreateVSockTunnel(0)
+	assert.Error(err)
+
+	socketPath, err := s.CreateVSockTunnel(2000)
+	assert.NoError(err)
+	defer s.closeVSockTunnels()
+
+	// the tunnel is only created once per port
+	path, err := s.CreateVSockTunnel(2000)
+	assert.NoError(err)
+	assert.Equal(socketPath, path)
+
+	conn, err := net.Dial("unix", socketPath)
+	assert.NoError(err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping\n"))
+	assert.NoError(err)
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(err)
+	assert.Equal("ping\n", reply)
+
+	s.closeVSockTunnels()
+	assert.Empty(s.vsockTunnels)
+	_, err = os.Stat(socketPath)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestVSockTunnelTCP(t *testing.T) {
+	assert := assert.New(t)
+
+	tmpdir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(tmpdir)
+
+	udsPath := filepath.Join(tmpdir, "kata.hvsock")
+	l, err := net.Listen("unix", udsPath)
+	assert.NoError(err)
+	defer l.Close()
+
+	go serveHybridVSock(l, 2000)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(err)
+
+	tunnel := newVSockTunnel(listener, fmt.Sprintf("hvsock://%s:2000", udsPath), virtLog)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("ping\n"))
+	assert.NoError(err)
+
+	reply, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(err)
+	assert.Equal("ping\n", reply)
+
+	// the tunnel is done once its listener is closed
+	listener.Close()
+	tunnel.wait()
+
+	_, err = net.Dial("tcp", listener.Addr().String())
+	assert.Error(err)
+}